@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/manifest"
+	"github.com/vespaiach/auth/pkg/storage/mysql"
+)
+
+func main() {
+	var file = flag.String("f", "", "manifest file, yaml or json")
+	var dryRun = flag.Bool("dry-run", false, "only print the plan, don't apply it")
+	var prune = flag.Bool("prune", false, "delete keys, bunches and grants which aren't in the manifest")
+	var hash = flag.String("plan", "", "hash of a reviewed plan, the manifest is applied only if the plan is the same")
+	flag.Parse()
+
+	if len(*file) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	m, err := manifest.Decode(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	appConfig := cf.LoadAppConfig()
+
+	db, err := mysql.InitDb(appConfig.BuildMysqlDSN())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	mserv := manifest.NewService(mysql.NewManifestStorage(db))
+
+	if *dryRun {
+		plan, err := mserv.Plan(m, *prune)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(plan)
+		return
+	}
+
+	plan, err := mserv.Apply(m, *prune, *hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(plan)
+	if len(plan.Changes) > 0 {
+		fmt.Println("Applied.")
+	}
+}
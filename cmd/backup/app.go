@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/vespaiach/auth/pkg/backup"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/storage/mysql"
+)
+
+func main() {
+	exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+	exportOut := exportCmd.String("o", "", "output file, stdout if it's empty")
+	exportHashes := exportCmd.Bool("hashes", false, "include password hashes")
+
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+	importFile := importCmd.String("f", "", "document file to import")
+	importStrategy := importCmd.String("strategy", backup.StrategySkip, "conflict strategy: skip, overwrite or fail")
+	importDryRun := importCmd.Bool("dry-run", false, "only report what would be imported")
+
+	if len(os.Args) < 2 {
+		fmt.Println("usage: backup <export|import> [flags]")
+		os.Exit(2)
+	}
+
+	appConfig := cf.LoadAppConfig()
+
+	db, err := mysql.InitDb(appConfig.BuildMysqlDSN())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	bkserv := backup.NewService(mysql.NewBackupStorage(db), appConfig.BcryptCost)
+
+	switch os.Args[1] {
+	case "export":
+		exportCmd.Parse(os.Args[2:])
+
+		doc, err := bkserv.Export(*exportHashes)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		out := os.Stdout
+		if len(*exportOut) > 0 {
+			out, err = os.Create(*exportOut)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer out.Close()
+		}
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(doc); err != nil {
+			log.Fatal(err)
+		}
+	case "import":
+		importCmd.Parse(os.Args[2:])
+
+		f, err := os.Open(*importFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		doc := new(backup.Document)
+		if err := json.NewDecoder(f).Decode(doc); err != nil {
+			log.Fatal(err)
+		}
+
+		result, err := bkserv.Import(doc, *importStrategy, *importDryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("keys:         %+v\n", result.Keys)
+		fmt.Printf("bunches:      %+v\n", result.Bunches)
+		fmt.Printf("users:        %+v\n", result.Users)
+		fmt.Printf("bunch_keys:   %+v\n", result.BunchKeys)
+		fmt.Printf("user_bunches: %+v\n", result.UserBunches)
+	default:
+		fmt.Println("usage: backup <export|import> [flags]")
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/storage/mysql"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+func main() {
+	var file = flag.String("f", "", "csv or ndjson file of users")
+	var format = flag.String("format", "", "csv or ndjson, guessed from file extension if it's empty")
+	var dryRun = flag.Bool("dry-run", false, "only validate rows")
+	var batchSize = flag.Int("batch", 100, "number of users committed in one transaction")
+	flag.Parse()
+
+	if len(*file) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	var rows []*usrmgr.ImportRow
+	if *format == "ndjson" || (len(*format) == 0 && strings.HasSuffix(*file, ".ndjson")) {
+		rows, err = usrmgr.DecodeImportNDJSON(f)
+	} else {
+		rows, err = usrmgr.DecodeImportCSV(f)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	appConfig := cf.LoadAppConfig()
+
+	db, err := mysql.InitDb(appConfig.BuildMysqlDSN())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	userserv := usrmgr.NewService(mysql.NewUserStorage(db))
+
+	results, err := userserv.ImportUsers(rows, &usrmgr.ImportOptions{
+		DryRun:     *dryRun,
+		BatchSize:  *batchSize,
+		BcryptCost: appConfig.BcryptCost,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != nil {
+			failed++
+			fmt.Printf("line %d\t%s\t%s\t%s\n", r.Line, r.Username, r.Status, r.Error)
+		} else if len(r.Invite) > 0 {
+			fmt.Printf("line %d\t%s\t%s\t%s\n", r.Line, r.Username, r.Status, r.Invite)
+		} else {
+			fmt.Printf("line %d\t%s\t%s\n", r.Line, r.Username, r.Status)
+		}
+	}
+	fmt.Printf("%d rows, %d failed\n", len(results), failed)
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
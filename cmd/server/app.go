@@ -1,16 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/authn"
+	"github.com/vespaiach/auth/pkg/backup"
 	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/errcat"
+	"github.com/vespaiach/auth/pkg/i18n"
 	"github.com/vespaiach/auth/pkg/keymgr"
+	"github.com/vespaiach/auth/pkg/manifest"
+	"github.com/vespaiach/auth/pkg/rebac"
+	"github.com/vespaiach/auth/pkg/reviewmgr"
+	"github.com/vespaiach/auth/pkg/sessionmgr"
+	"github.com/vespaiach/auth/pkg/sso"
+	"github.com/vespaiach/auth/pkg/tenantmgr"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
 	"github.com/vespaiach/auth/pkg/usrmgr"
+	"github.com/vespaiach/auth/pkg/webhook"
 	"log"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/vespaiach/auth/pkg/cf"
 	"github.com/vespaiach/auth/pkg/storage/mysql"
 	"github.com/vespaiach/auth/pkg/tp"
+	"github.com/vespaiach/auth/pkg/tp/grpc"
 )
 
 func main() {
@@ -25,8 +42,57 @@ func main() {
 	keyserv := keymgr.NewService(mysql.NewKeyStorage(db))
 	bunchserv := bunchmgr.NewService(mysql.NewBunchStorage(db))
 	userserv := usrmgr.NewService(mysql.NewUserStorage(db))
+	reviewserv := reviewmgr.NewService(mysql.NewReviewStorage(db))
+	manifestserv := manifest.NewService(mysql.NewManifestStorage(db))
+	backupserv := backup.NewService(mysql.NewBackupStorage(db), appConfig.BcryptCost)
+	tenantserv := tenantmgr.NewService(mysql.NewTenantStorage(db))
+	relationserv := rebac.NewService(mysql.NewRelationStorage(db))
+	auditserv := audit.NewService(mysql.NewAuditStorage(db), appConfig.AuditHashChain)
 
-	http.Handle("/", tp.CreateRouter(appConfig, userserv, bunchserv, keyserv))
+	identityst := mysql.NewIdentityStorage(db)
+	ssoserv := sso.NewService(identityst, userserv, appConfig)
+	tokenserv := tokenmgr.NewService(mysql.NewTokenStorage(db), userserv)
+	sessionserv := sessionmgr.NewService(mysql.NewSessionStorage(db), userserv)
+
+	webhookst := mysql.NewWebhookStorage(db)
+	webhookserv := webhook.NewService(webhookst)
+	go webhook.NewDispatcher(webhookst, webhook.NewClient(10*time.Second), 5*time.Second).
+		Run(context.Background())
+
+	authenticator, err := authn.NewAuthenticator(appConfig, userserv, identityst)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	locales, err := i18n.Load(appConfig.LocaleDir, appConfig.DefaultLocale)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	catalog, err := errcat.Load(appConfig.ErrorFilePath, locales)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	http.Handle("/", tp.CreateRouter(appConfig, userserv, bunchserv, keyserv, reviewserv, manifestserv, backupserv,
+		authenticator, ssoserv, tokenserv, tenantserv, relationserv, auditserv, sessionserv,
+		webhookserv, catalog, locales))
+
+	// grpc calls are served alongside the http api once an address is given
+	if len(appConfig.GrpcAddress) > 0 {
+		lis, err := net.Listen("tcp", appConfig.GrpcAddress)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		srv := grpc.CreateServer(appConfig, userserv, bunchserv, keyserv, reviewserv, manifestserv, backupserv,
+			authenticator, ssoserv, tokenserv, tenantserv, relationserv, auditserv, sessionserv, webhookserv,
+			catalog, locales)
+		go func() {
+			fmt.Println("grpc address ", appConfig.GrpcAddress, " msg listening")
+			fmt.Println(srv.Serve(lis))
+		}()
+	}
 
 	fmt.Println("http address ", appConfig.ServerAddress, " msg listening")
 	fmt.Println(http.ListenAndServe(appConfig.ServerAddress, nil))
@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcomes of events. Changes are recorded as attempted before they're made, then with their outcome, so that no
+// change goes unrecorded when the outcome can't be recorded.
+const (
+	Attempted = "attempted"
+	Success   = "success"
+	Failure   = "failure"
+)
+
+// Event records one administrative change or authentication attempt. Before and After are json snapshots of the
+// target, Diff holds the fields which differ between them.
+type Event struct {
+	ID        int64
+	Actor     string
+	Action    string
+	Target    string
+	Before    string
+	After     string
+	Diff      string
+	IP        string
+	UserAgent string
+	Outcome   string
+	Error     string
+	CreatedAt time.Time
+
+	// PrevHash and Hash chain events of a tenant when chaining is on, they're empty otherwise
+	PrevHash string
+	Hash     string
+}
+
+// Filter picks events, empty fields match everything
+type Filter struct {
+	Actor   string
+	Action  string
+	Target  string
+	Outcome string
+	From    time.Time
+	To      time.Time
+}
+
+// Verification is the result of walking a tenant's hash chain
+type Verification struct {
+	Valid   bool
+	Checked int64
+
+	// BrokenAt is the id of the first event whose hash doesn't match, zero when the chain is valid
+	BrokenAt int64
+}
+
+// seal returns the hash of the event chained to the previous one. Times are stored with second precision,
+// so they're hashed the same way.
+func (e *Event) seal(prevHash string) string {
+	fields := []string{
+		prevHash,
+		e.Actor,
+		e.Action,
+		e.Target,
+		e.Before,
+		e.After,
+		e.Diff,
+		e.IP,
+		e.UserAgent,
+		e.Outcome,
+		e.Error,
+		strconv.FormatInt(e.CreatedAt.Unix(), 10),
+	}
+
+	// fields are quoted so that moving text between adjacent fields changes the hash
+	for i, f := range fields {
+		fields[i] = strconv.Quote(f)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff returns a json object of top level fields which differ between two json objects, each as
+// {"before": ..., "after": ...}. It's empty when there's nothing to compare.
+func Diff(before string, after string) string {
+	if len(before) == 0 && len(after) == 0 {
+		return ""
+	}
+
+	var b, a map[string]interface{}
+	if len(before) > 0 && json.Unmarshal([]byte(before), &b) != nil {
+		return ""
+	}
+	if len(after) > 0 && json.Unmarshal([]byte(after), &a) != nil {
+		return ""
+	}
+
+	changes := make(map[string]map[string]interface{})
+	for name, v := range b {
+		if w, ok := a[name]; !ok || !equal(v, w) {
+			changes[name] = map[string]interface{}{"before": v, "after": a[name]}
+		}
+	}
+	for name, w := range a {
+		if _, ok := b[name]; !ok {
+			changes[name] = map[string]interface{}{"before": nil, "after": w}
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+
+	return string(raw)
+}
+
+func equal(v interface{}, w interface{}) bool {
+	rv, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	rw, err := json.Marshal(w)
+	if err != nil {
+		return false
+	}
+
+	return string(rv) == string(rw)
+}
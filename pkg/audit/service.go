@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"github.com/vespaiach/auth/pkg/common"
+	"time"
+)
+
+// batch is how many events are read at once while exporting or verifying
+const batch int64 = 500
+
+type Storer interface {
+	AppendEvent(e *Event, seal func(prevHash string) string) (int64, error)
+	QueryEvents(take int64, skip int64, filter *Filter) ([]*Event, int64, error)
+	GetEventsAfter(id int64, take int64) ([]*Event, error)
+	WithTenant(tenantID int64) Storer
+}
+
+// Service keeps the append-only audit log, events can't be modified or removed through it
+type Service interface {
+	Record(e *Event) error
+	QueryEvents(page int64, perPage int64, filter *Filter) ([]*Event, int64, error)
+	ExportEvents(filter *Filter) ([]*Event, error)
+	Verify() (*Verification, error)
+	WithTenant(tenantID int64) Service
+}
+
+type service struct {
+	st    Storer
+	chain bool
+}
+
+// NewService creates the audit service, chain turns on hash chaining of new events
+func NewService(st Storer, chain bool) Service {
+	return &service{st, chain}
+}
+
+// WithTenant returns the service working on the audit log of the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st.WithTenant(tenantID), s.chain}
+}
+
+func (s *service) Record(e *Event) error {
+	if !isValidOutcome(e.Outcome) {
+		return common.ErrAuditOutcomeInvalid
+	}
+
+	e.CreatedAt = time.Now().Truncate(time.Second)
+
+	var seal func(string) string
+	if s.chain {
+		seal = e.seal
+	}
+
+	id, err := s.st.AppendEvent(e, seal)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+
+	return nil
+}
+
+func (s *service) QueryEvents(page int64, perPage int64, filter *Filter) ([]*Event, int64, error) {
+	if err := s.validateFilter(filter); err != nil {
+		return nil, 0, err
+	}
+
+	return s.st.QueryEvents(perPage, perPage*(page-1), filter)
+}
+
+// ExportEvents returns every event matching the filter, newest first. Events recorded while exporting are left out.
+func (s *service) ExportEvents(filter *Filter) ([]*Event, error) {
+	if err := s.validateFilter(filter); err != nil {
+		return nil, err
+	}
+
+	frozen := *filter
+	if frozen.To.IsZero() {
+		frozen.To = time.Now()
+	}
+
+	events := make([]*Event, 0)
+	for skip := int64(0); ; skip += batch {
+		lst, _, err := s.st.QueryEvents(batch, skip, &frozen)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, lst...)
+
+		if int64(len(lst)) < batch {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// Verify walks the hash chain from the first event. Events recorded while chaining was off aren't part of it.
+func (s *service) Verify() (*Verification, error) {
+	result := &Verification{Valid: true}
+
+	var (
+		lastID   int64
+		prevHash string
+	)
+	for {
+		lst, err := s.st.GetEventsAfter(lastID, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range lst {
+			lastID = e.ID
+			if len(e.Hash) == 0 {
+				continue
+			}
+
+			result.Checked++
+			if e.PrevHash != prevHash || e.seal(prevHash) != e.Hash {
+				result.Valid = false
+				result.BrokenAt = e.ID
+				return result, nil
+			}
+			prevHash = e.Hash
+		}
+
+		if int64(len(lst)) < batch {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func (s *service) validateFilter(filter *Filter) error {
+	if len(filter.Outcome) > 0 && !isValidOutcome(filter.Outcome) {
+		return common.ErrAuditOutcomeInvalid
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return common.ErrAuditPeriodInvalid
+	}
+
+	return nil
+}
+
+func isValidOutcome(outcome string) bool {
+	return outcome == Attempted || outcome == Success || outcome == Failure
+}
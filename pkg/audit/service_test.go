@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+// events is an in-memory Storer
+type events struct {
+	rows []*Event
+}
+
+func (st *events) AppendEvent(e *Event, seal func(prevHash string) string) (int64, error) {
+	if seal != nil {
+		var prevHash string
+		for _, r := range st.rows {
+			if len(r.Hash) > 0 {
+				prevHash = r.Hash
+			}
+		}
+		e.PrevHash = prevHash
+		e.Hash = seal(prevHash)
+	}
+
+	stored := *e
+	stored.ID = int64(len(st.rows) + 1)
+	st.rows = append(st.rows, &stored)
+
+	return stored.ID, nil
+}
+
+func (st *events) QueryEvents(take int64, skip int64, filter *Filter) ([]*Event, int64, error) {
+	matched := make([]*Event, 0)
+	for i := len(st.rows) - 1; i >= 0; i-- {
+		e := st.rows[i]
+		if (len(filter.Actor) == 0 || e.Actor == filter.Actor) && (filter.To.IsZero() || !e.CreatedAt.After(filter.To)) {
+			matched = append(matched, e)
+		}
+	}
+
+	total := int64(len(matched))
+	if skip >= total {
+		return []*Event{}, total, nil
+	}
+	end := skip + take
+	if end > total {
+		end = total
+	}
+
+	return matched[skip:end], total, nil
+}
+
+func (st *events) GetEventsAfter(id int64, take int64) ([]*Event, error) {
+	lst := make([]*Event, 0)
+	for _, e := range st.rows {
+		if e.ID > id && int64(len(lst)) < take {
+			lst = append(lst, e)
+		}
+	}
+	return lst, nil
+}
+
+func (st *events) WithTenant(tenantID int64) Storer {
+	return st
+}
+
+func TestService_Verify(t *testing.T) {
+	st := &events{}
+	s := NewService(st, true)
+
+	for _, actor := range []string{"admin", "staff", "admin"} {
+		require.Nil(t, s.Record(&Event{Actor: actor, Action: "modify_user", Outcome: Success}))
+	}
+
+	v, err := s.Verify()
+	require.Nil(t, err)
+	require.True(t, v.Valid)
+	require.Equal(t, int64(3), v.Checked)
+	require.Equal(t, st.rows[0].Hash, st.rows[1].PrevHash)
+
+	st.rows[1].Actor = "intruder"
+
+	v, err = s.Verify()
+	require.Nil(t, err)
+	require.False(t, v.Valid)
+	require.Equal(t, int64(2), v.BrokenAt)
+}
+
+func TestService_Verify_Unchained(t *testing.T) {
+	st := &events{}
+
+	require.Nil(t, NewService(st, false).Record(&Event{Action: "login", Outcome: Failure}))
+	require.Empty(t, st.rows[0].Hash)
+
+	s := NewService(st, true)
+	require.Nil(t, s.Record(&Event{Action: "login", Outcome: Success}))
+
+	v, err := s.Verify()
+	require.Nil(t, err)
+	require.True(t, v.Valid)
+	require.Equal(t, int64(1), v.Checked)
+}
+
+func TestService_Record(t *testing.T) {
+	s := NewService(&events{}, false)
+
+	err := s.Record(&Event{Action: "login", Outcome: "maybe"})
+	require.Equal(t, common.ErrAuditOutcomeInvalid, err)
+	require.Nil(t, s.Record(&Event{Action: "add_key", Outcome: Attempted}))
+
+	_, _, err = s.QueryEvents(1, 10, &Filter{From: time.Now(), To: time.Now().Add(-time.Hour)})
+	require.Equal(t, common.ErrAuditPeriodInvalid, err)
+}
+
+func TestService_ExportEvents(t *testing.T) {
+	s := NewService(&events{}, false)
+
+	for i := int64(0); i < batch+1; i++ {
+		require.Nil(t, s.Record(&Event{Actor: "admin", Action: "add_key", Outcome: Success}))
+	}
+	require.Nil(t, s.Record(&Event{Actor: "staff", Action: "add_key", Outcome: Success}))
+
+	lst, err := s.ExportEvents(&Filter{Actor: "admin"})
+	require.Nil(t, err)
+	require.Len(t, lst, int(batch+1))
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		before string
+		after  string
+		diff   string
+	}{
+		{"", "", ""},
+		{`{"a":1}`, `{"a":1}`, ""},
+		{`{"a":1,"b":[1]}`, `{"a":2,"b":[1]}`, `{"a":{"after":2,"before":1}}`},
+		{"", `{"a":1}`, `{"a":{"after":1,"before":null}}`},
+		{`{"a":1}`, "", `{"a":{"after":null,"before":1}}`},
+		{"not json", `{"a":1}`, ""},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.diff, Diff(tt.before, tt.after), tt.before+" -> "+tt.after)
+	}
+}
@@ -0,0 +1,86 @@
+package authn
+
+import (
+	"log"
+
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/sso"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// Authenticator checks user's credentials. It returns common.ErrUserNotFound when it doesn't know
+// the user and common.ErrWrongCredentials when the password is wrong.
+type Authenticator interface {
+	Authenticate(username string, password string) (*usrmgr.User, error)
+	WithTenant(tenantID int64) Authenticator
+}
+
+// Chain tries authenticators in order until one of them accepts the credentials
+type Chain []Authenticator
+
+// WithTenant returns the chain authenticating users of the tenant
+func (c Chain) WithTenant(tenantID int64) Authenticator {
+	scoped := make(Chain, 0, len(c))
+	for _, a := range c {
+		scoped = append(scoped, a.WithTenant(tenantID))
+	}
+
+	return scoped
+}
+
+func (c Chain) Authenticate(username string, password string) (*usrmgr.User, error) {
+	result := common.ErrUserNotFound
+
+	for _, a := range c {
+		user, err := a.Authenticate(username, password)
+		if err == nil {
+			return user, nil
+		}
+
+		switch err {
+		case common.ErrUserNotFound:
+			break
+		case common.ErrWrongCredentials:
+			result = err
+			break
+		default:
+			// a backend is unavailable, the next ones may still know the user
+			log.Println(err)
+			if result == common.ErrUserNotFound {
+				result = err
+			}
+			break
+		}
+	}
+
+	return nil, result
+}
+
+// NewAuthenticator builds the chain of backends listed in AUTH_BACKENDS, identities link users to entries of
+// the directory
+func NewAuthenticator(appConfig *cf.AppConfig, userServ usrmgr.Service, identities sso.Storer) (Authenticator, error) {
+	chain := make(Chain, 0, len(appConfig.AuthBackends))
+
+	for _, backend := range appConfig.AuthBackends {
+		switch backend {
+		case "local":
+			chain = append(chain, NewLocal(userServ))
+			break
+		case "ldap":
+			if len(appConfig.Ldap.URL) == 0 {
+				return nil, common.ErrAuthBackendInvalid
+			}
+			chain = append(chain, NewLDAP(appConfig.Ldap, userServ, identities, appConfig.BcryptCost))
+			break
+		default:
+			return nil, common.ErrAuthBackendInvalid
+		}
+	}
+
+	if len(chain) == 0 {
+		return nil, common.ErrAuthBackendInvalid
+	}
+
+	return chain, nil
+}
@@ -0,0 +1,242 @@
+package authn
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/sso"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+const ldapTimeout = 10 * time.Second
+
+// ldapProvider names the directory in links of identities to users
+const ldapProvider = "ldap"
+
+// Conn is the part of an ldap connection which is needed for authenticating
+type Conn interface {
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close()
+}
+
+// Dialer opens a new ldap connection
+type Dialer func() (Conn, error)
+
+// LDAP authenticates with search-then-bind: it searches for the user's entry, then binds as that entry
+// with the given password. Users are provisioned into users table on their first login and linked to
+// their entries, and their mapped bunches are kept in sync with ldap groups on every login. Entries only
+// log in as users linked to them, a local user of the same name is never taken over.
+type LDAP struct {
+	config     *cf.LdapConfig
+	users      usrmgr.Service
+	identities sso.Storer
+	dial       Dialer
+	bcryptCost int
+}
+
+func NewLDAP(config *cf.LdapConfig, users usrmgr.Service, identities sso.Storer, bcryptCost int) *LDAP {
+	return &LDAP{config, users, identities, dialer(config), bcryptCost}
+}
+
+// NewLDAPWithDialer is the same as NewLDAP but uses the given dialer, e.g. one connecting to a stand-in server
+func NewLDAPWithDialer(config *cf.LdapConfig, users usrmgr.Service, identities sso.Storer, bcryptCost int,
+	dial Dialer) *LDAP {
+
+	return &LDAP{config, users, identities, dial, bcryptCost}
+}
+
+// WithTenant returns the authenticator provisioning users into the tenant. The directory is the
+// same for every tenant, an entry is linked to one user of each tenant.
+func (l *LDAP) WithTenant(tenantID int64) Authenticator {
+	return &LDAP{l.config, l.users.WithTenant(tenantID), l.identities.WithTenant(tenantID), l.dial, l.bcryptCost}
+}
+
+func (l *LDAP) Authenticate(username string, password string) (*usrmgr.User, error) {
+	// an empty password makes an unauthenticated bind, which ldap servers accept
+	if len(password) == 0 {
+		return nil, common.ErrWrongCredentials
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if len(l.config.BindDN) > 0 {
+		if err := conn.Bind(l.config.BindDN, l.config.BindPassword); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		l.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		fmt.Sprintf(l.config.UserFilter, ldap.EscapeFilter(username)),
+		[]string{l.config.EmailAttribute, l.config.GroupAttribute},
+		nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) != 1 {
+		return nil, common.ErrUserNotFound
+	}
+
+	entry := result.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, common.ErrWrongCredentials
+		}
+		return nil, err
+	}
+
+	return l.provision(entry.DN, username, entry.GetAttributeValue(l.config.EmailAttribute),
+		entry.GetAttributeValues(l.config.GroupAttribute))
+}
+
+// provision finds the user linked to the entry, or creates and links it when the entry logs in for the first
+// time, then syncs its mapped bunches
+func (l *LDAP) provision(dn string, username string, email string, groups []string) (*usrmgr.User, error) {
+	// entries are linked in every tenant on their own, identities are kept by tenant
+	subject := strings.ToLower(dn)
+
+	identity, err := l.identities.GetIdentity(ldapProvider, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *usrmgr.User
+	if identity != nil {
+		if user, err = l.users.GetUser(identity.UserID); err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, common.ErrIdentityNotLinked
+		}
+	} else {
+		existing, err := l.users.GetUserByUsername(username)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, common.ErrIdentityNotLinked
+		}
+
+		// provisioned users get an unusable password, so they can only login through ldap
+		hash, err := usrmgr.HashPassword("", l.bcryptCost)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := l.users.AddExternalUser(username, email, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := l.identities.AddIdentity(ldapProvider, subject, id); err != nil {
+			return nil, err
+		}
+
+		if user, err = l.users.GetUser(id); err != nil {
+			return nil, err
+		}
+	}
+
+	// a service account can't be taken over by a directory entry of the same name
+	if isInactive(user) || user.IsServiceAccount() {
+		return nil, common.ErrWrongCredentials
+	}
+
+	// a linked user may have been renamed since, its bunches are synced under its current name
+	if err := l.syncBunches(user.Username, groups); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// syncBunches grants bunches mapped from user's groups and takes back mapped bunches of groups
+// which the user has left
+func (l *LDAP) syncBunches(username string, groups []string) error {
+	if len(l.config.GroupMapping) == 0 {
+		return nil
+	}
+
+	wanted := make([]string, 0)
+	for _, g := range groups {
+		if bunch, ok := l.config.GroupMapping[strings.ToLower(g)]; ok {
+			wanted = append(wanted, bunch)
+		}
+	}
+
+	managed := make([]string, 0, len(l.config.GroupMapping))
+	for _, bunch := range l.config.GroupMapping {
+		managed = append(managed, bunch)
+	}
+
+	return l.users.SyncBunches(username, managed, wanted)
+}
+
+func dialer(config *cf.LdapConfig) Dialer {
+	return func() (Conn, error) {
+		tlsConfig, err := newTLSConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := ldap.DialURL(config.URL, ldap.DialWithTLSConfig(tlsConfig),
+			ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+		if err != nil {
+			return nil, err
+		}
+		conn.SetTimeout(ldapTimeout)
+
+		if config.StartTLS {
+			if err := conn.StartTLS(tlsConfig); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+
+		return conn, nil
+	}
+}
+
+func newTLSConfig(config *cf.LdapConfig) (*tls.Config, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if len(config.CACertFile) > 0 {
+		pem, err := ioutil.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + config.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,329 @@
+package authn
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/sso"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// directory is an in-process ldap stand-in which knows entries and their passwords
+type directory struct {
+	entries   []*ldap.Entry
+	passwords map[string]string
+}
+
+type directoryConn struct {
+	dir *directory
+}
+
+func (c *directoryConn) Bind(dn, password string) error {
+	if p, ok := c.dir.passwords[dn]; ok && p == password {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, nil)
+}
+
+func (c *directoryConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result := new(ldap.SearchResult)
+	for _, e := range c.dir.entries {
+		uid := e.GetAttributeValue("uid")
+		if req.Filter == "(uid="+uid+")" && strings.HasSuffix(e.DN, req.BaseDN) {
+			result.Entries = append(result.Entries, e)
+		}
+	}
+	return result, nil
+}
+
+func (c *directoryConn) Close() {}
+
+// users is an in-memory usrmgr.Service
+type users struct {
+	usrmgr.Service
+	byName  map[string]*usrmgr.User
+	bunches map[string][]string
+}
+
+func newUsers() *users {
+	return &users{byName: make(map[string]*usrmgr.User), bunches: make(map[string][]string)}
+}
+
+func (u *users) GetUserByUsername(username string) (*usrmgr.User, error) {
+	return u.byName[username], nil
+}
+
+func (u *users) GetUser(id int64) (*usrmgr.User, error) {
+	for _, user := range u.byName {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (u *users) AddUser(username string, email string, hash string) (int64, error) {
+	id := int64(len(u.byName) + 1)
+	u.byName[username] = &usrmgr.User{ID: id, Username: username, Email: email, Hash: hash,
+		Active: sql.NullBool{Bool: true, Valid: true}}
+	return id, nil
+}
+
+func (u *users) AddExternalUser(username string, email string, hash string) (int64, error) {
+	return u.AddUser(username, email, hash)
+}
+
+func (u *users) WithTenant(tenantID int64) usrmgr.Service {
+	return u
+}
+
+func (u *users) SyncBunches(username string, managed []string, wanted []string) error {
+	kept := make([]string, 0)
+	for _, b := range u.bunches[username] {
+		if !contains(managed, b) && !contains(wanted, b) {
+			kept = append(kept, b)
+		}
+	}
+	u.bunches[username] = append(kept, wanted...)
+	return nil
+}
+
+// identities is an in-memory sso.Storer, links are keyed by tenant, provider and subject
+type identities struct {
+	links  map[string]int64
+	tenant int64
+}
+
+func newIdentities(links map[string]int64) *identities {
+	return &identities{links, common.DefaultTenant}
+}
+
+func (i *identities) key(provider string, subject string) string {
+	return fmt.Sprintf("%d/%s/%s", i.tenant, provider, subject)
+}
+
+func (i *identities) GetIdentity(provider string, subject string) (*sso.Identity, error) {
+	id, ok := i.links[i.key(provider, subject)]
+	if !ok {
+		return nil, nil
+	}
+	return &sso.Identity{Provider: provider, Subject: subject, UserID: id}, nil
+}
+
+func (i *identities) AddIdentity(provider string, subject string, userID int64) (int64, error) {
+	i.links[i.key(provider, subject)] = userID
+	return int64(len(i.links)), nil
+}
+
+func (i *identities) WithTenant(tenantID int64) sso.Storer {
+	return &identities{i.links, tenantID}
+}
+
+func contains(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestLDAP(us *users) *LDAP {
+	return newTestLDAPWithIdentities(us, newIdentities(make(map[string]int64)))
+}
+
+func newTestLDAPWithIdentities(us *users, ids *identities) *LDAP {
+	dir := &directory{
+		entries: []*ldap.Entry{
+			ldap.NewEntry("uid=jane,ou=people,dc=example,dc=com", map[string][]string{
+				"uid":      {"jane"},
+				"mail":     {"jane@example.com"},
+				"memberOf": {"CN=Admins,OU=Groups,DC=example,DC=com"},
+			}),
+			ldap.NewEntry("uid=admin,ou=people,dc=example,dc=com", map[string][]string{
+				"uid":      {"admin"},
+				"memberOf": {"CN=Staff,OU=Groups,DC=example,DC=com"},
+			}),
+		},
+		passwords: map[string]string{
+			"cn=reader,dc=example,dc=com":           "reader_secret",
+			"uid=jane,ou=people,dc=example,dc=com":  "jane_secret",
+			"uid=admin,ou=people,dc=example,dc=com": "admin_secret",
+		},
+	}
+
+	config := &cf.LdapConfig{
+		BindDN:         "cn=reader,dc=example,dc=com",
+		BindPassword:   "reader_secret",
+		BaseDN:         "dc=example,dc=com",
+		UserFilter:     "(uid=%s)",
+		EmailAttribute: "mail",
+		GroupAttribute: "memberOf",
+		GroupMapping: map[string]string{
+			"cn=admins,ou=groups,dc=example,dc=com": "admin",
+			"cn=staff,ou=groups,dc=example,dc=com":  "staff",
+		},
+	}
+
+	return NewLDAPWithDialer(config, us, ids, bcrypt.MinCost, func() (Conn, error) {
+		return &directoryConn{dir}, nil
+	})
+}
+
+func TestLDAP_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_provision_user_and_map_groups", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		us.bunches["jane"] = []string{"staff", "unmanaged"}
+
+		user, err := newTestLDAP(us).Authenticate("jane", "jane_secret")
+		require.Nil(t, err)
+		require.Equal(t, "jane", user.Username)
+		require.Equal(t, "jane@example.com", user.Email)
+		require.ElementsMatch(t, []string{"unmanaged", "admin"}, us.bunches["jane"])
+	})
+
+	t.Run("fail_wrong_password", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		_, err := newTestLDAP(us).Authenticate("jane", "wrong")
+		require.Equal(t, common.ErrWrongCredentials, err)
+		require.Nil(t, us.byName["jane"])
+	})
+
+	t.Run("fail_empty_password", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newTestLDAP(newUsers()).Authenticate("jane", "")
+		require.Equal(t, common.ErrWrongCredentials, err)
+	})
+
+	t.Run("fail_unknown_user", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newTestLDAP(newUsers()).Authenticate("john", "secret")
+		require.Equal(t, common.ErrUserNotFound, err)
+	})
+
+	t.Run("success_linked_user", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		us.byName["jane"] = &usrmgr.User{ID: 7, Username: "jane", Active: sql.NullBool{Bool: true, Valid: true}}
+		ids := newIdentities(map[string]int64{"1/ldap/uid=jane,ou=people,dc=example,dc=com": 7})
+
+		user, err := newTestLDAPWithIdentities(us, ids).Authenticate("jane", "jane_secret")
+		require.Nil(t, err)
+		require.Equal(t, int64(7), user.ID)
+		require.Equal(t, []string{"admin"}, us.bunches["jane"])
+	})
+
+	t.Run("success_renamed_linked_user", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		us.byName["jane.doe"] = &usrmgr.User{ID: 7, Username: "jane.doe", Active: sql.NullBool{Bool: true, Valid: true}}
+		us.bunches["jane.doe"] = []string{"staff"}
+		ids := newIdentities(map[string]int64{"1/ldap/uid=jane,ou=people,dc=example,dc=com": 7})
+
+		user, err := newTestLDAPWithIdentities(us, ids).Authenticate("jane", "jane_secret")
+		require.Nil(t, err)
+		require.Equal(t, "jane.doe", user.Username)
+		require.Equal(t, []string{"admin"}, us.bunches["jane.doe"])
+		require.Empty(t, us.bunches["jane"])
+	})
+
+	t.Run("success_provision_user_without_email", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		ids := newIdentities(make(map[string]int64))
+
+		user, err := newTestLDAPWithIdentities(us, ids).Authenticate("admin", "admin_secret")
+		require.Nil(t, err)
+		require.Empty(t, user.Email)
+		require.Contains(t, ids.links, "1/ldap/uid=admin,ou=people,dc=example,dc=com")
+
+		// entries are linked in each tenant on their own
+		_, err = newTestLDAPWithIdentities(us, ids).WithTenant(2).Authenticate("jane", "jane_secret")
+		require.Nil(t, err)
+		require.Contains(t, ids.links, "2/ldap/uid=jane,ou=people,dc=example,dc=com")
+	})
+
+	t.Run("fail_local_user_of_same_name", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		us.byName["admin"] = &usrmgr.User{ID: 1, Username: "admin", Active: sql.NullBool{Bool: true, Valid: true}}
+		us.bunches["admin"] = []string{"admin"}
+
+		_, err := newTestLDAP(us).Authenticate("admin", "admin_secret")
+		require.Equal(t, common.ErrIdentityNotLinked, err)
+		require.Equal(t, []string{"admin"}, us.bunches["admin"])
+	})
+
+	t.Run("fail_inactive_user", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		us.byName["jane"] = &usrmgr.User{ID: 1, Username: "jane", Active: sql.NullBool{Bool: false, Valid: true}}
+		ids := newIdentities(map[string]int64{"1/ldap/uid=jane,ou=people,dc=example,dc=com": 1})
+
+		_, err := newTestLDAPWithIdentities(us, ids).Authenticate("jane", "jane_secret")
+		require.Equal(t, common.ErrWrongCredentials, err)
+	})
+}
+
+func TestChain_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_fall_through_to_ldap", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		chain := Chain{NewLocal(us), newTestLDAP(us)}
+
+		user, err := chain.Authenticate("jane", "jane_secret")
+		require.Nil(t, err)
+		require.Equal(t, "jane", user.Username)
+
+		// jane is provisioned now, local backend rejects her password and ldap accepts it again
+		user, err = chain.Authenticate("jane", "jane_secret")
+		require.Nil(t, err)
+		require.Equal(t, "jane", user.Username)
+	})
+
+	t.Run("success_local_user", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+		us.AddUser("bob", "bob@example.com", string(hash))
+
+		user, err := Chain{NewLocal(us), newTestLDAP(us)}.Authenticate("bob", "password")
+		require.Nil(t, err)
+		require.Equal(t, "bob", user.Username)
+	})
+
+	t.Run("fail_wrong_credentials_wins_over_not_found", func(t *testing.T) {
+		t.Parallel()
+
+		us := newUsers()
+		hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+		us.AddUser("bob", "bob@example.com", string(hash))
+
+		_, err := Chain{NewLocal(us), newTestLDAP(us)}.Authenticate("bob", "wrong")
+		require.Equal(t, common.ErrWrongCredentials, err)
+	})
+}
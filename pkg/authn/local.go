@@ -0,0 +1,46 @@
+package authn
+
+import (
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Local checks passwords against bcrypt hashes in users table
+type Local struct {
+	users usrmgr.Service
+}
+
+func NewLocal(users usrmgr.Service) *Local {
+	return &Local{users}
+}
+
+// WithTenant returns the authenticator checking passwords of users of the tenant
+func (l *Local) WithTenant(tenantID int64) Authenticator {
+	return &Local{l.users.WithTenant(tenantID)}
+}
+
+func (l *Local) Authenticate(username string, password string) (*usrmgr.User, error) {
+	user, err := l.users.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	// deactivated users, e.g. deprovisioned through scim, can't login, nor can service accounts
+	if isInactive(user) || user.IsServiceAccount() {
+		return nil, common.ErrWrongCredentials
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Hash), []byte(password)) != nil {
+		return nil, common.ErrWrongCredentials
+	}
+
+	return user, nil
+}
+
+func isInactive(user *usrmgr.User) bool {
+	return user.Active.Valid && !user.Active.Bool
+}
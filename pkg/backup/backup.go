@@ -0,0 +1,73 @@
+package backup
+
+import "time"
+
+// Version of the document format written by Export
+const Version = 1
+
+// Conflict strategies of an import
+const (
+	StrategySkip      = "skip"
+	StrategyOverwrite = "overwrite"
+	StrategyFail      = "fail"
+)
+
+// Document is a full dump of keys, bunches, users and their relations. Rows reference each other by
+// name rather than by id so that a document can be loaded into another environment.
+type Document struct {
+	Version     int          `json:"version"`
+	ExportedAt  time.Time    `json:"exported_at"`
+	Keys        []*Key       `json:"keys"`
+	Bunches     []*Bunch     `json:"bunches"`
+	Users       []*User      `json:"users"`
+	BunchKeys   []*BunchKey  `json:"bunch_keys"`
+	UserBunches []*UserBunch `json:"user_bunches"`
+}
+
+type Key struct {
+	Key  string `json:"key"`
+	Desc string `json:"desc"`
+}
+
+type Bunch struct {
+	Name   string `json:"name"`
+	Desc   string `json:"desc"`
+	Active bool   `json:"active"`
+}
+
+type User struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Hash     string `json:"hash,omitempty"`
+	Active   bool   `json:"active"`
+	Type     string `json:"type,omitempty"`
+	Owner    string `json:"owner,omitempty"`
+	Desc     string `json:"desc,omitempty"`
+}
+
+type BunchKey struct {
+	Bunch     string `json:"bunch"`
+	Key       string `json:"key"`
+	Condition string `json:"condition,omitempty"`
+}
+
+type UserBunch struct {
+	Username  string `json:"username"`
+	Bunch     string `json:"bunch"`
+	Condition string `json:"condition,omitempty"`
+}
+
+// Counter counts what an import did with rows of one kind
+type Counter struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+type Result struct {
+	Keys        Counter `json:"keys"`
+	Bunches     Counter `json:"bunches"`
+	Users       Counter `json:"users"`
+	BunchKeys   Counter `json:"bunch_keys"`
+	UserBunches Counter `json:"user_bunches"`
+}
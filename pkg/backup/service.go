@@ -0,0 +1,297 @@
+package backup
+
+import (
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/condition"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"sort"
+	"time"
+)
+
+type Storer interface {
+	Export(withHashes bool) (*Document, error)
+	Import(writes *Document) error
+
+	// GetExclusions returns bunches of each exclusion by the exclusion's name
+	GetExclusions() (map[string][]string, error)
+
+	WithTenant(tenantID int64) Storer
+}
+
+type Service interface {
+	Export(withHashes bool) (*Document, error)
+	Import(doc *Document, strategy string, dryRun bool) (*Result, error)
+	WithTenant(tenantID int64) Service
+}
+
+type service struct {
+	st         Storer
+	bcryptCost int
+}
+
+// NewService creates the service, bcryptCost is the cost of hashes given to imported users which have none
+func NewService(st Storer, bcryptCost int) Service {
+	return &service{st, bcryptCost}
+}
+
+// WithTenant returns the service exporting and importing data of the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st.WithTenant(tenantID), s.bcryptCost}
+}
+
+func (s *service) Export(withHashes bool) (*Document, error) {
+	doc, err := s.st.Export(withHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Version = Version
+	doc.ExportedAt = time.Now()
+
+	return doc, nil
+}
+
+// Import writes rows which don't exist yet. Rows which exist with the same values are left as they
+// are, so importing a document twice is a no-op. Rows which exist with different values are
+// conflicts and handled by strategy. Users are checked as the user service checks the users which it adds, and
+// users without a hash are given an unusable one.
+func (s *service) Import(doc *Document, strategy string, dryRun bool) (*Result, error) {
+	if doc.Version < 1 || doc.Version > Version {
+		return nil, common.ErrUnsupportedVersion
+	}
+
+	if strategy != StrategySkip && strategy != StrategyOverwrite && strategy != StrategyFail {
+		return nil, common.ErrImportStrategyInvalid
+	}
+
+	for _, bk := range doc.BunchKeys {
+		if len(bk.Condition) > 0 && condition.Validate(bk.Condition) != nil {
+			return nil, common.ErrConditionInvalid
+		}
+	}
+	for _, ub := range doc.UserBunches {
+		if len(ub.Condition) > 0 && condition.Validate(ub.Condition) != nil {
+			return nil, common.ErrConditionInvalid
+		}
+	}
+	for _, u := range doc.Users {
+		// documents exported before service accounts have no user types
+		if len(u.Type) == 0 {
+			u.Type = usrmgr.TypeHuman
+		}
+		if u.Type != usrmgr.TypeHuman && u.Type != usrmgr.TypeService {
+			return nil, common.ErrUserTypeInvalid
+		}
+		if err := usrmgr.ValidateExternalUser(u.Username, u.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := s.st.Export(true)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(Result)
+	writes := new(Document)
+
+	keys := make(map[string]*Key, len(current.Keys))
+	for _, k := range current.Keys {
+		keys[k.Key] = k
+	}
+	for _, k := range doc.Keys {
+		existing, ok := keys[k.Key]
+		switch {
+		case !ok:
+			result.Keys.Created++
+		case *existing == *k:
+			continue
+		case strategy == StrategyFail:
+			return nil, &common.ImportConflictError{Kind: "key", Name: k.Key}
+		case strategy == StrategySkip:
+			result.Keys.Skipped++
+			continue
+		default:
+			result.Keys.Updated++
+		}
+		writes.Keys = append(writes.Keys, k)
+	}
+
+	bunches := make(map[string]*Bunch, len(current.Bunches))
+	for _, b := range current.Bunches {
+		bunches[b.Name] = b
+	}
+	for _, b := range doc.Bunches {
+		existing, ok := bunches[b.Name]
+		switch {
+		case !ok:
+			result.Bunches.Created++
+		case *existing == *b:
+			continue
+		case strategy == StrategyFail:
+			return nil, &common.ImportConflictError{Kind: "bunch", Name: b.Name}
+		case strategy == StrategySkip:
+			result.Bunches.Skipped++
+			continue
+		default:
+			result.Bunches.Updated++
+		}
+		writes.Bunches = append(writes.Bunches, b)
+	}
+
+	users := make(map[string]*User, len(current.Users))
+	for _, u := range current.Users {
+		users[u.Username] = u
+	}
+	for _, u := range doc.Users {
+		existing, ok := users[u.Username]
+		switch {
+		case !ok:
+			result.Users.Created++
+		case existing.Email == u.Email && existing.Active == u.Active && existing.Type == u.Type &&
+			existing.Owner == u.Owner && existing.Desc == u.Desc && (len(u.Hash) == 0 || existing.Hash == u.Hash):
+			continue
+		case strategy == StrategyFail:
+			return nil, &common.ImportConflictError{Kind: "user", Name: u.Username}
+		case strategy == StrategySkip:
+			result.Users.Skipped++
+			continue
+		default:
+			result.Users.Updated++
+		}
+		writes.Users = append(writes.Users, u)
+	}
+
+	// grants are matched without their conditions, conditions of existing grants are kept
+	bunchKeys := make(map[BunchKey]bool, len(current.BunchKeys))
+	for _, bk := range current.BunchKeys {
+		bunchKeys[BunchKey{Bunch: bk.Bunch, Key: bk.Key}] = true
+	}
+	for _, bk := range doc.BunchKeys {
+		if !bunchKeys[BunchKey{Bunch: bk.Bunch, Key: bk.Key}] {
+			result.BunchKeys.Created++
+			writes.BunchKeys = append(writes.BunchKeys, bk)
+		}
+	}
+
+	userBunches := make(map[UserBunch]bool, len(current.UserBunches))
+	for _, ub := range current.UserBunches {
+		userBunches[UserBunch{Username: ub.Username, Bunch: ub.Bunch}] = true
+	}
+	for _, ub := range doc.UserBunches {
+		if !userBunches[UserBunch{Username: ub.Username, Bunch: ub.Bunch}] {
+			result.UserBunches.Created++
+			writes.UserBunches = append(writes.UserBunches, ub)
+		}
+	}
+
+	if err := checkUsers(users, writes.Users); err != nil {
+		return nil, err
+	}
+	if err := s.checkExclusions(current.UserBunches, writes.UserBunches); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	for i, u := range writes.Users {
+		if _, ok := users[u.Username]; ok || len(u.Hash) > 0 {
+			continue
+		}
+
+		hash, err := usrmgr.HashPassword("", s.bcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		added := *u
+		added.Hash = hash
+		writes.Users[i] = &added
+	}
+
+	if err := s.st.Import(writes); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// checkUsers makes sure that users, once written, have unique emails and that service accounts are owned by
+// human users
+func checkUsers(current map[string]*User, written []*User) error {
+	users := make(map[string]*User, len(current)+len(written))
+	for name, u := range current {
+		users[name] = u
+	}
+	for _, u := range written {
+		users[u.Username] = u
+	}
+
+	emails := make(map[string]int, len(users))
+	for _, u := range users {
+		emails[u.Email]++
+	}
+
+	for _, u := range written {
+		if len(u.Email) > 0 && emails[u.Email] > 1 {
+			return common.ErrDuplicatedEmail
+		}
+
+		if u.Type != usrmgr.TypeService {
+			continue
+		}
+		owner, ok := users[u.Owner]
+		if !ok || owner.Type == usrmgr.TypeService {
+			return common.ErrOwnerInvalid
+		}
+	}
+
+	return nil
+}
+
+// checkExclusions makes sure that no user will hold two bunches of the same exclusion set once the grants are
+// written
+func (s *service) checkExclusions(current []*UserBunch, written []*UserBunch) error {
+	if len(written) == 0 {
+		return nil
+	}
+
+	exclusions, err := s.st.GetExclusions()
+	if err != nil {
+		return err
+	}
+	if len(exclusions) == 0 {
+		return nil
+	}
+
+	held := make(map[string]map[string]bool)
+	for _, ub := range append(append([]*UserBunch{}, current...), written...) {
+		if held[ub.Username] == nil {
+			held[ub.Username] = make(map[string]bool)
+		}
+		held[ub.Username][ub.Bunch] = true
+	}
+
+	names := make([]string, 0, len(exclusions))
+	for name := range exclusions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, ub := range written {
+		for _, name := range names {
+			conflicts := make([]string, 0)
+			for _, b := range exclusions[name] {
+				if held[ub.Username][b] {
+					conflicts = append(conflicts, b)
+				}
+			}
+			if len(conflicts) > 1 {
+				return &common.ExclusionError{Exclusion: name, Bunches: conflicts}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// store is an in-memory Storer, Import records the rows which are written
+type store struct {
+	current    *Document
+	exclusions map[string][]string
+	written    *Document
+}
+
+func (st *store) Export(withHashes bool) (*Document, error) {
+	return st.current, nil
+}
+
+func (st *store) Import(writes *Document) error {
+	st.written = writes
+	return nil
+}
+
+func (st *store) GetExclusions() (map[string][]string, error) {
+	return st.exclusions, nil
+}
+
+func (st *store) WithTenant(tenantID int64) Storer {
+	return st
+}
+
+func newStore() *store {
+	return &store{
+		current: &Document{
+			Bunches: []*Bunch{{Name: "staff_role", Active: true}, {Name: "audit_role", Active: true}},
+			Users: []*User{
+				{Username: "admin", Email: "admin@test.com", Hash: "hash", Active: true, Type: usrmgr.TypeHuman},
+				{Username: "clerk", Email: "clerk@test.com", Hash: "hash", Active: true, Type: usrmgr.TypeHuman},
+			},
+			UserBunches: []*UserBunch{{Username: "clerk", Bunch: "staff_role"}},
+		},
+		exclusions: map[string][]string{"sod": {"staff_role", "audit_role"}},
+	}
+}
+
+func TestService_Import(t *testing.T) {
+	st := newStore()
+	s := NewService(st, bcrypt.MinCost)
+
+	result, err := s.Import(&Document{
+		Version: Version,
+		Users: []*User{
+			{Username: "admin", Email: "admin@test.com", Active: true},
+			{Username: "newbie", Email: "newbie@test.com", Active: true},
+			{Username: "robot", Active: true, Type: usrmgr.TypeService, Owner: "admin"},
+		},
+		UserBunches: []*UserBunch{{Username: "newbie", Bunch: "audit_role"}},
+	}, StrategyFail, false)
+	require.Nil(t, err)
+	require.Equal(t, 2, result.Users.Created)
+	require.Equal(t, 1, result.UserBunches.Created)
+
+	// users without hashes are given one which no password matches
+	require.Len(t, st.written.Users, 2)
+	for _, u := range st.written.Users {
+		require.NotEmpty(t, u.Hash)
+		require.NotNil(t, bcrypt.CompareHashAndPassword([]byte(u.Hash), []byte("")))
+	}
+}
+
+func TestService_ImportDryRun(t *testing.T) {
+	st := newStore()
+	s := NewService(st, bcrypt.MinCost)
+
+	result, err := s.Import(&Document{
+		Version: Version,
+		Users:   []*User{{Username: "newbie", Email: "newbie@test.com", Active: true}},
+	}, StrategyFail, true)
+	require.Nil(t, err)
+	require.Equal(t, 1, result.Users.Created)
+	require.Nil(t, st.written)
+}
+
+func TestService_ImportInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  *Document
+		err  error
+	}{
+		{"version_unsupported", &Document{Version: Version + 1}, common.ErrUnsupportedVersion},
+		{"condition_invalid", &Document{Version: Version,
+			UserBunches: []*UserBunch{{Username: "clerk", Bunch: "audit_role", Condition: "ip =="}}},
+			common.ErrConditionInvalid},
+		{"user_type_invalid", &Document{Version: Version,
+			Users: []*User{{Username: "newbie", Email: "newbie@test.com", Type: "robot"}}},
+			common.ErrUserTypeInvalid},
+		{"email_duplicated", &Document{Version: Version,
+			Users: []*User{{Username: "newbie", Email: "clerk@test.com"}}},
+			common.ErrDuplicatedEmail},
+		{"owner_missing", &Document{Version: Version,
+			Users: []*User{{Username: "robot", Type: usrmgr.TypeService, Owner: "nobody"}}},
+			common.ErrOwnerInvalid},
+		{"owner_service_account", &Document{Version: Version, Users: []*User{
+			{Username: "robot", Type: usrmgr.TypeService, Owner: "admin"},
+			{Username: "droid", Type: usrmgr.TypeService, Owner: "robot"},
+		}}, common.ErrOwnerInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			st := newStore()
+			_, err := NewService(st, bcrypt.MinCost).Import(test.doc, StrategyFail, false)
+			require.Equal(t, test.err, err)
+			require.Nil(t, st.written)
+		})
+	}
+
+	// usernames and emails are checked as the user service checks them
+	st := newStore()
+	_, err := NewService(st, bcrypt.MinCost).Import(&Document{Version: Version,
+		Users: []*User{{Username: "new user", Email: "newbie"}}}, StrategyFail, false)
+	require.IsType(t, &common.ValidationError{}, err)
+	require.Nil(t, st.written)
+
+	// bunches of an exclusion can't be held together, even in a dry run
+	_, err = NewService(st, bcrypt.MinCost).Import(&Document{Version: Version,
+		UserBunches: []*UserBunch{{Username: "clerk", Bunch: "audit_role"}}}, StrategyFail, true)
+	require.Equal(t, &common.ExclusionError{Exclusion: "sod", Bunches: []string{"staff_role", "audit_role"}}, err)
+}
+
+func TestService_ImportConflicts(t *testing.T) {
+	doc := &Document{
+		Version: Version,
+		Users:   []*User{{Username: "clerk", Email: "clerk@new.com", Active: true}},
+	}
+
+	st := newStore()
+	_, err := NewService(st, bcrypt.MinCost).Import(doc, StrategyFail, false)
+	require.Equal(t, &common.ImportConflictError{Kind: "user", Name: "clerk"}, err)
+
+	result, err := NewService(st, bcrypt.MinCost).Import(doc, StrategySkip, false)
+	require.Nil(t, err)
+	require.Equal(t, 1, result.Users.Skipped)
+	require.Len(t, st.written.Users, 0)
+
+	// existing users keep their hashes when the document has none
+	result, err = NewService(st, bcrypt.MinCost).Import(doc, StrategyOverwrite, false)
+	require.Nil(t, err)
+	require.Equal(t, 1, result.Users.Updated)
+	require.Empty(t, st.written.Users[0].Hash)
+}
@@ -18,6 +18,29 @@ type Key struct {
 	ID        int64
 	Key       string
 	Desc      string
+	Condition string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// Member is a user who holds a bunch
+type Member struct {
+	ID       int64
+	Username string
+}
+
+// Exclusion is a set of bunches which mustn't be held together by one user
+type Exclusion struct {
+	ID        int64
+	Name      string
+	Desc      string
+	Bunches   []string
+	CreatedAt time.Time
+}
+
+// Violation is a user who currently holds more than one bunch of an exclusion set
+type Violation struct {
+	Exclusion string
+	Username  string
+	Bunches   []string
+}
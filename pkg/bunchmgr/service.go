@@ -3,6 +3,8 @@ package bunchmgr
 import (
 	"database/sql"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/condition"
+	"github.com/vespaiach/auth/pkg/keymatch"
 	"regexp"
 	"strings"
 )
@@ -16,7 +18,20 @@ type Storer interface {
 		direction common.SortingDirection) ([]*Bunch, int64, error)
 	GetKeyIDs(keys []string) ([]int64, error)
 	AddKeysToBunch(bunchID int64, keyIDs []int64) error
+	RemoveKeysFromBunch(bunchID int64, keyIDs []int64) error
 	GetKeysInBunch(name string) ([]*Key, error)
+	SetKeyCondition(bunchID int64, keyID int64, expression sql.NullString) error
+	AddDenials(bunchID int64, keyIDs []int64) error
+	RemoveDenials(bunchID int64, keyIDs []int64) error
+	GetDenials(name string) ([]*Key, error)
+	GetBunchIDs(names []string) ([]int64, error)
+	AddExclusion(name string, desc string, bunchIDs []int64) (int64, error)
+	GetExclusionByName(name string) (*Exclusion, error)
+	GetExclusions() ([]*Exclusion, error)
+	RemoveExclusion(id int64) error
+	GetViolations() ([]*Violation, error)
+	GetMembers(name string) ([]*Member, error)
+	WithTenant(tenantID int64) Storer
 }
 
 type Service interface {
@@ -27,6 +42,18 @@ type Service interface {
 	GetKeysInBunch(name string) ([]*Key, error)
 	QueryBunches(page int64, perPage int64, name string, active sql.NullBool, order string) ([]*Bunch, int64, error)
 	AddKeysToBunch(bunch string, keys []string) error
+	RemoveKeysFromBunch(bunch string, keys []string) error
+	SetKeyCondition(bunch string, key string, expression string) error
+	AddDenials(bunch string, keys []string) error
+	RemoveDenials(bunch string, keys []string) error
+	GetDenials(bunch string) ([]*Key, error)
+	AddExclusion(name string, desc string, bunches []string) (int64, error)
+	GetExclusion(name string) (*Exclusion, error)
+	GetExclusions() ([]*Exclusion, error)
+	RemoveExclusion(name string) error
+	GetViolations() ([]*Violation, error)
+	GetMembers(name string) ([]*Member, error)
+	WithTenant(tenantID int64) Service
 }
 
 type service struct {
@@ -37,9 +64,18 @@ func NewService(st Storer) Service {
 	return &service{st}
 }
 
+// WithTenant returns the service working on bunches of the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st.WithTenant(tenantID)}
+}
+
 func (s *service) AddBunch(name string, desc string) (int64, error) {
+	invalid := new(common.ValidationError)
 	if !s.isValidKey(name) {
-		return 0, common.ErrBunchNameInvalid
+		invalid.Add("name", common.ErrBunchNameInvalid)
+	}
+	if err := invalid.Err(); err != nil {
+		return 0, err
 	}
 
 	dup, err := s.isDuplicatedKey(name)
@@ -62,12 +98,14 @@ func (s *service) ModifyBunch(id int64, name string, desc string, active sql.Nul
 		return common.ErrBunchNotFound
 	}
 
+	invalid := new(common.ValidationError)
 	if len(name) == 0 {
 		name = updating.Name
-	} else {
-		if !s.isValidKey(name) {
-			return common.ErrBunchNameInvalid
-		}
+	} else if !s.isValidKey(name) {
+		invalid.Add("name", common.ErrBunchNameInvalid)
+	}
+	if err := invalid.Err(); err != nil {
+		return err
 	}
 
 	if len(desc) == 0 {
@@ -137,6 +175,13 @@ func (s *service) AddKeysToBunch(bunchName string, keys []string) error {
 		return common.ErrBunchNotFound
 	}
 
+	// keys which can't be defined, e.g. "*", would grant every other key
+	for _, k := range keys {
+		if !keymatch.IsValid(k) {
+			return common.ErrKeyNameInvalid
+		}
+	}
+
 	if len(keys) > 0 {
 		keyIDs, err := s.st.GetKeyIDs(keys)
 		if err != nil {
@@ -152,10 +197,177 @@ func (s *service) AddKeysToBunch(bunchName string, keys []string) error {
 	return nil
 }
 
+// RemoveKeysFromBunch takes the keys back from the bunch, keys which it doesn't have are ignored
+func (s *service) RemoveKeysFromBunch(bunchName string, keys []string) error {
+	bunch, err := s.st.GetBunchByName(bunchName)
+	if err != nil {
+		return err
+	}
+	if bunch == nil {
+		return common.ErrBunchNotFound
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	keyIDs, err := s.st.GetKeyIDs(keys)
+	if err != nil {
+		return err
+	}
+	if len(keyIDs) == 0 {
+		return common.ErrKeyNotFound
+	}
+
+	return s.st.RemoveKeysFromBunch(bunch.ID, keyIDs)
+}
+
 func (s *service) GetKeysInBunch(name string) ([]*Key, error) {
 	return s.st.GetKeysInBunch(name)
 }
 
+// SetKeyCondition makes bunch's grant of the key conditional, an empty condition removes it
+func (s *service) SetKeyCondition(bunchName string, key string, expression string) error {
+	bunch, err := s.st.GetBunchByName(bunchName)
+	if err != nil {
+		return err
+	}
+	if bunch == nil {
+		return common.ErrBunchNotFound
+	}
+
+	expression = strings.TrimSpace(expression)
+	expr := sql.NullString{String: expression, Valid: len(expression) > 0}
+	if expr.Valid && condition.Validate(expression) != nil {
+		return common.ErrConditionInvalid
+	}
+
+	keys, err := s.st.GetKeysInBunch(bunch.Name)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k.Key == key {
+			return s.st.SetKeyCondition(bunch.ID, k.ID, expr)
+		}
+	}
+
+	return common.ErrGrantNotFound
+}
+
+// AddDenials denies keys to holders of the bunch, whatever other bunches grant them
+func (s *service) AddDenials(bunchName string, keys []string) error {
+	bunch, keyIDs, err := s.lookupDenials(bunchName, keys)
+	if err != nil {
+		return err
+	}
+
+	return s.st.AddDenials(bunch.ID, keyIDs)
+}
+
+func (s *service) RemoveDenials(bunchName string, keys []string) error {
+	bunch, keyIDs, err := s.lookupDenials(bunchName, keys)
+	if err != nil {
+		return err
+	}
+
+	return s.st.RemoveDenials(bunch.ID, keyIDs)
+}
+
+func (s *service) GetDenials(bunchName string) ([]*Key, error) {
+	bunch, err := s.st.GetBunchByName(bunchName)
+	if err != nil {
+		return nil, err
+	}
+	if bunch == nil {
+		return nil, common.ErrBunchNotFound
+	}
+
+	return s.st.GetDenials(bunch.Name)
+}
+
+func (s *service) lookupDenials(bunchName string, keys []string) (*Bunch, []int64, error) {
+	bunch, err := s.st.GetBunchByName(bunchName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bunch == nil {
+		return nil, nil, common.ErrBunchNotFound
+	}
+
+	if len(keys) == 0 {
+		return nil, nil, common.ErrKeyNotFound
+	}
+	keyIDs, err := s.st.GetKeyIDs(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(keyIDs) != len(keys) {
+		return nil, nil, common.ErrKeyNotFound
+	}
+
+	return bunch, keyIDs, nil
+}
+
+func (s *service) AddExclusion(name string, desc string, bunches []string) (int64, error) {
+	invalid := new(common.ValidationError)
+	if !s.isValidKey(name) {
+		invalid.Add("name", common.ErrExclusionNameInvalid)
+	}
+	if len(bunches) < 2 {
+		invalid.Add("bunches", common.ErrExclusionTooSmall)
+	}
+	if err := invalid.Err(); err != nil {
+		return 0, err
+	}
+
+	existing, err := s.st.GetExclusionByName(name)
+	if err != nil {
+		return 0, err
+	}
+	if existing != nil {
+		return 0, common.ErrDuplicatedExclusion
+	}
+
+	bunchIDs, err := s.st.GetBunchIDs(bunches)
+	if err != nil {
+		return 0, err
+	}
+	if len(bunchIDs) != len(bunches) {
+		return 0, common.ErrBunchNotFound
+	}
+
+	return s.st.AddExclusion(name, desc, bunchIDs)
+}
+
+func (s *service) GetExclusion(name string) (*Exclusion, error) {
+	return s.st.GetExclusionByName(name)
+}
+
+func (s *service) GetExclusions() ([]*Exclusion, error) {
+	return s.st.GetExclusions()
+}
+
+func (s *service) RemoveExclusion(name string) error {
+	existing, err := s.st.GetExclusionByName(name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return common.ErrExclusionNotFound
+	}
+
+	return s.st.RemoveExclusion(existing.ID)
+}
+
+func (s *service) GetViolations() ([]*Violation, error) {
+	return s.st.GetViolations()
+}
+
+func (s *service) GetMembers(name string) ([]*Member, error) {
+	return s.st.GetMembers(name)
+}
+
 func (s *service) isDuplicatedKey(name string) (bool, error) {
 	existing, err := s.st.GetBunchByName(name)
 	if err != nil {
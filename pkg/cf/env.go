@@ -10,7 +10,10 @@ import (
 var (
 	defaultBcryptCost           = 10
 	defaultErrorFile            = "./error.yml"
+	defaultLocaleDir            = "./locales"
+	defaultLocale               = "en" // language of messages of the error catalog
 	defaultServerAddress        = ":4000"
+	defaultGrpcAddress          = "" // grpc transport is disabled without an address
 	defaultSigningText          = "key_signing"
 	defaultDbhost               = "localhost"
 	defaultDbport               = "3306"
@@ -20,13 +23,18 @@ var (
 	defaultDboption             = "charset=utf8&parseTime=True&loc=Local&multiStatements=True&maxAllowedPacket=0"
 	defaultAccessTokenDuration  = "120m" // minutes
 	defaultRefreshTokenDuration = "0m"   // minutes
+	defaultScimToken            = ""     // scim endpoints are disabled without a token
+	defaultAuditHashChain       = false
 )
 
 // AppConfig holds all app's settings and will be read from env
 type AppConfig struct {
 	AppDir               string
 	ErrorFilePath        string
+	LocaleDir            string
+	DefaultLocale        string
 	ServerAddress        string
+	GrpcAddress          string
 	BcryptCost           int
 	SigningText          string
 	DbHost               string
@@ -37,6 +45,11 @@ type AppConfig struct {
 	DbOption             string
 	AccessTokenDuration  string
 	RefreshTokenDuration string
+	ScimToken            string
+	AuditHashChain       bool
+	AuthBackends         []string
+	Ldap                 *LdapConfig
+	OidcProviders        []*OidcProvider
 }
 
 // BuildMysqlDSN returns mysqldsn
@@ -58,12 +71,30 @@ func LoadAppConfig() *AppConfig {
 		ErrorFile = path.Join(AppDir, defaultErrorFile)
 	}
 
+	LocaleDir, err := getEnvString("LOCALE_DIR")
+	if err != nil {
+		log.Println(err)
+		LocaleDir = path.Join(AppDir, defaultLocaleDir)
+	}
+
+	DefaultLocale, err := getEnvString("DEFAULT_LOCALE")
+	if err != nil {
+		log.Println(err)
+		DefaultLocale = defaultLocale
+	}
+
 	ServerAddress, err := getEnvString("SERVER_ADDRESS")
 	if err != nil {
 		log.Println(err)
 		ServerAddress = defaultServerAddress
 	}
 
+	GrpcAddress, err := getEnvString("GRPC_ADDRESS")
+	if err != nil {
+		log.Println(err)
+		GrpcAddress = defaultGrpcAddress
+	}
+
 	BcryptCost, err := getEnvInt("BCRYPT_COST")
 	if err != nil {
 		log.Println(err)
@@ -124,10 +155,25 @@ func LoadAppConfig() *AppConfig {
 		RefreshTokenDuration = defaultRefreshTokenDuration
 	}
 
+	ScimToken, err := getEnvString("SCIM_TOKEN")
+	if err != nil {
+		log.Println(err)
+		ScimToken = defaultScimToken
+	}
+
+	AuditHashChain, err := getEnvBool("AUDIT_HASH_CHAIN")
+	if err != nil {
+		log.Println(err)
+		AuditHashChain = defaultAuditHashChain
+	}
+
 	return &AppConfig{
 		AppDir,
 		ErrorFile,
+		LocaleDir,
+		DefaultLocale,
 		ServerAddress,
+		GrpcAddress,
 		BcryptCost,
 		SigningText,
 		DbHost,
@@ -138,5 +184,10 @@ func LoadAppConfig() *AppConfig {
 		DbOption,
 		AccessTokenDuration,
 		RefreshTokenDuration,
+		ScimToken,
+		AuditHashChain,
+		loadAuthBackends(),
+		loadLdapConfig(),
+		loadOidcProviders(),
 	}
 }
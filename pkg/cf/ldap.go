@@ -0,0 +1,76 @@
+package cf
+
+import (
+	"log"
+	"strings"
+)
+
+var (
+	defaultAuthBackends       = []string{"local"}
+	defaultLdapUserFilter     = "(uid=%s)"
+	defaultLdapEmailAttribute = "mail"
+	defaultLdapGroupAttribute = "memberOf"
+)
+
+// LdapConfig holds settings of the ldap authentication backend
+type LdapConfig struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	CACertFile         string
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string
+	EmailAttribute     string
+	GroupAttribute     string
+
+	// GroupMapping maps lowercased group DNs to bunch names
+	GroupMapping map[string]string
+}
+
+func loadAuthBackends() []string {
+	AuthBackends, err := getEnvStringSlice("AUTH_BACKENDS")
+	if err != nil {
+		log.Println(err)
+		AuthBackends = defaultAuthBackends
+	}
+
+	return AuthBackends
+}
+
+func loadLdapConfig() *LdapConfig {
+	config := &LdapConfig{GroupMapping: make(map[string]string)}
+
+	config.URL, _ = getEnvString("LDAP_URL")
+	config.StartTLS, _ = getEnvBool("LDAP_START_TLS")
+	config.InsecureSkipVerify, _ = getEnvBool("LDAP_INSECURE_SKIP_VERIFY")
+	config.CACertFile, _ = getEnvString("LDAP_CA_CERT_FILE")
+	config.BindDN, _ = getEnvString("LDAP_BIND_DN")
+	config.BindPassword, _ = getEnvString("LDAP_BIND_PASSWORD")
+	config.BaseDN, _ = getEnvString("LDAP_BASE_DN")
+
+	var err error
+	if config.UserFilter, err = getEnvString("LDAP_USER_FILTER"); err != nil {
+		config.UserFilter = defaultLdapUserFilter
+	}
+	if config.EmailAttribute, err = getEnvString("LDAP_EMAIL_ATTRIBUTE"); err != nil {
+		config.EmailAttribute = defaultLdapEmailAttribute
+	}
+	if config.GroupAttribute, err = getEnvString("LDAP_GROUP_ATTRIBUTE"); err != nil {
+		config.GroupAttribute = defaultLdapGroupAttribute
+	}
+
+	// Each mapping looks like "cn=admins,ou=groups,dc=example,dc=com:admin" and is separated by "|"
+	mappings, _ := getEnvStringSlice("LDAP_GROUP_MAPPING")
+	for _, m := range mappings {
+		i := strings.LastIndex(m, ":")
+		if i < 0 {
+			log.Println("ldap group mapping is invalid: " + m)
+			continue
+		}
+		config.GroupMapping[strings.TrimSpace(m[:i])] = strings.TrimSpace(m[i+1:])
+	}
+
+	return config
+}
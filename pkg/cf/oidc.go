@@ -0,0 +1,63 @@
+package cf
+
+import (
+	"io/ioutil"
+	"log"
+
+	"gopkg.in/yaml.v2"
+)
+
+// OidcProvider holds settings of an upstream oidc identity provider
+type OidcProvider struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+
+	// UsernameClaim names the claim used as username of provisioned users, default is preferred_username
+	UsernameClaim string `yaml:"username_claim"`
+
+	// LinkByEmail links an identity to the existing user having the same verified email
+	LinkByEmail bool `yaml:"link_by_email"`
+
+	// Provision creates a user for an identity which can't be linked
+	Provision bool `yaml:"provision"`
+
+	Rules []*OidcRule `yaml:"rules"`
+}
+
+// OidcRule grants Bunch when Claim equals Value, or contains it when the claim is a list
+type OidcRule struct {
+	Claim string `yaml:"claim"`
+	Value string `yaml:"value"`
+	Bunch string `yaml:"bunch"`
+}
+
+type oidcConfig struct {
+	Providers []*OidcProvider `yaml:"providers"`
+}
+
+// loadOidcProviders reads providers from the yaml file at OIDC_CONFIG_FILE
+func loadOidcProviders() []*OidcProvider {
+	file, err := getEnvString("OIDC_CONFIG_FILE")
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	config := new(oidcConfig)
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return config.Providers
+}
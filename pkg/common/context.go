@@ -8,4 +8,25 @@ const (
 	BunchManagementService
 	UserManagementService
 	AppConfigContextKey
+	ReviewManagementService
+	ManifestService
+	BackupService
+	AuthenticatorContextKey
+	FederationService
+	TokenManagementService
+	TenantManagementService
+	TenantContextKey
+	TenantHeaderContextKey
+	AudienceContextKey
+	RemoteAddrContextKey
+	RelationService
+	AuditService
+	UserAgentContextKey
+	RequestPathContextKey
+	SessionService
+	ForwardedForContextKey
+	RealIPContextKey
+	WebhookService
+	RequestIDContextKey
+	LanguageContextKey
 )
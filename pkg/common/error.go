@@ -1,6 +1,10 @@
 package common
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	ErrDuplicatedKey      = errors.New("duplicated key")
@@ -21,4 +25,147 @@ var (
 	ErrMissingJWTToken    = errors.New("jwt token is missing")
 	ErrWrongJWTToken      = errors.New("jwt token is not correct")
 	ErrNotAllowed         = errors.New("not allowed to access")
+
+	ErrCampaignNameInvalid   = errors.New("campaign name is invalid")
+	ErrCampaignNotFound      = errors.New("campaign doesn't exist")
+	ErrCampaignClosed        = errors.New("campaign is closed")
+	ErrCampaignNotClosed     = errors.New("campaign is not closed yet")
+	ErrReviewScopeInvalid    = errors.New("review scope is invalid")
+	ErrMissingReviewTargets  = errors.New("review targets are missing")
+	ErrMissingReviewers      = errors.New("reviewers are missing")
+	ErrReviewItemNotFound    = errors.New("review item doesn't exist")
+	ErrReviewDecisionInvalid = errors.New("review decision is invalid")
+	ErrReviewerNotAssigned   = errors.New("reviewer is not assigned to this item")
+	ErrReviewItemDecided     = errors.New("review item is already decided")
+
+	ErrExclusionNameInvalid = errors.New("exclusion name is invalid")
+	ErrDuplicatedExclusion  = errors.New("duplicated exclusion")
+	ErrExclusionNotFound    = errors.New("exclusion doesn't exist")
+	ErrExclusionTooSmall    = errors.New("exclusion needs at least two bunches")
+
+	ErrUnsupportedVersion    = errors.New("document version is not supported")
+	ErrImportStrategyInvalid = errors.New("import strategy is invalid")
+
+	ErrAuthBackendInvalid = errors.New("authentication backend is invalid")
+
+	ErrProviderNotFound   = errors.New("identity provider doesn't exist")
+	ErrLoginStateInvalid  = errors.New("login state is invalid or expired")
+	ErrIDTokenInvalid     = errors.New("identity token is invalid")
+	ErrIdentityNotLinked  = errors.New("identity isn't linked to any user")
+	ErrUpstreamLoginError = errors.New("identity provider rejected the login")
+
+	ErrTokenNameInvalid     = errors.New("token name is invalid")
+	ErrMissingTokenKeys     = errors.New("token keys are missing")
+	ErrTokenExpiryInvalid   = errors.New("token expiry must be in the future")
+	ErrDuplicatedToken      = errors.New("duplicated token")
+	ErrTokenNotFound        = errors.New("token doesn't exist")
+	ErrPersonalTokenInvalid = errors.New("personal access token is invalid, expired or revoked")
+
+	ErrServiceAccountNotFound = errors.New("service account doesn't exist")
+	ErrOwnerInvalid           = errors.New("owner must be an existing user")
+	ErrUserTypeInvalid        = errors.New("user type is invalid")
+
+	ErrTenantNameInvalid = errors.New("tenant name is invalid")
+	ErrDuplicatedTenant  = errors.New("duplicated tenant")
+	ErrTenantNotFound    = errors.New("tenant doesn't exist")
+	ErrTenantInactive    = errors.New("tenant is inactive")
+
+	ErrApplicationNameInvalid = errors.New("application name is invalid")
+	ErrDuplicatedApplication  = errors.New("duplicated application")
+	ErrApplicationNotFound    = errors.New("application doesn't exist")
+
+	ErrConditionInvalid = errors.New("condition is invalid")
+	ErrGrantNotFound    = errors.New("grant doesn't exist")
+	ErrAttributeInvalid = errors.New("user attribute is invalid")
+
+	ErrTupleInvalid            = errors.New("relation tuple is invalid")
+	ErrNamespaceInvalid        = errors.New("namespace config is invalid")
+	ErrNamespaceNotFound       = errors.New("namespace doesn't exist")
+	ErrRelationNotFound        = errors.New("relation doesn't exist in namespace")
+	ErrConsistencyTokenInvalid = errors.New("consistency token is invalid")
+	ErrRelationTooDeep         = errors.New("relations are nested too deeply")
+
+	ErrAuditOutcomeInvalid = errors.New("audit outcome must be success or failure")
+	ErrAuditPeriodInvalid  = errors.New("audit period is invalid")
+
+	ErrSessionNotFound   = errors.New("session doesn't exist")
+	ErrSessionTerminated = errors.New("session is terminated")
+
+	ErrWebhookNameInvalid    = errors.New("webhook name is invalid")
+	ErrWebhookURLInvalid     = errors.New("webhook url must be an http or https url")
+	ErrWebhookEventsInvalid  = errors.New("webhook events are invalid")
+	ErrDuplicatedWebhook     = errors.New("duplicated webhook")
+	ErrWebhookNotFound       = errors.New("webhook doesn't exist")
+	ErrDeliveryStatusInvalid = errors.New("delivery status must be pending, succeeded or dead")
+	ErrDeliveryNotFound      = errors.New("delivery doesn't exist")
+	ErrEventTypeInvalid      = errors.New("event type is invalid")
+
+	ErrLocaleInvalid = errors.New("locale is invalid")
+
+	ErrPrivilegeEscalation = errors.New("can't grant keys which the caller doesn't hold")
+
+	ErrManifestPlanChanged = errors.New("manifest plan has changed since it was reviewed")
+	ErrAdminBunchProtected = errors.New("admin bunch can't be deactivated")
+
+	ErrRequestInvalid = errors.New("request is malformed")
+
+	ErrInviteInvalid = errors.New("invite is invalid or has expired")
 )
+
+// ExclusionError is returned when a user would hold bunches which are mutually exclusive
+type ExclusionError struct {
+	Exclusion string
+	Bunches   []string
+}
+
+func (e *ExclusionError) Error() string {
+	return fmt.Sprintf("bunches %s can't be held together because of exclusion %s",
+		strings.Join(e.Bunches, ", "), e.Exclusion)
+}
+
+// ImportConflictError is returned when an imported row differs from the stored one
+type ImportConflictError struct {
+	Kind string
+	Name string
+}
+
+func (e *ImportConflictError) Error() string {
+	return fmt.Sprintf("%s %s already exists with different values", e.Kind, e.Name)
+}
+
+// ValidationError is returned when fields of a request are invalid, it tells every invalid field at once
+type ValidationError struct {
+	Problems []*FieldProblem
+}
+
+// FieldProblem tells why a field is invalid
+type FieldProblem struct {
+	Field string
+	Err   error
+}
+
+// Add records that field is invalid because of err
+func (e *ValidationError) Add(field string, err error) {
+	e.Problems = append(e.Problems, &FieldProblem{field, err})
+}
+
+// Err returns nil when no field is invalid, and the problem's own error when only one is, so that callers
+// comparing errors keep working
+func (e *ValidationError) Err() error {
+	switch len(e.Problems) {
+	case 0:
+		return nil
+	case 1:
+		return e.Problems[0].Err
+	default:
+		return e
+	}
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		problems = append(problems, fmt.Sprintf("%s: %v", p.Field, p.Err))
+	}
+	return strings.Join(problems, "; ")
+}
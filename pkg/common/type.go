@@ -14,6 +14,9 @@ type NullableBool struct {
 // Default total record will be returned in a request to sql server
 const Take = 100
 
+// DefaultTenant is the tenant which exists from the start, its admins manage other tenants
+const DefaultTenant int64 = 1
+
 // SortingDirection sort by direction
 type SortingDirection int
 
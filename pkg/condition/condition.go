@@ -0,0 +1,668 @@
+// Package condition parses and evaluates conditions attached to grants, e.g.
+//
+//	cidr(ip, "10.0.0.0/8") && hour >= 9 && hour < 18 && weekday in ["mon", "tue", "wed", "thu", "fri"]
+//	hour("Asia/Ho_Chi_Minh") >= 8 && hour("Asia/Ho_Chi_Minh") < 17
+//	user.department == resource.department
+//
+// A condition is a boolean expression over the request's context:
+//
+//   - ip: caller's address, a string
+//   - hour: hour of the request's time in UTC, a number from 0 to 23
+//   - weekday: day of the request's time in UTC, one of "mon", "tue", "wed", "thu", "fri", "sat", "sun"
+//   - user.<name>: attribute of the caller, a string
+//   - resource.<name>: attribute of the resource the request is made for, a string
+//
+// Expressions are made of string and number literals, lists in brackets, comparisons (==, !=, <, <=, >, >=, in),
+// logical operators (&&, ||, !), parentheses and the functions cidr(ip, range), hour(zone) and weekday(zone). The
+// latter two are hour and weekday in a time zone of the IANA database, e.g. "Europe/Paris", zones are read from
+// the system's zoneinfo when the condition is compiled. They have no side effects nor loops, their size is bounded
+// and they are type checked when compiled, so saved conditions can't do more than answer yes or no. Evaluation
+// never fails, values which can't be compared make comparisons false. Attributes which the caller or the resource
+// hasn't got can't be compared, so that any comparison against them is false, even between two of them.
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxLength is the longest condition which can be saved
+	MaxLength = 255
+
+	maxDepth = 16
+)
+
+var ErrInvalid = errors.New("invalid condition")
+
+// Env is the request's context which conditions are evaluated against
+type Env struct {
+	IP       string
+	Time     time.Time
+	User     map[string]string
+	Resource map[string]string
+}
+
+// Expr is a compiled condition
+type Expr struct {
+	src  string
+	root node
+}
+
+// String returns the source of the condition
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval reports whether the condition holds in env
+func (e *Expr) Eval(env *Env) bool {
+	v, ok := e.root.eval(env).(bool)
+	return ok && v
+}
+
+// Compile parses and type checks a condition. Conditions of grants which are combined, see All, may be up to
+// twice as long and deep as the ones which can be saved.
+func Compile(src string) (*Expr, error) {
+	return compile(src, 2*MaxLength+len(" && ()()"), 2*maxDepth+1)
+}
+
+// Validate checks that a condition can be saved
+func Validate(src string) error {
+	_, err := compile(src, MaxLength, maxDepth)
+	return err
+}
+
+// All combines conditions which must hold together, empty ones are left out
+func All(srcs ...string) string {
+	parts := make([]string, 0, len(srcs))
+	for _, src := range srcs {
+		if src = strings.TrimSpace(src); len(src) > 0 {
+			parts = append(parts, src)
+		}
+	}
+	if len(parts) < 2 {
+		return strings.Join(parts, "")
+	}
+	return "(" + strings.Join(parts, ") && (") + ")"
+}
+
+func compile(src string, maxLength int, maxDepth int) (*Expr, error) {
+	if len(strings.TrimSpace(src)) == 0 || len(src) > maxLength {
+		return nil, ErrInvalid
+	}
+
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks, maxDepth: maxDepth}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, invalid("unexpected %q", p.peek().text)
+	}
+	if root.typ() != typeBool {
+		return nil, invalid("condition must be a boolean expression")
+	}
+
+	return &Expr{src, root}, nil
+}
+
+// Eval compiles and evaluates a condition, conditions which don't compile never hold
+func Eval(src string, env *Env) bool {
+	e, err := Compile(src)
+	if err != nil {
+		return false
+	}
+	return e.Eval(env)
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func lex(src string) ([]token, error) {
+	toks := make([]token, 0)
+
+	for i := 0; i < len(src); {
+		c := src[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					return nil, invalid("escapes aren't supported in strings")
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, invalid("unterminated string")
+			}
+			toks = append(toks, token{tokString, src[i+1 : j]})
+			i = j + 1
+
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, src[i:j]})
+			i = j
+
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || src[j] >= 'a' && src[j] <= 'z' ||
+				src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j]})
+			i = j
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					toks = append(toks, token{tokOp, op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, invalid("unexpected character %q", c)
+			}
+		}
+	}
+
+	return append(toks, token{tokEOF, ""}), nil
+}
+
+type parser struct {
+	toks     []token
+	pos      int
+	maxDepth int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		return invalid("expected %q", op)
+	}
+	return nil
+}
+
+func (p *parser) parseOr(depth int) (node, error) {
+	if depth > p.maxDepth {
+		return nil, invalid("condition is nested too deeply")
+	}
+
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		if left, err = newLogical("||", left, right); err != nil {
+			return nil, err
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd(depth int) (node, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		if left, err = newLogical("&&", left, right); err != nil {
+			return nil, err
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary(depth int) (node, error) {
+	if p.accept("!") {
+		if depth+1 > p.maxDepth {
+			return nil, invalid("condition is nested too deeply")
+		}
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != typeBool {
+			return nil, invalid("! needs a boolean operand")
+		}
+		return &notNode{operand}, nil
+	}
+
+	return p.parseComparison(depth)
+}
+
+func (p *parser) parseComparison(depth int) (node, error) {
+	left, err := p.parseTerm(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	op := t.text
+	if (t.kind == tokOp && (op == "==" || op == "!=" || op == "<" || op == "<=" || op == ">" || op == ">=")) ||
+		(t.kind == tokIdent && op == "in") {
+		p.next()
+		right, err := p.parseTerm(depth)
+		if err != nil {
+			return nil, err
+		}
+		return newComparison(op, left, right)
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseTerm(depth int) (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokString:
+		return &literal{t.text, typeString}, nil
+
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, invalid("bad number %q", t.text)
+		}
+		return &literal{n, typeNumber}, nil
+
+	case tokIdent:
+		if p.accept("(") {
+			return p.parseCall(t.text, depth)
+		}
+		return newVariable(t.text)
+
+	case tokOp:
+		switch t.text {
+		case "(":
+			inner, err := p.parseOr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+
+		case "[":
+			items := make([]node, 0)
+			if !p.accept("]") {
+				for {
+					item, err := p.parseTerm(depth + 1)
+					if err != nil {
+						return nil, err
+					}
+					if item.typ() != typeString && item.typ() != typeNumber {
+						return nil, invalid("lists may only hold strings and numbers")
+					}
+					items = append(items, item)
+					if p.accept("]") {
+						break
+					}
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return &listNode{items}, nil
+		}
+	}
+
+	if t.kind == tokEOF {
+		return nil, invalid("unexpected end of condition")
+	}
+	return nil, invalid("unexpected %q", t.text)
+}
+
+func (p *parser) parseCall(name string, depth int) (node, error) {
+	args := make([]node, 0)
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseOr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch name {
+	case "cidr":
+		if len(args) != 2 || args[0].typ() != typeString {
+			return nil, invalid("cidr takes an address and a range")
+		}
+		lit, ok := args[1].(*literal)
+		if !ok || lit.t != typeString {
+			return nil, invalid("cidr's range must be a string")
+		}
+		_, network, err := net.ParseCIDR(lit.v.(string))
+		if err != nil {
+			return nil, invalid("bad range %q", lit.v)
+		}
+		return &cidrNode{args[0], network}, nil
+
+	case "hour", "weekday":
+		if len(args) != 1 {
+			return nil, invalid("%s takes a time zone", name)
+		}
+		lit, ok := args[0].(*literal)
+		if !ok || lit.t != typeString {
+			return nil, invalid("%s's time zone must be a string", name)
+		}
+		loc, err := time.LoadLocation(lit.v.(string))
+		if err != nil || len(lit.v.(string)) == 0 {
+			return nil, invalid("bad time zone %q", lit.v)
+		}
+		return newClock(name, loc), nil
+	}
+
+	return nil, invalid("unknown function %q", name)
+}
+
+type valueType int
+
+const (
+	typeBool valueType = iota
+	typeString
+	typeNumber
+	typeList
+)
+
+type node interface {
+	typ() valueType
+	eval(env *Env) interface{}
+}
+
+type literal struct {
+	v interface{}
+	t valueType
+}
+
+func (n *literal) typ() valueType {
+	return n.t
+}
+
+func (n *literal) eval(_ *Env) interface{} {
+	return n.v
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) typ() valueType {
+	return typeList
+}
+
+func (n *listNode) eval(env *Env) interface{} {
+	values := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		values = append(values, item.eval(env))
+	}
+	return values
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+type variable struct {
+	name string
+	t    valueType
+	get  func(env *Env) interface{}
+}
+
+func newVariable(name string) (node, error) {
+	switch {
+	case name == "ip":
+		return &variable{name, typeString, func(env *Env) interface{} { return env.IP }}, nil
+
+	case name == "hour" || name == "weekday":
+		return newClock(name, time.UTC), nil
+
+	case strings.HasPrefix(name, "user.") && isAttribute(name[len("user."):]):
+		attr := name[len("user."):]
+		return &variable{name, typeString, func(env *Env) interface{} { return attribute(env.User, attr) }}, nil
+
+	case strings.HasPrefix(name, "resource.") && isAttribute(name[len("resource."):]):
+		attr := name[len("resource."):]
+		return &variable{name, typeString, func(env *Env) interface{} { return attribute(env.Resource, attr) }}, nil
+	}
+
+	return nil, invalid("unknown variable %q", name)
+}
+
+// newClock returns the hour or the weekday of the request's time in the location
+func newClock(name string, loc *time.Location) node {
+	if name == "hour" {
+		return &variable{name, typeNumber, func(env *Env) interface{} { return float64(env.Time.In(loc).Hour()) }}
+	}
+	return &variable{name, typeString, func(env *Env) interface{} { return weekdays[env.Time.In(loc).Weekday()] }}
+}
+
+// attribute returns the value of the attribute, nil when it's missing so that it can't be compared
+func attribute(attrs map[string]string, name string) interface{} {
+	if v, ok := attrs[name]; ok {
+		return v
+	}
+	return nil
+}
+
+func isAttribute(name string) bool {
+	return len(name) > 0 && !strings.Contains(name, ".")
+}
+
+func (n *variable) typ() valueType {
+	return n.t
+}
+
+func (n *variable) eval(env *Env) interface{} {
+	return n.get(env)
+}
+
+type logical struct {
+	op          string
+	left, right node
+}
+
+func newLogical(op string, left node, right node) (node, error) {
+	if left.typ() != typeBool || right.typ() != typeBool {
+		return nil, invalid("%s needs boolean operands", op)
+	}
+	return &logical{op, left, right}, nil
+}
+
+func (n *logical) typ() valueType {
+	return typeBool
+}
+
+func (n *logical) eval(env *Env) interface{} {
+	l, _ := n.left.eval(env).(bool)
+	if n.op == "&&" && !l {
+		return false
+	}
+	if n.op == "||" && l {
+		return true
+	}
+	r, _ := n.right.eval(env).(bool)
+	return r
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) typ() valueType {
+	return typeBool
+}
+
+func (n *notNode) eval(env *Env) interface{} {
+	v, _ := n.operand.eval(env).(bool)
+	return !v
+}
+
+type comparison struct {
+	op          string
+	left, right node
+}
+
+func newComparison(op string, left node, right node) (node, error) {
+	switch op {
+	case "in":
+		if right.typ() != typeList || left.typ() == typeList || left.typ() == typeBool {
+			return nil, invalid("in needs a value and a list")
+		}
+	default:
+		if left.typ() != right.typ() || left.typ() == typeList {
+			return nil, invalid("%s needs operands of the same type", op)
+		}
+		if left.typ() == typeBool && op != "==" && op != "!=" {
+			return nil, invalid("%s can't compare booleans", op)
+		}
+	}
+
+	return &comparison{op, left, right}, nil
+}
+
+func (n *comparison) typ() valueType {
+	return typeBool
+}
+
+func (n *comparison) eval(env *Env) interface{} {
+	l := n.left.eval(env)
+	r := n.right.eval(env)
+	if l == nil || r == nil {
+		return false
+	}
+
+	switch n.op {
+	case "in":
+		items, _ := r.([]interface{})
+		for _, item := range items {
+			if item == l {
+				return true
+			}
+		}
+		return false
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	}
+
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		return ok && order(n.op, compareNumbers(lv, rv))
+	case string:
+		rv, ok := r.(string)
+		return ok && order(n.op, strings.Compare(lv, rv))
+	}
+
+	return false
+}
+
+func compareNumbers(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func order(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+type cidrNode struct {
+	addr    node
+	network *net.IPNet
+}
+
+func (n *cidrNode) typ() valueType {
+	return typeBool
+}
+
+func (n *cidrNode) eval(env *Env) interface{} {
+	s, _ := n.addr.eval(env).(string)
+	ip := net.ParseIP(s)
+	return ip != nil && n.network.Contains(ip)
+}
@@ -0,0 +1,129 @@
+package condition
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{
+		`cidr(ip, "10.0.0.0/8")`,
+		`hour >= 9 && hour < 18`,
+		`weekday in ["mon", "tue", "wed", "thu", "fri"]`,
+		`user.department == resource.department`,
+		`!(ip == "127.0.0.1") || user.level > "2"`,
+		`hour("Asia/Ho_Chi_Minh") >= 8 && weekday("Europe/Paris") != "sun"`,
+	}
+	for _, src := range valid {
+		_, err := Compile(src)
+		require.Nil(t, err, src)
+	}
+
+	invalid := []string{
+		``,
+		`hour`,
+		`hour >= "9"`,
+		`ip in "10.0.0.1"`,
+		`cidr(ip, "10.0.0.0")`,
+		`cidr(ip, user.range)`,
+		`hour("Mars/Olympus") > 9`,
+		`hour("") > 9`,
+		`hour(user.zone) > 9`,
+		`weekday() == "mon"`,
+		`hour("UTC") == "9"`,
+		`exec("rm")`,
+		`os.env == "x"`,
+		`user.a.b == "x"`,
+		`hour > 9 &&`,
+		`(hour > 9`,
+		`ip == "a\"b"`,
+		`hour > 9 ; hour < 10`,
+		`[1, 2] == [1, 2]`,
+	}
+	for _, src := range invalid {
+		_, err := Compile(src)
+		require.True(t, errors.Is(err, ErrInvalid), src)
+	}
+}
+
+func TestCompile_limits(t *testing.T) {
+	t.Parallel()
+
+	long := `ip == "` + string(make([]byte, MaxLength)) + `"`
+	require.NotNil(t, Validate(long))
+
+	nested := ""
+	for i := 0; i < maxDepth+2; i++ {
+		nested += "("
+	}
+	nested += "hour > 1"
+	for i := 0; i < maxDepth+2; i++ {
+		nested += ")"
+	}
+	require.NotNil(t, Validate(nested))
+}
+
+func TestAll(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "", All("", " "))
+	require.Equal(t, `hour > 9`, All("", "hour > 9"))
+	require.Equal(t, `(hour > 9) && (ip == "10.0.0.1" || hour < 2)`, All("hour > 9", `ip == "10.0.0.1" || hour < 2`))
+
+	// combined conditions may go beyond the limits of saved ones
+	long := `ip != "` + strings.Repeat("a", MaxLength-len(`ip != ""`)) + `"`
+	require.Nil(t, Validate(long))
+	_, err := Compile(All(long, long))
+	require.Nil(t, err)
+}
+
+func TestEval(t *testing.T) {
+	t.Parallel()
+
+	// Wednesday at 10:30
+	env := &Env{
+		IP:       "10.1.2.3",
+		Time:     time.Date(2020, 7, 15, 10, 30, 0, 0, time.UTC),
+		User:     map[string]string{"department": "sales"},
+		Resource: map[string]string{"department": "sales"},
+	}
+
+	cases := []struct {
+		src  string
+		want bool
+	}{
+		{`cidr(ip, "10.0.0.0/8")`, true},
+		{`cidr(ip, "192.168.0.0/16")`, false},
+		{`hour >= 9 && hour < 18`, true},
+		{`weekday in ["sat", "sun"]`, false},
+		{`!(weekday in ["sat", "sun"])`, true},
+		{`user.department == resource.department`, true},
+		{`user.team == resource.team && user.team != ""`, false},
+		{`hour < 9 || ip == "10.1.2.3"`, true},
+		{`hour("Asia/Tokyo") == 19 && weekday("Asia/Tokyo") == "wed"`, true},
+		{`hour("America/Los_Angeles") >= 9`, false},
+		{`weekday("Pacific/Kiritimati") == "thu"`, true},
+
+		// missing attributes can't be compared, not even with each other
+		{`user.team == resource.team`, false},
+		{`user.team != "sales"`, false},
+		{`user.team in ["", "sales"]`, false},
+		{`user.team < "z"`, false},
+		{`user.department != resource.team`, false},
+	}
+
+	for _, c := range cases {
+		e, err := Compile(c.src)
+		require.Nil(t, err, c.src)
+		require.Equal(t, c.want, e.Eval(env), c.src)
+	}
+
+	require.False(t, Eval(`cidr(ip, "10.0.0.0/8")`, &Env{IP: "not an ip"}))
+	require.False(t, Eval(`not a condition`, env))
+}
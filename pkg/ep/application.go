@@ -0,0 +1,198 @@
+package ep
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymgr"
+	"time"
+)
+
+type Application struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Desc      string    `json:"desc"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Applications struct {
+	Records []*Application `json:"records"`
+	Total   int64          `json:"total"`
+	Page    int64          `json:"page"`
+	PerPage int64          `json:"per_page"`
+}
+
+type AddingApplication struct {
+	Name string `json:"name"`
+	Desc string `json:"desc"`
+}
+
+type ModifyingApplication struct {
+	Lookup string
+	Desc   string `json:"desc"`
+}
+
+type QueryingApplication struct {
+	Name    string
+	Sort    string
+	Page    int64
+	PerPage int64
+}
+
+func AddingApplicationEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	appch := make(chan *keymgr.Application)
+	keyserv := ctx.Value(common.KeyManagementService).(keymgr.Service)
+
+	go func() {
+		req, ok := request.(*AddingApplication)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if _, err := keyserv.AddApplication(req.Name, req.Desc); err != nil {
+			erch <- err
+			return
+		}
+
+		app, err := keyserv.GetApplication(req.Name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		appch <- app
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case app := <-appch:
+		return toApplication(app), nil
+	}
+}
+
+func ModifyingApplicationEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	keyserv := ctx.Value(common.KeyManagementService).(keymgr.Service)
+
+	go func() {
+		req, ok := request.(*ModifyingApplication)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := keyserv.ModifyApplication(req.Lookup, req.Desc); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func GettingApplicationEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	appch := make(chan *keymgr.Application)
+	keyserv := ctx.Value(common.KeyManagementService).(keymgr.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok || len(name) == 0 {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		app, err := keyserv.GetApplication(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		if app == nil {
+			erch <- common.ErrApplicationNotFound
+			return
+		}
+		appch <- app
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case app := <-appch:
+		return toApplication(app), nil
+	}
+}
+
+func QueryingApplicationEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	appch := make(chan []*keymgr.Application)
+	keyserv := ctx.Value(common.KeyManagementService).(keymgr.Service)
+	params, ok := request.(*QueryingApplication)
+
+	var total int64
+
+	go func() {
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if params.PerPage == 0 {
+			params.PerPage = common.Take
+		}
+
+		if params.Page == 0 {
+			params.Page = 1
+		}
+
+		records, count, err := keyserv.QueryApplications(params.Page, params.PerPage, params.Name, params.Sort)
+		if err != nil {
+			erch <- err
+			return
+		}
+		total = count
+		appch <- records
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-appch:
+		rows := make([]*Application, 0, len(lst))
+		for _, row := range lst {
+			rows = append(rows, toApplication(row))
+		}
+		return &Applications{
+			rows,
+			total,
+			params.Page,
+			params.PerPage,
+		}, nil
+	}
+}
+
+func toApplication(app *keymgr.Application) *Application {
+	return &Application{
+		app.ID,
+		app.Name,
+		app.Desc,
+		app.CreatedAt,
+		app.UpdatedAt,
+	}
+}
@@ -0,0 +1,401 @@
+package ep
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"time"
+)
+
+// maxAuditError is the length which errors are cut to in audit events
+const maxAuditError = 255
+
+type AuditEvent struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Diff      json.RawMessage `json:"diff,omitempty"`
+	IP        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	Outcome   string          `json:"outcome"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	PrevHash  string          `json:"prev_hash,omitempty"`
+	Hash      string          `json:"hash,omitempty"`
+}
+
+type AuditEvents struct {
+	Records []*AuditEvent `json:"records"`
+	Total   int64         `json:"total"`
+	Page    int64         `json:"page"`
+	PerPage int64         `json:"per_page"`
+}
+
+type QueryingAuditEvent struct {
+	Filter  *audit.Filter
+	Page    int64
+	PerPage int64
+}
+
+type ExportingAuditEvent struct {
+	Filter *audit.Filter
+	Format string
+}
+
+// AuditExport is the response of the export API, it's written as csv when the format asks for it
+type AuditExport struct {
+	Format string
+	Events []*AuditEvent
+}
+
+type AuditVerification struct {
+	Valid    bool  `json:"valid"`
+	Checked  int64 `json:"checked"`
+	BrokenAt int64 `json:"broken_at,omitempty"`
+}
+
+// resourceBunch is implemented by requests made for a bunch, which is snapshot before and after audited changes
+type resourceBunch interface {
+	resourceBunch() string
+}
+
+func (r *ModifyingBunch) resourceBunch() string {
+	return r.Lookup
+}
+
+func (r *AddingKeysToBunch) resourceBunch() string {
+	return r.Bunch
+}
+
+func (r *AddingKeyToBunch) resourceBunch() string {
+	return r.Bunch
+}
+
+func (r *ModifyingKeyCondition) resourceBunch() string {
+	return r.Bunch
+}
+
+func (r *ChangingBunchDenials) resourceBunch() string {
+	return r.Bunch
+}
+
+// AuditMiddleware records every call of the endpoint as the action in the audit log, with snapshots of the user
+// or bunch which the request is made for. Services make changes in transactions of their own, so the call is
+// recorded as attempted before it's made and with its outcome after it. The call isn't made when the attempt
+// can't be recorded, and fails when the outcome can't be recorded.
+func AuditMiddleware(action string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			aserv, ok := ctx.Value(common.AuditService).(audit.Service)
+			if !ok {
+				return next(ctx, request)
+			}
+
+			ip, _ := ctx.Value(common.RemoteAddrContextKey).(string)
+			agent, _ := ctx.Value(common.UserAgentContextKey).(string)
+			attempt := &audit.Event{
+				Actor:     auditActor(ctx, request),
+				Action:    action,
+				Target:    auditTarget(ctx, request),
+				Before:    auditSnapshot(ctx, request, false),
+				IP:        ip,
+				UserAgent: agent,
+				Outcome:   audit.Attempted,
+			}
+			if err := aserv.Record(attempt); err != nil {
+				return nil, err
+			}
+
+			response, err := next(ctx, request)
+
+			event := &audit.Event{
+				Actor:     attempt.Actor,
+				Action:    action,
+				Target:    attempt.Target,
+				Before:    attempt.Before,
+				IP:        ip,
+				UserAgent: agent,
+				Outcome:   audit.Success,
+			}
+			if err != nil {
+				event.Outcome = audit.Failure
+				event.Error = err.Error()
+				if len(event.Error) > maxAuditError {
+					event.Error = event.Error[:maxAuditError]
+				}
+			} else {
+				event.After = auditSnapshot(ctx, request, true)
+				event.Diff = audit.Diff(event.Before, event.After)
+			}
+
+			if rerr := aserv.Record(event); rerr != nil && err == nil {
+				return nil, rerr
+			}
+
+			return response, err
+		}
+	}
+}
+
+// auditActor is the caller, or the user logging in
+func auditActor(ctx context.Context, request interface{}) string {
+	if claims, ok := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims); ok {
+		return claims.Audience
+	}
+	if r, ok := request.(*VerifyingUser); ok {
+		return r.Username
+	}
+	return ""
+}
+
+func auditTarget(ctx context.Context, request interface{}) string {
+	switch r := request.(type) {
+	case resourceUser:
+		return "user:" + r.resourceUser()
+	case resourceBunch:
+		return "bunch:" + r.resourceBunch()
+	}
+
+	path, _ := ctx.Value(common.RequestPathContextKey).(string)
+	return path
+}
+
+// auditSnapshot returns json of the user or the bunch which the request is made for, as it's stored. Renamed
+// users and bunches are looked up by their new names after the change.
+func auditSnapshot(ctx context.Context, request interface{}, after bool) string {
+	var snapshot interface{}
+
+	switch r := request.(type) {
+	case resourceUser:
+		name := r.resourceUser()
+		if m, ok := r.(*ModifyingUser); ok && after && len(m.Username) > 0 {
+			name = m.Username
+		}
+		snapshot = userSnapshot(ctx, name)
+	case resourceBunch:
+		name := r.resourceBunch()
+		if m, ok := r.(*ModifyingBunch); ok && after && len(m.Name) > 0 {
+			name = m.Name
+		}
+		snapshot = bunchSnapshot(ctx, name)
+	}
+
+	if snapshot == nil {
+		return ""
+	}
+
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return ""
+	}
+
+	return string(raw)
+}
+
+func userSnapshot(ctx context.Context, name string) map[string]interface{} {
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	user, err := userv.GetUserByUsername(name)
+	if err != nil || user == nil {
+		return nil
+	}
+
+	bunches := make([]string, 0)
+	if lst, err := userv.GetBunches(name); err == nil {
+		for _, b := range lst {
+			bunches = append(bunches, b.Name)
+		}
+	}
+
+	denials := make([]string, 0)
+	if lst, err := userv.GetDenials(name); err == nil {
+		for _, d := range lst {
+			if len(d.Bunch) == 0 {
+				denials = append(denials, d.Key)
+			}
+		}
+	}
+
+	attrs, _ := userv.GetAttributes(name)
+
+	return map[string]interface{}{
+		"username":   user.Username,
+		"email":      user.Email,
+		"active":     user.Active.Bool,
+		"type":       user.Type,
+		"owner":      user.Owner,
+		"desc":       user.Desc,
+		"bunches":    bunches,
+		"denials":    denials,
+		"attributes": attrs,
+	}
+}
+
+func bunchSnapshot(ctx context.Context, name string) map[string]interface{} {
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	bunch, err := bserv.GetBunchByName(name)
+	if err != nil || bunch == nil {
+		return nil
+	}
+
+	keys := make(map[string]string)
+	if lst, err := bserv.GetKeysInBunch(name); err == nil {
+		for _, k := range lst {
+			keys[k.Key] = k.Condition
+		}
+	}
+
+	denials := make([]string, 0)
+	if lst, err := bserv.GetDenials(name); err == nil {
+		for _, k := range lst {
+			denials = append(denials, k.Key)
+		}
+	}
+
+	return map[string]interface{}{
+		"name":    bunch.Name,
+		"desc":    bunch.Desc,
+		"active":  bunch.Active.Bool,
+		"keys":    keys,
+		"denials": denials,
+	}
+}
+
+func QueryingAuditEventEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	ech := make(chan []*audit.Event)
+	aserv := ctx.Value(common.AuditService).(audit.Service)
+	params, ok := request.(*QueryingAuditEvent)
+
+	var total int64
+
+	go func() {
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if params.PerPage == 0 {
+			params.PerPage = common.Take
+		}
+
+		if params.Page == 0 {
+			params.Page = 1
+		}
+
+		records, count, err := aserv.QueryEvents(params.Page, params.PerPage, params.Filter)
+		if err != nil {
+			erch <- err
+			return
+		}
+		total = count
+		ech <- records
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-ech:
+		return &AuditEvents{
+			toAuditEvents(lst),
+			total,
+			params.Page,
+			params.PerPage,
+		}, nil
+	}
+}
+
+func ExportingAuditEventEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	ech := make(chan []*audit.Event)
+	aserv := ctx.Value(common.AuditService).(audit.Service)
+	params, ok := request.(*ExportingAuditEvent)
+
+	go func() {
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		events, err := aserv.ExportEvents(params.Filter)
+		if err != nil {
+			erch <- err
+			return
+		}
+		ech <- events
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-ech:
+		return &AuditExport{params.Format, toAuditEvents(lst)}, nil
+	}
+}
+
+func VerifyingAuditLogEndpoint(ctx context.Context, _ interface{}) (interface{}, error) {
+	erch := make(chan error)
+	vch := make(chan *audit.Verification)
+	aserv := ctx.Value(common.AuditService).(audit.Service)
+
+	go func() {
+		v, err := aserv.Verify()
+		if err != nil {
+			erch <- err
+			return
+		}
+		vch <- v
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case v := <-vch:
+		return &AuditVerification{v.Valid, v.Checked, v.BrokenAt}, nil
+	}
+}
+
+func toAuditEvents(lst []*audit.Event) []*AuditEvent {
+	rows := make([]*AuditEvent, 0, len(lst))
+	for _, e := range lst {
+		rows = append(rows, &AuditEvent{
+			e.ID,
+			e.Actor,
+			e.Action,
+			e.Target,
+			rawJSON(e.Before),
+			rawJSON(e.After),
+			rawJSON(e.Diff),
+			e.IP,
+			e.UserAgent,
+			e.Outcome,
+			e.Error,
+			e.CreatedAt,
+			e.PrevHash,
+			e.Hash,
+		})
+	}
+	return rows
+}
+
+func rawJSON(s string) json.RawMessage {
+	if len(s) == 0 {
+		return nil
+	}
+	return json.RawMessage(s)
+}
@@ -0,0 +1,58 @@
+package ep
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+// auditLog is an audit service keeping the events which it records, it fails to record while err is set
+type auditLog struct {
+	audit.Service
+	events []*audit.Event
+	err    error
+}
+
+func (s *auditLog) Record(e *audit.Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	log := &auditLog{}
+	ctx := context.WithValue(context.Background(), common.AuditService, log)
+	ctx = context.WithValue(ctx, common.RequestPathContextKey, "/keys")
+
+	calls := 0
+	var callErr error
+	ep := AuditMiddleware("add_key")(func(ctx context.Context, request interface{}) (interface{}, error) {
+		calls++
+		return nil, callErr
+	})
+
+	_, err := ep(ctx, nil)
+	require.Nil(t, err)
+
+	callErr = common.ErrDuplicatedKey
+	_, err = ep(ctx, nil)
+	require.Equal(t, common.ErrDuplicatedKey, err)
+
+	require.Len(t, log.events, 4)
+	require.Equal(t, []string{audit.Attempted, audit.Success, audit.Attempted, audit.Failure},
+		[]string{log.events[0].Outcome, log.events[1].Outcome, log.events[2].Outcome, log.events[3].Outcome})
+	require.Equal(t, "/keys", log.events[3].Target)
+	require.Equal(t, common.ErrDuplicatedKey.Error(), log.events[3].Error)
+
+	// calls aren't made when they can't be recorded
+	log.err = errors.New("Error 1040: Too many connections")
+	_, err = ep(ctx, nil)
+	require.Equal(t, log.err, err)
+	require.Equal(t, 2, calls)
+}
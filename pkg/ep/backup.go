@@ -0,0 +1,77 @@
+package ep
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/backup"
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+type ExportingData struct {
+	WithHashes bool
+}
+
+type ImportingData struct {
+	Document *backup.Document
+	Strategy string
+	DryRun   bool
+}
+
+func ExportingDataEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	dch := make(chan *backup.Document)
+	bkserv := ctx.Value(common.BackupService).(backup.Service)
+
+	go func() {
+		req, ok := request.(*ExportingData)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		doc, err := bkserv.Export(req.WithHashes)
+		if err != nil {
+			erch <- err
+			return
+		}
+		dch <- doc
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case doc := <-dch:
+		return doc, nil
+	}
+}
+
+func ImportingDataEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	rch := make(chan *backup.Result)
+	bkserv := ctx.Value(common.BackupService).(backup.Service)
+
+	go func() {
+		req, ok := request.(*ImportingData)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		result, err := bkserv.Import(req.Document, req.Strategy, req.DryRun)
+		if err != nil {
+			erch <- err
+			return
+		}
+		rch <- result
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case result := <-rch:
+		return result, nil
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"github.com/vespaiach/auth/pkg/bunchmgr"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymatch"
 	"time"
 )
 
@@ -15,6 +16,9 @@ type Bunch struct {
 	Active    bool      `json:"active"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Condition of user's grant of the bunch, it's only set when bunches of a user are listed
+	Condition string `json:"condition,omitempty"`
 }
 
 type AddingBunch struct {
@@ -88,6 +92,7 @@ func AddingBunchEndpoint(ctx context.Context, request interface{}) (interface{},
 			b.Active.Bool,
 			b.CreatedAt,
 			b.UpdatedAt,
+			"",
 		}, nil
 	}
 }
@@ -175,6 +180,7 @@ func GettingBunchEndpoint(ctx context.Context, request interface{}) (interface{}
 			b.Active.Bool,
 			b.CreatedAt,
 			b.UpdatedAt,
+			"",
 		}, nil
 	}
 }
@@ -225,6 +231,7 @@ func QueryingBunchEndpoint(ctx context.Context, request interface{}) (interface{
 				row.Active.Bool,
 				row.CreatedAt,
 				row.UpdatedAt,
+				"",
 			})
 		}
 		return &Bunches{
@@ -299,9 +306,11 @@ func GettingKeysInBunchEndpoint(ctx context.Context, request interface{}) (inter
 			rows = append(rows, &Key{
 				row.ID,
 				row.Key,
+				keymatch.ApplicationOf(row.Key),
 				row.Desc,
 				row.CreatedAt,
 				row.UpdatedAt,
+				conditionsOf(row.Condition),
 			})
 		}
 		return rows, nil
@@ -0,0 +1,259 @@
+package ep
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/condition"
+	"github.com/vespaiach/auth/pkg/keymatch"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"time"
+)
+
+type ModifyingKeyCondition struct {
+	Bunch     string
+	Key       string
+	Condition string `json:"condition"`
+}
+
+type ModifyingBunchCondition struct {
+	Username  string
+	Bunch     string
+	Condition string `json:"condition"`
+}
+
+type ModifyingUserAttributes struct {
+	Username   string
+	Attributes map[string]string `json:"attributes"`
+}
+
+// resourceUser is implemented by requests made for a user, whose attributes are the resource's attributes
+// which conditions are evaluated against
+type resourceUser interface {
+	resourceUser() string
+}
+
+func (r *ModifyingUser) resourceUser() string {
+	return r.Lookup
+}
+
+func (r *AddingBunchesToUser) resourceUser() string {
+	return r.Username
+}
+
+func (r *RemovingBunchesFromUser) resourceUser() string {
+	return r.Username
+}
+
+func (r *ModifyingBunchCondition) resourceUser() string {
+	return r.Username
+}
+
+func (r *ModifyingUserAttributes) resourceUser() string {
+	return r.Username
+}
+
+// hasKey reports whether claims grant the key without condition, denied keys are never granted
+func hasKey(claims *TokenClaims, key string) bool {
+	return !keymatch.Any(claims.Denied, key) && keymatch.Any(claims.Keys, key)
+}
+
+// isGranted reports whether claims grant the key, denied keys are never granted. Keys which are granted with conditions are granted when one of
+// their conditions holds for the request, resource's attributes are given by the check API or read from the
+// request when it's made for a user.
+func isGranted(ctx context.Context, claims *TokenClaims, key string, request interface{}) (bool, error) {
+	if keymatch.Any(claims.Denied, key) {
+		return false, nil
+	}
+	if keymatch.Any(claims.Keys, key) {
+		return true, nil
+	}
+
+	conditions := make([]string, 0)
+	for pattern, cs := range claims.Conditions {
+		if keymatch.Match(pattern, key) {
+			conditions = append(conditions, cs...)
+		}
+	}
+	if len(conditions) == 0 {
+		return false, nil
+	}
+
+	resource := map[string]string{}
+	switch r := request.(type) {
+	case *CheckingKeys:
+		if r.Resource != nil {
+			resource = r.Resource
+		}
+	case resourceUser:
+		userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+		attrs, err := userv.GetAttributes(r.resourceUser())
+		if err != nil && err != common.ErrUserNotFound {
+			return false, err
+		}
+		if attrs != nil {
+			resource = attrs
+		}
+	}
+
+	env, err := requestEnv(ctx, claims, resource)
+	if err != nil {
+		return false, err
+	}
+
+	for _, c := range conditions {
+		if condition.Eval(c, env) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// requestEnv gathers what conditions are evaluated against, the caller's attributes are read in the caller's
+// tenant. The time is in UTC, conditions tell hours and weekdays of other zones with hour(zone) and weekday(zone).
+func requestEnv(ctx context.Context, claims *TokenClaims, resource map[string]string) (*condition.Env, error) {
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+	ip, _ := ctx.Value(common.RemoteAddrContextKey).(string)
+
+	tenant := claims.Tenant
+	if tenant == 0 {
+		tenant = common.DefaultTenant
+	}
+
+	user, err := userv.WithTenant(tenant).GetAttributes(claims.Audience)
+	if err != nil && err != common.ErrUserNotFound {
+		return nil, err
+	}
+
+	return &condition.Env{
+		IP:       ip,
+		Time:     time.Now().UTC(),
+		User:     user,
+		Resource: resource,
+	}, nil
+}
+
+func conditionsOf(expression string) []string {
+	if len(expression) == 0 {
+		return nil
+	}
+	return []string{expression}
+}
+
+func ModifyingKeyConditionEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	go func() {
+		req, ok := request.(*ModifyingKeyCondition)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := bserv.SetKeyCondition(req.Bunch, req.Key, req.Condition); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func ModifyingBunchConditionEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	go func() {
+		req, ok := request.(*ModifyingBunchCondition)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := userv.SetBunchCondition(req.Username, req.Bunch, req.Condition); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func GettingUserAttributesEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	ach := make(chan map[string]string)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		attrs, err := userv.GetAttributes(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		ach <- attrs
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case attrs := <-ach:
+		return attrs, nil
+	}
+}
+
+func ModifyingUserAttributesEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	go func() {
+		req, ok := request.(*ModifyingUserAttributes)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := userv.SetAttributes(req.Username, req.Attributes); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
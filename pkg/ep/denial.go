@@ -0,0 +1,250 @@
+package ep
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymatch"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+type Denial struct {
+	Key   string `json:"key"`
+	Bunch string `json:"bunch,omitempty"`
+}
+
+type Grant struct {
+	Bunch     string `json:"bunch"`
+	Key       string `json:"key"`
+	Condition string `json:"condition,omitempty"`
+}
+
+type Explanation struct {
+	Key         string    `json:"key"`
+	Granted     bool      `json:"granted"`
+	Conditional bool      `json:"conditional"`
+	Allows      []*Grant  `json:"allows"`
+	Denials     []*Denial `json:"denials"`
+}
+
+type ChangingBunchDenials struct {
+	Bunch string
+	Keys  []string `json:"keys"`
+}
+
+type ChangingUserDenials struct {
+	Username string
+	Keys     []string `json:"keys"`
+}
+
+type ExplainingKey struct {
+	Username string
+	Key      string
+}
+
+func (r *ChangingUserDenials) resourceUser() string {
+	return r.Username
+}
+
+func (r *ExplainingKey) resourceUser() string {
+	return r.Username
+}
+
+func AddingBunchDenialsEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	return changeBunchDenials(ctx, request, bunchmgr.Service.AddDenials)
+}
+
+func RemovingBunchDenialsEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	return changeBunchDenials(ctx, request, bunchmgr.Service.RemoveDenials)
+}
+
+func changeBunchDenials(ctx context.Context, request interface{},
+	change func(bunchmgr.Service, string, []string) error) (interface{}, error) {
+
+	erch := make(chan error)
+	success := make(chan bool)
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	go func() {
+		req, ok := request.(*ChangingBunchDenials)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := change(bserv, req.Bunch, req.Keys); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func GettingBunchDenialsEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	kch := make(chan []*bunchmgr.Key)
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		keys, err := bserv.GetDenials(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		kch <- keys
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-kch:
+		rows := make([]*Key, 0, len(lst))
+		for _, row := range lst {
+			rows = append(rows, &Key{
+				row.ID,
+				row.Key,
+				keymatch.ApplicationOf(row.Key),
+				row.Desc,
+				row.CreatedAt,
+				row.UpdatedAt,
+				nil,
+			})
+		}
+		return rows, nil
+	}
+}
+
+func AddingUserDenialsEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	return changeUserDenials(ctx, request, usrmgr.Service.AddDenials)
+}
+
+func RemovingUserDenialsEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	return changeUserDenials(ctx, request, usrmgr.Service.RemoveDenials)
+}
+
+func changeUserDenials(ctx context.Context, request interface{},
+	change func(usrmgr.Service, string, []string) error) (interface{}, error) {
+
+	erch := make(chan error)
+	success := make(chan bool)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	go func() {
+		req, ok := request.(*ChangingUserDenials)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := change(userv, req.Username, req.Keys); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func GettingUserDenialsEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	dch := make(chan []*usrmgr.Denial)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		denials, err := userv.GetDenials(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		dch <- denials
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-dch:
+		return toDenials(lst), nil
+	}
+}
+
+// ExplainingKeyEndpoint tells which grants and denials of a user decide whether a key is granted
+func ExplainingKeyEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	ech := make(chan *usrmgr.Explanation)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	go func() {
+		req, ok := request.(*ExplainingKey)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		explanation, err := userv.Explain(req.Username, req.Key)
+		if err != nil {
+			erch <- err
+			return
+		}
+		ech <- explanation
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case ex := <-ech:
+		allows := make([]*Grant, 0, len(ex.Allows))
+		for _, g := range ex.Allows {
+			allows = append(allows, &Grant{g.Bunch, g.Key, g.Condition})
+		}
+		return &Explanation{
+			ex.Key,
+			ex.Granted,
+			ex.Conditional,
+			allows,
+			toDenials(ex.Denials),
+		}, nil
+	}
+}
+
+func toDenials(lst []*usrmgr.Denial) []*Denial {
+	rows := make([]*Denial, 0, len(lst))
+	for _, d := range lst {
+		rows = append(rows, &Denial{d.Key, d.Bunch})
+	}
+	return rows
+}
@@ -0,0 +1,90 @@
+package ep
+
+import (
+	"context"
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"time"
+)
+
+// streamBatch is how many events are read at once while streaming
+const streamBatch = 100
+
+// StreamingEvent asks for events after the sequence number LastEventID, every event since the stream is opened
+// is sent when it's zero
+type StreamingEvent struct {
+	LastEventID int64
+	Types       []string
+}
+
+// EventStream is the response of the stream API. The transport keeps writing events read by Next until
+// the request is done, until the caller's token expires at Until unless it's zero, or until Check finds that
+// the token is revoked.
+type EventStream struct {
+	LastEventID int64
+	Until       time.Time
+	Next        func(afterSeq int64) ([]*webhook.Event, error)
+	Check       func() error
+}
+
+func StreamingEventEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	sch := make(chan *EventStream)
+	wserv := ctx.Value(common.WebhookService).(webhook.Service)
+
+	go func() {
+		req, ok := request.(*StreamingEvent)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		claims, ok := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims)
+		if !ok {
+			erch <- common.ErrMissingJWTToken
+			return
+		}
+		tokenStr, _ := ctx.Value(jwt.JWTTokenContextKey).(string)
+
+		// types are checked before the stream is opened, so that a bad filter is refused with its error
+		if len(req.Types) > 0 && len(webhook.Expand(req.Types)) == 0 {
+			erch <- common.ErrEventTypeInvalid
+			return
+		}
+
+		lastSeq := req.LastEventID
+		if lastSeq == 0 {
+			seq, err := wserv.GetLastSeq()
+			if err != nil {
+				erch <- err
+				return
+			}
+			lastSeq = seq
+		}
+
+		stream := &EventStream{
+			LastEventID: lastSeq,
+			Next: func(afterSeq int64) ([]*webhook.Event, error) {
+				return wserv.GetEvents(afterSeq, req.Types, streamBatch)
+			},
+			Check: func() error {
+				_, err := parseToken(ctx, tokenStr)
+				return err
+			},
+		}
+		if claims.ExpiresAt > 0 {
+			stream.Until = time.Unix(claims.ExpiresAt, 0)
+		}
+		sch <- stream
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case s := <-sch:
+		return s, nil
+	}
+}
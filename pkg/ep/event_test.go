@@ -0,0 +1,57 @@
+package ep
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
+	"github.com/vespaiach/auth/pkg/webhook"
+)
+
+// outbox is a webhook service which only knows the last sequence number
+type outbox struct {
+	webhook.Service
+}
+
+func (s *outbox) GetLastSeq() (int64, error) {
+	return 42, nil
+}
+
+// pats is a token service which knows a single token until it's revoked
+type pats struct {
+	tokenmgr.Service
+	revoked bool
+}
+
+func (s *pats) Authenticate(plain string) (*tokenmgr.Token, error) {
+	if s.revoked {
+		return nil, common.ErrPersonalTokenInvalid
+	}
+	return &tokenmgr.Token{Username: "robot", TenantID: common.DefaultTenant, Keys: []string{"stream_events"}}, nil
+}
+
+func TestStreamingEventEndpoint_Check(t *testing.T) {
+	tserv := new(pats)
+	plain := tokenmgr.Prefix + "secret"
+
+	ctx := context.WithValue(context.Background(), common.AppConfigContextKey, &cf.AppConfig{})
+	ctx = context.WithValue(ctx, common.WebhookService, &outbox{})
+	ctx = context.WithValue(ctx, common.TokenManagementService, tserv)
+	ctx = context.WithValue(ctx, jwt.JWTTokenContextKey, plain)
+	ctx = context.WithValue(ctx, jwt.JWTClaimsContextKey, &TokenClaims{})
+
+	res, err := StreamingEventEndpoint(ctx, &StreamingEvent{})
+	require.Nil(t, err)
+
+	stream := res.(*EventStream)
+	require.Equal(t, int64(42), stream.LastEventID)
+	require.Nil(t, stream.Check())
+
+	// the stream is closed once its token is revoked
+	tserv.revoked = true
+	require.Equal(t, common.ErrPersonalTokenInvalid, stream.Check())
+}
@@ -0,0 +1,206 @@
+package ep
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/common"
+	"time"
+)
+
+type Exclusion struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Desc      string    `json:"desc"`
+	Bunches   []string  `json:"bunches"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AddingExclusion struct {
+	Name    string   `json:"name"`
+	Desc    string   `json:"desc"`
+	Bunches []string `json:"bunches"`
+}
+
+type Violation struct {
+	Exclusion string   `json:"exclusion"`
+	Username  string   `json:"username"`
+	Bunches   []string `json:"bunches"`
+}
+
+func AddingExclusionEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	ech := make(chan *bunchmgr.Exclusion)
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	go func() {
+		req, ok := request.(*AddingExclusion)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		_, err := bserv.AddExclusion(req.Name, req.Desc, req.Bunches)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		exclusion, err := bserv.GetExclusion(req.Name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		ech <- exclusion
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case e := <-ech:
+		return &Exclusion{
+			e.ID,
+			e.Name,
+			e.Desc,
+			e.Bunches,
+			e.CreatedAt,
+		}, nil
+	}
+}
+
+func GettingExclusionEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	ech := make(chan *bunchmgr.Exclusion)
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		exclusion, err := bserv.GetExclusion(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		if exclusion == nil {
+			erch <- common.ErrExclusionNotFound
+			return
+		}
+		ech <- exclusion
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case e := <-ech:
+		return &Exclusion{
+			e.ID,
+			e.Name,
+			e.Desc,
+			e.Bunches,
+			e.CreatedAt,
+		}, nil
+	}
+}
+
+func QueryingExclusionEndpoint(ctx context.Context, _ interface{}) (interface{}, error) {
+	erch := make(chan error)
+	ech := make(chan []*bunchmgr.Exclusion)
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	go func() {
+		exclusions, err := bserv.GetExclusions()
+		if err != nil {
+			erch <- err
+			return
+		}
+		ech <- exclusions
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-ech:
+		rows := make([]*Exclusion, 0, len(lst))
+		for _, row := range lst {
+			rows = append(rows, &Exclusion{
+				row.ID,
+				row.Name,
+				row.Desc,
+				row.Bunches,
+				row.CreatedAt,
+			})
+		}
+		return rows, nil
+	}
+}
+
+func RemovingExclusionEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		err := bserv.RemoveExclusion(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func GettingExclusionViolationsEndpoint(ctx context.Context, _ interface{}) (interface{}, error) {
+	erch := make(chan error)
+	vch := make(chan []*bunchmgr.Violation)
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	go func() {
+		violations, err := bserv.GetViolations()
+		if err != nil {
+			erch <- err
+			return
+		}
+		vch <- violations
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-vch:
+		rows := make([]*Violation, 0, len(lst))
+		for _, row := range lst {
+			rows = append(rows, &Violation{
+				row.Exclusion,
+				row.Username,
+				row.Bunches,
+			})
+		}
+		return rows, nil
+	}
+}
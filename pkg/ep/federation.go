@@ -0,0 +1,87 @@
+package ep
+
+import (
+	"context"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/sso"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+type FederatedCallback struct {
+	Provider    string
+	Code        string
+	State       string
+	SignedState string
+	Error       string
+}
+
+// StartingFederatedLoginEndpoint returns where to redirect a user to sign in at an upstream provider
+func StartingFederatedLoginEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	rch := make(chan *sso.Redirect)
+	sserv := ctx.Value(common.FederationService).(sso.Service)
+
+	go func() {
+		provider, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		redirect, err := sserv.Begin(provider)
+		if err != nil {
+			erch <- err
+			return
+		}
+		rch <- redirect
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case r := <-rch:
+		return r, nil
+	}
+}
+
+// FederatedUserMiddleware finishes an upstream login and passes the linked user to next endpoint,
+// which is IssueTokenEndpoint, so users get the same tokens as with a password login
+func FederatedUserMiddleware(ep endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		erch := make(chan error)
+		uch := make(chan *usrmgr.User)
+		sserv := ctx.Value(common.FederationService).(sso.Service)
+
+		go func() {
+			req, ok := request.(*FederatedCallback)
+			if !ok {
+				erch <- common.ErrWrongInputDatatype
+				return
+			}
+
+			if len(req.Error) > 0 {
+				erch <- common.ErrUpstreamLoginError
+				return
+			}
+
+			user, err := sserv.Finish(req.Provider, req.Code, req.State, req.SignedState)
+			if err != nil {
+				erch <- err
+				return
+			}
+			uch <- user
+		}()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case e := <-erch:
+			return nil, e
+		case u := <-uch:
+			return ep(ctx, u)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package ep
 
 import (
 	"context"
+	"github.com/go-kit/kit/auth/jwt"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymatch"
 	"github.com/vespaiach/auth/pkg/keymgr"
 	"time"
 )
@@ -13,11 +15,15 @@ type AddingKey struct {
 }
 
 type Key struct {
-	ID        int64     `json:"id"`
-	Key       string    `json:"key"`
-	Desc      string    `json:"desc"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          int64     `json:"id"`
+	Key         string    `json:"key"`
+	Application string    `json:"application,omitempty"`
+	Desc        string    `json:"desc"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Conditions of the grants leading to the key, it's only set on keys granted with conditions
+	Conditions []string `json:"conditions,omitempty"`
 }
 
 type Keys struct {
@@ -28,10 +34,11 @@ type Keys struct {
 }
 
 type QueryingKey struct {
-	Name    string
-	Sort    string
-	Page    int64
-	PerPage int64
+	Name        string
+	Application string
+	Sort        string
+	Page        int64
+	PerPage     int64
 }
 
 type ModifyingKey struct {
@@ -45,6 +52,17 @@ type AddingKeyToBunch struct {
 	Bunch string `json:"bunch"`
 }
 
+type CheckingKeys struct {
+	Keys []string `json:"keys"`
+
+	// Resource holds attributes of the resource which conditions of grants are evaluated against
+	Resource map[string]string `json:"resource"`
+}
+
+type CheckedKeys struct {
+	Keys map[string]bool `json:"keys"`
+}
+
 func AddingKeyEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
 	erch := make(chan error)
 	keych := make(chan *keymgr.Key)
@@ -80,9 +98,11 @@ func AddingKeyEndpoint(ctx context.Context, request interface{}) (interface{}, e
 		return &Key{
 			key.ID,
 			key.Key,
+			keymatch.ApplicationOf(key.Key),
 			key.Desc,
 			key.CreatedAt,
 			key.UpdatedAt,
+			nil,
 		}, nil
 	}
 }
@@ -132,9 +152,11 @@ func ModifyingKeyEndpoint(ctx context.Context, request interface{}) (interface{}
 		return &Key{
 			key.ID,
 			key.Key,
+			keymatch.ApplicationOf(key.Key),
 			key.Desc,
 			key.CreatedAt,
 			key.UpdatedAt,
+			nil,
 		}, nil
 	}
 }
@@ -168,9 +190,11 @@ func GettingKeyEndpoint(ctx context.Context, request interface{}) (interface{},
 		return &Key{
 			key.ID,
 			key.Key,
+			keymatch.ApplicationOf(key.Key),
 			key.Desc,
 			key.CreatedAt,
 			key.UpdatedAt,
+			nil,
 		}, nil
 	}
 }
@@ -197,7 +221,8 @@ func QueryingKeyEndpoint(ctx context.Context, request interface{}) (interface{},
 			params.Page = 1
 		}
 
-		records, count, err := keyserv.QueryKeys(params.Page, params.PerPage, params.Name, params.Sort)
+		records, count, err := keyserv.QueryKeys(params.Page, params.PerPage, params.Name, params.Application,
+			params.Sort)
 		if err != nil {
 			erch <- err
 			return
@@ -217,9 +242,11 @@ func QueryingKeyEndpoint(ctx context.Context, request interface{}) (interface{},
 			rows = append(rows, &Key{
 				row.ID,
 				row.Key,
+				keymatch.ApplicationOf(row.Key),
 				row.Desc,
 				row.CreatedAt,
 				row.UpdatedAt,
+				nil,
 			})
 		}
 		return &Keys{
@@ -261,3 +288,45 @@ func AddingKeyToBunchEndpoint(ctx context.Context, request interface{}) (interfa
 		return id, nil
 	}
 }
+
+// CheckingKeysEndpoint tells which of the keys are granted to the caller's token, with the same matching and
+// conditions as KeyCheckerMiddleware
+func CheckingKeysEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	cch := make(chan map[string]bool)
+
+	go func() {
+		req, ok := request.(*CheckingKeys)
+		if !ok || len(req.Keys) == 0 {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		claims, ok := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims)
+		if !ok {
+			erch <- common.ErrMissingJWTToken
+			return
+		}
+
+		checked := make(map[string]bool, len(req.Keys))
+		for _, k := range req.Keys {
+			granted, err := isGranted(ctx, claims, k, req)
+			if err != nil {
+				erch <- err
+				return
+			}
+			checked[k] = granted
+		}
+
+		cch <- checked
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case checked := <-cch:
+		return &CheckedKeys{checked}, nil
+	}
+}
@@ -0,0 +1,70 @@
+package ep
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/manifest"
+)
+
+// ApplyingManifest applies the manifest, or only plans it on a dry run. When PlanHash is given, the manifest is
+// applied only if it still makes the plan which was reviewed.
+type ApplyingManifest struct {
+	Manifest *manifest.Manifest
+	DryRun   bool
+	Prune    bool
+	PlanHash string
+}
+
+type ManifestPlan struct {
+	Changes []*manifest.Change `json:"changes"`
+	Summary string             `json:"summary"`
+	Hash    string             `json:"hash"`
+	Applied bool               `json:"applied"`
+}
+
+func ApplyingManifestEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	pch := make(chan *manifest.Plan)
+	mserv := ctx.Value(common.ManifestService).(manifest.Service)
+	req, ok := request.(*ApplyingManifest)
+
+	go func() {
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		var (
+			plan *manifest.Plan
+			err  error
+		)
+		if req.DryRun {
+			plan, err = mserv.Plan(req.Manifest, req.Prune)
+		} else {
+			plan, err = mserv.Apply(req.Manifest, req.Prune, req.PlanHash)
+		}
+		if err != nil {
+			erch <- err
+			return
+		}
+		pch <- plan
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case p := <-pch:
+		changes := p.Changes
+		if changes == nil {
+			changes = make([]*manifest.Change, 0)
+		}
+		return &ManifestPlan{
+			changes,
+			p.String(),
+			p.Hash,
+			!req.DryRun && len(p.Changes) > 0,
+		}, nil
+	}
+}
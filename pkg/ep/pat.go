@@ -0,0 +1,209 @@
+package ep
+
+import (
+	"context"
+	"database/sql"
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymatch"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
+	"time"
+)
+
+type PersonalToken struct {
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Keys       []string   `json:"keys"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Revoked    bool       `json:"revoked"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	// Token is the plain token, it's only returned once when the token is created
+	Token string `json:"token,omitempty"`
+}
+
+type AddingPersonalToken struct {
+	Name      string     `json:"name"`
+	Keys      []string   `json:"keys"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func AddingPersonalTokenEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	tch := make(chan *PersonalToken)
+	tserv := ctx.Value(common.TokenManagementService).(tokenmgr.Service)
+
+	go func() {
+		req, ok := request.(*AddingPersonalToken)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		// a token can't get keys which the caller's own token doesn't carry
+		claims, ok := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims)
+		if !ok {
+			erch <- common.ErrMissingJWTToken
+			return
+		}
+		for _, k := range req.Keys {
+			if !carriesKey(claims, k) {
+				erch <- common.ErrNotAllowed
+				return
+			}
+		}
+
+		var expiresAt sql.NullTime
+		if req.ExpiresAt != nil {
+			expiresAt = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+		}
+		expiresAt = childExpiry(claims, expiresAt)
+
+		// the token service is scoped to the request's tenant, where the caller's name may be someone else's
+		name, err := callerName(ctx)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		token, plain, err := tserv.CreateToken(name, req.Name, req.Keys, expiresAt)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		result := toPersonalToken(token)
+		result.Token = plain
+		tch <- result
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case t := <-tch:
+		return t, nil
+	}
+}
+
+func QueryingPersonalTokenEndpoint(ctx context.Context, _ interface{}) (interface{}, error) {
+	erch := make(chan error)
+	tch := make(chan []*tokenmgr.Token)
+	tserv := ctx.Value(common.TokenManagementService).(tokenmgr.Service)
+
+	go func() {
+		name, err := callerName(ctx)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		tokens, err := tserv.GetTokens(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		tch <- tokens
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case tokens := <-tch:
+		results := make([]*PersonalToken, 0, len(tokens))
+		for _, t := range tokens {
+			results = append(results, toPersonalToken(t))
+		}
+		return results, nil
+	}
+}
+
+func RevokingPersonalTokenEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	dch := make(chan bool)
+	tserv := ctx.Value(common.TokenManagementService).(tokenmgr.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		caller, err := callerName(ctx)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		err = tserv.RevokeToken(caller, name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		dch <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-dch:
+		return nil, nil
+	}
+}
+
+func toPersonalToken(t *tokenmgr.Token) *PersonalToken {
+	result := &PersonalToken{
+		Name:      t.Name,
+		Prefix:    tokenmgr.Prefix + t.Prefix,
+		Keys:      t.Keys,
+		Revoked:   t.Revoked,
+		CreatedAt: t.CreatedAt,
+	}
+	if t.ExpiresAt.Valid {
+		result.ExpiresAt = &t.ExpiresAt.Time
+	}
+	if t.LastUsedAt.Valid {
+		result.LastUsedAt = &t.LastUsedAt.Time
+	}
+
+	return result
+}
+
+// carriesKey reports whether the caller's token carries the key, with or without conditions. Tokens which are
+// given keys that their owner holds with conditions carry those conditions.
+func carriesKey(claims *TokenClaims, key string) bool {
+	if keymatch.Any(claims.Denied, key) {
+		return false
+	}
+	if keymatch.Any(claims.Keys, key) {
+		return true
+	}
+
+	for pattern := range claims.Conditions {
+		if keymatch.Match(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// childExpiry caps the expiry of a token which is minted with a personal access token at that token's expiry,
+// so that tokens can't outlive the tokens which minted them
+func childExpiry(claims *TokenClaims, expiresAt sql.NullTime) sql.NullTime {
+	if !claims.Personal || claims.ExpiresAt == 0 {
+		return expiresAt
+	}
+
+	parent := time.Unix(claims.ExpiresAt, 0)
+	if !expiresAt.Valid || expiresAt.Time.After(parent) {
+		return sql.NullTime{Time: parent, Valid: true}
+	}
+	return expiresAt
+}
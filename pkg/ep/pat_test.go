@@ -0,0 +1,49 @@
+package ep
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
+)
+
+// issuer is a token service which keeps the owners of the tokens which it creates
+type issuer struct {
+	tokenmgr.Service
+	owners []string
+}
+
+func (s *issuer) CreateToken(username string, name string, keys []string,
+	expiresAt sql.NullTime) (*tokenmgr.Token, string, error) {
+
+	s.owners = append(s.owners, username)
+	return &tokenmgr.Token{Username: username, Name: name, Keys: keys}, tokenmgr.Prefix + "secret", nil
+}
+
+func (s *issuer) WithTenant(int64) tokenmgr.Service {
+	return s
+}
+
+func TestAddingPersonalTokenEndpoint(t *testing.T) {
+	tserv := new(issuer)
+	claims := &TokenClaims{Keys: []string{"get_user"}}
+	claims.Audience = "admin"
+
+	ctx := context.WithValue(context.Background(), common.TokenManagementService, tserv)
+	ctx = context.WithValue(ctx, jwt.JWTClaimsContextKey, claims)
+
+	_, err := AddingPersonalTokenEndpoint(withTenant(ctx, common.DefaultTenant),
+		&AddingPersonalToken{Name: "ci", Keys: []string{"get_user"}})
+	require.Nil(t, err)
+	require.Equal(t, []string{"admin"}, tserv.owners)
+
+	// the same name in another tenant is someone else, no token is made for them
+	_, err = AddingPersonalTokenEndpoint(withTenant(ctx, 2),
+		&AddingPersonalToken{Name: "ci", Keys: []string{"get_user"}})
+	require.Equal(t, common.ErrNotAllowed, err)
+	require.Len(t, tserv.owners, 1)
+}
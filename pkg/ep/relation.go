@@ -0,0 +1,245 @@
+package ep
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/rebac"
+)
+
+type WritingRelations struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+type CheckingRelation struct {
+	Tuple string `json:"tuple"`
+	Token string `json:"token"`
+}
+
+type ExpandingRelation struct {
+	Object   string
+	Relation string
+	Token    string
+}
+
+type ListingObjects struct {
+	Namespace string
+	Relation  string
+	User      string
+	Token     string
+}
+
+// RelationToken is the consistency token of a write, passing it to later reads makes them see the write
+type RelationToken struct {
+	Token string `json:"token"`
+}
+
+type CheckedRelation struct {
+	Allowed bool   `json:"allowed"`
+	Token   string `json:"token"`
+}
+
+type RelationTree struct {
+	Object   string          `json:"object"`
+	Relation string          `json:"relation"`
+	Users    []string        `json:"users"`
+	Children []*RelationTree `json:"children"`
+}
+
+type ExpandedRelation struct {
+	Tree  *RelationTree `json:"tree"`
+	Token string        `json:"token"`
+}
+
+type ListedObjects struct {
+	Objects []string `json:"objects"`
+	Token   string   `json:"token"`
+}
+
+func toRelationTree(t *rebac.Tree) *RelationTree {
+	children := make([]*RelationTree, 0, len(t.Children))
+	for _, c := range t.Children {
+		children = append(children, toRelationTree(c))
+	}
+
+	return &RelationTree{t.Object, t.Relation, t.Users, children}
+}
+
+func WritingNamespaceEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	rserv := ctx.Value(common.RelationService).(rebac.Service)
+
+	go func() {
+		ns, ok := request.(*rebac.Namespace)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := rserv.WriteNamespace(ns); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func GettingNamespaceEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	nsch := make(chan *rebac.Namespace)
+	rserv := ctx.Value(common.RelationService).(rebac.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		ns, err := rserv.GetNamespace(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		nsch <- ns
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case ns := <-nsch:
+		return ns, nil
+	}
+}
+
+func WritingRelationsEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	tch := make(chan string)
+	rserv := ctx.Value(common.RelationService).(rebac.Service)
+
+	go func() {
+		req, ok := request.(*WritingRelations)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		token, err := rserv.WriteTuples(req.Add, req.Remove)
+		if err != nil {
+			erch <- err
+			return
+		}
+		tch <- token
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case token := <-tch:
+		return &RelationToken{token}, nil
+	}
+}
+
+func CheckingRelationEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	data := make(chan *CheckedRelation)
+	rserv := ctx.Value(common.RelationService).(rebac.Service)
+
+	go func() {
+		req, ok := request.(*CheckingRelation)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		allowed, token, err := rserv.Check(req.Tuple, req.Token)
+		if err != nil {
+			erch <- err
+			return
+		}
+		data <- &CheckedRelation{allowed, token}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case checked := <-data:
+		return checked, nil
+	}
+}
+
+func ExpandingRelationEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	data := make(chan *ExpandedRelation)
+	rserv := ctx.Value(common.RelationService).(rebac.Service)
+
+	go func() {
+		req, ok := request.(*ExpandingRelation)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		tree, token, err := rserv.Expand(req.Object, req.Relation, req.Token)
+		if err != nil {
+			erch <- err
+			return
+		}
+		data <- &ExpandedRelation{toRelationTree(tree), token}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case expanded := <-data:
+		return expanded, nil
+	}
+}
+
+func ListingObjectsEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	data := make(chan *ListedObjects)
+	rserv := ctx.Value(common.RelationService).(rebac.Service)
+
+	go func() {
+		req, ok := request.(*ListingObjects)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		ids, token, err := rserv.ListObjects(req.Namespace, req.Relation, req.User, req.Token)
+		if err != nil {
+			erch <- err
+			return
+		}
+		data <- &ListedObjects{ids, token}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case listed := <-data:
+		return listed, nil
+	}
+}
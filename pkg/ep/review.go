@@ -0,0 +1,287 @@
+package ep
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/reviewmgr"
+	"time"
+)
+
+type ReviewCampaign struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	Desc      string        `json:"desc"`
+	Scope     string        `json:"scope"`
+	Targets   []string      `json:"targets"`
+	Status    string        `json:"status"`
+	CreatedAt time.Time     `json:"created_at"`
+	ClosedAt  *time.Time    `json:"closed_at"`
+	Items     []*ReviewItem `json:"items,omitempty"`
+}
+
+type ReviewItem struct {
+	ID        int64      `json:"id"`
+	Username  string     `json:"username"`
+	Bunch     string     `json:"bunch"`
+	Reviewer  string     `json:"reviewer"`
+	Decision  string     `json:"decision"`
+	Comment   string     `json:"comment"`
+	DecidedAt *time.Time `json:"decided_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ReviewReport is campaign's result, it's exported as json or csv
+type ReviewReport struct {
+	Format   string          `json:"-"`
+	Campaign *ReviewCampaign `json:"campaign"`
+	Items    []*ReviewItem   `json:"items"`
+}
+
+type StartingReview struct {
+	Name      string   `json:"name"`
+	Desc      string   `json:"desc"`
+	Scope     string   `json:"scope"`
+	Targets   []string `json:"targets"`
+	Reviewers []string `json:"reviewers"`
+}
+
+type GettingReview struct {
+	ID       int64
+	Reviewer string
+	Decision string
+}
+
+type DecidingReviewItem struct {
+	CampaignID int64
+	ItemID     int64
+	Decision   string `json:"decision"`
+	Comment    string `json:"comment"`
+}
+
+type GettingReviewReport struct {
+	ID     int64
+	Format string
+}
+
+func StartingReviewEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	cch := make(chan *reviewmgr.Campaign)
+	rserv := ctx.Value(common.ReviewManagementService).(reviewmgr.Service)
+
+	go func() {
+		req, ok := request.(*StartingReview)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		id, err := rserv.StartCampaign(req.Name, req.Desc, req.Scope, req.Targets, req.Reviewers)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		campaign, err := rserv.GetCampaign(id)
+		if err != nil {
+			erch <- err
+			return
+		}
+		cch <- campaign
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case c := <-cch:
+		return toReviewCampaign(c, nil), nil
+	}
+}
+
+func GettingReviewEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	cch := make(chan *reviewmgr.Campaign)
+	rserv := ctx.Value(common.ReviewManagementService).(reviewmgr.Service)
+
+	var items []*reviewmgr.Item
+
+	go func() {
+		req, ok := request.(*GettingReview)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		campaign, err := rserv.GetCampaign(req.ID)
+		if err != nil {
+			erch <- err
+			return
+		}
+		if campaign == nil {
+			erch <- common.ErrCampaignNotFound
+			return
+		}
+
+		items, err = rserv.GetItems(campaign.ID, req.Reviewer, req.Decision)
+		if err != nil {
+			erch <- err
+			return
+		}
+		cch <- campaign
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case c := <-cch:
+		return toReviewCampaign(c, items), nil
+	}
+}
+
+func DecidingReviewItemEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	rserv := ctx.Value(common.ReviewManagementService).(reviewmgr.Service)
+
+	go func() {
+		req, ok := request.(*DecidingReviewItem)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		reviewer, err := callerName(ctx)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		err = rserv.Decide(req.CampaignID, req.ItemID, reviewer, req.Decision, req.Comment)
+		if err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func ClosingReviewEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	rch := make(chan *reviewmgr.Report)
+	rserv := ctx.Value(common.ReviewManagementService).(reviewmgr.Service)
+
+	go func() {
+		id, ok := request.(int64)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		report, err := rserv.CloseCampaign(id)
+		if err != nil {
+			erch <- err
+			return
+		}
+		rch <- report
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case r := <-rch:
+		return toReviewReport(r, ""), nil
+	}
+}
+
+func GettingReviewReportEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	rch := make(chan *reviewmgr.Report)
+	rserv := ctx.Value(common.ReviewManagementService).(reviewmgr.Service)
+	req, ok := request.(*GettingReviewReport)
+
+	go func() {
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		report, err := rserv.GetReport(req.ID)
+		if err != nil {
+			erch <- err
+			return
+		}
+		rch <- report
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case r := <-rch:
+		return toReviewReport(r, req.Format), nil
+	}
+}
+
+func toReviewCampaign(c *reviewmgr.Campaign, items []*reviewmgr.Item) *ReviewCampaign {
+	campaign := &ReviewCampaign{
+		ID:        c.ID,
+		Name:      c.Name,
+		Desc:      c.Desc,
+		Scope:     c.Scope,
+		Targets:   c.Targets,
+		Status:    c.Status,
+		CreatedAt: c.CreatedAt,
+	}
+	if c.ClosedAt.Valid {
+		campaign.ClosedAt = &c.ClosedAt.Time
+	}
+	if items != nil {
+		campaign.Items = toReviewItems(items)
+	}
+
+	return campaign
+}
+
+func toReviewItems(lst []*reviewmgr.Item) []*ReviewItem {
+	rows := make([]*ReviewItem, 0, len(lst))
+	for _, row := range lst {
+		item := &ReviewItem{
+			ID:        row.ID,
+			Username:  row.Username,
+			Bunch:     row.Bunch,
+			Reviewer:  row.Reviewer,
+			Decision:  row.Decision,
+			Comment:   row.Comment,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.DecidedAt.Valid {
+			item.DecidedAt = &row.DecidedAt.Time
+		}
+		rows = append(rows, item)
+	}
+
+	return rows
+}
+
+func toReviewReport(r *reviewmgr.Report, format string) *ReviewReport {
+	return &ReviewReport{
+		format,
+		toReviewCampaign(r.Campaign, nil),
+		toReviewItems(r.Items),
+	}
+}
@@ -0,0 +1,273 @@
+package ep
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"strings"
+)
+
+// grantAnyKey lets callers grant bunches and keys which they don't hold themselves
+const grantAnyKey = "grant_any_key"
+
+// keys of the routes which take denials away, and so give keys back
+const (
+	removeUserDenialsKey  = "remove_user_denials"
+	removeBunchDenialsKey = "remove_bunch_denials"
+)
+
+// Keys of routes may be narrowed to the resources which requests act on, holders of a scoped key may call the
+// route for those resources only:
+//
+//	modify_user:users:staff_role             users whose bunches are all staff_role
+//	add_bunch_to_user:bunches:staff_role     giving staff_role to any user
+//	add_bunch_to_user:held                   giving bunches which the caller holds to any user
+//
+// Requests which give or take bunches are scoped by those bunches only, so that a first bunch can be given to a
+// new user. Other requests made for a user are scoped by the user's bunches, and requests made for a bunch by the
+// bunch, a renamed bunch by both of its names. Requests which act on neither need the route's key itself.
+const (
+	usersScope   = "users"
+	bunchesScope = "bunches"
+	heldScope    = "held"
+)
+
+// resourceBunches is implemented by requests which give bunches to a user or take them away
+type resourceBunches interface {
+	resourceBunches() []string
+}
+
+func (r *AddingBunchesToUser) resourceBunches() []string {
+	return r.Bunches
+}
+
+func (r *RemovingBunchesFromUser) resourceBunches() []string {
+	return r.Bunches
+}
+
+func (r *ModifyingBunchCondition) resourceBunches() []string {
+	return []string{r.Bunch}
+}
+
+// a renamed bunch takes its members into the scopes of its new name, so the new name must be in scope as well
+func (r *ModifyingBunch) resourceBunches() []string {
+	if len(r.Name) == 0 || r.Name == r.Lookup {
+		return []string{r.Lookup}
+	}
+	return []string{r.Lookup, r.Name}
+}
+
+func scopedKey(key string, scope ...string) string {
+	return strings.Join(append([]string{key}, scope...), ":")
+}
+
+// isGrantedInScope reports whether scoped keys of the route's key grant the request
+func isGrantedInScope(ctx context.Context, claims *TokenClaims, key string, request interface{}) (bool, error) {
+	switch r := request.(type) {
+	case resourceBunches:
+		return areBunchesInScope(ctx, claims, key, r.resourceBunches(), request)
+	case resourceUser:
+		return isUserInScope(ctx, claims, key, r.resourceUser(), request)
+	case resourceBunch:
+		return areBunchesInScope(ctx, claims, key, []string{r.resourceBunch()}, request)
+	}
+
+	return false, nil
+}
+
+// isUserInScope reports whether every bunch of the user is in scope, users without bunches are in no scope
+func isUserInScope(ctx context.Context, claims *TokenClaims, key string, username string,
+	request interface{}) (bool, error) {
+
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	held, err := userv.GetBunches(username)
+	if err == common.ErrUserNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(held) == 0 {
+		return false, nil
+	}
+
+	for _, b := range held {
+		granted, err := isGranted(ctx, claims, scopedKey(key, usersScope, b.Name), request)
+		if err != nil || !granted {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// areBunchesInScope reports whether every bunch is in scope, either by name or by being held by the caller.
+// No bunches are in no scope.
+func areBunchesInScope(ctx context.Context, claims *TokenClaims, key string, bunches []string,
+	request interface{}) (bool, error) {
+
+	if len(bunches) == 0 {
+		return false, nil
+	}
+
+	var own map[string]bool
+	for _, name := range bunches {
+		granted, err := isGranted(ctx, claims, scopedKey(key, bunchesScope, name), request)
+		if err != nil {
+			return false, err
+		}
+		if granted {
+			continue
+		}
+
+		if own == nil {
+			granted, err = isGranted(ctx, claims, scopedKey(key, heldScope), request)
+			if err != nil || !granted {
+				return false, err
+			}
+
+			if own, err = callerBunches(ctx, claims); err != nil {
+				return false, err
+			}
+		}
+		if !own[name] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// callerBunches returns names of the bunches which the caller holds, they're read in the caller's tenant
+func callerBunches(ctx context.Context, claims *TokenClaims) (map[string]bool, error) {
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	tenant := claims.Tenant
+	if tenant == 0 {
+		tenant = common.DefaultTenant
+	}
+
+	held, err := userv.WithTenant(tenant).GetBunches(claims.Audience)
+	if err != nil && err != common.ErrUserNotFound {
+		return nil, err
+	}
+
+	own := make(map[string]bool)
+	for _, b := range held {
+		own[b.Name] = true
+	}
+	return own, nil
+}
+
+// checkEscalation refuses requests which would give keys that the caller doesn't hold without condition, unless
+// the caller holds grantAnyKey. Loosening a condition and taking a denial away give keys as well.
+func checkEscalation(ctx context.Context, claims *TokenClaims, key string, request interface{}) error {
+	if hasKey(claims, grantAnyKey) {
+		return nil
+	}
+
+	keys, err := givenKeys(ctx, key, request)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if !hasKey(claims, k) {
+			return common.ErrPrivilegeEscalation
+		}
+	}
+
+	return nil
+}
+
+// givenKeys returns keys which the request of the route gives. Manifests and imported documents give keys which
+// they list for bunches, and keys which the bunches they give to users already hold.
+func givenKeys(ctx context.Context, key string, request interface{}) ([]string, error) {
+	switch r := request.(type) {
+	case *AddingBunchesToUser:
+		return keysOfBunches(ctx, r.Bunches)
+	case *ModifyingBunchCondition:
+		return keysOfBunches(ctx, []string{r.Bunch})
+	case *ImportingUsers:
+		bunches := make([]string, 0)
+		for _, row := range r.Rows {
+			bunches = append(bunches, row.Bunches...)
+		}
+		return keysOfBunches(ctx, bunches)
+	case *AddingKeysToBunch:
+		return r.Keys, nil
+	case *AddingKeyToBunch:
+		return []string{r.Key}, nil
+	case *ModifyingKeyCondition:
+		return []string{r.Key}, nil
+	case *ModifyingKey:
+		// bunches which hold the key hold it by its new name, which is given to their members
+		if len(r.Key) > 0 && r.Key != r.Lookup {
+			return []string{r.Key}, nil
+		}
+	case *ApplyingManifest:
+		if r.Manifest == nil {
+			return nil, nil
+		}
+		keys := make([]string, 0)
+		bunches := make([]string, 0)
+		for _, b := range r.Manifest.Bunches {
+			keys = append(keys, b.Keys...)
+		}
+		for _, u := range r.Manifest.Users {
+			bunches = append(bunches, u.Bunches...)
+		}
+		held, err := keysOfBunches(ctx, bunches)
+		return append(keys, held...), err
+	case *ImportingData:
+		if r.Document == nil {
+			return nil, nil
+		}
+		keys := make([]string, 0)
+		bunches := make([]string, 0)
+		for _, bk := range r.Document.BunchKeys {
+			keys = append(keys, bk.Key)
+		}
+		for _, ub := range r.Document.UserBunches {
+			bunches = append(bunches, ub.Bunch)
+		}
+		held, err := keysOfBunches(ctx, bunches)
+		return append(keys, held...), err
+	case *ChangingUserDenials:
+		if key == removeUserDenialsKey {
+			return r.Keys, nil
+		}
+	case *ChangingBunchDenials:
+		if key == removeBunchDenialsKey {
+			return r.Keys, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// keysOfBunches returns keys which the stored bunches hold, bunches which don't exist yet hold none
+func keysOfBunches(ctx context.Context, bunches []string) ([]string, error) {
+	bserv := ctx.Value(common.BunchManagementService).(bunchmgr.Service)
+
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, name := range bunches {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		lst, err := bserv.GetKeysInBunch(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range lst {
+			keys = append(keys, k.Key)
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,250 @@
+package ep
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/backup"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/manifest"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// users is a user service which only knows bunches and attributes of users
+type users struct {
+	usrmgr.Service
+	bunches map[string][]string
+	attrs   map[string]map[string]string
+}
+
+func (s *users) WithTenant(int64) usrmgr.Service {
+	return s
+}
+
+func (s *users) GetBunches(username string) ([]*usrmgr.Bunch, error) {
+	names, ok := s.bunches[username]
+	if !ok {
+		return nil, common.ErrUserNotFound
+	}
+
+	lst := make([]*usrmgr.Bunch, 0, len(names))
+	for _, name := range names {
+		lst = append(lst, &usrmgr.Bunch{Name: name})
+	}
+	return lst, nil
+}
+
+func (s *users) GetAttributes(username string) (map[string]string, error) {
+	return s.attrs[username], nil
+}
+
+// bunches is a bunch service which only knows keys of bunches
+type bunches struct {
+	bunchmgr.Service
+	keys map[string][]string
+}
+
+func (s *bunches) GetKeysInBunch(name string) ([]*bunchmgr.Key, error) {
+	lst := make([]*bunchmgr.Key, 0)
+	for _, k := range s.keys[name] {
+		lst = append(lst, &bunchmgr.Key{Key: k})
+	}
+	return lst, nil
+}
+
+func scopeContext() context.Context {
+	ctx := context.WithValue(context.Background(), common.UserManagementService, &users{
+		bunches: map[string][]string{
+			"manager": {"staff_role", "sales_role"},
+			"clerk":   {"staff_role"},
+			"seller":  {"staff_role", "sales_role"},
+			"admin":   {"admin_role"},
+			"newbie":  {},
+		},
+		attrs: map[string]map[string]string{
+			"clerk":  {"department": "sales"},
+			"seller": {"department": "it"},
+		},
+	})
+	return context.WithValue(ctx, common.BunchManagementService, &bunches{
+		keys: map[string][]string{
+			"staff_role": {"get_user"},
+			"sales_role": {"get_user", "query_user"},
+			"admin_role": {"modify_user", "add_bunch_to_user"},
+		},
+	})
+}
+
+func TestIsGrantedInScope_Users(t *testing.T) {
+	ctx := scopeContext()
+	claims := &TokenClaims{Keys: []string{"modify_user:users:staff_role"}}
+	claims.Audience = "manager"
+
+	granted, err := isGrantedInScope(ctx, claims, "modify_user", &ModifyingUser{Lookup: "clerk"})
+	require.Nil(t, err)
+	require.True(t, granted)
+
+	// every bunch of the user has to be in scope
+	granted, err = isGrantedInScope(ctx, claims, "modify_user", &ModifyingUser{Lookup: "seller"})
+	require.Nil(t, err)
+	require.False(t, granted)
+
+	// users without bunches and unknown users are in no scope
+	for _, name := range []string{"newbie", "nobody"} {
+		granted, err = isGrantedInScope(ctx, claims, "modify_user", &ModifyingUser{Lookup: name})
+		require.Nil(t, err)
+		require.False(t, granted)
+	}
+
+	// requests for no user or bunch need the route's key
+	granted, err = isGrantedInScope(ctx, claims, "modify_user", &AddingUser{})
+	require.Nil(t, err)
+	require.False(t, granted)
+
+	// scoped keys are denied as any other key
+	claims.Denied = []string{"modify_user:users:*"}
+	granted, err = isGrantedInScope(ctx, claims, "modify_user", &ModifyingUser{Lookup: "clerk"})
+	require.Nil(t, err)
+	require.False(t, granted)
+}
+
+func TestIsGrantedInScope_Conditions(t *testing.T) {
+	ctx := scopeContext()
+	claims := &TokenClaims{Conditions: map[string][]string{
+		"modify_user:users:staff_role": {`resource.department == "sales"`},
+	}}
+	claims.Audience = "manager"
+
+	granted, err := isGrantedInScope(ctx, claims, "modify_user", &ModifyingUser{Lookup: "clerk"})
+	require.Nil(t, err)
+	require.True(t, granted)
+
+	granted, err = isGrantedInScope(ctx, claims, "modify_user", &ModifyingUser{Lookup: "seller"})
+	require.Nil(t, err)
+	require.False(t, granted)
+}
+
+func TestAreBunchesInScope(t *testing.T) {
+	ctx := scopeContext()
+
+	// bunches are in scope by name, whatever bunches the user holds
+	claims := &TokenClaims{Keys: []string{"add_bunch_to_user:bunches:staff_role"}}
+	claims.Audience = "manager"
+
+	granted, err := isGrantedInScope(ctx, claims, "add_bunch_to_user",
+		&AddingBunchesToUser{Username: "newbie", Bunches: []string{"staff_role"}})
+	require.Nil(t, err)
+	require.True(t, granted)
+
+	granted, err = isGrantedInScope(ctx, claims, "add_bunch_to_user",
+		&AddingBunchesToUser{Username: "newbie", Bunches: []string{"staff_role", "sales_role"}})
+	require.Nil(t, err)
+	require.False(t, granted)
+
+	// or by being held by the caller
+	claims = &TokenClaims{Keys: []string{"add_bunch_to_user:held"}}
+	claims.Audience = "manager"
+
+	granted, err = areBunchesInScope(ctx, claims, "add_bunch_to_user", []string{"staff_role", "sales_role"}, nil)
+	require.Nil(t, err)
+	require.True(t, granted)
+
+	granted, err = areBunchesInScope(ctx, claims, "add_bunch_to_user", []string{"staff_role", "admin_role"}, nil)
+	require.Nil(t, err)
+	require.False(t, granted)
+
+	granted, err = areBunchesInScope(ctx, claims, "add_bunch_to_user", nil, nil)
+	require.Nil(t, err)
+	require.False(t, granted)
+
+	// bunches held by the caller aren't in scope without the held key
+	claims = &TokenClaims{Keys: []string{"add_bunch_to_user:bunches:admin_role"}}
+	claims.Audience = "manager"
+
+	granted, err = areBunchesInScope(ctx, claims, "add_bunch_to_user", []string{"staff_role"}, nil)
+	require.Nil(t, err)
+	require.False(t, granted)
+}
+
+func TestIsGrantedInScope_RenamedBunch(t *testing.T) {
+	ctx := scopeContext()
+	claims := &TokenClaims{Keys: []string{"modify_bunch:bunches:sales_role"}}
+	claims.Audience = "manager"
+
+	granted, err := isGrantedInScope(ctx, claims, "modify_bunch", &ModifyingBunch{Lookup: "sales_role", Desc: "Sales"})
+	require.Nil(t, err)
+	require.True(t, granted)
+
+	// a bunch renamed into another scope takes its members there, the new name must be in scope too
+	granted, err = isGrantedInScope(ctx, claims, "modify_bunch", &ModifyingBunch{Lookup: "sales_role",
+		Name: "staff_role"})
+	require.Nil(t, err)
+	require.False(t, granted)
+}
+
+func TestCheckEscalation(t *testing.T) {
+	ctx := scopeContext()
+	claims := &TokenClaims{
+		Keys:       []string{"add_bunch_to_user", "get_user", "query_user"},
+		Conditions: map[string][]string{"modify_user": {`ip == "10.0.0.1"`}},
+	}
+
+	require.Nil(t, checkEscalation(ctx, claims, "add_bunch_to_user",
+		&AddingBunchesToUser{Username: "newbie", Bunches: []string{"staff_role", "sales_role"}}))
+	require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, "add_bunch_to_user",
+		&AddingBunchesToUser{Username: "newbie", Bunches: []string{"admin_role"}}))
+
+	// keys held with conditions can't be given
+	require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, "add_keys_to_bunch",
+		&AddingKeysToBunch{Bunch: "staff_role", Keys: []string{"modify_user"}}))
+
+	// nor keys which are denied
+	claims.Denied = []string{"query_user"}
+	require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, "add_bunch_to_user",
+		&AddingBunchesToUser{Username: "newbie", Bunches: []string{"sales_role"}}))
+
+	// taking a denial away gives the key back, adding one doesn't
+	denial := &ChangingUserDenials{Username: "clerk", Keys: []string{"modify_user"}}
+	require.Nil(t, checkEscalation(ctx, claims, "add_user_denials", denial))
+	require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, removeUserDenialsKey, denial))
+	require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, removeBunchDenialsKey,
+		&ChangingBunchDenials{Bunch: "staff_role", Keys: []string{"modify_user"}}))
+
+	// manifests and imports give keys of their bunches
+	require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, "apply_manifest",
+		&ApplyingManifest{Manifest: &manifest.Manifest{Users: []*manifest.User{
+			{Username: "clerk", Bunches: []string{"admin_role"}},
+		}}}))
+	require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, "apply_manifest",
+		&ApplyingManifest{Manifest: &manifest.Manifest{Bunches: []*manifest.Bunch{
+			{Name: "staff_role", Keys: []string{"modify_user"}},
+		}}}))
+	require.Nil(t, checkEscalation(ctx, claims, "apply_manifest",
+		&ApplyingManifest{Manifest: &manifest.Manifest{Users: []*manifest.User{
+			{Username: "clerk", Bunches: []string{"staff_role"}},
+		}}}))
+	require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, "import_data",
+		&ImportingData{Document: &backup.Document{UserBunches: []*backup.UserBunch{
+			{Username: "clerk", Bunch: "admin_role"},
+		}}}))
+	require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, "import_data",
+		&ImportingData{Document: &backup.Document{BunchKeys: []*backup.BunchKey{
+			{Bunch: "staff_role", Key: "*"},
+		}}}))
+
+	// renaming a key gives the new name to holders of the key
+	require.Nil(t, checkEscalation(ctx, claims, "modify_key", &ModifyingKey{Lookup: "get_user", Desc: "Get"}))
+	require.Nil(t, checkEscalation(ctx, claims, "modify_key", &ModifyingKey{Lookup: "users:get", Key: "get_user"}))
+	for _, name := range []string{grantAnyKey, "modify_tenant", "add_bunch_to_user:bunches:admin_role"} {
+		require.Equal(t, common.ErrPrivilegeEscalation, checkEscalation(ctx, claims, "modify_key",
+			&ModifyingKey{Lookup: "get_user", Key: name}))
+	}
+
+	// unless the caller may grant any key
+	claims.Keys = append(claims.Keys, grantAnyKey)
+	require.Nil(t, checkEscalation(ctx, claims, removeUserDenialsKey, denial))
+	require.Nil(t, checkEscalation(ctx, claims, "add_bunch_to_user",
+		&AddingBunchesToUser{Username: "newbie", Bunches: []string{"admin_role"}}))
+}
@@ -0,0 +1,288 @@
+package ep
+
+import (
+	"context"
+	"database/sql"
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// holders of this key manage every service account, others only manage the ones they own
+const serviceAccountAdminKey = "modify_service_account"
+
+type AddingServiceAccount struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	Desc  string `json:"desc"`
+}
+
+type ModifyingServiceAccount struct {
+	Lookup string
+	Owner  string `json:"owner"`
+	Desc   string `json:"desc"`
+}
+
+type AddingServiceAccountToken struct {
+	Account string
+	AddingPersonalToken
+}
+
+type RevokingServiceAccountToken struct {
+	Account string
+	Name    string
+}
+
+func AddingServiceAccountEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	uch := make(chan *usrmgr.User)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+	appConfig := ctx.Value(common.AppConfigContextKey).(*cf.AppConfig)
+
+	go func() {
+		req, ok := request.(*AddingServiceAccount)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		// caller owns the account unless someone else is named
+		owner := req.Owner
+		if len(owner) == 0 {
+			caller, err := callerName(ctx)
+			if err != nil {
+				erch <- err
+				return
+			}
+			owner = caller
+		}
+
+		// service accounts can't login, they're given a hash which no password matches
+		hash, err := usrmgr.HashPassword("", appConfig.BcryptCost)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		id, err := userv.AddServiceAccount(req.Name, owner, req.Desc, hash)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		u, err := userv.GetUser(id)
+		if err != nil {
+			erch <- err
+			return
+		}
+		uch <- u
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case u := <-uch:
+		return &User{
+			u.ID,
+			u.Username,
+			u.Email,
+			u.Active.Bool,
+			u.Type,
+			u.Owner,
+			u.Desc,
+			u.CreatedAt,
+			u.UpdatedAt,
+			u.Locale,
+		}, nil
+	}
+}
+
+func ModifyingServiceAccountEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	go func() {
+		req, ok := request.(*ModifyingServiceAccount)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		err := userv.ModifyServiceAccount(req.Lookup, req.Owner, req.Desc)
+		if err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func AddingServiceAccountTokenEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	tch := make(chan *PersonalToken)
+	tserv := ctx.Value(common.TokenManagementService).(tokenmgr.Service)
+
+	go func() {
+		req, ok := request.(*AddingServiceAccountToken)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := checkServiceAccountManager(ctx, req.Account); err != nil {
+			erch <- err
+			return
+		}
+
+		// the same as personal tokens, caller can't hand out keys which its own token doesn't carry
+		claims := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims)
+		for _, k := range req.Keys {
+			if !carriesKey(claims, k) {
+				erch <- common.ErrNotAllowed
+				return
+			}
+		}
+
+		var expiresAt sql.NullTime
+		if req.ExpiresAt != nil {
+			expiresAt = sql.NullTime{Time: *req.ExpiresAt, Valid: true}
+		}
+		expiresAt = childExpiry(claims, expiresAt)
+
+		token, plain, err := tserv.CreateToken(req.Account, req.Name, req.Keys, expiresAt)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		result := toPersonalToken(token)
+		result.Token = plain
+		tch <- result
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case t := <-tch:
+		return t, nil
+	}
+}
+
+func QueryingServiceAccountTokenEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	tch := make(chan []*tokenmgr.Token)
+	tserv := ctx.Value(common.TokenManagementService).(tokenmgr.Service)
+
+	go func() {
+		account, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := checkServiceAccountManager(ctx, account); err != nil {
+			erch <- err
+			return
+		}
+
+		tokens, err := tserv.GetTokens(account)
+		if err != nil {
+			erch <- err
+			return
+		}
+		tch <- tokens
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case tokens := <-tch:
+		results := make([]*PersonalToken, 0, len(tokens))
+		for _, t := range tokens {
+			results = append(results, toPersonalToken(t))
+		}
+		return results, nil
+	}
+}
+
+func RevokingServiceAccountTokenEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	dch := make(chan bool)
+	tserv := ctx.Value(common.TokenManagementService).(tokenmgr.Service)
+
+	go func() {
+		req, ok := request.(*RevokingServiceAccountToken)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := checkServiceAccountManager(ctx, req.Account); err != nil {
+			erch <- err
+			return
+		}
+
+		err := tserv.RevokeToken(req.Account, req.Name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		dch <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-dch:
+		return nil, nil
+	}
+}
+
+// checkServiceAccountManager allows the owner of the service account and holders of serviceAccountAdminKey
+func checkServiceAccountManager(ctx context.Context, account string) error {
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	claims, ok := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims)
+	if !ok {
+		return common.ErrMissingJWTToken
+	}
+
+	u, err := userv.GetUserByUsername(account)
+	if err != nil {
+		return err
+	}
+	if u == nil || !u.IsServiceAccount() {
+		return common.ErrServiceAccountNotFound
+	}
+
+	// owners are told by their names in the request's tenant, a caller who has switched to another tenant isn't
+	// the owner of its accounts
+	if name, err := callerName(ctx); err == nil && u.Owner == name {
+		return nil
+	}
+	if !hasKey(claims, serviceAccountAdminKey) {
+		return common.ErrNotAllowed
+	}
+
+	return nil
+}
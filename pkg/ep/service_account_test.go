@@ -0,0 +1,46 @@
+package ep
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// accounts is a user service which knows a single service account, owned by admin
+type accounts struct {
+	usrmgr.Service
+}
+
+func (s *accounts) GetUserByUsername(username string) (*usrmgr.User, error) {
+	if username != "ci_bot" {
+		return nil, nil
+	}
+	return &usrmgr.User{ID: 2, Username: username, Type: usrmgr.TypeService, Owner: "admin"}, nil
+}
+
+func (s *accounts) WithTenant(int64) usrmgr.Service {
+	return s
+}
+
+func TestCheckServiceAccountManager(t *testing.T) {
+	claims := &TokenClaims{}
+	claims.Audience = "admin"
+
+	ctx := context.WithValue(context.Background(), common.UserManagementService, &accounts{})
+	ctx = context.WithValue(ctx, jwt.JWTClaimsContextKey, claims)
+
+	require.Nil(t, checkServiceAccountManager(withTenant(ctx, common.DefaultTenant), "ci_bot"))
+	require.Equal(t, common.ErrServiceAccountNotFound,
+		checkServiceAccountManager(withTenant(ctx, common.DefaultTenant), "nobody"))
+
+	// an owner of the same name in another tenant is someone else
+	require.Equal(t, common.ErrNotAllowed, checkServiceAccountManager(withTenant(ctx, 2), "ci_bot"))
+
+	// holders of the admin key manage every account
+	claims.Keys = []string{serviceAccountAdminKey}
+	require.Nil(t, checkServiceAccountManager(withTenant(ctx, 2), "ci_bot"))
+}
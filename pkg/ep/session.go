@@ -0,0 +1,155 @@
+package ep
+
+import (
+	"context"
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/sessionmgr"
+	"time"
+)
+
+type Session struct {
+	ID            string    `json:"id"`
+	RemoteAddr    string    `json:"remote_addr"`
+	XForwardedFor string    `json:"x_forwarded_for,omitempty"`
+	XRealIP       string    `json:"x_real_ip,omitempty"`
+	UserAgent     string    `json:"user_agent"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiredAt     time.Time `json:"expired_at"`
+
+	// Current tells the session whose token the request is made with
+	Current bool `json:"current"`
+}
+
+// TerminatingSession terminates one session of the user, or all of them when ID is empty
+type TerminatingSession struct {
+	Username string
+	ID       string
+}
+
+func (r *TerminatingSession) resourceUser() string {
+	return r.Username
+}
+
+// sessionMetadata gathers where the request which a token is issued for comes from
+func sessionMetadata(ctx context.Context) *sessionmgr.Metadata {
+	meta := new(sessionmgr.Metadata)
+	meta.RemoteAddr, _ = ctx.Value(common.RemoteAddrContextKey).(string)
+	meta.XForwardedFor, _ = ctx.Value(common.ForwardedForContextKey).(string)
+	meta.XRealIP, _ = ctx.Value(common.RealIPContextKey).(string)
+	meta.UserAgent, _ = ctx.Value(common.UserAgentContextKey).(string)
+
+	return meta
+}
+
+func QueryingOwnSessionEndpoint(ctx context.Context, _ interface{}) (interface{}, error) {
+	name, err := callerName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return querySessions(ctx, name)
+}
+
+func QueryingUserSessionEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	name, ok := request.(string)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return querySessions(ctx, name)
+}
+
+func querySessions(ctx context.Context, username string) (interface{}, error) {
+	erch := make(chan error)
+	sch := make(chan []*sessionmgr.Session)
+	sserv := ctx.Value(common.SessionService).(sessionmgr.Service)
+
+	var current string
+	if claims, ok := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims); ok {
+		current = claims.Id
+	}
+
+	go func() {
+		sessions, err := sserv.GetSessions(username)
+		if err != nil {
+			erch <- err
+			return
+		}
+		sch <- sessions
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case sessions := <-sch:
+		results := make([]*Session, 0, len(sessions))
+		for _, s := range sessions {
+			results = append(results, &Session{
+				s.UID,
+				s.RemoteAddr,
+				s.XForwardedFor,
+				s.XRealIP,
+				s.UserAgent,
+				s.CreatedAt,
+				s.ExpiredAt,
+				s.UID == current,
+			})
+		}
+		return results, nil
+	}
+}
+
+func TerminatingOwnSessionEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*TerminatingSession)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+	name, err := callerName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.Username = name
+
+	return terminateSessions(ctx, req)
+}
+
+func TerminatingUserSessionEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*TerminatingSession)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return terminateSessions(ctx, req)
+}
+
+func terminateSessions(ctx context.Context, req *TerminatingSession) (interface{}, error) {
+	erch := make(chan error)
+	dch := make(chan bool)
+	sserv := ctx.Value(common.SessionService).(sessionmgr.Service)
+
+	go func() {
+		var err error
+		if len(req.ID) > 0 {
+			err = sserv.Terminate(req.Username, req.ID)
+		} else {
+			err = sserv.TerminateAll(req.Username)
+		}
+		if err != nil {
+			erch <- err
+			return
+		}
+		dch <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-dch:
+		return nil, nil
+	}
+}
@@ -0,0 +1,371 @@
+package ep
+
+import (
+	"context"
+	"database/sql"
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/authn"
+	"github.com/vespaiach/auth/pkg/backup"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymgr"
+	"github.com/vespaiach/auth/pkg/manifest"
+	"github.com/vespaiach/auth/pkg/rebac"
+	"github.com/vespaiach/auth/pkg/reviewmgr"
+	"github.com/vespaiach/auth/pkg/sessionmgr"
+	"github.com/vespaiach/auth/pkg/sso"
+	"github.com/vespaiach/auth/pkg/tenantmgr"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"time"
+)
+
+// callers of the default tenant holding this key may act in other tenants through the tenant header
+const tenantSwitchKey = "modify_tenant"
+
+type Tenant struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Desc      string    `json:"desc"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Tenants struct {
+	Records []*Tenant `json:"records"`
+	Total   int64     `json:"total"`
+	Page    int64     `json:"page"`
+	PerPage int64     `json:"per_page"`
+}
+
+type AddingTenant struct {
+	Name  string      `json:"name"`
+	Desc  string      `json:"desc"`
+	Admin *AddingUser `json:"admin"`
+}
+
+type ModifyingTenant struct {
+	Lookup string
+	Name   string `json:"name"`
+	Desc   string `json:"desc"`
+	Active *bool  `json:"active"`
+}
+
+type QueryingTenant struct {
+	Name    string
+	Sort    string
+	Page    int64
+	PerPage int64
+}
+
+// TenantMiddleware resolves the tenant of the request and scopes every service in the context to it.
+// The tenant comes from token's claims, the tenant header may pick another one for callers of the
+// default tenant. Requests without a token are made for the tenant named by the header, or the default one.
+func TenantMiddleware(ep endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		tserv := ctx.Value(common.TenantManagementService).(tenantmgr.Service)
+		header, _ := ctx.Value(common.TenantHeaderContextKey).(string)
+
+		var (
+			tenant *tenantmgr.Tenant
+			err    error
+		)
+
+		claims, authenticated := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims)
+		callerTenant := common.DefaultTenant
+		if authenticated && claims.Tenant != 0 {
+			callerTenant = claims.Tenant
+		}
+
+		if len(header) > 0 {
+			tenant, err = tserv.GetTenantByName(header)
+		} else {
+			tenant, err = tserv.GetTenant(callerTenant)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tenant == nil {
+			return nil, common.ErrTenantNotFound
+		}
+
+		if authenticated && tenant.ID != callerTenant &&
+			(callerTenant != common.DefaultTenant || !hasKey(claims, tenantSwitchKey)) {
+			return nil, common.ErrNotAllowed
+		}
+
+		if !tenant.Active {
+			return nil, common.ErrTenantInactive
+		}
+
+		return ep(withTenant(ctx, tenant.ID), request)
+	}
+}
+
+// withTenant scopes services in the context to the tenant
+func withTenant(ctx context.Context, tenantID int64) context.Context {
+	ctx = context.WithValue(ctx, common.TenantContextKey, tenantID)
+
+	if s, ok := ctx.Value(common.UserManagementService).(usrmgr.Service); ok {
+		ctx = context.WithValue(ctx, common.UserManagementService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.BunchManagementService).(bunchmgr.Service); ok {
+		ctx = context.WithValue(ctx, common.BunchManagementService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.KeyManagementService).(keymgr.Service); ok {
+		ctx = context.WithValue(ctx, common.KeyManagementService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.ReviewManagementService).(reviewmgr.Service); ok {
+		ctx = context.WithValue(ctx, common.ReviewManagementService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.ManifestService).(manifest.Service); ok {
+		ctx = context.WithValue(ctx, common.ManifestService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.BackupService).(backup.Service); ok {
+		ctx = context.WithValue(ctx, common.BackupService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.AuthenticatorContextKey).(authn.Authenticator); ok {
+		ctx = context.WithValue(ctx, common.AuthenticatorContextKey, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.FederationService).(sso.Service); ok {
+		ctx = context.WithValue(ctx, common.FederationService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.TokenManagementService).(tokenmgr.Service); ok {
+		ctx = context.WithValue(ctx, common.TokenManagementService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.RelationService).(rebac.Service); ok {
+		ctx = context.WithValue(ctx, common.RelationService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.AuditService).(audit.Service); ok {
+		ctx = context.WithValue(ctx, common.AuditService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.SessionService).(sessionmgr.Service); ok {
+		ctx = context.WithValue(ctx, common.SessionService, s.WithTenant(tenantID))
+	}
+	if s, ok := ctx.Value(common.WebhookService).(webhook.Service); ok {
+		ctx = context.WithValue(ctx, common.WebhookService, s.WithTenant(tenantID))
+	}
+
+	return ctx
+}
+
+// checkRootCaller allows only callers of the default tenant, keys of the same names may exist in other tenants
+func checkRootCaller(ctx context.Context) error {
+	claims, ok := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims)
+	if !ok {
+		return common.ErrMissingJWTToken
+	}
+
+	if claims.Tenant != 0 && claims.Tenant != common.DefaultTenant {
+		return common.ErrNotAllowed
+	}
+
+	return nil
+}
+
+func AddingTenantEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	tch := make(chan *tenantmgr.Tenant)
+	tserv := ctx.Value(common.TenantManagementService).(tenantmgr.Service)
+	appConfig := ctx.Value(common.AppConfigContextKey).(*cf.AppConfig)
+
+	go func() {
+		req, ok := request.(*AddingTenant)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := checkRootCaller(ctx); err != nil {
+			erch <- err
+			return
+		}
+
+		// the first admin of the tenant is created with it and gets its admin bunch
+		var admin *tenantmgr.Admin
+		if req.Admin != nil {
+			if len(req.Admin.Password) == 0 {
+				erch <- common.ErrPasswordMissing
+				return
+			}
+
+			hash, err := usrmgr.HashPassword(req.Admin.Password, appConfig.BcryptCost)
+			if err != nil {
+				erch <- err
+				return
+			}
+			admin = &tenantmgr.Admin{Username: req.Admin.Username, Email: req.Admin.Email, Hash: hash}
+		}
+
+		id, err := tserv.AddTenant(req.Name, req.Desc, admin)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		t, err := tserv.GetTenant(id)
+		if err != nil {
+			erch <- err
+			return
+		}
+		tch <- t
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case t := <-tch:
+		return toTenant(t), nil
+	}
+}
+
+func ModifyingTenantEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	tserv := ctx.Value(common.TenantManagementService).(tenantmgr.Service)
+
+	go func() {
+		req, ok := request.(*ModifyingTenant)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := checkRootCaller(ctx); err != nil {
+			erch <- err
+			return
+		}
+
+		var active sql.NullBool
+		if req.Active != nil {
+			active = sql.NullBool{Bool: *req.Active, Valid: true}
+		}
+
+		if err := tserv.ModifyTenant(req.Lookup, req.Name, req.Desc, active); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func GettingTenantEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	tch := make(chan *tenantmgr.Tenant)
+	tserv := ctx.Value(common.TenantManagementService).(tenantmgr.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := checkRootCaller(ctx); err != nil {
+			erch <- err
+			return
+		}
+
+		t, err := tserv.GetTenantByName(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		if t == nil {
+			erch <- common.ErrTenantNotFound
+			return
+		}
+		tch <- t
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case t := <-tch:
+		return toTenant(t), nil
+	}
+}
+
+func QueryingTenantEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	tch := make(chan []*tenantmgr.Tenant)
+	tserv := ctx.Value(common.TenantManagementService).(tenantmgr.Service)
+	params, ok := request.(*QueryingTenant)
+
+	var total int64
+
+	go func() {
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := checkRootCaller(ctx); err != nil {
+			erch <- err
+			return
+		}
+
+		if params.PerPage == 0 {
+			params.PerPage = common.Take
+		}
+
+		if params.Page == 0 {
+			params.Page = 1
+		}
+
+		records, count, err := tserv.QueryTenants(params.Page, params.PerPage, params.Name, params.Sort)
+		if err != nil {
+			erch <- err
+			return
+		}
+		total = count
+		tch <- records
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-tch:
+		rows := make([]*Tenant, 0, len(lst))
+		for _, row := range lst {
+			rows = append(rows, toTenant(row))
+		}
+		return &Tenants{
+			rows,
+			total,
+			params.Page,
+			params.PerPage,
+		}, nil
+	}
+}
+
+func toTenant(t *tenantmgr.Tenant) *Tenant {
+	return &Tenant{
+		t.ID,
+		t.Name,
+		t.Desc,
+		t.Active,
+		t.CreatedAt,
+		t.UpdatedAt,
+	}
+}
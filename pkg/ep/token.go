@@ -5,11 +5,16 @@ import (
 	"github.com/go-kit/kit/auth/jwt"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/google/uuid"
+	"github.com/vespaiach/auth/pkg/authn"
 	"github.com/vespaiach/auth/pkg/cf"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymatch"
+	"github.com/vespaiach/auth/pkg/keymgr"
+	"github.com/vespaiach/auth/pkg/sessionmgr"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
 	"github.com/vespaiach/auth/pkg/usrmgr"
-	"golang.org/x/crypto/bcrypt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,6 +26,22 @@ type TokenClaims struct {
 	jwtgo.StandardClaims
 	Bunches []string `json:"bunches"`
 	Keys    []string `json:"keys"`
+	Tenant  int64    `json:"tenant"`
+
+	// Application is the audience application, the token only carries that application's keys
+	Application string `json:"application,omitempty"`
+
+	// Conditions holds keys granted with conditions, they're granted when one of their conditions holds
+	Conditions map[string][]string `json:"conditions,omitempty"`
+
+	// Denied holds keys denied to the user, they override any of the keys above
+	Denied []string `json:"denied,omitempty"`
+
+	// Locale is the language which the user prefers messages in
+	Locale string `json:"locale,omitempty"`
+
+	// Personal tells that the claims are of a personal access token, it's never signed into a jwt token
+	Personal bool `json:"-"`
 }
 
 type Token struct {
@@ -30,45 +51,107 @@ type Token struct {
 type VerifyingUser struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Audience string `json:"audience"`
 }
 
 // TokenParser will read and parse jwt token from context
 func TokenParserMiddleware(ep endpoint.Endpoint) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		appConfig := ctx.Value(common.AppConfigContextKey).(*cf.AppConfig)
-
 		tokenStr, ok := ctx.Value(jwt.JWTTokenContextKey).(string)
 		if !ok {
 			return nil, common.ErrMissingJWTToken
 		}
 
-		token, err := jwtgo.ParseWithClaims(tokenStr, &TokenClaims{}, func(token *jwtgo.Token) (interface{}, error) {
-			if token.Method != jwtgo.SigningMethodHS256 {
-				return nil, common.ErrWrongJWTToken
-			}
+		claims, err := parseToken(ctx, tokenStr)
+		if err != nil {
+			return nil, err
+		}
+
+		return ep(context.WithValue(ctx, jwt.JWTClaimsContextKey, claims), request)
+	}
+}
+
+// parseToken checks the token and returns its claims. It's run for every request, and again while a stream is
+// open, so that revoked tokens stop being served.
+func parseToken(ctx context.Context, tokenStr string) (*TokenClaims, error) {
+	appConfig := ctx.Value(common.AppConfigContextKey).(*cf.AppConfig)
 
-			return []byte(appConfig.SigningText), nil
-		})
+	// personal access tokens are turned into the same claims as jwt tokens, with the token's keys
+	if strings.HasPrefix(tokenStr, tokenmgr.Prefix) {
+		tserv := ctx.Value(common.TokenManagementService).(tokenmgr.Service)
 
+		pat, err := tserv.Authenticate(tokenStr)
 		if err != nil {
 			return nil, err
 		}
 
-		if !token.Valid {
+		claims := &TokenClaims{StandardClaims: jwtgo.StandardClaims{Audience: pat.Username}, Keys: pat.Keys,
+			Tenant: pat.TenantID, Conditions: pat.Conditions, Denied: pat.Denied, Personal: true}
+		if pat.ExpiresAt.Valid {
+			claims.ExpiresAt = pat.ExpiresAt.Time.Unix()
+		}
+		return claims, nil
+	}
+
+	token, err := jwtgo.ParseWithClaims(tokenStr, &TokenClaims{}, func(token *jwtgo.Token) (interface{}, error) {
+		if token.Method != jwtgo.SigningMethodHS256 {
 			return nil, common.ErrWrongJWTToken
 		}
 
-		ctx = context.WithValue(ctx, jwt.JWTClaimsContextKey, token.Claims)
+		return []byte(appConfig.SigningText), nil
+	})
 
-		return ep(ctx, request)
+	if err != nil {
+		return nil, err
 	}
+
+	if !token.Valid {
+		return nil, common.ErrWrongJWTToken
+	}
+
+	claims := token.Claims.(*TokenClaims)
+
+	// tokens are refused once their sessions are terminated
+	if sserv, ok := ctx.Value(common.SessionService).(sessionmgr.Service); ok {
+		tenant := claims.Tenant
+		if tenant == 0 {
+			tenant = common.DefaultTenant
+		}
+
+		active, err := sserv.WithTenant(tenant).IsActive(claims.Id)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, common.ErrSessionTerminated
+		}
+	}
+
+	return claims, nil
+}
+
+// TokenLocale reads the locale claim of the bearer token without verifying the token, as the claim only picks
+// the language of messages and is trusted for nothing else. Tokens are verified by the endpoints.
+func TokenLocale(ctx context.Context) string {
+	tokenStr, ok := ctx.Value(jwt.JWTTokenContextKey).(string)
+	if !ok {
+		return ""
+	}
+
+	claims := new(TokenClaims)
+	if _, _, err := new(jwtgo.Parser).ParseUnverified(tokenStr, claims); err != nil {
+		return ""
+	}
+	return claims.Locale
 }
 
 func VerifyingUserMiddleware(ep endpoint.Endpoint) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		erch := make(chan error)
 		uch := make(chan *usrmgr.User)
-		userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+		auth := ctx.Value(common.AuthenticatorContextKey).(authn.Authenticator)
+
+		var audience string
 
 		go func() {
 			req, ok := request.(*VerifyingUser)
@@ -76,21 +159,13 @@ func VerifyingUserMiddleware(ep endpoint.Endpoint) endpoint.Endpoint {
 				erch <- common.ErrWrongInputDatatype
 				return
 			}
+			audience = req.Audience
 
-			user, err := userv.GetUserByUsername(req.Username)
+			user, err := auth.Authenticate(req.Username, req.Password)
 			if err != nil {
 				erch <- err
 				return
 			}
-			if user == nil {
-				erch <- common.ErrUserNotFound
-				return
-			}
-
-			if bcrypt.CompareHashAndPassword([]byte(user.Hash), []byte(req.Password)) != nil {
-				erch <- common.ErrWrongCredentials
-				return
-			}
 
 			uch <- user
 		}()
@@ -101,12 +176,13 @@ func VerifyingUserMiddleware(ep endpoint.Endpoint) endpoint.Endpoint {
 		case e := <-erch:
 			return nil, e
 		case u := <-uch:
-			return ep(ctx, u)
+			return ep(context.WithValue(ctx, common.AudienceContextKey, audience), u)
 		}
 	}
 }
 
-// KeyCheckerMiddleware is for checking key
+// KeyCheckerMiddleware is for checking key, the key itself or its scoped keys grant the request. Requests which
+// give keys are refused when the caller doesn't hold them.
 func KeyCheckerMiddleware(key string) endpoint.Middleware {
 	return func(ep endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
@@ -115,10 +191,21 @@ func KeyCheckerMiddleware(key string) endpoint.Middleware {
 				return nil, common.ErrMissingJWTToken
 			}
 
-			if contains(claims.Keys, key) {
-				return ep(ctx, request)
+			granted, err := isGranted(ctx, claims, key, request)
+			if err == nil && !granted {
+				granted, err = isGrantedInScope(ctx, claims, key, request)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !granted {
+				return nil, common.ErrNotAllowed
 			}
-			return nil, common.ErrNotAllowed
+
+			if err := checkEscalation(ctx, claims, key, request); err != nil {
+				return nil, err
+			}
+			return ep(ctx, request)
 		}
 	}
 }
@@ -127,7 +214,9 @@ func IssueTokenEndpoint(ctx context.Context, request interface{}) (interface{},
 	erch := make(chan error)
 	ach := make(chan string)
 	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+	keyserv := ctx.Value(common.KeyManagementService).(keymgr.Service)
 	appConfig := ctx.Value(common.AppConfigContextKey).(*cf.AppConfig)
+	audience, _ := ctx.Value(common.AudienceContextKey).(string)
 
 	duration, err := time.ParseDuration(appConfig.AccessTokenDuration)
 	if err != nil {
@@ -139,13 +228,28 @@ func IssueTokenEndpoint(ctx context.Context, request interface{}) (interface{},
 		return nil, common.ErrWrongInputDatatype
 	}
 
+	// bunches and keys are read in the user's own tenant
+	userv = userv.WithTenant(user.TenantID)
+
+	if len(audience) > 0 {
+		app, err := keyserv.WithTenant(user.TenantID).GetApplication(audience)
+		if err != nil {
+			return nil, err
+		}
+		if app == nil {
+			return nil, common.ErrApplicationNotFound
+		}
+	}
+
 	go func() {
 		var (
-			wg          sync.WaitGroup
-			bunches     []*usrmgr.Bunch
-			keys        []*usrmgr.Key
-			errGetBunch error
-			errGetKey   error
+			wg           sync.WaitGroup
+			bunches      []*usrmgr.Bunch
+			keys         []*usrmgr.Key
+			denials      []*usrmgr.Denial
+			errGetBunch  error
+			errGetKey    error
+			errGetDenial error
 		)
 
 		wg.Add(1)
@@ -160,6 +264,12 @@ func IssueTokenEndpoint(ctx context.Context, request interface{}) (interface{},
 			keys, errGetKey = userv.GetKeys(user.Username)
 		}()
 
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			denials, errGetDenial = userv.GetDenials(user.Username)
+		}()
+
 		wg.Wait()
 
 		if errGetBunch != nil {
@@ -172,12 +282,28 @@ func IssueTokenEndpoint(ctx context.Context, request interface{}) (interface{},
 			return
 		}
 
-		tokenObj := createToken(user, bunches, keys, duration)
+		if errGetDenial != nil {
+			erch <- errGetDenial
+			return
+		}
+
+		tokenObj := createToken(user, bunches, keys, denials, duration, audience)
 		accessToken, err := tokenObj.SignedString([]byte(appConfig.SigningText))
 		if err != nil {
 			erch <- err
 			return
 		}
+
+		if sserv, ok := ctx.Value(common.SessionService).(sessionmgr.Service); ok {
+			claims := tokenObj.Claims.(TokenClaims)
+			err := sserv.WithTenant(user.TenantID).Record(user, claims.Id, accessToken,
+				time.Unix(claims.ExpiresAt, 0), sessionMetadata(ctx))
+			if err != nil {
+				erch <- err
+				return
+			}
+		}
+
 		ach <- accessToken
 	}()
 
@@ -191,14 +317,35 @@ func IssueTokenEndpoint(ctx context.Context, request interface{}) (interface{},
 	}
 }
 
-func createToken(user *usrmgr.User, bunches []*usrmgr.Bunch, keys []*usrmgr.Key, duration time.Duration) *jwtgo.Token {
+func createToken(user *usrmgr.User, bunches []*usrmgr.Bunch, keys []*usrmgr.Key, denials []*usrmgr.Denial,
+	duration time.Duration, audience string) *jwtgo.Token {
+
 	klst := make([]string, 0, len(keys))
 	blst := make([]string, 0, len(bunches))
+	var conditions map[string][]string
 
+	// a token for an application only carries keys of that application
 	for _, k := range keys {
+		if len(audience) > 0 && keymatch.ApplicationOf(k.Key) != audience {
+			continue
+		}
+
+		if k.IsConditional() {
+			if conditions == nil {
+				conditions = make(map[string][]string)
+			}
+			conditions[k.Key] = k.Conditions
+			continue
+		}
 		klst = append(klst, k.Key)
 	}
 
+	var denied []string
+	for _, d := range denials {
+		denied = append(denied, d.Key)
+	}
+	sort.Strings(denied)
+
 	for _, b := range blst {
 		blst = append(blst, b)
 	}
@@ -220,15 +367,30 @@ func createToken(user *usrmgr.User, bunches []*usrmgr.Bunch, keys []*usrmgr.Key,
 		},
 		blst,
 		klst,
+		user.TenantID,
+		audience,
+		conditions,
+		denied,
+		user.Locale,
+		false,
 	})
 }
 
-// Todo: improve string searching algorithm
-func contains(a []string, x string) bool {
-	for _, n := range a {
-		if x == n {
-			return true
-		}
+// callerName returns username of the token's owner. Services are scoped to the tenant of the request, where the
+// name may be someone else's, so acting for the caller is refused when the caller has switched to another tenant.
+func callerName(ctx context.Context) (string, error) {
+	claims, ok := ctx.Value(jwt.JWTClaimsContextKey).(*TokenClaims)
+	if !ok {
+		return "", common.ErrMissingJWTToken
+	}
+
+	tenant := claims.Tenant
+	if tenant == 0 {
+		tenant = common.DefaultTenant
 	}
-	return false
+	if scoped, ok := ctx.Value(common.TenantContextKey).(int64); ok && scoped != tenant {
+		return "", common.ErrNotAllowed
+	}
+
+	return claims.Audience, nil
 }
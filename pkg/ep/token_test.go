@@ -0,0 +1,91 @@
+package ep
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
+)
+
+func TestCallerName(t *testing.T) {
+	claims := &TokenClaims{}
+	claims.Audience = "admin"
+	ctx := context.WithValue(context.Background(), jwt.JWTClaimsContextKey, claims)
+
+	name, err := callerName(withTenant(ctx, common.DefaultTenant))
+	require.Nil(t, err)
+	require.Equal(t, "admin", name)
+
+	// the same name in another tenant is someone else
+	_, err = callerName(withTenant(ctx, 2))
+	require.Equal(t, common.ErrNotAllowed, err)
+
+	_, err = callerName(context.Background())
+	require.Equal(t, common.ErrMissingJWTToken, err)
+}
+
+// conditionalPats is a token service whose token carries a key with conditions
+type conditionalPats struct {
+	tokenmgr.Service
+}
+
+func (s *conditionalPats) Authenticate(plain string) (*tokenmgr.Token, error) {
+	return &tokenmgr.Token{Username: "robot", TenantID: common.DefaultTenant, Keys: []string{"get_user"},
+		Conditions: map[string][]string{"modify_user": {`ip == "10.0.0.1"`}}}, nil
+}
+
+func TestParseToken_Personal(t *testing.T) {
+	ctx := context.WithValue(context.Background(), common.AppConfigContextKey, &cf.AppConfig{})
+	ctx = context.WithValue(ctx, common.TokenManagementService, &conditionalPats{})
+
+	claims, err := parseToken(ctx, tokenmgr.Prefix+"secret")
+	require.Nil(t, err)
+	require.True(t, claims.Personal)
+	require.Equal(t, []string{"get_user"}, claims.Keys)
+	require.Equal(t, map[string][]string{"modify_user": {`ip == "10.0.0.1"`}}, claims.Conditions)
+}
+
+func TestCarriesKey(t *testing.T) {
+	claims := &TokenClaims{
+		Keys:       []string{"users:*"},
+		Conditions: map[string][]string{"reports:*": {`ip == "10.0.0.1"`}},
+		Denied:     []string{"users:delete"},
+	}
+
+	require.True(t, carriesKey(claims, "users:get"))
+	require.True(t, carriesKey(claims, "reports:sales"))
+	require.False(t, carriesKey(claims, "users:delete"))
+	require.False(t, carriesKey(claims, "orders:get"))
+
+	// a key held with conditions doesn't give the keys which it's part of
+	claims.Conditions = map[string][]string{"reports:sales": {`ip == "10.0.0.1"`}}
+	require.False(t, carriesKey(claims, "reports:*"))
+}
+
+func TestChildExpiry(t *testing.T) {
+	parent := time.Now().Add(time.Hour).Truncate(time.Second)
+	claims := &TokenClaims{Personal: true}
+	claims.ExpiresAt = parent.Unix()
+
+	// tokens minted by a personal access token expire with it at the latest
+	require.Equal(t, sql.NullTime{Time: parent, Valid: true}, childExpiry(claims, sql.NullTime{}))
+	require.Equal(t, sql.NullTime{Time: parent, Valid: true},
+		childExpiry(claims, sql.NullTime{Time: parent.Add(time.Hour), Valid: true}))
+
+	sooner := sql.NullTime{Time: parent.Add(-time.Minute), Valid: true}
+	require.Equal(t, sooner, childExpiry(claims, sooner))
+
+	// personal access tokens which don't expire and jwt tokens leave the expiry as it is
+	claims.ExpiresAt = 0
+	require.Equal(t, sql.NullTime{}, childExpiry(claims, sql.NullTime{}))
+
+	claims = &TokenClaims{}
+	claims.ExpiresAt = parent.Unix()
+	require.Equal(t, sql.NullTime{}, childExpiry(claims, sql.NullTime{}))
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"github.com/vespaiach/auth/pkg/cf"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymatch"
 	"github.com/vespaiach/auth/pkg/usrmgr"
 	"golang.org/x/crypto/bcrypt"
 	"time"
@@ -15,8 +16,12 @@ type User struct {
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
 	Active    bool      `json:"active"`
+	Type      string    `json:"type"`
+	Owner     string    `json:"owner,omitempty"`
+	Desc      string    `json:"desc,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Locale    string    `json:"locale,omitempty"`
 }
 
 type AddingUser struct {
@@ -32,12 +37,16 @@ type ModifyingUser struct {
 	NewPassword string `json:"new_password"`
 	OldPassword string `json:"old_password"`
 	Active      *bool  `json:"active"`
+
+	// Locale sets the language which the user is told messages in, an empty one clears it
+	Locale *string `json:"locale"`
 }
 
 type QueryingUser struct {
 	Username string
 	Email    string
 	Active   sql.NullBool
+	Type     string
 	Sort     string
 	Page     int64
 	PerPage  int64
@@ -55,6 +64,39 @@ type AddingBunchesToUser struct {
 	Username string
 }
 
+type ImportingUsers struct {
+	Rows      []*usrmgr.ImportRow
+	DryRun    bool
+	BatchSize int
+}
+
+// UserImportRow is the outcome of a row, invited users are told their invites by whoever imports them
+type UserImportRow struct {
+	Line     int    `json:"line"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Invite   string `json:"invite,omitempty"`
+}
+
+// AcceptingInvite sets the password of an invited user
+type AcceptingInvite struct {
+	Invite   string `json:"invite"`
+	Password string `json:"password"`
+}
+
+type UserImportReport struct {
+	DryRun bool             `json:"dry_run"`
+	Total  int              `json:"total"`
+	Failed int              `json:"failed"`
+	Rows   []*UserImportRow `json:"rows"`
+}
+
+type RemovingBunchesFromUser struct {
+	Bunches  []string `json:"bunches"`
+	Username string
+}
+
 func AddingUserEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
 	erch := make(chan error)
 	uch := make(chan *usrmgr.User)
@@ -104,12 +146,110 @@ func AddingUserEndpoint(ctx context.Context, request interface{}) (interface{},
 			u.Username,
 			u.Email,
 			u.Active.Bool,
+			u.Type,
+			u.Owner,
+			u.Desc,
 			u.CreatedAt,
 			u.UpdatedAt,
+			u.Locale,
 		}, nil
 	}
 }
 
+func ImportingUsersEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	rch := make(chan []*usrmgr.ImportResult)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+	appConfig := ctx.Value(common.AppConfigContextKey).(*cf.AppConfig)
+	req, ok := request.(*ImportingUsers)
+
+	go func() {
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		results, err := userv.ImportUsers(req.Rows, &usrmgr.ImportOptions{
+			DryRun:     req.DryRun,
+			BatchSize:  req.BatchSize,
+			BcryptCost: appConfig.BcryptCost,
+		})
+		if err != nil {
+			erch <- err
+			return
+		}
+		rch <- results
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-rch:
+		report := &UserImportReport{
+			DryRun: req.DryRun,
+			Total:  len(lst),
+			Rows:   make([]*UserImportRow, 0, len(lst)),
+		}
+		for _, row := range lst {
+			r := &UserImportRow{
+				Line:     row.Line,
+				Username: row.Username,
+				Status:   row.Status,
+				Invite:   row.Invite,
+			}
+			if row.Error != nil {
+				r.Error = row.Error.Error()
+				report.Failed++
+			}
+			report.Rows = append(report.Rows, r)
+		}
+		return report, nil
+	}
+}
+
+func AcceptingInviteEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+	appConfig := ctx.Value(common.AppConfigContextKey).(*cf.AppConfig)
+
+	go func() {
+		req, ok := request.(*AcceptingInvite)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if len(req.Password) == 0 {
+			erch <- common.ErrPasswordMissing
+			return
+		}
+
+		hash, err := usrmgr.HashPassword(req.Password, appConfig.BcryptCost)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		if err := userv.AcceptInvite(req.Invite, hash); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
 func GettingUserEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
 	erch := make(chan error)
 	uch := make(chan *usrmgr.User)
@@ -146,8 +286,12 @@ func GettingUserEndpoint(ctx context.Context, request interface{}) (interface{},
 			u.Username,
 			u.Email,
 			u.Active.Bool,
+			u.Type,
+			u.Owner,
+			u.Desc,
 			u.CreatedAt,
 			u.UpdatedAt,
+			u.Locale,
 		}, nil
 	}
 }
@@ -180,7 +324,13 @@ func ModifyingUserEndpoint(ctx context.Context, request interface{}) (interface{
 			active.Valid = true
 		}
 
-		err = userv.ModifyUser(user.ID, req.Username, req.Email, "", active)
+		locale := sql.NullString{}
+		if req.Locale != nil {
+			locale.String = *req.Locale
+			locale.Valid = true
+		}
+
+		err = userv.ModifyUser(user.ID, req.Username, req.Email, "", active, locale)
 		if err != nil {
 			erch <- err
 			return
@@ -221,7 +371,7 @@ func QueryingUserEndpoint(ctx context.Context, request interface{}) (interface{}
 		}
 
 		records, count, err := userv.QueryUsers(params.Page, params.PerPage, params.Username, params.Email,
-			params.Active, params.Sort)
+			params.Active, params.Type, params.Sort)
 		if err != nil {
 			erch <- err
 			return
@@ -243,8 +393,12 @@ func QueryingUserEndpoint(ctx context.Context, request interface{}) (interface{}
 				row.Username,
 				row.Email,
 				row.Active.Bool,
+				row.Type,
+				row.Owner,
+				row.Desc,
 				row.CreatedAt,
 				row.UpdatedAt,
+				row.Locale,
 			})
 		}
 		return &Users{
@@ -287,6 +441,37 @@ func AddingBunchesToUserEndpoint(ctx context.Context, request interface{}) (inte
 	}
 }
 
+func RemovingBunchesFromUserEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	qch := make(chan bool)
+	userv := ctx.Value(common.UserManagementService).(usrmgr.Service)
+
+	go func() {
+		req, ok := request.(*RemovingBunchesFromUser)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		err := userv.RemoveBunchesFromUser(req.Username, req.Bunches)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		qch <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-qch:
+		return true, nil
+	}
+}
+
 func GettingBunchesOfUserEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
 	erch := make(chan error)
 	bch := make(chan []*usrmgr.Bunch)
@@ -323,6 +508,7 @@ func GettingBunchesOfUserEndpoint(ctx context.Context, request interface{}) (int
 				row.Active.Bool,
 				row.CreatedAt,
 				row.UpdatedAt,
+				row.Condition,
 			})
 		}
 		return rows, nil
@@ -361,9 +547,11 @@ func GettingKeysOfUserEndpoint(ctx context.Context, request interface{}) (interf
 			rows = append(rows, &Key{
 				row.ID,
 				row.Key,
+				keymatch.ApplicationOf(row.Key),
 				row.Desc,
 				row.CreatedAt,
 				row.UpdatedAt,
+				row.Conditions,
 			})
 		}
 		return rows, nil
@@ -0,0 +1,385 @@
+package ep
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"time"
+)
+
+type Webhook struct {
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Secret signs deliveries, it's only returned once when the webhook is added
+	Secret string `json:"secret,omitempty"`
+}
+
+type AddingWebhook struct {
+	Name   string   `json:"name"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type ModifyingWebhook struct {
+	Lookup string
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EventID        int64           `json:"event_id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+
+	// Log lists attempts of the delivery, it's only returned when a single delivery is asked for
+	Log []*WebhookAttempt `json:"log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookDeliveries struct {
+	Records []*WebhookDelivery `json:"records"`
+	Total   int64              `json:"total"`
+	Page    int64              `json:"page"`
+	PerPage int64              `json:"per_page"`
+}
+
+type QueryingWebhookDelivery struct {
+	Name    string
+	Status  string
+	Page    int64
+	PerPage int64
+}
+
+// GettingWebhookDelivery picks a delivery of the webhook, to get or to retry it
+type GettingWebhookDelivery struct {
+	Name string
+	ID   int64
+}
+
+func AddingWebhookEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	wch := make(chan *webhook.Subscription)
+	wserv := ctx.Value(common.WebhookService).(webhook.Service)
+
+	go func() {
+		req, ok := request.(*AddingWebhook)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		sub, err := wserv.AddSubscription(req.Name, req.URL, req.Events)
+		if err != nil {
+			erch <- err
+			return
+		}
+		wch <- sub
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case sub := <-wch:
+		result := toWebhook(sub)
+		result.Secret = sub.Secret
+		return result, nil
+	}
+}
+
+func GettingWebhookEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	wch := make(chan *webhook.Subscription)
+	wserv := ctx.Value(common.WebhookService).(webhook.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		sub, err := wserv.GetSubscription(name)
+		if err != nil {
+			erch <- err
+			return
+		}
+		wch <- sub
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case sub := <-wch:
+		return toWebhook(sub), nil
+	}
+}
+
+func QueryingWebhookEndpoint(ctx context.Context, _ interface{}) (interface{}, error) {
+	erch := make(chan error)
+	wch := make(chan []*webhook.Subscription)
+	wserv := ctx.Value(common.WebhookService).(webhook.Service)
+
+	go func() {
+		subs, err := wserv.GetSubscriptions()
+		if err != nil {
+			erch <- err
+			return
+		}
+		wch <- subs
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case subs := <-wch:
+		results := make([]*Webhook, 0, len(subs))
+		for _, sub := range subs {
+			results = append(results, toWebhook(sub))
+		}
+		return results, nil
+	}
+}
+
+func ModifyingWebhookEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	wserv := ctx.Value(common.WebhookService).(webhook.Service)
+
+	go func() {
+		req, ok := request.(*ModifyingWebhook)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		var active sql.NullBool
+		if req.Active != nil {
+			active = sql.NullBool{Bool: *req.Active, Valid: true}
+		}
+
+		if err := wserv.ModifySubscription(req.Lookup, req.URL, req.Events, active); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func RemovingWebhookEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	wserv := ctx.Value(common.WebhookService).(webhook.Service)
+
+	go func() {
+		name, ok := request.(string)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := wserv.RemoveSubscription(name); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func QueryingWebhookDeliveryEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	dch := make(chan []*webhook.Delivery)
+	wserv := ctx.Value(common.WebhookService).(webhook.Service)
+	params, ok := request.(*QueryingWebhookDelivery)
+
+	var total int64
+
+	go func() {
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if params.PerPage == 0 {
+			params.PerPage = common.Take
+		}
+
+		if params.Page == 0 {
+			params.Page = 1
+		}
+
+		records, count, err := wserv.QueryDeliveries(params.Name, params.Status, params.Page, params.PerPage)
+		if err != nil {
+			erch <- err
+			return
+		}
+		total = count
+		dch <- records
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case lst := <-dch:
+		results := make([]*WebhookDelivery, 0, len(lst))
+		for _, d := range lst {
+			results = append(results, toWebhookDelivery(d))
+		}
+		return &WebhookDeliveries{
+			results,
+			total,
+			params.Page,
+			params.PerPage,
+		}, nil
+	}
+}
+
+func GettingWebhookDeliveryEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	dch := make(chan *WebhookDelivery)
+	wserv := ctx.Value(common.WebhookService).(webhook.Service)
+
+	go func() {
+		req, ok := request.(*GettingWebhookDelivery)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		delivery, attempts, err := wserv.GetDelivery(req.Name, req.ID)
+		if err != nil {
+			erch <- err
+			return
+		}
+
+		result := toWebhookDelivery(delivery)
+		result.Payload = json.RawMessage(delivery.Payload)
+		result.Log = make([]*WebhookAttempt, 0, len(attempts))
+		for _, a := range attempts {
+			result.Log = append(result.Log, &WebhookAttempt{
+				a.StatusCode,
+				a.Error,
+				a.Duration.Milliseconds(),
+				a.CreatedAt,
+			})
+		}
+		dch <- result
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case d := <-dch:
+		return d, nil
+	}
+}
+
+// RetryingWebhookDeliveryEndpoint sends the delivery again, dead deliveries are taken out of the dead-letter
+// queue this way
+func RetryingWebhookDeliveryEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	erch := make(chan error)
+	success := make(chan bool)
+	wserv := ctx.Value(common.WebhookService).(webhook.Service)
+
+	go func() {
+		req, ok := request.(*GettingWebhookDelivery)
+		if !ok {
+			erch <- common.ErrWrongInputDatatype
+			return
+		}
+
+		if err := wserv.RetryDelivery(req.Name, req.ID); err != nil {
+			erch <- err
+			return
+		}
+		success <- true
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case e := <-erch:
+		return nil, e
+	case <-success:
+		return true, nil
+	}
+}
+
+func toWebhook(s *webhook.Subscription) *Webhook {
+	return &Webhook{
+		Name:      s.Name,
+		URL:       s.URL,
+		Events:    s.Events,
+		Active:    s.Active,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+}
+
+func toWebhookDelivery(d *webhook.Delivery) *WebhookDelivery {
+	result := &WebhookDelivery{
+		ID:             d.ID,
+		EventID:        d.EventID,
+		Event:          d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+	if d.NextAttemptAt.Valid {
+		result.NextAttemptAt = &d.NextAttemptAt.Time
+	}
+
+	return result
+}
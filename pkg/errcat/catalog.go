@@ -0,0 +1,179 @@
+// Package errcat answers errors with entries of the error catalog. The catalog is read from a yaml file whose
+// entries are keyed by names of errors of pkg/common, e.g. ErrDuplicatedKey, and tell the machine code, the
+// http status and the message which callers are given. Messages in other languages are read from bundles of
+// locales, keyed by codes in the errors section, e.g. errors.duplicated_key.
+//
+// Errors which aren't in the catalog are answered with its Internal entry, so that messages of the storage
+// never reach callers.
+package errcat
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/i18n"
+	"gopkg.in/yaml.v2"
+)
+
+// Internal names the entry which errors out of the catalog are answered with
+const Internal = "Internal"
+
+// internal answers errors out of the catalog when the catalog has no Internal entry
+var internal = mustEntry(&Entry{Code: "internal", Status: http.StatusInternalServerError, Message: "internal error"})
+
+var funcs = template.FuncMap{"join": strings.Join}
+
+// Entry tells how an error is answered
+type Entry struct {
+	Code   string `yaml:"code"`
+	Status int    `yaml:"status"`
+
+	// Message is a template, typed errors fill it with their fields
+	Message string `yaml:"message"`
+
+	// Field names the request field which the error is about
+	Field string `yaml:"field"`
+
+	template *template.Template
+}
+
+// Catalog holds entries by names of errors
+type Catalog struct {
+	entries map[string]*Entry
+	locales *i18n.Locales
+}
+
+// Problem is an error as callers are told it
+type Problem struct {
+	Status  int
+	Code    string
+	Message string
+	Details []*Detail
+
+	// Unknown is set when the error isn't in the catalog, it's worth logging as callers aren't told it
+	Unknown bool
+}
+
+// Detail tells a problem of one field
+type Detail struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Load reads the catalog from the yaml file, messages are translated by the locales
+func Load(file string, locales *i18n.Locales) (*Catalog, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(content, locales)
+}
+
+// Parse reads the catalog from yaml content, entries must be named after known errors
+func Parse(content []byte, locales *i18n.Locales) (*Catalog, error) {
+	entries := make(map[string]*Entry)
+	if err := yaml.UnmarshalStrict(content, &entries); err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{Internal: true, "ExclusionError": true, "ImportConflictError": true,
+		"ValidationError": true}
+	for _, name := range names {
+		known[name] = true
+	}
+
+	for name, e := range entries {
+		if !known[name] {
+			return nil, fmt.Errorf("error %s is unknown", name)
+		}
+		if len(e.Code) == 0 {
+			return nil, fmt.Errorf("error %s has no code", name)
+		}
+		if len(http.StatusText(e.Status)) == 0 {
+			return nil, fmt.Errorf("error %s has an invalid status %d", name, e.Status)
+		}
+		if err := e.compile(); err != nil {
+			return nil, fmt.Errorf("error %s: %v", name, err)
+		}
+	}
+
+	return &Catalog{entries, locales}, nil
+}
+
+// Lookup returns the entry of err, and whether err is in the catalog. Errors out of the catalog get the
+// Internal entry.
+func (c *Catalog) Lookup(err error) (*Entry, bool) {
+	if c == nil {
+		return internal, false
+	}
+
+	if e, ok := c.entries[nameOf(err)]; ok {
+		return e, true
+	}
+	if e, ok := c.entries[Internal]; ok {
+		return e, false
+	}
+	return internal, false
+}
+
+// Describe returns err as callers are told it in the locale lang, messages which aren't translated are told
+// in the catalog's own language. Fields of validation errors, and the field of errors which are about
+// one, are listed in details.
+func (c *Catalog) Describe(err error, lang string) *Problem {
+	e, ok := c.Lookup(err)
+	p := &Problem{
+		Status:  e.Status,
+		Code:    e.Code,
+		Message: c.message(e, err, lang),
+		Details: make([]*Detail, 0),
+		Unknown: !ok,
+	}
+
+	if verr, isValidation := err.(*common.ValidationError); isValidation {
+		for _, problem := range verr.Problems {
+			pe, _ := c.Lookup(problem.Err)
+			p.Details = append(p.Details, &Detail{problem.Field, pe.Code, c.message(pe, problem.Err, lang)})
+		}
+	} else if ok && len(e.Field) > 0 {
+		p.Details = append(p.Details, &Detail{e.Field, e.Code, p.Message})
+	}
+
+	return p
+}
+
+func (e *Entry) compile() error {
+	tmpl, err := template.New("").Funcs(funcs).Parse(e.Message)
+	if err != nil {
+		return err
+	}
+	e.template = tmpl
+	return nil
+}
+
+// message fills the message of the entry in lang with fields of err
+func (c *Catalog) message(e *Entry, err error, lang string) string {
+	if c != nil {
+		if message, ok := c.locales.Render(lang, "errors."+e.Code, err); ok {
+			return message
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if e.template.Execute(buf, err) != nil {
+		return e.Message
+	}
+	return buf.String()
+}
+
+func mustEntry(e *Entry) *Entry {
+	if err := e.compile(); err != nil {
+		panic(err)
+	}
+	return e
+}
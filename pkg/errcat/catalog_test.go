@@ -0,0 +1,153 @@
+package errcat
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/i18n"
+)
+
+func TestLoad_Complete(t *testing.T) {
+	locales, err := i18n.Load("../../locales", "en")
+	require.Nil(t, err)
+
+	catalog, err := Load("../../error.yml", locales)
+	require.Nil(t, err)
+
+	// every error declared by pkg/common is named and catalogued
+	file, err := parser.ParseFile(token.NewFileSet(), "../common/error.go", nil, 0)
+	require.Nil(t, err)
+
+	registered := make(map[string]bool)
+	for _, name := range names {
+		registered[name] = true
+	}
+
+	declared := 0
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for _, ident := range spec.Names {
+			if strings.HasPrefix(ident.Name, "Err") {
+				declared++
+				require.True(t, registered[ident.Name], "%s isn't named", ident.Name)
+				require.Contains(t, catalog.entries, ident.Name)
+			}
+		}
+		return true
+	})
+	require.Equal(t, len(names), declared)
+
+	for _, name := range []string{Internal, "ExclusionError", "ImportConflictError", "ValidationError"} {
+		require.Contains(t, catalog.entries, name)
+	}
+
+	codes := make(map[string]string)
+	for name, e := range catalog.entries {
+		require.NotContains(t, codes, e.Code, "%s and %s share a code", name, codes[e.Code])
+		codes[e.Code] = name
+	}
+
+	// bundles only translate codes of the catalog
+	for _, tag := range locales.Tags() {
+		for _, key := range locales.Keys(tag) {
+			require.True(t, strings.HasPrefix(key, "errors."), "%s: %s", tag, key)
+			require.Contains(t, codes, strings.TrimPrefix(key, "errors."), "%s: %s", tag, key)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse([]byte("ErrNoSuchThing:\n  code: no_such_thing\n  status: 400\n"), nil)
+	require.NotNil(t, err)
+
+	_, err = Parse([]byte("ErrKeyNotFound:\n  status: 404\n"), nil)
+	require.NotNil(t, err)
+
+	_, err = Parse([]byte("ErrKeyNotFound:\n  code: key_not_found\n  status: 42\n"), nil)
+	require.NotNil(t, err)
+
+	_, err = Parse([]byte("ErrKeyNotFound:\n  code: key_not_found\n  status: 404\n  message: \"{{.Name\"\n"), nil)
+	require.NotNil(t, err)
+}
+
+func TestDescribe(t *testing.T) {
+	locales := i18n.New("en")
+	require.Nil(t, locales.Add("vi", []byte("errors:\n  username_invalid: tên đăng nhập không hợp lệ\n")))
+
+	catalog, err := Parse([]byte(`
+Internal:
+  code: internal
+  status: 500
+  message: something went wrong
+ErrUsernameInvalid:
+  code: username_invalid
+  status: 400
+  message: username is invalid
+  field: username
+ErrEmailInvalid:
+  code: email_invalid
+  status: 400
+  message: email is invalid
+  field: email
+ErrKeyNotFound:
+  code: key_not_found
+  status: 404
+  message: key doesn't exist
+ExclusionError:
+  code: exclusion_violated
+  status: 409
+  message: "bunches {{join .Bunches \", \"}} are exclusive by {{.Exclusion}}"
+ValidationError:
+  code: validation_failed
+  status: 400
+  message: request has invalid fields
+`), locales)
+	require.Nil(t, err)
+
+	p := catalog.Describe(common.ErrKeyNotFound, "")
+	require.Equal(t, http.StatusNotFound, p.Status)
+	require.Equal(t, "key_not_found", p.Code)
+	require.Empty(t, p.Details)
+	require.False(t, p.Unknown)
+
+	p = catalog.Describe(common.ErrUsernameInvalid, "")
+	require.Equal(t, []*Detail{{"username", "username_invalid", "username is invalid"}}, p.Details)
+
+	// locales fall back to their base language, then to the catalog's own
+	require.Equal(t, "tên đăng nhập không hợp lệ", catalog.Describe(common.ErrUsernameInvalid, "vi-VN").Message)
+	require.Equal(t, "username is invalid", catalog.Describe(common.ErrUsernameInvalid, "fr").Message)
+
+	p = catalog.Describe(&common.ExclusionError{Exclusion: "sod", Bunches: []string{"a", "b"}}, "")
+	require.Equal(t, http.StatusConflict, p.Status)
+	require.Equal(t, "bunches a, b are exclusive by sod", p.Message)
+
+	invalid := new(common.ValidationError)
+	invalid.Add("username", common.ErrUsernameInvalid)
+	invalid.Add("email", common.ErrEmailInvalid)
+	p = catalog.Describe(invalid.Err(), "")
+	require.Equal(t, "validation_failed", p.Code)
+	require.Equal(t, []*Detail{
+		{"username", "username_invalid", "username is invalid"},
+		{"email", "email_invalid", "email is invalid"},
+	}, p.Details)
+
+	// messages of unknown errors aren't told
+	p = catalog.Describe(errors.New("Error 1054: Unknown column 'x' in 'field list'"), "")
+	require.Equal(t, http.StatusInternalServerError, p.Status)
+	require.Equal(t, "something went wrong", p.Message)
+	require.True(t, p.Unknown)
+
+	var none *Catalog
+	p = none.Describe(common.ErrKeyNotFound, "")
+	require.Equal(t, "internal", p.Code)
+}
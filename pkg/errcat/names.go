@@ -0,0 +1,133 @@
+package errcat
+
+import (
+	"reflect"
+
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+// names are the names which sentinel errors go by in the catalog
+var names = map[error]string{
+	common.ErrDuplicatedKey:      "ErrDuplicatedKey",
+	common.ErrKeyNameInvalid:     "ErrKeyNameInvalid",
+	common.ErrKeyNotFound:        "ErrKeyNotFound",
+	common.ErrBunchNotFound:      "ErrBunchNotFound",
+	common.ErrWrongInputDatatype: "ErrWrongInputDatatype",
+	common.ErrDuplicatedBunch:    "ErrDuplicatedBunch",
+	common.ErrBunchNameInvalid:   "ErrBunchNameInvalid",
+	common.ErrUsernameInvalid:    "ErrUsernameInvalid",
+	common.ErrWrongCredentials:   "ErrWrongCredentials",
+	common.ErrDuplicatedUsername: "ErrDuplicatedUsername",
+	common.ErrEmailInvalid:       "ErrEmailInvalid",
+	common.ErrDuplicatedEmail:    "ErrDuplicatedEmail",
+	common.ErrMissingHash:        "ErrMissingHash",
+	common.ErrUserNotFound:       "ErrUserNotFound",
+	common.ErrPasswordMissing:    "ErrPasswordMissing",
+	common.ErrMissingJWTToken:    "ErrMissingJWTToken",
+	common.ErrWrongJWTToken:      "ErrWrongJWTToken",
+	common.ErrNotAllowed:         "ErrNotAllowed",
+
+	common.ErrCampaignNameInvalid:   "ErrCampaignNameInvalid",
+	common.ErrCampaignNotFound:      "ErrCampaignNotFound",
+	common.ErrCampaignClosed:        "ErrCampaignClosed",
+	common.ErrCampaignNotClosed:     "ErrCampaignNotClosed",
+	common.ErrReviewScopeInvalid:    "ErrReviewScopeInvalid",
+	common.ErrMissingReviewTargets:  "ErrMissingReviewTargets",
+	common.ErrMissingReviewers:      "ErrMissingReviewers",
+	common.ErrReviewItemNotFound:    "ErrReviewItemNotFound",
+	common.ErrReviewDecisionInvalid: "ErrReviewDecisionInvalid",
+	common.ErrReviewerNotAssigned:   "ErrReviewerNotAssigned",
+	common.ErrReviewItemDecided:     "ErrReviewItemDecided",
+
+	common.ErrExclusionNameInvalid: "ErrExclusionNameInvalid",
+	common.ErrDuplicatedExclusion:  "ErrDuplicatedExclusion",
+	common.ErrExclusionNotFound:    "ErrExclusionNotFound",
+	common.ErrExclusionTooSmall:    "ErrExclusionTooSmall",
+
+	common.ErrUnsupportedVersion:    "ErrUnsupportedVersion",
+	common.ErrImportStrategyInvalid: "ErrImportStrategyInvalid",
+
+	common.ErrAuthBackendInvalid: "ErrAuthBackendInvalid",
+
+	common.ErrProviderNotFound:   "ErrProviderNotFound",
+	common.ErrLoginStateInvalid:  "ErrLoginStateInvalid",
+	common.ErrIDTokenInvalid:     "ErrIDTokenInvalid",
+	common.ErrIdentityNotLinked:  "ErrIdentityNotLinked",
+	common.ErrUpstreamLoginError: "ErrUpstreamLoginError",
+
+	common.ErrTokenNameInvalid:     "ErrTokenNameInvalid",
+	common.ErrMissingTokenKeys:     "ErrMissingTokenKeys",
+	common.ErrTokenExpiryInvalid:   "ErrTokenExpiryInvalid",
+	common.ErrDuplicatedToken:      "ErrDuplicatedToken",
+	common.ErrTokenNotFound:        "ErrTokenNotFound",
+	common.ErrPersonalTokenInvalid: "ErrPersonalTokenInvalid",
+
+	common.ErrServiceAccountNotFound: "ErrServiceAccountNotFound",
+	common.ErrOwnerInvalid:           "ErrOwnerInvalid",
+	common.ErrUserTypeInvalid:        "ErrUserTypeInvalid",
+
+	common.ErrTenantNameInvalid: "ErrTenantNameInvalid",
+	common.ErrDuplicatedTenant:  "ErrDuplicatedTenant",
+	common.ErrTenantNotFound:    "ErrTenantNotFound",
+	common.ErrTenantInactive:    "ErrTenantInactive",
+
+	common.ErrApplicationNameInvalid: "ErrApplicationNameInvalid",
+	common.ErrDuplicatedApplication:  "ErrDuplicatedApplication",
+	common.ErrApplicationNotFound:    "ErrApplicationNotFound",
+
+	common.ErrConditionInvalid: "ErrConditionInvalid",
+	common.ErrGrantNotFound:    "ErrGrantNotFound",
+	common.ErrAttributeInvalid: "ErrAttributeInvalid",
+
+	common.ErrTupleInvalid:            "ErrTupleInvalid",
+	common.ErrNamespaceInvalid:        "ErrNamespaceInvalid",
+	common.ErrNamespaceNotFound:       "ErrNamespaceNotFound",
+	common.ErrRelationNotFound:        "ErrRelationNotFound",
+	common.ErrConsistencyTokenInvalid: "ErrConsistencyTokenInvalid",
+	common.ErrRelationTooDeep:         "ErrRelationTooDeep",
+
+	common.ErrAuditOutcomeInvalid: "ErrAuditOutcomeInvalid",
+	common.ErrAuditPeriodInvalid:  "ErrAuditPeriodInvalid",
+
+	common.ErrSessionNotFound:   "ErrSessionNotFound",
+	common.ErrSessionTerminated: "ErrSessionTerminated",
+
+	common.ErrWebhookNameInvalid:    "ErrWebhookNameInvalid",
+	common.ErrWebhookURLInvalid:     "ErrWebhookURLInvalid",
+	common.ErrWebhookEventsInvalid:  "ErrWebhookEventsInvalid",
+	common.ErrDuplicatedWebhook:     "ErrDuplicatedWebhook",
+	common.ErrWebhookNotFound:       "ErrWebhookNotFound",
+	common.ErrDeliveryStatusInvalid: "ErrDeliveryStatusInvalid",
+	common.ErrDeliveryNotFound:      "ErrDeliveryNotFound",
+	common.ErrEventTypeInvalid:      "ErrEventTypeInvalid",
+
+	common.ErrLocaleInvalid: "ErrLocaleInvalid",
+
+	common.ErrPrivilegeEscalation: "ErrPrivilegeEscalation",
+
+	common.ErrManifestPlanChanged: "ErrManifestPlanChanged",
+	common.ErrAdminBunchProtected: "ErrAdminBunchProtected",
+
+	common.ErrRequestInvalid: "ErrRequestInvalid",
+
+	common.ErrInviteInvalid: "ErrInviteInvalid",
+}
+
+// nameOf returns the name which err goes by in the catalog, it's empty for errors which aren't named
+func nameOf(err error) string {
+	switch err.(type) {
+	case *common.ExclusionError:
+		return "ExclusionError"
+	case *common.ImportConflictError:
+		return "ImportConflictError"
+	case *common.ValidationError:
+		return "ValidationError"
+	}
+
+	// errors of incomparable types can't be map keys
+	if err == nil || !reflect.TypeOf(err).Comparable() {
+		return ""
+	}
+
+	return names[err]
+}
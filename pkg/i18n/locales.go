@@ -0,0 +1,221 @@
+// Package i18n holds message bundles of locales. A bundle is a yaml file of the locales directory named after
+// its locale tag, e.g. vi.yml or pt-BR.yml, so that adding a language only takes a file. Messages are templates
+// keyed by dotted names of their sections, e.g. errors.duplicated_key, and are filled with data of the message,
+// such as fields of an error.
+//
+// The default locale is the language which messages are written in where they're defined, it needs no bundle.
+// Messages which a bundle lacks are told in it.
+//
+// Only errors are localized. Lockout messages and the verification and reset email templates are out of scope:
+// the service has no lockout and sends no emails, their sections and a template loader come with those features.
+package i18n
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+var funcs = template.FuncMap{"join": strings.Join}
+
+// Locales holds bundles by their lowercased locale tags
+type Locales struct {
+	fallback string
+	bundles  map[string]map[string]*template.Template
+}
+
+// New returns locales without bundles, messages are told in the fallback locale
+func New(fallback string) *Locales {
+	return &Locales{strings.ToLower(fallback), make(map[string]map[string]*template.Template)}
+}
+
+// Load reads bundles from yaml files of the directory, fallback is the default locale
+func Load(dir string, fallback string) (*Locales, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	l := New(fallback)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		tag := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if err := l.Add(tag, content); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+
+	return l, nil
+}
+
+// Add reads the bundle of the locale tag from yaml content
+func (l *Locales) Add(tag string, content []byte) error {
+	tree := make(map[string]interface{})
+	if err := yaml.UnmarshalStrict(content, &tree); err != nil {
+		return err
+	}
+
+	messages := make(map[string]string)
+	if err := flatten("", tree, messages); err != nil {
+		return err
+	}
+
+	bundle := make(map[string]*template.Template)
+	for key, message := range messages {
+		tmpl, err := template.New(key).Funcs(funcs).Parse(message)
+		if err != nil {
+			return err
+		}
+		bundle[key] = tmpl
+	}
+
+	l.bundles[strings.ToLower(tag)] = bundle
+	return nil
+}
+
+// Tags returns tags of the locales which have bundles
+func (l *Locales) Tags() []string {
+	tags := make([]string, 0, len(l.bundles))
+	for tag := range l.bundles {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Keys returns keys of the messages which the bundle of the locale holds
+func (l *Locales) Keys(tag string) []string {
+	keys := make([]string, 0)
+	for key := range l.bundles[strings.ToLower(tag)] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Match returns the first of the tags which is supported, either by a bundle of its own or of its base
+// language, e.g. pt for pt-br. It returns the default locale when none is.
+func (l *Locales) Match(tags ...string) string {
+	if l == nil {
+		return ""
+	}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		base := strings.Split(tag, "-")[0]
+
+		switch {
+		case len(tag) == 0 || tag == "*":
+			continue
+		case tag == l.fallback || base == l.fallback:
+			return l.fallback
+		}
+
+		if _, ok := l.bundles[tag]; ok {
+			return tag
+		}
+		if _, ok := l.bundles[base]; ok {
+			return base
+		}
+	}
+
+	return l.fallback
+}
+
+// Render fills the message of the key in the locale with data. It's false when the locale has no such message,
+// callers then tell the message in the default locale.
+func (l *Locales) Render(tag string, key string, data interface{}) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+
+	tag = strings.ToLower(tag)
+	tmpl, ok := l.bundles[tag][key]
+	if !ok {
+		tmpl, ok = l.bundles[strings.Split(tag, "-")[0]][key]
+	}
+	if !ok {
+		return "", false
+	}
+
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// ParseAcceptLanguage returns tags of an Accept-Language header, the most preferred first. Tags weighted
+// with q=0 are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	lst := make([]*weighted, 0)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		w := &weighted{strings.TrimSpace(fields[0]), 1}
+		if len(w.tag) == 0 {
+			continue
+		}
+
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				w.q = q
+			}
+		}
+		if w.q > 0 {
+			lst = append(lst, w)
+		}
+	}
+
+	sort.SliceStable(lst, func(i, j int) bool { return lst[i].q > lst[j].q })
+
+	tags := make([]string, 0, len(lst))
+	for _, w := range lst {
+		tags = append(tags, w.tag)
+	}
+	return tags
+}
+
+// flatten keys messages of nested sections by their dotted names
+func flatten(prefix string, tree map[string]interface{}, messages map[string]string) error {
+	for name, value := range tree {
+		key := prefix + name
+
+		switch v := value.(type) {
+		case string:
+			messages[key] = v
+		case map[interface{}]interface{}:
+			section := make(map[string]interface{})
+			for k, sv := range v {
+				section[fmt.Sprint(k)] = sv
+			}
+			if err := flatten(key+".", section, messages); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %s isn't a text", key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	l, err := Load("../../locales", "en")
+	require.Nil(t, err)
+	require.Contains(t, l.Tags(), "vi")
+
+	message, ok := l.Render("vi", "errors.key_not_found", nil)
+	require.True(t, ok)
+	require.Equal(t, "khóa không tồn tại", message)
+}
+
+func TestLocales_Add(t *testing.T) {
+	l := New("en")
+
+	require.Nil(t, l.Add("pt-BR", []byte("errors:\n  not_found: \"{{.Name}} não existe\"\nmail:\n  reset:\n    subject: Redefinir senha\n")))
+	require.Equal(t, []string{"errors.not_found", "mail.reset.subject"}, l.Keys("pt-br"))
+
+	message, ok := l.Render("pt-BR", "errors.not_found", struct{ Name string }{"bob"})
+	require.True(t, ok)
+	require.Equal(t, "bob não existe", message)
+
+	// messages are texts, templates must parse
+	require.NotNil(t, l.Add("fr", []byte("errors:\n  not_found: [1, 2]\n")))
+	require.NotNil(t, l.Add("fr", []byte("errors:\n  not_found: \"{{.Name\"\n")))
+}
+
+func TestLocales_Match(t *testing.T) {
+	l := New("en")
+	require.Nil(t, l.Add("vi", []byte("errors:\n  not_found: không tồn tại\n")))
+	require.Nil(t, l.Add("pt", []byte("errors:\n  not_found: não existe\n")))
+
+	require.Equal(t, "vi", l.Match("vi-VN", "en"))
+	require.Equal(t, "pt", l.Match("pt-BR"))
+	require.Equal(t, "en", l.Match("en-US", "vi"))
+	require.Equal(t, "vi", l.Match("*", "fr", "vi"))
+	require.Equal(t, "en", l.Match("fr"))
+	require.Equal(t, "en", l.Match())
+
+	_, ok := l.Render("en", "errors.not_found", nil)
+	require.False(t, ok)
+	_, ok = l.Render("fr", "errors.not_found", nil)
+	require.False(t, ok)
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	require.Equal(t, []string{"fr-CH", "fr", "en", "de", "*"},
+		ParseAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5"))
+	require.Equal(t, []string{"vi", "en"}, ParseAcceptLanguage("en;q=0.5, vi, fr;q=0"))
+	require.Empty(t, ParseAcceptLanguage(""))
+}
@@ -0,0 +1,103 @@
+// Package keymatch matches required keys against granted key patterns. It's used by the
+// server's key checks and can be imported by downstream services to check token's keys the same way.
+//
+// A key is made of segments joined by ":", e.g. "billing:invoices:read", optionally qualified by
+// its application, e.g. "crm.contacts:read". A granted key matches a required key when:
+//
+//   - both belong to the same application, or to none
+//   - every segment of the granted key equals the required key's segment at the same position,
+//     or is "*", which matches exactly one segment
+//   - both have the same number of segments, except that a "*" as the last segment of the granted
+//     key matches all remaining segments, so "users:*" grants "users:read" and "users:groups:read"
+//     but not "users"
+//
+// Keys without wildcards only match themselves. The first segment of a key can't be a wildcard, so that no key,
+// e.g. "*" or "*:read", grants every other key.
+package keymatch
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// Separator joins segments of a key
+	Separator = ":"
+
+	// Wildcard matches one segment, or all remaining ones at the end of a key
+	Wildcard = "*"
+
+	// ApplicationSeparator joins an application's name and its key's own name, e.g. "billing.read"
+	ApplicationSeparator = "."
+
+	// MaxLength is the longest key which can be stored, the width of keys.key
+	MaxLength = 65
+)
+
+var keyReg = regexp.MustCompile(`^([a-z0-9_]{1,32}\.)?[a-z0-9_]+(:([a-z0-9_]+|\*))*$`)
+
+// IsValid reports whether the key can be defined, e.g. "add_key", "users:*" or "billing.invoices:read"
+func IsValid(key string) bool {
+	return len(key) <= MaxLength && keyReg.MatchString(key)
+}
+
+// ApplicationOf returns the application of the key, or empty if the key doesn't belong to one
+func ApplicationOf(key string) string {
+	app, _ := splitApplication(key)
+	return app
+}
+
+// Match reports whether the granted key pattern grants the required key
+func Match(pattern string, key string) bool {
+	if pattern == key {
+		return true
+	}
+
+	patternApp, patternRest := splitApplication(pattern)
+	keyApp, keyRest := splitApplication(key)
+	if patternApp != keyApp {
+		return false
+	}
+
+	patternSegs := strings.Split(patternRest, Separator)
+	keySegs := strings.Split(keyRest, Separator)
+
+	for i, seg := range patternSegs {
+		if i >= len(keySegs) {
+			return false
+		}
+
+		if seg == Wildcard {
+			if i == len(patternSegs)-1 {
+				return true
+			}
+			continue
+		}
+
+		if seg != keySegs[i] {
+			return false
+		}
+	}
+
+	return len(patternSegs) == len(keySegs)
+}
+
+// Any reports whether one of the granted key patterns grants the required key
+func Any(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if Match(p, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitApplication(key string) (string, string) {
+	i := strings.Index(key, ApplicationSeparator)
+	if i < 0 {
+		return "", key
+	}
+
+	return key[:i], key[i+1:]
+}
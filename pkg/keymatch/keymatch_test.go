@@ -0,0 +1,69 @@
+package keymatch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		pattern string
+		key     string
+		matched bool
+	}{
+		{"success_exact_key", "add_user", "add_user", true},
+		{"success_exact_segments", "billing:invoices:read", "billing:invoices:read", true},
+		{"success_trailing_wildcard", "users:*", "users:read", true},
+		{"success_trailing_wildcard_is_hierarchical", "users:*", "users:groups:read", true},
+		{"success_inner_wildcard", "billing:*:read", "billing:invoices:read", true},
+		{"success_everything_outside_applications", "*", "billing:invoices:read", true},
+		{"success_application_key", "crm.contacts:*", "crm.contacts:read", true},
+		{"success_scoped_route_key", "modify_user:users:*", "modify_user:users:staff_role", true},
+		{"fail_different_key", "add_user", "add_bunch", false},
+		{"fail_route_key_isnt_scoped", "modify_user", "modify_user:users:staff_role", false},
+		{"fail_other_scope", "add_bunch_to_user:bunches:*", "add_bunch_to_user:held", false},
+		{"fail_trailing_wildcard_needs_a_segment", "users:*", "users", false},
+		{"fail_inner_wildcard_is_one_segment", "billing:*:read", "billing:invoices:items:read", false},
+		{"fail_longer_key", "billing:invoices", "billing:invoices:read", false},
+		{"fail_wildcard_literal_in_key", "users:read", "users:*", false},
+		{"fail_other_application", "crm.contacts:*", "billing.contacts:read", false},
+		{"fail_wildcard_doesnt_cross_applications", "*", "crm.contacts:read", false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, c.matched, Match(c.pattern, c.key))
+		})
+	}
+}
+
+func TestAny(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, Any([]string{"add_user", "users:*"}, "users:read"))
+	require.False(t, Any([]string{"add_user", "users:*"}, "bunches:read"))
+	require.False(t, Any(nil, "users:read"))
+}
+
+func TestIsValid(t *testing.T) {
+	t.Parallel()
+
+	for _, key := range []string{"add_key", "users:*", "users:*:read", "billing.invoices:read", "billing.invoices:*"} {
+		require.True(t, IsValid(key), key)
+	}
+
+	// no key grants every other key
+	for _, key := range []string{"*", "*:read", "*:*", "billing.*", "Users", "users:", strings.Repeat("a", 66)} {
+		require.False(t, IsValid(key), key)
+	}
+
+	require.Equal(t, "billing", ApplicationOf("billing.invoices:read"))
+	require.Equal(t, "", ApplicationOf("users:read"))
+}
@@ -0,0 +1,14 @@
+package keymgr
+
+import (
+	"time"
+)
+
+// Application is a namespace of keys, tokens can be issued for one application only
+type Application struct {
+	ID        int64
+	Name      string
+	Desc      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -2,19 +2,26 @@ package keymgr
 
 import (
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymatch"
 	"regexp"
 	"strings"
 )
 
 type Storer interface {
-	AddKey(name string, desc string) (int64, error)
+	AddKey(name string, desc string, applicationID int64) (int64, error)
 	GetKeyByName(name string) (*Key, error)
 	GetKey(id int64) (*Key, error)
 	GetBunchID(name string) (int64, error)
 	ModifyKey(id int64, name string, desc string) error
 	AddKeyToBunch(keyID int64, bunchID int64) (int64, error)
-	QueryKeys(take int64, skip int64, name string, sortby string,
+	QueryKeys(take int64, skip int64, name string, application string, sortby string,
 		direction common.SortingDirection) ([]*Key, int64, error)
+	AddApplication(name string, desc string) (int64, error)
+	GetApplicationByName(name string) (*Application, error)
+	ModifyApplication(id int64, desc string) error
+	QueryApplications(take int64, skip int64, name string, sortby string,
+		direction common.SortingDirection) ([]*Application, int64, error)
+	WithTenant(tenantID int64) Storer
 }
 
 type Service interface {
@@ -23,7 +30,12 @@ type Service interface {
 	GetKey(id int64) (key *Key, err error)
 	GetKeyByName(name string) (key *Key, err error)
 	AddKeyToBunch(name string, bunch string) (int64, error)
-	QueryKeys(page int64, perPage int64, name string, order string) ([]*Key, int64, error)
+	QueryKeys(page int64, perPage int64, name string, application string, order string) ([]*Key, int64, error)
+	AddApplication(name string, desc string) (int64, error)
+	GetApplication(name string) (*Application, error)
+	ModifyApplication(lookup string, desc string) error
+	QueryApplications(page int64, perPage int64, name string, order string) ([]*Application, int64, error)
+	WithTenant(tenantID int64) Service
 }
 
 type service struct {
@@ -34,9 +46,18 @@ func NewService(st Storer) Service {
 	return &service{st}
 }
 
+// WithTenant returns the service working on keys of the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st.WithTenant(tenantID)}
+}
+
 func (s *service) AddKey(name string, desc string) (int64, error) {
+	invalid := new(common.ValidationError)
 	if !s.isValidKey(name) {
-		return 0, common.ErrKeyNameInvalid
+		invalid.Add("key", common.ErrKeyNameInvalid)
+	}
+	if err := invalid.Err(); err != nil {
+		return 0, err
 	}
 
 	existing, err := s.st.GetKeyByName(name)
@@ -47,7 +68,20 @@ func (s *service) AddKey(name string, desc string) (int64, error) {
 		return 0, common.ErrDuplicatedKey
 	}
 
-	return s.st.AddKey(name, desc)
+	// a qualified key goes into its application, which must be registered
+	var applicationID int64
+	if application := keymatch.ApplicationOf(name); len(application) > 0 {
+		app, err := s.st.GetApplicationByName(application)
+		if err != nil {
+			return 0, err
+		}
+		if app == nil {
+			return 0, common.ErrApplicationNotFound
+		}
+		applicationID = app.ID
+	}
+
+	return s.st.AddKey(name, desc, applicationID)
 }
 
 func (s *service) ModifyKey(id int64, name string, desc string) error {
@@ -59,12 +93,15 @@ func (s *service) ModifyKey(id int64, name string, desc string) error {
 		return common.ErrKeyNotFound
 	}
 
+	// keys can be renamed, but they can't move to another application
+	invalid := new(common.ValidationError)
 	if len(name) == 0 {
 		name = updating.Key
-	} else {
-		if !s.isValidKey(name) {
-			return common.ErrKeyNameInvalid
-		}
+	} else if !s.isValidKey(name) || keymatch.ApplicationOf(name) != keymatch.ApplicationOf(updating.Key) {
+		invalid.Add("key", common.ErrKeyNameInvalid)
+	}
+	if err := invalid.Err(); err != nil {
+		return err
 	}
 
 	if len(desc) == 0 {
@@ -101,6 +138,11 @@ func (s *service) AddKeyToBunch(name string, bunch string) (int64, error) {
 		return 0, common.ErrKeyNotFound
 	}
 
+	// keys stored before they were checked as they are now, e.g. "*", would grant every other key
+	if !s.isValidKey(key.Key) {
+		return 0, common.ErrKeyNameInvalid
+	}
+
 	bunchID, err := s.st.GetBunchID(bunch)
 	if err != nil {
 		return 0, err
@@ -112,35 +154,72 @@ func (s *service) AddKeyToBunch(name string, bunch string) (int64, error) {
 	return s.st.AddKeyToBunch(key.ID, bunchID)
 }
 
-func (s *service) QueryKeys(page int64, perPage int64, name string, order string) ([]*Key, int64, error) {
-	var (
-		sortby    string
-		direction common.SortingDirection
-		take      int64 = perPage
-		skip      int64 = perPage * (page - 1)
-	)
-
-	if len(order) > 0 {
-		switch order[0] {
-		case '+':
-			direction = common.Ascending
-			sortby = strings.TrimSpace(order[1:])
-			break
-		case '-':
-			direction = common.Descending
-			sortby = strings.TrimSpace(order[1:])
-			break
-		default:
-			direction = common.Descending
-			sortby = strings.TrimSpace(order)
-			break
-		}
-	} else {
-		sortby = "created_at"
-		direction = common.Descending
+func (s *service) QueryKeys(page int64, perPage int64, name string, application string,
+	order string) ([]*Key, int64, error) {
+
+	take, skip := perPage, perPage*(page-1)
+	sortby, direction := parseOrder(order)
+
+	return s.st.QueryKeys(take, skip, name, application, sortby, direction)
+}
+
+func (s *service) AddApplication(name string, desc string) (int64, error) {
+	if !s.isValidApplication(name) {
+		return 0, common.ErrApplicationNameInvalid
+	}
+
+	existing, err := s.st.GetApplicationByName(name)
+	if err != nil {
+		return 0, err
+	}
+	if existing != nil {
+		return 0, common.ErrDuplicatedApplication
+	}
+
+	return s.st.AddApplication(name, desc)
+}
+
+func (s *service) GetApplication(name string) (*Application, error) {
+	return s.st.GetApplicationByName(name)
+}
+
+// ModifyApplication changes application's description. Applications can't be renamed, their name
+// is part of their keys' names, which are held by issued tokens.
+func (s *service) ModifyApplication(lookup string, desc string) error {
+	updating, err := s.st.GetApplicationByName(lookup)
+	if err != nil {
+		return err
+	}
+	if updating == nil {
+		return common.ErrApplicationNotFound
+	}
+
+	return s.st.ModifyApplication(updating.ID, desc)
+}
+
+func (s *service) QueryApplications(page int64, perPage int64, name string,
+	order string) ([]*Application, int64, error) {
+
+	take, skip := perPage, perPage*(page-1)
+	sortby, direction := parseOrder(order)
+
+	return s.st.QueryApplications(take, skip, name, sortby, direction)
+}
+
+// parseOrder reads "+field" as ascending and "field" or "-field" as descending, by default newest come first
+func parseOrder(order string) (string, common.SortingDirection) {
+	if len(order) == 0 {
+		return "created_at", common.Descending
 	}
 
-	return s.st.QueryKeys(take, skip, name, sortby, direction)
+	switch order[0] {
+	case '+':
+		return strings.TrimSpace(order[1:]), common.Ascending
+	case '-':
+		return strings.TrimSpace(order[1:]), common.Descending
+	default:
+		return strings.TrimSpace(order), common.Descending
+	}
 }
 
 func (s *service) isDuplicatedKey(name string) (bool, error) {
@@ -151,7 +230,13 @@ func (s *service) isDuplicatedKey(name string) (bool, error) {
 	return existing != nil, nil
 }
 
+// isValidKey accepts keys outside of applications, e.g. "add_key", and qualified ones, e.g. "billing.read".
+// Keys may be made of segments and wildcards, e.g. "users:*" or "billing:invoices:read", see keymatch.
 func (s *service) isValidKey(name string) bool {
+	return keymatch.IsValid(name)
+}
+
+func (s *service) isValidApplication(name string) bool {
 	matched, err := regexp.Match(`^[a-z0-9_]{1,32}$`, []byte(name))
 	return err == nil && matched
 }
@@ -0,0 +1,143 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Manifest describes the wanted authorization model: keys, bunches with their keys and
+// bunches granted to users. Users have to exist already, a manifest only manages their grants.
+type Manifest struct {
+	Keys    []*Key   `yaml:"keys" json:"keys"`
+	Bunches []*Bunch `yaml:"bunches" json:"bunches"`
+	Users   []*User  `yaml:"users" json:"users"`
+}
+
+type Key struct {
+	Key  string `yaml:"key" json:"key"`
+	Desc string `yaml:"desc" json:"desc"`
+}
+
+type Bunch struct {
+	Name   string   `yaml:"name" json:"name"`
+	Desc   string   `yaml:"desc" json:"desc"`
+	Active *bool    `yaml:"active" json:"active"`
+	Keys   []string `yaml:"keys" json:"keys"`
+}
+
+type User struct {
+	Username string   `yaml:"username" json:"username"`
+	Bunches  []string `yaml:"bunches" json:"bunches"`
+}
+
+// State is the current authorization model read from storage
+type State struct {
+	Keys        map[string]string
+	Bunches     map[string]*Bunch
+	Users       map[string]bool
+	UserBunches map[string][]string
+	Exclusions  map[string][]string
+}
+
+// Change actions
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change kinds
+const (
+	KindKey       = "key"
+	KindBunch     = "bunch"
+	KindBunchKey  = "bunch_key"
+	KindUserBunch = "user_bunch"
+)
+
+// Change is one step of a plan. For relation kinds, Name is the bunch (or username) and
+// Target is the key (or bunch).
+type Change struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Target string `json:"target,omitempty"`
+	Desc   string `json:"desc,omitempty"`
+	Active bool   `json:"active"`
+}
+
+func (c *Change) String() string {
+	var sign string
+	switch c.Action {
+	case ActionCreate:
+		sign = "+"
+	case ActionUpdate:
+		sign = "~"
+	default:
+		sign = "-"
+	}
+
+	if len(c.Target) > 0 {
+		return fmt.Sprintf("%s %s %s -> %s", sign, c.Kind, c.Name, c.Target)
+	}
+	return fmt.Sprintf("%s %s %s", sign, c.Kind, c.Name)
+}
+
+// Plan is the list of changes which turns the current state into the manifest. Hash identifies the changes, a
+// plan can be applied only while it's still the same by giving its hash.
+type Plan struct {
+	Changes []*Change
+	Prune   bool
+	Hash    string
+}
+
+func newPlan(changes []*Change, prune bool) *Plan {
+	h := sha256.New()
+	fmt.Fprintf(h, "prune=%t\n", prune)
+	for _, c := range changes {
+		data, _ := json.Marshal(c)
+		h.Write(data)
+		h.Write([]byte("\n"))
+	}
+
+	return &Plan{changes, prune, hex.EncodeToString(h.Sum(nil))}
+}
+
+func (p *Plan) String() string {
+	if len(p.Changes) == 0 {
+		return "No changes. Storage is up to date with the manifest.\n"
+	}
+
+	var b strings.Builder
+	counts := make(map[string]int)
+	for _, c := range p.Changes {
+		b.WriteString(c.String())
+		b.WriteString("\n")
+		counts[c.Action]++
+	}
+	fmt.Fprintf(&b, "Plan: %d to create, %d to update, %d to delete.\n",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionDelete])
+	fmt.Fprintf(&b, "Plan hash: %s\n", p.Hash)
+
+	return b.String()
+}
+
+// Decode reads a manifest written in yaml or json
+func Decode(r io.Reader) (*Manifest, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(Manifest)
+	if err := yaml.UnmarshalStrict(data, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,319 @@
+package manifest
+
+import (
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymatch"
+	"github.com/vespaiach/auth/pkg/tenantmgr"
+	"regexp"
+	"sort"
+)
+
+type Storer interface {
+	GetState() (*State, error)
+
+	// Apply reads the state, and applies the changes which plan returns for it, in one transaction. Nothing is
+	// written when plan fails.
+	Apply(plan func(state *State) ([]*Change, error)) error
+
+	WithTenant(tenantID int64) Storer
+}
+
+type Service interface {
+	Plan(m *Manifest, prune bool) (*Plan, error)
+	Apply(m *Manifest, prune bool, hash string) (*Plan, error)
+	WithTenant(tenantID int64) Service
+}
+
+type service struct {
+	st Storer
+}
+
+func NewService(st Storer) Service {
+	return &service{st}
+}
+
+// WithTenant returns the service working on the authorization model of the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st.WithTenant(tenantID)}
+}
+
+// Plan computes changes without touching storage
+func (s *service) Plan(m *Manifest, prune bool) (*Plan, error) {
+	state, err := s.st.GetState()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.diff(m, state, prune)
+}
+
+// Apply computes changes on the state read in the transaction which applies them. When hash is given, it's
+// the hash of a plan which was reviewed, and nothing is applied unless the changes are still the same.
+func (s *service) Apply(m *Manifest, prune bool, hash string) (*Plan, error) {
+	var plan *Plan
+	err := s.st.Apply(func(state *State) ([]*Change, error) {
+		var err error
+		if plan, err = s.diff(m, state, prune); err != nil {
+			return nil, err
+		}
+		if len(hash) > 0 && hash != plan.Hash {
+			return nil, common.ErrManifestPlanChanged
+		}
+
+		return plan.Changes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// diff orders changes so that they can be applied one by one: creations and updates of keys and
+// bunches come first, then grants, then removals of grants, bunches and keys.
+func (s *service) diff(m *Manifest, state *State, prune bool) (*Plan, error) {
+	if err := s.validate(m, state, prune); err != nil {
+		return nil, err
+	}
+
+	var (
+		upserts  []*Change
+		grants   []*Change
+		revokes  []*Change
+		removals []*Change
+	)
+
+	wantedKeys := make(map[string]bool, len(m.Keys))
+	for _, k := range m.Keys {
+		wantedKeys[k.Key] = true
+		desc, ok := state.Keys[k.Key]
+		if !ok {
+			upserts = append(upserts, &Change{Action: ActionCreate, Kind: KindKey, Name: k.Key, Desc: k.Desc})
+		} else if desc != k.Desc {
+			upserts = append(upserts, &Change{Action: ActionUpdate, Kind: KindKey, Name: k.Key, Desc: k.Desc})
+		}
+	}
+
+	wantedBunches := make(map[string]bool, len(m.Bunches))
+	for _, b := range m.Bunches {
+		wantedBunches[b.Name] = true
+		active := b.Active == nil || *b.Active
+		current, ok := state.Bunches[b.Name]
+		if !ok {
+			upserts = append(upserts, &Change{Action: ActionCreate, Kind: KindBunch, Name: b.Name, Desc: b.Desc,
+				Active: active})
+		} else if current.Desc != b.Desc || (current.Active != nil && *current.Active != active) {
+			upserts = append(upserts, &Change{Action: ActionUpdate, Kind: KindBunch, Name: b.Name, Desc: b.Desc,
+				Active: active})
+		}
+
+		var currentKeys []string
+		if ok {
+			currentKeys = current.Keys
+		}
+		added, removed := difference(b.Keys, currentKeys)
+		for _, k := range added {
+			grants = append(grants, &Change{Action: ActionCreate, Kind: KindBunchKey, Name: b.Name, Target: k})
+		}
+		if prune && b.Name != tenantmgr.AdminBunch {
+			for _, k := range removed {
+				revokes = append(revokes, &Change{Action: ActionDelete, Kind: KindBunchKey, Name: b.Name, Target: k})
+			}
+		}
+	}
+
+	for _, u := range m.Users {
+		added, removed := difference(u.Bunches, state.UserBunches[u.Username])
+		for _, b := range added {
+			grants = append(grants, &Change{Action: ActionCreate, Kind: KindUserBunch, Name: u.Username, Target: b})
+		}
+		if prune {
+			for _, b := range removed {
+				if b == tenantmgr.AdminBunch {
+					continue
+				}
+				revokes = append(revokes, &Change{Action: ActionDelete, Kind: KindUserBunch, Name: u.Username,
+					Target: b})
+			}
+		}
+	}
+
+	if prune {
+		for _, name := range sortedBunches(state.Bunches) {
+			if !wantedBunches[name] && name != tenantmgr.AdminBunch {
+				removals = append(removals, &Change{Action: ActionDelete, Kind: KindBunch, Name: name})
+			}
+		}
+		for _, name := range sortedKeys(state.Keys) {
+			if !wantedKeys[name] && !isProtectedKey(state, name) {
+				removals = append(removals, &Change{Action: ActionDelete, Kind: KindKey, Name: name})
+			}
+		}
+	}
+
+	changes := make([]*Change, 0, len(upserts)+len(grants)+len(revokes)+len(removals))
+	changes = append(changes, upserts...)
+	changes = append(changes, grants...)
+	changes = append(changes, revokes...)
+	changes = append(changes, removals...)
+
+	return newPlan(changes, prune), nil
+}
+
+// isProtectedKey reports whether the key is held by the admin bunch. The admin bunch holds the keys of the
+// routes, pruning them or the admin bunch itself would lock administrators out, so they're left alone.
+func isProtectedKey(state *State, key string) bool {
+	admin, ok := state.Bunches[tenantmgr.AdminBunch]
+	if !ok {
+		return false
+	}
+
+	for _, k := range admin.Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *service) validate(m *Manifest, state *State, prune bool) error {
+	keys := make(map[string]bool)
+	for _, k := range m.Keys {
+		if !s.isValidKey(k.Key) {
+			return common.ErrKeyNameInvalid
+		}
+		if keys[k.Key] {
+			return common.ErrDuplicatedKey
+		}
+		keys[k.Key] = true
+	}
+	if !prune {
+		for k := range state.Keys {
+			keys[k] = true
+		}
+	}
+
+	bunches := make(map[string]bool)
+	for _, b := range m.Bunches {
+		if !s.isValidName(b.Name) {
+			return common.ErrBunchNameInvalid
+		}
+		if b.Name == tenantmgr.AdminBunch && b.Active != nil && !*b.Active {
+			return common.ErrAdminBunchProtected
+		}
+		if bunches[b.Name] {
+			return common.ErrDuplicatedBunch
+		}
+		bunches[b.Name] = true
+
+		for _, k := range b.Keys {
+			if !keys[k] {
+				return common.ErrKeyNotFound
+			}
+		}
+	}
+	if !prune {
+		for b := range state.Bunches {
+			bunches[b] = true
+		}
+	}
+
+	for _, u := range m.Users {
+		if !state.Users[u.Username] {
+			return common.ErrUserNotFound
+		}
+
+		held := make(map[string]bool)
+		for _, b := range u.Bunches {
+			if !bunches[b] {
+				return common.ErrBunchNotFound
+			}
+			held[b] = true
+		}
+		if !prune {
+			for _, b := range state.UserBunches[u.Username] {
+				held[b] = true
+			}
+		}
+
+		for _, name := range sortedStrings(state.Exclusions) {
+			conflicts := make([]string, 0)
+			for _, b := range state.Exclusions[name] {
+				if held[b] {
+					conflicts = append(conflicts, b)
+				}
+			}
+			if len(conflicts) > 1 {
+				return &common.ExclusionError{Exclusion: name, Bunches: conflicts}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isValidKey accepts keys made of segments and wildcards, e.g. "users:*", keys of applications aren't managed by
+// manifests
+func (s *service) isValidKey(name string) bool {
+	return keymatch.IsValid(name) && len(keymatch.ApplicationOf(name)) == 0
+}
+
+func (s *service) isValidName(name string) bool {
+	matched, err := regexp.Match(`^[a-z0-9_]{1,32}$`, []byte(name))
+	return err == nil && matched
+}
+
+// difference returns items which are wanted but don't exist and items which exist but aren't wanted
+func difference(wanted []string, current []string) (added []string, removed []string) {
+	cur := make(map[string]bool, len(current))
+	for _, c := range current {
+		cur[c] = true
+	}
+
+	want := make(map[string]bool, len(wanted))
+	for _, w := range wanted {
+		if !want[w] && !cur[w] {
+			added = append(added, w)
+		}
+		want[w] = true
+	}
+
+	for _, c := range current {
+		if !want[c] {
+			removed = append(removed, c)
+		}
+	}
+	sort.Strings(removed)
+
+	return
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sortedBunches(m map[string]*Bunch) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sortedStrings(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
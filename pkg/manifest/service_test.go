@@ -0,0 +1,205 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/tenantmgr"
+)
+
+// store is an in-memory Storer, Apply records the changes which the plan returns
+type store struct {
+	state   *State
+	applied []*Change
+}
+
+func (st *store) GetState() (*State, error) {
+	return st.state, nil
+}
+
+func (st *store) Apply(plan func(state *State) ([]*Change, error)) error {
+	changes, err := plan(st.state)
+	if err != nil {
+		return err
+	}
+	st.applied = append(st.applied, changes...)
+	return nil
+}
+
+func (st *store) WithTenant(tenantID int64) Storer {
+	return st
+}
+
+func newState() *State {
+	active := true
+	return &State{
+		Keys: map[string]string{"get_user": "", "modify_user": "", "report": "", "legacy": ""},
+		Bunches: map[string]*Bunch{
+			tenantmgr.AdminBunch: {Name: tenantmgr.AdminBunch, Active: &active,
+				Keys: []string{"get_user", "modify_user"}},
+			"staff_role": {Name: "staff_role", Active: &active, Keys: []string{"get_user", "report"}},
+			"old_role":   {Name: "old_role", Active: &active},
+		},
+		Users:       map[string]bool{"admin": true, "clerk": true},
+		UserBunches: map[string][]string{"admin": {tenantmgr.AdminBunch}, "clerk": {"staff_role"}},
+		Exclusions:  map[string][]string{"sod": {"staff_role", "audit_role"}},
+	}
+}
+
+func changeStrings(changes []*Change) []string {
+	lst := make([]string, 0, len(changes))
+	for _, c := range changes {
+		lst = append(lst, c.String())
+	}
+	return lst
+}
+
+func TestService_Plan(t *testing.T) {
+	s := NewService(&store{state: newState()})
+
+	plan, err := s.Plan(&Manifest{
+		Keys:    []*Key{{Key: "get_user"}, {Key: "report", Desc: "Reports"}, {Key: "export"}},
+		Bunches: []*Bunch{{Name: "staff_role", Keys: []string{"get_user", "export"}}},
+		Users:   []*User{{Username: "clerk", Bunches: []string{"staff_role", "old_role"}}},
+	}, false)
+	require.Nil(t, err)
+
+	// creations and updates come first, then grants, nothing is removed without prune
+	require.Equal(t, []string{
+		"~ key report",
+		"+ key export",
+		"+ bunch_key staff_role -> export",
+		"+ user_bunch clerk -> old_role",
+	}, changeStrings(plan.Changes))
+	require.Len(t, plan.Hash, 64)
+	require.True(t, strings.HasSuffix(plan.String(), "Plan hash: "+plan.Hash+"\n"))
+}
+
+func TestService_PlanPrune(t *testing.T) {
+	s := NewService(&store{state: newState()})
+
+	plan, err := s.Plan(&Manifest{
+		Keys:    []*Key{{Key: "get_user"}},
+		Bunches: []*Bunch{{Name: "staff_role", Keys: []string{"get_user"}}},
+		Users:   []*User{{Username: "admin", Bunches: []string{"staff_role"}}},
+	}, true)
+	require.Nil(t, err)
+
+	// the admin bunch, its keys and its grants are left alone
+	require.Equal(t, []string{
+		"+ user_bunch admin -> staff_role",
+		"- bunch_key staff_role -> report",
+		"- bunch old_role",
+		"- key legacy",
+		"- key report",
+	}, changeStrings(plan.Changes))
+
+	// pruning the admin bunch's keys away is left alone as well
+	plan, err = s.Plan(&Manifest{
+		Keys:    []*Key{{Key: "get_user"}, {Key: "modify_user"}, {Key: "report"}, {Key: "legacy"}},
+		Bunches: []*Bunch{{Name: tenantmgr.AdminBunch, Keys: []string{"get_user"}}},
+	}, true)
+	require.Nil(t, err)
+	require.Equal(t, []string{"- bunch old_role", "- bunch staff_role"}, changeStrings(plan.Changes))
+}
+
+func TestService_PlanInvalid(t *testing.T) {
+	s := NewService(&store{state: newState()})
+	inactive := false
+
+	tests := []struct {
+		name string
+		m    *Manifest
+		err  error
+	}{
+		{"key_invalid", &Manifest{Keys: []*Key{{Key: "Get User"}}}, common.ErrKeyNameInvalid},
+		{"key_wildcard", &Manifest{Keys: []*Key{{Key: "*"}}}, common.ErrKeyNameInvalid},
+		{"key_duplicated", &Manifest{Keys: []*Key{{Key: "export"}, {Key: "export"}}}, common.ErrDuplicatedKey},
+		{"bunch_invalid", &Manifest{Bunches: []*Bunch{{Name: "Staff Role"}}}, common.ErrBunchNameInvalid},
+		{"bunch_key_missing", &Manifest{Bunches: []*Bunch{{Name: "sales", Keys: []string{"sell"}}}},
+			common.ErrKeyNotFound},
+		{"user_missing", &Manifest{Users: []*User{{Username: "nobody"}}}, common.ErrUserNotFound},
+		{"user_bunch_missing", &Manifest{Users: []*User{{Username: "clerk", Bunches: []string{"sales"}}}},
+			common.ErrBunchNotFound},
+		{"admin_bunch_deactivated", &Manifest{Bunches: []*Bunch{{Name: tenantmgr.AdminBunch, Active: &inactive}}},
+			common.ErrAdminBunchProtected},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := s.Plan(test.m, false)
+			require.Equal(t, test.err, err)
+		})
+	}
+
+	// bunches of an exclusion can't be held together
+	_, err := s.Plan(&Manifest{
+		Bunches: []*Bunch{{Name: "audit_role"}},
+		Users:   []*User{{Username: "clerk", Bunches: []string{"audit_role"}}},
+	}, false)
+	require.IsType(t, &common.ExclusionError{}, err)
+
+	// unless the other bunch is pruned from the user
+	_, err = s.Plan(&Manifest{
+		Keys:    []*Key{{Key: "get_user"}, {Key: "report"}},
+		Bunches: []*Bunch{{Name: "audit_role"}, {Name: "staff_role", Keys: []string{"get_user", "report"}}},
+		Users:   []*User{{Username: "clerk", Bunches: []string{"audit_role"}}},
+	}, true)
+	require.Nil(t, err)
+}
+
+func TestService_Apply(t *testing.T) {
+	st := &store{state: newState()}
+	s := NewService(st)
+	m := &Manifest{Keys: []*Key{{Key: "export"}}}
+
+	reviewed, err := s.Plan(m, false)
+	require.Nil(t, err)
+
+	plan, err := s.Apply(m, false, reviewed.Hash)
+	require.Nil(t, err)
+	require.Equal(t, reviewed.Hash, plan.Hash)
+	require.Equal(t, []string{"+ key export"}, changeStrings(st.applied))
+
+	// the state has changed since the plan was reviewed, nothing is applied
+	st.applied = nil
+	st.state.Keys["export"] = ""
+
+	_, err = s.Apply(m, false, reviewed.Hash)
+	require.Equal(t, common.ErrManifestPlanChanged, err)
+	require.Len(t, st.applied, 0)
+
+	// without a hash the plan of the current state is applied
+	plan, err = s.Apply(&Manifest{Keys: []*Key{{Key: "export", Desc: "Export"}}}, false, "")
+	require.Nil(t, err)
+	require.Equal(t, []string{"~ key export"}, changeStrings(st.applied))
+	require.NotEqual(t, reviewed.Hash, plan.Hash)
+}
+
+func TestDecode(t *testing.T) {
+	m, err := Decode(strings.NewReader(`
+keys:
+  - key: get_user
+bunches:
+  - name: staff_role
+    keys: [get_user]
+users:
+  - username: clerk
+    bunches: [staff_role]
+`))
+	require.Nil(t, err)
+	require.Equal(t, "get_user", m.Keys[0].Key)
+	require.Equal(t, []string{"get_user"}, m.Bunches[0].Keys)
+	require.Equal(t, []string{"staff_role"}, m.Users[0].Bunches)
+
+	// json is yaml as well
+	m, err = Decode(strings.NewReader(`{"keys": [{"key": "get_user"}]}`))
+	require.Nil(t, err)
+	require.Equal(t, "get_user", m.Keys[0].Key)
+
+	// unknown fields are refused rather than ignored
+	_, err = Decode(strings.NewReader(`bunches: [{name: staff_role, key: [get_user]}]`))
+	require.NotNil(t, err)
+}
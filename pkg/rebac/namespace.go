@@ -0,0 +1,91 @@
+package rebac
+
+// Namespace configures relations of a namespace's objects. A relation without rewrite only holds its own tuples.
+//
+//	{
+//	  "relations": {
+//	    "owner": {},
+//	    "editor": {"union": [{"this": true}, {"computed_userset": "owner"}]},
+//	    "viewer": {"union": [
+//	      {"this": true},
+//	      {"computed_userset": "editor"},
+//	      {"tuple_to_userset": {"tupleset": "parent", "computed_userset": "viewer"}}
+//	    ]}
+//	  }
+//	}
+type Namespace struct {
+	Name      string               `json:"name"`
+	Relations map[string]*Relation `json:"relations"`
+}
+
+// Relation holds subjects of any of its usersets
+type Relation struct {
+	Union []*Userset `json:"union,omitempty"`
+}
+
+// Userset is one of
+//   - this: subjects of the relation's own tuples
+//   - computed_userset: subjects of another relation of the same object
+//   - tuple_to_userset: subjects of a relation of the objects which the tupleset relation of the object points to
+type Userset struct {
+	This            bool            `json:"this,omitempty"`
+	ComputedUserset string          `json:"computed_userset,omitempty"`
+	TupleToUserset  *TupleToUserset `json:"tuple_to_userset,omitempty"`
+}
+
+type TupleToUserset struct {
+	Tupleset        string `json:"tupleset"`
+	ComputedUserset string `json:"computed_userset"`
+}
+
+// usersets returns usersets of the relation, a relation without rewrite is its own tuples
+func (r *Relation) usersets() []*Userset {
+	if len(r.Union) == 0 {
+		return []*Userset{{This: true}}
+	}
+	return r.Union
+}
+
+// validate checks that every userset is one thing and refers to relations of the namespace
+func (n *Namespace) validate() bool {
+	if !nameReg.MatchString(n.Name) || len(n.Relations) == 0 {
+		return false
+	}
+
+	for name, r := range n.Relations {
+		if !nameReg.MatchString(name) || r == nil {
+			return false
+		}
+		for _, u := range r.Union {
+			if u == nil {
+				return false
+			}
+
+			kinds := 0
+			if u.This {
+				kinds++
+			}
+			if len(u.ComputedUserset) > 0 {
+				kinds++
+				if _, ok := n.Relations[u.ComputedUserset]; !ok {
+					return false
+				}
+			}
+			if u.TupleToUserset != nil {
+				kinds++
+				if _, ok := n.Relations[u.TupleToUserset.Tupleset]; !ok {
+					return false
+				}
+				// the computed relation belongs to the pointed objects' namespace, which may be another one
+				if !nameReg.MatchString(u.TupleToUserset.ComputedUserset) {
+					return false
+				}
+			}
+			if kinds != 1 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,411 @@
+package rebac
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/vespaiach/auth/pkg/common"
+	"sort"
+	"strconv"
+)
+
+// maxDepth bounds how many relations a check or an expansion follows
+const maxDepth = 25
+
+type Storer interface {
+	SaveNamespace(name string, config string) error
+	GetNamespace(name string) (string, error)
+	WriteTuples(adds []*Tuple, removes []*Tuple) (int64, error)
+	GetRevision() (int64, error)
+	GetSubjects(object Object, relation string, revision int64) ([]Subject, error)
+	GetObjectIDs(namespace string, revision int64) ([]string, error)
+	WithTenant(tenantID int64) Storer
+}
+
+// Service stores relation tuples and answers questions about them. Every answer comes with a consistency token
+// naming the snapshot it was read from, passing the token back makes sure a later answer isn't read from an older
+// snapshot, e.g. a check made after a write sees the write.
+type Service interface {
+	WriteNamespace(ns *Namespace) error
+	GetNamespace(name string) (*Namespace, error)
+	WriteTuples(adds []string, removes []string) (string, error)
+	Check(tuple string, token string) (bool, string, error)
+	Expand(object string, relation string, token string) (*Tree, string, error)
+	ListObjects(namespace string, relation string, user string, token string) ([]string, string, error)
+	WithTenant(tenantID int64) Service
+}
+
+// Tree is the expansion of a relation of an object: its users and the expansions of the usersets it includes
+type Tree struct {
+	Object   string
+	Relation string
+	Users    []string
+	Children []*Tree
+}
+
+type service struct {
+	st Storer
+}
+
+func NewService(st Storer) Service {
+	return &service{st}
+}
+
+// WithTenant returns the service working on tuples of the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st.WithTenant(tenantID)}
+}
+
+func (s *service) WriteNamespace(ns *Namespace) error {
+	if ns == nil || !ns.validate() {
+		return common.ErrNamespaceInvalid
+	}
+
+	config, err := json.Marshal(ns)
+	if err != nil {
+		return err
+	}
+
+	return s.st.SaveNamespace(ns.Name, string(config))
+}
+
+func (s *service) GetNamespace(name string) (*Namespace, error) {
+	config, err := s.st.GetNamespace(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(config) == 0 {
+		return nil, common.ErrNamespaceNotFound
+	}
+
+	ns := new(Namespace)
+	if err := json.Unmarshal([]byte(config), ns); err != nil {
+		return nil, err
+	}
+
+	return ns, nil
+}
+
+// WriteTuples adds and removes tuples at once, tuples must belong to configured namespaces and relations
+func (s *service) WriteTuples(adds []string, removes []string) (string, error) {
+	if len(adds) == 0 && len(removes) == 0 {
+		return "", common.ErrTupleInvalid
+	}
+
+	e := s.evaluator(0)
+
+	parse := func(lst []string) ([]*Tuple, error) {
+		tuples := make([]*Tuple, 0, len(lst))
+		for _, raw := range lst {
+			t, ok := ParseTuple(raw)
+			if !ok {
+				return nil, common.ErrTupleInvalid
+			}
+			if _, err := e.relation(t.Object.Namespace, t.Relation); err != nil {
+				return nil, err
+			}
+			tuples = append(tuples, t)
+		}
+		return tuples, nil
+	}
+
+	addings, err := parse(adds)
+	if err != nil {
+		return "", err
+	}
+	removings, err := parse(removes)
+	if err != nil {
+		return "", err
+	}
+
+	rev, err := s.st.WriteTuples(addings, removings)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeToken(rev), nil
+}
+
+// Check tells whether the user, the tuple's subject, has the tuple's relation to its object
+func (s *service) Check(tuple string, token string) (bool, string, error) {
+	t, ok := ParseTuple(tuple)
+	if !ok || !t.Subject.IsUser() {
+		return false, "", common.ErrTupleInvalid
+	}
+
+	rev, err := s.snapshot(token)
+	if err != nil {
+		return false, "", err
+	}
+
+	allowed, err := s.evaluator(rev).check(t.Object, t.Relation, t.Subject.User, 0)
+	if err != nil {
+		return false, "", err
+	}
+
+	return allowed, encodeToken(rev), nil
+}
+
+func (s *service) Expand(object string, relation string, token string) (*Tree, string, error) {
+	o, ok := ParseObject(object)
+	if !ok || !nameReg.MatchString(relation) {
+		return nil, "", common.ErrTupleInvalid
+	}
+
+	rev, err := s.snapshot(token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tree, err := s.evaluator(rev).expand(o, relation, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return tree, encodeToken(rev), nil
+}
+
+// ListObjects returns ids of the namespace's objects which the user has the relation to. Every object of the
+// namespace is checked, it's meant for namespaces of modest size.
+func (s *service) ListObjects(namespace string, relation string, user string, token string) ([]string, string, error) {
+	if !nameReg.MatchString(namespace) || !nameReg.MatchString(relation) || !userReg.MatchString(user) {
+		return nil, "", common.ErrTupleInvalid
+	}
+
+	rev, err := s.snapshot(token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	e := s.evaluator(rev)
+	if _, err := e.relation(namespace, relation); err != nil {
+		return nil, "", err
+	}
+
+	ids, err := s.st.GetObjectIDs(namespace, rev)
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := make([]string, 0)
+	for _, id := range ids {
+		allowed, err := e.check(Object{namespace, id}, relation, user, 0)
+		if err != nil {
+			return nil, "", err
+		}
+		if allowed {
+			results = append(results, id)
+		}
+	}
+	sort.Strings(results)
+
+	return results, encodeToken(rev), nil
+}
+
+// snapshot returns the revision to read at, the latest one, which is never older than the token's
+func (s *service) snapshot(token string) (int64, error) {
+	rev, err := s.st.GetRevision()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(token) > 0 {
+		at, ok := decodeToken(token)
+		if !ok || at > rev {
+			return 0, common.ErrConsistencyTokenInvalid
+		}
+	}
+
+	return rev, nil
+}
+
+func encodeToken(rev int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(rev, 10)))
+}
+
+func decodeToken(token string) (int64, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, false
+	}
+
+	rev, err := strconv.ParseInt(string(raw), 10, 64)
+	return rev, err == nil && rev >= 0
+}
+
+// evaluator reads tuples at one revision, it caches namespaces and checks made while answering one question
+type evaluator struct {
+	s          *service
+	rev        int64
+	namespaces map[string]*Namespace
+	checks     map[string]bool
+	visiting   map[string]bool
+}
+
+func (s *service) evaluator(rev int64) *evaluator {
+	return &evaluator{s, rev, make(map[string]*Namespace), make(map[string]bool), make(map[string]bool)}
+}
+
+func (e *evaluator) relation(namespace string, relation string) (*Relation, error) {
+	ns, ok := e.namespaces[namespace]
+	if !ok {
+		var err error
+		if ns, err = e.s.GetNamespace(namespace); err != nil {
+			return nil, err
+		}
+		e.namespaces[namespace] = ns
+	}
+
+	r, ok := ns.Relations[relation]
+	if !ok {
+		return nil, common.ErrRelationNotFound
+	}
+
+	return r, nil
+}
+
+func (e *evaluator) check(o Object, relation string, user string, depth int) (bool, error) {
+	if depth > maxDepth {
+		return false, common.ErrRelationTooDeep
+	}
+
+	// a check which is already being made doesn't grant more than the others, this cuts cycles. A denial found
+	// below a cut cycle may not hold on its own so only granted checks and top level denials are remembered.
+	memo := o.String() + "#" + relation
+	if allowed, ok := e.checks[memo]; ok {
+		return allowed, nil
+	}
+	if e.visiting[memo] {
+		return false, nil
+	}
+	e.visiting[memo] = true
+	defer delete(e.visiting, memo)
+
+	r, err := e.relation(o.Namespace, relation)
+	if err != nil {
+		return false, err
+	}
+
+	for _, u := range r.usersets() {
+		var allowed bool
+
+		switch {
+		case u.This:
+			subjects, err := e.s.st.GetSubjects(o, relation, e.rev)
+			if err != nil {
+				return false, err
+			}
+			for _, sub := range subjects {
+				if sub.IsUser() {
+					allowed = sub.User == user
+				} else if len(sub.Relation) > 0 {
+					allowed, err = e.check(sub.Object, sub.Relation, user, depth+1)
+				}
+				if err != nil {
+					return false, err
+				}
+				if allowed {
+					break
+				}
+			}
+
+		case len(u.ComputedUserset) > 0:
+			allowed, err = e.check(o, u.ComputedUserset, user, depth+1)
+
+		case u.TupleToUserset != nil:
+			subjects, err := e.s.st.GetSubjects(o, u.TupleToUserset.Tupleset, e.rev)
+			if err != nil {
+				return false, err
+			}
+			for _, sub := range subjects {
+				if sub.IsUser() {
+					continue
+				}
+				allowed, err = e.check(sub.Object, u.TupleToUserset.ComputedUserset, user, depth+1)
+				if err != nil {
+					return false, err
+				}
+				if allowed {
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			return false, err
+		}
+		if allowed {
+			e.checks[memo] = true
+			return true, nil
+		}
+	}
+
+	if depth == 0 {
+		e.checks[memo] = false
+	}
+
+	return false, nil
+}
+
+func (e *evaluator) expand(o Object, relation string, depth int) (*Tree, error) {
+	if depth > maxDepth {
+		return nil, common.ErrRelationTooDeep
+	}
+
+	r, err := e.relation(o.Namespace, relation)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &Tree{Object: o.String(), Relation: relation, Users: make([]string, 0), Children: make([]*Tree, 0)}
+
+	for _, u := range r.usersets() {
+		switch {
+		case u.This:
+			subjects, err := e.s.st.GetSubjects(o, relation, e.rev)
+			if err != nil {
+				return nil, err
+			}
+			for _, sub := range subjects {
+				if sub.IsUser() {
+					tree.Users = append(tree.Users, sub.User)
+					continue
+				}
+				if len(sub.Relation) == 0 {
+					continue
+				}
+				child, err := e.expand(sub.Object, sub.Relation, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				tree.Children = append(tree.Children, child)
+			}
+
+		case len(u.ComputedUserset) > 0:
+			child, err := e.expand(o, u.ComputedUserset, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			tree.Children = append(tree.Children, child)
+
+		case u.TupleToUserset != nil:
+			subjects, err := e.s.st.GetSubjects(o, u.TupleToUserset.Tupleset, e.rev)
+			if err != nil {
+				return nil, err
+			}
+			for _, sub := range subjects {
+				if sub.IsUser() {
+					continue
+				}
+				child, err := e.expand(sub.Object, u.TupleToUserset.ComputedUserset, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				tree.Children = append(tree.Children, child)
+			}
+		}
+	}
+
+	sort.Strings(tree.Users)
+
+	return tree, nil
+}
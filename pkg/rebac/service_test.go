@@ -0,0 +1,256 @@
+package rebac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+// tuples is an in-memory Storer keeping every tuple with the revisions it lived between
+type tuples struct {
+	namespaces map[string]string
+	rows       []*row
+	rev        int64
+}
+
+type row struct {
+	tuple   *Tuple
+	created int64
+	deleted int64
+}
+
+func (r *row) livesAt(rev int64) bool {
+	return r.created <= rev && (r.deleted == 0 || r.deleted > rev)
+}
+
+func newTuples() *tuples {
+	return &tuples{namespaces: make(map[string]string)}
+}
+
+func (st *tuples) SaveNamespace(name string, config string) error {
+	st.namespaces[name] = config
+	return nil
+}
+
+func (st *tuples) GetNamespace(name string) (string, error) {
+	return st.namespaces[name], nil
+}
+
+func (st *tuples) WriteTuples(adds []*Tuple, removes []*Tuple) (int64, error) {
+	st.rev++
+	for _, t := range removes {
+		for _, r := range st.rows {
+			if r.deleted == 0 && r.tuple.String() == t.String() {
+				r.deleted = st.rev
+			}
+		}
+	}
+	for _, t := range adds {
+		st.rows = append(st.rows, &row{t, st.rev, 0})
+	}
+	return st.rev, nil
+}
+
+func (st *tuples) GetRevision() (int64, error) {
+	return st.rev, nil
+}
+
+func (st *tuples) GetSubjects(object Object, relation string, revision int64) ([]Subject, error) {
+	subjects := make([]Subject, 0)
+	for _, r := range st.rows {
+		if r.livesAt(revision) && r.tuple.Object == object && r.tuple.Relation == relation {
+			subjects = append(subjects, r.tuple.Subject)
+		}
+	}
+	return subjects, nil
+}
+
+func (st *tuples) GetObjectIDs(namespace string, revision int64) ([]string, error) {
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, r := range st.rows {
+		if r.livesAt(revision) && r.tuple.Object.Namespace == namespace && !seen[r.tuple.Object.ID] {
+			seen[r.tuple.Object.ID] = true
+			ids = append(ids, r.tuple.Object.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (st *tuples) WithTenant(tenantID int64) Storer {
+	return st
+}
+
+// newDocs returns a service knowing groups, folders and docs whose viewers include editors and viewers of the
+// parent folder
+func newDocs(t *testing.T) Service {
+	s := NewService(newTuples())
+
+	require.Nil(t, s.WriteNamespace(&Namespace{
+		Name:      "group",
+		Relations: map[string]*Relation{"member": {}},
+	}))
+	require.Nil(t, s.WriteNamespace(&Namespace{
+		Name: "folder",
+		Relations: map[string]*Relation{
+			"parent": {},
+			"viewer": {Union: []*Userset{
+				{This: true},
+				{TupleToUserset: &TupleToUserset{Tupleset: "parent", ComputedUserset: "viewer"}},
+			}},
+		},
+	}))
+	require.Nil(t, s.WriteNamespace(&Namespace{
+		Name: "doc",
+		Relations: map[string]*Relation{
+			"parent": {},
+			"owner":  {},
+			"editor": {Union: []*Userset{{This: true}, {ComputedUserset: "owner"}}},
+			"viewer": {Union: []*Userset{
+				{This: true},
+				{ComputedUserset: "editor"},
+				{TupleToUserset: &TupleToUserset{Tupleset: "parent", ComputedUserset: "viewer"}},
+			}},
+		},
+	}))
+
+	return s
+}
+
+func TestService_Check(t *testing.T) {
+	s := newDocs(t)
+
+	token, err := s.WriteTuples([]string{
+		"doc:1#owner@alice",
+		"doc:1#parent@folder:a",
+		"folder:a#parent@folder:root",
+		"folder:root#viewer@group:eng#member",
+		"group:eng#member@bob",
+	}, nil)
+	require.Nil(t, err)
+
+	tests := []struct {
+		tuple   string
+		allowed bool
+	}{
+		{"doc:1#owner@alice", true},
+		{"doc:1#viewer@alice", true},
+		{"doc:1#editor@bob", false},
+		{"doc:1#viewer@bob", true},
+		{"doc:1#viewer@carol", false},
+		{"folder:a#viewer@bob", true},
+	}
+
+	for _, tt := range tests {
+		allowed, _, err := s.Check(tt.tuple, token)
+		require.Nil(t, err, tt.tuple)
+		require.Equal(t, tt.allowed, allowed, tt.tuple)
+	}
+
+	_, _, err = s.Check("doc:1#viewer@group:eng#member", token)
+	require.Equal(t, common.ErrTupleInvalid, err)
+
+	_, _, err = s.Check("doc:1#reader@alice", token)
+	require.Equal(t, common.ErrRelationNotFound, err)
+}
+
+func TestService_Check_Cycle(t *testing.T) {
+	s := newDocs(t)
+
+	token, err := s.WriteTuples([]string{
+		"group:a#member@group:b#member",
+		"group:b#member@group:a#member",
+		"group:b#member@alice",
+	}, nil)
+	require.Nil(t, err)
+
+	allowed, _, err := s.Check("group:a#member@alice", token)
+	require.Nil(t, err)
+	require.True(t, allowed)
+
+	allowed, _, err = s.Check("group:a#member@bob", token)
+	require.Nil(t, err)
+	require.False(t, allowed)
+}
+
+func TestService_ConsistencyToken(t *testing.T) {
+	s := newDocs(t)
+
+	first, err := s.WriteTuples([]string{"doc:1#viewer@alice"}, nil)
+	require.Nil(t, err)
+
+	second, err := s.WriteTuples(nil, []string{"doc:1#viewer@alice"})
+	require.Nil(t, err)
+
+	// answers are read from the latest snapshot, which is never older than the token's
+	allowed, token, err := s.Check("doc:1#viewer@alice", first)
+	require.Nil(t, err)
+	require.False(t, allowed)
+	require.Equal(t, second, token)
+
+	_, _, err = s.Check("doc:1#viewer@alice", encodeToken(100))
+	require.Equal(t, common.ErrConsistencyTokenInvalid, err)
+
+	_, _, err = s.Check("doc:1#viewer@alice", "not a token")
+	require.Equal(t, common.ErrConsistencyTokenInvalid, err)
+}
+
+func TestService_Expand(t *testing.T) {
+	s := newDocs(t)
+
+	token, err := s.WriteTuples([]string{
+		"doc:1#owner@alice",
+		"doc:1#viewer@carol",
+		"doc:1#viewer@group:eng#member",
+		"group:eng#member@bob",
+	}, nil)
+	require.Nil(t, err)
+
+	tree, _, err := s.Expand("doc:1", "viewer", token)
+	require.Nil(t, err)
+	require.Equal(t, []string{"carol"}, tree.Users)
+	require.Len(t, tree.Children, 2)
+	require.Equal(t, "group:eng", tree.Children[0].Object)
+	require.Equal(t, []string{"bob"}, tree.Children[0].Users)
+	require.Equal(t, "editor", tree.Children[1].Relation)
+	require.Equal(t, []string{"alice"}, tree.Children[1].Children[0].Users)
+}
+
+func TestService_ListObjects(t *testing.T) {
+	s := newDocs(t)
+
+	token, err := s.WriteTuples([]string{
+		"doc:1#owner@alice",
+		"doc:2#parent@folder:a",
+		"doc:3#viewer@bob",
+		"folder:a#viewer@alice",
+	}, nil)
+	require.Nil(t, err)
+
+	ids, _, err := s.ListObjects("doc", "viewer", "alice", token)
+	require.Nil(t, err)
+	require.Equal(t, []string{"1", "2"}, ids)
+
+	_, _, err = s.ListObjects("note", "viewer", "alice", token)
+	require.Equal(t, common.ErrNamespaceNotFound, err)
+}
+
+func TestService_WriteNamespace(t *testing.T) {
+	s := NewService(newTuples())
+
+	err := s.WriteNamespace(&Namespace{
+		Name:      "doc",
+		Relations: map[string]*Relation{"viewer": {Union: []*Userset{{ComputedUserset: "editor"}}}},
+	})
+	require.Equal(t, common.ErrNamespaceInvalid, err)
+
+	err = s.WriteNamespace(&Namespace{
+		Name:      "doc",
+		Relations: map[string]*Relation{"viewer": {Union: []*Userset{{This: true, ComputedUserset: "viewer"}}}},
+	})
+	require.Equal(t, common.ErrNamespaceInvalid, err)
+
+	_, err = s.WriteTuples([]string{"doc:1#viewer@alice"}, nil)
+	require.Equal(t, common.ErrNamespaceNotFound, err)
+}
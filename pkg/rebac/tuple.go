@@ -0,0 +1,111 @@
+package rebac
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	nameReg     = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+	objectIDReg = regexp.MustCompile(`^[a-zA-Z0-9_\-.]{1,64}$`)
+	userReg     = regexp.MustCompile(`^[a-zA-Z0-9_]{1,32}$`)
+)
+
+// Object is an object of a namespace, written as "doc:42"
+type Object struct {
+	Namespace string
+	ID        string
+}
+
+func (o Object) String() string {
+	return o.Namespace + ":" + o.ID
+}
+
+// Subject is either a user, written as "alice", an object, written as "folder:1", or the set of subjects having
+// a relation to an object, written as "group:eng#member". Objects as subjects are followed by tuple to userset
+// rewrites, e.g. "doc:42#parent@folder:1".
+type Subject struct {
+	User     string
+	Object   Object
+	Relation string
+}
+
+// IsUser tells whether the subject is a single user
+func (s Subject) IsUser() bool {
+	return len(s.User) > 0
+}
+
+func (s Subject) String() string {
+	if s.IsUser() {
+		return s.User
+	}
+	if len(s.Relation) == 0 {
+		return s.Object.String()
+	}
+	return s.Object.String() + "#" + s.Relation
+}
+
+// Tuple is a relation tuple, written as "object#relation@subject", e.g. "doc:42#editor@alice"
+type Tuple struct {
+	Object   Object
+	Relation string
+	Subject  Subject
+}
+
+func (t *Tuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+// ParseTuple reads a tuple written as "object#relation@subject"
+func ParseTuple(s string) (*Tuple, bool) {
+	at := strings.Index(s, "@")
+	if at < 0 {
+		return nil, false
+	}
+
+	object, relation, ok := parseObjectRelation(s[:at])
+	if !ok || len(relation) == 0 {
+		return nil, false
+	}
+
+	subject, ok := ParseSubject(s[at+1:])
+	if !ok {
+		return nil, false
+	}
+
+	return &Tuple{object, relation, subject}, true
+}
+
+// ParseSubject reads a subject written as "user", "namespace:id" or "namespace:id#relation"
+func ParseSubject(s string) (Subject, bool) {
+	if !strings.Contains(s, ":") {
+		return Subject{User: s}, userReg.MatchString(s)
+	}
+
+	object, relation, ok := parseObjectRelation(s)
+	return Subject{Object: object, Relation: relation}, ok
+}
+
+// ParseObject reads an object written as "namespace:id"
+func ParseObject(s string) (Object, bool) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return Object{}, false
+	}
+
+	o := Object{s[:i], s[i+1:]}
+	return o, nameReg.MatchString(o.Namespace) && objectIDReg.MatchString(o.ID)
+}
+
+func parseObjectRelation(s string) (Object, string, bool) {
+	relation := ""
+	if i := strings.Index(s, "#"); i >= 0 {
+		s, relation = s[:i], s[i+1:]
+		if !nameReg.MatchString(relation) {
+			return Object{}, "", false
+		}
+	}
+
+	o, ok := ParseObject(s)
+	return o, relation, ok
+}
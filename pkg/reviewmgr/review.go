@@ -0,0 +1,56 @@
+package reviewmgr
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Campaign scopes
+const (
+	ScopeBunch = "bunch"
+	ScopeKey   = "key"
+)
+
+// Campaign statuses
+const (
+	StatusOpen   = "open"
+	StatusClosed = "closed"
+)
+
+// Review decisions
+const (
+	DecisionPending   = "pending"
+	DecisionCertified = "certified"
+	DecisionRevoked   = "revoked"
+)
+
+type Campaign struct {
+	ID        int64
+	Name      string
+	Desc      string
+	Scope     string
+	Targets   []string
+	Status    string
+	CreatedAt time.Time
+	ClosedAt  sql.NullTime
+}
+
+// Item is a snapshot of one user_bunches grant which has to be reviewed
+type Item struct {
+	ID         int64
+	CampaignID int64
+	UserID     int64
+	Username   string
+	BunchID    int64
+	Bunch      string
+	Reviewer   string
+	Decision   string
+	Comment    string
+	DecidedAt  sql.NullTime
+	CreatedAt  time.Time
+}
+
+type Report struct {
+	Campaign *Campaign
+	Items    []*Item
+}
@@ -0,0 +1,165 @@
+package reviewmgr
+
+import (
+	"github.com/vespaiach/auth/pkg/common"
+	"regexp"
+)
+
+type Storer interface {
+	AddCampaign(name string, desc string, scope string, targets []string, reviewerIDs []int64) (int64, error)
+	GetCampaign(id int64) (*Campaign, error)
+	CloseCampaign(id int64) error
+	GetItem(id int64) (*Item, error)
+	GetItems(campaignID int64, reviewer string, decision string) ([]*Item, error)
+
+	// DecideItem records the decision of a pending item, and removes the item's grant when it's revoked, in one
+	// transaction. The grant is removed as usrmgr's Storer removes grants, with the same events. Items which are
+	// already decided are refused with ErrReviewItemDecided.
+	DecideItem(id int64, decision string, comment string) error
+	GetUserIDs(usernames []string) ([]int64, error)
+	WithTenant(tenantID int64) Storer
+}
+
+type Service interface {
+	StartCampaign(name string, desc string, scope string, targets []string, reviewers []string) (int64, error)
+	GetCampaign(id int64) (*Campaign, error)
+	GetItems(campaignID int64, reviewer string, decision string) ([]*Item, error)
+	Decide(campaignID int64, itemID int64, reviewer string, decision string, comment string) error
+	CloseCampaign(id int64) (*Report, error)
+	GetReport(id int64) (*Report, error)
+	WithTenant(tenantID int64) Service
+}
+
+type service struct {
+	st Storer
+}
+
+func NewService(st Storer) Service {
+	return &service{st}
+}
+
+// WithTenant returns the service working on campaigns of the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st.WithTenant(tenantID)}
+}
+
+func (s *service) StartCampaign(name string, desc string, scope string, targets []string,
+	reviewers []string) (int64, error) {
+
+	if !s.isValidName(name) {
+		return 0, common.ErrCampaignNameInvalid
+	}
+
+	if scope != ScopeBunch && scope != ScopeKey {
+		return 0, common.ErrReviewScopeInvalid
+	}
+
+	if len(targets) == 0 {
+		return 0, common.ErrMissingReviewTargets
+	}
+
+	if len(reviewers) == 0 {
+		return 0, common.ErrMissingReviewers
+	}
+
+	reviewerIDs, err := s.st.GetUserIDs(reviewers)
+	if err != nil {
+		return 0, err
+	}
+	if len(reviewerIDs) != len(reviewers) {
+		return 0, common.ErrUserNotFound
+	}
+
+	return s.st.AddCampaign(name, desc, scope, targets, reviewerIDs)
+}
+
+func (s *service) GetCampaign(id int64) (*Campaign, error) {
+	return s.st.GetCampaign(id)
+}
+
+func (s *service) GetItems(campaignID int64, reviewer string, decision string) ([]*Item, error) {
+	return s.st.GetItems(campaignID, reviewer, decision)
+}
+
+func (s *service) Decide(campaignID int64, itemID int64, reviewer string, decision string, comment string) error {
+	if decision != DecisionCertified && decision != DecisionRevoked {
+		return common.ErrReviewDecisionInvalid
+	}
+
+	campaign, err := s.openCampaign(campaignID)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.st.GetItem(itemID)
+	if err != nil {
+		return err
+	}
+	if item == nil || item.CampaignID != campaign.ID {
+		return common.ErrReviewItemNotFound
+	}
+
+	if item.Reviewer != reviewer {
+		return common.ErrReviewerNotAssigned
+	}
+
+	if item.Decision != DecisionPending {
+		return common.ErrReviewItemDecided
+	}
+
+	return s.st.DecideItem(item.ID, decision, comment)
+}
+
+func (s *service) CloseCampaign(id int64) (*Report, error) {
+	campaign, err := s.openCampaign(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.st.CloseCampaign(campaign.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReport(campaign.ID)
+}
+
+func (s *service) GetReport(id int64) (*Report, error) {
+	campaign, err := s.st.GetCampaign(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, common.ErrCampaignNotFound
+	}
+	if campaign.Status != StatusClosed {
+		return nil, common.ErrCampaignNotClosed
+	}
+
+	items, err := s.st.GetItems(campaign.ID, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{campaign, items}, nil
+}
+
+func (s *service) openCampaign(id int64) (*Campaign, error) {
+	campaign, err := s.st.GetCampaign(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, common.ErrCampaignNotFound
+	}
+	if campaign.Status != StatusOpen {
+		return nil, common.ErrCampaignClosed
+	}
+
+	return campaign, nil
+}
+
+func (s *service) isValidName(name string) bool {
+	matched, err := regexp.Match(`^[a-zA-Z0-9_]{1,64}$`, []byte(name))
+	return err == nil && matched
+}
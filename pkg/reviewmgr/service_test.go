@@ -0,0 +1,188 @@
+package reviewmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+// store is an in-memory Storer of one campaign, DecideItem records decisions as the db's storage does
+type store struct {
+	campaign *Campaign
+	items    map[int64]*Item
+	users    map[string]int64
+	revoked  []string
+}
+
+func (st *store) AddCampaign(name string, desc string, scope string, targets []string,
+	reviewerIDs []int64) (int64, error) {
+
+	st.campaign = &Campaign{ID: 1, Name: name, Desc: desc, Scope: scope, Targets: targets, Status: StatusOpen}
+	return st.campaign.ID, nil
+}
+
+func (st *store) GetCampaign(id int64) (*Campaign, error) {
+	if st.campaign == nil || st.campaign.ID != id {
+		return nil, nil
+	}
+	return st.campaign, nil
+}
+
+func (st *store) CloseCampaign(id int64) error {
+	st.campaign.Status = StatusClosed
+	return nil
+}
+
+func (st *store) GetItem(id int64) (*Item, error) {
+	return st.items[id], nil
+}
+
+func (st *store) GetItems(campaignID int64, reviewer string, decision string) ([]*Item, error) {
+	lst := make([]*Item, 0)
+	for id := int64(1); id <= int64(len(st.items)); id++ {
+		i := st.items[id]
+		if (len(reviewer) == 0 || i.Reviewer == reviewer) && (len(decision) == 0 || i.Decision == decision) {
+			lst = append(lst, i)
+		}
+	}
+	return lst, nil
+}
+
+func (st *store) DecideItem(id int64, decision string, comment string) error {
+	i := st.items[id]
+	if i.Decision != DecisionPending {
+		return common.ErrReviewItemDecided
+	}
+
+	i.Decision = decision
+	i.Comment = comment
+	if decision == DecisionRevoked {
+		st.revoked = append(st.revoked, i.Username+":"+i.Bunch)
+	}
+	return nil
+}
+
+func (st *store) GetUserIDs(usernames []string) ([]int64, error) {
+	ids := make([]int64, 0, len(usernames))
+	for _, name := range usernames {
+		if id, ok := st.users[name]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (st *store) WithTenant(tenantID int64) Storer {
+	return st
+}
+
+func newStore() *store {
+	return &store{
+		campaign: &Campaign{ID: 1, Name: "q1", Scope: ScopeBunch, Targets: []string{"staff_role"}, Status: StatusOpen},
+		items: map[int64]*Item{
+			1: {ID: 1, CampaignID: 1, Username: "clerk", Bunch: "staff_role", Reviewer: "manager",
+				Decision: DecisionPending},
+			2: {ID: 2, CampaignID: 1, Username: "seller", Bunch: "staff_role", Reviewer: "manager",
+				Decision: DecisionPending},
+		},
+		users: map[string]int64{"manager": 1, "auditor": 2},
+	}
+}
+
+func TestService_StartCampaign(t *testing.T) {
+	s := NewService(newStore())
+
+	id, err := s.StartCampaign("q2", "", ScopeKey, []string{"get_user"}, []string{"manager", "auditor"})
+	require.Nil(t, err)
+	require.NotZero(t, id)
+
+	tests := []struct {
+		name      string
+		campaign  string
+		scope     string
+		targets   []string
+		reviewers []string
+		err       error
+	}{
+		{"name_invalid", "q 2", ScopeKey, []string{"get_user"}, []string{"manager"}, common.ErrCampaignNameInvalid},
+		{"scope_invalid", "q2", "user", []string{"get_user"}, []string{"manager"}, common.ErrReviewScopeInvalid},
+		{"targets_missing", "q2", ScopeKey, nil, []string{"manager"}, common.ErrMissingReviewTargets},
+		{"reviewers_missing", "q2", ScopeKey, []string{"get_user"}, nil, common.ErrMissingReviewers},
+		{"reviewer_not_found", "q2", ScopeKey, []string{"get_user"}, []string{"manager", "nobody"},
+			common.ErrUserNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := s.StartCampaign(test.campaign, "", test.scope, test.targets, test.reviewers)
+			require.Equal(t, test.err, err)
+		})
+	}
+}
+
+func TestService_Decide(t *testing.T) {
+	st := newStore()
+	s := NewService(st)
+
+	require.Nil(t, s.Decide(1, 1, "manager", DecisionCertified, "still in sales"))
+	require.Nil(t, s.Decide(1, 2, "manager", DecisionRevoked, "left the team"))
+	require.Equal(t, []string{"seller:staff_role"}, st.revoked)
+
+	// decided items can't be decided again, whatever the decision
+	require.Equal(t, common.ErrReviewItemDecided, s.Decide(1, 1, "manager", DecisionRevoked, ""))
+	require.Equal(t, common.ErrReviewItemDecided, s.Decide(1, 2, "manager", DecisionRevoked, ""))
+	require.Equal(t, []string{"seller:staff_role"}, st.revoked)
+	require.Equal(t, DecisionCertified, st.items[1].Decision)
+}
+
+func TestService_DecideInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		campaign int64
+		item     int64
+		reviewer string
+		decision string
+		err      error
+	}{
+		{"decision_invalid", 1, 1, "manager", DecisionPending, common.ErrReviewDecisionInvalid},
+		{"campaign_not_found", 2, 1, "manager", DecisionCertified, common.ErrCampaignNotFound},
+		{"item_not_found", 1, 3, "manager", DecisionCertified, common.ErrReviewItemNotFound},
+		{"reviewer_not_assigned", 1, 1, "auditor", DecisionCertified, common.ErrReviewerNotAssigned},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			st := newStore()
+			err := NewService(st).Decide(test.campaign, test.item, test.reviewer, test.decision, "")
+			require.Equal(t, test.err, err)
+			require.Equal(t, DecisionPending, st.items[1].Decision)
+		})
+	}
+
+	// closed campaigns take no decisions
+	st := newStore()
+	s := NewService(st)
+	_, err := s.CloseCampaign(1)
+	require.Nil(t, err)
+	require.Equal(t, common.ErrCampaignClosed, s.Decide(1, 1, "manager", DecisionRevoked, ""))
+	require.Len(t, st.revoked, 0)
+}
+
+func TestService_Report(t *testing.T) {
+	s := NewService(newStore())
+
+	_, err := s.GetReport(1)
+	require.Equal(t, common.ErrCampaignNotClosed, err)
+
+	require.Nil(t, s.Decide(1, 2, "manager", DecisionRevoked, ""))
+
+	report, err := s.CloseCampaign(1)
+	require.Nil(t, err)
+	require.Equal(t, StatusClosed, report.Campaign.Status)
+	require.Len(t, report.Items, 2)
+	require.Equal(t, DecisionRevoked, report.Items[1].Decision)
+
+	_, err = s.CloseCampaign(1)
+	require.Equal(t, common.ErrCampaignClosed, err)
+}
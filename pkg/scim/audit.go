@@ -0,0 +1,82 @@
+package scim
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+// verbs name the scim changes in the audit log by their methods
+var verbs = map[string]string{"POST": "create", "PUT": "replace", "PATCH": "patch", "DELETE": "delete"}
+
+// responseRecorder holds back the status and body which a handler writes, until the outcome is recorded
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// recordChanges records scim changes in the audit log of the default tenant as the endpoints record theirs, as
+// attempted before they're made and with their outcome after them. Requests refused for a wrong token are
+// recorded too. A change isn't made when its attempt can't be recorded, and fails when its outcome can't be.
+func (s *server) recordChanges(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verb, ok := verbs[r.Method]
+		if !ok || s.audit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		aserv := s.audit.WithTenant(common.DefaultTenant)
+		target := strings.TrimPrefix(r.URL.Path, PathPrefix)
+		resource := strings.TrimSuffix(strings.ToLower(strings.SplitN(strings.TrimPrefix(target, "/"), "/", 2)[0]), "s")
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		newEvent := func(outcome string) *audit.Event {
+			return &audit.Event{
+				Actor:     "scim",
+				Action:    "scim_" + verb + "_" + resource,
+				Target:    target,
+				IP:        ip,
+				UserAgent: r.UserAgent(),
+				Outcome:   outcome,
+			}
+		}
+
+		if err := aserv.Record(newEvent(audit.Attempted)); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		event := newEvent(audit.Success)
+		if rec.status >= http.StatusBadRequest {
+			event.Outcome = audit.Failure
+			event.Error = http.StatusText(rec.status)
+		}
+		if err := aserv.Record(event); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
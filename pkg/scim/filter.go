@@ -0,0 +1,102 @@
+package scim
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Condition is one `attribute operator value` expression of a scim filter
+type Condition struct {
+	Attribute string
+	Operator  string
+	Value     string
+}
+
+// Filter is a conjunction of conditions; only "and" is supported
+type Filter []*Condition
+
+var filterOperators = map[string]bool{"eq": true, "co": true, "sw": true}
+
+// ParseFilter parses filters such as `userName eq "bjensen" and active eq true`
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := make(Filter, 0)
+	for i := 0; i < len(tokens); {
+		if len(tokens)-i < 3 {
+			return nil, invalidFilter("incomplete expression")
+		}
+
+		op := strings.ToLower(tokens[i+1])
+		if !filterOperators[op] {
+			return nil, invalidFilter("unsupported operator " + tokens[i+1])
+		}
+
+		filter = append(filter, &Condition{
+			Attribute: strings.ToLower(tokens[i]),
+			Operator:  op,
+			Value:     strings.Trim(tokens[i+2], `"`),
+		})
+		i += 3
+
+		if i < len(tokens) {
+			if strings.ToLower(tokens[i]) != "and" || i+1 == len(tokens) {
+				return nil, invalidFilter("only \"and\" is supported between expressions")
+			}
+			i++
+		}
+	}
+
+	return filter, nil
+}
+
+// Match reports whether value satisfies the condition; comparisons are case-insensitive
+func (c *Condition) Match(value string) bool {
+	value = strings.ToLower(value)
+	expected := strings.ToLower(c.Value)
+
+	switch c.Operator {
+	case "eq":
+		return value == expected
+	case "co":
+		return strings.Contains(value, expected)
+	case "sw":
+		return strings.HasPrefix(value, expected)
+	}
+
+	return false
+}
+
+func tokenize(s string) ([]string, error) {
+	tokens := make([]string, 0)
+	s = strings.TrimSpace(s)
+
+	for len(s) > 0 {
+		var token string
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, invalidFilter("unterminated string")
+			}
+			token, s = s[:end+2], s[end+2:]
+		} else {
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			token, s = s[:end], s[end:]
+		}
+
+		tokens = append(tokens, token)
+		s = strings.TrimLeft(s, " \t")
+	}
+
+	return tokens, nil
+}
+
+func invalidFilter(detail string) *Error {
+	return newError(http.StatusBadRequest, "invalidFilter", detail)
+}
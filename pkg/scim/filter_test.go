@@ -0,0 +1,62 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter(`userName eq "bjensen" and emails.value CO "@example.com"`)
+	require.Nil(t, err)
+	require.Equal(t, Filter{
+		{Attribute: "username", Operator: "eq", Value: "bjensen"},
+		{Attribute: "emails.value", Operator: "co", Value: "@example.com"},
+	}, filter)
+
+	filter, err = ParseFilter("")
+	require.Nil(t, err)
+	require.Len(t, filter, 0)
+
+	// quoted values keep their spaces
+	filter, err = ParseFilter(`displayName sw "sales team"`)
+	require.Nil(t, err)
+	require.Equal(t, "sales team", filter[0].Value)
+
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{"incomplete", `userName eq`},
+		{"operator_unsupported", `userName gt "a"`},
+		{"or_unsupported", `userName eq "a" or userName eq "b"`},
+		{"trailing_and", `userName eq "a" and`},
+		{"unterminated_string", `userName eq "bjensen`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseFilter(test.filter)
+			require.IsType(t, &Error{}, err)
+			require.Equal(t, "invalidFilter", err.(*Error).ScimType)
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, err := tokenize(" userName\teq \"b jensen\"  and active eq true ")
+	require.Nil(t, err)
+	require.Equal(t, []string{"userName", "eq", `"b jensen"`, "and", "active", "eq", "true"}, tokens)
+
+	tokens, err = tokenize(`displayName eq ""`)
+	require.Nil(t, err)
+	require.Equal(t, []string{"displayName", "eq", `""`}, tokens)
+}
+
+func TestCondition_Match(t *testing.T) {
+	require.True(t, (&Condition{Operator: "eq", Value: "BJensen"}).Match("bjensen"))
+	require.False(t, (&Condition{Operator: "eq", Value: "bjensen"}).Match("bjensen2"))
+	require.True(t, (&Condition{Operator: "co", Value: "JENS"}).Match("bjensen"))
+	require.True(t, (&Condition{Operator: "sw", Value: "bj"}).Match("bjensen"))
+	require.False(t, (&Condition{Operator: "sw", Value: "jensen"}).Match("bjensen"))
+}
@@ -0,0 +1,587 @@
+package scim
+
+import (
+	"database/sql"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+var memberPathReg = regexp.MustCompile(`(?i)^members\[value eq "([^"]*)"\]$`)
+
+// activeBunches filters bunches of groups which aren't deleted
+var activeBunches = sql.NullBool{Bool: true, Valid: true}
+
+// groupChange collects the name and the members which are sent by PUT and PATCH requests, members are kept
+// by username
+type groupChange struct {
+	name    string
+	members map[string]bool
+}
+
+func (s *server) toGroup(r *http.Request, b *bunchmgr.Bunch) (*Group, error) {
+	members, err := s.bunches.GetMembers(b.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]Reference, 0, len(members))
+	for _, m := range members {
+		refs = append(refs, Reference{
+			Value:   strconv.FormatInt(m.ID, 10),
+			Display: m.Username,
+			Ref:     location(r, "Users", m.ID),
+		})
+	}
+
+	return &Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          strconv.FormatInt(b.ID, 10),
+		DisplayName: b.Name,
+		Members:     refs,
+		Meta: &Meta{
+			ResourceType: "Group",
+			Created:      b.CreatedAt,
+			LastModified: b.UpdatedAt,
+			Location:     location(r, "Groups", b.ID),
+		},
+	}, nil
+}
+
+// getBunch returns the bunch of a group. Groups have no active attribute, a group is deleted by deactivating its
+// bunch, so inactive bunches aren't found.
+func (s *server) getBunch(id int64) (*bunchmgr.Bunch, error) {
+	b, err := s.bunches.GetBunch(id)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil || !isActive(b) {
+		return nil, newError(http.StatusNotFound, "", "group doesn't exist")
+	}
+
+	return b, nil
+}
+
+func (s *server) writeGroup(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	b, err := s.getBunch(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	group, err := s.toGroup(r, b)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", group.Meta.Location)
+	}
+	writeJSON(w, status, group)
+}
+
+func (s *server) getGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.writeGroup(w, r, http.StatusOK, id)
+}
+
+func (s *server) queryGroups(w http.ResponseWriter, r *http.Request) {
+	var (
+		name       string
+		postFilter Filter
+	)
+
+	startIndex, count := paging(r)
+
+	filter, err := ParseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	for _, c := range filter {
+		if c.Attribute != "displayname" {
+			writeError(w, invalidFilter("filtering by "+c.Attribute+" isn't supported"))
+			return
+		}
+
+		name = c.Value
+		if c.Operator != "co" {
+			postFilter = append(postFilter, c)
+		}
+	}
+
+	var (
+		bunches []*bunchmgr.Bunch
+		total   int64
+	)
+
+	if len(postFilter) == 0 {
+		page, perPage, drop := toPage(startIndex, count)
+		bunches, total, err = s.bunches.QueryBunches(page, perPage, name, activeBunches, "+id")
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if count == 0 || drop >= int64(len(bunches)) {
+			bunches = bunches[:0]
+		} else {
+			bunches = bunches[drop:]
+		}
+	} else {
+		all, err := s.matchBunches(name, postFilter)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		total = int64(len(all))
+		for _, b := range slice(all, startIndex, count) {
+			bunches = append(bunches, b.(*bunchmgr.Bunch))
+		}
+	}
+
+	resources := make([]interface{}, 0, len(bunches))
+	for _, b := range bunches {
+		group, err := s.toGroup(r, b)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		resources = append(resources, group)
+	}
+
+	writeJSON(w, http.StatusOK, newListResponse(resources, total, startIndex))
+}
+
+func (s *server) matchBunches(name string, filter Filter) ([]interface{}, error) {
+	results := make([]interface{}, 0)
+	for page := int64(1); ; page++ {
+		bunches, total, err := s.bunches.QueryBunches(page, maxCount, name, activeBunches, "+id")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range bunches {
+			matched := true
+			for _, c := range filter {
+				matched = matched && c.Match(b.Name)
+			}
+			if matched {
+				results = append(results, b)
+			}
+		}
+
+		if page*maxCount >= total || len(bunches) == 0 {
+			return results, nil
+		}
+	}
+}
+
+func (s *server) createGroup(w http.ResponseWriter, r *http.Request) {
+	group := new(Group)
+	if err := decodeBody(r, group); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	id, err := s.addBunch(group.DisplayName)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.addMembers(group.DisplayName, group.Members); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.writeGroup(w, r, http.StatusCreated, id)
+}
+
+// addBunch adds the bunch of a new group. A group which was deleted is created again by activating its bunch,
+// which has no members nor keys left.
+func (s *server) addBunch(name string) (int64, error) {
+	id, err := s.bunches.AddBunch(name, "")
+	if err != common.ErrDuplicatedBunch {
+		return id, err
+	}
+
+	b, err := s.bunches.GetBunchByName(name)
+	if err != nil {
+		return 0, err
+	}
+	if b == nil || isActive(b) {
+		return 0, common.ErrDuplicatedBunch
+	}
+
+	if err := s.bunches.ModifyBunch(b.ID, "", "", activeBunches); err != nil {
+		return 0, err
+	}
+	return b.ID, nil
+}
+
+func (s *server) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	group := new(Group)
+	if err := decodeBody(r, group); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	b, err := s.getBunch(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	usernames, err := s.memberNames(group.Members)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	change := &groupChange{name: group.DisplayName, members: make(map[string]bool)}
+	for _, username := range usernames {
+		change.members[username] = true
+	}
+
+	if err := s.modifyGroup(b, change); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.writeGroup(w, r, http.StatusOK, id)
+}
+
+func (s *server) patchGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	req := new(PatchRequest)
+	if err := decodeBody(r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	b, err := s.getBunch(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	current, err := s.currentMembers(b.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// operations are applied to the change, which is written once all of them are valid, so that a request
+	// having an invalid operation changes nothing
+	change := &groupChange{name: b.Name, members: make(map[string]bool)}
+	for username := range current {
+		change.members[username] = true
+	}
+
+	for _, op := range req.Operations {
+		if err := s.applyGroupOperation(change, op); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	if err := s.modifyGroup(b, change); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.writeGroup(w, r, http.StatusOK, id)
+}
+
+// applyGroupOperation applies one PATCH operation to the change, members are resolved to usernames so that
+// unknown members fail the operation
+func (s *server) applyGroupOperation(change *groupChange, op *PatchOperation) error {
+	path := strings.TrimPrefix(op.Path, SchemaGroup+":")
+
+	if len(path) == 0 {
+		attributes, ok := op.Value.(map[string]interface{})
+		if !ok {
+			return newError(http.StatusBadRequest, "invalidValue", "value must be an object when path is omitted")
+		}
+		for attr, v := range attributes {
+			if err := s.applyGroupOperation(change, &PatchOperation{op.Op, attr, v}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch strings.ToLower(op.Op) {
+	case "add", "replace":
+		switch strings.ToLower(path) {
+		case "displayname":
+			displayName, _ := op.Value.(string)
+			if len(displayName) == 0 {
+				return newError(http.StatusBadRequest, "invalidValue", "displayName is required")
+			}
+			change.name = displayName
+			return nil
+		case "members":
+			usernames, err := s.memberNames(toReferences(op.Value))
+			if err != nil {
+				return err
+			}
+			if strings.ToLower(op.Op) == "replace" {
+				change.members = make(map[string]bool)
+			}
+			for _, username := range usernames {
+				change.members[username] = true
+			}
+			return nil
+		}
+		return newError(http.StatusBadRequest, "noTarget", "unsupported path "+op.Path)
+	case "remove":
+		var refs []Reference
+		if m := memberPathReg.FindStringSubmatch(path); m != nil {
+			refs = []Reference{{Value: m[1]}}
+		} else if strings.ToLower(path) != "members" {
+			return newError(http.StatusBadRequest, "noTarget", "unsupported path "+op.Path)
+		} else if op.Value == nil {
+			change.members = make(map[string]bool)
+			return nil
+		} else {
+			refs = toReferences(op.Value)
+		}
+
+		usernames, err := s.memberNames(refs)
+		if err != nil {
+			return err
+		}
+		for _, username := range usernames {
+			delete(change.members, username)
+		}
+		return nil
+	}
+
+	return newError(http.StatusBadRequest, "invalidSyntax", "unsupported operation "+op.Op)
+}
+
+// modifyGroup writes the change of the group's bunch: it's renamed first, then its members are made the
+// changed ones
+func (s *server) modifyGroup(b *bunchmgr.Bunch, change *groupChange) error {
+	name := b.Name
+	if change.name != b.Name {
+		var err error
+		if name, err = s.renameBunch(b.ID, change.name); err != nil {
+			return err
+		}
+	}
+
+	current, err := s.currentMembers(name)
+	if err != nil {
+		return err
+	}
+
+	return s.syncMembers(name, current, change.members)
+}
+
+// deleteGroup deprovisions a group by removing its members, taking back its keys and deactivating the bunch, which
+// keeps the bunch's history. The group isn't found afterwards, and one created again by the same name starts
+// with no keys.
+func (s *server) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	b, err := s.getBunch(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.setMembers(b.Name, nil); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.removeKeys(b.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.bunches.ModifyBunch(id, "", "", sql.NullBool{Bool: false, Valid: true}); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeKeys takes back every key of the bunch
+func (s *server) removeKeys(name string) error {
+	keys, err := s.bunches.GetKeysInBunch(name)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.Key)
+	}
+
+	return s.bunches.RemoveKeysFromBunch(name, names)
+}
+
+func (s *server) renameBunch(id int64, name string) (string, error) {
+	if len(name) == 0 {
+		return "", newError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	if err := s.bunches.ModifyBunch(id, name, "", sql.NullBool{}); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// memberNames resolves user ids of member references to usernames
+func (s *server) memberNames(refs []Reference) ([]string, error) {
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		id, err := strconv.ParseInt(ref.Value, 10, 64)
+		if err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "member "+ref.Value+" doesn't exist")
+		}
+
+		u, err := s.users.GetUser(id)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "member "+ref.Value+" doesn't exist")
+		}
+
+		names = append(names, u.Username)
+	}
+
+	return names, nil
+}
+
+func (s *server) currentMembers(name string) (map[string]bool, error) {
+	members, err := s.bunches.GetMembers(name)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]bool)
+	for _, m := range members {
+		current[m.Username] = true
+	}
+
+	return current, nil
+}
+
+func (s *server) addMembers(name string, refs []Reference) error {
+	usernames, err := s.memberNames(refs)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.currentMembers(name)
+	if err != nil {
+		return err
+	}
+
+	for _, username := range usernames {
+		if current[username] {
+			continue
+		}
+		if err := s.users.AddBunchesToUser(username, []string{name}); err != nil {
+			return err
+		}
+		current[username] = true
+	}
+
+	return nil
+}
+
+// setMembers makes the bunch's members exactly the given ones
+func (s *server) setMembers(name string, refs []Reference) error {
+	usernames, err := s.memberNames(refs)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.currentMembers(name)
+	if err != nil {
+		return err
+	}
+
+	desired := make(map[string]bool)
+	for _, username := range usernames {
+		desired[username] = true
+	}
+
+	return s.syncMembers(name, current, desired)
+}
+
+// syncMembers grants the bunch to desired users which aren't its members, and takes it back from current members
+// which aren't desired
+func (s *server) syncMembers(name string, current map[string]bool, desired map[string]bool) error {
+	for username := range desired {
+		if !current[username] {
+			if err := s.users.AddBunchesToUser(username, []string{name}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for username := range current {
+		if !desired[username] {
+			if err := s.users.RemoveBunchesFromUser(username, []string{name}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func isActive(b *bunchmgr.Bunch) bool {
+	return !b.Active.Valid || b.Active.Bool
+}
+
+func toReferences(value interface{}) []Reference {
+	items, _ := value.([]interface{})
+
+	refs := make([]Reference, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			if v, ok := m["value"].(string); ok {
+				refs = append(refs, Reference{Value: v})
+			}
+		}
+	}
+
+	return refs
+}
@@ -0,0 +1,291 @@
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// PathPrefix is where the scim endpoints are mounted
+const PathPrefix = "/scim/v2"
+
+type server struct {
+	users      usrmgr.Service
+	bunches    bunchmgr.Service
+	audit      audit.Service
+	token      string
+	bcryptCost int
+}
+
+// NewHandler returns the scim http handler. Groups are mapped to bunches and every request must carry
+// the bearer token configured by SCIM_TOKEN; without one, all scim requests are rejected. Changes are recorded
+// in the audit log when an audit service is given.
+func NewHandler(appConfig *cf.AppConfig, userServ usrmgr.Service, bunchServ bunchmgr.Service,
+	auditServ audit.Service) http.Handler {
+
+	s := &server{userServ, bunchServ, auditServ, appConfig.ScimToken, appConfig.BcryptCost}
+
+	router := mux.NewRouter().PathPrefix(PathPrefix).Subrouter()
+	router.Use(s.recordChanges)
+	router.Use(s.authenticate)
+
+	router.HandleFunc("/ServiceProviderConfig", s.getServiceProviderConfig).Methods("GET")
+	router.HandleFunc("/ResourceTypes", s.getResourceTypes).Methods("GET")
+	router.HandleFunc("/Schemas", s.getSchemas).Methods("GET")
+
+	router.HandleFunc("/Users", s.queryUsers).Methods("GET")
+	router.HandleFunc("/Users", s.createUser).Methods("POST")
+	router.HandleFunc("/Users/{id}", s.getUser).Methods("GET")
+	router.HandleFunc("/Users/{id}", s.replaceUser).Methods("PUT")
+	router.HandleFunc("/Users/{id}", s.patchUser).Methods("PATCH")
+	router.HandleFunc("/Users/{id}", s.deleteUser).Methods("DELETE")
+
+	router.HandleFunc("/Groups", s.queryGroups).Methods("GET")
+	router.HandleFunc("/Groups", s.createGroup).Methods("POST")
+	router.HandleFunc("/Groups/{id}", s.getGroup).Methods("GET")
+	router.HandleFunc("/Groups/{id}", s.replaceGroup).Methods("PUT")
+	router.HandleFunc("/Groups/{id}", s.patchGroup).Methods("PATCH")
+	router.HandleFunc("/Groups/{id}", s.deleteGroup).Methods("DELETE")
+
+	return router
+}
+
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if len(s.token) == 0 || !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(s.token)) != 1 {
+			writeError(w, newError(http.StatusUnauthorized, "", "bearer token is missing or not correct"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newError(status int, scimType string, detail string) *Error {
+	return &Error{[]string{SchemaError}, strconv.Itoa(status), scimType, detail}
+}
+
+// toError maps service errors to scim errors. Other errors are logged, clients are only told that the request
+// failed, so that messages of the storage never reach them.
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+
+	switch err.(type) {
+	case *common.ExclusionError:
+		return newError(http.StatusConflict, "", err.Error())
+	case *common.ValidationError:
+		return newError(http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	switch err {
+	case common.ErrDuplicatedUsername, common.ErrDuplicatedEmail, common.ErrDuplicatedBunch:
+		return newError(http.StatusConflict, "uniqueness", err.Error())
+	case common.ErrUsernameInvalid, common.ErrEmailInvalid, common.ErrBunchNameInvalid:
+		return newError(http.StatusBadRequest, "invalidValue", err.Error())
+	case common.ErrUserNotFound, common.ErrBunchNotFound:
+		return newError(http.StatusNotFound, "", err.Error())
+	}
+
+	log.Printf("scim: %v", err)
+	return newError(http.StatusInternalServerError, "", "internal error")
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e := toError(err)
+	status, _ := strconv.Atoi(e.Status)
+	writeJSON(w, status, e)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return newError(http.StatusBadRequest, "invalidSyntax", err.Error())
+	}
+
+	return nil
+}
+
+func parseID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, newError(http.StatusNotFound, "", "resource doesn't exist")
+	}
+
+	return id, nil
+}
+
+// location builds the absolute url of a resource
+func location(r *http.Request, resource string, id int64) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host + PathPrefix + "/" + resource + "/" + strconv.FormatInt(id, 10)
+}
+
+// paging reads 1-based startIndex and count from query string
+func paging(r *http.Request) (int64, int64) {
+	startIndex, err := strconv.ParseInt(r.URL.Query().Get("startIndex"), 10, 64)
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+
+	count, err := strconv.ParseInt(r.URL.Query().Get("count"), 10, 64)
+	if err != nil || count < 0 {
+		count = defaultCount
+	}
+	if count > maxCount {
+		count = maxCount
+	}
+
+	return startIndex, count
+}
+
+// toPage converts startIndex and count to the page/perPage pair which services use. When startIndex
+// isn't aligned to count, a bigger page is fetched and extra leading rows have to be dropped.
+func toPage(startIndex int64, count int64) (page int64, perPage int64, drop int64) {
+	if count == 0 {
+		return 1, 1, 0
+	}
+
+	if (startIndex-1)%count == 0 {
+		return (startIndex-1)/count + 1, count, 0
+	}
+
+	return 1, startIndex - 1 + count, startIndex - 1
+}
+
+func newListResponse(resources []interface{}, total int64, startIndex int64) *ListResponse {
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: int64(len(resources)),
+		Resources:    resources,
+	}
+}
+
+// slice returns the requested window of in-memory filtered resources
+func slice(resources []interface{}, startIndex int64, count int64) []interface{} {
+	from := startIndex - 1
+	if from > int64(len(resources)) {
+		from = int64(len(resources))
+	}
+
+	to := from + count
+	if to > int64(len(resources)) {
+		to = int64(len(resources))
+	}
+
+	return resources[from:to]
+}
+
+func (s *server) getServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"schemas":          []string{SchemaServiceProviderConfig},
+		"documentationUri": "https://tools.ietf.org/html/rfc7644",
+		"patch":            map[string]bool{"supported": true},
+		"bulk":             map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]interface{}{"supported": true, "maxResults": maxCount},
+		"changePassword":   map[string]bool{"supported": true},
+		"sort":             map[string]bool{"supported": false},
+		"etag":             map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]interface{}{
+			{
+				"type":        "oauthbearertoken",
+				"name":        "Bearer Token",
+				"description": "Authentication with the token configured by SCIM_TOKEN",
+				"primary":     true,
+			},
+		},
+	})
+}
+
+func (s *server) getResourceTypes(w http.ResponseWriter, r *http.Request) {
+	types := []interface{}{
+		map[string]interface{}{
+			"schemas":  []string{SchemaResourceType},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   SchemaUser,
+		},
+		map[string]interface{}{
+			"schemas":  []string{SchemaResourceType},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   SchemaGroup,
+		},
+	}
+
+	writeJSON(w, http.StatusOK, newListResponse(types, int64(len(types)), 1))
+}
+
+func (s *server) getSchemas(w http.ResponseWriter, r *http.Request) {
+	attribute := func(name string, typ string, required bool, multi bool, uniqueness string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":        name,
+			"type":        typ,
+			"multiValued": multi,
+			"required":    required,
+			"caseExact":   false,
+			"mutability":  "readWrite",
+			"returned":    "default",
+			"uniqueness":  uniqueness,
+		}
+	}
+
+	password := attribute("password", "string", false, false, "none")
+	password["mutability"] = "writeOnly"
+	password["returned"] = "never"
+
+	schemas := []interface{}{
+		map[string]interface{}{
+			"id":          SchemaUser,
+			"name":        "User",
+			"description": "User Account",
+			"attributes": []interface{}{
+				attribute("userName", "string", true, false, "server"),
+				attribute("emails", "complex", true, true, "server"),
+				attribute("active", "boolean", false, false, "none"),
+				password,
+				attribute("groups", "complex", false, true, "none"),
+			},
+		},
+		map[string]interface{}{
+			"id":          SchemaGroup,
+			"name":        "Group",
+			"description": "Group, stored as a bunch",
+			"attributes": []interface{}{
+				attribute("displayName", "string", true, false, "server"),
+				attribute("members", "complex", false, true, "none"),
+			},
+		},
+	}
+
+	writeJSON(w, http.StatusOK, newListResponse(schemas, int64(len(schemas)), 1))
+}
@@ -0,0 +1,308 @@
+package scim
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// users is a user service keeping users by id, users hold no bunches
+type users struct {
+	usrmgr.Service
+	byID map[int64]*usrmgr.User
+}
+
+func (s *users) GetUser(id int64) (*usrmgr.User, error) {
+	return s.byID[id], nil
+}
+
+func (s *users) DeleteUser(id int64) error {
+	if _, ok := s.byID[id]; !ok {
+		return common.ErrUserNotFound
+	}
+	delete(s.byID, id)
+	return nil
+}
+
+func (s *users) GetBunches(username string) ([]*usrmgr.Bunch, error) {
+	return nil, nil
+}
+
+// bunches is a bunch service keeping bunches by id and their keys by name, bunches have no members
+type bunches struct {
+	bunchmgr.Service
+	byID map[int64]*bunchmgr.Bunch
+	keys map[string][]string
+}
+
+func (s *bunches) GetKeysInBunch(name string) ([]*bunchmgr.Key, error) {
+	keys := make([]*bunchmgr.Key, 0, len(s.keys[name]))
+	for _, k := range s.keys[name] {
+		keys = append(keys, &bunchmgr.Key{Key: k})
+	}
+	return keys, nil
+}
+
+func (s *bunches) RemoveKeysFromBunch(name string, keys []string) error {
+	kept := make([]string, 0)
+	for _, k := range s.keys[name] {
+		removed := false
+		for _, r := range keys {
+			removed = removed || r == k
+		}
+		if !removed {
+			kept = append(kept, k)
+		}
+	}
+	s.keys[name] = kept
+	return nil
+}
+
+func (s *bunches) AddBunch(name string, desc string) (int64, error) {
+	if b, _ := s.GetBunchByName(name); b != nil {
+		return 0, common.ErrDuplicatedBunch
+	}
+
+	id := int64(len(s.byID) + 1)
+	s.byID[id] = &bunchmgr.Bunch{ID: id, Name: name, Active: sql.NullBool{Bool: true, Valid: true}}
+	return id, nil
+}
+
+func (s *bunches) ModifyBunch(id int64, name string, desc string, active sql.NullBool) error {
+	if len(name) > 0 {
+		s.byID[id].Name = name
+	}
+	if active.Valid {
+		s.byID[id].Active = active
+	}
+	return nil
+}
+
+func (s *bunches) GetBunch(id int64) (*bunchmgr.Bunch, error) {
+	return s.byID[id], nil
+}
+
+func (s *bunches) GetBunchByName(name string) (*bunchmgr.Bunch, error) {
+	for _, b := range s.byID {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *bunches) GetMembers(name string) ([]*bunchmgr.Member, error) {
+	return nil, nil
+}
+
+// auditLog is an audit service keeping the events which it records, it fails to record while err is set
+type auditLog struct {
+	audit.Service
+	events []*audit.Event
+	err    error
+}
+
+func (s *auditLog) Record(e *audit.Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *auditLog) WithTenant(tenantID int64) audit.Service {
+	return s
+}
+
+func serve(h http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, PathPrefix+path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer secret")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestDeleteUser(t *testing.T) {
+	h := NewHandler(&cf.AppConfig{ScimToken: "secret"}, &users{byID: map[int64]*usrmgr.User{
+		1: {ID: 1, Username: "bjensen", Type: usrmgr.TypeHuman},
+		2: {ID: 2, Username: "ci_bot", Type: usrmgr.TypeService},
+	}}, &bunches{}, nil)
+
+	require.Equal(t, http.StatusOK, serve(h, "GET", "/Users/1", "").Code)
+	require.Equal(t, http.StatusNoContent, serve(h, "DELETE", "/Users/1", "").Code)
+
+	// deleted users aren't found afterwards
+	require.Equal(t, http.StatusNotFound, serve(h, "GET", "/Users/1", "").Code)
+	require.Equal(t, http.StatusNotFound, serve(h, "DELETE", "/Users/1", "").Code)
+
+	// service accounts aren't provisioned, nor deleted
+	require.Equal(t, http.StatusNotFound, serve(h, "DELETE", "/Users/2", "").Code)
+}
+
+func TestDeleteGroup(t *testing.T) {
+	b := &bunches{byID: map[int64]*bunchmgr.Bunch{}, keys: map[string][]string{}}
+	h := NewHandler(&cf.AppConfig{ScimToken: "secret"}, &users{}, b, nil)
+
+	w := serve(h, "POST", "/Groups", `{"displayName": "sales_role"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+	require.True(t, strings.HasSuffix(location, "/Groups/1"))
+	b.keys["sales_role"] = []string{"create_order", "refund_order"}
+
+	require.Equal(t, http.StatusNoContent, serve(h, "DELETE", "/Groups/1", "").Code)
+
+	// deleted groups aren't found afterwards
+	require.Equal(t, http.StatusNotFound, serve(h, "GET", "/Groups/1", "").Code)
+	require.Equal(t, http.StatusNotFound, serve(h, "PUT", "/Groups/1", `{"displayName": "sales_role"}`).Code)
+	require.Equal(t, http.StatusNotFound, serve(h, "DELETE", "/Groups/1", "").Code)
+
+	// but they can be created again, without the keys which they had
+	w = serve(h, "POST", "/Groups", `{"displayName": "sales_role"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, location, w.Header().Get("Location"))
+	require.Empty(t, b.keys["sales_role"])
+
+	require.Equal(t, http.StatusConflict, serve(h, "POST", "/Groups", `{"displayName": "sales_role"}`).Code)
+}
+
+func TestPatchGroup(t *testing.T) {
+	b := &bunches{byID: map[int64]*bunchmgr.Bunch{}}
+	h := NewHandler(&cf.AppConfig{ScimToken: "secret"}, &users{}, b, nil)
+	require.Equal(t, http.StatusCreated, serve(h, "POST", "/Groups", `{"displayName": "sales_role"}`).Code)
+
+	// an invalid operation fails the request before the ones preceding it are applied
+	w := serve(h, "PATCH", "/Groups/1", `{"Operations": [
+		{"op": "replace", "path": "displayName", "value": "buyer_role"},
+		{"op": "add", "path": "members", "value": [{"value": "99"}]}]}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "sales_role", b.byID[1].Name)
+
+	w = serve(h, "PATCH", "/Groups/1", `{"Operations": [{"op": "replace", "value": {"displayName": "buyer_role"}}]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "buyer_role", b.byID[1].Name)
+}
+
+func TestRecordChanges(t *testing.T) {
+	log := &auditLog{}
+	u := &users{byID: map[int64]*usrmgr.User{1: {ID: 1, Username: "bjensen", Type: usrmgr.TypeHuman}}}
+	h := NewHandler(&cf.AppConfig{ScimToken: "secret"}, u, &bunches{}, log)
+
+	// reads aren't recorded
+	require.Equal(t, http.StatusOK, serve(h, "GET", "/Users/1", "").Code)
+	require.Len(t, log.events, 0)
+
+	require.Equal(t, http.StatusNoContent, serve(h, "DELETE", "/Users/1", "").Code)
+	require.Equal(t, http.StatusNotFound, serve(h, "DELETE", "/Users/1", "").Code)
+	require.Len(t, log.events, 4)
+	require.Equal(t, "scim_delete_user", log.events[0].Action)
+	require.Equal(t, "/Users/1", log.events[0].Target)
+	require.Equal(t, []string{audit.Attempted, audit.Success, audit.Attempted, audit.Failure},
+		[]string{log.events[0].Outcome, log.events[1].Outcome, log.events[2].Outcome, log.events[3].Outcome})
+
+	// changes aren't made when they can't be recorded
+	u.byID[2] = &usrmgr.User{ID: 2, Username: "jsmith", Type: usrmgr.TypeHuman}
+	log.err = errors.New("Error 1040: Too many connections")
+
+	w := serve(h, "DELETE", "/Users/2", "")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NotContains(t, w.Body.String(), "connections")
+	require.NotNil(t, u.byID[2])
+}
+
+func TestAuthenticate(t *testing.T) {
+	h := NewHandler(&cf.AppConfig{ScimToken: "secret"}, &users{}, &bunches{}, nil)
+
+	r := httptest.NewRequest("GET", PathPrefix+"/Schemas", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	require.Equal(t, http.StatusOK, serve(h, "GET", "/Schemas", "").Code)
+
+	// scim is disabled without a token
+	h = NewHandler(&cf.AppConfig{}, &users{}, &bunches{}, nil)
+	require.Equal(t, http.StatusUnauthorized, serve(h, "GET", "/Schemas", "").Code)
+}
+
+func TestPaging(t *testing.T) {
+	tests := []struct {
+		query      string
+		startIndex int64
+		count      int64
+	}{
+		{"", 1, defaultCount},
+		{"startIndex=11&count=10", 11, 10},
+		{"startIndex=0&count=-1", 1, defaultCount},
+		{"startIndex=x&count=y", 1, defaultCount},
+		{"count=100000", 1, maxCount},
+		{"count=0", 1, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			startIndex, count := paging(httptest.NewRequest("GET", "/Users?"+test.query, nil))
+			require.Equal(t, test.startIndex, startIndex)
+			require.Equal(t, test.count, count)
+		})
+	}
+}
+
+func TestToPage(t *testing.T) {
+	tests := []struct {
+		startIndex int64
+		count      int64
+		page       int64
+		perPage    int64
+		drop       int64
+	}{
+		{1, 10, 1, 10, 0},
+		{21, 10, 3, 10, 0},
+		{5, 10, 1, 14, 4},
+		{3, 0, 1, 1, 0},
+	}
+
+	for _, test := range tests {
+		page, perPage, drop := toPage(test.startIndex, test.count)
+		require.Equal(t, []int64{test.page, test.perPage, test.drop}, []int64{page, perPage, drop},
+			"startIndex %d count %d", test.startIndex, test.count)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	resources := []interface{}{"a", "b", "c", "d"}
+
+	require.Equal(t, []interface{}{"b", "c"}, slice(resources, 2, 2))
+	require.Equal(t, []interface{}{"c", "d"}, slice(resources, 3, 10))
+	require.Len(t, slice(resources, 10, 2), 0)
+	require.Len(t, slice(resources, 1, 0), 0)
+}
+
+func TestToError(t *testing.T) {
+	e := toError(common.ErrDuplicatedUsername)
+	require.Equal(t, "409", e.Status)
+	require.Equal(t, "uniqueness", e.ScimType)
+
+	require.Equal(t, "404", toError(common.ErrBunchNotFound).Status)
+
+	invalid := new(common.ValidationError)
+	invalid.Add("username", common.ErrUsernameInvalid)
+	invalid.Add("email", common.ErrEmailInvalid)
+	require.Equal(t, "400", toError(invalid).Status)
+
+	// messages of the storage aren't told to clients
+	e = toError(errors.New("Error 1146: Table 'auth.users' doesn't exist"))
+	require.Equal(t, "500", e.Status)
+	require.NotContains(t, e.Detail, "auth.users")
+}
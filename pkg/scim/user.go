@@ -0,0 +1,422 @@
+package scim
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// userChange collects attributes which are sent by PUT and PATCH requests
+type userChange struct {
+	username string
+	email    string
+	password string
+	active   sql.NullBool
+}
+
+func (s *server) toUser(r *http.Request, u *usrmgr.User) (*User, error) {
+	bunches, err := s.users.GetBunches(u.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]Reference, 0, len(bunches))
+	for _, b := range bunches {
+		groups = append(groups, Reference{
+			Value:   strconv.FormatInt(b.ID, 10),
+			Display: b.Name,
+			Ref:     location(r, "Groups", b.ID),
+		})
+	}
+
+	active := !u.Active.Valid || u.Active.Bool
+
+	return &User{
+		Schemas:  []string{SchemaUser},
+		ID:       strconv.FormatInt(u.ID, 10),
+		UserName: u.Username,
+		Active:   &active,
+		Emails:   []Email{{Value: u.Email, Type: "work", Primary: true}},
+		Groups:   groups,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Location:     location(r, "Users", u.ID),
+		},
+	}, nil
+}
+
+func (s *server) writeUser(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	u, err := s.users.GetUser(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// service accounts aren't provisioned by identity providers
+	if u == nil || u.IsServiceAccount() {
+		writeError(w, newError(http.StatusNotFound, "", "user doesn't exist"))
+		return
+	}
+
+	user, err := s.toUser(r, u)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", user.Meta.Location)
+	}
+	writeJSON(w, status, user)
+}
+
+func (s *server) getUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.writeUser(w, r, http.StatusOK, id)
+}
+
+func (s *server) queryUsers(w http.ResponseWriter, r *http.Request) {
+	var (
+		username   string
+		email      string
+		active     sql.NullBool
+		postFilter Filter
+	)
+
+	startIndex, count := paging(r)
+
+	filter, err := ParseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// conditions are pushed down to QueryUsers as substring matches;
+	// the ones which need an exact or prefix match are checked again in memory
+	for _, c := range filter {
+		switch c.Attribute {
+		case "username":
+			username = c.Value
+		case "emails", "emails.value":
+			email = c.Value
+		case "active":
+			b, err := strconv.ParseBool(c.Value)
+			if err != nil || c.Operator != "eq" {
+				writeError(w, invalidFilter("active only supports eq true or false"))
+				return
+			}
+			active = sql.NullBool{Bool: b, Valid: true}
+			continue
+		default:
+			writeError(w, invalidFilter("filtering by "+c.Attribute+" isn't supported"))
+			return
+		}
+
+		if c.Operator != "co" {
+			postFilter = append(postFilter, c)
+		}
+	}
+
+	var (
+		users []*usrmgr.User
+		total int64
+	)
+
+	if len(postFilter) == 0 {
+		page, perPage, drop := toPage(startIndex, count)
+		users, total, err = s.users.QueryUsers(page, perPage, username, email, active, usrmgr.TypeHuman, "+id")
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if count == 0 || drop >= int64(len(users)) {
+			users = users[:0]
+		} else {
+			users = users[drop:]
+		}
+	} else {
+		all, err := s.matchUsers(username, email, active, postFilter)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		total = int64(len(all))
+		for _, u := range slice(all, startIndex, count) {
+			users = append(users, u.(*usrmgr.User))
+		}
+	}
+
+	resources := make([]interface{}, 0, len(users))
+	for _, u := range users {
+		user, err := s.toUser(r, u)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		resources = append(resources, user)
+	}
+
+	writeJSON(w, http.StatusOK, newListResponse(resources, total, startIndex))
+}
+
+// matchUsers loads every user matching the pushed down conditions and applies the rest in memory
+func (s *server) matchUsers(username string, email string, active sql.NullBool,
+	filter Filter) ([]interface{}, error) {
+
+	results := make([]interface{}, 0)
+	for page := int64(1); ; page++ {
+		users, total, err := s.users.QueryUsers(page, maxCount, username, email, active, usrmgr.TypeHuman, "+id")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, u := range users {
+			if matchUser(u, filter) {
+				results = append(results, u)
+			}
+		}
+
+		if page*maxCount >= total || len(users) == 0 {
+			return results, nil
+		}
+	}
+}
+
+func matchUser(u *usrmgr.User, filter Filter) bool {
+	for _, c := range filter {
+		var value string
+		switch c.Attribute {
+		case "username":
+			value = u.Username
+		case "emails", "emails.value":
+			value = u.Email
+		}
+
+		if !c.Match(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *server) createUser(w http.ResponseWriter, r *http.Request) {
+	user := new(User)
+	if err := decodeBody(r, user); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	hash, err := s.hashPassword(user.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	id, err := s.users.AddUser(user.UserName, primaryEmail(user.Emails), hash)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if user.Active != nil && !*user.Active {
+		if err := s.users.ModifyUser(id, "", "", "", sql.NullBool{Bool: false, Valid: true}, sql.NullString{}); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	s.writeUser(w, r, http.StatusCreated, id)
+}
+
+func (s *server) replaceUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user := new(User)
+	if err := decodeBody(r, user); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(user.UserName) == 0 {
+		writeError(w, newError(http.StatusBadRequest, "invalidValue", "userName is required"))
+		return
+	}
+
+	change := &userChange{username: user.UserName, email: primaryEmail(user.Emails), password: user.Password}
+	if user.Active != nil {
+		change.active = sql.NullBool{Bool: *user.Active, Valid: true}
+	}
+
+	if err := s.modifyUser(id, change); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.writeUser(w, r, http.StatusOK, id)
+}
+
+func (s *server) patchUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	req := new(PatchRequest)
+	if err := decodeBody(r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	change := new(userChange)
+	for _, op := range req.Operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if err := change.apply(op.Path, op.Value); err != nil {
+				writeError(w, err)
+				return
+			}
+		case "remove":
+			writeError(w, newError(http.StatusBadRequest, "mutability", "user attributes can't be removed"))
+			return
+		default:
+			writeError(w, newError(http.StatusBadRequest, "invalidSyntax", "unsupported operation "+op.Op))
+			return
+		}
+	}
+
+	if err := s.modifyUser(id, change); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.writeUser(w, r, http.StatusOK, id)
+}
+
+// deleteUser removes the user, so that it isn't found afterwards. Users which are only deprovisioned are
+// deactivated by PATCH or PUT, which keeps them.
+func (s *server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	u, err := s.users.GetUser(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if u == nil || u.IsServiceAccount() {
+		writeError(w, newError(http.StatusNotFound, "", "user doesn't exist"))
+		return
+	}
+
+	if err := s.users.DeleteUser(id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) modifyUser(id int64, change *userChange) error {
+	var hash string
+	if len(change.password) > 0 {
+		h, err := s.hashPassword(change.password)
+		if err != nil {
+			return err
+		}
+		hash = h
+	}
+
+	return s.users.ModifyUser(id, change.username, change.email, hash, change.active, sql.NullString{})
+}
+
+// hashPassword hashes the given password. Users provisioned without one get a random password,
+// they can't login until it's reset.
+func (s *server) hashPassword(password string) (string, error) {
+	return usrmgr.HashPassword(password, s.bcryptCost)
+}
+
+// apply sets one attribute of a PATCH operation. Attributes which aren't stored are ignored,
+// identity providers usually send many of them.
+func (c *userChange) apply(path string, value interface{}) error {
+	path = strings.ToLower(strings.TrimPrefix(path, SchemaUser+":"))
+
+	switch {
+	case len(path) == 0:
+		attributes, ok := value.(map[string]interface{})
+		if !ok {
+			return newError(http.StatusBadRequest, "invalidValue", "value must be an object when path is omitted")
+		}
+		for name, v := range attributes {
+			if err := c.apply(name, v); err != nil {
+				return err
+			}
+		}
+	case path == "username":
+		c.username, _ = value.(string)
+	case path == "password":
+		c.password, _ = value.(string)
+	case path == "active":
+		switch v := value.(type) {
+		case bool:
+			c.active = sql.NullBool{Bool: v, Valid: true}
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return newError(http.StatusBadRequest, "invalidValue", "active must be a boolean")
+			}
+			c.active = sql.NullBool{Bool: b, Valid: true}
+		default:
+			return newError(http.StatusBadRequest, "invalidValue", "active must be a boolean")
+		}
+	case strings.HasPrefix(path, "emails"):
+		switch v := value.(type) {
+		case string:
+			c.email = v
+		case []interface{}:
+			emails := make([]Email, 0, len(v))
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					e := Email{}
+					e.Value, _ = m["value"].(string)
+					e.Primary, _ = m["primary"].(bool)
+					emails = append(emails, e)
+				}
+			}
+			c.email = primaryEmail(emails)
+		}
+	}
+
+	return nil
+}
+
+func primaryEmail(emails []Email) string {
+	for _, e := range emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+
+	return ""
+}
@@ -0,0 +1,137 @@
+package sessionmgr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"time"
+)
+
+// maxMetadata is the length which metadata of a request is cut to
+const maxMetadata = 512
+
+type Storer interface {
+	AddSession(s *Session, tokenHash string) error
+	GetSession(uid string) (*Session, error)
+	GetSessions(userID int64, now time.Time) ([]*Session, error)
+	RevokeSession(userID int64, uid string, at time.Time) (bool, error)
+
+	// RevokeSessions revokes all sessions of the user along with the user's personal access tokens
+	RevokeSessions(userID int64, at time.Time) error
+}
+
+type UserGetter interface {
+	GetUserByUsername(username string) (*usrmgr.User, error)
+	WithTenant(tenantID int64) usrmgr.Service
+}
+
+// Service keeps track of access tokens issued on logins. A terminated session's token is refused even though
+// it hasn't expired yet.
+type Service interface {
+	Record(user *usrmgr.User, uid string, token string, expiredAt time.Time, meta *Metadata) error
+	IsActive(uid string) (bool, error)
+	GetSessions(username string) ([]*Session, error)
+	Terminate(username string, uid string) error
+	TerminateAll(username string) error
+	WithTenant(tenantID int64) Service
+}
+
+type service struct {
+	st    Storer
+	users UserGetter
+}
+
+func NewService(st Storer, users UserGetter) Service {
+	return &service{st, users}
+}
+
+// WithTenant returns the service managing sessions of users of the tenant. Sessions themselves
+// belong to users, so only user lookups are scoped.
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st, s.users.WithTenant(tenantID)}
+}
+
+// Record stores the session of a token issued to the user, the token itself isn't kept, only its hash
+func (s *service) Record(user *usrmgr.User, uid string, token string, expiredAt time.Time, meta *Metadata) error {
+	sum := sha256.Sum256([]byte(token))
+
+	return s.st.AddSession(&Session{
+		UID:           uid,
+		UserID:        user.ID,
+		RemoteAddr:    cut(meta.RemoteAddr),
+		XForwardedFor: cut(meta.XForwardedFor),
+		XRealIP:       cut(meta.XRealIP),
+		UserAgent:     cut(meta.UserAgent),
+		CreatedAt:     time.Now(),
+		ExpiredAt:     expiredAt,
+	}, hex.EncodeToString(sum[:]))
+}
+
+// IsActive reports whether the token of the session is still accepted, tokens without a session weren't issued
+// here and aren't
+func (s *service) IsActive(uid string) (bool, error) {
+	session, err := s.st.GetSession(uid)
+	if err != nil {
+		return false, err
+	}
+
+	return session != nil && session.Active(time.Now()), nil
+}
+
+// GetSessions returns the user's active sessions, latest first
+func (s *service) GetSessions(username string) ([]*Session, error) {
+	user, err := s.getUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.st.GetSessions(user.ID, time.Now())
+}
+
+func (s *service) Terminate(username string, uid string) error {
+	user, err := s.getUser(username)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.st.RevokeSession(user.ID, uid, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return common.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// TerminateAll terminates every session of the user and revokes the user's personal access tokens, which would
+// otherwise keep working as sessions which can't be terminated
+func (s *service) TerminateAll(username string) error {
+	user, err := s.getUser(username)
+	if err != nil {
+		return err
+	}
+
+	return s.st.RevokeSessions(user.ID, time.Now())
+}
+
+func (s *service) getUser(username string) (*usrmgr.User, error) {
+	user, err := s.users.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func cut(s string) string {
+	if len(s) > maxMetadata {
+		return s[:maxMetadata]
+	}
+	return s
+}
@@ -0,0 +1,136 @@
+package sessionmgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// sessions is an in-memory Storer
+type sessions struct {
+	rows   []*Session
+	hashes map[string]string
+}
+
+func (st *sessions) AddSession(s *Session, tokenHash string) error {
+	st.rows = append(st.rows, s)
+	st.hashes[s.UID] = tokenHash
+	return nil
+}
+
+func (st *sessions) GetSession(uid string) (*Session, error) {
+	for _, s := range st.rows {
+		if s.UID == uid {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (st *sessions) GetSessions(userID int64, now time.Time) ([]*Session, error) {
+	lst := make([]*Session, 0)
+	for _, s := range st.rows {
+		if s.UserID == userID && s.Active(now) {
+			lst = append(lst, s)
+		}
+	}
+	return lst, nil
+}
+
+func (st *sessions) RevokeSession(userID int64, uid string, at time.Time) (bool, error) {
+	for _, s := range st.rows {
+		if s.UID == uid && s.UserID == userID && !s.RevokedAt.Valid {
+			s.RevokedAt.Time, s.RevokedAt.Valid = at, true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (st *sessions) RevokeSessions(userID int64, at time.Time) error {
+	for _, s := range st.rows {
+		if s.UserID == userID && !s.RevokedAt.Valid {
+			s.RevokedAt.Time, s.RevokedAt.Valid = at, true
+		}
+	}
+	return nil
+}
+
+// users is an in-memory usrmgr.Service
+type users struct {
+	usrmgr.Service
+	byName map[string]*usrmgr.User
+}
+
+func (u *users) GetUserByUsername(username string) (*usrmgr.User, error) {
+	return u.byName[username], nil
+}
+
+func (u *users) WithTenant(tenantID int64) usrmgr.Service {
+	return u
+}
+
+func newService() (Service, *sessions) {
+	st := &sessions{hashes: make(map[string]string)}
+	us := &users{byName: map[string]*usrmgr.User{
+		"alice": {ID: 1, Username: "alice"},
+		"bob":   {ID: 2, Username: "bob"},
+	}}
+
+	return NewService(st, us), st
+}
+
+func TestService_Terminate(t *testing.T) {
+	s, st := newService()
+	alice := &usrmgr.User{ID: 1, Username: "alice"}
+	expiredAt := time.Now().Add(time.Hour)
+
+	require.Nil(t, s.Record(alice, "a1", "token1", expiredAt, &Metadata{RemoteAddr: "10.0.0.1", UserAgent: "curl"}))
+	require.Nil(t, s.Record(alice, "a2", "token2", expiredAt, &Metadata{}))
+	require.NotEqual(t, "token1", st.hashes["a1"])
+
+	lst, err := s.GetSessions("alice")
+	require.Nil(t, err)
+	require.Len(t, lst, 2)
+	require.Equal(t, "curl", lst[0].UserAgent)
+
+	// a session can only be terminated by its own user's name
+	require.Equal(t, common.ErrSessionNotFound, s.Terminate("bob", "a1"))
+	require.Nil(t, s.Terminate("alice", "a1"))
+	require.Equal(t, common.ErrSessionNotFound, s.Terminate("alice", "a1"))
+
+	active, err := s.IsActive("a1")
+	require.Nil(t, err)
+	require.False(t, active)
+
+	active, err = s.IsActive("a2")
+	require.Nil(t, err)
+	require.True(t, active)
+
+	require.Nil(t, s.TerminateAll("alice"))
+
+	lst, err = s.GetSessions("alice")
+	require.Nil(t, err)
+	require.Len(t, lst, 0)
+}
+
+func TestService_IsActive(t *testing.T) {
+	s, _ := newService()
+	alice := &usrmgr.User{ID: 1, Username: "alice"}
+
+	require.Nil(t, s.Record(alice, "expired", "token", time.Now().Add(-time.Minute), &Metadata{}))
+
+	active, err := s.IsActive("expired")
+	require.Nil(t, err)
+	require.False(t, active)
+
+	active, err = s.IsActive("unknown")
+	require.Nil(t, err)
+	require.False(t, active)
+
+	_, err = s.GetSessions("carol")
+	require.Equal(t, common.ErrUserNotFound, err)
+}
@@ -0,0 +1,32 @@
+package sessionmgr
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Session is an access token issued on a login, along with where the login came from
+type Session struct {
+	UID           string
+	UserID        int64
+	RemoteAddr    string
+	XForwardedFor string
+	XRealIP       string
+	UserAgent     string
+	CreatedAt     time.Time
+	ExpiredAt     time.Time
+	RevokedAt     sql.NullTime
+}
+
+// Metadata describes the request which a token is issued for
+type Metadata struct {
+	RemoteAddr    string
+	XForwardedFor string
+	XRealIP       string
+	UserAgent     string
+}
+
+// Active reports whether the session's token is still accepted
+func (s *Session) Active(now time.Time) bool {
+	return !s.RevokedAt.Valid && now.Before(s.ExpiredAt)
+}
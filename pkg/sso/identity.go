@@ -0,0 +1,22 @@
+package sso
+
+import (
+	"time"
+)
+
+// Identity links a subject of an upstream identity provider to a user
+type Identity struct {
+	ID        int64
+	Provider  string
+	Subject   string
+	UserID    int64
+	CreatedAt time.Time
+}
+
+// Redirect sends a user to sign in at an upstream provider. State is signed and has to be handed
+// back, together with the callback's parameters, to finish the login.
+type Redirect struct {
+	URL    string
+	State  string
+	Secure bool
+}
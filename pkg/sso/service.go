@@ -0,0 +1,383 @@
+package sso
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"golang.org/x/oauth2"
+)
+
+// How long a user has to finish signing in upstream
+const stateDuration = 10 * time.Minute
+
+const upstreamTimeout = 30 * time.Second
+
+// stateAudience is the audience of login states, they're signed with a key of their own as well, so that states
+// and access tokens can't be used as each other
+const stateAudience = "login_state"
+
+type Storer interface {
+	GetIdentity(provider string, subject string) (*Identity, error)
+	AddIdentity(provider string, subject string, userID int64) (int64, error)
+	WithTenant(tenantID int64) Storer
+}
+
+type Service interface {
+	Begin(provider string) (*Redirect, error)
+	Finish(provider string, code string, state string, signedState string) (*usrmgr.User, error)
+	WithTenant(tenantID int64) Service
+}
+
+// provider is an upstream provider whose discovery document has been loaded
+type provider struct {
+	config   *cf.OidcProvider
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+type service struct {
+	st         Storer
+	users      usrmgr.Service
+	secret     []byte
+	bcryptCost int
+	configs    map[string]*cf.OidcProvider
+	tenant     int64
+
+	// shared by the copies of every tenant
+	mu        *sync.Mutex
+	providers map[string]*provider
+}
+
+// stateClaims is signed and kept by the user agent while it signs in upstream
+type stateClaims struct {
+	jwtgo.StandardClaims
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Tenant   int64  `json:"tenant"`
+}
+
+func NewService(st Storer, users usrmgr.Service, appConfig *cf.AppConfig) Service {
+	configs := make(map[string]*cf.OidcProvider, len(appConfig.OidcProviders))
+	for _, p := range appConfig.OidcProviders {
+		configs[p.Name] = p
+	}
+
+	return &service{
+		st:         st,
+		users:      users,
+		secret:     stateKey(appConfig.SigningText),
+		bcryptCost: appConfig.BcryptCost,
+		configs:    configs,
+		tenant:     common.DefaultTenant,
+		mu:         new(sync.Mutex),
+		providers:  make(map[string]*provider),
+	}
+}
+
+// WithTenant returns the service signing users in to the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return s.withTenant(tenantID)
+}
+
+func (s *service) withTenant(tenantID int64) *service {
+	c := *s
+	c.tenant = tenantID
+	c.st = s.st.WithTenant(tenantID)
+	c.users = s.users.WithTenant(tenantID)
+
+	return &c
+}
+
+// Begin creates the authorization url with a random state, nonce and PKCE challenge
+func (s *service) Begin(name string) (*Redirect, error) {
+	p, err := s.getProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &stateClaims{
+		StandardClaims: jwtgo.StandardClaims{
+			Audience:  stateAudience,
+			Subject:   name,
+			ExpiresAt: time.Now().Add(stateDuration).Unix(),
+		},
+		Tenant: s.tenant,
+	}
+	for _, v := range []*string{&claims.State, &claims.Nonce, &claims.Verifier} {
+		if *v, err = randomString(); err != nil {
+			return nil, err
+		}
+	}
+
+	signed, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := sha256.Sum256([]byte(claims.Verifier))
+	url := p.oauth.AuthCodeURL(claims.State,
+		oidc.Nonce(claims.Nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	return &Redirect{
+		URL:    url,
+		State:  signed,
+		Secure: strings.HasPrefix(p.config.RedirectURL, "https://"),
+	}, nil
+}
+
+// Finish exchanges the authorization code, verifies the id token and returns the linked user
+func (s *service) Finish(name string, code string, state string, signedState string) (*usrmgr.User, error) {
+	p, err := s.getProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.parseState(name, state, signedState)
+	if err != nil {
+		return nil, err
+	}
+
+	// the user is signed in to the tenant which the login began for
+	s = s.withTenant(claims.Tenant)
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+	defer cancel()
+
+	token, err := p.oauth.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", claims.Verifier))
+	if err != nil {
+		log.Println(err)
+		return nil, common.ErrUpstreamLoginError
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, common.ErrIDTokenInvalid
+	}
+
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		log.Println(err)
+		return nil, common.ErrIDTokenInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(claims.Nonce)) != 1 {
+		return nil, common.ErrIDTokenInvalid
+	}
+
+	attrs := make(map[string]interface{})
+	if err := idToken.Claims(&attrs); err != nil {
+		return nil, common.ErrIDTokenInvalid
+	}
+
+	user, err := s.linkUser(p.config, idToken.Subject, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.config.Rules) > 0 {
+		managed, wanted := applyRules(p.config.Rules, attrs)
+		if err := s.users.SyncBunches(user.Username, managed, wanted); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// linkUser finds the user linked to the identity. An identity seen for the first time is linked to
+// the user having the same verified email, or to a newly provisioned user, if the provider allows.
+func (s *service) linkUser(config *cf.OidcProvider, subject string, attrs map[string]interface{}) (*usrmgr.User, error) {
+	var user *usrmgr.User
+
+	identity, err := s.st.GetIdentity(config.Name, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity != nil {
+		if user, err = s.users.GetUser(identity.UserID); err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, common.ErrIdentityNotLinked
+		}
+	} else {
+		email, _ := attrs["email"].(string)
+		verified, _ := attrs["email_verified"].(bool)
+
+		if config.LinkByEmail && verified && len(email) > 0 {
+			if user, err = s.users.GetUserByEmail(email); err != nil {
+				return nil, err
+			}
+		}
+
+		if user == nil && config.Provision {
+			if user, err = s.provisionUser(config, email, attrs); err != nil {
+				return nil, err
+			}
+		}
+
+		if user == nil {
+			return nil, common.ErrIdentityNotLinked
+		}
+
+		if _, err := s.st.AddIdentity(config.Name, subject, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if (user.Active.Valid && !user.Active.Bool) || user.IsServiceAccount() {
+		return nil, common.ErrWrongCredentials
+	}
+
+	return user, nil
+}
+
+func (s *service) provisionUser(config *cf.OidcProvider, email string,
+	attrs map[string]interface{}) (*usrmgr.User, error) {
+
+	claim := config.UsernameClaim
+	if len(claim) == 0 {
+		claim = "preferred_username"
+	}
+	username, _ := attrs[claim].(string)
+
+	// provisioned users get an unusable password, so they can only login through their provider
+	hash, err := usrmgr.HashPassword("", s.bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.users.AddUser(username, email, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.users.GetUser(id)
+}
+
+func (s *service) parseState(name string, state string, signedState string) (*stateClaims, error) {
+	claims := new(stateClaims)
+	token, err := jwtgo.ParseWithClaims(signedState, claims, func(token *jwtgo.Token) (interface{}, error) {
+		if token.Method != jwtgo.SigningMethodHS256 {
+			return nil, common.ErrLoginStateInvalid
+		}
+		return s.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, common.ErrLoginStateInvalid
+	}
+
+	if !claims.VerifyAudience(stateAudience, true) || claims.Subject != name ||
+		subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return nil, common.ErrLoginStateInvalid
+	}
+
+	return claims, nil
+}
+
+// stateKey derives the key which login states are signed with from the signing text of access tokens
+func stateKey(signingText string) []byte {
+	mac := hmac.New(sha256.New, []byte(signingText))
+	mac.Write([]byte(stateAudience))
+	return mac.Sum(nil)
+}
+
+// getProvider loads the provider's discovery document on first use, so the service can start while
+// an upstream provider is unreachable
+func (s *service) getProvider(name string) (*provider, error) {
+	config, ok := s.configs[name]
+	if !ok {
+		return nil, common.ErrProviderNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.providers[name]; ok {
+		return p, nil
+	}
+
+	// key set is refreshed later with this context, so it mustn't be cancelled
+	discovered, err := oidc.NewProvider(context.Background(), config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("can't discover identity provider %s: %v", name, err)
+	}
+
+	scopes := config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"profile", "email"}
+	}
+
+	p := &provider{
+		config: config,
+		oauth: &oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       append([]string{oidc.ScopeOpenID}, scopes...),
+		},
+		verifier: discovered.Verifier(&oidc.Config{ClientID: config.ClientID}),
+	}
+	s.providers[name] = p
+
+	return p, nil
+}
+
+// applyRules returns bunches managed by rules and the ones whose rules match the claims
+func applyRules(rules []*cf.OidcRule, attrs map[string]interface{}) ([]string, []string) {
+	managed := make([]string, 0, len(rules))
+	wanted := make([]string, 0)
+
+	for _, r := range rules {
+		managed = append(managed, r.Bunch)
+		if matchClaim(attrs[r.Claim], r.Value) {
+			wanted = append(wanted, r.Bunch)
+		}
+	}
+
+	return managed, wanted
+}
+
+func matchClaim(claim interface{}, value string) bool {
+	switch v := claim.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if matchClaim(item, value) {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	default:
+		return fmt.Sprint(v) == value
+	}
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
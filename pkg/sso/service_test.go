@@ -0,0 +1,323 @@
+package sso
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// fakeProvider is a local oidc provider. Every authorization request is approved for the subject
+// set by the test; the code is bound to the request's nonce and PKCE challenge.
+type fakeProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims map[string]interface{}
+	codes  map[string]url.Values
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	p := &fakeProvider{key: key, codes: make(map[string]url.Values)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		p.mu.Lock()
+		auth, ok := p.codes[r.Form.Get("code")]
+		delete(p.codes, r.Form.Get("code"))
+		p.mu.Unlock()
+
+		challenge := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.Get("code_challenge") {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		claims := map[string]interface{}{
+			"iss":   p.URL,
+			"aud":   auth.Get("client_id"),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": auth.Get("nonce"),
+		}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     p.sign(t, claims),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+
+	return p
+}
+
+func (p *fakeProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: p.key},
+		(&jose.SignerOptions{}).WithHeader("kid", "test"))
+	require.Nil(t, err)
+
+	payload, _ := json.Marshal(claims)
+	jws, err := signer.Sign(payload)
+	require.Nil(t, err)
+
+	raw, err := jws.CompactSerialize()
+	require.Nil(t, err)
+
+	return raw
+}
+
+// authorize approves the authorization url as if the user signed in, and returns the code
+func (p *fakeProvider) authorize(t *testing.T, authURL string, claims map[string]interface{}) string {
+	u, err := url.Parse(authURL)
+	require.Nil(t, err)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	code := "code_" + u.Query().Get("state")
+	p.codes[code] = u.Query()
+	p.claims = claims
+
+	return code
+}
+
+// identities is an in-memory Storer, its copies of every tenant share the rows
+type identities struct {
+	mu     *sync.Mutex
+	rows   map[string]*Identity
+	tenant int64
+}
+
+func newIdentities() *identities {
+	return &identities{mu: new(sync.Mutex), rows: make(map[string]*Identity), tenant: common.DefaultTenant}
+}
+
+func (st *identities) key(provider string, subject string) string {
+	return fmt.Sprintf("%d/%s/%s", st.tenant, provider, subject)
+}
+
+func (st *identities) GetIdentity(provider string, subject string) (*Identity, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.rows[st.key(provider, subject)], nil
+}
+
+func (st *identities) AddIdentity(provider string, subject string, userID int64) (int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	id := int64(len(st.rows) + 1)
+	st.rows[st.key(provider, subject)] = &Identity{ID: id, Provider: provider, Subject: subject, UserID: userID}
+	return id, nil
+}
+
+func (st *identities) WithTenant(tenantID int64) Storer {
+	return &identities{st.mu, st.rows, tenantID}
+}
+
+type users struct {
+	usrmgr.Service
+	rows    []*usrmgr.User
+	bunches map[string][]string
+}
+
+func (u *users) GetUser(id int64) (*usrmgr.User, error) {
+	for _, user := range u.rows {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (u *users) GetUserByEmail(email string) (*usrmgr.User, error) {
+	for _, user := range u.rows {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (u *users) AddUser(username string, email string, hash string) (int64, error) {
+	id := int64(len(u.rows) + 1)
+	u.rows = append(u.rows, &usrmgr.User{ID: id, Username: username, Email: email, Hash: hash,
+		Active: sql.NullBool{Bool: true, Valid: true}})
+	return id, nil
+}
+
+func (u *users) WithTenant(tenantID int64) usrmgr.Service {
+	return u
+}
+
+func (u *users) SyncBunches(username string, managed []string, wanted []string) error {
+	u.bunches[username] = wanted
+	return nil
+}
+
+func newTestService(p *fakeProvider, us *users, config *cf.OidcProvider) Service {
+	config.Name = "corp"
+	config.Issuer = p.URL
+	config.ClientID = "client"
+	config.ClientSecret = "secret"
+	config.RedirectURL = "https://auth.example.com/v1/login/corp/callback"
+
+	return NewService(newIdentities(), us, &cf.AppConfig{
+		SigningText:   "signing",
+		BcryptCost:    4,
+		OidcProviders: []*cf.OidcProvider{config},
+	})
+}
+
+func login(t *testing.T, s Service, p *fakeProvider, claims map[string]interface{}) (*usrmgr.User, error) {
+	redirect, err := s.Begin("corp")
+	require.Nil(t, err)
+
+	u, _ := url.Parse(redirect.URL)
+	require.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	code := p.authorize(t, redirect.URL, claims)
+
+	return s.Finish("corp", code, u.Query().Get("state"), redirect.State)
+}
+
+func TestService_Finish(t *testing.T) {
+	t.Parallel()
+
+	p := newFakeProvider(t)
+	defer p.Close()
+
+	t.Run("success_link_by_verified_email", func(t *testing.T) {
+		us := &users{bunches: make(map[string][]string)}
+		us.AddUser("jane", "jane@example.com", "hash")
+		s := newTestService(p, us, &cf.OidcProvider{LinkByEmail: true})
+
+		user, err := login(t, s, p, map[string]interface{}{
+			"sub": "1001", "email": "jane@example.com", "email_verified": true,
+		})
+		require.Nil(t, err)
+		require.Equal(t, "jane", user.Username)
+
+		// the identity is linked now, email isn't needed anymore
+		user, err = login(t, s, p, map[string]interface{}{"sub": "1001"})
+		require.Nil(t, err)
+		require.Equal(t, "jane", user.Username)
+
+		// but only in the tenant which it's linked in
+		_, err = login(t, s.WithTenant(2), p, map[string]interface{}{"sub": "1001"})
+		require.Equal(t, common.ErrIdentityNotLinked, err)
+	})
+
+	t.Run("success_provision_user_and_map_claims", func(t *testing.T) {
+		us := &users{bunches: make(map[string][]string)}
+		s := newTestService(p, us, &cf.OidcProvider{
+			Provision: true,
+			Rules: []*cf.OidcRule{
+				{Claim: "groups", Value: "engineering", Bunch: "staff"},
+				{Claim: "groups", Value: "admins", Bunch: "admin"},
+			},
+		})
+
+		user, err := login(t, s, p, map[string]interface{}{
+			"sub": "1002", "email": "john@example.com", "preferred_username": "john",
+			"groups": []string{"engineering"},
+		})
+		require.Nil(t, err)
+		require.Equal(t, "john", user.Username)
+		require.Equal(t, []string{"staff"}, us.bunches["john"])
+	})
+
+	t.Run("fail_unverified_email_isnt_linked", func(t *testing.T) {
+		us := &users{bunches: make(map[string][]string)}
+		us.AddUser("jane", "jane@example.com", "hash")
+		s := newTestService(p, us, &cf.OidcProvider{LinkByEmail: true})
+
+		_, err := login(t, s, p, map[string]interface{}{
+			"sub": "1003", "email": "jane@example.com", "email_verified": false,
+		})
+		require.Equal(t, common.ErrIdentityNotLinked, err)
+	})
+
+	t.Run("fail_wrong_state", func(t *testing.T) {
+		us := &users{bunches: make(map[string][]string)}
+		s := newTestService(p, us, &cf.OidcProvider{Provision: true})
+
+		redirect, err := s.Begin("corp")
+		require.Nil(t, err)
+		code := p.authorize(t, redirect.URL, map[string]interface{}{"sub": "1004"})
+
+		_, err = s.Finish("corp", code, "forged", redirect.State)
+		require.Equal(t, common.ErrLoginStateInvalid, err)
+	})
+
+	t.Run("fail_state_and_access_token_swapped", func(t *testing.T) {
+		us := &users{bunches: make(map[string][]string)}
+		s := newTestService(p, us, &cf.OidcProvider{Provision: true})
+
+		redirect, err := s.Begin("corp")
+		require.Nil(t, err)
+		u, _ := url.Parse(redirect.URL)
+		state := u.Query().Get("state")
+
+		// the state isn't signed with the signing text of access tokens
+		_, err = jwtgo.Parse(redirect.State, func(*jwtgo.Token) (interface{}, error) {
+			return []byte("signing"), nil
+		})
+		require.NotNil(t, err)
+
+		// nor is a token signed with that signing text a state
+		forged, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, &stateClaims{
+			StandardClaims: jwtgo.StandardClaims{Subject: "corp", ExpiresAt: time.Now().Add(time.Minute).Unix()},
+			State:          state,
+		}).SignedString([]byte("signing"))
+		require.Nil(t, err)
+
+		code := p.authorize(t, redirect.URL, map[string]interface{}{"sub": "1005"})
+		_, err = s.Finish("corp", code, state, forged)
+		require.Equal(t, common.ErrLoginStateInvalid, err)
+	})
+
+	t.Run("fail_unknown_provider", func(t *testing.T) {
+		s := newTestService(p, &users{}, &cf.OidcProvider{})
+
+		_, err := s.Begin("unknown")
+		require.Equal(t, common.ErrProviderNotFound, err)
+	})
+}
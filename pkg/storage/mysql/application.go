@@ -0,0 +1,155 @@
+package mysql
+
+import (
+	"fmt"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymgr"
+	"sync"
+	"time"
+)
+
+var sqlAddApplication = "INSERT INTO applications (tenant_id, `name`, `desc`, created_at, updated_at) VALUES (?, ?, ?, ?, ?);"
+
+func (st *KeyStorage) AddApplication(name string, desc string) (int64, error) {
+	now := time.Now()
+	res, err := st.db.Exec(sqlAddApplication, st.tenant, name, desc, now, now)
+	if err != nil {
+		return 0, err
+	}
+
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return lastID, nil
+}
+
+var sqlGetApplicationByName = "SELECT id, `name`, `desc`, created_at, updated_at FROM applications " +
+	"WHERE tenant_id = ? AND `name` = ? LIMIT 1;"
+
+func (st *KeyStorage) GetApplicationByName(name string) (*keymgr.Application, error) {
+	rows, err := st.db.Queryx(sqlGetApplicationByName, st.tenant, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	app := new(keymgr.Application)
+	if err := rows.Scan(&app.ID, &app.Name, &app.Desc, &app.CreatedAt, &app.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+var sqlUpdateApplication = "UPDATE applications SET `desc` = ?, updated_at = ? WHERE id = ? AND tenant_id = ?;"
+
+func (st *KeyStorage) ModifyApplication(id int64, desc string) error {
+	_, err := st.db.Exec(sqlUpdateApplication, desc, time.Now(), id, st.tenant)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var sqlQueryApplications = "SELECT id, `name`, `desc`, created_at, updated_at FROM applications %s " +
+	"ORDER BY %s LIMIT :offset, :limit;"
+var sqlQueryApplicationsCounter = "SELECT count(id) FROM applications %s;"
+
+func (st *KeyStorage) QueryApplications(take int64, skip int64, name string, sortby string,
+	direction common.SortingDirection) ([]*keymgr.Application, int64, error) {
+
+	var (
+		order         string
+		where         string
+		filter        map[string]interface{}
+		wg            sync.WaitGroup
+		queryErr      error
+		countTotalErr error
+		results       []*keymgr.Application
+		total         int64
+	)
+
+	filter = make(map[string]interface{})
+
+	where = "WHERE tenant_id = :tenant_id"
+	filter["tenant_id"] = st.tenant
+
+	if len(name) > 0 {
+		where += " AND `name` LIKE :name"
+		filter["name"] = "%" + name + "%"
+	}
+
+	if direction == common.Descending {
+		order = fmt.Sprintf("`%s` DESC, id", sortby)
+	} else {
+		order = fmt.Sprintf("`%s` ASC, id", sortby)
+	}
+
+	filter["offset"] = skip
+	filter["limit"] = take
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rows, err := st.db.NamedQuery(fmt.Sprintf(sqlQueryApplications, where, order), filter)
+		if err != nil {
+			queryErr = err
+			return
+		}
+		defer rows.Close()
+
+		results = make([]*keymgr.Application, 0, take)
+		for rows.Next() {
+			app := new(keymgr.Application)
+			err := rows.Scan(&app.ID, &app.Name, &app.Desc, &app.CreatedAt, &app.UpdatedAt)
+			if err != nil {
+				queryErr = err
+				return
+			}
+			results = append(results, app)
+		}
+
+		if rows.Err() != nil {
+			queryErr = rows.Err()
+			return
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		rows, err := st.db.NamedQuery(fmt.Sprintf(sqlQueryApplicationsCounter, where), filter)
+		if err != nil {
+			countTotalErr = err
+			return
+		}
+		defer rows.Close()
+
+		if rows.Next() {
+			err := rows.Scan(&total)
+			if err != nil {
+				countTotalErr = err
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	if queryErr != nil {
+		return nil, 0, queryErr
+	}
+	if countTotalErr != nil {
+		return nil, 0, countTotalErr
+	}
+
+	return results, total, nil
+}
@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"testing"
+)
+
+func TestKeyStorage_AddApplication(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_add_application_with_keys", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("app")
+		key := name + ".read"
+
+		id, err := test.kst.AddApplication(name, "desc")
+		require.Nil(t, err)
+		require.NotZero(t, id)
+
+		_, err = test.kst.AddKey(key, "", id)
+		require.Nil(t, err)
+
+		_, err = test.kst.AddKey(test.mig.createUniqueString("key"), "", 0)
+		require.Nil(t, err)
+
+		keys, total, err := test.kst.QueryKeys(10, 0, "", name, "key", common.Ascending)
+		require.Nil(t, err)
+		require.Equal(t, int64(1), total)
+		require.Equal(t, key, keys[0].Key)
+	})
+
+	t.Run("fail_duplicated_name", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("app")
+
+		_, err := test.kst.AddApplication(name, "")
+		require.Nil(t, err)
+
+		_, err = test.kst.AddApplication(name, "")
+		require.NotNil(t, err)
+	})
+}
+
+func TestKeyStorage_ModifyApplication(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_modify_desc", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("app")
+		id, err := test.kst.AddApplication(name, "desc")
+		require.Nil(t, err)
+
+		require.Nil(t, test.kst.ModifyApplication(id, "new desc"))
+
+		app, err := test.kst.GetApplicationByName(name)
+		require.Nil(t, err)
+		require.Equal(t, "new desc", app.Desc)
+	})
+}
@@ -0,0 +1,223 @@
+package mysql
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/common"
+	"sync"
+)
+
+// AuditStorage implements db's storage for the audit log, it only ever inserts events
+type AuditStorage struct {
+	db     *sqlx.DB
+	tenant int64
+}
+
+// NewAuditStorage create new instance of AuditStorage, working on the default tenant
+func NewAuditStorage(db *sqlx.DB) *AuditStorage {
+	return &AuditStorage{
+		db,
+		common.DefaultTenant,
+	}
+}
+
+// WithTenant returns a storage working on the audit log of the tenant
+func (st *AuditStorage) WithTenant(tenantID int64) audit.Storer {
+	return &AuditStorage{st.db, tenantID}
+}
+
+var (
+	sqlLockAuditTenant  = "SELECT id FROM tenants WHERE id = ? FOR UPDATE;"
+	sqlGetLastAuditHash = "SELECT hash FROM audit_events WHERE tenant_id = ? AND hash <> '' " +
+		"ORDER BY id DESC LIMIT 1;"
+	sqlAddAuditEvent = "INSERT INTO audit_events (tenant_id, actor, action, target, `before`, `after`, diff, ip, " +
+		"user_agent, outcome, error, prev_hash, hash, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+)
+
+// AppendEvent inserts the event, when seal is given the event is chained to the tenant's last chained event.
+// Appends of a tenant are serialized on the tenant's row so that the chain never forks, and so that events of
+// the tenant are committed in the order of their ids: readers going on from an id never skip an event which
+// commits later with a lower one.
+func (st *AuditStorage) AppendEvent(e *audit.Event, seal func(prevHash string) string) (int64, error) {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqlLockAuditTenant, st.tenant); err != nil {
+		return 0, err
+	}
+
+	if seal != nil {
+		rows, err := tx.Queryx(sqlGetLastAuditHash, st.tenant)
+		if err != nil {
+			return 0, err
+		}
+
+		var prevHash string
+		if rows.Next() {
+			err = rows.Scan(&prevHash)
+		}
+		rows.Close()
+		if err != nil {
+			return 0, err
+		}
+
+		e.PrevHash = prevHash
+		e.Hash = seal(prevHash)
+	}
+
+	res, err := tx.Exec(sqlAddAuditEvent, st.tenant, e.Actor, e.Action, e.Target, e.Before, e.After, e.Diff, e.IP,
+		e.UserAgent, e.Outcome, e.Error, e.PrevHash, e.Hash, e.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+var sqlAuditEventColumns = "id, actor, action, target, `before`, `after`, diff, ip, user_agent, outcome, error, " +
+	"prev_hash, hash, created_at"
+var sqlQueryAuditEvents = "SELECT " + sqlAuditEventColumns + " FROM audit_events %s " +
+	"ORDER BY id DESC LIMIT :offset, :limit;"
+var sqlQueryAuditEventsCounter = "SELECT count(id) FROM audit_events %s;"
+
+// QueryEvents returns events matching the filter, newest first
+func (st *AuditStorage) QueryEvents(take int64, skip int64, f *audit.Filter) ([]*audit.Event, int64, error) {
+	var (
+		where         string
+		filter        map[string]interface{}
+		wg            sync.WaitGroup
+		queryErr      error
+		countTotalErr error
+		results       []*audit.Event
+		total         int64
+	)
+
+	filter = make(map[string]interface{})
+
+	where = "WHERE tenant_id = :tenant_id"
+	filter["tenant_id"] = st.tenant
+
+	if len(f.Actor) > 0 {
+		where += " AND actor = :actor"
+		filter["actor"] = f.Actor
+	}
+
+	if len(f.Action) > 0 {
+		where += " AND action = :action"
+		filter["action"] = f.Action
+	}
+
+	if len(f.Target) > 0 {
+		where += " AND target LIKE :target"
+		filter["target"] = "%" + f.Target + "%"
+	}
+
+	if len(f.Outcome) > 0 {
+		where += " AND outcome = :outcome"
+		filter["outcome"] = f.Outcome
+	}
+
+	if !f.From.IsZero() {
+		where += " AND created_at >= :from"
+		filter["from"] = f.From
+	}
+
+	if !f.To.IsZero() {
+		where += " AND created_at <= :to"
+		filter["to"] = f.To
+	}
+
+	filter["offset"] = skip
+	filter["limit"] = take
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rows, err := st.db.NamedQuery(fmt.Sprintf(sqlQueryAuditEvents, where), filter)
+		if err != nil {
+			queryErr = err
+			return
+		}
+		defer rows.Close()
+
+		results, queryErr = scanAuditEvents(rows, take)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		rows, err := st.db.NamedQuery(fmt.Sprintf(sqlQueryAuditEventsCounter, where), filter)
+		if err != nil {
+			countTotalErr = err
+			return
+		}
+		defer rows.Close()
+
+		if rows.Next() {
+			err := rows.Scan(&total)
+			if err != nil {
+				countTotalErr = err
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	if queryErr != nil {
+		return nil, 0, queryErr
+	}
+	if countTotalErr != nil {
+		return nil, 0, countTotalErr
+	}
+
+	return results, total, nil
+}
+
+var sqlGetAuditEventsAfter = "SELECT " + sqlAuditEventColumns + " FROM audit_events " +
+	"WHERE tenant_id = ? AND id > ? ORDER BY id LIMIT ?;"
+
+// GetEventsAfter returns events following the id, oldest first. Ids of a tenant's events are handed out in the
+// order of commit, see AppendEvent.
+func (st *AuditStorage) GetEventsAfter(id int64, take int64) ([]*audit.Event, error) {
+	rows, err := st.db.Queryx(sqlGetAuditEventsAfter, st.tenant, id, take)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAuditEvents(rows, take)
+}
+
+func scanAuditEvents(rows *sqlx.Rows, take int64) ([]*audit.Event, error) {
+	events := make([]*audit.Event, 0, take)
+	for rows.Next() {
+		e := new(audit.Event)
+		err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.Target, &e.Before, &e.After, &e.Diff, &e.IP, &e.UserAgent,
+			&e.Outcome, &e.Error, &e.PrevHash, &e.Hash, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return events, nil
+}
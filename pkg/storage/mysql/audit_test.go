@@ -0,0 +1,56 @@
+package mysql
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/audit"
+	"testing"
+	"time"
+)
+
+func TestAuditStorage_AppendEvent(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_chain_events", func(t *testing.T) {
+		t.Parallel()
+
+		actor := test.mig.createUniqueString("actor")
+		seal := func(prevHash string) string {
+			return prevHash + "x"
+		}
+
+		first := &audit.Event{Actor: actor, Action: "add_key", Outcome: audit.Success, CreatedAt: time.Now()}
+		id, err := test.adst.AppendEvent(first, seal)
+		require.Nil(t, err)
+		require.NotZero(t, id)
+
+		second := &audit.Event{Actor: actor, Action: "add_key", Outcome: audit.Failure, CreatedAt: time.Now()}
+		_, err = test.adst.AppendEvent(second, seal)
+		require.Nil(t, err)
+		require.Equal(t, first.Hash, second.PrevHash)
+
+		lst, err := test.adst.GetEventsAfter(id-1, 2)
+		require.Nil(t, err)
+		require.Equal(t, first.Hash, lst[0].Hash)
+	})
+}
+
+func TestAuditStorage_QueryEvents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_filter_by_actor_and_outcome", func(t *testing.T) {
+		t.Parallel()
+
+		actor := test.mig.createUniqueString("actor")
+		for _, outcome := range []string{audit.Success, audit.Failure, audit.Failure} {
+			_, err := test.adst.AppendEvent(&audit.Event{Actor: actor, Action: "login", Outcome: outcome,
+				CreatedAt: time.Now()}, nil)
+			require.Nil(t, err)
+		}
+
+		lst, total, err := test.adst.QueryEvents(10, 0, &audit.Filter{Actor: actor, Outcome: audit.Failure})
+		require.Nil(t, err)
+		require.Equal(t, int64(2), total)
+		require.Len(t, lst, 2)
+		require.True(t, lst[0].ID > lst[1].ID)
+	})
+}
@@ -0,0 +1,340 @@
+package mysql
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/backup"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"time"
+)
+
+// BackupStorage implements db's storage for exporting and importing data
+type BackupStorage struct {
+	db     *sqlx.DB
+	tenant int64
+}
+
+// NewBackupStorage create new instance of BackupStorage, working on data of the default tenant
+func NewBackupStorage(db *sqlx.DB) *BackupStorage {
+	return &BackupStorage{
+		db,
+		common.DefaultTenant,
+	}
+}
+
+// WithTenant returns a storage working on data of the tenant
+func (st *BackupStorage) WithTenant(tenantID int64) backup.Storer {
+	return &BackupStorage{st.db, tenantID}
+}
+
+var sqlExportKeys = "SELECT `key`, `desc` FROM `keys` WHERE tenant_id = ? ORDER BY `key`;"
+var sqlExportBunches = "SELECT `name`, `desc`, active FROM bunches WHERE tenant_id = ? ORDER BY `name`;"
+var sqlExportUsers = "SELECT users.username, IFNULL(users.email, ''), users.hash, users.active, users.`type`, " +
+	"IFNULL(owners.username, ''), users.`desc` FROM users " +
+	"LEFT JOIN users AS owners ON owners.id = users.owner_id WHERE users.tenant_id = ? ORDER BY users.username;"
+var sqlExportBunchKeys = "SELECT bunches.`name`, `keys`.`key`, IFNULL(bunch_keys.expression, '') FROM bunch_keys " +
+	"INNER JOIN bunches ON bunches.id = bunch_keys.bunch_id " +
+	"INNER JOIN `keys` ON `keys`.id = bunch_keys.key_id WHERE bunch_keys.tenant_id = ? " +
+	"ORDER BY bunches.`name`, `keys`.`key`;"
+var sqlExportUserBunches = "SELECT users.username, bunches.`name`, IFNULL(user_bunches.expression, '') " +
+	"FROM user_bunches " +
+	"INNER JOIN users ON users.id = user_bunches.user_id " +
+	"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id WHERE user_bunches.tenant_id = ? " +
+	"ORDER BY users.username, bunches.`name`;"
+
+// Export reads all tables in one consistent snapshot
+func (st *BackupStorage) Export(withHashes bool) (*backup.Document, error) {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	doc := &backup.Document{
+		Keys:        make([]*backup.Key, 0),
+		Bunches:     make([]*backup.Bunch, 0),
+		Users:       make([]*backup.User, 0),
+		BunchKeys:   make([]*backup.BunchKey, 0),
+		UserBunches: make([]*backup.UserBunch, 0),
+	}
+
+	err = queryPairs(tx, sqlExportKeys, st.tenant, func(key string, desc string) {
+		doc.Keys = append(doc.Keys, &backup.Key{Key: key, Desc: desc})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Queryx(sqlExportBunches, st.tenant)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		b := new(backup.Bunch)
+		if err := rows.Scan(&b.Name, &b.Desc, &b.Active); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		doc.Bunches = append(doc.Bunches, b)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	rows, err = tx.Queryx(sqlExportUsers, st.tenant)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		u := new(backup.User)
+		if err := rows.Scan(&u.Username, &u.Email, &u.Hash, &u.Active, &u.Type, &u.Owner, &u.Desc); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !withHashes {
+			u.Hash = ""
+		}
+		doc.Users = append(doc.Users, u)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	err = queryTriples(tx, sqlExportBunchKeys, st.tenant, func(bunch string, key string, expression string) {
+		doc.BunchKeys = append(doc.BunchKeys, &backup.BunchKey{Bunch: bunch, Key: key, Condition: expression})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryTriples(tx, sqlExportUserBunches, st.tenant, func(username string, bunch string, expression string) {
+		doc.UserBunches = append(doc.UserBunches, &backup.UserBunch{Username: username, Bunch: bunch,
+			Condition: expression})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+var (
+	sqlImportKey = "INSERT INTO `keys` (tenant_id, `key`, `desc`, created_at, updated_at) VALUES (?, ?, ?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE `desc` = VALUES(`desc`), updated_at = VALUES(updated_at);"
+	sqlImportBunch = "INSERT INTO bunches (tenant_id, `name`, `desc`, active, created_at, updated_at) " +
+		"VALUES (?, ?, ?, ?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE `desc` = VALUES(`desc`), active = VALUES(active), updated_at = VALUES(updated_at);"
+	sqlImportGetBunch  = "SELECT id, active FROM bunches WHERE tenant_id = ? AND `name` = ? LIMIT 1;"
+	sqlImportGetUserID = "SELECT id FROM users WHERE tenant_id = ? AND username = ? LIMIT 1;"
+	sqlImportGetUser   = "SELECT id, active FROM users WHERE tenant_id = ? AND username = ? LIMIT 1;"
+	sqlImportAddUser   = "INSERT INTO users (tenant_id, username, email, hash, active, `type`, `desc`, created_at, " +
+		"updated_at) VALUES (?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?);"
+	sqlImportUpdateUser = "UPDATE users SET email = NULLIF(?, ''), hash = IF(? = '', hash, ?), active = ?, `type` = ?, " +
+		"`desc` = ?, updated_at = ? WHERE id = ?;"
+	sqlImportUserOwner = "UPDATE users SET owner_id = ? WHERE tenant_id = ? AND username = ?;"
+	sqlImportBunchKey  = "INSERT IGNORE INTO bunch_keys (tenant_id, bunch_id, key_id, expression, created_at) " +
+		"SELECT bunches.tenant_id, bunches.id, `keys`.id, NULLIF(?, ''), ? FROM bunches, `keys` " +
+		"WHERE bunches.tenant_id = ? AND `keys`.tenant_id = bunches.tenant_id " +
+		"AND bunches.`name` = ? AND `keys`.`key` = ?;"
+	sqlImportUserBunch = "INSERT IGNORE INTO user_bunches (tenant_id, user_id, bunch_id, expression, created_at) " +
+		"SELECT users.tenant_id, users.id, bunches.id, NULLIF(?, ''), ? FROM users, bunches " +
+		"WHERE users.tenant_id = ? AND bunches.tenant_id = users.tenant_id " +
+		"AND users.username = ? AND bunches.`name` = ?;"
+)
+
+// importedRow is the id and activity of a row which an import upserts
+type importedRow struct {
+	ID     int64 `db:"id"`
+	Active bool  `db:"active"`
+}
+
+// Import upserts all rows of writes in one transaction, along with events of the users, bunches and grants which
+// it creates or changes
+func (st *BackupStorage) Import(writes *backup.Document) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	for _, k := range writes.Keys {
+		if _, err := tx.Exec(sqlImportKey, st.tenant, k.Key, k.Desc, now, now); err != nil {
+			return err
+		}
+	}
+
+	for _, b := range writes.Bunches {
+		var was importedRow
+		err := tx.Get(&was, sqlImportGetBunch, st.tenant, b.Name)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		exists := err == nil
+
+		res, err := tx.Exec(sqlImportBunch, st.tenant, b.Name, b.Desc, b.Active, now, now)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			err = addOutboxEvent(tx, st.tenant, webhook.BunchCreated, map[string]interface{}{"id": id, "name": b.Name})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if typ := activity(was.Active, b.Active, webhook.BunchActivated, webhook.BunchDeactivated); len(typ) > 0 {
+			err = addOutboxEvent(tx, st.tenant, typ, map[string]interface{}{"id": was.ID, "name": b.Name})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, u := range writes.Users {
+		if err := st.importUser(tx, u, now); err != nil {
+			return err
+		}
+	}
+
+	// owners are set once all users exist, an owner may come after its service accounts
+	for _, u := range writes.Users {
+		if len(u.Owner) == 0 {
+			continue
+		}
+
+		var ownerID int64
+		if err := tx.Get(&ownerID, sqlImportGetUserID, st.tenant, u.Owner); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqlImportUserOwner, ownerID, st.tenant, u.Username); err != nil {
+			return err
+		}
+	}
+
+	for _, bk := range writes.BunchKeys {
+		res, err := tx.Exec(sqlImportBunchKey, bk.Condition, now, st.tenant, bk.Bunch, bk.Key)
+		if err != nil {
+			return err
+		}
+		err = st.addInsertedEvent(tx, res, webhook.BunchKeyAdded,
+			map[string]interface{}{"bunch": bk.Bunch, "key": bk.Key})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ub := range writes.UserBunches {
+		res, err := tx.Exec(sqlImportUserBunch, ub.Condition, now, st.tenant, ub.Username, ub.Bunch)
+		if err != nil {
+			return err
+		}
+		err = st.addInsertedEvent(tx, res, webhook.GrantAdded,
+			map[string]interface{}{"username": ub.Username, "bunch": ub.Bunch})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetExclusions returns bunches of each exclusion of the tenant by the exclusion's name
+func (st *BackupStorage) GetExclusions() (map[string][]string, error) {
+	rows, err := st.db.Queryx(sqlStateExclusions, st.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exclusions := make(map[string][]string)
+	for rows.Next() {
+		var exclusion, bunch string
+		if err := rows.Scan(&exclusion, &bunch); err != nil {
+			return nil, err
+		}
+		exclusions[exclusion] = append(exclusions[exclusion], bunch)
+	}
+
+	return exclusions, rows.Err()
+}
+
+// importUser adds the user or updates the one of the same name
+func (st *BackupStorage) importUser(tx *sqlx.Tx, u *backup.User, now time.Time) error {
+	var was importedRow
+	err := tx.Get(&was, sqlImportGetUser, st.tenant, u.Username)
+	if err == sql.ErrNoRows {
+		res, err := tx.Exec(sqlImportAddUser, st.tenant, u.Username, u.Email, u.Hash, u.Active, u.Type, u.Desc,
+			now, now)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		return addOutboxEvent(tx, st.tenant, webhook.UserCreated, map[string]interface{}{
+			"id":       id,
+			"username": u.Username,
+			"email":    u.Email,
+			"type":     u.Type,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(sqlImportUpdateUser, u.Email, u.Hash, u.Hash, u.Active, u.Type, u.Desc, now, was.ID)
+	if err != nil {
+		return err
+	}
+
+	if typ := activity(was.Active, u.Active, webhook.UserActivated, webhook.UserDeactivated); len(typ) > 0 {
+		return addOutboxEvent(tx, st.tenant, typ, map[string]interface{}{"id": was.ID, "username": u.Username})
+	}
+	return nil
+}
+
+// addInsertedEvent writes the event when the statement has inserted a row, rows which already exist are ignored
+// by imports
+func (st *BackupStorage) addInsertedEvent(tx *sqlx.Tx, res sql.Result, typ string,
+	payload map[string]interface{}) error {
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return err
+	}
+
+	return addOutboxEvent(tx, st.tenant, typ, payload)
+}
+
+// queryTriples runs a query of the tenant selecting three strings per row
+func queryTriples(tx *sqlx.Tx, query string, tenant int64, fn func(string, string, string)) error {
+	rows, err := tx.Queryx(query, tenant)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a, b, c string
+		if err := rows.Scan(&a, &b, &c); err != nil {
+			return err
+		}
+		fn(a, b, c)
+	}
+
+	return rows.Err()
+}
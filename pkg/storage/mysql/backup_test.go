@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/backup"
+	"testing"
+)
+
+func TestBackupStorage_Export(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_export_without_hashes", func(t *testing.T) {
+		t.Parallel()
+
+		username := test.mig.createUniqueString("username")
+		test.mig.createSeedingUser(func(fields map[string]interface{}) { fields["username"] = username })
+
+		doc, err := test.bkst.Export(false)
+		require.Nil(t, err)
+
+		found := false
+		for _, u := range doc.Users {
+			require.Empty(t, u.Hash)
+			if u.Username == username {
+				found = true
+			}
+		}
+		require.True(t, found)
+	})
+}
+
+func TestBackupStorage_Import(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_import_rows", func(t *testing.T) {
+		t.Parallel()
+
+		key := test.mig.createUniqueString("key")
+		bunch := test.mig.createUniqueString("bunch")
+		username := test.mig.createUniqueString("username")
+
+		writes := &backup.Document{
+			Keys:        []*backup.Key{{Key: key, Desc: "desc"}},
+			Bunches:     []*backup.Bunch{{Name: bunch, Active: true}},
+			Users:       []*backup.User{{Username: username, Email: username + "@test.com", Hash: "hash", Active: true}},
+			BunchKeys:   []*backup.BunchKey{{Bunch: bunch, Key: key}},
+			UserBunches: []*backup.UserBunch{{Username: username, Bunch: bunch}},
+		}
+
+		require.Nil(t, test.bkst.Import(writes))
+		require.Nil(t, test.bkst.Import(writes))
+
+		keys, err := test.ust.GetKeys(username)
+		require.Nil(t, err)
+		require.Len(t, keys, 1)
+	})
+
+	t.Run("success_keep_hash_when_missing", func(t *testing.T) {
+		t.Parallel()
+
+		username := test.mig.createUniqueString("username")
+		id := test.mig.createSeedingUser(func(fields map[string]interface{}) {
+			fields["username"] = username
+			fields["hash"] = "old_hash"
+		})
+
+		err := test.bkst.Import(&backup.Document{
+			Users: []*backup.User{{Username: username, Email: username + "@new.com", Active: true}},
+		})
+		require.Nil(t, err)
+
+		_, email, hash, _ := test.mig.getUserByID(id)
+		require.Equal(t, username+"@new.com", email)
+		require.Equal(t, "old_hash", hash)
+	})
+}
+
+func TestBackupStorage_GetExclusions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_get_exclusions", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("exclusion")
+		bunch1 := test.mig.createUniqueString("bunch")
+		bunch2 := test.mig.createUniqueString("bunch")
+		bID1 := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch1 })
+		bID2 := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch2 })
+
+		_, err := test.bst.AddExclusion(name, "", []int64{bID1, bID2})
+		require.Nil(t, err)
+
+		exclusions, err := test.bkst.GetExclusions()
+		require.Nil(t, err)
+		require.ElementsMatch(t, []string{bunch1, bunch2}, exclusions[name])
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/vespaiach/auth/pkg/bunchmgr"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/webhook"
 	"strings"
 	"sync"
 	"time"
@@ -13,26 +14,35 @@ import (
 
 // BunchStorage implements db's storage for bunch
 type BunchStorage struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	tenant int64
 }
 
-// NewBunchStorage create new instance of BunchStorage
+// NewBunchStorage create new instance of BunchStorage, working on bunches of the default tenant
 func NewBunchStorage(db *sqlx.DB) *BunchStorage {
 	return &BunchStorage{
 		db,
+		common.DefaultTenant,
 	}
 }
 
-var sqlCreateBunch = "INSERT INTO bunches (`name`, `desc`, `active`, created_at, updated_at) VALUES (?, ?, ?, ?, ?);"
+// WithTenant returns a storage working on bunches of the tenant
+func (st *BunchStorage) WithTenant(tenantID int64) bunchmgr.Storer {
+	return &BunchStorage{st.db, tenantID}
+}
+
+var sqlCreateBunch = "INSERT INTO bunches (tenant_id, `name`, `desc`, `active`, created_at, updated_at) " +
+	"VALUES (?, ?, ?, ?, ?, ?);"
 
 func (st *BunchStorage) AddBunch(name string, desc string) (int64, error) {
-	stmt, err := st.db.Prepare(sqlCreateBunch)
+	tx, err := st.db.Beginx()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
 	now := time.Now()
-	res, err := stmt.Exec(name, desc, true, now, now)
+	res, err := tx.Exec(sqlCreateBunch, st.tenant, name, desc, true, now, now)
 	if err != nil {
 		return 0, err
 	}
@@ -42,13 +52,19 @@ func (st *BunchStorage) AddBunch(name string, desc string) (int64, error) {
 		return 0, err
 	}
 
-	return lastID, nil
+	err = addOutboxEvent(tx, st.tenant, webhook.BunchCreated, map[string]interface{}{"id": lastID, "name": name})
+	if err != nil {
+		return 0, err
+	}
+
+	return lastID, tx.Commit()
 }
 
-var sqlGetBunchByName = "SELECT id, `name`, `desc`, active, created_at, updated_at FROM `bunches` WHERE `name` = ? LIMIT 1;"
+var sqlGetBunchByName = "SELECT id, `name`, `desc`, active, created_at, updated_at FROM `bunches` " +
+	"WHERE tenant_id = ? AND `name` = ? LIMIT 1;"
 
 func (st *BunchStorage) GetBunchByName(name string) (*bunchmgr.Bunch, error) {
-	rows, err := st.db.Queryx(sqlGetBunchByName, name)
+	rows, err := st.db.Queryx(sqlGetBunchByName, st.tenant, name)
 	if err != nil {
 		return nil, err
 	}
@@ -66,10 +82,11 @@ func (st *BunchStorage) GetBunchByName(name string) (*bunchmgr.Bunch, error) {
 	return b, nil
 }
 
-var sqlGetBunchByID = "SELECT id, `name`, `desc`, active, created_at, updated_at FROM `bunches` WHERE id = ? LIMIT 1;"
+var sqlGetBunchByID = "SELECT id, `name`, `desc`, active, created_at, updated_at FROM `bunches` " +
+	"WHERE tenant_id = ? AND id = ? LIMIT 1;"
 
 func (st *BunchStorage) GetBunch(id int64) (*bunchmgr.Bunch, error) {
-	rows, err := st.db.Queryx(sqlGetBunchByID, id)
+	rows, err := st.db.Queryx(sqlGetBunchByID, st.tenant, id)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +104,8 @@ func (st *BunchStorage) GetBunch(id int64) (*bunchmgr.Bunch, error) {
 	return b, nil
 }
 
-var sqlUpdateBunch = "UPDATE bunches SET %s	WHERE id = :id;"
+var sqlUpdateBunch = "UPDATE bunches SET %s	WHERE id = :id AND tenant_id = :tenant_id;"
+var sqlLockBunch = "SELECT `name`, active FROM bunches WHERE id = ? AND tenant_id = ? FOR UPDATE;"
 
 func (st *BunchStorage) ModifyBunch(id int64, name string, desc string, active sql.NullBool) error {
 	updating := make(map[string]interface{})
@@ -112,18 +130,67 @@ func (st *BunchStorage) ModifyBunch(id int64, name string, desc string, active s
 		prefix = ", "
 	}
 
-	if len(updating) > 0 {
-		updating["id"] = id
-		updating["updated_at"] = time.Now()
-		condition += prefix + "`updated_at` = :updated_at"
+	if len(updating) == 0 {
+		return nil
+	}
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	var wasActive bool
+	err = tx.QueryRowx(sqlLockBunch, id, st.tenant).Scan(&current, &wasActive)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	changes := make([]string, 0, 2)
+	for _, field := range []string{"desc", "name"} {
+		if _, ok := updating[field]; ok {
+			changes = append(changes, field)
+		}
+	}
+
+	updating["id"] = id
+	updating["tenant_id"] = st.tenant
+	updating["updated_at"] = time.Now()
+	condition += prefix + "`updated_at` = :updated_at"
+
+	if _, err := tx.NamedExec(fmt.Sprintf(sqlUpdateBunch, condition), updating); err != nil {
+		return err
+	}
+
+	if len(name) > 0 {
+		current = name
+	}
 
-		_, err := st.db.NamedExec(fmt.Sprintf(sqlUpdateBunch, condition), updating)
+	if len(changes) > 0 {
+		err = addOutboxEvent(tx, st.tenant, webhook.BunchUpdated, map[string]interface{}{
+			"id":      id,
+			"name":    current,
+			"changes": changes,
+		})
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if active.Valid {
+		if typ := activity(wasActive, active.Bool, webhook.BunchActivated, webhook.BunchDeactivated); len(typ) > 0 {
+			err = addOutboxEvent(tx, st.tenant, typ, map[string]interface{}{"id": id, "name": current})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
 }
 
 var sqlQueryBunches = "SELECT id, `name`, `desc`, active, created_at, updated_at FROM `bunches` %s ORDER BY %s LIMIT :offset, :limit;"
@@ -147,12 +214,13 @@ func (st *BunchStorage) QueryBunches(take int64, skip int64, name string, active
 
 	filter = make(map[string]interface{})
 
+	where = "WHERE tenant_id = :tenant_id"
+	filter["tenant_id"] = st.tenant
+	prefix = " AND "
+
 	if len(name) > 0 {
-		where = "WHERE `name` LIKE :name"
+		where += prefix + "`name` LIKE :name"
 		filter["name"] = "%" + name + "%"
-		prefix = " AND "
-	} else {
-		prefix = " WHERE "
 	}
 
 	if active.Valid {
@@ -230,32 +298,72 @@ func (st *BunchStorage) QueryBunches(take int64, skip int64, name string, active
 	return results, total, nil
 }
 
-var sqlAddKeysToBunch = "INSERT INTO `bunch_keys` (bunch_id, key_id, created_at) VALUES %s;"
+var sqlAddKeysToBunch = "INSERT INTO `bunch_keys` (tenant_id, bunch_id, key_id, created_at) VALUES %s;"
 
 func (st *BunchStorage) AddKeysToBunch(bunchID int64, keyIDs []int64) error {
 	updating := make([]string, 0, len(keyIDs))
 	for _, id := range keyIDs {
-		updating = append(updating, fmt.Sprintf("(%d, %d, :created_at)", bunchID, id))
+		updating = append(updating, fmt.Sprintf("(%d, %d, %d, :created_at)", st.tenant, bunchID, id))
 	}
 
 	sql := fmt.Sprintf(sqlAddKeysToBunch, strings.Join(updating, ", "))
 
-	_, err := st.db.NamedExec(sql, map[string]interface{}{"created_at": time.Now()})
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.NamedExec(sql, map[string]interface{}{"created_at": time.Now()})
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := addBunchKeyEvents(tx, st.tenant, webhook.BunchKeyAdded, bunchID, keyIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-var sqlGetKeyInBunch = "SELECT `keys`.id, `keys`.`key`, `keys`.`desc`, `keys`.created_at, `keys`.updated_at " +
+var sqlRemoveKeysFromBunch = "DELETE FROM bunch_keys WHERE tenant_id = ? AND bunch_id = ? AND key_id IN (%s);"
+
+func (st *BunchStorage) RemoveKeysFromBunch(bunchID int64, keyIDs []int64) error {
+	conditions := make([]string, 0, len(keyIDs))
+	values := make([]interface{}, 0, len(keyIDs)+2)
+	values = append(values, st.tenant, bunchID)
+	for _, id := range keyIDs {
+		conditions = append(conditions, "?")
+		values = append(values, id)
+	}
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// events are written first, names of keys which are taken back can't be read afterwards
+	if err := addBunchKeyEvents(tx, st.tenant, webhook.BunchKeyRemoved, bunchID, keyIDs); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(sqlRemoveKeysFromBunch, strings.Join(conditions, ",")), values...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var sqlGetKeyInBunch = "SELECT `keys`.id, `keys`.`key`, `keys`.`desc`, IFNULL(bunch_keys.expression, ''), " +
+	"`keys`.created_at, `keys`.updated_at " +
 	"FROM bunch_keys " +
 	"INNER JOIN `keys` ON `keys`.id = bunch_keys.key_id " +
 	"INNER JOIN `bunches` ON `bunches`.id = bunch_keys.bunch_id " +
-	"WHERE bunches.name = ?"
+	"WHERE bunches.tenant_id = ? AND bunches.name = ?"
 
 func (st *BunchStorage) GetKeysInBunch(name string) ([]*bunchmgr.Key, error) {
-	rows, err := st.db.Queryx(sqlGetKeyInBunch, name)
+	rows, err := st.db.Queryx(sqlGetKeyInBunch, st.tenant, name)
 	if err != nil {
 		return nil, err
 	}
@@ -264,7 +372,7 @@ func (st *BunchStorage) GetKeysInBunch(name string) ([]*bunchmgr.Key, error) {
 	results := make([]*bunchmgr.Key, 0)
 	for rows.Next() {
 		key := new(bunchmgr.Key)
-		err := rows.Scan(&key.ID, &key.Key, &key.Desc, &key.CreatedAt, &key.UpdatedAt)
+		err := rows.Scan(&key.ID, &key.Key, &key.Desc, &key.Condition, &key.CreatedAt, &key.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -278,12 +386,122 @@ func (st *BunchStorage) GetKeysInBunch(name string) ([]*bunchmgr.Key, error) {
 	return results, nil
 }
 
-var sqlGetKeyIDsByKeyName = "SELECT id FROM `keys` WHERE `key` IN (%s)"
+var sqlSetKeyCondition = "UPDATE bunch_keys SET expression = ? WHERE tenant_id = ? AND bunch_id = ? AND key_id = ?"
+
+func (st *BunchStorage) SetKeyCondition(bunchID int64, keyID int64, expression sql.NullString) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqlSetKeyCondition, expression, st.tenant, bunchID, keyID); err != nil {
+		return err
+	}
+
+	err = addConditionEvent(tx, sqlGetBunchKeyNames, st.tenant, webhook.BunchKeyConditioned, bunchID, keyID,
+		[2]string{"bunch", "key"}, expression)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var sqlAddBunchDenials = "INSERT IGNORE INTO bunch_denials (tenant_id, bunch_id, key_id, created_at) VALUES %s;"
+
+func (st *BunchStorage) AddDenials(bunchID int64, keyIDs []int64) error {
+	updating := make([]string, 0, len(keyIDs))
+	for _, id := range keyIDs {
+		updating = append(updating, fmt.Sprintf("(%d, %d, %d, :created_at)", st.tenant, bunchID, id))
+	}
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = addDenialEvents(tx, sqlGetNewBunchDenialNames, st.tenant, webhook.BunchDenialAdded, bunchID, keyIDs, "bunch")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NamedExec(fmt.Sprintf(sqlAddBunchDenials, strings.Join(updating, ", ")),
+		map[string]interface{}{"created_at": time.Now()})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var sqlRemoveBunchDenials = "DELETE FROM bunch_denials WHERE tenant_id = ? AND bunch_id = ? AND key_id IN (%s);"
+
+func (st *BunchStorage) RemoveDenials(bunchID int64, keyIDs []int64) error {
+	conditions := make([]string, 0, len(keyIDs))
+	values := make([]interface{}, 0, len(keyIDs)+2)
+	values = append(values, st.tenant, bunchID)
+	for _, id := range keyIDs {
+		conditions = append(conditions, "?")
+		values = append(values, id)
+	}
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = addDenialEvents(tx, sqlGetBunchDenialNames, st.tenant, webhook.BunchDenialRemoved, bunchID, keyIDs, "bunch")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(sqlRemoveBunchDenials, strings.Join(conditions, ",")), values...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var sqlGetBunchDenials = "SELECT `keys`.id, `keys`.`key`, `keys`.`desc`, '', `keys`.created_at, `keys`.updated_at " +
+	"FROM bunch_denials " +
+	"INNER JOIN `keys` ON `keys`.id = bunch_denials.key_id " +
+	"INNER JOIN `bunches` ON `bunches`.id = bunch_denials.bunch_id " +
+	"WHERE bunches.tenant_id = ? AND bunches.name = ? ORDER BY `keys`.`key`"
+
+func (st *BunchStorage) GetDenials(name string) ([]*bunchmgr.Key, error) {
+	rows, err := st.db.Queryx(sqlGetBunchDenials, st.tenant, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*bunchmgr.Key, 0)
+	for rows.Next() {
+		key := new(bunchmgr.Key)
+		err := rows.Scan(&key.ID, &key.Key, &key.Desc, &key.Condition, &key.CreatedAt, &key.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, key)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+var sqlGetKeyIDsByKeyName = "SELECT id FROM `keys` WHERE tenant_id = ? AND `key` IN (%s)"
 
 func (st *BunchStorage) GetKeyIDs(keys []string) ([]int64, error) {
 	len := len(keys)
 	conditions := make([]string, 0, len)
-	values := make([]interface{}, 0, len)
+	values := make([]interface{}, 0, len+1)
+	values = append(values, st.tenant)
 	for i := 0; i < len; i++ {
 		conditions = append(conditions, "?")
 		values = append(values, interface{}(keys[i]))
@@ -312,3 +530,31 @@ func (st *BunchStorage) GetKeyIDs(keys []string) ([]int64, error) {
 
 	return results, nil
 }
+
+var sqlGetMembers = "SELECT users.id, users.username FROM user_bunches " +
+	"INNER JOIN users ON users.id = user_bunches.user_id " +
+	"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id " +
+	"WHERE bunches.tenant_id = ? AND bunches.`name` = ? ORDER BY users.id"
+
+func (st *BunchStorage) GetMembers(name string) ([]*bunchmgr.Member, error) {
+	rows, err := st.db.Queryx(sqlGetMembers, st.tenant, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*bunchmgr.Member, 0)
+	for rows.Next() {
+		m := new(bunchmgr.Member)
+		if err := rows.Scan(&m.ID, &m.Username); err != nil {
+			return nil, err
+		}
+		results = append(results, m)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
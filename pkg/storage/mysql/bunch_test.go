@@ -157,6 +157,27 @@ func TestBunchStorage_AddKeysToBunch(t *testing.T) {
 	})
 }
 
+func TestBunchStorage_RemoveKeysFromBunch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_remove_keys_from_a_bunch", func(t *testing.T) {
+		t.Parallel()
+
+		kid1 := test.mig.createSeedingServiceKey(nil)
+		kid2 := test.mig.createSeedingServiceKey(nil)
+		bid := test.mig.createSeedingBunch(nil)
+
+		err := test.bst.AddKeysToBunch(bid, []int64{kid1, kid2})
+		require.Nil(t, err)
+
+		err = test.bst.RemoveKeysFromBunch(bid, []int64{kid1})
+		require.Nil(t, err)
+
+		results := test.mig.getKeyIDByBunchID(bid)
+		require.Len(t, results, 1)
+	})
+}
+
 func TestBunchStorage_GetKeyInBunch(t *testing.T) {
 	t.Parallel()
 
@@ -212,6 +233,28 @@ func TestBunchStorage_GetKeyIDs(t *testing.T) {
 	})
 }
 
+func TestBunchStorage_GetMembers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_get_members_of_a_bunch", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("bunch")
+		bID := test.mig.createSeedingBunch(func(field map[string]interface{}) { field["name"] = name })
+		uID1 := test.mig.createSeedingUser(nil)
+		uID2 := test.mig.createSeedingUser(nil)
+
+		require.Nil(t, test.ust.AddBunchesToUser(uID1, []int64{bID}, nil))
+		require.Nil(t, test.ust.AddBunchesToUser(uID2, []int64{bID}, nil))
+
+		members, err := test.bst.GetMembers(name)
+		require.Nil(t, err)
+		require.Len(t, members, 2)
+		require.Equal(t, uID1, members[0].ID)
+		require.Equal(t, uID2, members[1].ID)
+	})
+}
+
 func contains(s []int64, e int64) bool {
 	for _, a := range s {
 		if a == e {
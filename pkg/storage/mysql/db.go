@@ -177,7 +177,7 @@ func (m *Migrator) getBunchByID(id int64) (name string, desc string, active bool
 }
 
 func (m *Migrator) getUserByID(id int64) (username string, email string, hash string, active bool) {
-	rows, err := m.db.Queryx("Select `username`, `email`, `hash`, `active` FROM `users` WHERE id = ?", id)
+	rows, err := m.db.Queryx("Select `username`, IFNULL(`email`, ''), `hash`, `active` FROM `users` WHERE id = ?", id)
 	defer rows.Close()
 
 	if err == nil && rows.Next() {
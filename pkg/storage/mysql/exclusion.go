@@ -0,0 +1,235 @@
+package mysql
+
+import (
+	"fmt"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"strings"
+	"time"
+)
+
+var sqlGetBunchIDsByName = "SELECT id FROM bunches WHERE tenant_id = ? AND `name` IN (%s);"
+
+func (st *BunchStorage) GetBunchIDs(names []string) ([]int64, error) {
+	conditions := make([]string, 0, len(names))
+	values := make([]interface{}, 0, len(names)+1)
+	values = append(values, st.tenant)
+	for _, n := range names {
+		conditions = append(conditions, "?")
+		values = append(values, n)
+	}
+
+	rows, err := st.db.Queryx(fmt.Sprintf(sqlGetBunchIDsByName, strings.Join(conditions, ",")), values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		results = append(results, id)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+var sqlAddExclusion = "INSERT INTO bunch_exclusions (tenant_id, `name`, `desc`, created_at) VALUES (?, ?, ?, ?);"
+var sqlAddExclusionMember = "INSERT INTO bunch_exclusion_members (exclusion_id, bunch_id) VALUES (?, ?);"
+
+func (st *BunchStorage) AddExclusion(name string, desc string, bunchIDs []int64) (int64, error) {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(sqlAddExclusion, st.tenant, name, desc, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range bunchIDs {
+		if _, err := tx.Exec(sqlAddExclusionMember, lastID, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return lastID, nil
+}
+
+var sqlSelectExclusions = "SELECT bunch_exclusions.id, bunch_exclusions.`name`, bunch_exclusions.`desc`, " +
+	"bunch_exclusions.created_at, bunches.`name` FROM bunch_exclusions " +
+	"INNER JOIN bunch_exclusion_members ON bunch_exclusion_members.exclusion_id = bunch_exclusions.id " +
+	"INNER JOIN bunches ON bunches.id = bunch_exclusion_members.bunch_id "
+
+var sqlGetExclusionByName = sqlSelectExclusions + "WHERE bunch_exclusions.tenant_id = ? AND bunch_exclusions.`name` = ? " +
+	"ORDER BY bunches.`name`;"
+
+func (st *BunchStorage) GetExclusionByName(name string) (*bunchmgr.Exclusion, error) {
+	results, err := st.queryExclusions(sqlGetExclusionByName, st.tenant, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	return results[0], nil
+}
+
+var sqlGetExclusions = sqlSelectExclusions + "WHERE bunch_exclusions.tenant_id = ? " +
+	"ORDER BY bunch_exclusions.`name`, bunches.`name`;"
+
+func (st *BunchStorage) GetExclusions() ([]*bunchmgr.Exclusion, error) {
+	return st.queryExclusions(sqlGetExclusions, st.tenant)
+}
+
+func (st *BunchStorage) queryExclusions(query string, args ...interface{}) ([]*bunchmgr.Exclusion, error) {
+	rows, err := st.db.Queryx(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last *bunchmgr.Exclusion
+	results := make([]*bunchmgr.Exclusion, 0)
+	for rows.Next() {
+		var bunch string
+		e := new(bunchmgr.Exclusion)
+		if err := rows.Scan(&e.ID, &e.Name, &e.Desc, &e.CreatedAt, &bunch); err != nil {
+			return nil, err
+		}
+
+		if last == nil || last.ID != e.ID {
+			last = e
+			results = append(results, e)
+		}
+		last.Bunches = append(last.Bunches, bunch)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+var sqlRemoveExclusion = "DELETE FROM bunch_exclusions WHERE id = ? AND tenant_id = ?;"
+
+func (st *BunchStorage) RemoveExclusion(id int64) error {
+	_, err := st.db.Exec(sqlRemoveExclusion, id, st.tenant)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var sqlGetViolations = "SELECT bunch_exclusions.`name`, users.username, bunches.`name` " +
+	"FROM bunch_exclusion_members " +
+	"INNER JOIN bunch_exclusions ON bunch_exclusions.id = bunch_exclusion_members.exclusion_id " +
+	"INNER JOIN user_bunches ON user_bunches.bunch_id = bunch_exclusion_members.bunch_id " +
+	"INNER JOIN users ON users.id = user_bunches.user_id " +
+	"INNER JOIN bunches ON bunches.id = bunch_exclusion_members.bunch_id " +
+	"WHERE bunch_exclusions.tenant_id = ? AND (bunch_exclusion_members.exclusion_id, user_bunches.user_id) IN (" +
+	"SELECT m.exclusion_id, ub.user_id FROM bunch_exclusion_members AS m " +
+	"INNER JOIN user_bunches AS ub ON ub.bunch_id = m.bunch_id " +
+	"GROUP BY m.exclusion_id, ub.user_id HAVING COUNT(*) > 1) " +
+	"ORDER BY bunch_exclusions.`name`, users.username, bunches.`name`;"
+
+func (st *BunchStorage) GetViolations() ([]*bunchmgr.Violation, error) {
+	rows, err := st.db.Queryx(sqlGetViolations, st.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last *bunchmgr.Violation
+	results := make([]*bunchmgr.Violation, 0)
+	for rows.Next() {
+		var bunch string
+		v := new(bunchmgr.Violation)
+		if err := rows.Scan(&v.Exclusion, &v.Username, &bunch); err != nil {
+			return nil, err
+		}
+
+		if last == nil || last.Exclusion != v.Exclusion || last.Username != v.Username {
+			last = v
+			results = append(results, v)
+		}
+		last.Bunches = append(last.Bunches, bunch)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+var sqlGetExclusionsOfBunches = "SELECT bunch_exclusions.id, bunch_exclusions.`name`, bunches.id, bunches.`name` " +
+	"FROM bunch_exclusions " +
+	"INNER JOIN bunch_exclusion_members ON bunch_exclusion_members.exclusion_id = bunch_exclusions.id " +
+	"INNER JOIN bunches ON bunches.id = bunch_exclusion_members.bunch_id " +
+	"WHERE bunch_exclusions.tenant_id = ? " +
+	"AND bunch_exclusions.id IN (SELECT exclusion_id FROM bunch_exclusion_members WHERE bunch_id IN (%s)) " +
+	"ORDER BY bunch_exclusions.id, bunches.`name`;"
+
+func (st *UserStorage) GetExclusionsOfBunches(bunchIDs []int64) ([]*usrmgr.Exclusion, error) {
+	conditions := make([]string, 0, len(bunchIDs))
+	values := make([]interface{}, 0, len(bunchIDs)+1)
+	values = append(values, st.tenant)
+	for _, id := range bunchIDs {
+		conditions = append(conditions, "?")
+		values = append(values, id)
+	}
+
+	rows, err := st.db.Queryx(fmt.Sprintf(sqlGetExclusionsOfBunches, strings.Join(conditions, ",")), values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last *usrmgr.Exclusion
+	results := make([]*usrmgr.Exclusion, 0)
+	for rows.Next() {
+		var (
+			bunchID int64
+			bunch   string
+		)
+		e := new(usrmgr.Exclusion)
+		if err := rows.Scan(&e.ID, &e.Name, &bunchID, &bunch); err != nil {
+			return nil, err
+		}
+
+		if last == nil || last.ID != e.ID {
+			last = e
+			results = append(results, e)
+		}
+		last.BunchIDs = append(last.BunchIDs, bunchID)
+		last.Bunches = append(last.Bunches, bunch)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
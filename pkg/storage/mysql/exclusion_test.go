@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBunchStorage_AddExclusion(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_add_an_exclusion", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("exclusion")
+		bunch1 := test.mig.createUniqueString("bunch")
+		bunch2 := test.mig.createUniqueString("bunch")
+		bID1 := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch1 })
+		bID2 := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch2 })
+
+		id, err := test.bst.AddExclusion(name, "desc", []int64{bID1, bID2})
+		require.Nil(t, err)
+		require.NotZero(t, id)
+
+		exclusion, err := test.bst.GetExclusionByName(name)
+		require.Nil(t, err)
+		require.NotNil(t, exclusion)
+		require.Equal(t, id, exclusion.ID)
+		require.ElementsMatch(t, []string{bunch1, bunch2}, exclusion.Bunches)
+	})
+}
+
+func TestBunchStorage_RemoveExclusion(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_remove_an_exclusion", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("exclusion")
+		bID1 := test.mig.createSeedingBunch(nil)
+		bID2 := test.mig.createSeedingBunch(nil)
+
+		id, err := test.bst.AddExclusion(name, "", []int64{bID1, bID2})
+		require.Nil(t, err)
+
+		err = test.bst.RemoveExclusion(id)
+		require.Nil(t, err)
+
+		exclusion, err := test.bst.GetExclusionByName(name)
+		require.Nil(t, err)
+		require.Nil(t, exclusion)
+	})
+}
+
+func TestBunchStorage_GetViolations(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_report_violations", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("exclusion")
+		username := test.mig.createUniqueString("username")
+		bID1 := test.mig.createSeedingBunch(nil)
+		bID2 := test.mig.createSeedingBunch(nil)
+		uID := test.mig.createSeedingUser(func(fields map[string]interface{}) { fields["username"] = username })
+
+		require.Nil(t, test.ust.AddBunchesToUser(uID, []int64{bID1, bID2}, nil))
+
+		_, err := test.bst.AddExclusion(name, "", []int64{bID1, bID2})
+		require.Nil(t, err)
+
+		violations, err := test.bst.GetViolations()
+		require.Nil(t, err)
+
+		found := false
+		for _, v := range violations {
+			if v.Exclusion == name && v.Username == username {
+				found = true
+				require.Len(t, v.Bunches, 2)
+			}
+		}
+		require.True(t, found)
+	})
+}
+
+func TestUserStorage_GetExclusionsOfBunches(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_get_exclusions_of_bunches", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("exclusion")
+		bID1 := test.mig.createSeedingBunch(nil)
+		bID2 := test.mig.createSeedingBunch(nil)
+		bID3 := test.mig.createSeedingBunch(nil)
+
+		_, err := test.bst.AddExclusion(name, "", []int64{bID1, bID2, bID3})
+		require.Nil(t, err)
+
+		exclusions, err := test.ust.GetExclusionsOfBunches([]int64{bID2})
+		require.Nil(t, err)
+		require.Len(t, exclusions, 1)
+		require.Equal(t, name, exclusions[0].Name)
+		require.Len(t, exclusions[0].BunchIDs, 3)
+	})
+}
@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/sso"
+	"time"
+)
+
+// IdentityStorage implements db's storage for identities of upstream providers
+type IdentityStorage struct {
+	db     *sqlx.DB
+	tenant int64
+}
+
+// NewIdentityStorage create new instance of IdentityStorage, working on identities of the default tenant
+func NewIdentityStorage(db *sqlx.DB) *IdentityStorage {
+	return &IdentityStorage{
+		db,
+		common.DefaultTenant,
+	}
+}
+
+// WithTenant returns a storage working on identities of the tenant, a subject is linked in every tenant on its own
+func (st *IdentityStorage) WithTenant(tenantID int64) sso.Storer {
+	return &IdentityStorage{st.db, tenantID}
+}
+
+var sqlGetIdentity = "SELECT id, provider, subject, user_id, created_at FROM user_identities " +
+	"WHERE tenant_id = ? AND provider = ? AND subject = ? LIMIT 1;"
+
+func (st *IdentityStorage) GetIdentity(provider string, subject string) (*sso.Identity, error) {
+	rows, err := st.db.Queryx(sqlGetIdentity, st.tenant, provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	i := new(sso.Identity)
+	if err := rows.Scan(&i.ID, &i.Provider, &i.Subject, &i.UserID, &i.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+var sqlAddIdentity = "INSERT INTO user_identities (tenant_id, provider, subject, user_id, created_at) " +
+	"VALUES (?, ?, ?, ?, ?);"
+
+func (st *IdentityStorage) AddIdentity(provider string, subject string, userID int64) (int64, error) {
+	result, err := st.db.Exec(sqlAddIdentity, st.tenant, provider, subject, userID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
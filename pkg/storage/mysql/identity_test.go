@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityStorage_AddIdentity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_add_and_get_identity", func(t *testing.T) {
+		t.Parallel()
+
+		userID := test.mig.createSeedingUser(nil)
+		subject := test.mig.createUniqueString("subject")
+
+		id, err := test.ist.AddIdentity("google", subject, userID)
+		require.Nil(t, err)
+		require.True(t, id > 0)
+
+		identity, err := test.ist.GetIdentity("google", subject)
+		require.Nil(t, err)
+		require.Equal(t, id, identity.ID)
+		require.Equal(t, userID, identity.UserID)
+	})
+
+	t.Run("fail_duplicated_subject", func(t *testing.T) {
+		t.Parallel()
+
+		subject := test.mig.createUniqueString("subject")
+
+		_, err := test.ist.AddIdentity("google", subject, test.mig.createSeedingUser(nil))
+		require.Nil(t, err)
+
+		_, err = test.ist.AddIdentity("google", subject, test.mig.createSeedingUser(nil))
+		require.NotNil(t, err)
+	})
+
+	t.Run("success_add_subject_in_another_tenant", func(t *testing.T) {
+		t.Parallel()
+
+		subject := test.mig.createUniqueString("subject")
+		tenantID, err := test.tnst.AddTenant(test.mig.createUniqueString("tenant"), "", nil, nil)
+		require.Nil(t, err)
+
+		_, err = test.ist.AddIdentity("google", subject, test.mig.createSeedingUser(nil))
+		require.Nil(t, err)
+
+		// the subject isn't linked in the other tenant until it's added there
+		identity, err := test.ist.WithTenant(tenantID).GetIdentity("google", subject)
+		require.Nil(t, err)
+		require.Nil(t, identity)
+
+		_, err = test.ist.WithTenant(tenantID).AddIdentity("google", subject, test.mig.createSeedingUser(nil))
+		require.Nil(t, err)
+	})
+}
+
+func TestIdentityStorage_GetIdentity(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_get_unknown_identity", func(t *testing.T) {
+		t.Parallel()
+
+		identity, err := test.ist.GetIdentity("google", test.mig.createUniqueString("subject"))
+		require.Nil(t, err)
+		require.Nil(t, identity)
+	})
+}
@@ -1,36 +1,48 @@
 package mysql
 
 import (
+	"database/sql"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/vespaiach/auth/pkg/common"
 	"github.com/vespaiach/auth/pkg/keymgr"
+	"github.com/vespaiach/auth/pkg/webhook"
 	"sync"
 	"time"
 )
 
 // KeyStorage implements db's storage for key
 type KeyStorage struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	tenant int64
 }
 
-// NewKeyStorage create new instance of KeyStorage
+// NewKeyStorage create new instance of KeyStorage, working on keys of the default tenant
 func NewKeyStorage(db *sqlx.DB) *KeyStorage {
 	return &KeyStorage{
 		db,
+		common.DefaultTenant,
 	}
 }
 
-var sqlCreateKey = "INSERT INTO `keys` (`key`, `desc`, created_at, updated_at) VALUES (?, ?, ?, ?);"
+// WithTenant returns a storage working on keys of the tenant
+func (st *KeyStorage) WithTenant(tenantID int64) keymgr.Storer {
+	return &KeyStorage{st.db, tenantID}
+}
+
+var sqlCreateKey = "INSERT INTO `keys` (tenant_id, application_id, `key`, `desc`, created_at, updated_at) " +
+	"VALUES (?, ?, ?, ?, ?, ?);"
 
-func (st *KeyStorage) AddKey(name string, desc string) (int64, error) {
-	stmt, err := st.db.Prepare(sqlCreateKey)
+// AddKey creates the key, in the application unless applicationID is zero
+func (st *KeyStorage) AddKey(name string, desc string, applicationID int64) (int64, error) {
+	tx, err := st.db.Beginx()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
 	now := time.Now()
-	res, err := stmt.Exec(name, desc, now, now)
+	res, err := tx.Exec(sqlCreateKey, st.tenant, sql.NullInt64{Int64: applicationID, Valid: applicationID > 0}, name, desc, now, now)
 	if err != nil {
 		return 0, err
 	}
@@ -40,13 +52,19 @@ func (st *KeyStorage) AddKey(name string, desc string) (int64, error) {
 		return 0, err
 	}
 
-	return lastID, nil
+	err = addOutboxEvent(tx, st.tenant, webhook.KeyCreated, map[string]interface{}{"id": lastID, "key": name})
+	if err != nil {
+		return 0, err
+	}
+
+	return lastID, tx.Commit()
 }
 
-var sqlGetKeyByName = "SELECT id, `key`, `desc`, created_at, updated_at FROM `keys` WHERE `key` = ? LIMIT 1;"
+var sqlGetKeyByName = "SELECT id, `key`, `desc`, created_at, updated_at FROM `keys` " +
+	"WHERE tenant_id = ? AND `key` = ? LIMIT 1;"
 
 func (st *KeyStorage) GetKeyByName(name string) (*keymgr.Key, error) {
-	rows, err := st.db.Queryx(sqlGetKeyByName, name)
+	rows, err := st.db.Queryx(sqlGetKeyByName, st.tenant, name)
 	if err != nil {
 		return nil, err
 	}
@@ -64,10 +82,11 @@ func (st *KeyStorage) GetKeyByName(name string) (*keymgr.Key, error) {
 	return key, nil
 }
 
-var sqlGetKeyByID = "SELECT id, `key`, `desc`, created_at, updated_at FROM `keys` WHERE id = ? LIMIT 1;"
+var sqlGetKeyByID = "SELECT id, `key`, `desc`, created_at, updated_at FROM `keys` " +
+	"WHERE tenant_id = ? AND id = ? LIMIT 1;"
 
 func (st *KeyStorage) GetKey(id int64) (*keymgr.Key, error) {
-	rows, err := st.db.Queryx(sqlGetKeyByID, id)
+	rows, err := st.db.Queryx(sqlGetKeyByID, st.tenant, id)
 	if err != nil {
 		return nil, err
 	}
@@ -85,10 +104,10 @@ func (st *KeyStorage) GetKey(id int64) (*keymgr.Key, error) {
 	return key, nil
 }
 
-var sqlGetBunchID = "SELECT id FROM `bunches` WHERE `name` = ? LIMIT 1;"
+var sqlGetBunchID = "SELECT id FROM `bunches` WHERE tenant_id = ? AND `name` = ? LIMIT 1;"
 
 func (st *KeyStorage) GetBunchID(name string) (int64, error) {
-	rows, err := st.db.Queryx(sqlGetBunchID, name)
+	rows, err := st.db.Queryx(sqlGetBunchID, st.tenant, name)
 	if err != nil {
 		return 0, err
 	}
@@ -106,34 +125,62 @@ func (st *KeyStorage) GetBunchID(name string) (int64, error) {
 	return id, nil
 }
 
-var sqlUpdateKeys = "UPDATE `keys` SET `key` = :key, `desc` = :desc, updated_at = :updated_at WHERE `keys`.id = :id;"
+var sqlUpdateKeys = "UPDATE `keys` SET `key` = :key, `desc` = :desc, updated_at = :updated_at " +
+	"WHERE `keys`.id = :id AND `keys`.tenant_id = :tenant_id;"
 
 func (st *KeyStorage) ModifyKey(id int64, name string, desc string) error {
 	updating := map[string]interface{}{
 		"key":        name,
 		"desc":       desc,
 		"id":         id,
+		"tenant_id":  st.tenant,
 		"updated_at": time.Now(),
 	}
 
-	_, err := st.db.NamedExec(sqlUpdateKeys, updating)
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.NamedExec(sqlUpdateKeys, updating)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if affected > 0 {
+		err = addOutboxEvent(tx, st.tenant, webhook.KeyUpdated, map[string]interface{}{"id": id, "key": name})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-var sqlAddKeyToBunch = "INSERT INTO `bunch_keys` (bunch_id, key_id, created_at) VALUES (:bunch_id, :key_id, :created_at);"
+var sqlAddKeyToBunch = "INSERT INTO `bunch_keys` (tenant_id, bunch_id, key_id, created_at) " +
+	"VALUES (:tenant_id, :bunch_id, :key_id, :created_at);"
 
 func (st *KeyStorage) AddKeyToBunch(keyID int64, bunchID int64) (int64, error) {
 	updating := map[string]interface{}{
+		"tenant_id":  st.tenant,
 		"bunch_id":   bunchID,
 		"key_id":     keyID,
 		"created_at": time.Now(),
 	}
 
-	res, err := st.db.NamedExec(sqlAddKeyToBunch, updating)
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.NamedExec(sqlAddKeyToBunch, updating)
 	if err != nil {
 		return 0, err
 	}
@@ -143,19 +190,23 @@ func (st *KeyStorage) AddKeyToBunch(keyID int64, bunchID int64) (int64, error) {
 		return 0, err
 	}
 
-	return lastID, nil
+	if err := addBunchKeyEvents(tx, st.tenant, webhook.BunchKeyAdded, bunchID, []int64{keyID}); err != nil {
+		return 0, err
+	}
+
+	return lastID, tx.Commit()
 }
 
 var sqlQueryKeys = "SELECT id, `key`, `desc`, created_at, updated_at FROM `keys` %s ORDER BY %s LIMIT :offset, :limit;"
 var sqlQueryKeysCounter = "SELECT count(id) FROM `keys` %s;"
 
-func (st *KeyStorage) QueryKeys(take int64, skip int64, name string, sortby string,
+func (st *KeyStorage) QueryKeys(take int64, skip int64, name string, application string, sortby string,
 	direction common.SortingDirection) ([]*keymgr.Key, int64, error) {
 
 	var (
 		order         string
 		where         string
-		sql           string
+		query         string
 		filter        map[string]interface{}
 		wg            sync.WaitGroup
 		queryErr      error
@@ -166,18 +217,26 @@ func (st *KeyStorage) QueryKeys(take int64, skip int64, name string, sortby stri
 
 	filter = make(map[string]interface{})
 
+	where = "WHERE tenant_id = :tenant_id"
+	filter["tenant_id"] = st.tenant
+
 	if len(name) > 0 {
-		where = "WHERE `key` LIKE :name"
+		where += " AND `key` LIKE :name"
 		filter["name"] = "%" + name + "%"
 	}
 
+	if len(application) > 0 {
+		where += " AND application_id = (SELECT id FROM applications WHERE tenant_id = :tenant_id AND `name` = :application)"
+		filter["application"] = application
+	}
+
 	if direction == common.Descending {
 		order = fmt.Sprintf("`%s` DESC, id", sortby)
 	} else {
 		order = fmt.Sprintf("`%s` ASC, id", sortby)
 	}
 
-	sql = fmt.Sprintf(sqlQueryKeys, where, order)
+	query = fmt.Sprintf(sqlQueryKeys, where, order)
 
 	filter["offset"] = skip
 	filter["limit"] = take
@@ -185,7 +244,7 @@ func (st *KeyStorage) QueryKeys(take int64, skip int64, name string, sortby stri
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		rows, err := st.db.NamedQuery(sql, filter)
+		rows, err := st.db.NamedQuery(query, filter)
 		if err != nil {
 			queryErr = err
 			return
@@ -14,7 +14,7 @@ func TestStorage_AddServiceKey(t *testing.T) {
 		key := test.mig.createUniqueString("key")
 		desc := test.mig.createUniqueString("desc")
 
-		id, err := test.kst.AddKey(key, desc)
+		id, err := test.kst.AddKey(key, desc, 0)
 		require.Nil(t, err)
 		require.NotZero(t, id)
 	})
@@ -31,7 +31,7 @@ func TestStorage_AddServiceKey(t *testing.T) {
 			fields["desc"] = desc1
 		})
 
-		dupID, errDup := test.kst.AddKey(key, desc2)
+		dupID, errDup := test.kst.AddKey(key, desc2, 0)
 		require.NotNil(t, errDup)
 		require.Zero(t, dupID)
 	})
@@ -11,10 +11,20 @@ import (
 )
 
 type testApp struct {
-	mig *Migrator
-	kst *KeyStorage
-	bst *BunchStorage
-	ust *UserStorage
+	mig   *Migrator
+	kst   *KeyStorage
+	bst   *BunchStorage
+	ust   *UserStorage
+	rst   *ReviewStorage
+	mst   *ManifestStorage
+	bkst  *BackupStorage
+	ist   *IdentityStorage
+	tst   *TokenStorage
+	tnst  *TenantStorage
+	relst *RelationStorage
+	adst  *AuditStorage
+	sest  *SessionStorage
+	whst  *WebhookStorage
 }
 
 var test *testApp
@@ -27,10 +37,20 @@ func TestMain(m *testing.M) {
 	}
 
 	test = &testApp{
-		mig: NewMigrator(db),
-		kst: NewKeyStorage(db),
-		bst: NewBunchStorage(db),
-		ust: NewUserStorage(db),
+		mig:   NewMigrator(db),
+		kst:   NewKeyStorage(db),
+		bst:   NewBunchStorage(db),
+		ust:   NewUserStorage(db),
+		rst:   NewReviewStorage(db),
+		mst:   NewManifestStorage(db),
+		bkst:  NewBackupStorage(db),
+		ist:   NewIdentityStorage(db),
+		tst:   NewTokenStorage(db),
+		tnst:  NewTenantStorage(db),
+		relst: NewRelationStorage(db),
+		adst:  NewAuditStorage(db),
+		sest:  NewSessionStorage(db),
+		whst:  NewWebhookStorage(db),
 	}
 
 	test.mig.Drop()
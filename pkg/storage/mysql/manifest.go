@@ -0,0 +1,378 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/manifest"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"strings"
+	"time"
+)
+
+// ManifestStorage implements db's storage for applying manifests
+type ManifestStorage struct {
+	db     *sqlx.DB
+	tenant int64
+}
+
+// NewManifestStorage create new instance of ManifestStorage, working on the default tenant
+func NewManifestStorage(db *sqlx.DB) *ManifestStorage {
+	return &ManifestStorage{
+		db,
+		common.DefaultTenant,
+	}
+}
+
+// WithTenant returns a storage working on the authorization model of the tenant
+func (st *ManifestStorage) WithTenant(tenantID int64) manifest.Storer {
+	return &ManifestStorage{st.db, tenantID}
+}
+
+// keys of applications are managed through their applications, manifests leave them alone
+var sqlStateKeys = "SELECT `key`, `desc` FROM `keys` WHERE tenant_id = ? AND application_id IS NULL;"
+var sqlStateBunches = "SELECT `name`, `desc`, active FROM bunches WHERE tenant_id = ?;"
+var sqlStateBunchKeys = "SELECT bunches.`name`, `keys`.`key` FROM bunch_keys " +
+	"INNER JOIN bunches ON bunches.id = bunch_keys.bunch_id " +
+	"INNER JOIN `keys` ON `keys`.id = bunch_keys.key_id " +
+	"WHERE bunch_keys.tenant_id = ? AND `keys`.application_id IS NULL ORDER BY `keys`.`key`;"
+var sqlStateUsers = "SELECT username FROM users WHERE tenant_id = ?;"
+var sqlStateUserBunches = "SELECT users.username, bunches.`name` FROM user_bunches " +
+	"INNER JOIN users ON users.id = user_bunches.user_id " +
+	"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id WHERE user_bunches.tenant_id = ? " +
+	"ORDER BY bunches.`name`;"
+var sqlStateExclusions = "SELECT bunch_exclusions.`name`, bunches.`name` FROM bunch_exclusion_members " +
+	"INNER JOIN bunch_exclusions ON bunch_exclusions.id = bunch_exclusion_members.exclusion_id " +
+	"INNER JOIN bunches ON bunches.id = bunch_exclusion_members.bunch_id WHERE bunch_exclusions.tenant_id = ?;"
+
+// GetState reads the whole authorization model in one consistent snapshot
+func (st *ManifestStorage) GetState() (*manifest.State, error) {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return st.getState(tx, func(query string) string { return query })
+}
+
+// lockInShareMode turns the query into a locking read, rows which it reads can't be changed by others until
+// the transaction ends
+func lockInShareMode(query string) string {
+	return strings.TrimSuffix(query, ";") + " LOCK IN SHARE MODE;"
+}
+
+// getState reads the authorization model in the transaction, read turns queries into the reads to run
+func (st *ManifestStorage) getState(tx *sqlx.Tx, read func(query string) string) (*manifest.State, error) {
+	state := &manifest.State{
+		Keys:        make(map[string]string),
+		Bunches:     make(map[string]*manifest.Bunch),
+		Users:       make(map[string]bool),
+		UserBunches: make(map[string][]string),
+		Exclusions:  make(map[string][]string),
+	}
+
+	err := queryPairs(tx, read(sqlStateKeys), st.tenant, func(key string, desc string) {
+		state.Keys[key] = desc
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Queryx(read(sqlStateBunches), st.tenant)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		b := new(manifest.Bunch)
+		active := new(bool)
+		if err := rows.Scan(&b.Name, &b.Desc, active); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		b.Active = active
+		state.Bunches[b.Name] = b
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	err = queryPairs(tx, read(sqlStateBunchKeys), st.tenant, func(bunch string, key string) {
+		state.Bunches[bunch].Keys = append(state.Bunches[bunch].Keys, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Queryx(read(sqlStateUsers), st.tenant)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		state.Users[username] = true
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	err = queryPairs(tx, read(sqlStateUserBunches), st.tenant, func(username string, bunch string) {
+		state.UserBunches[username] = append(state.UserBunches[username], bunch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryPairs(tx, read(sqlStateExclusions), st.tenant, func(exclusion string, bunch string) {
+		state.Exclusions[exclusion] = append(state.Exclusions[exclusion], bunch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+var (
+	sqlManifestCreateKey   = "INSERT INTO `keys` (tenant_id, `key`, `desc`, created_at, updated_at) VALUES (?, ?, ?, ?, ?);"
+	sqlManifestUpdateKey   = "UPDATE `keys` SET `desc` = ?, updated_at = ? WHERE tenant_id = ? AND `key` = ?;"
+	sqlManifestDeleteKey   = "DELETE FROM `keys` WHERE tenant_id = ? AND `key` = ?;"
+	sqlManifestCreateBunch = "INSERT INTO bunches (tenant_id, `name`, `desc`, active, created_at, updated_at) " +
+		"VALUES (?, ?, ?, ?, ?, ?);"
+	sqlManifestUpdateBunch = "UPDATE bunches SET `desc` = ?, active = ?, updated_at = ? WHERE tenant_id = ? AND `name` = ?;"
+	sqlManifestDeleteBunch = "DELETE FROM bunches WHERE tenant_id = ? AND `name` = ?;"
+	sqlManifestAddBunchKey = "INSERT INTO bunch_keys (tenant_id, bunch_id, key_id, created_at) " +
+		"SELECT bunches.tenant_id, bunches.id, `keys`.id, ? FROM bunches, `keys` " +
+		"WHERE bunches.tenant_id = ? AND `keys`.tenant_id = bunches.tenant_id " +
+		"AND bunches.`name` = ? AND `keys`.`key` = ?;"
+	sqlManifestRemoveBunchKey = "DELETE bunch_keys FROM bunch_keys " +
+		"INNER JOIN bunches ON bunches.id = bunch_keys.bunch_id " +
+		"INNER JOIN `keys` ON `keys`.id = bunch_keys.key_id " +
+		"WHERE bunch_keys.tenant_id = ? AND bunches.`name` = ? AND `keys`.`key` = ?;"
+	sqlManifestAddUserBunch = "INSERT INTO user_bunches (tenant_id, user_id, bunch_id, created_at) " +
+		"SELECT users.tenant_id, users.id, bunches.id, ? FROM users, bunches " +
+		"WHERE users.tenant_id = ? AND bunches.tenant_id = users.tenant_id " +
+		"AND users.username = ? AND bunches.`name` = ?;"
+	sqlManifestRemoveUserBunch = "DELETE user_bunches FROM user_bunches " +
+		"INNER JOIN users ON users.id = user_bunches.user_id " +
+		"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id " +
+		"WHERE user_bunches.tenant_id = ? AND users.username = ? AND bunches.`name` = ?;"
+)
+
+var (
+	sqlManifestGetKeyID   = "SELECT id FROM `keys` WHERE tenant_id = ? AND `key` = ?;"
+	sqlManifestGetBunch   = "SELECT id, active FROM bunches WHERE tenant_id = ? AND `name` = ?;"
+	sqlManifestKeyHolders = "SELECT bunches.`name`, `keys`.`key` FROM bunch_keys " +
+		"INNER JOIN bunches ON bunches.id = bunch_keys.bunch_id " +
+		"INNER JOIN `keys` ON `keys`.id = bunch_keys.key_id WHERE bunch_keys.tenant_id = ? AND `keys`.`key` = ?;"
+	sqlManifestBunchMembers = "SELECT users.username, bunches.`name` FROM user_bunches " +
+		"INNER JOIN users ON users.id = user_bunches.user_id " +
+		"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id " +
+		"WHERE user_bunches.tenant_id = ? AND bunches.`name` = ?;"
+)
+
+var sqlLockManifestTenant = "SELECT id FROM tenants WHERE id = ? FOR UPDATE;"
+
+var errUnknownChange = errors.New("unknown manifest change")
+
+// Apply plans changes on the state and runs them in one transaction, nothing is written if one of them fails.
+// Manifests of a tenant are applied one at a time, and the state is read with locks, so that it can't change
+// between the plan and its changes.
+//
+// Events of the changes are written along with them, rows which go with deleted keys and bunches are told
+// about before they're deleted.
+func (st *ManifestStorage) Apply(plan func(state *manifest.State) ([]*manifest.Change, error)) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqlLockManifestTenant, st.tenant); err != nil {
+		return err
+	}
+
+	state, err := st.getState(tx, lockInShareMode)
+	if err != nil {
+		return err
+	}
+
+	changes, err := plan(state)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, c := range changes {
+		if err := st.apply(tx, c, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (st *ManifestStorage) apply(tx *sqlx.Tx, c *manifest.Change, now time.Time) error {
+	switch c.Kind + "." + c.Action {
+	case manifest.KindKey + "." + manifest.ActionCreate:
+		res, err := tx.Exec(sqlManifestCreateKey, st.tenant, c.Name, c.Desc, now, now)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		return addOutboxEvent(tx, st.tenant, webhook.KeyCreated, map[string]interface{}{"id": id, "key": c.Name})
+
+	case manifest.KindKey + "." + manifest.ActionUpdate:
+		var id int64
+		if err := tx.Get(&id, sqlManifestGetKeyID, st.tenant, c.Name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqlManifestUpdateKey, c.Desc, now, st.tenant, c.Name); err != nil {
+			return err
+		}
+		return addOutboxEvent(tx, st.tenant, webhook.KeyUpdated, map[string]interface{}{"id": id, "key": c.Name})
+
+	case manifest.KindKey + "." + manifest.ActionDelete:
+		err := st.addNamedEvents(tx, webhook.BunchKeyRemoved, [2]string{"bunch", "key"}, sqlManifestKeyHolders, c.Name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(sqlManifestDeleteKey, st.tenant, c.Name)
+		return err
+
+	case manifest.KindBunch + "." + manifest.ActionCreate:
+		res, err := tx.Exec(sqlManifestCreateBunch, st.tenant, c.Name, c.Desc, c.Active, now, now)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		return addOutboxEvent(tx, st.tenant, webhook.BunchCreated, map[string]interface{}{"id": id, "name": c.Name})
+
+	case manifest.KindBunch + "." + manifest.ActionUpdate:
+		var current struct {
+			ID     int64 `db:"id"`
+			Active bool  `db:"active"`
+		}
+		if err := tx.Get(&current, sqlManifestGetBunch, st.tenant, c.Name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqlManifestUpdateBunch, c.Desc, c.Active, now, st.tenant, c.Name); err != nil {
+			return err
+		}
+
+		err := addOutboxEvent(tx, st.tenant, webhook.BunchUpdated, map[string]interface{}{
+			"id":      current.ID,
+			"name":    c.Name,
+			"changes": []string{"desc"},
+		})
+		if err != nil {
+			return err
+		}
+		if typ := activity(current.Active, c.Active, webhook.BunchActivated, webhook.BunchDeactivated); len(typ) > 0 {
+			return addOutboxEvent(tx, st.tenant, typ, map[string]interface{}{"id": current.ID, "name": c.Name})
+		}
+		return nil
+
+	case manifest.KindBunch + "." + manifest.ActionDelete:
+		err := st.addNamedEvents(tx, webhook.GrantRevoked, [2]string{"username", "bunch"}, sqlManifestBunchMembers,
+			c.Name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(sqlManifestDeleteBunch, st.tenant, c.Name)
+		return err
+
+	case manifest.KindBunchKey + "." + manifest.ActionCreate:
+		res, err := tx.Exec(sqlManifestAddBunchKey, now, st.tenant, c.Name, c.Target)
+		return st.addChangedEvent(tx, res, err, webhook.BunchKeyAdded,
+			map[string]interface{}{"bunch": c.Name, "key": c.Target})
+
+	case manifest.KindBunchKey + "." + manifest.ActionDelete:
+		res, err := tx.Exec(sqlManifestRemoveBunchKey, st.tenant, c.Name, c.Target)
+		return st.addChangedEvent(tx, res, err, webhook.BunchKeyRemoved,
+			map[string]interface{}{"bunch": c.Name, "key": c.Target})
+
+	case manifest.KindUserBunch + "." + manifest.ActionCreate:
+		res, err := tx.Exec(sqlManifestAddUserBunch, now, st.tenant, c.Name, c.Target)
+		return st.addChangedEvent(tx, res, err, webhook.GrantAdded,
+			map[string]interface{}{"username": c.Name, "bunch": c.Target})
+
+	case manifest.KindUserBunch + "." + manifest.ActionDelete:
+		res, err := tx.Exec(sqlManifestRemoveUserBunch, st.tenant, c.Name, c.Target)
+		return st.addChangedEvent(tx, res, err, webhook.GrantRevoked,
+			map[string]interface{}{"username": c.Name, "bunch": c.Target})
+	}
+
+	return errUnknownChange
+}
+
+// addChangedEvent writes the event when the statement has changed a row
+func (st *ManifestStorage) addChangedEvent(tx *sqlx.Tx, res sql.Result, err error, typ string,
+	payload map[string]interface{}) error {
+
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return err
+	}
+
+	return addOutboxEvent(tx, st.tenant, typ, payload)
+}
+
+// addNamedEvents writes an event for each row of two names which the query finds for the name, the names are set
+// to the payload's fields. Rows are all read before any event is written.
+func (st *ManifestStorage) addNamedEvents(tx *sqlx.Tx, typ string, fields [2]string, query string,
+	name string) error {
+
+	pairs := make([][2]string, 0)
+	err := queryPairs(tx, query, st.tenant, func(a string, b string) {
+		pairs = append(pairs, [2]string{a, b})
+	}, name)
+	if err != nil {
+		return err
+	}
+
+	for _, pair := range pairs {
+		err := addOutboxEvent(tx, st.tenant, typ, map[string]interface{}{fields[0]: pair[0], fields[1]: pair[1]})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryPairs scans rows of two string columns of the tenant, args follow the tenant in the query
+func queryPairs(tx *sqlx.Tx, query string, tenant int64, fn func(string, string), args ...interface{}) error {
+	rows, err := tx.Queryx(query, append([]interface{}{tenant}, args...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a, b string
+		if err := rows.Scan(&a, &b); err != nil {
+			return err
+		}
+		fn(a, b)
+	}
+
+	return rows.Err()
+}
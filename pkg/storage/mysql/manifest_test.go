@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/manifest"
+	"testing"
+)
+
+func TestManifestStorage_GetState(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_read_state", func(t *testing.T) {
+		t.Parallel()
+
+		key := test.mig.createUniqueString("key")
+		bunch := test.mig.createUniqueString("bunch")
+		username := test.mig.createUniqueString("username")
+		kID := test.mig.createSeedingServiceKey(func(fields map[string]interface{}) { fields["key"] = key })
+		bID := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch })
+		uID := test.mig.createSeedingUser(func(fields map[string]interface{}) { fields["username"] = username })
+
+		require.Nil(t, test.bst.AddKeysToBunch(bID, []int64{kID}))
+		require.Nil(t, test.ust.AddBunchesToUser(uID, []int64{bID}, nil))
+
+		state, err := test.mst.GetState()
+		require.Nil(t, err)
+		require.Contains(t, state.Keys, key)
+		require.Contains(t, state.Bunches, bunch)
+		require.Equal(t, []string{key}, state.Bunches[bunch].Keys)
+		require.True(t, state.Users[username])
+		require.Equal(t, []string{bunch}, state.UserBunches[username])
+	})
+}
+
+func TestManifestStorage_Apply(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_apply_changes", func(t *testing.T) {
+		t.Parallel()
+
+		key := test.mig.createUniqueString("key")
+		bunch := test.mig.createUniqueString("bunch")
+		username := test.mig.createUniqueString("username")
+		test.mig.createSeedingUser(func(fields map[string]interface{}) { fields["username"] = username })
+
+		err := test.mst.Apply(changes(
+			&manifest.Change{Action: manifest.ActionCreate, Kind: manifest.KindKey, Name: key, Desc: "desc"},
+			&manifest.Change{Action: manifest.ActionCreate, Kind: manifest.KindBunch, Name: bunch, Active: true},
+			&manifest.Change{Action: manifest.ActionCreate, Kind: manifest.KindBunchKey, Name: bunch, Target: key},
+			&manifest.Change{Action: manifest.ActionCreate, Kind: manifest.KindUserBunch, Name: username,
+				Target: bunch},
+		))
+		require.Nil(t, err)
+
+		keys, err := test.ust.GetKeys(username)
+		require.Nil(t, err)
+		require.Len(t, keys, 1)
+		require.Equal(t, key, keys[0].Key)
+	})
+
+	t.Run("fail_rollback_all_changes", func(t *testing.T) {
+		t.Parallel()
+
+		key := test.mig.createUniqueString("key")
+
+		err := test.mst.Apply(changes(
+			&manifest.Change{Action: manifest.ActionCreate, Kind: manifest.KindKey, Name: key},
+			&manifest.Change{Action: manifest.ActionCreate, Kind: manifest.KindKey, Name: key},
+		))
+		require.NotNil(t, err)
+
+		k, err := test.kst.GetKeyByName(key)
+		require.Nil(t, err)
+		require.Nil(t, k)
+	})
+
+	t.Run("success_plan_on_state_of_the_transaction", func(t *testing.T) {
+		t.Parallel()
+
+		key := test.mig.createUniqueString("key")
+		test.mig.createSeedingServiceKey(func(fields map[string]interface{}) { fields["key"] = key })
+
+		err := test.mst.Apply(func(state *manifest.State) ([]*manifest.Change, error) {
+			require.Contains(t, state.Keys, key)
+			return nil, nil
+		})
+		require.Nil(t, err)
+	})
+
+	t.Run("fail_plan_write_nothing", func(t *testing.T) {
+		t.Parallel()
+
+		err := test.mst.Apply(func(state *manifest.State) ([]*manifest.Change, error) {
+			return nil, common.ErrManifestPlanChanged
+		})
+		require.Equal(t, common.ErrManifestPlanChanged, err)
+	})
+}
+
+// changes returns a plan which makes the changes whatever the state
+func changes(lst ...*manifest.Change) func(state *manifest.State) ([]*manifest.Change, error) {
+	return func(state *manifest.State) ([]*manifest.Change, error) {
+		return lst, nil
+	}
+}
@@ -0,0 +1,166 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"time"
+)
+
+var (
+	sqlNextOutboxSeq = "INSERT INTO outbox_sequences (tenant_id, seq) VALUES (?, LAST_INSERT_ID(1)) " +
+		"ON DUPLICATE KEY UPDATE seq = LAST_INSERT_ID(seq + 1);"
+	sqlAddOutboxEvent = "INSERT INTO outbox_events (tenant_id, seq, `type`, payload, created_at) " +
+		"VALUES (?, ?, ?, ?, ?);"
+)
+
+// addOutboxEvent writes the domain event in the transaction of the change it tells about, so that the event
+// is published if and only if the change is committed.
+//
+// Events are numbered by a sequence of the tenant. Its row stays locked until the transaction ends, so a
+// transaction taking a number waits for the ones which took lower numbers to commit or roll back, and events are
+// committed in the order of their numbers. Ids are handed out as statements run, a lower id may be committed
+// after a higher one has been read, so readers resume from numbers rather than from ids.
+func addOutboxEvent(tx *sqlx.Tx, tenant int64, typ string, payload map[string]interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(sqlNextOutboxSeq, tenant)
+	if err != nil {
+		return err
+	}
+	seq, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(sqlAddOutboxEvent, tenant, seq, typ, string(data), time.Now())
+	return err
+}
+
+var sqlGetGrantNames = "SELECT users.`username`, bunches.`name` FROM user_bunches " +
+	"INNER JOIN users ON users.id = user_bunches.user_id " +
+	"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id " +
+	"WHERE user_bunches.tenant_id = ? AND user_bunches.user_id = ? AND user_bunches.bunch_id IN (%s);"
+
+// addGrantEvents writes an event for each of the user's bunches which is in bunchIDs
+func addGrantEvents(tx *sqlx.Tx, tenant int64, typ string, userID int64, bunchIDs []int64) error {
+	return addPairEvents(tx, sqlGetGrantNames, tenant, userID, bunchIDs, func(username, bunch string) error {
+		return addOutboxEvent(tx, tenant, typ, map[string]interface{}{"username": username, "bunch": bunch})
+	})
+}
+
+var sqlGetBunchKeyNames = "SELECT bunches.`name`, `keys`.`key` FROM bunch_keys " +
+	"INNER JOIN bunches ON bunches.id = bunch_keys.bunch_id " +
+	"INNER JOIN `keys` ON `keys`.id = bunch_keys.key_id " +
+	"WHERE bunch_keys.tenant_id = ? AND bunch_keys.bunch_id = ? AND bunch_keys.key_id IN (%s);"
+
+// addBunchKeyEvents writes an event for each of the bunch's keys which is in keyIDs
+func addBunchKeyEvents(tx *sqlx.Tx, tenant int64, typ string, bunchID int64, keyIDs []int64) error {
+	return addPairEvents(tx, sqlGetBunchKeyNames, tenant, bunchID, keyIDs, func(bunch, key string) error {
+		return addOutboxEvent(tx, tenant, typ, map[string]interface{}{"bunch": bunch, "key": key})
+	})
+}
+
+// addConditionEvent writes the event telling that the condition of a member has changed, query looks up the
+// names of the owner and of the member which are set to the payload's fields
+func addConditionEvent(tx *sqlx.Tx, query string, tenant int64, typ string, ownerID int64, memberID int64,
+	fields [2]string, expression sql.NullString) error {
+
+	return addPairEvents(tx, query, tenant, ownerID, []int64{memberID}, func(owner, member string) error {
+		return addOutboxEvent(tx, tenant, typ, map[string]interface{}{
+			fields[0]:   owner,
+			fields[1]:   member,
+			"condition": expression.String,
+		})
+	})
+}
+
+var (
+	sqlGetNewUserDenialNames = "SELECT users.`username`, `keys`.`key` FROM users " +
+		"INNER JOIN `keys` ON `keys`.tenant_id = users.tenant_id " +
+		"WHERE users.tenant_id = ? AND users.id = ? AND `keys`.id IN (%s) AND NOT EXISTS " +
+		"(SELECT 1 FROM user_denials WHERE user_denials.user_id = users.id AND user_denials.key_id = `keys`.id);"
+	sqlGetUserDenialNames = "SELECT users.`username`, `keys`.`key` FROM user_denials " +
+		"INNER JOIN users ON users.id = user_denials.user_id " +
+		"INNER JOIN `keys` ON `keys`.id = user_denials.key_id " +
+		"WHERE user_denials.tenant_id = ? AND user_denials.user_id = ? AND user_denials.key_id IN (%s);"
+	sqlGetNewBunchDenialNames = "SELECT bunches.`name`, `keys`.`key` FROM bunches " +
+		"INNER JOIN `keys` ON `keys`.tenant_id = bunches.tenant_id " +
+		"WHERE bunches.tenant_id = ? AND bunches.id = ? AND `keys`.id IN (%s) AND NOT EXISTS " +
+		"(SELECT 1 FROM bunch_denials WHERE bunch_denials.bunch_id = bunches.id AND bunch_denials.key_id = `keys`.id);"
+	sqlGetBunchDenialNames = "SELECT bunches.`name`, `keys`.`key` FROM bunch_denials " +
+		"INNER JOIN bunches ON bunches.id = bunch_denials.bunch_id " +
+		"INNER JOIN `keys` ON `keys`.id = bunch_denials.key_id " +
+		"WHERE bunch_denials.tenant_id = ? AND bunch_denials.bunch_id = ? AND bunch_denials.key_id IN (%s);"
+)
+
+// addDenialEvents writes an event for each key in keyIDs which query finds for the owner, denials are looked up
+// before they're written or removed so that only the ones which change are told about
+func addDenialEvents(tx *sqlx.Tx, query string, tenant int64, typ string, ownerID int64, keyIDs []int64,
+	field string) error {
+
+	return addPairEvents(tx, query, tenant, ownerID, keyIDs, func(owner, key string) error {
+		return addOutboxEvent(tx, tenant, typ, map[string]interface{}{field: owner, "key": key})
+	})
+}
+
+// addPairEvents looks up names of the owner and of its members, and calls add for each pair of them.
+// Rows are all read before any event is written, as the transaction can't run statements while reading.
+func addPairEvents(tx *sqlx.Tx, query string, tenant int64, ownerID int64, ids []int64,
+	add func(owner string, member string) error) error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	conditions := make([]string, 0, len(ids))
+	values := make([]interface{}, 0, len(ids)+2)
+	values = append(values, tenant, ownerID)
+	for _, id := range ids {
+		conditions = append(conditions, "?")
+		values = append(values, id)
+	}
+
+	rows, err := tx.Queryx(fmt.Sprintf(query, strings.Join(conditions, ",")), values...)
+	if err != nil {
+		return err
+	}
+
+	pairs := make([][2]string, 0, len(ids))
+	for rows.Next() {
+		var pair [2]string
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			rows.Close()
+			return err
+		}
+		pairs = append(pairs, pair)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	for _, pair := range pairs {
+		if err := add(pair[0], pair[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// activity returns the event telling that active has changed from was, or an empty string when it hasn't
+func activity(was bool, active bool, activated string, deactivated string) string {
+	switch {
+	case !was && active:
+		return activated
+	case was && !active:
+		return deactivated
+	}
+	return ""
+}
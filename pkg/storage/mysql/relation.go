@@ -0,0 +1,189 @@
+package mysql
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/rebac"
+	"time"
+)
+
+// RelationStorage implements db's storage for relation tuples. Tuples are never updated, a write takes the next
+// revision of the tenant, added tuples live from it and removed ones stop living at it, so reading at a revision
+// gives a consistent snapshot. Living tuples have a deleted revision of 0, which keeps them unique.
+type RelationStorage struct {
+	db     *sqlx.DB
+	tenant int64
+}
+
+// NewRelationStorage create new instance of RelationStorage, working on the default tenant
+func NewRelationStorage(db *sqlx.DB) *RelationStorage {
+	return &RelationStorage{
+		db,
+		common.DefaultTenant,
+	}
+}
+
+// WithTenant returns a storage working on tuples of the tenant
+func (st *RelationStorage) WithTenant(tenantID int64) rebac.Storer {
+	return &RelationStorage{st.db, tenantID}
+}
+
+var sqlSaveNamespace = "INSERT INTO rebac_namespaces (tenant_id, `name`, config, created_at, updated_at) " +
+	"VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE config = VALUES(config), updated_at = VALUES(updated_at);"
+
+func (st *RelationStorage) SaveNamespace(name string, config string) error {
+	now := time.Now()
+	_, err := st.db.Exec(sqlSaveNamespace, st.tenant, name, config, now, now)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var sqlGetNamespace = "SELECT config FROM rebac_namespaces WHERE tenant_id = ? AND `name` = ? LIMIT 1;"
+
+// GetNamespace returns config of the namespace, empty when there isn't one
+func (st *RelationStorage) GetNamespace(name string) (string, error) {
+	rows, err := st.db.Queryx(sqlGetNamespace, st.tenant, name)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", nil
+	}
+
+	var config string
+	if err := rows.Scan(&config); err != nil {
+		return "", err
+	}
+
+	return config, nil
+}
+
+var (
+	sqlNextRelationRev = "INSERT INTO relation_revisions (tenant_id, rev) VALUES (?, LAST_INSERT_ID(1)) " +
+		"ON DUPLICATE KEY UPDATE rev = LAST_INSERT_ID(rev + 1);"
+	sqlRemoveTuple = "UPDATE relation_tuples SET deleted_rev = ? WHERE tenant_id = ? AND namespace = ? " +
+		"AND object_id = ? AND relation = ? AND subject_user = ? AND subject_namespace = ? AND subject_object = ? " +
+		"AND subject_relation = ? AND deleted_rev = 0;"
+	sqlAddTuple = "INSERT INTO relation_tuples (tenant_id, namespace, object_id, relation, subject_user, " +
+		"subject_namespace, subject_object, subject_relation, created_rev) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE id = id;"
+)
+
+// WriteTuples removes then adds tuples in one transaction and returns the revision of the change, adding a living
+// tuple or removing a missing one is a no-op.
+//
+// The revision row of the tenant stays locked until the transaction ends, as the outbox's sequences do, so writes
+// of a tenant are committed in the order of their revisions and a revision which has been read never gains tuples
+// later.
+func (st *RelationStorage) WriteTuples(adds []*rebac.Tuple, removes []*rebac.Tuple) (int64, error) {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(sqlNextRelationRev, st.tenant)
+	if err != nil {
+		return 0, err
+	}
+
+	rev, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, t := range removes {
+		_, err := tx.Exec(sqlRemoveTuple, rev, st.tenant, t.Object.Namespace, t.Object.ID, t.Relation,
+			t.Subject.User, t.Subject.Object.Namespace, t.Subject.Object.ID, t.Subject.Relation)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, t := range adds {
+		_, err := tx.Exec(sqlAddTuple, st.tenant, t.Object.Namespace, t.Object.ID, t.Relation,
+			t.Subject.User, t.Subject.Object.Namespace, t.Subject.Object.ID, t.Subject.Relation, rev)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return rev, nil
+}
+
+var sqlGetRevision = "SELECT COALESCE(MAX(rev), 0) FROM relation_revisions WHERE tenant_id = ?;"
+
+// GetRevision returns the revision of the tenant's latest change
+func (st *RelationStorage) GetRevision() (int64, error) {
+	var rev int64
+	if err := st.db.Get(&rev, sqlGetRevision, st.tenant); err != nil {
+		return 0, err
+	}
+
+	return rev, nil
+}
+
+var sqlGetSubjects = "SELECT subject_user, subject_namespace, subject_object, subject_relation FROM relation_tuples " +
+	"WHERE tenant_id = ? AND namespace = ? AND object_id = ? AND relation = ? AND created_rev <= ? " +
+	"AND (deleted_rev = 0 OR deleted_rev > ?) ORDER BY id;"
+
+// GetSubjects returns subjects of the object's relation at the revision
+func (st *RelationStorage) GetSubjects(object rebac.Object, relation string, revision int64) ([]rebac.Subject, error) {
+	rows, err := st.db.Queryx(sqlGetSubjects, st.tenant, object.Namespace, object.ID, relation, revision, revision)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := make([]rebac.Subject, 0)
+	for rows.Next() {
+		var sub rebac.Subject
+		err := rows.Scan(&sub.User, &sub.Object.Namespace, &sub.Object.ID, &sub.Relation)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, sub)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return subjects, nil
+}
+
+var sqlGetObjectIDs = "SELECT DISTINCT object_id FROM relation_tuples WHERE tenant_id = ? AND namespace = ? " +
+	"AND created_rev <= ? AND (deleted_rev = 0 OR deleted_rev > ?) ORDER BY object_id;"
+
+// GetObjectIDs returns ids of the namespace's objects having tuples at the revision
+func (st *RelationStorage) GetObjectIDs(namespace string, revision int64) ([]string, error) {
+	rows, err := st.db.Queryx(sqlGetObjectIDs, st.tenant, namespace, revision, revision)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,98 @@
+package mysql
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/rebac"
+	"testing"
+)
+
+func TestRelationStorage_WriteTuples(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_read_at_revisions", func(t *testing.T) {
+		t.Parallel()
+
+		doc := rebac.Object{Namespace: "doc", ID: test.mig.createUniqueString("doc")}
+		alice := &rebac.Tuple{Object: doc, Relation: "viewer", Subject: rebac.Subject{User: "alice"}}
+		group := &rebac.Tuple{Object: doc, Relation: "viewer", Subject: rebac.Subject{
+			Object: rebac.Object{Namespace: "group", ID: "eng"}, Relation: "member"}}
+
+		first, err := test.relst.WriteTuples([]*rebac.Tuple{alice, group}, nil)
+		require.Nil(t, err)
+
+		second, err := test.relst.WriteTuples([]*rebac.Tuple{alice}, []*rebac.Tuple{group})
+		require.Nil(t, err)
+		require.True(t, second > first)
+
+		subjects, err := test.relst.GetSubjects(doc, "viewer", first)
+		require.Nil(t, err)
+		require.Len(t, subjects, 2)
+		require.Equal(t, "group:eng#member", subjects[1].String())
+
+		subjects, err = test.relst.GetSubjects(doc, "viewer", second)
+		require.Nil(t, err)
+		require.Len(t, subjects, 1)
+		require.Equal(t, "alice", subjects[0].User)
+
+		subjects, err = test.relst.GetSubjects(doc, "viewer", first-1)
+		require.Nil(t, err)
+		require.Len(t, subjects, 0)
+
+		rev, err := test.relst.GetRevision()
+		require.Nil(t, err)
+		require.True(t, rev >= second)
+	})
+
+	t.Run("success_living_tuple_kept_once", func(t *testing.T) {
+		t.Parallel()
+
+		doc := rebac.Object{Namespace: "doc", ID: test.mig.createUniqueString("doc")}
+		bob := &rebac.Tuple{Object: doc, Relation: "viewer", Subject: rebac.Subject{User: "bob"}}
+
+		_, err := test.relst.WriteTuples([]*rebac.Tuple{bob, bob}, nil)
+		require.Nil(t, err)
+
+		rev, err := test.relst.WriteTuples([]*rebac.Tuple{bob}, nil)
+		require.Nil(t, err)
+
+		subjects, err := test.relst.GetSubjects(doc, "viewer", rev)
+		require.Nil(t, err)
+		require.Len(t, subjects, 1)
+
+		// a removed tuple can be added again
+		removed, err := test.relst.WriteTuples(nil, []*rebac.Tuple{bob})
+		require.Nil(t, err)
+
+		added, err := test.relst.WriteTuples([]*rebac.Tuple{bob}, nil)
+		require.Nil(t, err)
+
+		subjects, err = test.relst.GetSubjects(doc, "viewer", removed)
+		require.Nil(t, err)
+		require.Len(t, subjects, 0)
+
+		subjects, err = test.relst.GetSubjects(doc, "viewer", added)
+		require.Nil(t, err)
+		require.Len(t, subjects, 1)
+	})
+}
+
+func TestRelationStorage_SaveNamespace(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_replace_config", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("ns")
+
+		config, err := test.relst.GetNamespace(name)
+		require.Nil(t, err)
+		require.Empty(t, config)
+
+		require.Nil(t, test.relst.SaveNamespace(name, `{"name":"a"}`))
+		require.Nil(t, test.relst.SaveNamespace(name, `{"name":"b"}`))
+
+		config, err = test.relst.GetNamespace(name)
+		require.Nil(t, err)
+		require.Equal(t, `{"name":"b"}`, config)
+	})
+}
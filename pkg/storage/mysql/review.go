@@ -0,0 +1,306 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/reviewmgr"
+	"strings"
+	"time"
+)
+
+// ReviewStorage implements db's storage for access review campaigns
+type ReviewStorage struct {
+	db     *sqlx.DB
+	tenant int64
+}
+
+// NewReviewStorage create new instance of ReviewStorage, working on campaigns of the default tenant
+func NewReviewStorage(db *sqlx.DB) *ReviewStorage {
+	return &ReviewStorage{
+		db,
+		common.DefaultTenant,
+	}
+}
+
+// WithTenant returns a storage working on campaigns of the tenant
+func (st *ReviewStorage) WithTenant(tenantID int64) reviewmgr.Storer {
+	return &ReviewStorage{st.db, tenantID}
+}
+
+var sqlAddCampaign = "INSERT INTO review_campaigns (tenant_id, `name`, `desc`, `scope`, targets, `status`, created_at) " +
+	"VALUES (?, ?, ?, ?, ?, ?, ?);"
+
+var sqlGetBunchGrants = "SELECT user_bunches.user_id, user_bunches.bunch_id FROM user_bunches " +
+	"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id " +
+	"WHERE bunches.tenant_id = ? AND bunches.`name` IN (%s) ORDER BY user_bunches.id"
+
+var sqlGetKeyGrants = "SELECT DISTINCT user_bunches.user_id, user_bunches.bunch_id FROM user_bunches " +
+	"INNER JOIN bunch_keys ON bunch_keys.bunch_id = user_bunches.bunch_id " +
+	"INNER JOIN `keys` ON `keys`.id = bunch_keys.key_id " +
+	"WHERE `keys`.tenant_id = ? AND `keys`.`key` IN (%s) ORDER BY user_bunches.user_id, user_bunches.bunch_id"
+
+var sqlAddReviewItem = "INSERT INTO review_items (campaign_id, user_id, bunch_id, reviewer_id, decision, created_at) " +
+	"VALUES (?, ?, ?, ?, ?, ?);"
+
+// AddCampaign creates a campaign and snapshots matching grants in one transaction. Reviewers are
+// assigned to the snapshotted grants in round-robin order.
+func (st *ReviewStorage) AddCampaign(name string, desc string, scope string, targets []string,
+	reviewerIDs []int64) (int64, error) {
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	res, err := tx.Exec(sqlAddCampaign, st.tenant, name, desc, scope, strings.Join(targets, ","),
+		reviewmgr.StatusOpen, now)
+	if err != nil {
+		return 0, err
+	}
+
+	campaignID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	conditions := make([]string, 0, len(targets))
+	values := make([]interface{}, 0, len(targets)+1)
+	values = append(values, st.tenant)
+	for _, t := range targets {
+		conditions = append(conditions, "?")
+		values = append(values, t)
+	}
+
+	query := sqlGetBunchGrants
+	if scope == reviewmgr.ScopeKey {
+		query = sqlGetKeyGrants
+	}
+
+	rows, err := tx.Queryx(fmt.Sprintf(query, strings.Join(conditions, ",")), values...)
+	if err != nil {
+		return 0, err
+	}
+
+	grants := make([][2]int64, 0)
+	for rows.Next() {
+		var g [2]int64
+		if err := rows.Scan(&g[0], &g[1]); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		grants = append(grants, g)
+	}
+	rows.Close()
+
+	if rows.Err() != nil {
+		return 0, rows.Err()
+	}
+
+	for i, g := range grants {
+		_, err := tx.Exec(sqlAddReviewItem, campaignID, g[0], g[1], reviewerIDs[i%len(reviewerIDs)],
+			reviewmgr.DecisionPending, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return campaignID, nil
+}
+
+var sqlGetCampaign = "SELECT id, `name`, `desc`, `scope`, targets, `status`, created_at, closed_at " +
+	"FROM review_campaigns WHERE id = ? AND tenant_id = ? LIMIT 1;"
+
+func (st *ReviewStorage) GetCampaign(id int64) (*reviewmgr.Campaign, error) {
+	rows, err := st.db.Queryx(sqlGetCampaign, id, st.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	var targets string
+	c := new(reviewmgr.Campaign)
+	err = rows.Scan(&c.ID, &c.Name, &c.Desc, &c.Scope, &targets, &c.Status, &c.CreatedAt, &c.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.Targets = strings.Split(targets, ",")
+
+	return c, nil
+}
+
+var sqlCloseCampaign = "UPDATE review_campaigns SET `status` = ?, closed_at = ? WHERE id = ? AND tenant_id = ?;"
+
+func (st *ReviewStorage) CloseCampaign(id int64) error {
+	_, err := st.db.Exec(sqlCloseCampaign, reviewmgr.StatusClosed, time.Now(), id, st.tenant)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var sqlSelectReviewItems = "SELECT review_items.id, review_items.campaign_id, review_items.user_id, users.username, " +
+	"review_items.bunch_id, bunches.`name`, reviewers.username, review_items.decision, review_items.`comment`, " +
+	"review_items.decided_at, review_items.created_at FROM review_items " +
+	"INNER JOIN users ON users.id = review_items.user_id " +
+	"INNER JOIN bunches ON bunches.id = review_items.bunch_id " +
+	"INNER JOIN users AS reviewers ON reviewers.id = review_items.reviewer_id "
+
+var sqlGetReviewItem = sqlSelectReviewItems + "WHERE review_items.id = ? AND users.tenant_id = ? LIMIT 1;"
+
+func (st *ReviewStorage) GetItem(id int64) (*reviewmgr.Item, error) {
+	rows, err := st.db.Queryx(sqlGetReviewItem, id, st.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	i := new(reviewmgr.Item)
+	err = rows.Scan(&i.ID, &i.CampaignID, &i.UserID, &i.Username, &i.BunchID, &i.Bunch, &i.Reviewer,
+		&i.Decision, &i.Comment, &i.DecidedAt, &i.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+var sqlGetReviewItems = sqlSelectReviewItems +
+	"WHERE review_items.campaign_id = :campaign_id AND users.tenant_id = :tenant_id %s ORDER BY review_items.id;"
+
+func (st *ReviewStorage) GetItems(campaignID int64, reviewer string, decision string) ([]*reviewmgr.Item, error) {
+	var where string
+	filter := map[string]interface{}{"campaign_id": campaignID, "tenant_id": st.tenant}
+
+	if len(reviewer) > 0 {
+		where += " AND reviewers.username = :reviewer"
+		filter["reviewer"] = reviewer
+	}
+
+	if len(decision) > 0 {
+		where += " AND review_items.decision = :decision"
+		filter["decision"] = decision
+	}
+
+	rows, err := st.db.NamedQuery(fmt.Sprintf(sqlGetReviewItems, where), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*reviewmgr.Item, 0)
+	for rows.Next() {
+		i := new(reviewmgr.Item)
+		err := rows.Scan(&i.ID, &i.CampaignID, &i.UserID, &i.Username, &i.BunchID, &i.Bunch, &i.Reviewer,
+			&i.Decision, &i.Comment, &i.DecidedAt, &i.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, i)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+var sqlLockReviewItem = "SELECT review_items.user_id, review_items.bunch_id, review_items.decision, " +
+	"review_campaigns.`status` FROM review_items " +
+	"INNER JOIN review_campaigns ON review_campaigns.id = review_items.campaign_id " +
+	"WHERE review_items.id = ? AND review_campaigns.tenant_id = ? FOR UPDATE;"
+var sqlDecideReviewItem = "UPDATE review_items SET decision = ?, `comment` = ?, decided_at = ? WHERE id = ?;"
+
+// DecideItem locks the item and its campaign, so that an item is decided once and not after its campaign is
+// closed, and removes the item's grant in the same transaction when it's revoked. The grant is removed by
+// removeGrants, as usrmgr's RemoveBunchesFromUser does, rather than through usrmgr's service: the service can't
+// join the transaction, and a revoke mustn't be recorded without its grant being removed.
+func (st *ReviewStorage) DecideItem(id int64, decision string, comment string) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var item struct {
+		UserID   int64  `db:"user_id"`
+		BunchID  int64  `db:"bunch_id"`
+		Decision string `db:"decision"`
+		Status   string `db:"status"`
+	}
+	err = tx.Get(&item, sqlLockReviewItem, id, st.tenant)
+	if err == sql.ErrNoRows {
+		return common.ErrReviewItemNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if item.Status != reviewmgr.StatusOpen {
+		return common.ErrCampaignClosed
+	}
+	if item.Decision != reviewmgr.DecisionPending {
+		return common.ErrReviewItemDecided
+	}
+
+	if _, err := tx.Exec(sqlDecideReviewItem, decision, comment, time.Now(), id); err != nil {
+		return err
+	}
+
+	if decision == reviewmgr.DecisionRevoked {
+		if err := removeGrants(tx, st.tenant, item.UserID, []int64{item.BunchID}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+var sqlGetUserIDs = "SELECT id FROM users WHERE tenant_id = ? AND username IN (%s);"
+
+func (st *ReviewStorage) GetUserIDs(usernames []string) ([]int64, error) {
+	conditions := make([]string, 0, len(usernames))
+	values := make([]interface{}, 0, len(usernames)+1)
+	values = append(values, st.tenant)
+	for _, n := range usernames {
+		conditions = append(conditions, "?")
+		values = append(values, n)
+	}
+
+	rows, err := st.db.Queryx(fmt.Sprintf(sqlGetUserIDs, strings.Join(conditions, ",")), values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		results = append(results, id)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
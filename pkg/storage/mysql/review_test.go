@@ -0,0 +1,167 @@
+package mysql
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/reviewmgr"
+	"testing"
+)
+
+func TestReviewStorage_AddCampaign(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_snapshot_bunch_grants", func(t *testing.T) {
+		t.Parallel()
+
+		bunch := test.mig.createUniqueString("bunch")
+		bID := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch })
+		uID1 := test.mig.createSeedingUser(nil)
+		uID2 := test.mig.createSeedingUser(nil)
+		rID1 := test.mig.createSeedingUser(nil)
+		rID2 := test.mig.createSeedingUser(nil)
+
+		require.Nil(t, test.ust.AddBunchesToUser(uID1, []int64{bID}, nil))
+		require.Nil(t, test.ust.AddBunchesToUser(uID2, []int64{bID}, nil))
+
+		id, err := test.rst.AddCampaign(test.mig.createUniqueString("campaign"), "desc", reviewmgr.ScopeBunch,
+			[]string{bunch}, []int64{rID1, rID2})
+		require.Nil(t, err)
+		require.NotZero(t, id)
+
+		items, err := test.rst.GetItems(id, "", reviewmgr.DecisionPending)
+		require.Nil(t, err)
+		require.Len(t, items, 2)
+		require.NotEqual(t, items[0].Reviewer, items[1].Reviewer)
+	})
+
+	t.Run("success_snapshot_key_grants", func(t *testing.T) {
+		t.Parallel()
+
+		key := test.mig.createUniqueString("key")
+		kID := test.mig.createSeedingServiceKey(func(fields map[string]interface{}) { fields["key"] = key })
+		bID1 := test.mig.createSeedingBunch(nil)
+		bID2 := test.mig.createSeedingBunch(nil)
+		uID := test.mig.createSeedingUser(nil)
+		rID := test.mig.createSeedingUser(nil)
+
+		require.Nil(t, test.bst.AddKeysToBunch(bID1, []int64{kID}))
+		require.Nil(t, test.bst.AddKeysToBunch(bID2, []int64{kID}))
+		require.Nil(t, test.ust.AddBunchesToUser(uID, []int64{bID1, bID2}, nil))
+
+		id, err := test.rst.AddCampaign(test.mig.createUniqueString("campaign"), "desc", reviewmgr.ScopeKey,
+			[]string{key}, []int64{rID})
+		require.Nil(t, err)
+
+		items, err := test.rst.GetItems(id, "", "")
+		require.Nil(t, err)
+		require.Len(t, items, 2)
+	})
+}
+
+func TestReviewStorage_DecideItem(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_decide_an_item", func(t *testing.T) {
+		t.Parallel()
+
+		bunch := test.mig.createUniqueString("bunch")
+		reviewer := test.mig.createUniqueString("reviewer")
+		bID := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch })
+		uID := test.mig.createSeedingUser(nil)
+		rID := test.mig.createSeedingUser(func(fields map[string]interface{}) { fields["username"] = reviewer })
+		require.Nil(t, test.ust.AddBunchesToUser(uID, []int64{bID}, nil))
+
+		id, err := test.rst.AddCampaign(test.mig.createUniqueString("campaign"), "", reviewmgr.ScopeBunch,
+			[]string{bunch}, []int64{rID})
+		require.Nil(t, err)
+
+		items, err := test.rst.GetItems(id, reviewer, "")
+		require.Nil(t, err)
+		require.Len(t, items, 1)
+
+		err = test.rst.DecideItem(items[0].ID, reviewmgr.DecisionCertified, "looks good")
+		require.Nil(t, err)
+
+		item, err := test.rst.GetItem(items[0].ID)
+		require.Nil(t, err)
+		require.Equal(t, reviewmgr.DecisionCertified, item.Decision)
+		require.Equal(t, "looks good", item.Comment)
+		require.True(t, item.DecidedAt.Valid)
+
+		// an item is decided once
+		err = test.rst.DecideItem(items[0].ID, reviewmgr.DecisionRevoked, "")
+		require.Equal(t, common.ErrReviewItemDecided, err)
+	})
+
+	t.Run("success_revoke_an_item", func(t *testing.T) {
+		t.Parallel()
+
+		bunch := test.mig.createUniqueString("bunch")
+		username := test.mig.createUniqueString("username")
+		bID := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch })
+		uID := test.mig.createSeedingUser(func(fields map[string]interface{}) { fields["username"] = username })
+		rID := test.mig.createSeedingUser(nil)
+		require.Nil(t, test.ust.AddBunchesToUser(uID, []int64{bID}, nil))
+
+		id, err := test.rst.AddCampaign(test.mig.createUniqueString("campaign"), "", reviewmgr.ScopeBunch,
+			[]string{bunch}, []int64{rID})
+		require.Nil(t, err)
+
+		items, err := test.rst.GetItems(id, "", "")
+		require.Nil(t, err)
+		require.Len(t, items, 1)
+
+		require.Nil(t, test.rst.DecideItem(items[0].ID, reviewmgr.DecisionRevoked, "left the team"))
+
+		bunches, err := test.ust.GetBunches(username)
+		require.Nil(t, err)
+		require.Len(t, bunches, 0)
+	})
+
+	t.Run("fail_campaign_closed", func(t *testing.T) {
+		t.Parallel()
+
+		bunch := test.mig.createUniqueString("bunch")
+		bID := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch })
+		uID := test.mig.createSeedingUser(nil)
+		rID := test.mig.createSeedingUser(nil)
+		require.Nil(t, test.ust.AddBunchesToUser(uID, []int64{bID}, nil))
+
+		id, err := test.rst.AddCampaign(test.mig.createUniqueString("campaign"), "", reviewmgr.ScopeBunch,
+			[]string{bunch}, []int64{rID})
+		require.Nil(t, err)
+		require.Nil(t, test.rst.CloseCampaign(id))
+
+		items, err := test.rst.GetItems(id, "", "")
+		require.Nil(t, err)
+		require.Len(t, items, 1)
+
+		err = test.rst.DecideItem(items[0].ID, reviewmgr.DecisionRevoked, "")
+		require.Equal(t, common.ErrCampaignClosed, err)
+	})
+}
+
+func TestReviewStorage_CloseCampaign(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_close_a_campaign", func(t *testing.T) {
+		t.Parallel()
+
+		bunch := test.mig.createUniqueString("bunch")
+		test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch })
+		rID := test.mig.createSeedingUser(nil)
+
+		id, err := test.rst.AddCampaign(test.mig.createUniqueString("campaign"), "", reviewmgr.ScopeBunch,
+			[]string{bunch}, []int64{rID})
+		require.Nil(t, err)
+
+		err = test.rst.CloseCampaign(id)
+		require.Nil(t, err)
+
+		campaign, err := test.rst.GetCampaign(id)
+		require.Nil(t, err)
+		require.Equal(t, reviewmgr.StatusClosed, campaign.Status)
+		require.True(t, campaign.ClosedAt.Valid)
+		require.Equal(t, []string{bunch}, campaign.Targets)
+	})
+}
@@ -1,42 +1,110 @@
 package mysql
 
 var initDatabase = `
+CREATE TABLE IF NOT EXISTS "tenants" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "name" VARCHAR(32) NOT NULL,
+  "desc" VARCHAR(255) NOT NULL DEFAULT '',
+  "active" TINYINT(1) NOT NULL DEFAULT 1,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "tenant_name_uniq" ("name" ASC))
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+INSERT IGNORE INTO tenants (id, "name", "desc") VALUES (1, 'default', 'Default tenant');
+
+CREATE TABLE IF NOT EXISTS "applications" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "name" VARCHAR(32) NOT NULL,
+  "desc" VARCHAR(255) NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "application_name_uniq" ("tenant_id" ASC, "name" ASC),
+  CONSTRAINT "tenant_id_on_application"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
 CREATE TABLE IF NOT EXISTS "keys" (
   	"id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
-	"key" VARCHAR(32) NOT NULL DEFAULT '',
+  	"tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  	"application_id" BIGINT(20) UNSIGNED NULL,
+	"key" VARCHAR(65) NOT NULL DEFAULT '',
 	"desc" VARCHAR(64) NOT NULL DEFAULT '',
   	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   	"updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY ("id"),
-  UNIQUE INDEX "keys_key_uniq" ("key" ASC))
+  UNIQUE INDEX "keys_key_uniq" ("tenant_id" ASC, "key" ASC),
+  INDEX "application_id_on_key_idx" ("application_id" ASC),
+  CONSTRAINT "tenant_id_on_key"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "application_id_on_key"
+    FOREIGN KEY ("application_id")
+    REFERENCES "applications" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
 ENGINE = InnoDB
 AUTO_INCREMENT = 1
 DEFAULT CHARACTER SET = utf8;
 
 CREATE TABLE IF NOT EXISTS "bunches" (
   "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
   "name" VARCHAR(32) NOT NULL DEFAULT '',
   "desc" VARCHAR(64) NOT NULL DEFAULT '',
   "active" TINYINT(1) UNSIGNED NOT NULL DEFAULT 1,
   "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY ("id"),
-  UNIQUE INDEX "bunch_name_uniq" ("name" ASC),
-  INDEX "bunch_active_idx" ("active" ASC))
+  UNIQUE INDEX "bunch_name_uniq" ("tenant_id" ASC, "name" ASC),
+  INDEX "bunch_active_idx" ("active" ASC),
+  CONSTRAINT "tenant_id_on_bunch"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
 ENGINE = InnoDB;
 
 CREATE TABLE IF NOT EXISTS "users" (
   "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
   "username" VARCHAR(32) NOT NULL,
-  "email" VARCHAR(64) NOT NULL,
+  "email" VARCHAR(64) NULL DEFAULT NULL,
   "hash" VARCHAR(255) NOT NULL,
   "active" TINYINT(1) NOT NULL DEFAULT 1,
+  "type" VARCHAR(16) NOT NULL DEFAULT 'user',
+  "owner_id" BIGINT(20) UNSIGNED NULL DEFAULT NULL,
+  "desc" VARCHAR(255) NOT NULL DEFAULT '',
+  "locale" VARCHAR(35) NOT NULL DEFAULT '',
   "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   PRIMARY KEY ("id"),
-  UNIQUE INDEX "users_username_uniq" ("username" ASC),
-  UNIQUE INDEX "users_email_uniq" ("email" ASC),
-  INDEX "users_active_idx" ("active" ASC))
+  UNIQUE INDEX "users_username_uniq" ("tenant_id" ASC, "username" ASC),
+  UNIQUE INDEX "users_email_uniq" ("tenant_id" ASC, "email" ASC),
+  INDEX "users_active_idx" ("active" ASC),
+  INDEX "users_type_idx" ("type" ASC),
+  CONSTRAINT "owner_id_on_users"
+    FOREIGN KEY ("owner_id")
+    REFERENCES "users" ("id")
+    ON DELETE SET NULL
+    ON UPDATE CASCADE,
+  CONSTRAINT "tenant_id_on_user"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
 ENGINE = InnoDB
 AUTO_INCREMENT = 1
 DEFAULT CHARACTER SET = utf8;
@@ -52,15 +120,19 @@ CREATE TABLE IF NOT EXISTS "token_histories" (
   "user_agent" VARCHAR(512) NOT NULL DEFAULT '',
   "created_at" TIMESTAMP NOT NULL,
   "expired_at" TIMESTAMP NOT NULL,
+  "revoked_at" TIMESTAMP NULL,
   PRIMARY KEY ("uid"),
-  UNIQUE INDEX "uid_uniq" ("uid" ASC))
+  UNIQUE INDEX "uid_uniq" ("uid" ASC),
+  INDEX "token_history_user" ("user_id" ASC, "expired_at" ASC))
 ENGINE = InnoDB
 DEFAULT CHARACTER SET = utf8;
 
 CREATE TABLE IF NOT EXISTS "bunch_keys" (
   "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
   "bunch_id" BIGINT(20) UNSIGNED NOT NULL,
   "key_id" BIGINT(20) UNSIGNED NOT NULL,
+  "expression" VARCHAR(255) NULL DEFAULT NULL,
   "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   PRIMARY KEY ("id"),
   INDEX "bunch_key_key_id_idx" ("key_id" ASC),
@@ -75,6 +147,11 @@ CREATE TABLE IF NOT EXISTS "bunch_keys" (
     FOREIGN KEY ("bunch_id")
     REFERENCES "bunches" ("id")
     ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "tenant_id_on_bunch_key"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
     ON UPDATE CASCADE)
 ENGINE = InnoDB
 AUTO_INCREMENT = 1
@@ -82,8 +159,10 @@ DEFAULT CHARACTER SET = utf8;
 
 CREATE TABLE IF NOT EXISTS "user_bunches" (
   "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
   "user_id" BIGINT(20) UNSIGNED NOT NULL,
   "bunch_id" BIGINT(20) UNSIGNED NOT NULL,
+  "expression" VARCHAR(255) NULL DEFAULT NULL,
   "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   PRIMARY KEY ("id"),
   INDEX "user_bunch_user_id_idx" ("user_id" ASC),
@@ -98,18 +177,496 @@ CREATE TABLE IF NOT EXISTS "user_bunches" (
     FOREIGN KEY ("bunch_id")
     REFERENCES "bunches" ("id")
     ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "tenant_id_on_user_bunch"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
     ON UPDATE CASCADE)
 ENGINE = InnoDB;
+
+CREATE TABLE IF NOT EXISTS "review_campaigns" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "name" VARCHAR(64) NOT NULL,
+  "desc" VARCHAR(255) NOT NULL DEFAULT '',
+  "scope" VARCHAR(8) NOT NULL,
+  "targets" VARCHAR(1024) NOT NULL DEFAULT '',
+  "status" VARCHAR(8) NOT NULL DEFAULT 'open',
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "closed_at" TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY ("id"),
+  INDEX "review_campaign_status_idx" ("status" ASC),
+  CONSTRAINT "tenant_id_on_review_campaign"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "review_items" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "campaign_id" BIGINT(20) UNSIGNED NOT NULL,
+  "user_id" BIGINT(20) UNSIGNED NOT NULL,
+  "bunch_id" BIGINT(20) UNSIGNED NOT NULL,
+  "reviewer_id" BIGINT(20) UNSIGNED NOT NULL,
+  "decision" VARCHAR(10) NOT NULL DEFAULT 'pending',
+  "comment" VARCHAR(255) NOT NULL DEFAULT '',
+  "decided_at" TIMESTAMP NULL DEFAULT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  INDEX "review_item_campaign_id_idx" ("campaign_id" ASC),
+  INDEX "review_item_reviewer_id_idx" ("reviewer_id" ASC),
+  CONSTRAINT "campaign_id_on_review_item"
+    FOREIGN KEY ("campaign_id")
+    REFERENCES "review_campaigns" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "user_id_on_review_item"
+    FOREIGN KEY ("user_id")
+    REFERENCES "users" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "bunch_id_on_review_item"
+    FOREIGN KEY ("bunch_id")
+    REFERENCES "bunches" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "reviewer_id_on_review_item"
+    FOREIGN KEY ("reviewer_id")
+    REFERENCES "users" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "bunch_exclusions" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "name" VARCHAR(32) NOT NULL,
+  "desc" VARCHAR(64) NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "bunch_exclusion_name_uniq" ("tenant_id" ASC, "name" ASC),
+  CONSTRAINT "tenant_id_on_bunch_exclusion"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "bunch_exclusion_members" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "exclusion_id" BIGINT(20) UNSIGNED NOT NULL,
+  "bunch_id" BIGINT(20) UNSIGNED NOT NULL,
+  PRIMARY KEY ("id"),
+  INDEX "bunch_exclusion_member_bunch_id_idx" ("bunch_id" ASC),
+  UNIQUE INDEX "bunch_exclusion_member_uniq" ("exclusion_id" ASC, "bunch_id" ASC),
+  CONSTRAINT "exclusion_id_on_bunch_exclusion_member"
+    FOREIGN KEY ("exclusion_id")
+    REFERENCES "bunch_exclusions" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "bunch_id_on_bunch_exclusion_member"
+    FOREIGN KEY ("bunch_id")
+    REFERENCES "bunches" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "user_identities" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "provider" VARCHAR(32) NOT NULL,
+  "subject" VARCHAR(255) NOT NULL,
+  "user_id" BIGINT(20) UNSIGNED NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "user_identity_subject_uniq" ("tenant_id" ASC, "provider" ASC, "subject" ASC),
+  INDEX "user_identity_user_id_idx" ("user_id" ASC),
+  CONSTRAINT "user_id_on_user_identity"
+    FOREIGN KEY ("user_id")
+    REFERENCES "users" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "tenant_id_on_user_identity"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "user_invites" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "user_id" BIGINT(20) UNSIGNED NOT NULL,
+  "hash" CHAR(64) NOT NULL,
+  "expires_at" TIMESTAMP NULL DEFAULT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "user_invite_hash_uniq" ("hash" ASC),
+  UNIQUE INDEX "user_invite_user_uniq" ("user_id" ASC),
+  CONSTRAINT "user_id_on_user_invite"
+    FOREIGN KEY ("user_id")
+    REFERENCES "users" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "tenant_id_on_user_invite"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "personal_access_tokens" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "user_id" BIGINT(20) UNSIGNED NOT NULL,
+  "name" VARCHAR(32) NOT NULL,
+  "prefix" VARCHAR(8) NOT NULL,
+  "hash" CHAR(64) NOT NULL,
+  "expires_at" TIMESTAMP NULL DEFAULT NULL,
+  "revoked" TINYINT(1) NOT NULL DEFAULT 0,
+  "last_used_at" TIMESTAMP NULL DEFAULT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "personal_access_token_hash_uniq" ("hash" ASC),
+  UNIQUE INDEX "personal_access_token_name_uniq" ("user_id" ASC, "name" ASC),
+  CONSTRAINT "user_id_on_personal_access_token"
+    FOREIGN KEY ("user_id")
+    REFERENCES "users" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "personal_access_token_keys" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "token_id" BIGINT(20) UNSIGNED NOT NULL,
+  "key_id" BIGINT(20) UNSIGNED NOT NULL,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "personal_access_token_key_uniq" ("token_id" ASC, "key_id" ASC),
+  CONSTRAINT "token_id_on_personal_access_token_key"
+    FOREIGN KEY ("token_id")
+    REFERENCES "personal_access_tokens" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "key_id_on_personal_access_token_key"
+    FOREIGN KEY ("key_id")
+    REFERENCES "keys" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "bunch_denials" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "bunch_id" BIGINT(20) UNSIGNED NOT NULL,
+  "key_id" BIGINT(20) UNSIGNED NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "bunch_denial_uniq" ("bunch_id" ASC, "key_id" ASC),
+  CONSTRAINT "bunch_id_on_bunch_denial"
+    FOREIGN KEY ("bunch_id")
+    REFERENCES "bunches" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "key_id_on_bunch_denial"
+    FOREIGN KEY ("key_id")
+    REFERENCES "keys" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "tenant_id_on_bunch_denial"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "user_denials" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "user_id" BIGINT(20) UNSIGNED NOT NULL,
+  "key_id" BIGINT(20) UNSIGNED NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "user_denial_uniq" ("user_id" ASC, "key_id" ASC),
+  CONSTRAINT "user_id_on_user_denial"
+    FOREIGN KEY ("user_id")
+    REFERENCES "users" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "key_id_on_user_denial"
+    FOREIGN KEY ("key_id")
+    REFERENCES "keys" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "tenant_id_on_user_denial"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "rebac_namespaces" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "name" VARCHAR(64) NOT NULL,
+  "config" TEXT NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "rebac_namespace_uniq" ("tenant_id" ASC, "name" ASC),
+  CONSTRAINT "tenant_id_on_rebac_namespace"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "relation_revisions" (
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL,
+  "rev" BIGINT(20) UNSIGNED NOT NULL,
+  "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY ("tenant_id"),
+  CONSTRAINT "tenant_id_on_relation_revision"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "relation_tuples" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "namespace" VARCHAR(64) NOT NULL,
+  "object_id" VARCHAR(64) NOT NULL,
+  "relation" VARCHAR(64) NOT NULL,
+  "subject_user" VARCHAR(32) NOT NULL DEFAULT '',
+  "subject_namespace" VARCHAR(64) NOT NULL DEFAULT '',
+  "subject_object" VARCHAR(64) NOT NULL DEFAULT '',
+  "subject_relation" VARCHAR(64) NOT NULL DEFAULT '',
+  "created_rev" BIGINT(20) UNSIGNED NOT NULL,
+  "deleted_rev" BIGINT(20) UNSIGNED NOT NULL DEFAULT 0,
+  PRIMARY KEY ("id"),
+  INDEX "relation_tuple_object" ("tenant_id" ASC, "namespace" ASC, "object_id" ASC, "relation" ASC),
+  UNIQUE INDEX "relation_tuple_uniq" ("tenant_id" ASC, "namespace" ASC, "object_id" ASC, "relation" ASC,
+    "subject_user" ASC, "subject_namespace" ASC, "subject_object" ASC, "subject_relation" ASC, "deleted_rev" ASC),
+  CONSTRAINT "tenant_id_on_relation_tuple"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "audit_events" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "actor" VARCHAR(64) NOT NULL DEFAULT '',
+  "action" VARCHAR(64) NOT NULL,
+  "target" VARCHAR(255) NOT NULL DEFAULT '',
+  "before" MEDIUMTEXT NOT NULL,
+  "after" MEDIUMTEXT NOT NULL,
+  "diff" MEDIUMTEXT NOT NULL,
+  "ip" VARCHAR(64) NOT NULL DEFAULT '',
+  "user_agent" VARCHAR(255) NOT NULL DEFAULT '',
+  "outcome" VARCHAR(16) NOT NULL,
+  "error" VARCHAR(255) NOT NULL DEFAULT '',
+  "prev_hash" CHAR(64) NOT NULL DEFAULT '',
+  "hash" CHAR(64) NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  INDEX "audit_event_tenant_created" ("tenant_id" ASC, "created_at" ASC),
+  CONSTRAINT "tenant_id_on_audit_event"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "outbox_sequences" (
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL,
+  "seq" BIGINT(20) UNSIGNED NOT NULL,
+  PRIMARY KEY ("tenant_id"),
+  CONSTRAINT "tenant_id_on_outbox_sequence"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "outbox_events" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "seq" BIGINT(20) UNSIGNED NOT NULL,
+  "type" VARCHAR(64) NOT NULL,
+  "payload" TEXT NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "relayed_at" TIMESTAMP NULL,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "outbox_event_seq" ("tenant_id" ASC, "seq" ASC),
+  INDEX "outbox_event_relayed" ("relayed_at" ASC, "id" ASC),
+  CONSTRAINT "tenant_id_on_outbox_event"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "webhook_subscriptions" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "name" VARCHAR(64) NOT NULL,
+  "url" VARCHAR(1024) NOT NULL,
+  "secret" VARCHAR(64) NOT NULL,
+  "events" VARCHAR(1024) NOT NULL,
+  "active" TINYINT(1) NOT NULL DEFAULT 1,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "webhook_subscription_name_uniq" ("tenant_id" ASC, "name" ASC),
+  CONSTRAINT "tenant_id_on_webhook_subscription"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "subscription_id" BIGINT(20) UNSIGNED NOT NULL,
+  "event_id" BIGINT(20) UNSIGNED NOT NULL,
+  "status" VARCHAR(16) NOT NULL,
+  "attempts" INT NOT NULL DEFAULT 0,
+  "next_attempt_at" TIMESTAMP NULL,
+  "last_status_code" INT NOT NULL DEFAULT 0,
+  "last_error" VARCHAR(255) NOT NULL DEFAULT '',
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "webhook_delivery_uniq" ("subscription_id" ASC, "event_id" ASC),
+  INDEX "webhook_delivery_due" ("status" ASC, "next_attempt_at" ASC),
+  CONSTRAINT "subscription_id_on_webhook_delivery"
+    FOREIGN KEY ("subscription_id")
+    REFERENCES "webhook_subscriptions" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "event_id_on_webhook_delivery"
+    FOREIGN KEY ("event_id")
+    REFERENCES "outbox_events" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "tenant_id_on_webhook_delivery"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "webhook_attempts" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "delivery_id" BIGINT(20) UNSIGNED NOT NULL,
+  "status_code" INT NOT NULL DEFAULT 0,
+  "error" VARCHAR(255) NOT NULL DEFAULT '',
+  "duration_ms" BIGINT(20) NOT NULL DEFAULT 0,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id"),
+  INDEX "webhook_attempt_delivery" ("delivery_id" ASC),
+  CONSTRAINT "delivery_id_on_webhook_attempt"
+    FOREIGN KEY ("delivery_id")
+    REFERENCES "webhook_deliveries" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
+
+CREATE TABLE IF NOT EXISTS "user_attributes" (
+  "id" BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  "tenant_id" BIGINT(20) UNSIGNED NOT NULL DEFAULT 1,
+  "user_id" BIGINT(20) UNSIGNED NOT NULL,
+  "name" VARCHAR(32) NOT NULL,
+  "value" VARCHAR(255) NOT NULL DEFAULT '',
+  PRIMARY KEY ("id"),
+  UNIQUE INDEX "user_attribute_uniq" ("user_id" ASC, "name" ASC),
+  CONSTRAINT "user_id_on_user_attribute"
+    FOREIGN KEY ("user_id")
+    REFERENCES "users" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE,
+  CONSTRAINT "tenant_id_on_user_attribute"
+    FOREIGN KEY ("tenant_id")
+    REFERENCES "tenants" ("id")
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB
+AUTO_INCREMENT = 1
+DEFAULT CHARACTER SET = utf8;
 `
 
 var dropDatabase = `
+DROP TABLE IF EXISTS "webhook_attempts";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "webhook_subscriptions";
+DROP TABLE IF EXISTS "outbox_events";
+DROP TABLE IF EXISTS "outbox_sequences";
+DROP TABLE IF EXISTS "audit_events";
+DROP TABLE IF EXISTS "relation_tuples";
+DROP TABLE IF EXISTS "relation_revisions";
+DROP TABLE IF EXISTS "rebac_namespaces";
+DROP TABLE IF EXISTS "user_attributes";
+DROP TABLE IF EXISTS "user_denials";
+DROP TABLE IF EXISTS "bunch_denials";
+DROP TABLE IF EXISTS "personal_access_token_keys";
+DROP TABLE IF EXISTS "personal_access_tokens";
+DROP TABLE IF EXISTS "user_invites";
+DROP TABLE IF EXISTS "user_identities";
+DROP TABLE IF EXISTS "bunch_exclusion_members";
+DROP TABLE IF EXISTS "bunch_exclusions";
+DROP TABLE IF EXISTS "review_items";
+DROP TABLE IF EXISTS "review_campaigns";
 DROP TABLE IF EXISTS "user_bunches";
 DROP TABLE IF EXISTS "bunch_keys";
 DROP TABLE IF EXISTS "keys";
+DROP TABLE IF EXISTS "applications";
 DROP TABLE IF EXISTS "bunches";
 DROP TABLE IF EXISTS "users";
 DROP TABLE IF EXISTS "token_histories";
+DROP TABLE IF EXISTS "tenants";
 `
+
 // default password: "password"
 var seedingData = `
 INSERT INTO "keys" (id, "key", "desc") VALUES (1, 'add_key', 'Add a key');
@@ -122,6 +679,72 @@ INSERT INTO "keys" (id, "key", "desc") VALUES (7, 'get_bunch', 'Get bunch');
 INSERT INTO "keys" (id, "key", "desc") VALUES (8, 'query_bunch', 'Query bunches');
 INSERT INTO "keys" (id, "key", "desc") VALUES (9, 'add_user', 'add_user');
 INSERT INTO "keys" (id, "key", "desc") VALUES (10 ,'modify_user', 'modify_user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (11, 'remove_bunch_from_user', 'Remove bunches from user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (12, 'start_review', 'Start an access review campaign');
+INSERT INTO "keys" (id, "key", "desc") VALUES (13, 'get_review', 'Get an access review campaign');
+INSERT INTO "keys" (id, "key", "desc") VALUES (14, 'decide_review_item', 'Certify or revoke a reviewed grant');
+INSERT INTO "keys" (id, "key", "desc") VALUES (15, 'close_review', 'Close an access review campaign');
+INSERT INTO "keys" (id, "key", "desc") VALUES (16, 'get_review_report', 'Export an access review report');
+INSERT INTO "keys" (id, "key", "desc") VALUES (17, 'add_exclusion', 'Add a separation of duties rule');
+INSERT INTO "keys" (id, "key", "desc") VALUES (18, 'get_exclusion', 'Get a separation of duties rule');
+INSERT INTO "keys" (id, "key", "desc") VALUES (19, 'query_exclusion', 'List separation of duties rules');
+INSERT INTO "keys" (id, "key", "desc") VALUES (20, 'remove_exclusion', 'Remove a separation of duties rule');
+INSERT INTO "keys" (id, "key", "desc") VALUES (21, 'get_exclusion_violation', 'Report separation of duties violations');
+INSERT INTO "keys" (id, "key", "desc") VALUES (22, 'apply_manifest', 'Plan or apply an authorization manifest');
+INSERT INTO "keys" (id, "key", "desc") VALUES (23, 'export_data', 'Export keys, bunches and users');
+INSERT INTO "keys" (id, "key", "desc") VALUES (24, 'import_data', 'Import keys, bunches and users');
+INSERT INTO "keys" (id, "key", "desc") VALUES (25, 'import_users', 'Bulk import users');
+INSERT INTO "keys" (id, "key", "desc") VALUES (26, 'add_token', 'Create a personal access token');
+INSERT INTO "keys" (id, "key", "desc") VALUES (27, 'query_token', 'List own personal access tokens');
+INSERT INTO "keys" (id, "key", "desc") VALUES (28, 'revoke_token', 'Revoke a personal access token');
+INSERT INTO "keys" (id, "key", "desc") VALUES (29, 'add_service_account', 'Create a service account');
+INSERT INTO "keys" (id, "key", "desc") VALUES (30, 'modify_service_account', 'Modify any service account');
+INSERT INTO "keys" (id, "key", "desc") VALUES (31, 'add_service_account_token', 'Create a token of an owned service account');
+INSERT INTO "keys" (id, "key", "desc") VALUES (32, 'query_service_account_token', 'List tokens of an owned service account');
+INSERT INTO "keys" (id, "key", "desc") VALUES (33, 'revoke_service_account_token', 'Revoke a token of an owned service account');
+INSERT INTO "keys" (id, "key", "desc") VALUES (34, 'add_tenant', 'Create a tenant');
+INSERT INTO "keys" (id, "key", "desc") VALUES (35, 'modify_tenant', 'Modify a tenant');
+INSERT INTO "keys" (id, "key", "desc") VALUES (36, 'get_tenant', 'Get a tenant');
+INSERT INTO "keys" (id, "key", "desc") VALUES (37, 'query_tenant', 'List tenants');
+INSERT INTO "keys" (id, "key", "desc") VALUES (38, 'add_application', 'Register an application');
+INSERT INTO "keys" (id, "key", "desc") VALUES (39, 'modify_application', 'Modify an application');
+INSERT INTO "keys" (id, "key", "desc") VALUES (40, 'get_application', 'Get an application');
+INSERT INTO "keys" (id, "key", "desc") VALUES (41, 'query_application', 'List applications');
+INSERT INTO "keys" (id, "key", "desc") VALUES (42, 'check_key', 'Check keys granted to the caller');
+INSERT INTO "keys" (id, "key", "desc") VALUES (43, 'modify_key_condition', 'Set condition of a key granted to a bunch');
+INSERT INTO "keys" (id, "key", "desc") VALUES (44, 'modify_bunch_condition', 'Set condition of a bunch granted to a user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (45, 'get_user_attributes', 'Get attributes of a user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (46, 'modify_user_attributes', 'Modify attributes of a user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (47, 'add_bunch_denials', 'Deny keys to holders of a bunch');
+INSERT INTO "keys" (id, "key", "desc") VALUES (48, 'remove_bunch_denials', 'Remove keys denied by a bunch');
+INSERT INTO "keys" (id, "key", "desc") VALUES (49, 'get_bunch_denials', 'Get keys denied by a bunch');
+INSERT INTO "keys" (id, "key", "desc") VALUES (50, 'add_user_denials', 'Deny keys to a user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (51, 'remove_user_denials', 'Remove keys denied to a user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (52, 'get_user_denials', 'Get keys denied to a user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (53, 'explain_key', 'Explain why a key is granted or denied to a user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (54, 'write_namespace', 'Create or replace a relation namespace config');
+INSERT INTO "keys" (id, "key", "desc") VALUES (55, 'get_namespace', 'Get a relation namespace config');
+INSERT INTO "keys" (id, "key", "desc") VALUES (56, 'write_relations', 'Add and remove relation tuples');
+INSERT INTO "keys" (id, "key", "desc") VALUES (57, 'check_relation', 'Check a relation of a user to an object');
+INSERT INTO "keys" (id, "key", "desc") VALUES (58, 'expand_relation', 'Expand users having a relation to an object');
+INSERT INTO "keys" (id, "key", "desc") VALUES (59, 'list_objects', 'List objects a user has a relation to');
+INSERT INTO "keys" (id, "key", "desc") VALUES (60, 'query_audit_event', 'Query the audit log');
+INSERT INTO "keys" (id, "key", "desc") VALUES (61, 'export_audit_event', 'Export the audit log');
+INSERT INTO "keys" (id, "key", "desc") VALUES (62, 'verify_audit_log', 'Verify hash chain of the audit log');
+INSERT INTO "keys" (id, "key", "desc") VALUES (63, 'query_own_session', 'List own active sessions');
+INSERT INTO "keys" (id, "key", "desc") VALUES (64, 'terminate_own_session', 'Terminate own sessions');
+INSERT INTO "keys" (id, "key", "desc") VALUES (65, 'query_user_session', 'List active sessions of a user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (66, 'terminate_user_session', 'Terminate sessions of a user');
+INSERT INTO "keys" (id, "key", "desc") VALUES (67, 'add_webhook', 'Subscribe a webhook to events');
+INSERT INTO "keys" (id, "key", "desc") VALUES (68, 'get_webhook', 'Get a webhook');
+INSERT INTO "keys" (id, "key", "desc") VALUES (69, 'query_webhook', 'Query webhooks');
+INSERT INTO "keys" (id, "key", "desc") VALUES (70, 'modify_webhook', 'Modify a webhook');
+INSERT INTO "keys" (id, "key", "desc") VALUES (71, 'remove_webhook', 'Remove a webhook');
+INSERT INTO "keys" (id, "key", "desc") VALUES (72, 'query_webhook_delivery', 'Query deliveries of a webhook');
+INSERT INTO "keys" (id, "key", "desc") VALUES (73, 'get_webhook_delivery', 'Get a delivery of a webhook with its attempts');
+INSERT INTO "keys" (id, "key", "desc") VALUES (74, 'retry_webhook_delivery', 'Retry a delivery of a webhook');
+INSERT INTO "keys" (id, "key", "desc") VALUES (75, 'stream_event', 'Stream permission changes as server-sent events');
+INSERT INTO "keys" (id, "key", "desc") VALUES (76, 'grant_any_key', 'Grant bunches and keys which the caller lacks');
 INSERT INTO bunches (id, "name", "desc") VALUES (1, 'admin_role', 'Admin role');
 INSERT INTO bunches (id, "name", "desc") VALUES (2, 'staff_role', 'Staff role');
 INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (1, 1, 1);
@@ -139,6 +762,75 @@ INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (12, 2, 2);
 INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (13, 2, 3);
 INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (14, 2, 4);
 INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (15, 2, 5);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (16, 1, 11);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (17, 1, 12);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (18, 1, 13);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (19, 1, 14);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (20, 1, 15);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (21, 1, 16);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (22, 1, 17);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (23, 1, 18);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (24, 1, 19);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (25, 1, 20);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (26, 1, 21);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (27, 1, 22);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (28, 1, 23);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (29, 1, 24);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (30, 1, 25);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (31, 2, 26);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (32, 2, 27);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (33, 2, 28);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (34, 1, 29);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (35, 1, 30);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (36, 2, 31);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (37, 2, 32);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (38, 2, 33);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (39, 1, 34);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (40, 1, 35);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (41, 1, 36);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (42, 1, 37);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (43, 1, 38);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (44, 1, 39);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (45, 1, 40);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (46, 1, 41);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (47, 1, 42);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (48, 2, 42);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (49, 1, 43);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (50, 1, 44);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (51, 1, 45);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (52, 1, 46);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (53, 1, 47);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (54, 1, 48);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (55, 1, 49);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (56, 1, 50);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (57, 1, 51);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (58, 1, 52);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (59, 1, 53);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (60, 1, 54);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (61, 1, 55);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (62, 1, 56);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (63, 1, 57);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (64, 1, 58);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (65, 1, 59);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (66, 1, 60);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (67, 1, 61);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (68, 1, 62);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (69, 1, 63);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (70, 2, 63);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (71, 1, 64);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (72, 2, 64);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (73, 1, 65);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (74, 1, 66);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (75, 1, 67);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (76, 1, 68);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (77, 1, 69);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (78, 1, 70);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (79, 1, 71);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (80, 1, 72);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (81, 1, 73);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (82, 1, 74);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (83, 1, 75);
+INSERT INTO bunch_keys (id, bunch_id, key_id) VALUES (84, 1, 76);
 INSERT INTO "users" (id, username, hash, email) VALUES (1, 'admin', '$2a$10$AdPyZkgVv70bXz9JvZLpH.CCQEzb8MbK8vMHIVQyCKtWestIyK46K', 'admin@test.com');
 INSERT INTO "users" (id, username, hash, email) VALUES (2, 'staff', '$2a$10$AdPyZkgVv70bXz9JvZLpH.CCQEzb8MbK8vMHIVQyCKtWestIyK46K', 'staff@test.com');
 INSERT INTO user_bunches (id, user_id, bunch_id) VALUES (1, 1, 1);
@@ -0,0 +1,147 @@
+package mysql
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/sessionmgr"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"time"
+)
+
+// SessionStorage implements db's storage for sessions, which are kept in token histories
+type SessionStorage struct {
+	db *sqlx.DB
+}
+
+// NewSessionStorage create new instance of SessionStorage
+func NewSessionStorage(db *sqlx.DB) *SessionStorage {
+	return &SessionStorage{
+		db,
+	}
+}
+
+var sqlAddSession = "INSERT INTO token_histories (uid, user_id, access_token, remote_addr, x_forwarded_for, " +
+	"x_real_ip, user_agent, created_at, expired_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);"
+
+var sqlGetSessionUser = "SELECT tenant_id, `username` FROM users WHERE id = ? LIMIT 1;"
+
+// AddSession stores the session, access_token holds the hash of the token. The login is published
+// in the user's tenant.
+func (st *SessionStorage) AddSession(s *sessionmgr.Session, tokenHash string) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(sqlAddSession, s.UID, s.UserID, tokenHash, s.RemoteAddr, s.XForwardedFor, s.XRealIP,
+		s.UserAgent, s.CreatedAt, s.ExpiredAt)
+	if err != nil {
+		return err
+	}
+
+	var tenant int64
+	var username string
+	if err := tx.QueryRowx(sqlGetSessionUser, s.UserID).Scan(&tenant, &username); err != nil {
+		return err
+	}
+
+	err = addOutboxEvent(tx, tenant, webhook.LoginSucceeded, map[string]interface{}{
+		"username":    username,
+		"session":     s.UID,
+		"remote_addr": s.RemoteAddr,
+		"user_agent":  s.UserAgent,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var sqlSelectSessions = "SELECT uid, user_id, remote_addr, x_forwarded_for, x_real_ip, user_agent, created_at, " +
+	"expired_at, revoked_at FROM token_histories "
+var sqlGetSession = sqlSelectSessions + "WHERE uid = ? LIMIT 1;"
+
+func (st *SessionStorage) GetSession(uid string) (*sessionmgr.Session, error) {
+	lst, err := st.querySessions(sqlGetSession, uid)
+	if err != nil {
+		return nil, err
+	}
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	return lst[0], nil
+}
+
+var sqlGetSessions = sqlSelectSessions + "WHERE user_id = ? AND revoked_at IS NULL AND expired_at > ? " +
+	"ORDER BY created_at DESC;"
+
+// GetSessions returns sessions of the user which are neither revoked nor expired at now
+func (st *SessionStorage) GetSessions(userID int64, now time.Time) ([]*sessionmgr.Session, error) {
+	return st.querySessions(sqlGetSessions, userID, now)
+}
+
+var sqlRevokeSession = "UPDATE token_histories SET revoked_at = ? WHERE uid = ? AND user_id = ? AND revoked_at IS NULL;"
+
+// RevokeSession reports whether there was a session of the user to revoke
+func (st *SessionStorage) RevokeSession(userID int64, uid string, at time.Time) (bool, error) {
+	res, err := st.db.Exec(sqlRevokeSession, at, uid, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+var sqlRevokeSessions = "UPDATE token_histories SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL;"
+var sqlRevokeUserTokens = "UPDATE personal_access_tokens SET revoked = 1 WHERE user_id = ? AND revoked = 0;"
+
+// RevokeSessions revokes the user's sessions and personal access tokens in one transaction
+func (st *SessionStorage) RevokeSessions(userID int64, at time.Time) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqlRevokeSessions, at, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(sqlRevokeUserTokens, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (st *SessionStorage) querySessions(query string, args ...interface{}) ([]*sessionmgr.Session, error) {
+	rows, err := st.db.Queryx(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*sessionmgr.Session, 0)
+	for rows.Next() {
+		s := new(sessionmgr.Session)
+		err := rows.Scan(&s.UID, &s.UserID, &s.RemoteAddr, &s.XForwardedFor, &s.XRealIP, &s.UserAgent,
+			&s.CreatedAt, &s.ExpiredAt, &s.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/sessionmgr"
+	"testing"
+	"time"
+)
+
+func TestSessionStorage_RevokeSession(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_revoke_one_then_all", func(t *testing.T) {
+		t.Parallel()
+
+		userID := test.mig.createSeedingUser(nil)
+		now := time.Now()
+
+		uids := []string{uuid.New().String(), uuid.New().String()}
+		for _, uid := range uids {
+			err := test.sest.AddSession(&sessionmgr.Session{UID: uid, UserID: userID, RemoteAddr: "10.0.0.1",
+				UserAgent: "curl", CreatedAt: now, ExpiredAt: now.Add(time.Hour)}, "hash")
+			require.Nil(t, err)
+		}
+
+		lst, err := test.sest.GetSessions(userID, now)
+		require.Nil(t, err)
+		require.Len(t, lst, 2)
+
+		revoked, err := test.sest.RevokeSession(userID+1, uids[0], now)
+		require.Nil(t, err)
+		require.False(t, revoked)
+
+		revoked, err = test.sest.RevokeSession(userID, uids[0], now)
+		require.Nil(t, err)
+		require.True(t, revoked)
+
+		session, err := test.sest.GetSession(uids[0])
+		require.Nil(t, err)
+		require.True(t, session.RevokedAt.Valid)
+
+		key := test.mig.createUniqueString("key")
+		test.mig.createSeedingServiceKey(func(field map[string]interface{}) { field["key"] = key })
+		hash := test.mig.createUniqueString("hash")
+		_, err = test.tst.AddToken(userID, "ci", "abcdefgh", hash, []string{key}, sql.NullTime{})
+		require.Nil(t, err)
+
+		require.Nil(t, test.sest.RevokeSessions(userID, now))
+
+		lst, err = test.sest.GetSessions(userID, now)
+		require.Nil(t, err)
+		require.Len(t, lst, 0)
+
+		// personal access tokens are revoked along with the sessions
+		token, err := test.tst.GetTokenByHash(hash)
+		require.Nil(t, err)
+		require.True(t, token.Revoked)
+	})
+}
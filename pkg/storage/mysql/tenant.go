@@ -0,0 +1,281 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/tenantmgr"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TenantStorage implements db's storage for tenants
+type TenantStorage struct {
+	db *sqlx.DB
+}
+
+// NewTenantStorage create new instance of TenantStorage
+func NewTenantStorage(db *sqlx.DB) *TenantStorage {
+	return &TenantStorage{
+		db,
+	}
+}
+
+var sqlAddTenant = "INSERT INTO tenants (`name`, `desc`, active, created_at, updated_at) VALUES (?, ?, 1, ?, ?);"
+var sqlCopyTenantKeys = "INSERT INTO `keys` (tenant_id, `key`, `desc`, created_at, updated_at) " +
+	"SELECT ?, `key`, `desc`, ?, ? FROM `keys` WHERE tenant_id = ? AND application_id IS NULL %s;"
+var sqlAddTenantAdminBunch = "INSERT INTO bunches (tenant_id, `name`, `desc`, active, created_at, updated_at) " +
+	"VALUES (?, ?, ?, 1, ?, ?);"
+var sqlAddTenantAdminKeys = "INSERT INTO bunch_keys (tenant_id, bunch_id, key_id, created_at) " +
+	"SELECT tenant_id, ?, id, ? FROM `keys` WHERE tenant_id = ?;"
+var sqlAddTenantAdminBunchToUser = "INSERT INTO `user_bunches` (tenant_id, user_id, bunch_id, created_at) " +
+	"VALUES (?, ?, ?, ?);"
+
+// AddTenant creates the tenant, copies default tenant's keys except excludedKeys and keys of applications,
+// grants all of them to the tenant's admin bunch and gives the bunch to the admin, in one transaction
+func (st *TenantStorage) AddTenant(name string, desc string, excludedKeys []string,
+	admin *tenantmgr.Admin) (int64, error) {
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	res, err := tx.Exec(sqlAddTenant, name, desc, now, now)
+	if err != nil {
+		return 0, err
+	}
+
+	tenantID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	var exclusion string
+	values := make([]interface{}, 0, len(excludedKeys)+4)
+	values = append(values, tenantID, now, now, common.DefaultTenant)
+	if len(excludedKeys) > 0 {
+		conditions := make([]string, 0, len(excludedKeys))
+		for _, k := range excludedKeys {
+			conditions = append(conditions, "?")
+			values = append(values, k)
+		}
+		exclusion = fmt.Sprintf("AND `key` NOT IN (%s)", strings.Join(conditions, ","))
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(sqlCopyTenantKeys, exclusion), values...); err != nil {
+		return 0, err
+	}
+
+	res, err = tx.Exec(sqlAddTenantAdminBunch, tenantID, tenantmgr.AdminBunch, "Administrators of the tenant", now, now)
+	if err != nil {
+		return 0, err
+	}
+
+	bunchID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(sqlAddTenantAdminKeys, bunchID, now, tenantID); err != nil {
+		return 0, err
+	}
+
+	if admin != nil {
+		if err := addTenantAdmin(tx, tenantID, bunchID, admin, now); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return tenantID, nil
+}
+
+func addTenantAdmin(tx *sqlx.Tx, tenantID int64, bunchID int64, admin *tenantmgr.Admin, now time.Time) error {
+	res, err := tx.Exec(sqlAddUser, tenantID, admin.Username, admin.Email, admin.Hash)
+	if err != nil {
+		return err
+	}
+
+	userID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	err = addOutboxEvent(tx, tenantID, webhook.UserCreated, map[string]interface{}{
+		"id":       userID,
+		"username": admin.Username,
+		"email":    admin.Email,
+		"type":     usrmgr.TypeHuman,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(sqlAddTenantAdminBunchToUser, tenantID, userID, bunchID, now); err != nil {
+		return err
+	}
+
+	return addGrantEvents(tx, tenantID, webhook.GrantAdded, userID, []int64{bunchID})
+}
+
+var sqlGetTenantByID = "SELECT id, `name`, `desc`, active, created_at, updated_at FROM tenants WHERE id = ? LIMIT 1;"
+
+func (st *TenantStorage) GetTenant(id int64) (*tenantmgr.Tenant, error) {
+	return st.queryTenant(sqlGetTenantByID, id)
+}
+
+var sqlGetTenantByName = "SELECT id, `name`, `desc`, active, created_at, updated_at FROM tenants " +
+	"WHERE `name` = ? LIMIT 1;"
+
+func (st *TenantStorage) GetTenantByName(name string) (*tenantmgr.Tenant, error) {
+	return st.queryTenant(sqlGetTenantByName, name)
+}
+
+func (st *TenantStorage) queryTenant(query string, args ...interface{}) (*tenantmgr.Tenant, error) {
+	rows, err := st.db.Queryx(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	t := new(tenantmgr.Tenant)
+	if err := rows.Scan(&t.ID, &t.Name, &t.Desc, &t.Active, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+var sqlUpdateTenant = "UPDATE tenants SET %s WHERE id = :id;"
+
+func (st *TenantStorage) ModifyTenant(id int64, name string, desc string, active sql.NullBool) error {
+	updating := map[string]interface{}{
+		"id":         id,
+		"name":       name,
+		"desc":       desc,
+		"updated_at": time.Now(),
+	}
+	condition := "`name` = :name, `desc` = :desc, updated_at = :updated_at"
+
+	if active.Valid {
+		updating["active"] = active.Bool
+		condition += ", active = :active"
+	}
+
+	_, err := st.db.NamedExec(fmt.Sprintf(sqlUpdateTenant, condition), updating)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var sqlQueryTenants = "SELECT id, `name`, `desc`, active, created_at, updated_at FROM tenants %s " +
+	"ORDER BY %s LIMIT :offset, :limit;"
+var sqlQueryTenantsCounter = "SELECT count(id) FROM tenants %s;"
+
+func (st *TenantStorage) QueryTenants(take int64, skip int64, name string, sortby string,
+	direction common.SortingDirection) ([]*tenantmgr.Tenant, int64, error) {
+
+	var (
+		order         string
+		where         string
+		sql           string
+		filter        map[string]interface{}
+		wg            sync.WaitGroup
+		queryErr      error
+		countTotalErr error
+		results       []*tenantmgr.Tenant
+		total         int64
+	)
+
+	filter = make(map[string]interface{})
+
+	if len(name) > 0 {
+		where = "WHERE `name` LIKE :name"
+		filter["name"] = "%" + name + "%"
+	}
+
+	if direction == common.Descending {
+		order = fmt.Sprintf("`%s` DESC, id", sortby)
+	} else {
+		order = fmt.Sprintf("`%s` ASC, id", sortby)
+	}
+
+	sql = fmt.Sprintf(sqlQueryTenants, where, order)
+
+	filter["offset"] = skip
+	filter["limit"] = take
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rows, err := st.db.NamedQuery(sql, filter)
+		if err != nil {
+			queryErr = err
+			return
+		}
+		defer rows.Close()
+
+		results = make([]*tenantmgr.Tenant, 0, take)
+		for rows.Next() {
+			t := new(tenantmgr.Tenant)
+			err := rows.Scan(&t.ID, &t.Name, &t.Desc, &t.Active, &t.CreatedAt, &t.UpdatedAt)
+			if err != nil {
+				queryErr = err
+				return
+			}
+			results = append(results, t)
+		}
+
+		if rows.Err() != nil {
+			queryErr = rows.Err()
+			return
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		rows, err := st.db.NamedQuery(fmt.Sprintf(sqlQueryTenantsCounter, where), filter)
+		if err != nil {
+			countTotalErr = err
+			return
+		}
+		defer rows.Close()
+
+		if rows.Next() {
+			err := rows.Scan(&total)
+			if err != nil {
+				countTotalErr = err
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	if queryErr != nil {
+		return nil, 0, queryErr
+	}
+	if countTotalErr != nil {
+		return nil, 0, countTotalErr
+	}
+
+	return results, total, nil
+}
@@ -0,0 +1,123 @@
+package mysql
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/tenantmgr"
+	"testing"
+)
+
+func TestTenantStorage_AddTenant(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_add_tenant_with_admin_bunch", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("tenant")
+		key := test.mig.createUniqueString("key")
+		_, err := test.kst.AddKey(key, "", 0)
+		require.Nil(t, err)
+
+		id, err := test.tnst.AddTenant(name, "desc", tenantmgr.RootKeys, nil)
+		require.Nil(t, err)
+		require.NotZero(t, id)
+
+		tenant, err := test.tnst.GetTenantByName(name)
+		require.Nil(t, err)
+		require.NotNil(t, tenant)
+		require.Equal(t, id, tenant.ID)
+		require.True(t, tenant.Active)
+
+		keys, err := test.bst.WithTenant(id).GetKeysInBunch(tenantmgr.AdminBunch)
+		require.Nil(t, err)
+		names := make([]string, 0, len(keys))
+		for _, k := range keys {
+			names = append(names, k.Key)
+			require.NotContains(t, tenantmgr.RootKeys, k.Key)
+		}
+		require.Contains(t, names, key)
+	})
+
+	t.Run("success_add_tenant_with_admin", func(t *testing.T) {
+		t.Parallel()
+
+		admin := &tenantmgr.Admin{Username: test.mig.createUniqueString("username"),
+			Email: test.mig.createUniqueString("email"), Hash: "hash"}
+		id, err := test.tnst.AddTenant(test.mig.createUniqueString("tenant"), "", tenantmgr.RootKeys, admin)
+		require.Nil(t, err)
+
+		bunches, err := test.ust.WithTenant(id).GetBunches(admin.Username)
+		require.Nil(t, err)
+		require.Len(t, bunches, 1)
+		require.Equal(t, tenantmgr.AdminBunch, bunches[0].Name)
+	})
+
+	t.Run("success_isolate_data_of_tenants", func(t *testing.T) {
+		t.Parallel()
+
+		key := test.mig.createUniqueString("key")
+		id, err := test.tnst.AddTenant(test.mig.createUniqueString("tenant"), "", nil, nil)
+		require.Nil(t, err)
+
+		_, err = test.kst.AddKey(key, "", 0)
+		require.Nil(t, err)
+
+		found, err := test.kst.WithTenant(id).GetKeyByName(key)
+		require.Nil(t, err)
+		require.Nil(t, found)
+
+		// the same key can be added in another tenant
+		_, err = test.kst.WithTenant(id).AddKey(key, "", 0)
+		require.Nil(t, err)
+	})
+
+	t.Run("fail_duplicated_name", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("tenant")
+
+		_, err := test.tnst.AddTenant(name, "", nil, nil)
+		require.Nil(t, err)
+
+		_, err = test.tnst.AddTenant(name, "", nil, nil)
+		require.NotNil(t, err)
+	})
+}
+
+func TestTenantStorage_ModifyTenant(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_deactivate_tenant", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("tenant")
+		id, err := test.tnst.AddTenant(name, "desc", nil, nil)
+		require.Nil(t, err)
+
+		err = test.tnst.ModifyTenant(id, name, "new desc", sql.NullBool{Bool: false, Valid: true})
+		require.Nil(t, err)
+
+		tenant, err := test.tnst.GetTenant(id)
+		require.Nil(t, err)
+		require.Equal(t, "new desc", tenant.Desc)
+		require.False(t, tenant.Active)
+	})
+}
+
+func TestTenantStorage_QueryTenants(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_query_by_name", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("tenant")
+		_, err := test.tnst.AddTenant(name, "", nil, nil)
+		require.Nil(t, err)
+
+		tenants, total, err := test.tnst.QueryTenants(10, 0, name, "id", common.Ascending)
+		require.Nil(t, err)
+		require.Equal(t, int64(1), total)
+		require.Equal(t, name, tenants[0].Name)
+	})
+}
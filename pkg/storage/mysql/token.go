@@ -0,0 +1,160 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
+	"strings"
+	"time"
+)
+
+// TokenStorage implements db's storage for personal access tokens
+type TokenStorage struct {
+	db *sqlx.DB
+}
+
+// NewTokenStorage create new instance of TokenStorage
+func NewTokenStorage(db *sqlx.DB) *TokenStorage {
+	return &TokenStorage{
+		db,
+	}
+}
+
+var sqlAddToken = "INSERT INTO personal_access_tokens (user_id, `name`, prefix, `hash`, expires_at, revoked, created_at) " +
+	"VALUES (?, ?, ?, ?, ?, ?, ?);"
+var sqlAddTokenKeys = "INSERT INTO personal_access_token_keys (token_id, key_id) " +
+	"SELECT ?, `keys`.id FROM `keys` INNER JOIN users ON users.tenant_id = `keys`.tenant_id " +
+	"WHERE users.id = ? AND `keys`.`key` IN (%s);"
+
+func (st *TokenStorage) AddToken(userID int64, name string, prefix string, hash string, keys []string,
+	expiresAt sql.NullTime) (int64, error) {
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(sqlAddToken, userID, name, prefix, hash, expiresAt, false, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	conditions := make([]string, 0, len(keys))
+	values := make([]interface{}, 0, len(keys)+2)
+	values = append(values, lastID, userID)
+	for _, k := range keys {
+		conditions = append(conditions, "?")
+		values = append(values, k)
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(sqlAddTokenKeys, strings.Join(conditions, ",")), values...); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return lastID, nil
+}
+
+var sqlSelectTokens = "SELECT personal_access_tokens.id, personal_access_tokens.user_id, users.tenant_id, users.username, " +
+	"personal_access_tokens.`name`, personal_access_tokens.prefix, personal_access_tokens.`hash`, " +
+	"personal_access_tokens.expires_at, personal_access_tokens.revoked, personal_access_tokens.last_used_at, " +
+	"personal_access_tokens.created_at, `keys`.`key` FROM personal_access_tokens " +
+	"INNER JOIN users ON users.id = personal_access_tokens.user_id " +
+	"INNER JOIN personal_access_token_keys ON personal_access_token_keys.token_id = personal_access_tokens.id " +
+	"INNER JOIN `keys` ON `keys`.id = personal_access_token_keys.key_id "
+
+var sqlGetToken = sqlSelectTokens + "WHERE personal_access_tokens.user_id = ? AND personal_access_tokens.`name` = ? " +
+	"ORDER BY `keys`.`key`;"
+
+func (st *TokenStorage) GetToken(userID int64, name string) (*tokenmgr.Token, error) {
+	return st.queryToken(sqlGetToken, userID, name)
+}
+
+var sqlGetTokenByHash = sqlSelectTokens + "WHERE personal_access_tokens.`hash` = ? ORDER BY `keys`.`key`;"
+
+func (st *TokenStorage) GetTokenByHash(hash string) (*tokenmgr.Token, error) {
+	return st.queryToken(sqlGetTokenByHash, hash)
+}
+
+var sqlGetTokens = sqlSelectTokens + "WHERE personal_access_tokens.user_id = ? " +
+	"ORDER BY personal_access_tokens.id, `keys`.`key`;"
+
+func (st *TokenStorage) GetTokens(userID int64) ([]*tokenmgr.Token, error) {
+	return st.queryTokens(sqlGetTokens, userID)
+}
+
+func (st *TokenStorage) queryToken(query string, args ...interface{}) (*tokenmgr.Token, error) {
+	results, err := st.queryTokens(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	return results[0], nil
+}
+
+func (st *TokenStorage) queryTokens(query string, args ...interface{}) ([]*tokenmgr.Token, error) {
+	rows, err := st.db.Queryx(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last *tokenmgr.Token
+	results := make([]*tokenmgr.Token, 0)
+	for rows.Next() {
+		var key string
+		t := new(tokenmgr.Token)
+		if err := rows.Scan(&t.ID, &t.UserID, &t.TenantID, &t.Username, &t.Name, &t.Prefix, &t.Hash, &t.ExpiresAt,
+			&t.Revoked, &t.LastUsedAt, &t.CreatedAt, &key); err != nil {
+			return nil, err
+		}
+
+		if last == nil || last.ID != t.ID {
+			last = t
+			results = append(results, t)
+		}
+		last.Keys = append(last.Keys, key)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+var sqlRevokeToken = "UPDATE personal_access_tokens SET revoked = 1 WHERE id = ?;"
+
+func (st *TokenStorage) RevokeToken(id int64) error {
+	_, err := st.db.Exec(sqlRevokeToken, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var sqlTouchToken = "UPDATE personal_access_tokens SET last_used_at = ? " +
+	"WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?);"
+
+func (st *TokenStorage) TouchToken(id int64, usedAt time.Time, before time.Time) error {
+	_, err := st.db.Exec(sqlTouchToken, usedAt, id, before)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
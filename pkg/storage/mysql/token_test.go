@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenStorage_AddToken(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_add_token_with_keys", func(t *testing.T) {
+		t.Parallel()
+
+		userID := test.mig.createSeedingUser(nil)
+		key1 := test.mig.createUniqueString("key")
+		key2 := test.mig.createUniqueString("key")
+		test.mig.createSeedingServiceKey(func(field map[string]interface{}) { field["key"] = key1 })
+		test.mig.createSeedingServiceKey(func(field map[string]interface{}) { field["key"] = key2 })
+		hash := test.mig.createUniqueString("hash")
+		expiresAt := sql.NullTime{Time: time.Now().Add(time.Hour).Truncate(time.Second), Valid: true}
+
+		id, err := test.tst.AddToken(userID, "ci", "abcdefgh", hash, []string{key1, key2}, expiresAt)
+		require.Nil(t, err)
+
+		token, err := test.tst.GetTokenByHash(hash)
+		require.Nil(t, err)
+		require.Equal(t, id, token.ID)
+		require.Equal(t, "ci", token.Name)
+		require.ElementsMatch(t, []string{key1, key2}, token.Keys)
+		require.True(t, token.ExpiresAt.Valid)
+		require.False(t, token.Revoked)
+		require.False(t, token.LastUsedAt.Valid)
+	})
+
+	t.Run("fail_duplicated_name", func(t *testing.T) {
+		t.Parallel()
+
+		userID := test.mig.createSeedingUser(nil)
+		key := test.mig.createUniqueString("key")
+		test.mig.createSeedingServiceKey(func(field map[string]interface{}) { field["key"] = key })
+
+		_, err := test.tst.AddToken(userID, "ci", "abcdefgh", test.mig.createUniqueString("hash"),
+			[]string{key}, sql.NullTime{})
+		require.Nil(t, err)
+
+		_, err = test.tst.AddToken(userID, "ci", "abcdefgh", test.mig.createUniqueString("hash"),
+			[]string{key}, sql.NullTime{})
+		require.NotNil(t, err)
+	})
+}
+
+func TestTokenStorage_RevokeToken(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_revoke_and_touch_token", func(t *testing.T) {
+		t.Parallel()
+
+		userID := test.mig.createSeedingUser(nil)
+		key := test.mig.createUniqueString("key")
+		test.mig.createSeedingServiceKey(func(field map[string]interface{}) { field["key"] = key })
+
+		id, err := test.tst.AddToken(userID, "script", "abcdefgh", test.mig.createUniqueString("hash"),
+			[]string{key}, sql.NullTime{})
+		require.Nil(t, err)
+
+		now := time.Now()
+		require.Nil(t, test.tst.TouchToken(id, now, now.Add(-time.Minute)))
+		require.Nil(t, test.tst.RevokeToken(id))
+
+		tokens, err := test.tst.GetTokens(userID)
+		require.Nil(t, err)
+		require.Len(t, tokens, 1)
+		require.True(t, tokens[0].Revoked)
+		require.True(t, tokens[0].LastUsedAt.Valid)
+	})
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/condition"
 	"github.com/vespaiach/auth/pkg/usrmgr"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,25 +16,34 @@ import (
 
 // UserStorage implements db's storage for user
 type UserStorage struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	tenant int64
 }
 
-// NewUserStorage create new instance of BunchStorage
+// NewUserStorage create new instance of BunchStorage, working on users of the default tenant
 func NewUserStorage(db *sqlx.DB) *UserStorage {
 	return &UserStorage{
 		db,
+		common.DefaultTenant,
 	}
 }
 
-var sqlAddUser = "INSERT INTO users(username, email, hash) VALUES(?, ?, ?);"
+// WithTenant returns a storage working on users of the tenant
+func (st *UserStorage) WithTenant(tenantID int64) usrmgr.Storer {
+	return &UserStorage{st.db, tenantID}
+}
+
+// users without email have none rather than an empty one, which the unique index of emails would hold only once
+var sqlAddUser = "INSERT INTO users(tenant_id, username, email, hash) VALUES(?, ?, NULLIF(?, ''), ?);"
 
 func (st *UserStorage) AddUser(username string, email string, hash string) (int64, error) {
-	stmt, err := st.db.Prepare(sqlAddUser)
+	tx, err := st.db.Beginx()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
-	res, err := stmt.Exec(username, email, hash)
+	res, err := tx.Exec(sqlAddUser, st.tenant, username, email, hash)
 	if err != nil {
 		return 0, err
 	}
@@ -41,12 +53,25 @@ func (st *UserStorage) AddUser(username string, email string, hash string) (int6
 		return 0, err
 	}
 
-	return lastID, nil
+	err = addOutboxEvent(tx, st.tenant, webhook.UserCreated, map[string]interface{}{
+		"id":       lastID,
+		"username": username,
+		"email":    email,
+		"type":     usrmgr.TypeHuman,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return lastID, tx.Commit()
 }
 
-var sqlUpdateUser = "UPDATE `users` SET %s	WHERE id = :id;"
+var sqlUpdateUser = "UPDATE `users` SET %s	WHERE id = :id AND tenant_id = :tenant_id;"
+var sqlLockUser = "SELECT `username`, active FROM `users` WHERE id = ? AND tenant_id = ? FOR UPDATE;"
+
+func (st *UserStorage) ModifyUser(id int64, username string, email string, hash string, active sql.NullBool,
+	locale sql.NullString) error {
 
-func (st *UserStorage) ModifyUser(id int64, username string, email string, hash string, active sql.NullBool) error {
 	updating := make(map[string]interface{})
 	var condition string
 	var prefix string
@@ -75,48 +100,147 @@ func (st *UserStorage) ModifyUser(id int64, username string, email string, hash
 		prefix = ", "
 	}
 
-	if len(updating) > 0 {
-		updating["id"] = id
-		updating["updated_at"] = time.Now()
-		condition += prefix + "`updated_at` = :updated_at"
+	if locale.Valid {
+		updating["locale"] = locale.String
+		condition += prefix + "`locale` = :locale"
+		prefix = ", "
+	}
 
-		_, err := st.db.NamedExec(fmt.Sprintf(sqlUpdateUser, condition), updating)
+	if len(updating) == 0 {
+		return nil
+	}
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	var wasActive sql.NullBool
+	err = tx.QueryRowx(sqlLockUser, id, st.tenant).Scan(&current, &wasActive)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// the hash is told as a change of password, and isn't sent along
+	changes := make([]string, 0, 3)
+	for field, change := range map[string]string{"username": "username", "email": "email", "hash": "password"} {
+		if _, ok := updating[field]; ok {
+			changes = append(changes, change)
+		}
+	}
+	sort.Strings(changes)
+
+	updating["id"] = id
+	updating["tenant_id"] = st.tenant
+	updating["updated_at"] = time.Now()
+	condition += prefix + "`updated_at` = :updated_at"
+
+	if _, err := tx.NamedExec(fmt.Sprintf(sqlUpdateUser, condition), updating); err != nil {
+		return err
+	}
+
+	// a password which is set otherwise makes the user's invite useless
+	if len(hash) > 0 {
+		if _, err := tx.Exec(sqlDeleteUserInvite, st.tenant, id); err != nil {
+			return err
+		}
+	}
+
+	if len(username) > 0 {
+		current = username
+	}
+
+	if len(changes) > 0 {
+		err = addOutboxEvent(tx, st.tenant, webhook.UserUpdated, map[string]interface{}{
+			"id":       id,
+			"username": current,
+			"changes":  changes,
+		})
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if active.Valid {
+		was := !wasActive.Valid || wasActive.Bool
+		if typ := activity(was, active.Bool, webhook.UserActivated, webhook.UserDeactivated); len(typ) > 0 {
+			err = addOutboxEvent(tx, st.tenant, typ, map[string]interface{}{"id": id, "username": current})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
 }
 
-var sqlGetUserByName = "SELECT id, `username`, `email`, `hash`, active, created_at, updated_at FROM `users` " +
-	"WHERE `username` = ? LIMIT 1;"
+var (
+	sqlGetUserBunchIDs = "SELECT bunch_id FROM user_bunches WHERE tenant_id = ? AND user_id = ?;"
+	sqlDeleteUser      = "DELETE FROM `users` WHERE id = ? AND tenant_id = ?;"
+)
 
-func (st *UserStorage) GetUserByUsername(username string) (*usrmgr.User, error) {
-	rows, err := st.db.Queryx(sqlGetUserByName, username)
+// DeleteUser removes the user in one transaction along with its grants, which are told as revoked, and revokes
+// its sessions. Rows of the user in other tables are removed by their foreign keys.
+func (st *UserStorage) DeleteUser(id int64) error {
+	tx, err := st.db.Beginx()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	if !rows.Next() {
-		return nil, nil
+	var username string
+	var active sql.NullBool
+	err = tx.QueryRowx(sqlLockUser, id, st.tenant).Scan(&username, &active)
+	if err == sql.ErrNoRows {
+		return common.ErrUserNotFound
+	}
+	if err != nil {
+		return err
 	}
 
-	u := new(usrmgr.User)
-	err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.Hash, &u.Active, &u.CreatedAt, &u.UpdatedAt)
+	var bunchIDs []int64
+	if err := tx.Select(&bunchIDs, sqlGetUserBunchIDs, st.tenant, id); err != nil {
+		return err
+	}
+	if len(bunchIDs) > 0 {
+		if err := addGrantEvents(tx, st.tenant, webhook.GrantRevoked, id, bunchIDs); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(sqlRevokeSessions, time.Now(), id); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(sqlDeleteUser, id, st.tenant); err != nil {
+		return err
+	}
+
+	err = addOutboxEvent(tx, st.tenant, webhook.UserDeleted, map[string]interface{}{
+		"id":       id,
+		"username": username,
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return u, nil
+	return tx.Commit()
 }
 
-var sqlGetUserEmail = "SELECT id, `username`, `email`, `hash`, active, created_at, updated_at FROM `users` " +
-	"WHERE `email` = ? LIMIT 1;"
+// service accounts have no email, and their owner is read along with them
+var sqlUserColumns = "SELECT users.id, users.tenant_id, users.`username`, IFNULL(users.`email`, ''), users.`hash`, users.active, " +
+	"users.`type`, IFNULL(owners.`username`, ''), users.`desc`, users.created_at, users.updated_at, users.locale " +
+	"FROM `users` LEFT JOIN `users` AS owners ON owners.id = users.owner_id "
 
-func (st *UserStorage) GetUserByEmail(email string) (*usrmgr.User, error) {
-	rows, err := st.db.Queryx(sqlGetUserEmail, email)
+var sqlGetUserByName = sqlUserColumns + "WHERE users.tenant_id = ? AND users.`username` = ? LIMIT 1;"
+
+func (st *UserStorage) GetUserByUsername(username string) (*usrmgr.User, error) {
+	rows, err := st.db.Queryx(sqlGetUserByName, st.tenant, username)
 	if err != nil {
 		return nil, err
 	}
@@ -126,20 +250,29 @@ func (st *UserStorage) GetUserByEmail(email string) (*usrmgr.User, error) {
 		return nil, nil
 	}
 
-	u := new(usrmgr.User)
-	err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.Hash, &u.Active, &u.CreatedAt, &u.UpdatedAt)
+	return scanUser(rows)
+}
+
+var sqlGetUserEmail = sqlUserColumns + "WHERE users.tenant_id = ? AND users.`email` = ? LIMIT 1;"
+
+func (st *UserStorage) GetUserByEmail(email string) (*usrmgr.User, error) {
+	rows, err := st.db.Queryx(sqlGetUserEmail, st.tenant, email)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return u, nil
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	return scanUser(rows)
 }
 
-var sqlGetUserByID = "SELECT id, `username`, `email`, `hash`, active, created_at, updated_at FROM `users` " +
-	"WHERE `id` = ? LIMIT 1;"
+var sqlGetUserByID = sqlUserColumns + "WHERE users.tenant_id = ? AND users.`id` = ? LIMIT 1;"
 
 func (st *UserStorage) GetUser(id int64) (*usrmgr.User, error) {
-	rows, err := st.db.Queryx(sqlGetUserByID, id)
+	rows, err := st.db.Queryx(sqlGetUserByID, st.tenant, id)
 	if err != nil {
 		return nil, err
 	}
@@ -149,38 +282,271 @@ func (st *UserStorage) GetUser(id int64) (*usrmgr.User, error) {
 		return nil, nil
 	}
 
-	u := new(usrmgr.User)
-	err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.Hash, &u.Active, &u.CreatedAt, &u.UpdatedAt)
+	return scanUser(rows)
+}
+
+var sqlAddServiceAccount = "INSERT INTO users(tenant_id, username, hash, `type`, owner_id, `desc`) " +
+	"VALUES(?, ?, ?, ?, ?, ?);"
+
+// AddServiceAccount creates a user without email, the hash is an unusable one which no password matches
+func (st *UserStorage) AddServiceAccount(username string, ownerID int64, desc string, hash string) (int64, error) {
+	tx, err := st.db.Beginx()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	return u, nil
+	res, err := tx.Exec(sqlAddServiceAccount, st.tenant, username, hash, usrmgr.TypeService, ownerID, desc)
+	if err != nil {
+		return 0, err
+	}
+
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	err = addOutboxEvent(tx, st.tenant, webhook.UserCreated, map[string]interface{}{
+		"id":       lastID,
+		"username": username,
+		"type":     usrmgr.TypeService,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return lastID, tx.Commit()
 }
 
-var sqlAddBunchesToUser = "INSERT INTO `user_bunches` (user_id, bunch_id, created_at) VALUES %s;"
+var sqlUpdateServiceAccount = "UPDATE `users` SET %s WHERE id = :id AND tenant_id = :tenant_id AND `type` = :type;"
+
+func (st *UserStorage) ModifyServiceAccount(id int64, ownerID int64, desc string) error {
+	updating := make(map[string]interface{})
+	var condition string
+	var prefix string
 
-func (st *UserStorage) AddBunchesToUser(userID int64, bunchIDs []int64) error {
+	if ownerID > 0 {
+		updating["owner_id"] = ownerID
+		condition += prefix + "`owner_id` = :owner_id"
+		prefix = ", "
+	}
+
+	if len(desc) > 0 {
+		updating["desc"] = desc
+		condition += prefix + "`desc` = :desc"
+		prefix = ", "
+	}
+
+	if len(updating) > 0 {
+		updating["id"] = id
+		updating["tenant_id"] = st.tenant
+		updating["type"] = usrmgr.TypeService
+		updating["updated_at"] = time.Now()
+		condition += prefix + "`updated_at` = :updated_at"
+
+		_, err := st.db.NamedExec(fmt.Sprintf(sqlUpdateServiceAccount, condition), updating)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var sqlAddBunchesToUser = "INSERT INTO `user_bunches` (tenant_id, user_id, bunch_id, created_at) VALUES %s;"
+
+// AddBunchesToUser grants the bunches to the user. When check is given, it's called with ids of the bunches which
+// the user holds and the grants are only added when it passes. Checked grants of a user are serialized on the
+// user's row, so that the held bunches don't change before the new ones are added.
+func (st *UserStorage) AddBunchesToUser(userID int64, bunchIDs []int64, check func(held []int64) error) error {
 	updating := make([]string, 0, len(bunchIDs))
 	for _, id := range bunchIDs {
-		updating = append(updating, fmt.Sprintf("(%d, %d, :created_at)", userID, id))
+		updating = append(updating, fmt.Sprintf("(%d, %d, %d, :created_at)", st.tenant, userID, id))
 	}
 
-	sql := fmt.Sprintf(sqlAddBunchesToUser, strings.Join(updating, ", "))
+	query := fmt.Sprintf(sqlAddBunchesToUser, strings.Join(updating, ", "))
 
-	_, err := st.db.NamedExec(sql, map[string]interface{}{"created_at": time.Now()})
+	tx, err := st.db.Beginx()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	if check != nil {
+		var username string
+		var active sql.NullBool
+		err = tx.QueryRowx(sqlLockUser, userID, st.tenant).Scan(&username, &active)
+		if err == sql.ErrNoRows {
+			return common.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		held := make([]int64, 0)
+		if err := tx.Select(&held, sqlGetUserBunchIDs, st.tenant, userID); err != nil {
+			return err
+		}
+
+		if err := check(held); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.NamedExec(query, map[string]interface{}{"created_at": time.Now()})
+	if err != nil {
+		return err
+	}
+
+	if err := addGrantEvents(tx, st.tenant, webhook.GrantAdded, userID, bunchIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var sqlRemoveBunchesFromUser = "DELETE FROM `user_bunches` WHERE tenant_id = ? AND user_id = ? AND bunch_id IN (%s);"
+
+func (st *UserStorage) RemoveBunchesFromUser(userID int64, bunchIDs []int64) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := removeGrants(tx, st.tenant, userID, bunchIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// removeGrants takes the bunches back from the user in the transaction, it's the one removal of grants which
+// every revoke goes through
+func removeGrants(tx *sqlx.Tx, tenant int64, userID int64, bunchIDs []int64) error {
+	conditions := make([]string, 0, len(bunchIDs))
+	values := make([]interface{}, 0, len(bunchIDs)+2)
+	values = append(values, tenant, userID)
+	for _, id := range bunchIDs {
+		conditions = append(conditions, "?")
+		values = append(values, id)
+	}
+
+	// events are written first, as grants can't be looked up once they're removed
+	if err := addGrantEvents(tx, tenant, webhook.GrantRevoked, userID, bunchIDs); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(sqlRemoveBunchesFromUser, strings.Join(conditions, ",")), values...)
+	return err
 }
 
-var sqlQueryUsers = "SELECT id, `username`, `email`, `hash`, active, created_at, updated_at FROM `users` %s ORDER BY %s LIMIT :offset, :limit;"
+var sqlBulkAddUser = "INSERT INTO users (tenant_id, username, email, hash, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?);"
+var sqlBulkAddUserBunch = "INSERT INTO user_bunches (tenant_id, user_id, bunch_id, created_at) VALUES (?, ?, ?, ?);"
+var sqlAddInvite = "INSERT INTO user_invites (tenant_id, user_id, hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?);"
+
+// ImportUsers creates users, their bunches and the invites of users without password in one transaction
+func (st *UserStorage) ImportUsers(rows []*usrmgr.ImportRow) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, row := range rows {
+		res, err := tx.Exec(sqlBulkAddUser, st.tenant, row.Username, row.Email, row.Hash, now, now)
+		if err != nil {
+			return err
+		}
+
+		userID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, bunchID := range row.BunchIDs {
+			if _, err := tx.Exec(sqlBulkAddUserBunch, st.tenant, userID, bunchID, now); err != nil {
+				return err
+			}
+		}
+
+		if len(row.Invite) > 0 {
+			if _, err := tx.Exec(sqlAddInvite, st.tenant, userID, row.Invite, row.InviteExpiresAt, now); err != nil {
+				return err
+			}
+		}
+
+		err = addOutboxEvent(tx, st.tenant, webhook.UserCreated, map[string]interface{}{
+			"id":       userID,
+			"username": row.Username,
+			"email":    row.Email,
+			"type":     usrmgr.TypeHuman,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := addGrantEvents(tx, st.tenant, webhook.GrantAdded, userID, row.BunchIDs); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+var sqlGetInvite = "SELECT user_id, tenant_id, hash, expires_at FROM user_invites WHERE hash = ?;"
+
+// GetInvite finds the invite by its hash in any tenant, invites are accepted before their users are known
+func (st *UserStorage) GetInvite(hash string) (*usrmgr.Invite, error) {
+	inv := new(usrmgr.Invite)
+	err := st.db.QueryRowx(sqlGetInvite, hash).Scan(&inv.UserID, &inv.TenantID, &inv.Hash, &inv.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+var sqlDeleteInvite = "DELETE FROM user_invites WHERE tenant_id = ? AND user_id = ? AND hash = ?;"
+var sqlDeleteUserInvite = "DELETE FROM user_invites WHERE tenant_id = ? AND user_id = ?;"
+var sqlSetUserHash = "UPDATE `users` SET hash = ?, updated_at = ? WHERE tenant_id = ? AND id = ?;"
+
+// AcceptInvite sets the user's hash and removes the invite in one transaction. An invite which is accepted
+// concurrently is only accepted once.
+func (st *UserStorage) AcceptInvite(userID int64, inviteHash string, hash string) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(sqlDeleteInvite, st.tenant, userID, inviteHash)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = common.ErrInviteInvalid
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(sqlSetUserHash, hash, time.Now(), st.tenant, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var sqlQueryUsers = sqlUserColumns + "%s ORDER BY %s LIMIT :offset, :limit;"
 var sqlQueryUsersCounter = "SELECT count(id) FROM `users` %s;"
 
 func (st *UserStorage) QueryUsers(take int64, skip int64, username string, email string, active sql.NullBool,
-	sortby string, direction common.SortingDirection) ([]*usrmgr.User, int64, error) {
+	userType string, sortby string, direction common.SortingDirection) ([]*usrmgr.User, int64, error) {
 
 	var (
 		order         string
@@ -197,29 +563,36 @@ func (st *UserStorage) QueryUsers(take int64, skip int64, username string, email
 
 	filter = make(map[string]interface{})
 
+	where = "WHERE users.tenant_id = :tenant_id"
+	filter["tenant_id"] = st.tenant
+	prefix = " AND "
+
 	if len(username) > 0 {
-		where = "WHERE `username` LIKE :username"
+		where += prefix + "users.`username` LIKE :username"
 		filter["username"] = "%" + username + "%"
-		prefix = " AND "
-	} else {
-		prefix = " WHERE "
 	}
 
 	if len(email) > 0 {
-		where += prefix + "`email` LIKE :email"
+		where += prefix + "users.`email` LIKE :email"
 		filter["email"] = "%" + email + "%"
 		prefix = " AND "
 	}
 
 	if active.Valid {
-		where += prefix + "`active` = :active"
+		where += prefix + "users.`active` = :active"
 		filter["active"] = active.Bool
+		prefix = " AND "
+	}
+
+	if len(userType) > 0 {
+		where += prefix + "users.`type` = :type"
+		filter["type"] = userType
 	}
 
 	if direction == common.Descending {
-		order = fmt.Sprintf("`%s` DESC, id", sortby)
+		order = fmt.Sprintf("users.`%s` DESC, users.id", sortby)
 	} else {
-		order = fmt.Sprintf("`%s` ASC, id", sortby)
+		order = fmt.Sprintf("users.`%s` ASC, users.id", sortby)
 	}
 
 	sql = fmt.Sprintf(sqlQueryUsers, where, order)
@@ -239,8 +612,7 @@ func (st *UserStorage) QueryUsers(take int64, skip int64, username string, email
 
 		results = make([]*usrmgr.User, 0, take)
 		for rows.Next() {
-			u := new(usrmgr.User)
-			err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Hash, &u.Active, &u.CreatedAt, &u.UpdatedAt)
+			u, err := scanUser(rows)
 			if err != nil {
 				queryErr = err
 				return
@@ -286,12 +658,13 @@ func (st *UserStorage) QueryUsers(take int64, skip int64, username string, email
 	return results, total, nil
 }
 
-var sqlGetBunchIDs = "SELECT id FROM bunches WHERE `name` IN (%s);"
+var sqlGetBunchIDs = "SELECT id FROM bunches WHERE tenant_id = ? AND `name` IN (%s);"
 
 func (st *UserStorage) GetBunchIDs(bunches []string) ([]int64, error) {
 	len := len(bunches)
 	conditions := make([]string, 0, len)
-	values := make([]interface{}, 0, len)
+	values := make([]interface{}, 0, len+1)
+	values = append(values, st.tenant)
 	for i := 0; i < len; i++ {
 		conditions = append(conditions, "?")
 		values = append(values, interface{}(bunches[i]))
@@ -322,13 +695,13 @@ func (st *UserStorage) GetBunchIDs(bunches []string) ([]int64, error) {
 }
 
 var sqlGetBunchesByUsername = "SELECT bunches.id, bunches.`name`, bunches.`desc`, bunches.active, " +
-	"bunches.created_at, bunches.updated_at FROM `user_bunches` " +
+	"IFNULL(user_bunches.expression, ''), bunches.created_at, bunches.updated_at FROM `user_bunches` " +
 	"INNER JOIN `users` ON `user_bunches`.user_id = `users`.id " +
 	"INNER JOIN `bunches` ON `bunches`.id = `user_bunches`.bunch_id " +
-	"WHERE `users`.username = ?"
+	"WHERE `users`.tenant_id = ? AND `users`.username = ?"
 
 func (st *UserStorage) GetBunches(username string) ([]*usrmgr.Bunch, error) {
-	rows, err := st.db.Queryx(sqlGetBunchesByUsername, username)
+	rows, err := st.db.Queryx(sqlGetBunchesByUsername, st.tenant, username)
 	if err != nil {
 		return nil, err
 	}
@@ -337,7 +710,7 @@ func (st *UserStorage) GetBunches(username string) ([]*usrmgr.Bunch, error) {
 	results := make([]*usrmgr.Bunch, 0)
 	for rows.Next() {
 		b := new(usrmgr.Bunch)
-		if err := rows.Scan(&b.ID, &b.Name, &b.Desc, &b.Active, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		if err := rows.Scan(&b.ID, &b.Name, &b.Desc, &b.Active, &b.Condition, &b.CreatedAt, &b.UpdatedAt); err != nil {
 			return nil, err
 		}
 		results = append(results, b)
@@ -350,27 +723,45 @@ func (st *UserStorage) GetBunches(username string) ([]*usrmgr.Bunch, error) {
 	return results, nil
 }
 
-var sqlGetKeysByUsername = "SELECT DISTINCT `keys`.id, `keys`.`key`, `keys`.`desc`, `keys`.created_at, `keys`.updated_at " +
+var sqlGetKeysByUsername = "SELECT `keys`.id, `keys`.`key`, `keys`.`desc`, IFNULL(user_bunches.expression, ''), " +
+	"IFNULL(bunch_keys.expression, ''), `keys`.created_at, `keys`.updated_at " +
 	"FROM `users` INNER JOIN user_bunches ON `user_bunches`.user_id = `users`.id " +
 	"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id " +
 	"INNER JOIN bunch_keys ON bunch_keys.bunch_id = bunches.id " +
 	"INNER JOIN `keys` ON `keys`.id = bunch_keys.key_id " +
-	"WHERE `users`.username = ?"
+	"WHERE `users`.tenant_id = ? AND `users`.username = ? ORDER BY `keys`.id"
 
+// GetKeys returns keys granted to the user once each, along with conditions of the grants leading to them.
+// A key granted without condition through one of its grants has no conditions at all.
 func (st *UserStorage) GetKeys(username string) ([]*usrmgr.Key, error) {
-	rows, err := st.db.Queryx(sqlGetKeysByUsername, username)
+	rows, err := st.db.Queryx(sqlGetKeysByUsername, st.tenant, username)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	results := make([]*usrmgr.Key, 0)
+	unconditional := make(map[int64]bool)
 	for rows.Next() {
+		var bunchCondition, keyCondition string
 		key := new(usrmgr.Key)
-		if err := rows.Scan(&key.ID, &key.Key, &key.Desc, &key.CreatedAt, &key.UpdatedAt); err != nil {
+		err := rows.Scan(&key.ID, &key.Key, &key.Desc, &bunchCondition, &keyCondition, &key.CreatedAt, &key.UpdatedAt)
+		if err != nil {
 			return nil, err
 		}
-		results = append(results, key)
+
+		n := len(results)
+		if n == 0 || results[n-1].ID != key.ID {
+			results = append(results, key)
+			n++
+		}
+
+		if expr := condition.All(bunchCondition, keyCondition); len(expr) == 0 {
+			unconditional[key.ID] = true
+			results[n-1].Conditions = nil
+		} else if !unconditional[key.ID] {
+			results[n-1].Conditions = append(results[n-1].Conditions, expr)
+		}
 	}
 
 	if rows.Err() != nil {
@@ -379,3 +770,247 @@ func (st *UserStorage) GetKeys(username string) ([]*usrmgr.Key, error) {
 
 	return results, nil
 }
+
+var sqlSetBunchCondition = "UPDATE user_bunches SET expression = ? WHERE tenant_id = ? AND user_id = ? AND bunch_id = ?"
+
+func (st *UserStorage) SetBunchCondition(userID int64, bunchID int64, expression sql.NullString) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqlSetBunchCondition, expression, st.tenant, userID, bunchID); err != nil {
+		return err
+	}
+
+	err = addConditionEvent(tx, sqlGetGrantNames, st.tenant, webhook.GrantConditioned, userID, bunchID,
+		[2]string{"username", "bunch"}, expression)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var sqlGetAttributes = "SELECT `name`, `value` FROM user_attributes WHERE tenant_id = ? AND user_id = ?"
+
+func (st *UserStorage) GetAttributes(userID int64) (map[string]string, error) {
+	rows, err := st.db.Queryx(sqlGetAttributes, st.tenant, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		results[name] = value
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+var (
+	sqlRemoveAttributes = "DELETE FROM user_attributes WHERE tenant_id = ? AND user_id = ?"
+	sqlAddAttribute     = "INSERT INTO user_attributes (tenant_id, user_id, `name`, `value`) VALUES (?, ?, ?, ?)"
+)
+
+// SetAttributes replaces all attributes of the user
+func (st *UserStorage) SetAttributes(userID int64, attributes map[string]string) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqlRemoveAttributes, st.tenant, userID); err != nil {
+		return err
+	}
+	for name, value := range attributes {
+		if _, err := tx.Exec(sqlAddAttribute, st.tenant, userID, name, value); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+var sqlGetUserKeyIDs = "SELECT id FROM `keys` WHERE tenant_id = ? AND `key` IN (%s);"
+
+func (st *UserStorage) GetKeyIDs(keys []string) ([]int64, error) {
+	conditions := make([]string, 0, len(keys))
+	values := make([]interface{}, 0, len(keys)+1)
+	values = append(values, st.tenant)
+	for _, k := range keys {
+		conditions = append(conditions, "?")
+		values = append(values, k)
+	}
+
+	rows, err := st.db.Queryx(fmt.Sprintf(sqlGetUserKeyIDs, strings.Join(conditions, ",")), values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		results = append(results, id)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+var sqlAddUserDenials = "INSERT IGNORE INTO user_denials (tenant_id, user_id, key_id, created_at) VALUES %s;"
+
+func (st *UserStorage) AddDenials(userID int64, keyIDs []int64) error {
+	updating := make([]string, 0, len(keyIDs))
+	for _, id := range keyIDs {
+		updating = append(updating, fmt.Sprintf("(%d, %d, %d, :created_at)", st.tenant, userID, id))
+	}
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = addDenialEvents(tx, sqlGetNewUserDenialNames, st.tenant, webhook.UserDenialAdded, userID, keyIDs, "username")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NamedExec(fmt.Sprintf(sqlAddUserDenials, strings.Join(updating, ", ")),
+		map[string]interface{}{"created_at": time.Now()})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+var sqlRemoveUserDenials = "DELETE FROM user_denials WHERE tenant_id = ? AND user_id = ? AND key_id IN (%s);"
+
+func (st *UserStorage) RemoveDenials(userID int64, keyIDs []int64) error {
+	conditions := make([]string, 0, len(keyIDs))
+	values := make([]interface{}, 0, len(keyIDs)+2)
+	values = append(values, st.tenant, userID)
+	for _, id := range keyIDs {
+		conditions = append(conditions, "?")
+		values = append(values, id)
+	}
+
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = addDenialEvents(tx, sqlGetUserDenialNames, st.tenant, webhook.UserDenialRemoved, userID, keyIDs, "username")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(sqlRemoveUserDenials, strings.Join(conditions, ",")), values...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// denials of the user itself come with an empty bunch
+var sqlGetDenialsByUsername = "SELECT `keys`.`key`, '' FROM user_denials " +
+	"INNER JOIN `users` ON `users`.id = user_denials.user_id " +
+	"INNER JOIN `keys` ON `keys`.id = user_denials.key_id " +
+	"WHERE `users`.tenant_id = ? AND `users`.username = ? " +
+	"UNION ALL " +
+	"SELECT `keys`.`key`, bunches.`name` FROM `users` " +
+	"INNER JOIN user_bunches ON user_bunches.user_id = `users`.id " +
+	"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id " +
+	"INNER JOIN bunch_denials ON bunch_denials.bunch_id = bunches.id " +
+	"INNER JOIN `keys` ON `keys`.id = bunch_denials.key_id " +
+	"WHERE `users`.tenant_id = ? AND `users`.username = ?"
+
+func (st *UserStorage) GetDenials(username string) ([]*usrmgr.Denial, error) {
+	rows, err := st.db.Queryx(sqlGetDenialsByUsername, st.tenant, username, st.tenant, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*usrmgr.Denial, 0)
+	for rows.Next() {
+		d := new(usrmgr.Denial)
+		if err := rows.Scan(&d.Key, &d.Bunch); err != nil {
+			return nil, err
+		}
+		results = append(results, d)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+var sqlGetGrantsByUsername = "SELECT bunches.`name`, `keys`.`key`, IFNULL(user_bunches.expression, ''), " +
+	"IFNULL(bunch_keys.expression, '') FROM `users` " +
+	"INNER JOIN user_bunches ON user_bunches.user_id = `users`.id " +
+	"INNER JOIN bunches ON bunches.id = user_bunches.bunch_id " +
+	"INNER JOIN bunch_keys ON bunch_keys.bunch_id = bunches.id " +
+	"INNER JOIN `keys` ON `keys`.id = bunch_keys.key_id " +
+	"WHERE `users`.tenant_id = ? AND `users`.username = ? ORDER BY bunches.`name`, `keys`.`key`"
+
+// GetGrants returns every bunch to key path granting keys to the user, with their combined conditions
+func (st *UserStorage) GetGrants(username string) ([]*usrmgr.Grant, error) {
+	rows, err := st.db.Queryx(sqlGetGrantsByUsername, st.tenant, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*usrmgr.Grant, 0)
+	for rows.Next() {
+		var bunchCondition, keyCondition string
+		g := new(usrmgr.Grant)
+		if err := rows.Scan(&g.Bunch, &g.Key, &bunchCondition, &keyCondition); err != nil {
+			return nil, err
+		}
+		g.Condition = condition.All(bunchCondition, keyCondition)
+		results = append(results, g)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return results, nil
+}
+
+// scanUser reads a row selected with sqlUserColumns
+func scanUser(rows *sqlx.Rows) (*usrmgr.User, error) {
+	u := new(usrmgr.User)
+	err := rows.Scan(&u.ID, &u.TenantID, &u.Username, &u.Email, &u.Hash, &u.Active, &u.Type, &u.Owner, &u.Desc,
+		&u.CreatedAt, &u.UpdatedAt, &u.Locale)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
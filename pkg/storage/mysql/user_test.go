@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"github.com/stretchr/testify/require"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
 	"testing"
 )
 
@@ -20,6 +21,19 @@ func TestUserStorage_AddUser(t *testing.T) {
 		require.Nil(t, err)
 		require.NotZero(t, id)
 	})
+
+	t.Run("success_add_users_without_email", func(t *testing.T) {
+		t.Parallel()
+
+		for i := 0; i < 2; i++ {
+			id, err := test.ust.AddUser(test.mig.createUniqueString("username"), "", "hash")
+			require.Nil(t, err)
+
+			user, err := test.ust.GetUser(id)
+			require.Nil(t, err)
+			require.Empty(t, user.Email)
+		}
+	})
 }
 
 func TestUserStorage_ModifyUser(t *testing.T) {
@@ -32,7 +46,8 @@ func TestUserStorage_ModifyUser(t *testing.T) {
 		newname := test.mig.createUniqueString("username")
 		newemail := test.mig.createUniqueString("email")
 
-		err := test.ust.ModifyUser(id, newname, newemail, "hash_updated", sql.NullBool{Valid: true})
+		err := test.ust.ModifyUser(id, newname, newemail, "hash_updated", sql.NullBool{Valid: true},
+			sql.NullString{})
 		require.Nil(t, err)
 
 		name, email, hash, active := test.mig.getUserByID(id)
@@ -43,6 +58,35 @@ func TestUserStorage_ModifyUser(t *testing.T) {
 	})
 }
 
+func TestUserStorage_DeleteUser(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_delete_a_user_with_its_grants", func(t *testing.T) {
+		t.Parallel()
+
+		username := test.mig.createUniqueString("username")
+		bunchID := test.mig.createSeedingBunch(nil)
+		id := test.mig.createSeedingUser(func(field map[string]interface{}) { field["username"] = username })
+		require.Nil(t, test.ust.AddBunchesToUser(id, []int64{bunchID}, nil))
+
+		require.Nil(t, test.ust.DeleteUser(id))
+
+		u, err := test.ust.GetUser(id)
+		require.Nil(t, err)
+		require.Nil(t, u)
+
+		bunches, err := test.ust.GetBunches(username)
+		require.Nil(t, err)
+		require.Len(t, bunches, 0)
+	})
+
+	t.Run("fail_user_not_found", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, common.ErrUserNotFound, test.ust.DeleteUser(1<<40))
+	})
+}
+
 func TestUserStorage_GetUser(t *testing.T) {
 	t.Parallel()
 
@@ -115,12 +159,96 @@ func TestUserStorage_QueryUsers(t *testing.T) {
 		})
 
 		users, total, err := test.ust.QueryUsers(2, 2, "user1ame", "",
-			sql.NullBool{Valid: true, Bool: true}, "username", common.Ascending)
+			sql.NullBool{Valid: true, Bool: true}, "", "username", common.Ascending)
 		require.Nil(t, err)
 		require.NotNil(t, users)
 		require.Equal(t, int64(4), total)
 		require.Len(t, users, 2)
 	})
+
+	t.Run("success_query_service_accounts", func(t *testing.T) {
+		t.Parallel()
+
+		owner := test.mig.createSeedingUser(nil)
+		name := test.mig.createUniqueString("svc1ame")
+		test.mig.createSeedingUser(func(field map[string]interface{}) {
+			field["username"] = test.mig.createUniqueString("svc1ame")
+		})
+		_, err := test.ust.AddServiceAccount(name, owner, "", "unusable_hash")
+		require.Nil(t, err)
+
+		users, total, err := test.ust.QueryUsers(10, 0, "svc1ame", "", sql.NullBool{},
+			usrmgr.TypeService, "username", common.Ascending)
+		require.Nil(t, err)
+		require.Equal(t, int64(1), total)
+		require.Equal(t, name, users[0].Username)
+	})
+}
+
+func TestUserStorage_AddServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_add_service_accounts_without_email", func(t *testing.T) {
+		t.Parallel()
+
+		ownerName := test.mig.createUniqueString("username")
+		owner := test.mig.createSeedingUser(func(field map[string]interface{}) {
+			field["username"] = ownerName
+		})
+		name1 := test.mig.createUniqueString("service")
+		name2 := test.mig.createUniqueString("service")
+
+		id, err := test.ust.AddServiceAccount(name1, owner, "ci pipeline", "unusable_hash")
+		require.Nil(t, err)
+		_, err = test.ust.AddServiceAccount(name2, owner, "", "unusable_hash")
+		require.Nil(t, err)
+
+		account, err := test.ust.GetUser(id)
+		require.Nil(t, err)
+		require.Equal(t, usrmgr.TypeService, account.Type)
+		require.Equal(t, ownerName, account.Owner)
+		require.Equal(t, "ci pipeline", account.Desc)
+		require.Empty(t, account.Email)
+		require.Equal(t, "unusable_hash", account.Hash)
+	})
+}
+
+func TestUserStorage_ModifyServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_change_owner_and_desc", func(t *testing.T) {
+		t.Parallel()
+
+		owner := test.mig.createSeedingUser(nil)
+		newOwnerName := test.mig.createUniqueString("username")
+		newOwner := test.mig.createSeedingUser(func(field map[string]interface{}) {
+			field["username"] = newOwnerName
+		})
+		id, err := test.ust.AddServiceAccount(test.mig.createUniqueString("service"), owner, "old",
+			"unusable_hash")
+		require.Nil(t, err)
+
+		err = test.ust.ModifyServiceAccount(id, newOwner, "new")
+		require.Nil(t, err)
+
+		account, err := test.ust.GetUser(id)
+		require.Nil(t, err)
+		require.Equal(t, newOwnerName, account.Owner)
+		require.Equal(t, "new", account.Desc)
+	})
+
+	t.Run("success_human_users_are_left_untouched", func(t *testing.T) {
+		t.Parallel()
+
+		id := test.mig.createSeedingUser(nil)
+
+		err := test.ust.ModifyServiceAccount(id, 0, "changed")
+		require.Nil(t, err)
+
+		user, err := test.ust.GetUser(id)
+		require.Nil(t, err)
+		require.Empty(t, user.Desc)
+	})
 }
 
 func TestUserStorage_GetBunches(t *testing.T) {
@@ -138,7 +266,7 @@ func TestUserStorage_GetBunches(t *testing.T) {
 
 		userID := test.mig.createSeedingUser(func(field map[string]interface{}) { field["username"] = username })
 
-		err := test.ust.AddBunchesToUser(userID, []int64{id1, id2, id3, id4})
+		err := test.ust.AddBunchesToUser(userID, []int64{id1, id2, id3, id4}, nil)
 		require.Nil(t, err)
 
 		bunches, err := test.ust.GetBunches(username)
@@ -161,8 +289,39 @@ func TestUserStorage_AddBunchesToUser(t *testing.T) {
 
 		userID := test.mig.createSeedingUser(nil)
 
-		err := test.ust.AddBunchesToUser(userID, []int64{id1, id2, id3, id4})
+		err := test.ust.AddBunchesToUser(userID, []int64{id1, id2, id3, id4}, nil)
+		require.Nil(t, err)
+	})
+
+	t.Run("success_check_held_bunches_before_adding", func(t *testing.T) {
+		t.Parallel()
+
+		username := test.mig.createUniqueString("username")
+		id1 := test.mig.createSeedingBunch(nil)
+		id2 := test.mig.createSeedingBunch(nil)
+		userID := test.mig.createSeedingUser(func(field map[string]interface{}) { field["username"] = username })
+		require.Nil(t, test.ust.AddBunchesToUser(userID, []int64{id1}, nil))
+
+		conflict := &common.ExclusionError{Exclusion: "exclusion", Bunches: []string{"a", "b"}}
+		err := test.ust.AddBunchesToUser(userID, []int64{id2}, func(held []int64) error {
+			require.Equal(t, []int64{id1}, held)
+			return conflict
+		})
+		require.Equal(t, conflict, err)
+
+		bunches, err := test.ust.GetBunches(username)
 		require.Nil(t, err)
+		require.Len(t, bunches, 1)
+		require.Equal(t, id1, bunches[0].ID)
+	})
+
+	t.Run("fail_check_unknown_user", func(t *testing.T) {
+		t.Parallel()
+
+		err := test.ust.AddBunchesToUser(0, []int64{test.mig.createSeedingBunch(nil)}, func([]int64) error {
+			return nil
+		})
+		require.Equal(t, common.ErrUserNotFound, err)
 	})
 }
 
@@ -216,7 +375,7 @@ func TestUserStorage_GetKeys(t *testing.T) {
 		err = test.bst.AddKeysToBunch(bID2, []int64{kID3, kID4})
 		require.Nil(t, err)
 
-		err = test.ust.AddBunchesToUser(uID, []int64{bID1, bID2})
+		err = test.ust.AddBunchesToUser(uID, []int64{bID1, bID2}, nil)
 		require.Nil(t, err)
 
 		keys, err := test.ust.GetKeys(username)
@@ -225,3 +384,194 @@ func TestUserStorage_GetKeys(t *testing.T) {
 		require.Len(t, keys, 4)
 	})
 }
+
+func TestUserStorage_SetBunchCondition(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_get_keys_with_conditions", func(t *testing.T) {
+		t.Parallel()
+
+		username := test.mig.createUniqueString("username")
+		kID1 := test.mig.createSeedingServiceKey(nil)
+		kID2 := test.mig.createSeedingServiceKey(nil)
+		bID1 := test.mig.createSeedingBunch(nil)
+		bID2 := test.mig.createSeedingBunch(nil)
+		uID := test.mig.createSeedingUser(func(field map[string]interface{}) {
+			field["username"] = username
+		})
+
+		require.Nil(t, test.bst.AddKeysToBunch(bID1, []int64{kID1, kID2}))
+		require.Nil(t, test.bst.AddKeysToBunch(bID2, []int64{kID2}))
+		require.Nil(t, test.ust.AddBunchesToUser(uID, []int64{bID1, bID2}, nil))
+
+		err := test.ust.SetBunchCondition(uID, bID1, sql.NullString{String: "hour > 9", Valid: true})
+		require.Nil(t, err)
+		err = test.bst.SetKeyCondition(bID1, kID1, sql.NullString{String: `ip == "10.0.0.1"`, Valid: true})
+		require.Nil(t, err)
+
+		keys, err := test.ust.GetKeys(username)
+		require.Nil(t, err)
+		require.Len(t, keys, 2)
+
+		// the second key is also granted without condition through the second bunch
+		for _, k := range keys {
+			if k.ID == kID1 {
+				require.Equal(t, []string{`(hour > 9) && (ip == "10.0.0.1")`}, k.Conditions)
+			} else {
+				require.False(t, k.IsConditional())
+			}
+		}
+
+		bunches, err := test.ust.GetBunches(username)
+		require.Nil(t, err)
+		for _, b := range bunches {
+			if b.ID == bID1 {
+				require.Equal(t, "hour > 9", b.Condition)
+			}
+		}
+	})
+}
+
+func TestUserStorage_SetAttributes(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_replace_attributes", func(t *testing.T) {
+		t.Parallel()
+
+		uID := test.mig.createSeedingUser(nil)
+
+		err := test.ust.SetAttributes(uID, map[string]string{"department": "sales", "level": "2"})
+		require.Nil(t, err)
+
+		err = test.ust.SetAttributes(uID, map[string]string{"department": "finance"})
+		require.Nil(t, err)
+
+		attrs, err := test.ust.GetAttributes(uID)
+		require.Nil(t, err)
+		require.Equal(t, map[string]string{"department": "finance"}, attrs)
+	})
+}
+
+func TestUserStorage_ModifyUserLocale(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_set_locale", func(t *testing.T) {
+		t.Parallel()
+
+		uID := test.mig.createSeedingUser(nil)
+
+		err := test.ust.ModifyUser(uID, "", "", "", sql.NullBool{}, sql.NullString{String: "vi", Valid: true})
+		require.Nil(t, err)
+
+		u, err := test.ust.GetUser(uID)
+		require.Nil(t, err)
+		require.Equal(t, "vi", u.Locale)
+	})
+}
+
+func TestUserStorage_GetDenials(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_get_denials_of_user_and_bunches", func(t *testing.T) {
+		t.Parallel()
+
+		username := test.mig.createUniqueString("username")
+		bunch := test.mig.createUniqueString("bunch")
+		key1 := test.mig.createUniqueString("key")
+		key2 := test.mig.createUniqueString("key")
+		kID1 := test.mig.createSeedingServiceKey(func(fields map[string]interface{}) { fields["key"] = key1 })
+		kID2 := test.mig.createSeedingServiceKey(func(fields map[string]interface{}) { fields["key"] = key2 })
+		bID := test.mig.createSeedingBunch(func(fields map[string]interface{}) { fields["name"] = bunch })
+		uID := test.mig.createSeedingUser(func(field map[string]interface{}) {
+			field["username"] = username
+		})
+
+		require.Nil(t, test.ust.AddBunchesToUser(uID, []int64{bID}, nil))
+		require.Nil(t, test.bst.AddKeysToBunch(bID, []int64{kID1}))
+		require.Nil(t, test.bst.AddDenials(bID, []int64{kID2}))
+		require.Nil(t, test.ust.AddDenials(uID, []int64{kID1}))
+
+		denials, err := test.ust.GetDenials(username)
+		require.Nil(t, err)
+		require.ElementsMatch(t, []*usrmgr.Denial{{Key: key1}, {Key: key2, Bunch: bunch}}, denials)
+
+		grants, err := test.ust.GetGrants(username)
+		require.Nil(t, err)
+		require.Equal(t, []*usrmgr.Grant{{Bunch: bunch, Key: key1}}, grants)
+
+		require.Nil(t, test.ust.RemoveDenials(uID, []int64{kID1}))
+		require.Nil(t, test.bst.RemoveDenials(bID, []int64{kID2}))
+
+		denials, err = test.ust.GetDenials(username)
+		require.Nil(t, err)
+		require.Len(t, denials, 0)
+	})
+}
+
+func TestUserStorage_RemoveBunchesFromUser(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_remove_bunches_from_user", func(t *testing.T) {
+		t.Parallel()
+
+		username := test.mig.createUniqueString("username")
+		id1 := test.mig.createSeedingBunch(nil)
+		id2 := test.mig.createSeedingBunch(nil)
+		id3 := test.mig.createSeedingBunch(nil)
+		userID := test.mig.createSeedingUser(func(field map[string]interface{}) { field["username"] = username })
+
+		err := test.ust.AddBunchesToUser(userID, []int64{id1, id2, id3}, nil)
+		require.Nil(t, err)
+
+		err = test.ust.RemoveBunchesFromUser(userID, []int64{id1, id3})
+		require.Nil(t, err)
+
+		bunches, err := test.ust.GetBunches(username)
+		require.Nil(t, err)
+		require.Len(t, bunches, 1)
+		require.Equal(t, id2, bunches[0].ID)
+	})
+}
+
+func TestUserStorage_ImportUsers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_import_users_with_bunches", func(t *testing.T) {
+		t.Parallel()
+
+		name1 := test.mig.createUniqueString("username")
+		name2 := test.mig.createUniqueString("username")
+		bID := test.mig.createSeedingBunch(nil)
+
+		err := test.ust.ImportUsers([]*usrmgr.ImportRow{
+			{Username: name1, Email: name1 + "@test.com", Hash: "hash", BunchIDs: []int64{bID}},
+			{Username: name2, Email: name2 + "@test.com", Hash: "hash"},
+		})
+		require.Nil(t, err)
+
+		bunches, err := test.ust.GetBunches(name1)
+		require.Nil(t, err)
+		require.Len(t, bunches, 1)
+
+		user, err := test.ust.GetUserByUsername(name2)
+		require.Nil(t, err)
+		require.NotNil(t, user)
+	})
+
+	t.Run("fail_rollback_batch", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("username")
+		email := test.mig.createUniqueString("email")
+
+		err := test.ust.ImportUsers([]*usrmgr.ImportRow{
+			{Username: name, Email: email, Hash: "hash"},
+			{Username: name, Email: email, Hash: "hash"},
+		})
+		require.NotNil(t, err)
+
+		user, err := test.ust.GetUserByUsername(name)
+		require.Nil(t, err)
+		require.Nil(t, user)
+	})
+}
@@ -0,0 +1,496 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookStorage implements db's storage for webhook subscriptions and their deliveries. It's also the relay
+// which the dispatcher works on, relaying works across tenants.
+type WebhookStorage struct {
+	db     *sqlx.DB
+	tenant int64
+}
+
+// NewWebhookStorage create new instance of WebhookStorage, working on webhooks of the default tenant
+func NewWebhookStorage(db *sqlx.DB) *WebhookStorage {
+	return &WebhookStorage{
+		db,
+		common.DefaultTenant,
+	}
+}
+
+// WithTenant returns a storage working on webhooks of the tenant
+func (st *WebhookStorage) WithTenant(tenantID int64) webhook.Storer {
+	return &WebhookStorage{st.db, tenantID}
+}
+
+var sqlAddSubscription = "INSERT INTO webhook_subscriptions (tenant_id, `name`, url, secret, events, created_at, " +
+	"updated_at) VALUES (?, ?, ?, ?, ?, ?, ?);"
+
+func (st *WebhookStorage) AddSubscription(name string, url string, secret string, events []string) (int64, error) {
+	now := time.Now()
+	res, err := st.db.Exec(sqlAddSubscription, st.tenant, name, url, secret, strings.Join(events, ","), now, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+var sqlSelectSubscriptions = "SELECT id, tenant_id, `name`, url, secret, events, active, created_at, updated_at " +
+	"FROM webhook_subscriptions "
+var sqlGetSubscription = sqlSelectSubscriptions + "WHERE tenant_id = ? AND `name` = ? LIMIT 1;"
+
+func (st *WebhookStorage) GetSubscription(name string) (*webhook.Subscription, error) {
+	lst, err := querySubscriptions(st.db, sqlGetSubscription, st.tenant, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	return lst[0], nil
+}
+
+var sqlGetSubscriptions = sqlSelectSubscriptions + "WHERE tenant_id = ? ORDER BY `name`;"
+
+func (st *WebhookStorage) GetSubscriptions() ([]*webhook.Subscription, error) {
+	return querySubscriptions(st.db, sqlGetSubscriptions, st.tenant)
+}
+
+var sqlUpdateSubscription = "UPDATE webhook_subscriptions SET %s WHERE id = :id AND tenant_id = :tenant_id;"
+
+func (st *WebhookStorage) ModifySubscription(id int64, url string, events []string, active sql.NullBool) error {
+	updating := make(map[string]interface{})
+	var condition string
+	var prefix string
+
+	if len(url) > 0 {
+		updating["url"] = url
+		condition += prefix + "url = :url"
+		prefix = ", "
+	}
+
+	if events != nil {
+		updating["events"] = strings.Join(events, ",")
+		condition += prefix + "events = :events"
+		prefix = ", "
+	}
+
+	if active.Valid {
+		updating["active"] = active.Bool
+		condition += prefix + "active = :active"
+		prefix = ", "
+	}
+
+	if len(updating) > 0 {
+		updating["id"] = id
+		updating["tenant_id"] = st.tenant
+		updating["updated_at"] = time.Now()
+		condition += prefix + "updated_at = :updated_at"
+
+		_, err := st.db.NamedExec(fmt.Sprintf(sqlUpdateSubscription, condition), updating)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var sqlRemoveSubscription = "DELETE FROM webhook_subscriptions WHERE id = ? AND tenant_id = ?;"
+
+func (st *WebhookStorage) RemoveSubscription(id int64) error {
+	_, err := st.db.Exec(sqlRemoveSubscription, id, st.tenant)
+	return err
+}
+
+var sqlSelectDeliveries = "SELECT webhook_deliveries.id, webhook_deliveries.tenant_id, " +
+	"webhook_deliveries.subscription_id, webhook_deliveries.event_id, outbox_events.`type`, " +
+	"outbox_events.created_at, outbox_events.payload, webhook_subscriptions.url, webhook_subscriptions.secret, " +
+	"webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at, " +
+	"webhook_deliveries.last_status_code, webhook_deliveries.last_error, webhook_deliveries.created_at, " +
+	"webhook_deliveries.updated_at " +
+	"FROM webhook_deliveries " +
+	"INNER JOIN outbox_events ON outbox_events.id = webhook_deliveries.event_id " +
+	"INNER JOIN webhook_subscriptions ON webhook_subscriptions.id = webhook_deliveries.subscription_id "
+var sqlQueryDeliveries = sqlSelectDeliveries + "%s ORDER BY webhook_deliveries.id DESC LIMIT :offset, :limit;"
+var sqlQueryDeliveriesCounter = "SELECT count(id) FROM webhook_deliveries %s;"
+
+func (st *WebhookStorage) QueryDeliveries(subscriptionID int64, status string, take int64,
+	skip int64) ([]*webhook.Delivery, int64, error) {
+
+	var (
+		where         string
+		filter        map[string]interface{}
+		wg            sync.WaitGroup
+		queryErr      error
+		countTotalErr error
+		results       []*webhook.Delivery
+		total         int64
+	)
+
+	filter = map[string]interface{}{
+		"tenant_id":       st.tenant,
+		"subscription_id": subscriptionID,
+		"offset":          skip,
+		"limit":           take,
+	}
+
+	where = "WHERE webhook_deliveries.tenant_id = :tenant_id AND webhook_deliveries.subscription_id = :subscription_id"
+	if len(status) > 0 {
+		where += " AND webhook_deliveries.status = :status"
+		filter["status"] = status
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rows, err := st.db.NamedQuery(fmt.Sprintf(sqlQueryDeliveries, where), filter)
+		if err != nil {
+			queryErr = err
+			return
+		}
+		defer rows.Close()
+
+		results, queryErr = scanDeliveries(rows)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		rows, err := st.db.NamedQuery(fmt.Sprintf(sqlQueryDeliveriesCounter, where), filter)
+		if err != nil {
+			countTotalErr = err
+			return
+		}
+		defer rows.Close()
+
+		if rows.Next() {
+			err := rows.Scan(&total)
+			if err != nil {
+				countTotalErr = err
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	if queryErr != nil {
+		return nil, 0, queryErr
+	}
+	if countTotalErr != nil {
+		return nil, 0, countTotalErr
+	}
+
+	return results, total, nil
+}
+
+var sqlGetDelivery = sqlSelectDeliveries + "WHERE webhook_deliveries.tenant_id = ? AND " +
+	"webhook_deliveries.subscription_id = ? AND webhook_deliveries.id = ? LIMIT 1;"
+
+func (st *WebhookStorage) GetDelivery(subscriptionID int64, id int64) (*webhook.Delivery, error) {
+	rows, err := st.db.Queryx(sqlGetDelivery, st.tenant, subscriptionID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lst, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	return lst[0], nil
+}
+
+var sqlGetAttempts = "SELECT id, delivery_id, status_code, error, duration_ms, created_at FROM webhook_attempts " +
+	"WHERE delivery_id = ? ORDER BY id;"
+
+func (st *WebhookStorage) GetAttempts(deliveryID int64) ([]*webhook.Attempt, error) {
+	rows, err := st.db.Queryx(sqlGetAttempts, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := make([]*webhook.Attempt, 0)
+	for rows.Next() {
+		a := new(webhook.Attempt)
+		var ms int64
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.StatusCode, &a.Error, &ms, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Duration = time.Duration(ms) * time.Millisecond
+		attempts = append(attempts, a)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return attempts, nil
+}
+
+var sqlRequeueDelivery = "UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?, " +
+	"updated_at = ? WHERE id = ? AND tenant_id = ?;"
+
+func (st *WebhookStorage) RequeueDelivery(id int64, at time.Time) error {
+	_, err := st.db.Exec(sqlRequeueDelivery, webhook.Pending, at, at, id, st.tenant)
+	return err
+}
+
+var sqlGetEvents = "SELECT id, tenant_id, seq, `type`, payload, created_at FROM outbox_events " +
+	"WHERE tenant_id = ? AND seq > ? AND `type` IN (%s) ORDER BY seq LIMIT ?;"
+
+// GetEvents returns events of the types which follow the sequence number afterSeq, in the order of commit
+func (st *WebhookStorage) GetEvents(afterSeq int64, types []string, take int64) ([]*webhook.Event, error) {
+	conditions := make([]string, 0, len(types))
+	values := make([]interface{}, 0, len(types)+3)
+	values = append(values, st.tenant, afterSeq)
+	for _, typ := range types {
+		conditions = append(conditions, "?")
+		values = append(values, typ)
+	}
+	values = append(values, take)
+
+	rows, err := st.db.Queryx(fmt.Sprintf(sqlGetEvents, strings.Join(conditions, ",")), values...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*webhook.Event, 0)
+	for rows.Next() {
+		e := new(webhook.Event)
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.Seq, &e.Type, &e.Payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return events, nil
+}
+
+var sqlGetLastSeq = "SELECT IFNULL(MAX(seq), 0) FROM outbox_events WHERE tenant_id = ?;"
+
+func (st *WebhookStorage) GetLastSeq() (int64, error) {
+	var seq int64
+	if err := st.db.QueryRowx(sqlGetLastSeq, st.tenant).Scan(&seq); err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+var (
+	sqlGetUnrelayedEvents = "SELECT id, tenant_id, `type` FROM outbox_events WHERE relayed_at IS NULL " +
+		"ORDER BY id LIMIT ? FOR UPDATE;"
+	sqlGetActiveSubscriptions = sqlSelectSubscriptions + "WHERE tenant_id = ? AND active = 1;"
+	sqlAddDelivery            = "INSERT INTO webhook_deliveries (tenant_id, subscription_id, event_id, status, " +
+		"next_attempt_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?);"
+	sqlMarkEventsRelayed = "UPDATE outbox_events SET relayed_at = ? WHERE id IN (%s);"
+)
+
+// RelayEvents turns the oldest events of the outbox into deliveries, events are locked until they're relayed
+// so that no two dispatchers relay the same event
+func (st *WebhookStorage) RelayEvents(take int, now time.Time) (int, error) {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Queryx(sqlGetUnrelayedEvents, take)
+	if err != nil {
+		return 0, err
+	}
+
+	events := make([]*webhook.Event, 0, take)
+	for rows.Next() {
+		e := new(webhook.Event)
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.Type); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return 0, rows.Err()
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	subscriptions := make(map[int64][]*webhook.Subscription)
+	conditions := make([]string, 0, len(events))
+	values := make([]interface{}, 0, len(events)+1)
+	values = append(values, now)
+	for _, e := range events {
+		subs, ok := subscriptions[e.TenantID]
+		if !ok {
+			subs, err = querySubscriptions(tx, sqlGetActiveSubscriptions, e.TenantID)
+			if err != nil {
+				return 0, err
+			}
+			subscriptions[e.TenantID] = subs
+		}
+
+		for _, sub := range subs {
+			if !sub.Wants(e.Type) {
+				continue
+			}
+			if _, err := tx.Exec(sqlAddDelivery, e.TenantID, sub.ID, e.ID, webhook.Pending, now, now, now); err != nil {
+				return 0, err
+			}
+		}
+
+		conditions = append(conditions, "?")
+		values = append(values, e.ID)
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(sqlMarkEventsRelayed, strings.Join(conditions, ",")), values...); err != nil {
+		return 0, err
+	}
+
+	return len(events), tx.Commit()
+}
+
+var (
+	sqlGetDueDeliveries = sqlSelectDeliveries + "WHERE webhook_deliveries.status = ? AND " +
+		"webhook_deliveries.next_attempt_at <= ? ORDER BY webhook_deliveries.next_attempt_at LIMIT ? FOR UPDATE;"
+	sqlLeaseDeliveries = "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id IN (%s);"
+)
+
+// ClaimDeliveries returns due deliveries of every tenant, their next attempt is put off to leaseUntil so that
+// other dispatchers leave them alone while they're being sent
+func (st *WebhookStorage) ClaimDeliveries(take int, now time.Time, leaseUntil time.Time) ([]*webhook.Delivery, error) {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Queryx(sqlGetDueDeliveries, webhook.Pending, now, take)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := scanDeliveries(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deliveries) == 0 {
+		return deliveries, nil
+	}
+
+	conditions := make([]string, 0, len(deliveries))
+	values := make([]interface{}, 0, len(deliveries)+1)
+	values = append(values, leaseUntil)
+	for _, d := range deliveries {
+		conditions = append(conditions, "?")
+		values = append(values, d.ID)
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(sqlLeaseDeliveries, strings.Join(conditions, ",")), values...); err != nil {
+		return nil, err
+	}
+
+	return deliveries, tx.Commit()
+}
+
+var (
+	sqlAddAttempt = "INSERT INTO webhook_attempts (delivery_id, status_code, error, duration_ms, created_at) " +
+		"VALUES (?, ?, ?, ?, ?);"
+	sqlUpdateDelivery = "UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, " +
+		"last_status_code = ?, last_error = ?, updated_at = ? WHERE id = ?;"
+)
+
+func (st *WebhookStorage) AddAttempt(a *webhook.Attempt, d *webhook.Delivery) error {
+	tx, err := st.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(sqlAddAttempt, a.DeliveryID, a.StatusCode, a.Error, a.Duration.Milliseconds(), a.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(sqlUpdateDelivery, d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError,
+		time.Now(), d.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func querySubscriptions(q sqlx.Queryer, query string, args ...interface{}) ([]*webhook.Subscription, error) {
+	rows, err := q.Queryx(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]*webhook.Subscription, 0)
+	for rows.Next() {
+		s := new(webhook.Subscription)
+		var events string
+		err := rows.Scan(&s.ID, &s.TenantID, &s.Name, &s.URL, &s.Secret, &events, &s.Active, &s.CreatedAt,
+			&s.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		s.Events = strings.Split(events, ",")
+		subscriptions = append(subscriptions, s)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return subscriptions, nil
+}
+
+func scanDeliveries(rows *sqlx.Rows) ([]*webhook.Delivery, error) {
+	deliveries := make([]*webhook.Delivery, 0)
+	for rows.Next() {
+		d := new(webhook.Delivery)
+		err := rows.Scan(&d.ID, &d.TenantID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.EventCreatedAt,
+			&d.Payload, &d.URL, &d.Secret, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode,
+			&d.LastError, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,134 @@
+package mysql
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookStorage_RelayEvents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_relay_claim_and_log_attempt", func(t *testing.T) {
+		t.Parallel()
+
+		name := test.mig.createUniqueString("hook")
+		subID, err := test.whst.AddSubscription(name, "https://example.com/hooks", "secret",
+			[]string{webhook.UserCreated})
+		require.Nil(t, err)
+
+		username := test.mig.createUniqueString("hooked")
+		_, err = test.ust.AddUser(username, username+"@test.com", "hash")
+		require.Nil(t, err)
+
+		for {
+			n, err := test.whst.RelayEvents(100, time.Now())
+			require.Nil(t, err)
+			if n < 100 {
+				break
+			}
+		}
+
+		lst, total, err := test.whst.QueryDeliveries(subID, webhook.Pending, 100, 0)
+		require.Nil(t, err)
+		require.Equal(t, int64(len(lst)), total)
+
+		var delivery *webhook.Delivery
+		for _, d := range lst {
+			if strings.Contains(d.Payload, username) {
+				delivery = d
+			}
+		}
+		require.NotNil(t, delivery)
+		require.Equal(t, webhook.UserCreated, delivery.EventType)
+		require.Equal(t, "secret", delivery.Secret)
+
+		now := time.Now().Add(time.Second)
+		claimed, err := test.whst.ClaimDeliveries(1000, now, now.Add(time.Minute))
+		require.Nil(t, err)
+
+		var found bool
+		for _, d := range claimed {
+			found = found || d.ID == delivery.ID
+		}
+		require.True(t, found)
+
+		// claimed deliveries aren't due until their lease runs out
+		claimed, err = test.whst.ClaimDeliveries(1000, now, now.Add(time.Minute))
+		require.Nil(t, err)
+		for _, d := range claimed {
+			require.NotEqual(t, delivery.ID, d.ID)
+		}
+
+		delivery.Status = webhook.Dead
+		delivery.Attempts = webhook.MaxAttempts
+		delivery.NextAttemptAt.Valid = false
+		delivery.LastStatusCode = 500
+		err = test.whst.AddAttempt(&webhook.Attempt{DeliveryID: delivery.ID, StatusCode: 500,
+			Error: "receiver responded 500", Duration: 20 * time.Millisecond, CreatedAt: time.Now()}, delivery)
+		require.Nil(t, err)
+
+		dead, total, err := test.whst.QueryDeliveries(subID, webhook.Dead, 10, 0)
+		require.Nil(t, err)
+		require.Equal(t, int64(1), total)
+		require.Equal(t, delivery.ID, dead[0].ID)
+
+		attempts, err := test.whst.GetAttempts(delivery.ID)
+		require.Nil(t, err)
+		require.Len(t, attempts, 1)
+		require.Equal(t, 20*time.Millisecond, attempts[0].Duration)
+
+		require.Nil(t, test.whst.RequeueDelivery(delivery.ID, time.Now()))
+
+		got, err := test.whst.GetDelivery(subID, delivery.ID)
+		require.Nil(t, err)
+		require.Equal(t, webhook.Pending, got.Status)
+		require.Equal(t, 0, got.Attempts)
+	})
+}
+
+func TestWebhookStorage_GetEvents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success_get_grant_events", func(t *testing.T) {
+		t.Parallel()
+
+		lastSeq, err := test.whst.GetLastSeq()
+		require.Nil(t, err)
+
+		username := test.mig.createUniqueString("streamed")
+		userID, err := test.ust.AddUser(username, username+"@test.com", "hash")
+		require.Nil(t, err)
+
+		bunch := test.mig.createUniqueString("streamed_bunch")
+		bunchID, err := test.bst.AddBunch(bunch, "")
+		require.Nil(t, err)
+
+		require.Nil(t, test.ust.AddBunchesToUser(userID, []int64{bunchID}, nil))
+		require.Nil(t, test.ust.RemoveBunchesFromUser(userID, []int64{bunchID}))
+
+		events, err := test.whst.GetEvents(lastSeq, webhook.PermissionEvents, 1000)
+		require.Nil(t, err)
+
+		types := make([]string, 0)
+		for i, e := range events {
+			require.True(t, e.Seq > lastSeq)
+			if i > 0 {
+				require.True(t, e.Seq > events[i-1].Seq)
+			}
+			if strings.Contains(e.Payload, username) {
+				require.Contains(t, e.Payload, bunch)
+				types = append(types, e.Type)
+			}
+		}
+		require.Equal(t, []string{webhook.GrantAdded, webhook.GrantRevoked}, types)
+
+		// other tenants' events aren't read
+		events, err = test.whst.WithTenant(common.DefaultTenant+1000).GetEvents(lastSeq, webhook.PermissionEvents, 1000)
+		require.Nil(t, err)
+		require.Len(t, events, 0)
+	})
+}
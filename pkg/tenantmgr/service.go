@@ -0,0 +1,143 @@
+package tenantmgr
+
+import (
+	"database/sql"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"regexp"
+	"strings"
+)
+
+type Storer interface {
+	AddTenant(name string, desc string, excludedKeys []string, admin *Admin) (int64, error)
+	GetTenant(id int64) (*Tenant, error)
+	GetTenantByName(name string) (*Tenant, error)
+	ModifyTenant(id int64, name string, desc string, active sql.NullBool) error
+	QueryTenants(take int64, skip int64, name string, sortby string,
+		direction common.SortingDirection) ([]*Tenant, int64, error)
+}
+
+type Service interface {
+	AddTenant(name string, desc string, admin *Admin) (int64, error)
+	GetTenant(id int64) (*Tenant, error)
+	GetTenantByName(name string) (*Tenant, error)
+	ModifyTenant(lookup string, name string, desc string, active sql.NullBool) error
+	QueryTenants(page int64, perPage int64, name string, order string) ([]*Tenant, int64, error)
+}
+
+type service struct {
+	st Storer
+}
+
+func NewService(st Storer) Service {
+	return &service{st}
+}
+
+// AddTenant creates the tenant with a copy of the default tenant's keys, root keys excluded,
+// and an admin bunch holding all of them. The admin, when given, is created with the tenant and holds its
+// admin bunch.
+func (s *service) AddTenant(name string, desc string, admin *Admin) (int64, error) {
+	if !s.isValidName(name) {
+		return 0, common.ErrTenantNameInvalid
+	}
+
+	if admin != nil {
+		if err := usrmgr.ValidateUser(admin.Username, admin.Email); err != nil {
+			return 0, err
+		}
+		if len(admin.Hash) == 0 {
+			return 0, common.ErrMissingHash
+		}
+	}
+
+	existing, err := s.st.GetTenantByName(name)
+	if err != nil {
+		return 0, err
+	}
+	if existing != nil {
+		return 0, common.ErrDuplicatedTenant
+	}
+
+	return s.st.AddTenant(name, desc, RootKeys, admin)
+}
+
+func (s *service) GetTenant(id int64) (*Tenant, error) {
+	return s.st.GetTenant(id)
+}
+
+func (s *service) GetTenantByName(name string) (*Tenant, error) {
+	return s.st.GetTenantByName(name)
+}
+
+func (s *service) ModifyTenant(lookup string, name string, desc string, active sql.NullBool) error {
+	updating, err := s.st.GetTenantByName(lookup)
+	if err != nil {
+		return err
+	}
+	if updating == nil {
+		return common.ErrTenantNotFound
+	}
+
+	// default tenant's admins manage every other tenant, so it can't be turned off
+	if updating.ID == common.DefaultTenant && active.Valid && !active.Bool {
+		return common.ErrNotAllowed
+	}
+
+	if len(name) == 0 {
+		name = updating.Name
+	} else if !s.isValidName(name) {
+		return common.ErrTenantNameInvalid
+	}
+
+	if len(desc) == 0 {
+		desc = updating.Desc
+	}
+
+	if updating.Name != name {
+		dup, err := s.st.GetTenantByName(name)
+		if err != nil {
+			return err
+		}
+		if dup != nil {
+			return common.ErrDuplicatedTenant
+		}
+	}
+
+	return s.st.ModifyTenant(updating.ID, name, desc, active)
+}
+
+func (s *service) QueryTenants(page int64, perPage int64, name string, order string) ([]*Tenant, int64, error) {
+	var (
+		sortby    string
+		direction common.SortingDirection
+		take      int64 = perPage
+		skip      int64 = perPage * (page - 1)
+	)
+
+	if len(order) > 0 {
+		switch order[0] {
+		case '+':
+			direction = common.Ascending
+			sortby = strings.TrimSpace(order[1:])
+			break
+		case '-':
+			direction = common.Descending
+			sortby = strings.TrimSpace(order[1:])
+			break
+		default:
+			direction = common.Descending
+			sortby = strings.TrimSpace(order)
+			break
+		}
+	} else {
+		sortby = "created_at"
+		direction = common.Descending
+	}
+
+	return s.st.QueryTenants(take, skip, name, sortby, direction)
+}
+
+func (s *service) isValidName(name string) bool {
+	matched, err := regexp.Match(`^[a-z0-9_-]{1,32}$`, []byte(name))
+	return err == nil && matched
+}
@@ -0,0 +1,27 @@
+package tenantmgr
+
+import (
+	"time"
+)
+
+// AdminBunch is created in every new tenant and holds all of its keys
+const AdminBunch = "admin_role"
+
+// RootKeys manage tenants, they stay in the default tenant and aren't copied into new ones
+var RootKeys = []string{"add_tenant", "modify_tenant", "get_tenant", "query_tenant"}
+
+// Admin is the first user of a new tenant, who is given its admin bunch
+type Admin struct {
+	Username string
+	Email    string
+	Hash     string
+}
+
+type Tenant struct {
+	ID        int64
+	Name      string
+	Desc      string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,288 @@
+package tokenmgr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/keymatch"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// last_used_at is written at most once per this interval, so busy tokens don't cause a write per request
+const touchInterval = time.Minute
+
+type Storer interface {
+	AddToken(userID int64, name string, prefix string, hash string, keys []string,
+		expiresAt sql.NullTime) (int64, error)
+	GetToken(userID int64, name string) (*Token, error)
+	GetTokenByHash(hash string) (*Token, error)
+	GetTokens(userID int64) ([]*Token, error)
+	RevokeToken(id int64) error
+	TouchToken(id int64, usedAt time.Time, before time.Time) error
+}
+
+// UserGetter looks up users and their effective keys, it's satisfied by usrmgr.Service
+type UserGetter interface {
+	GetUserByUsername(username string) (*usrmgr.User, error)
+	GetKeys(username string) ([]*usrmgr.Key, error)
+	GetDenials(username string) ([]*usrmgr.Denial, error)
+	WithTenant(tenantID int64) usrmgr.Service
+}
+
+type Service interface {
+	CreateToken(username string, name string, keys []string, expiresAt sql.NullTime) (*Token, string, error)
+	GetTokens(username string) ([]*Token, error)
+	RevokeToken(username string, name string) error
+	Authenticate(plain string) (*Token, error)
+	WithTenant(tenantID int64) Service
+}
+
+type service struct {
+	st    Storer
+	users UserGetter
+}
+
+func NewService(st Storer, users UserGetter) Service {
+	return &service{st, users}
+}
+
+// WithTenant returns the service managing tokens of users of the tenant. Tokens themselves
+// belong to users, so only user lookups are scoped.
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st, s.users.WithTenant(tenantID)}
+}
+
+// CreateToken mints a token scoped to keys, which must be granted by user's effective keys, keys held with
+// conditions are carried along with those conditions. It returns the plain token, which can't be retrieved later.
+func (s *service) CreateToken(username string, name string, keys []string,
+	expiresAt sql.NullTime) (*Token, string, error) {
+
+	invalid := new(common.ValidationError)
+	if !s.isValidName(name) {
+		invalid.Add("name", common.ErrTokenNameInvalid)
+	}
+	if len(keys) == 0 {
+		invalid.Add("keys", common.ErrMissingTokenKeys)
+	}
+	if expiresAt.Valid && !expiresAt.Time.After(time.Now()) {
+		invalid.Add("expires_at", common.ErrTokenExpiryInvalid)
+	}
+	if err := invalid.Err(); err != nil {
+		return nil, "", err
+	}
+
+	user, err := s.getUser(username)
+	if err != nil {
+		return nil, "", err
+	}
+
+	effective, conditional, err := s.effectiveKeys(username)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, k := range keys {
+		if !keymatch.Any(effective, k) && len(conditionsOf(conditional, k)) == 0 {
+			return nil, "", common.ErrNotAllowed
+		}
+	}
+
+	existing, err := s.st.GetToken(user.ID, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if existing != nil {
+		return nil, "", common.ErrDuplicatedToken
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(secret)
+	plain := Prefix + body
+
+	_, err = s.st.AddToken(user.ID, name, body[:8], hash(plain), dedupe(keys), expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.st.GetToken(user.ID, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return token, plain, nil
+}
+
+func (s *service) GetTokens(username string) ([]*Token, error) {
+	user, err := s.getUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.st.GetTokens(user.ID)
+}
+
+func (s *service) RevokeToken(username string, name string) error {
+	user, err := s.getUser(username)
+	if err != nil {
+		return err
+	}
+
+	token, err := s.st.GetToken(user.ID, name)
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return common.ErrTokenNotFound
+	}
+
+	return s.st.RevokeToken(token.ID)
+}
+
+// Authenticate returns the token with its keys narrowed to the ones its owner still holds, keys which the owner
+// holds with conditions are returned with those conditions. Unknown, revoked and expired tokens, and tokens of
+// deactivated users are rejected.
+func (s *service) Authenticate(plain string) (*Token, error) {
+	if !strings.HasPrefix(plain, Prefix) {
+		return nil, common.ErrPersonalTokenInvalid
+	}
+
+	token, err := s.st.GetTokenByHash(hash(plain))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if token == nil || token.Revoked || token.Expired(now) {
+		return nil, common.ErrPersonalTokenInvalid
+	}
+
+	// a token is checked against its owner's tenant, whichever tenant the request was made for
+	s = &service{s.st, s.users.WithTenant(token.TenantID)}
+
+	user, err := s.users.GetUserByUsername(token.Username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || (user.Active.Valid && !user.Active.Bool) {
+		return nil, common.ErrPersonalTokenInvalid
+	}
+
+	effective, conditional, err := s.effectiveKeys(token.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(token.Keys))
+	for _, k := range token.Keys {
+		if keymatch.Any(effective, k) {
+			keys = append(keys, k)
+			continue
+		}
+
+		for pattern, conditions := range conditionsOf(conditional, k) {
+			if token.Conditions == nil {
+				token.Conditions = make(map[string][]string)
+			}
+			token.Conditions[pattern] = append(token.Conditions[pattern], conditions...)
+		}
+	}
+	token.Keys = keys
+
+	// owner's denials go along with the token, they may carve keys out of its wildcard keys
+	denials, err := s.users.GetDenials(token.Username)
+	if err != nil {
+		return nil, err
+	}
+	token.Denied = make([]string, 0, len(denials))
+	for _, d := range denials {
+		token.Denied = append(token.Denied, d.Key)
+	}
+
+	if err := s.st.TouchToken(token.ID, now, now.Add(-touchInterval)); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (s *service) getUser(username string) (*usrmgr.User, error) {
+	user, err := s.users.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// effectiveKeys returns key patterns granted to user without conditions, and the conditions of the ones granted
+// with conditions by their patterns
+func (s *service) effectiveKeys(username string) ([]string, map[string][]string, error) {
+	keys, err := s.users.GetKeys(username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]string, 0, len(keys))
+	conditional := make(map[string][]string)
+	for _, k := range keys {
+		if k.IsConditional() {
+			conditional[k.Key] = append(conditional[k.Key], k.Conditions...)
+			continue
+		}
+		results = append(results, k.Key)
+	}
+
+	return results, conditional, nil
+}
+
+// conditionsOf returns conditions under which the owner's conditional keys grant the token's key, by the
+// patterns which the token is granted. A conditional key which grants the whole token's key gives its conditions
+// to the token's key, one which the token's key covers gives them to itself.
+func conditionsOf(conditional map[string][]string, key string) map[string][]string {
+	results := make(map[string][]string)
+	for pattern, conditions := range conditional {
+		switch {
+		case keymatch.Match(pattern, key):
+			results[key] = append(results[key], conditions...)
+		case keymatch.Match(key, pattern):
+			results[pattern] = append(results[pattern], conditions...)
+		}
+	}
+
+	return results
+}
+
+func (s *service) isValidName(name string) bool {
+	matched, err := regexp.Match(`^[a-zA-Z0-9_-]{1,32}$`, []byte(name))
+	return err == nil && matched
+}
+
+func hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func dedupe(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	results := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if !seen[k] {
+			seen[k] = true
+			results = append(results, k)
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,163 @@
+package tokenmgr
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+)
+
+// store is an in-memory Storer of one user's tokens
+type store struct {
+	tokens []*Token
+}
+
+func (st *store) AddToken(userID int64, name string, prefix string, hash string, keys []string,
+	expiresAt sql.NullTime) (int64, error) {
+
+	id := int64(len(st.tokens) + 1)
+	st.tokens = append(st.tokens, &Token{ID: id, UserID: userID, TenantID: common.DefaultTenant, Username: "clerk",
+		Name: name, Prefix: prefix, Hash: hash, Keys: keys, ExpiresAt: expiresAt})
+	return id, nil
+}
+
+func (st *store) GetToken(userID int64, name string) (*Token, error) {
+	for _, t := range st.tokens {
+		if t.UserID == userID && t.Name == name {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (st *store) GetTokenByHash(hash string) (*Token, error) {
+	for _, t := range st.tokens {
+		if t.Hash == hash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (st *store) GetTokens(userID int64) ([]*Token, error) {
+	return st.tokens, nil
+}
+
+func (st *store) RevokeToken(id int64) error {
+	st.tokens[id-1].Revoked = true
+	return nil
+}
+
+func (st *store) TouchToken(id int64, usedAt time.Time, before time.Time) error {
+	return nil
+}
+
+// users is a user service which knows the keys of a single user
+type users struct {
+	usrmgr.Service
+	keys []*usrmgr.Key
+}
+
+func (s *users) GetUserByUsername(username string) (*usrmgr.User, error) {
+	if username != "clerk" {
+		return nil, nil
+	}
+	return &usrmgr.User{ID: 1, Username: username, TenantID: common.DefaultTenant}, nil
+}
+
+func (s *users) GetKeys(username string) ([]*usrmgr.Key, error) {
+	return s.keys, nil
+}
+
+func (s *users) GetDenials(username string) ([]*usrmgr.Denial, error) {
+	return []*usrmgr.Denial{{Key: "users:delete"}}, nil
+}
+
+func (s *users) WithTenant(tenantID int64) usrmgr.Service {
+	return s
+}
+
+func TestService_CreateToken(t *testing.T) {
+	u := &users{keys: []*usrmgr.Key{
+		{Key: "users:*"},
+		{Key: "reports:sales", Conditions: []string{`ip == "10.0.0.1"`}},
+	}}
+	s := NewService(&store{}, u)
+
+	token, plain, err := s.CreateToken("clerk", "ci", []string{"users:get", "users:get", "reports:sales"},
+		sql.NullTime{})
+	require.Nil(t, err)
+	require.Equal(t, []string{"users:get", "reports:sales"}, token.Keys)
+	require.Equal(t, token.Prefix, plain[len(Prefix):len(Prefix)+8])
+
+	tests := []struct {
+		name      string
+		username  string
+		token     string
+		keys      []string
+		expiresAt sql.NullTime
+		err       error
+	}{
+		{"name_invalid", "clerk", "c i", []string{"users:get"}, sql.NullTime{}, common.ErrTokenNameInvalid},
+		{"keys_missing", "clerk", "cd", nil, sql.NullTime{}, common.ErrMissingTokenKeys},
+		{"expired", "clerk", "cd", []string{"users:get"}, sql.NullTime{Time: time.Now(), Valid: true},
+			common.ErrTokenExpiryInvalid},
+		{"user_not_found", "nobody", "cd", []string{"users:get"}, sql.NullTime{}, common.ErrUserNotFound},
+		{"key_not_held", "clerk", "cd", []string{"orders:*"}, sql.NullTime{}, common.ErrNotAllowed},
+		{"duplicated", "clerk", "ci", []string{"users:get"}, sql.NullTime{}, common.ErrDuplicatedToken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := s.CreateToken(test.username, test.token, test.keys, test.expiresAt)
+			require.Equal(t, test.err, err)
+		})
+	}
+
+	// every invalid field is told
+	_, _, err = s.CreateToken("clerk", "c i", nil, sql.NullTime{Time: time.Now(), Valid: true})
+	require.Equal(t, &common.ValidationError{Problems: []*common.FieldProblem{
+		{Field: "name", Err: common.ErrTokenNameInvalid},
+		{Field: "keys", Err: common.ErrMissingTokenKeys},
+		{Field: "expires_at", Err: common.ErrTokenExpiryInvalid},
+	}}, err)
+}
+
+func TestService_Authenticate(t *testing.T) {
+	u := &users{keys: []*usrmgr.Key{
+		{Key: "users:*"},
+		{Key: "reports:sales", Conditions: []string{`ip == "10.0.0.1"`}},
+	}}
+	st := &store{}
+	s := NewService(st, u)
+
+	_, plain, err := s.CreateToken("clerk", "ci", []string{"users:get", "reports:*"}, sql.NullTime{})
+	require.Nil(t, err)
+
+	token, err := s.Authenticate(plain)
+	require.Nil(t, err)
+	require.Equal(t, []string{"users:get"}, token.Keys)
+	require.Equal(t, []string{"users:delete"}, token.Denied)
+
+	// keys which the owner holds with conditions keep their conditions
+	require.Equal(t, map[string][]string{"reports:sales": {`ip == "10.0.0.1"`}}, token.Conditions)
+
+	// keys are narrowed to the ones which the owner still holds
+	u.keys = []*usrmgr.Key{{Key: "users:get", Conditions: []string{`ip == "10.0.0.2"`}}}
+	token, err = s.Authenticate(plain)
+	require.Nil(t, err)
+	require.Len(t, token.Keys, 0)
+	require.Equal(t, map[string][]string{"users:get": {`ip == "10.0.0.2"`}}, token.Conditions)
+
+	require.Nil(t, s.RevokeToken("clerk", "ci"))
+	_, err = s.Authenticate(plain)
+	require.Equal(t, common.ErrPersonalTokenInvalid, err)
+
+	_, err = s.Authenticate(Prefix + "unknown")
+	require.Equal(t, common.ErrPersonalTokenInvalid, err)
+}
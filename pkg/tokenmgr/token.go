@@ -0,0 +1,36 @@
+package tokenmgr
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Prefix makes personal access tokens recognizable, e.g. by secret scanners and by the token parser
+const Prefix = "apat_"
+
+// Token is a personal access token. Only its sha256 hash is stored, the plain token is shown once
+// when it's created.
+type Token struct {
+	ID         int64
+	UserID     int64
+	TenantID   int64
+	Username   string
+	Name       string
+	Prefix     string
+	Hash       string
+	Keys       []string
+	Denied     []string
+	ExpiresAt  sql.NullTime
+	Revoked    bool
+	LastUsedAt sql.NullTime
+	CreatedAt  time.Time
+
+	// Conditions holds keys which the owner holds with conditions, they're granted when one of their conditions
+	// holds, as they are to the owner
+	Conditions map[string][]string
+}
+
+// Expired reports whether the token is past its expiry
+func (t *Token) Expired(now time.Time) bool {
+	return t.ExpiresAt.Valid && !now.Before(t.ExpiresAt.Time)
+}
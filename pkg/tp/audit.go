@@ -0,0 +1,149 @@
+package tp
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unaudited are routes which change nothing though they aren't read with GET
+var unaudited = map[string]bool{
+	"check_key":      true,
+	"check_relation": true,
+}
+
+// audited are changes, made with any method but GET, and logins
+func isAudited(r *route) bool {
+	if r.name == "login_provider_callback" {
+		return true
+	}
+	return r.method != "GET" && !unaudited[r.name]
+}
+
+func auditToContext() func(ctx context.Context, r *http.Request) context.Context {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		ctx = context.WithValue(ctx, common.UserAgentContextKey, r.UserAgent())
+		return context.WithValue(ctx, common.RequestPathContextKey, r.URL.Path)
+	}
+}
+
+func decodeAuditFilter(r *http.Request) (*audit.Filter, error) {
+	params := r.URL.Query()
+	filter := &audit.Filter{
+		Actor:   params.Get("actor"),
+		Action:  params.Get("action"),
+		Target:  params.Get("target"),
+		Outcome: params.Get("outcome"),
+	}
+
+	if from := params.Get("from"); len(from) > 0 {
+		t, err := time.Parse(common.TimeLayout, from)
+		if err != nil {
+			return nil, err
+		}
+		filter.From = t
+	}
+
+	if to := params.Get("to"); len(to) > 0 {
+		t, err := time.Parse(common.TimeLayout, to)
+		if err != nil {
+			return nil, err
+		}
+		filter.To = t
+	}
+
+	return filter, nil
+}
+
+func decodeQueryingAuditEventRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	filter, err := decodeAuditFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	params := r.URL.Query()
+	data := &ep.QueryingAuditEvent{Filter: filter}
+
+	page, pok := params["page"]
+	if pok && len(page) > 0 {
+		intPage, err := strconv.ParseInt(page[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data.Page = intPage
+	}
+
+	perPage, ppok := params["per_page"]
+	if ppok && len(perPage) > 0 {
+		intPerPage, err := strconv.ParseInt(perPage[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data.PerPage = intPerPage
+	}
+
+	return data, nil
+}
+
+func decodeExportingAuditEventRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	filter, err := decodeAuditFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ep.ExportingAuditEvent{
+		Filter: filter,
+		Format: r.URL.Query().Get("format"),
+	}, nil
+}
+
+func decodeVerifyingAuditLogRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+// encodeAuditExportResponse writes events as a csv attachment when it's requested, otherwise as json
+func encodeAuditExportResponse(ctx context.Context, w http.ResponseWriter, data interface{}) error {
+	export, ok := data.(*ep.AuditExport)
+	if !ok {
+		return encodeResponse(ctx, w, data)
+	}
+	if export.Format != "csv" {
+		return encodeResponse(ctx, w, export.Events)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"audit_%s.csv\"", time.Now().Format("20060102150405")))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "created_at", "actor", "action", "target", "outcome", "error", "ip", "user_agent",
+		"before", "after", "diff", "prev_hash", "hash"})
+	for _, e := range export.Events {
+		writer.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.Format(common.TimeLayout),
+			e.Actor,
+			e.Action,
+			e.Target,
+			e.Outcome,
+			e.Error,
+			e.IP,
+			e.UserAgent,
+			string(e.Before),
+			string(e.After),
+			string(e.Diff),
+			e.PrevHash,
+			e.Hash,
+		})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
@@ -0,0 +1,66 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/vespaiach/auth/pkg/backup"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+	"strconv"
+)
+
+func decodeExportingDataRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.ExportingData)
+
+	if hashes := r.URL.Query().Get("hashes"); len(hashes) > 0 {
+		b, err := strconv.ParseBool(hashes)
+		if err != nil {
+			return nil, err
+		}
+		data.WithHashes = b
+	}
+
+	return data, nil
+}
+
+func decodeImportingDataRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+	data := &ep.ImportingData{
+		Document: new(backup.Document),
+		Strategy: backup.StrategySkip,
+	}
+
+	err := json.NewDecoder(r.Body).Decode(data.Document)
+	if err != nil {
+		return nil, err
+	}
+
+	if strategy := params.Get("strategy"); len(strategy) > 0 {
+		data.Strategy = strategy
+	}
+
+	if dryRun := params.Get("dry_run"); len(dryRun) > 0 {
+		data.DryRun, err = strconv.ParseBool(dryRun)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// encodeDocumentResponse writes an exported document as is, so it can be imported back without changes
+func encodeDocumentResponse(ctx context.Context, w http.ResponseWriter, data interface{}) error {
+	doc, ok := data.(*backup.Document)
+	if !ok {
+		return encodeResponse(ctx, w, data)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"auth_v%d_%s.json\"", doc.Version, doc.ExportedAt.Format("20060102150405")))
+	w.WriteHeader(http.StatusOK)
+
+	return json.NewEncoder(w).Encode(doc)
+}
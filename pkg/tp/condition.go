@@ -0,0 +1,69 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net"
+	"net/http"
+)
+
+// remoteAddrToContext keeps the caller's address, which conditions of grants may check. Forwarding headers aren't
+// trusted as callers can set them.
+func remoteAddrToContext() func(ctx context.Context, r *http.Request) context.Context {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return context.WithValue(ctx, common.RemoteAddrContextKey, host)
+	}
+}
+
+func decodeModifyingKeyConditionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(ep.ModifyingKeyCondition)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Bunch = params["name"]
+	data.Key = params["key"]
+
+	return data, nil
+}
+
+func decodeModifyingBunchConditionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(ep.ModifyingBunchCondition)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Username = params["name"]
+	data.Bunch = params["bunch"]
+
+	return data, nil
+}
+
+func decodeGettingUserAttributesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
+
+func decodeModifyingUserAttributesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(ep.ModifyingUserAttributes)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Username = params["name"]
+
+	return data, nil
+}
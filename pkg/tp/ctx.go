@@ -2,8 +2,15 @@ package tp
 
 import (
 	"context"
+	"github.com/google/uuid"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/errcat"
+	"github.com/vespaiach/auth/pkg/i18n"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
 
 	kith "github.com/go-kit/kit/transport/http"
 )
@@ -13,9 +20,15 @@ import (
 	"time"
 )
 
+const (
+	// requestIDHeader carries the id which a request is told by in logs and error responses
+	requestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
+)
+
 type response struct {
 	Data        interface{} `json:"data,omitempty"`
-	Error       interface{} `json:"error,omitempty"`
 	Status      interface{} `json:"status"`
 	StatusCode  int         `json:"code"`
 	ResponsedAt string      `json:"responsed_at"`
@@ -37,35 +50,96 @@ func (res *response) success(statusCode int, data interface{}) {
 	res.response(statusCode)
 }
 
-func (res *response) fail(statusCode int, err error) {
-	res.Error = err.Error()
-	res.response(statusCode)
+// errorResponse tells an error as the catalog describes it
+type errorResponse struct {
+	Code        string           `json:"code"`
+	Message     string           `json:"message"`
+	Details     []*errcat.Detail `json:"details"`
+	RequestID   string           `json:"request_id"`
+	Status      string           `json:"status"`
+	ResponsedAt string           `json:"responsed_at"`
 }
 
-func encodeError(_ context.Context, err error, w http.ResponseWriter) {
-	result := &response{writer: w}
-	switch err {
-	case common.ErrWrongInputDatatype:
-		result.fail(http.StatusInternalServerError, err)
-		break
-	case common.ErrWrongJWTToken, common.ErrMissingJWTToken, common.ErrWrongCredentials:
-		result.fail(http.StatusUnauthorized, err)
-		break
-	case common.ErrNotAllowed:
-		result.fail(http.StatusForbidden, err)
-		break
-	case common.ErrDuplicatedUsername, common.ErrEmailInvalid, common.ErrDuplicatedEmail,
-		common.ErrUsernameInvalid, common.ErrDuplicatedBunch, common.ErrBunchNameInvalid,
-		common.ErrKeyNameInvalid, common.ErrMissingHash, common.ErrDuplicatedKey,
-		common.ErrPasswordMissing:
-		result.fail(http.StatusBadRequest, err)
-		break
-	case common.ErrKeyNotFound, common.ErrUserNotFound, common.ErrBunchNotFound:
-		result.fail(http.StatusNotFound, err)
-		break
-	default:
-		result.fail(http.StatusInternalServerError, err)
-		break
+// errorEncoder answers errors with the entries of the catalog. Errors out of the catalog are logged with the
+// request id, callers are only told that the request failed.
+func errorEncoder(catalog *errcat.Catalog) kith.ErrorEncoder {
+	return func(ctx context.Context, err error, w http.ResponseWriter) {
+		lang, _ := ctx.Value(common.LanguageContextKey).(string)
+		requestID, _ := ctx.Value(common.RequestIDContextKey).(string)
+
+		problem := catalog.Describe(requestError(err), lang)
+		if problem.Unknown {
+			log.Printf("request %s: %v", requestID, err)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if len(requestID) > 0 {
+			w.Header().Set(requestIDHeader, requestID)
+		}
+		w.WriteHeader(problem.Status)
+		json.NewEncoder(w).Encode(&errorResponse{
+			Code:        problem.Code,
+			Message:     problem.Message,
+			Details:     problem.Details,
+			RequestID:   requestID,
+			Status:      http.StatusText(problem.Status),
+			ResponsedAt: time.Now().Format(common.TimeLayout),
+		})
+	}
+}
+
+// requestError tells requests which can't be decoded as invalid requests, fields of mistyped values are
+// named
+func requestError(err error) error {
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		if len(e.Field) > 0 {
+			invalid := new(common.ValidationError)
+			invalid.Add(e.Field, common.ErrRequestInvalid)
+			return invalid
+		}
+		return common.ErrRequestInvalid
+	case *json.SyntaxError, *strconv.NumError:
+		return common.ErrRequestInvalid
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return common.ErrRequestInvalid
+	}
+	return err
+}
+
+// requestIDToContext keeps the id which the request is told by, the caller's own one from the X-Request-ID
+// header or a new one
+func requestIDToContext() kith.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		id := r.Header.Get(requestIDHeader)
+		if len(id) == 0 || len(id) > maxRequestIDLength {
+			id = uuid.New().String()
+		}
+		return context.WithValue(ctx, common.RequestIDContextKey, id)
+	}
+}
+
+// requestIDToHeader tells the request id back to callers
+func requestIDToHeader() kith.ServerResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter) context.Context {
+		if id, ok := ctx.Value(common.RequestIDContextKey).(string); ok {
+			w.Header().Set(requestIDHeader, id)
+		}
+		return ctx
+	}
+}
+
+// languageToContext keeps the locale which messages are told in. The caller's preference carried by the bearer
+// token goes first, then languages of the Accept-Language header.
+func languageToContext(locales *i18n.Locales) kith.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		tags := i18n.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+		if locale := ep.TokenLocale(ctx); len(locale) > 0 {
+			tags = append([]string{locale}, tags...)
+		}
+		return context.WithValue(ctx, common.LanguageContextKey, locales.Match(tags...))
 	}
 }
 
@@ -0,0 +1,82 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/errcat"
+	"github.com/vespaiach/auth/pkg/i18n"
+)
+
+func TestErrorEncoder(t *testing.T) {
+	catalog, err := errcat.Load("../../error.yml", nil)
+	require.Nil(t, err)
+	encode := errorEncoder(catalog)
+
+	r := httptest.NewRequest("POST", "/v1/users", nil)
+	r.Header.Set(requestIDHeader, "req-1")
+	ctx := requestIDToContext()(context.Background(), r)
+
+	w := httptest.NewRecorder()
+	encode(ctx, common.ErrDuplicatedKey, w)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "req-1", w.Header().Get(requestIDHeader))
+
+	res := new(errorResponse)
+	require.Nil(t, json.NewDecoder(w.Body).Decode(res))
+	require.Equal(t, "duplicated_key", res.Code)
+	require.Equal(t, "req-1", res.RequestID)
+
+	// messages of the storage aren't told to callers
+	w = httptest.NewRecorder()
+	encode(ctx, errors.New("Error 1146: Table 'auth.users' doesn't exist"), w)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NotContains(t, w.Body.String(), "auth.users")
+
+	// mistyped fields of bodies are listed
+	var data struct {
+		Active bool `json:"active"`
+	}
+	err = json.NewDecoder(strings.NewReader(`{"active": "yes"}`)).Decode(&data)
+	w = httptest.NewRecorder()
+	encode(ctx, err, w)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	res = new(errorResponse)
+	require.Nil(t, json.NewDecoder(w.Body).Decode(res))
+	require.Len(t, res.Details, 1)
+	require.Equal(t, "active", res.Details[0].Field)
+}
+
+func TestRequestIDToContext(t *testing.T) {
+	ctx := requestIDToContext()(context.Background(), httptest.NewRequest("GET", "/v1/users", nil))
+	id, _ := ctx.Value(common.RequestIDContextKey).(string)
+	require.NotEmpty(t, id)
+}
+
+func TestLanguageToContext(t *testing.T) {
+	locales := i18n.New("en")
+	require.Nil(t, locales.Add("vi", []byte("errors:\n  key_not_found: khóa không tồn tại\n")))
+	tolang := languageToContext(locales)
+
+	r := httptest.NewRequest("GET", "/v1/users", nil)
+	r.Header.Set("Accept-Language", "fr;q=0.9, vi-VN;q=0.8, en;q=0.5")
+	require.Equal(t, "vi", tolang(context.Background(), r).Value(common.LanguageContextKey))
+
+	// the user's own locale goes before the header
+	token, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, ep.TokenClaims{Locale: "en-GB"}).
+		SignedString([]byte("secret"))
+	require.Nil(t, err)
+	ctx := context.WithValue(context.Background(), jwt.JWTTokenContextKey, token)
+	require.Equal(t, "en", tolang(ctx, r).Value(common.LanguageContextKey))
+}
@@ -0,0 +1,50 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+)
+
+func decodeChangingBunchDenialsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(ep.ChangingBunchDenials)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Bunch = params["name"]
+
+	return data, nil
+}
+
+func decodeGettingBunchDenialsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
+
+func decodeChangingUserDenialsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(ep.ChangingUserDenials)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Username = params["name"]
+
+	return data, nil
+}
+
+func decodeGettingUserDenialsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
+
+func decodeExplainingKeyRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return &ep.ExplainingKey{Username: params["name"], Key: params["key"]}, nil
+}
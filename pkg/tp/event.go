@@ -0,0 +1,150 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// streamPoll is how often the stream looks for new events
+	streamPoll = time.Second
+
+	// streamHeartbeat is how long the stream may stay silent, comments are sent to keep proxies from
+	// closing idle connections
+	streamHeartbeat = 15 * time.Second
+
+	// streamRetry tells clients how long to wait before reconnecting, in milliseconds
+	streamRetry = 3000
+
+	// streamCheck is how often the caller's token is checked again, streams of revoked tokens are closed
+	streamCheck = 10 * time.Second
+)
+
+func decodeStreamingEventRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.StreamingEvent)
+	params := r.URL.Query()
+
+	// EventSource sends the header when it reconnects, clients which can't set headers resume with the query
+	lastID := r.Header.Get("Last-Event-ID")
+	if len(lastID) == 0 {
+		lastID = params.Get("last_event_id")
+	}
+	if len(lastID) > 0 {
+		id, err := strconv.ParseInt(lastID, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data.LastEventID = id
+	}
+
+	if types := params.Get("types"); len(types) > 0 {
+		data.Types = strings.Split(types, ",")
+	}
+
+	return data, nil
+}
+
+// encodeEventStream writes events as server-sent events until the client goes away, or its token expires or is
+// revoked. Events are identified by their sequence numbers, which clients resume from.
+// Errors can't be told with a status once the stream is opened, they're sent as error events closing it.
+func encodeEventStream(ctx context.Context, w http.ResponseWriter, data interface{}) error {
+	stream, ok := data.(*ep.EventStream)
+	if !ok {
+		return encodeResponse(ctx, w, data)
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming isn't supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+	flusher.Flush()
+
+	var expired <-chan time.Time
+	if !stream.Until.IsZero() {
+		timer := time.NewTimer(time.Until(stream.Until))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	ticker := time.NewTicker(streamPoll)
+	defer ticker.Stop()
+
+	checker := time.NewTicker(streamCheck)
+	defer checker.Stop()
+
+	lastSeq := stream.LastEventID
+	lastWrite := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-expired:
+			writeStreamError(w, "token is expired")
+			flusher.Flush()
+			return nil
+		case <-checker.C:
+			if stream.Check != nil && stream.Check() != nil {
+				writeStreamError(w, "token is revoked")
+				flusher.Flush()
+				return nil
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		for {
+			events, err := stream.Next(lastSeq)
+			if err != nil {
+				// errors of the storage aren't told to callers, as they aren't by error responses
+				requestID, _ := ctx.Value(common.RequestIDContextKey).(string)
+				log.Printf("request %s: %v", requestID, err)
+				writeStreamError(w, "internal error")
+				flusher.Flush()
+				return nil
+			}
+
+			for _, e := range events {
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, e.Payload); err != nil {
+					return nil
+				}
+				lastSeq = e.Seq
+				lastWrite = time.Now()
+			}
+
+			if len(events) == 0 {
+				break
+			}
+			flusher.Flush()
+		}
+
+		if time.Since(lastWrite) >= streamHeartbeat {
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+			lastWrite = time.Now()
+		}
+	}
+}
+
+func writeStreamError(w http.ResponseWriter, message string) {
+	data, _ := json.Marshal(map[string]string{"error": message})
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+}
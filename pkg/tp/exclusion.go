@@ -0,0 +1,29 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+)
+
+func decodeAddingExclusionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.AddingExclusion)
+
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decodeGettingExclusionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
+
+func decodeEmptyRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return nil, nil
+}
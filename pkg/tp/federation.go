@@ -0,0 +1,68 @@
+package tp
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/sso"
+	"net/http"
+)
+
+// stateCookie keeps signed login state while a user signs in upstream
+const stateCookie = "login_state"
+
+func decodeStartingFederatedLoginRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["provider"], nil
+}
+
+func decodeFederatedCallbackRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	query := r.URL.Query()
+
+	data := &ep.FederatedCallback{
+		Provider: params["provider"],
+		Code:     query.Get("code"),
+		State:    query.Get("state"),
+		Error:    query.Get("error"),
+	}
+
+	if cookie, err := r.Cookie(stateCookie); err == nil {
+		data.SignedState = cookie.Value
+	}
+
+	return data, nil
+}
+
+// encodeRedirectResponse keeps login state in a cookie and redirects to upstream provider
+func encodeRedirectResponse(ctx context.Context, w http.ResponseWriter, data interface{}) error {
+	redirect, ok := data.(*sso.Redirect)
+	if !ok {
+		return encodeResponse(ctx, w, data)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    redirect.State,
+		Path:     "/v1/login",
+		HttpOnly: true,
+		Secure:   redirect.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Location", redirect.URL)
+	w.WriteHeader(http.StatusFound)
+
+	return nil
+}
+
+// encodeFederatedTokenResponse drops login state, which can't be used again, and writes the token
+func encodeFederatedTokenResponse(ctx context.Context, w http.ResponseWriter, data interface{}) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Path:     "/v1/login",
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+
+	return encodeResponse(ctx, w, data)
+}
@@ -0,0 +1,2123 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: auth.proto
+
+package grpc
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	wrappers "github.com/golang/protobuf/ptypes/wrappers"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type LoginRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	Audience             string   `protobuf:"bytes,3,opt,name=audience,proto3" json:"audience,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LoginRequest) Reset()         { *m = LoginRequest{} }
+func (m *LoginRequest) String() string { return proto.CompactTextString(m) }
+func (*LoginRequest) ProtoMessage()    {}
+func (*LoginRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{0}
+}
+
+func (m *LoginRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LoginRequest.Unmarshal(m, b)
+}
+func (m *LoginRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LoginRequest.Marshal(b, m, deterministic)
+}
+func (m *LoginRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LoginRequest.Merge(m, src)
+}
+func (m *LoginRequest) XXX_Size() int {
+	return xxx_messageInfo_LoginRequest.Size(m)
+}
+func (m *LoginRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LoginRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LoginRequest proto.InternalMessageInfo
+
+func (m *LoginRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *LoginRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func (m *LoginRequest) GetAudience() string {
+	if m != nil {
+		return m.Audience
+	}
+	return ""
+}
+
+type Token struct {
+	AccessToken          string   `protobuf:"bytes,1,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Token) Reset()         { *m = Token{} }
+func (m *Token) String() string { return proto.CompactTextString(m) }
+func (*Token) ProtoMessage()    {}
+func (*Token) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{1}
+}
+
+func (m *Token) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Token.Unmarshal(m, b)
+}
+func (m *Token) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Token.Marshal(b, m, deterministic)
+}
+func (m *Token) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Token.Merge(m, src)
+}
+func (m *Token) XXX_Size() int {
+	return xxx_messageInfo_Token.Size(m)
+}
+func (m *Token) XXX_DiscardUnknown() {
+	xxx_messageInfo_Token.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Token proto.InternalMessageInfo
+
+func (m *Token) GetAccessToken() string {
+	if m != nil {
+		return m.AccessToken
+	}
+	return ""
+}
+
+type User struct {
+	Id                   int64                `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Username             string               `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Email                string               `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	Active               bool                 `protobuf:"varint,4,opt,name=active,proto3" json:"active,omitempty"`
+	Type                 string               `protobuf:"bytes,5,opt,name=type,proto3" json:"type,omitempty"`
+	Owner                string               `protobuf:"bytes,6,opt,name=owner,proto3" json:"owner,omitempty"`
+	Desc                 string               `protobuf:"bytes,7,opt,name=desc,proto3" json:"desc,omitempty"`
+	CreatedAt            *timestamp.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt            *timestamp.Timestamp `protobuf:"bytes,9,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *User) Reset()         { *m = User{} }
+func (m *User) String() string { return proto.CompactTextString(m) }
+func (*User) ProtoMessage()    {}
+func (*User) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{2}
+}
+
+func (m *User) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_User.Unmarshal(m, b)
+}
+func (m *User) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_User.Marshal(b, m, deterministic)
+}
+func (m *User) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_User.Merge(m, src)
+}
+func (m *User) XXX_Size() int {
+	return xxx_messageInfo_User.Size(m)
+}
+func (m *User) XXX_DiscardUnknown() {
+	xxx_messageInfo_User.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_User proto.InternalMessageInfo
+
+func (m *User) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *User) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *User) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *User) GetActive() bool {
+	if m != nil {
+		return m.Active
+	}
+	return false
+}
+
+func (m *User) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *User) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *User) GetDesc() string {
+	if m != nil {
+		return m.Desc
+	}
+	return ""
+}
+
+func (m *User) GetCreatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+func (m *User) GetUpdatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return nil
+}
+
+type AddUserRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Email                string   `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	Password             string   `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddUserRequest) Reset()         { *m = AddUserRequest{} }
+func (m *AddUserRequest) String() string { return proto.CompactTextString(m) }
+func (*AddUserRequest) ProtoMessage()    {}
+func (*AddUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{3}
+}
+
+func (m *AddUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddUserRequest.Unmarshal(m, b)
+}
+func (m *AddUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddUserRequest.Marshal(b, m, deterministic)
+}
+func (m *AddUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddUserRequest.Merge(m, src)
+}
+func (m *AddUserRequest) XXX_Size() int {
+	return xxx_messageInfo_AddUserRequest.Size(m)
+}
+func (m *AddUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddUserRequest proto.InternalMessageInfo
+
+func (m *AddUserRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *AddUserRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *AddUserRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+type GetUserRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetUserRequest) Reset()         { *m = GetUserRequest{} }
+func (m *GetUserRequest) String() string { return proto.CompactTextString(m) }
+func (*GetUserRequest) ProtoMessage()    {}
+func (*GetUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{4}
+}
+
+func (m *GetUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetUserRequest.Unmarshal(m, b)
+}
+func (m *GetUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetUserRequest.Marshal(b, m, deterministic)
+}
+func (m *GetUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetUserRequest.Merge(m, src)
+}
+func (m *GetUserRequest) XXX_Size() int {
+	return xxx_messageInfo_GetUserRequest.Size(m)
+}
+func (m *GetUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetUserRequest proto.InternalMessageInfo
+
+func (m *GetUserRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+// ModifyUserRequest changes the user named by lookup, empty fields are kept
+type ModifyUserRequest struct {
+	Lookup               string              `protobuf:"bytes,1,opt,name=lookup,proto3" json:"lookup,omitempty"`
+	Username             string              `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Email                string              `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	NewPassword          string              `protobuf:"bytes,4,opt,name=new_password,json=newPassword,proto3" json:"new_password,omitempty"`
+	OldPassword          string              `protobuf:"bytes,5,opt,name=old_password,json=oldPassword,proto3" json:"old_password,omitempty"`
+	Active               *wrappers.BoolValue `protobuf:"bytes,6,opt,name=active,proto3" json:"active,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *ModifyUserRequest) Reset()         { *m = ModifyUserRequest{} }
+func (m *ModifyUserRequest) String() string { return proto.CompactTextString(m) }
+func (*ModifyUserRequest) ProtoMessage()    {}
+func (*ModifyUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{5}
+}
+
+func (m *ModifyUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ModifyUserRequest.Unmarshal(m, b)
+}
+func (m *ModifyUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ModifyUserRequest.Marshal(b, m, deterministic)
+}
+func (m *ModifyUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ModifyUserRequest.Merge(m, src)
+}
+func (m *ModifyUserRequest) XXX_Size() int {
+	return xxx_messageInfo_ModifyUserRequest.Size(m)
+}
+func (m *ModifyUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ModifyUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ModifyUserRequest proto.InternalMessageInfo
+
+func (m *ModifyUserRequest) GetLookup() string {
+	if m != nil {
+		return m.Lookup
+	}
+	return ""
+}
+
+func (m *ModifyUserRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *ModifyUserRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *ModifyUserRequest) GetNewPassword() string {
+	if m != nil {
+		return m.NewPassword
+	}
+	return ""
+}
+
+func (m *ModifyUserRequest) GetOldPassword() string {
+	if m != nil {
+		return m.OldPassword
+	}
+	return ""
+}
+
+func (m *ModifyUserRequest) GetActive() *wrappers.BoolValue {
+	if m != nil {
+		return m.Active
+	}
+	return nil
+}
+
+type QueryUsersRequest struct {
+	Username             string              `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Email                string              `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	Active               *wrappers.BoolValue `protobuf:"bytes,3,opt,name=active,proto3" json:"active,omitempty"`
+	Type                 string              `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Sort                 string              `protobuf:"bytes,5,opt,name=sort,proto3" json:"sort,omitempty"`
+	Page                 int64               `protobuf:"varint,6,opt,name=page,proto3" json:"page,omitempty"`
+	PerPage              int64               `protobuf:"varint,7,opt,name=per_page,json=perPage,proto3" json:"per_page,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *QueryUsersRequest) Reset()         { *m = QueryUsersRequest{} }
+func (m *QueryUsersRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryUsersRequest) ProtoMessage()    {}
+func (*QueryUsersRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{6}
+}
+
+func (m *QueryUsersRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_QueryUsersRequest.Unmarshal(m, b)
+}
+func (m *QueryUsersRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_QueryUsersRequest.Marshal(b, m, deterministic)
+}
+func (m *QueryUsersRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryUsersRequest.Merge(m, src)
+}
+func (m *QueryUsersRequest) XXX_Size() int {
+	return xxx_messageInfo_QueryUsersRequest.Size(m)
+}
+func (m *QueryUsersRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryUsersRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryUsersRequest proto.InternalMessageInfo
+
+func (m *QueryUsersRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *QueryUsersRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *QueryUsersRequest) GetActive() *wrappers.BoolValue {
+	if m != nil {
+		return m.Active
+	}
+	return nil
+}
+
+func (m *QueryUsersRequest) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *QueryUsersRequest) GetSort() string {
+	if m != nil {
+		return m.Sort
+	}
+	return ""
+}
+
+func (m *QueryUsersRequest) GetPage() int64 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *QueryUsersRequest) GetPerPage() int64 {
+	if m != nil {
+		return m.PerPage
+	}
+	return 0
+}
+
+type Users struct {
+	Records              []*User  `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	Total                int64    `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Page                 int64    `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	PerPage              int64    `protobuf:"varint,4,opt,name=per_page,json=perPage,proto3" json:"per_page,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Users) Reset()         { *m = Users{} }
+func (m *Users) String() string { return proto.CompactTextString(m) }
+func (*Users) ProtoMessage()    {}
+func (*Users) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{7}
+}
+
+func (m *Users) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Users.Unmarshal(m, b)
+}
+func (m *Users) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Users.Marshal(b, m, deterministic)
+}
+func (m *Users) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Users.Merge(m, src)
+}
+func (m *Users) XXX_Size() int {
+	return xxx_messageInfo_Users.Size(m)
+}
+func (m *Users) XXX_DiscardUnknown() {
+	xxx_messageInfo_Users.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Users proto.InternalMessageInfo
+
+func (m *Users) GetRecords() []*User {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
+func (m *Users) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *Users) GetPage() int64 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *Users) GetPerPage() int64 {
+	if m != nil {
+		return m.PerPage
+	}
+	return 0
+}
+
+type AddBunchesToUserRequest struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Bunches              []string `protobuf:"bytes,2,rep,name=bunches,proto3" json:"bunches,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddBunchesToUserRequest) Reset()         { *m = AddBunchesToUserRequest{} }
+func (m *AddBunchesToUserRequest) String() string { return proto.CompactTextString(m) }
+func (*AddBunchesToUserRequest) ProtoMessage()    {}
+func (*AddBunchesToUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{8}
+}
+
+func (m *AddBunchesToUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddBunchesToUserRequest.Unmarshal(m, b)
+}
+func (m *AddBunchesToUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddBunchesToUserRequest.Marshal(b, m, deterministic)
+}
+func (m *AddBunchesToUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddBunchesToUserRequest.Merge(m, src)
+}
+func (m *AddBunchesToUserRequest) XXX_Size() int {
+	return xxx_messageInfo_AddBunchesToUserRequest.Size(m)
+}
+func (m *AddBunchesToUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddBunchesToUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddBunchesToUserRequest proto.InternalMessageInfo
+
+func (m *AddBunchesToUserRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *AddBunchesToUserRequest) GetBunches() []string {
+	if m != nil {
+		return m.Bunches
+	}
+	return nil
+}
+
+type Bunch struct {
+	Id                   int64                `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string               `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Desc                 string               `protobuf:"bytes,3,opt,name=desc,proto3" json:"desc,omitempty"`
+	Active               bool                 `protobuf:"varint,4,opt,name=active,proto3" json:"active,omitempty"`
+	CreatedAt            *timestamp.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt            *timestamp.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Bunch) Reset()         { *m = Bunch{} }
+func (m *Bunch) String() string { return proto.CompactTextString(m) }
+func (*Bunch) ProtoMessage()    {}
+func (*Bunch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{9}
+}
+
+func (m *Bunch) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Bunch.Unmarshal(m, b)
+}
+func (m *Bunch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Bunch.Marshal(b, m, deterministic)
+}
+func (m *Bunch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Bunch.Merge(m, src)
+}
+func (m *Bunch) XXX_Size() int {
+	return xxx_messageInfo_Bunch.Size(m)
+}
+func (m *Bunch) XXX_DiscardUnknown() {
+	xxx_messageInfo_Bunch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Bunch proto.InternalMessageInfo
+
+func (m *Bunch) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Bunch) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Bunch) GetDesc() string {
+	if m != nil {
+		return m.Desc
+	}
+	return ""
+}
+
+func (m *Bunch) GetActive() bool {
+	if m != nil {
+		return m.Active
+	}
+	return false
+}
+
+func (m *Bunch) GetCreatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+func (m *Bunch) GetUpdatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return nil
+}
+
+type AddBunchRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Desc                 string   `protobuf:"bytes,2,opt,name=desc,proto3" json:"desc,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddBunchRequest) Reset()         { *m = AddBunchRequest{} }
+func (m *AddBunchRequest) String() string { return proto.CompactTextString(m) }
+func (*AddBunchRequest) ProtoMessage()    {}
+func (*AddBunchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{10}
+}
+
+func (m *AddBunchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddBunchRequest.Unmarshal(m, b)
+}
+func (m *AddBunchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddBunchRequest.Marshal(b, m, deterministic)
+}
+func (m *AddBunchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddBunchRequest.Merge(m, src)
+}
+func (m *AddBunchRequest) XXX_Size() int {
+	return xxx_messageInfo_AddBunchRequest.Size(m)
+}
+func (m *AddBunchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddBunchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddBunchRequest proto.InternalMessageInfo
+
+func (m *AddBunchRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *AddBunchRequest) GetDesc() string {
+	if m != nil {
+		return m.Desc
+	}
+	return ""
+}
+
+type GetBunchRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetBunchRequest) Reset()         { *m = GetBunchRequest{} }
+func (m *GetBunchRequest) String() string { return proto.CompactTextString(m) }
+func (*GetBunchRequest) ProtoMessage()    {}
+func (*GetBunchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{11}
+}
+
+func (m *GetBunchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetBunchRequest.Unmarshal(m, b)
+}
+func (m *GetBunchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetBunchRequest.Marshal(b, m, deterministic)
+}
+func (m *GetBunchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetBunchRequest.Merge(m, src)
+}
+func (m *GetBunchRequest) XXX_Size() int {
+	return xxx_messageInfo_GetBunchRequest.Size(m)
+}
+func (m *GetBunchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetBunchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetBunchRequest proto.InternalMessageInfo
+
+func (m *GetBunchRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// ModifyBunchRequest changes the bunch named by lookup, empty fields are kept
+type ModifyBunchRequest struct {
+	Lookup               string              `protobuf:"bytes,1,opt,name=lookup,proto3" json:"lookup,omitempty"`
+	Name                 string              `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Desc                 string              `protobuf:"bytes,3,opt,name=desc,proto3" json:"desc,omitempty"`
+	Active               *wrappers.BoolValue `protobuf:"bytes,4,opt,name=active,proto3" json:"active,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *ModifyBunchRequest) Reset()         { *m = ModifyBunchRequest{} }
+func (m *ModifyBunchRequest) String() string { return proto.CompactTextString(m) }
+func (*ModifyBunchRequest) ProtoMessage()    {}
+func (*ModifyBunchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{12}
+}
+
+func (m *ModifyBunchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ModifyBunchRequest.Unmarshal(m, b)
+}
+func (m *ModifyBunchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ModifyBunchRequest.Marshal(b, m, deterministic)
+}
+func (m *ModifyBunchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ModifyBunchRequest.Merge(m, src)
+}
+func (m *ModifyBunchRequest) XXX_Size() int {
+	return xxx_messageInfo_ModifyBunchRequest.Size(m)
+}
+func (m *ModifyBunchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ModifyBunchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ModifyBunchRequest proto.InternalMessageInfo
+
+func (m *ModifyBunchRequest) GetLookup() string {
+	if m != nil {
+		return m.Lookup
+	}
+	return ""
+}
+
+func (m *ModifyBunchRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ModifyBunchRequest) GetDesc() string {
+	if m != nil {
+		return m.Desc
+	}
+	return ""
+}
+
+func (m *ModifyBunchRequest) GetActive() *wrappers.BoolValue {
+	if m != nil {
+		return m.Active
+	}
+	return nil
+}
+
+type QueryBunchesRequest struct {
+	Name                 string              `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Active               *wrappers.BoolValue `protobuf:"bytes,2,opt,name=active,proto3" json:"active,omitempty"`
+	Sort                 string              `protobuf:"bytes,3,opt,name=sort,proto3" json:"sort,omitempty"`
+	Page                 int64               `protobuf:"varint,4,opt,name=page,proto3" json:"page,omitempty"`
+	PerPage              int64               `protobuf:"varint,5,opt,name=per_page,json=perPage,proto3" json:"per_page,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *QueryBunchesRequest) Reset()         { *m = QueryBunchesRequest{} }
+func (m *QueryBunchesRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryBunchesRequest) ProtoMessage()    {}
+func (*QueryBunchesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{13}
+}
+
+func (m *QueryBunchesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_QueryBunchesRequest.Unmarshal(m, b)
+}
+func (m *QueryBunchesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_QueryBunchesRequest.Marshal(b, m, deterministic)
+}
+func (m *QueryBunchesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryBunchesRequest.Merge(m, src)
+}
+func (m *QueryBunchesRequest) XXX_Size() int {
+	return xxx_messageInfo_QueryBunchesRequest.Size(m)
+}
+func (m *QueryBunchesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryBunchesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryBunchesRequest proto.InternalMessageInfo
+
+func (m *QueryBunchesRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *QueryBunchesRequest) GetActive() *wrappers.BoolValue {
+	if m != nil {
+		return m.Active
+	}
+	return nil
+}
+
+func (m *QueryBunchesRequest) GetSort() string {
+	if m != nil {
+		return m.Sort
+	}
+	return ""
+}
+
+func (m *QueryBunchesRequest) GetPage() int64 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *QueryBunchesRequest) GetPerPage() int64 {
+	if m != nil {
+		return m.PerPage
+	}
+	return 0
+}
+
+type Bunches struct {
+	Records              []*Bunch `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	Total                int64    `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Page                 int64    `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	PerPage              int64    `protobuf:"varint,4,opt,name=per_page,json=perPage,proto3" json:"per_page,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Bunches) Reset()         { *m = Bunches{} }
+func (m *Bunches) String() string { return proto.CompactTextString(m) }
+func (*Bunches) ProtoMessage()    {}
+func (*Bunches) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{14}
+}
+
+func (m *Bunches) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Bunches.Unmarshal(m, b)
+}
+func (m *Bunches) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Bunches.Marshal(b, m, deterministic)
+}
+func (m *Bunches) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Bunches.Merge(m, src)
+}
+func (m *Bunches) XXX_Size() int {
+	return xxx_messageInfo_Bunches.Size(m)
+}
+func (m *Bunches) XXX_DiscardUnknown() {
+	xxx_messageInfo_Bunches.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Bunches proto.InternalMessageInfo
+
+func (m *Bunches) GetRecords() []*Bunch {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
+func (m *Bunches) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *Bunches) GetPage() int64 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *Bunches) GetPerPage() int64 {
+	if m != nil {
+		return m.PerPage
+	}
+	return 0
+}
+
+type AddKeysToBunchRequest struct {
+	Bunch                string   `protobuf:"bytes,1,opt,name=bunch,proto3" json:"bunch,omitempty"`
+	Keys                 []string `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddKeysToBunchRequest) Reset()         { *m = AddKeysToBunchRequest{} }
+func (m *AddKeysToBunchRequest) String() string { return proto.CompactTextString(m) }
+func (*AddKeysToBunchRequest) ProtoMessage()    {}
+func (*AddKeysToBunchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{15}
+}
+
+func (m *AddKeysToBunchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddKeysToBunchRequest.Unmarshal(m, b)
+}
+func (m *AddKeysToBunchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddKeysToBunchRequest.Marshal(b, m, deterministic)
+}
+func (m *AddKeysToBunchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddKeysToBunchRequest.Merge(m, src)
+}
+func (m *AddKeysToBunchRequest) XXX_Size() int {
+	return xxx_messageInfo_AddKeysToBunchRequest.Size(m)
+}
+func (m *AddKeysToBunchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddKeysToBunchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddKeysToBunchRequest proto.InternalMessageInfo
+
+func (m *AddKeysToBunchRequest) GetBunch() string {
+	if m != nil {
+		return m.Bunch
+	}
+	return ""
+}
+
+func (m *AddKeysToBunchRequest) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type Key struct {
+	Id                   int64                `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Key                  string               `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Application          string               `protobuf:"bytes,3,opt,name=application,proto3" json:"application,omitempty"`
+	Desc                 string               `protobuf:"bytes,4,opt,name=desc,proto3" json:"desc,omitempty"`
+	CreatedAt            *timestamp.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt            *timestamp.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Key) Reset()         { *m = Key{} }
+func (m *Key) String() string { return proto.CompactTextString(m) }
+func (*Key) ProtoMessage()    {}
+func (*Key) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{16}
+}
+
+func (m *Key) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Key.Unmarshal(m, b)
+}
+func (m *Key) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Key.Marshal(b, m, deterministic)
+}
+func (m *Key) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Key.Merge(m, src)
+}
+func (m *Key) XXX_Size() int {
+	return xxx_messageInfo_Key.Size(m)
+}
+func (m *Key) XXX_DiscardUnknown() {
+	xxx_messageInfo_Key.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Key proto.InternalMessageInfo
+
+func (m *Key) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Key) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Key) GetApplication() string {
+	if m != nil {
+		return m.Application
+	}
+	return ""
+}
+
+func (m *Key) GetDesc() string {
+	if m != nil {
+		return m.Desc
+	}
+	return ""
+}
+
+func (m *Key) GetCreatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+func (m *Key) GetUpdatedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return nil
+}
+
+type AddKeyRequest struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Desc                 string   `protobuf:"bytes,2,opt,name=desc,proto3" json:"desc,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddKeyRequest) Reset()         { *m = AddKeyRequest{} }
+func (m *AddKeyRequest) String() string { return proto.CompactTextString(m) }
+func (*AddKeyRequest) ProtoMessage()    {}
+func (*AddKeyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{17}
+}
+
+func (m *AddKeyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddKeyRequest.Unmarshal(m, b)
+}
+func (m *AddKeyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddKeyRequest.Marshal(b, m, deterministic)
+}
+func (m *AddKeyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddKeyRequest.Merge(m, src)
+}
+func (m *AddKeyRequest) XXX_Size() int {
+	return xxx_messageInfo_AddKeyRequest.Size(m)
+}
+func (m *AddKeyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddKeyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddKeyRequest proto.InternalMessageInfo
+
+func (m *AddKeyRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *AddKeyRequest) GetDesc() string {
+	if m != nil {
+		return m.Desc
+	}
+	return ""
+}
+
+type GetKeyRequest struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetKeyRequest) Reset()         { *m = GetKeyRequest{} }
+func (m *GetKeyRequest) String() string { return proto.CompactTextString(m) }
+func (*GetKeyRequest) ProtoMessage()    {}
+func (*GetKeyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{18}
+}
+
+func (m *GetKeyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetKeyRequest.Unmarshal(m, b)
+}
+func (m *GetKeyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetKeyRequest.Marshal(b, m, deterministic)
+}
+func (m *GetKeyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetKeyRequest.Merge(m, src)
+}
+func (m *GetKeyRequest) XXX_Size() int {
+	return xxx_messageInfo_GetKeyRequest.Size(m)
+}
+func (m *GetKeyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetKeyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetKeyRequest proto.InternalMessageInfo
+
+func (m *GetKeyRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+// ModifyKeyRequest changes the key named by lookup, empty fields are kept
+type ModifyKeyRequest struct {
+	Lookup               string   `protobuf:"bytes,1,opt,name=lookup,proto3" json:"lookup,omitempty"`
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Desc                 string   `protobuf:"bytes,3,opt,name=desc,proto3" json:"desc,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ModifyKeyRequest) Reset()         { *m = ModifyKeyRequest{} }
+func (m *ModifyKeyRequest) String() string { return proto.CompactTextString(m) }
+func (*ModifyKeyRequest) ProtoMessage()    {}
+func (*ModifyKeyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{19}
+}
+
+func (m *ModifyKeyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ModifyKeyRequest.Unmarshal(m, b)
+}
+func (m *ModifyKeyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ModifyKeyRequest.Marshal(b, m, deterministic)
+}
+func (m *ModifyKeyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ModifyKeyRequest.Merge(m, src)
+}
+func (m *ModifyKeyRequest) XXX_Size() int {
+	return xxx_messageInfo_ModifyKeyRequest.Size(m)
+}
+func (m *ModifyKeyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ModifyKeyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ModifyKeyRequest proto.InternalMessageInfo
+
+func (m *ModifyKeyRequest) GetLookup() string {
+	if m != nil {
+		return m.Lookup
+	}
+	return ""
+}
+
+func (m *ModifyKeyRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *ModifyKeyRequest) GetDesc() string {
+	if m != nil {
+		return m.Desc
+	}
+	return ""
+}
+
+type QueryKeysRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Application          string   `protobuf:"bytes,2,opt,name=application,proto3" json:"application,omitempty"`
+	Sort                 string   `protobuf:"bytes,3,opt,name=sort,proto3" json:"sort,omitempty"`
+	Page                 int64    `protobuf:"varint,4,opt,name=page,proto3" json:"page,omitempty"`
+	PerPage              int64    `protobuf:"varint,5,opt,name=per_page,json=perPage,proto3" json:"per_page,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *QueryKeysRequest) Reset()         { *m = QueryKeysRequest{} }
+func (m *QueryKeysRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryKeysRequest) ProtoMessage()    {}
+func (*QueryKeysRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{20}
+}
+
+func (m *QueryKeysRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_QueryKeysRequest.Unmarshal(m, b)
+}
+func (m *QueryKeysRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_QueryKeysRequest.Marshal(b, m, deterministic)
+}
+func (m *QueryKeysRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryKeysRequest.Merge(m, src)
+}
+func (m *QueryKeysRequest) XXX_Size() int {
+	return xxx_messageInfo_QueryKeysRequest.Size(m)
+}
+func (m *QueryKeysRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryKeysRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryKeysRequest proto.InternalMessageInfo
+
+func (m *QueryKeysRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *QueryKeysRequest) GetApplication() string {
+	if m != nil {
+		return m.Application
+	}
+	return ""
+}
+
+func (m *QueryKeysRequest) GetSort() string {
+	if m != nil {
+		return m.Sort
+	}
+	return ""
+}
+
+func (m *QueryKeysRequest) GetPage() int64 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *QueryKeysRequest) GetPerPage() int64 {
+	if m != nil {
+		return m.PerPage
+	}
+	return 0
+}
+
+type Keys struct {
+	Records              []*Key   `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	Total                int64    `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Page                 int64    `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	PerPage              int64    `protobuf:"varint,4,opt,name=per_page,json=perPage,proto3" json:"per_page,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Keys) Reset()         { *m = Keys{} }
+func (m *Keys) String() string { return proto.CompactTextString(m) }
+func (*Keys) ProtoMessage()    {}
+func (*Keys) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{21}
+}
+
+func (m *Keys) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Keys.Unmarshal(m, b)
+}
+func (m *Keys) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Keys.Marshal(b, m, deterministic)
+}
+func (m *Keys) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Keys.Merge(m, src)
+}
+func (m *Keys) XXX_Size() int {
+	return xxx_messageInfo_Keys.Size(m)
+}
+func (m *Keys) XXX_DiscardUnknown() {
+	xxx_messageInfo_Keys.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Keys proto.InternalMessageInfo
+
+func (m *Keys) GetRecords() []*Key {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
+func (m *Keys) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+func (m *Keys) GetPage() int64 {
+	if m != nil {
+		return m.Page
+	}
+	return 0
+}
+
+func (m *Keys) GetPerPage() int64 {
+	if m != nil {
+		return m.PerPage
+	}
+	return 0
+}
+
+// CheckKeysRequest asks which keys are granted to the caller, conditions of grants are evaluated against
+// attributes of the resource
+type CheckKeysRequest struct {
+	Keys                 []string          `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	Resource             map[string]string `protobuf:"bytes,2,rep,name=resource,proto3" json:"resource,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *CheckKeysRequest) Reset()         { *m = CheckKeysRequest{} }
+func (m *CheckKeysRequest) String() string { return proto.CompactTextString(m) }
+func (*CheckKeysRequest) ProtoMessage()    {}
+func (*CheckKeysRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{22}
+}
+
+func (m *CheckKeysRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CheckKeysRequest.Unmarshal(m, b)
+}
+func (m *CheckKeysRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CheckKeysRequest.Marshal(b, m, deterministic)
+}
+func (m *CheckKeysRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CheckKeysRequest.Merge(m, src)
+}
+func (m *CheckKeysRequest) XXX_Size() int {
+	return xxx_messageInfo_CheckKeysRequest.Size(m)
+}
+func (m *CheckKeysRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CheckKeysRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CheckKeysRequest proto.InternalMessageInfo
+
+func (m *CheckKeysRequest) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *CheckKeysRequest) GetResource() map[string]string {
+	if m != nil {
+		return m.Resource
+	}
+	return nil
+}
+
+type CheckedKeys struct {
+	Keys                 map[string]bool `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *CheckedKeys) Reset()         { *m = CheckedKeys{} }
+func (m *CheckedKeys) String() string { return proto.CompactTextString(m) }
+func (*CheckedKeys) ProtoMessage()    {}
+func (*CheckedKeys) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8bbd6f3875b0e874, []int{23}
+}
+
+func (m *CheckedKeys) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CheckedKeys.Unmarshal(m, b)
+}
+func (m *CheckedKeys) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CheckedKeys.Marshal(b, m, deterministic)
+}
+func (m *CheckedKeys) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CheckedKeys.Merge(m, src)
+}
+func (m *CheckedKeys) XXX_Size() int {
+	return xxx_messageInfo_CheckedKeys.Size(m)
+}
+func (m *CheckedKeys) XXX_DiscardUnknown() {
+	xxx_messageInfo_CheckedKeys.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CheckedKeys proto.InternalMessageInfo
+
+func (m *CheckedKeys) GetKeys() map[string]bool {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*LoginRequest)(nil), "auth.v1.LoginRequest")
+	proto.RegisterType((*Token)(nil), "auth.v1.Token")
+	proto.RegisterType((*User)(nil), "auth.v1.User")
+	proto.RegisterType((*AddUserRequest)(nil), "auth.v1.AddUserRequest")
+	proto.RegisterType((*GetUserRequest)(nil), "auth.v1.GetUserRequest")
+	proto.RegisterType((*ModifyUserRequest)(nil), "auth.v1.ModifyUserRequest")
+	proto.RegisterType((*QueryUsersRequest)(nil), "auth.v1.QueryUsersRequest")
+	proto.RegisterType((*Users)(nil), "auth.v1.Users")
+	proto.RegisterType((*AddBunchesToUserRequest)(nil), "auth.v1.AddBunchesToUserRequest")
+	proto.RegisterType((*Bunch)(nil), "auth.v1.Bunch")
+	proto.RegisterType((*AddBunchRequest)(nil), "auth.v1.AddBunchRequest")
+	proto.RegisterType((*GetBunchRequest)(nil), "auth.v1.GetBunchRequest")
+	proto.RegisterType((*ModifyBunchRequest)(nil), "auth.v1.ModifyBunchRequest")
+	proto.RegisterType((*QueryBunchesRequest)(nil), "auth.v1.QueryBunchesRequest")
+	proto.RegisterType((*Bunches)(nil), "auth.v1.Bunches")
+	proto.RegisterType((*AddKeysToBunchRequest)(nil), "auth.v1.AddKeysToBunchRequest")
+	proto.RegisterType((*Key)(nil), "auth.v1.Key")
+	proto.RegisterType((*AddKeyRequest)(nil), "auth.v1.AddKeyRequest")
+	proto.RegisterType((*GetKeyRequest)(nil), "auth.v1.GetKeyRequest")
+	proto.RegisterType((*ModifyKeyRequest)(nil), "auth.v1.ModifyKeyRequest")
+	proto.RegisterType((*QueryKeysRequest)(nil), "auth.v1.QueryKeysRequest")
+	proto.RegisterType((*Keys)(nil), "auth.v1.Keys")
+	proto.RegisterType((*CheckKeysRequest)(nil), "auth.v1.CheckKeysRequest")
+	proto.RegisterMapType((map[string]string)(nil), "auth.v1.CheckKeysRequest.ResourceEntry")
+	proto.RegisterType((*CheckedKeys)(nil), "auth.v1.CheckedKeys")
+	proto.RegisterMapType((map[string]bool)(nil), "auth.v1.CheckedKeys.KeysEntry")
+}
+
+func init() { proto.RegisterFile("auth.proto", fileDescriptor_8bbd6f3875b0e874) }
+
+var fileDescriptor_8bbd6f3875b0e874 = []byte{
+	// 1173 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x57, 0xdd, 0x6e, 0xdc, 0x44,
+	0x14, 0x96, 0x7f, 0xf6, 0xef, 0x6c, 0x92, 0x6e, 0x87, 0x34, 0x75, 0x5d, 0x14, 0x36, 0x96, 0xa0,
+	0x2b, 0x84, 0xb6, 0xea, 0x92, 0x8a, 0x16, 0x10, 0x62, 0x53, 0x55, 0x41, 0x6a, 0x11, 0x61, 0x15,
+	0xb8, 0xe0, 0x82, 0xc8, 0xb1, 0x4f, 0x37, 0xab, 0x6c, 0x76, 0x8c, 0x3d, 0xce, 0xb2, 0x3c, 0x01,
+	0x12, 0xb7, 0x3c, 0x00, 0x97, 0x48, 0x3c, 0x0b, 0x12, 0x12, 0x17, 0xbc, 0x0e, 0x9a, 0x19, 0xdb,
+	0x3b, 0xb6, 0xd7, 0xc9, 0x36, 0x54, 0xdc, 0xf9, 0xcc, 0x7c, 0x67, 0xe6, 0xfc, 0x7d, 0x67, 0x8e,
+	0x01, 0xdc, 0x98, 0x9d, 0xf5, 0x83, 0x90, 0x32, 0x4a, 0x1a, 0xe2, 0xfb, 0xf2, 0x91, 0x7d, 0x7f,
+	0x4c, 0xe9, 0x78, 0x8a, 0x0f, 0xc5, 0xf2, 0x69, 0xfc, 0xea, 0x21, 0x5e, 0x04, 0x6c, 0x21, 0x51,
+	0xf6, 0x3b, 0xc5, 0x4d, 0x36, 0xb9, 0xc0, 0x88, 0xb9, 0x17, 0x41, 0x02, 0xd8, 0x2d, 0x02, 0xe6,
+	0xa1, 0x1b, 0x04, 0x18, 0x46, 0x72, 0xdf, 0x39, 0x85, 0x8d, 0x97, 0x74, 0x3c, 0x99, 0x8d, 0xf0,
+	0x87, 0x18, 0x23, 0x46, 0x6c, 0x68, 0xc6, 0x11, 0x86, 0x33, 0xf7, 0x02, 0x2d, 0xad, 0xab, 0xf5,
+	0x5a, 0xa3, 0x4c, 0xe6, 0x7b, 0x81, 0x1b, 0x45, 0x73, 0x1a, 0xfa, 0x96, 0x2e, 0xf7, 0x52, 0x99,
+	0xef, 0xb9, 0xb1, 0x3f, 0xc1, 0x99, 0x87, 0x96, 0x21, 0xf7, 0x52, 0xd9, 0x79, 0x1f, 0x6a, 0xc7,
+	0xf4, 0x1c, 0x67, 0x64, 0x0f, 0x36, 0x5c, 0xcf, 0xc3, 0x28, 0x3a, 0x61, 0x5c, 0x4e, 0x2e, 0x68,
+	0xcb, 0x35, 0x01, 0x71, 0x7e, 0xd5, 0xc1, 0xfc, 0x26, 0xc2, 0x90, 0x6c, 0x81, 0x3e, 0xf1, 0x05,
+	0xc2, 0x18, 0xe9, 0x13, 0x3f, 0x67, 0x98, 0x5e, 0x30, 0x6c, 0x1b, 0x6a, 0x78, 0xe1, 0x4e, 0xa6,
+	0xc9, 0xcd, 0x52, 0x20, 0x3b, 0x50, 0x77, 0x3d, 0x36, 0xb9, 0x44, 0xcb, 0xec, 0x6a, 0xbd, 0xe6,
+	0x28, 0x91, 0x08, 0x01, 0x93, 0x2d, 0x02, 0xb4, 0x6a, 0x02, 0x2c, 0xbe, 0xf9, 0x09, 0x74, 0x3e,
+	0xc3, 0xd0, 0xaa, 0xcb, 0x13, 0x84, 0xc0, 0x91, 0x3e, 0x46, 0x9e, 0xd5, 0x90, 0x48, 0xfe, 0x4d,
+	0x9e, 0x02, 0x78, 0x21, 0xba, 0x0c, 0xfd, 0x13, 0x97, 0x59, 0xcd, 0xae, 0xd6, 0x6b, 0x0f, 0xec,
+	0xbe, 0x8c, 0x72, 0x3f, 0x8d, 0x72, 0xff, 0x38, 0x4d, 0xc3, 0xa8, 0x95, 0xa0, 0x87, 0x8c, 0xab,
+	0xc6, 0x81, 0x9f, 0xaa, 0xb6, 0xae, 0x57, 0x4d, 0xd0, 0x43, 0xe6, 0x7c, 0x0f, 0x5b, 0x43, 0xdf,
+	0xe7, 0x81, 0x59, 0x27, 0x51, 0x59, 0x3c, 0x74, 0x35, 0x1e, 0x6a, 0xfa, 0x8c, 0x7c, 0xfa, 0x9c,
+	0x0f, 0x60, 0xeb, 0x10, 0xd9, 0x9a, 0xe7, 0x3b, 0xff, 0x68, 0x70, 0xfb, 0x4b, 0xea, 0x4f, 0x5e,
+	0x2d, 0x54, 0x8d, 0x1d, 0xa8, 0x4f, 0x29, 0x3d, 0x8f, 0x83, 0x04, 0x9f, 0x48, 0x37, 0xc8, 0xdc,
+	0x1e, 0x6c, 0xcc, 0x70, 0x7e, 0x92, 0x59, 0x6b, 0xca, 0x3a, 0x99, 0xe1, 0xfc, 0x28, 0x59, 0xe2,
+	0x10, 0x3a, 0xf5, 0x97, 0x10, 0x99, 0xcc, 0x36, 0x9d, 0xfa, 0x19, 0x64, 0x90, 0xe5, 0xbf, 0x5e,
+	0x11, 0xea, 0x03, 0x4a, 0xa7, 0xdf, 0xba, 0xd3, 0x18, 0xd3, 0xda, 0x70, 0xfe, 0xd2, 0xe0, 0xf6,
+	0xd7, 0x31, 0x86, 0xc2, 0xb1, 0xe8, 0xe6, 0xb1, 0x5e, 0xde, 0x6d, 0xac, 0x7b, 0x77, 0x56, 0x97,
+	0xa6, 0x52, 0x97, 0x04, 0xcc, 0x88, 0x86, 0x2c, 0xad, 0x55, 0xfe, 0xcd, 0xd7, 0x02, 0x77, 0x2c,
+	0xbd, 0x32, 0x46, 0xe2, 0x9b, 0xdc, 0x83, 0x66, 0x80, 0xe1, 0x89, 0x58, 0x6f, 0x88, 0xf5, 0x46,
+	0x80, 0xe1, 0x91, 0x3b, 0x46, 0x27, 0x86, 0x9a, 0x70, 0x86, 0x3c, 0x80, 0x46, 0x88, 0x1e, 0x0d,
+	0xfd, 0xc8, 0xd2, 0xba, 0x46, 0xaf, 0x3d, 0xd8, 0xec, 0x27, 0x3d, 0xa6, 0x2f, 0xd2, 0x98, 0xee,
+	0x72, 0x97, 0x18, 0x65, 0xae, 0x74, 0xc9, 0x18, 0x49, 0x21, 0xbb, 0xd6, 0xa8, 0xb8, 0xd6, 0xcc,
+	0x5f, 0xfb, 0x15, 0xdc, 0x1d, 0xfa, 0xfe, 0x41, 0x3c, 0xf3, 0xce, 0x30, 0x3a, 0xa6, 0xeb, 0x96,
+	0xae, 0x05, 0x8d, 0x53, 0xa9, 0x63, 0xe9, 0x5d, 0xa3, 0xd7, 0x1a, 0xa5, 0xa2, 0xf3, 0xa7, 0x06,
+	0x35, 0x71, 0x5c, 0xa9, 0x35, 0x10, 0x30, 0x95, 0xe2, 0x12, 0xdf, 0x19, 0x75, 0x0d, 0x85, 0xba,
+	0x55, 0x0d, 0x21, 0x4f, 0xe9, 0xda, 0xcd, 0x29, 0x5d, 0x7f, 0x1d, 0x4a, 0x3f, 0x85, 0x5b, 0x69,
+	0x80, 0xd2, 0xc0, 0xa4, 0x8e, 0x68, 0x2b, 0x1c, 0xd1, 0x97, 0x8e, 0x38, 0xef, 0xc2, 0xad, 0x43,
+	0x64, 0xd7, 0xa9, 0x3a, 0x3f, 0x6b, 0x40, 0x24, 0x4d, 0x73, 0xd0, 0x2a, 0x9e, 0xae, 0x1b, 0xc6,
+	0x41, 0x2e, 0x8c, 0xeb, 0xf1, 0xea, 0x37, 0x0d, 0xde, 0x12, 0xbc, 0x4a, 0x0a, 0xe2, 0x2a, 0x8f,
+	0x97, 0xe7, 0xeb, 0xaf, 0xc3, 0x1d, 0xc1, 0x13, 0x63, 0x05, 0x4f, 0xcc, 0x8a, 0x82, 0xad, 0xe5,
+	0x0b, 0xf6, 0x47, 0x68, 0x24, 0xc6, 0x91, 0x5e, 0x91, 0x29, 0x5b, 0x19, 0x53, 0x64, 0x24, 0xdf,
+	0x2c, 0x55, 0x86, 0x70, 0x67, 0xe8, 0xfb, 0x2f, 0x70, 0x11, 0x1d, 0xd3, 0x5c, 0xa6, 0xb6, 0xa1,
+	0x26, 0xaa, 0x3f, 0x09, 0x8f, 0x14, 0xf8, 0xe9, 0xe7, 0xb8, 0x48, 0xf9, 0x21, 0xbe, 0x9d, 0xbf,
+	0x35, 0x30, 0x5e, 0xe0, 0xa2, 0x44, 0x8d, 0x0e, 0x18, 0xe7, 0xb8, 0x48, 0x52, 0xca, 0x3f, 0x49,
+	0x17, 0xda, 0x6e, 0x10, 0x4c, 0x27, 0x9e, 0xcb, 0x26, 0x74, 0x96, 0x04, 0x4c, 0x5d, 0xca, 0x72,
+	0x6e, 0x56, 0xbe, 0x7a, 0xff, 0x17, 0x45, 0x1e, 0xc3, 0xa6, 0x0c, 0x4c, 0x1a, 0x90, 0xc4, 0x1d,
+	0x6d, 0xe9, 0xce, 0x2a, 0x7a, 0xec, 0xc1, 0xe6, 0x21, 0xb2, 0xab, 0xd4, 0x9c, 0x23, 0xe8, 0x48,
+	0x66, 0x28, 0xa8, 0x2a, 0x5e, 0x94, 0x63, 0xb8, 0x82, 0x15, 0xce, 0x2f, 0x1a, 0x74, 0x44, 0x85,
+	0xf3, 0x3c, 0x5e, 0x55, 0xde, 0x85, 0x04, 0xe8, 0x2b, 0x13, 0xf0, 0x5f, 0x8b, 0x39, 0x02, 0x93,
+	0xdb, 0x41, 0xde, 0x2b, 0x56, 0xf2, 0x46, 0x56, 0xc9, 0xdc, 0xf3, 0x37, 0x5b, 0xc7, 0xbf, 0x6b,
+	0xd0, 0x79, 0x76, 0x86, 0xde, 0x79, 0x21, 0x04, 0xa2, 0x5a, 0xb5, 0x65, 0xb5, 0x92, 0x67, 0xd0,
+	0x0c, 0x31, 0xa2, 0x71, 0xe8, 0xa1, 0xa8, 0xe2, 0xf6, 0xe0, 0x41, 0x66, 0x56, 0xf1, 0x80, 0xfe,
+	0x28, 0x41, 0x3e, 0x9f, 0xb1, 0x70, 0x31, 0xca, 0x14, 0xed, 0x4f, 0x60, 0x33, 0xb7, 0xb5, 0xa2,
+	0x38, 0xb6, 0xa1, 0x76, 0xc9, 0xdb, 0x44, 0xfa, 0x36, 0x0b, 0xe1, 0x63, 0xfd, 0x89, 0xe6, 0xfc,
+	0x04, 0x6d, 0x71, 0x11, 0x0a, 0xda, 0x91, 0x81, 0x62, 0x64, 0x7b, 0xb0, 0x9b, 0x37, 0x46, 0x62,
+	0x78, 0xbc, 0x22, 0x69, 0x83, 0xc0, 0xda, 0x1f, 0x41, 0x2b, 0x5b, 0xba, 0xee, 0xee, 0xa6, 0x72,
+	0xf7, 0xe0, 0x8f, 0x06, 0x98, 0xc3, 0x98, 0x9d, 0x91, 0x3e, 0xd4, 0xc4, 0xec, 0x4d, 0xee, 0x64,
+	0x17, 0xaa, 0xb3, 0xb8, 0xbd, 0xec, 0x3a, 0x72, 0x7c, 0x7e, 0x04, 0x8d, 0x64, 0x08, 0x24, 0x77,
+	0xb3, 0xad, 0xfc, 0x58, 0x68, 0xe7, 0xdf, 0x74, 0xae, 0x92, 0xcc, 0x75, 0x8a, 0x4a, 0x7e, 0xd2,
+	0x2b, 0xaa, 0x7c, 0x0e, 0xb0, 0x9c, 0xed, 0x88, 0x9d, 0x6d, 0x96, 0x06, 0x3e, 0x7b, 0xa7, 0xc4,
+	0xe2, 0xe7, 0xfc, 0xd7, 0x84, 0x3c, 0x01, 0x58, 0xce, 0x50, 0xca, 0x09, 0xa5, 0xc1, 0x4a, 0xf1,
+	0x50, 0x62, 0x5f, 0x42, 0xa7, 0x38, 0x34, 0x90, 0xae, 0xea, 0xea, 0xaa, 0x79, 0xa2, 0xd2, 0x8e,
+	0x7d, 0x68, 0xa6, 0x2a, 0xc4, 0x2a, 0x9d, 0x52, 0xb6, 0x41, 0x22, 0xf7, 0xa1, 0x99, 0x3e, 0xae,
+	0x8a, 0x56, 0xe1, 0xbd, 0x2d, 0x69, 0x1d, 0x40, 0x5b, 0x79, 0x6a, 0xc9, 0xfd, 0x42, 0xd8, 0x72,
+	0xba, 0x55, 0xf6, 0x7e, 0x06, 0x1b, 0xea, 0x1b, 0x49, 0xde, 0xce, 0x47, 0x2e, 0xff, 0x74, 0xda,
+	0x9d, 0xbc, 0x05, 0x18, 0x91, 0x2f, 0xc4, 0x4f, 0x82, 0xf2, 0x8e, 0x90, 0x5d, 0xd5, 0xeb, 0xf2,
+	0x03, 0x53, 0x69, 0x49, 0x1f, 0xea, 0x52, 0x81, 0xec, 0x14, 0x4e, 0x48, 0x35, 0x73, 0x7d, 0x84,
+	0xe3, 0x65, 0xc7, 0x55, 0xf0, 0xb9, 0x16, 0x5c, 0xc0, 0xef, 0x43, 0x2b, 0x6b, 0xbf, 0xe4, 0x5e,
+	0x21, 0x56, 0x95, 0x5a, 0x8f, 0xa1, 0x95, 0x75, 0x58, 0x45, 0xab, 0xd8, 0x75, 0x95, 0x82, 0x16,
+	0xc8, 0x4f, 0xa1, 0x95, 0x35, 0x15, 0x45, 0xad, 0xd8, 0x68, 0xec, 0xed, 0x55, 0xb4, 0x3f, 0xa8,
+	0x7f, 0x67, 0x8e, 0xc3, 0xc0, 0x3b, 0xad, 0x8b, 0x10, 0x7d, 0xf8, 0xef, 0x00, 0x46, 0xa7, 0x1d,
+	0x94, 0xa4, 0x0f, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// AuthClient is the client API for Auth service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type AuthClient interface {
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*Token, error)
+	AddUser(ctx context.Context, in *AddUserRequest, opts ...grpc.CallOption) (*User, error)
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error)
+	ModifyUser(ctx context.Context, in *ModifyUserRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	QueryUsers(ctx context.Context, in *QueryUsersRequest, opts ...grpc.CallOption) (*Users, error)
+	AddBunchesToUser(ctx context.Context, in *AddBunchesToUserRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	AddBunch(ctx context.Context, in *AddBunchRequest, opts ...grpc.CallOption) (*Bunch, error)
+	GetBunch(ctx context.Context, in *GetBunchRequest, opts ...grpc.CallOption) (*Bunch, error)
+	ModifyBunch(ctx context.Context, in *ModifyBunchRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	QueryBunches(ctx context.Context, in *QueryBunchesRequest, opts ...grpc.CallOption) (*Bunches, error)
+	AddKeysToBunch(ctx context.Context, in *AddKeysToBunchRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	AddKey(ctx context.Context, in *AddKeyRequest, opts ...grpc.CallOption) (*Key, error)
+	GetKey(ctx context.Context, in *GetKeyRequest, opts ...grpc.CallOption) (*Key, error)
+	ModifyKey(ctx context.Context, in *ModifyKeyRequest, opts ...grpc.CallOption) (*Key, error)
+	QueryKeys(ctx context.Context, in *QueryKeysRequest, opts ...grpc.CallOption) (*Keys, error)
+	CheckKeys(ctx context.Context, in *CheckKeysRequest, opts ...grpc.CallOption) (*CheckedKeys, error)
+}
+
+type authClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAuthClient(cc *grpc.ClientConn) AuthClient {
+	return &authClient{cc}
+}
+
+func (c *authClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*Token, error) {
+	out := new(Token)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) AddUser(ctx context.Context, in *AddUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/AddUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/GetUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ModifyUser(ctx context.Context, in *ModifyUserRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/ModifyUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) QueryUsers(ctx context.Context, in *QueryUsersRequest, opts ...grpc.CallOption) (*Users, error) {
+	out := new(Users)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/QueryUsers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) AddBunchesToUser(ctx context.Context, in *AddBunchesToUserRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/AddBunchesToUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) AddBunch(ctx context.Context, in *AddBunchRequest, opts ...grpc.CallOption) (*Bunch, error) {
+	out := new(Bunch)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/AddBunch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) GetBunch(ctx context.Context, in *GetBunchRequest, opts ...grpc.CallOption) (*Bunch, error) {
+	out := new(Bunch)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/GetBunch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ModifyBunch(ctx context.Context, in *ModifyBunchRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/ModifyBunch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) QueryBunches(ctx context.Context, in *QueryBunchesRequest, opts ...grpc.CallOption) (*Bunches, error) {
+	out := new(Bunches)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/QueryBunches", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) AddKeysToBunch(ctx context.Context, in *AddKeysToBunchRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/AddKeysToBunch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) AddKey(ctx context.Context, in *AddKeyRequest, opts ...grpc.CallOption) (*Key, error) {
+	out := new(Key)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/AddKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) GetKey(ctx context.Context, in *GetKeyRequest, opts ...grpc.CallOption) (*Key, error) {
+	out := new(Key)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/GetKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ModifyKey(ctx context.Context, in *ModifyKeyRequest, opts ...grpc.CallOption) (*Key, error) {
+	out := new(Key)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/ModifyKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) QueryKeys(ctx context.Context, in *QueryKeysRequest, opts ...grpc.CallOption) (*Keys, error) {
+	out := new(Keys)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/QueryKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) CheckKeys(ctx context.Context, in *CheckKeysRequest, opts ...grpc.CallOption) (*CheckedKeys, error) {
+	out := new(CheckedKeys)
+	err := c.cc.Invoke(ctx, "/auth.v1.Auth/CheckKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AuthServer is the server API for Auth service.
+type AuthServer interface {
+	Login(context.Context, *LoginRequest) (*Token, error)
+	AddUser(context.Context, *AddUserRequest) (*User, error)
+	GetUser(context.Context, *GetUserRequest) (*User, error)
+	ModifyUser(context.Context, *ModifyUserRequest) (*empty.Empty, error)
+	QueryUsers(context.Context, *QueryUsersRequest) (*Users, error)
+	AddBunchesToUser(context.Context, *AddBunchesToUserRequest) (*empty.Empty, error)
+	AddBunch(context.Context, *AddBunchRequest) (*Bunch, error)
+	GetBunch(context.Context, *GetBunchRequest) (*Bunch, error)
+	ModifyBunch(context.Context, *ModifyBunchRequest) (*empty.Empty, error)
+	QueryBunches(context.Context, *QueryBunchesRequest) (*Bunches, error)
+	AddKeysToBunch(context.Context, *AddKeysToBunchRequest) (*empty.Empty, error)
+	AddKey(context.Context, *AddKeyRequest) (*Key, error)
+	GetKey(context.Context, *GetKeyRequest) (*Key, error)
+	ModifyKey(context.Context, *ModifyKeyRequest) (*Key, error)
+	QueryKeys(context.Context, *QueryKeysRequest) (*Keys, error)
+	CheckKeys(context.Context, *CheckKeysRequest) (*CheckedKeys, error)
+}
+
+func RegisterAuthServer(s *grpc.Server, srv AuthServer) {
+	s.RegisterService(&_Auth_serviceDesc, srv)
+}
+
+func _Auth_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_AddUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).AddUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/AddUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).AddUser(ctx, req.(*AddUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/GetUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ModifyUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModifyUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ModifyUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/ModifyUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ModifyUser(ctx, req.(*ModifyUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_QueryUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).QueryUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/QueryUsers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).QueryUsers(ctx, req.(*QueryUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_AddBunchesToUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddBunchesToUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).AddBunchesToUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/AddBunchesToUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).AddBunchesToUser(ctx, req.(*AddBunchesToUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_AddBunch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddBunchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).AddBunch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/AddBunch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).AddBunch(ctx, req.(*AddBunchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_GetBunch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBunchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GetBunch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/GetBunch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GetBunch(ctx, req.(*GetBunchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ModifyBunch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModifyBunchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ModifyBunch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/ModifyBunch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ModifyBunch(ctx, req.(*ModifyBunchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_QueryBunches_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryBunchesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).QueryBunches(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/QueryBunches",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).QueryBunches(ctx, req.(*QueryBunchesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_AddKeysToBunch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddKeysToBunchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).AddKeysToBunch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/AddKeysToBunch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).AddKeysToBunch(ctx, req.(*AddKeysToBunchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_AddKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).AddKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/AddKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).AddKey(ctx, req.(*AddKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_GetKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GetKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/GetKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GetKey(ctx, req.(*GetKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ModifyKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModifyKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ModifyKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/ModifyKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ModifyKey(ctx, req.(*ModifyKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_QueryKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).QueryKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/QueryKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).QueryKeys(ctx, req.(*QueryKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_CheckKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).CheckKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/auth.v1.Auth/CheckKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).CheckKeys(ctx, req.(*CheckKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Auth_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "auth.v1.Auth",
+	HandlerType: (*AuthServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Login",
+			Handler:    _Auth_Login_Handler,
+		},
+		{
+			MethodName: "AddUser",
+			Handler:    _Auth_AddUser_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _Auth_GetUser_Handler,
+		},
+		{
+			MethodName: "ModifyUser",
+			Handler:    _Auth_ModifyUser_Handler,
+		},
+		{
+			MethodName: "QueryUsers",
+			Handler:    _Auth_QueryUsers_Handler,
+		},
+		{
+			MethodName: "AddBunchesToUser",
+			Handler:    _Auth_AddBunchesToUser_Handler,
+		},
+		{
+			MethodName: "AddBunch",
+			Handler:    _Auth_AddBunch_Handler,
+		},
+		{
+			MethodName: "GetBunch",
+			Handler:    _Auth_GetBunch_Handler,
+		},
+		{
+			MethodName: "ModifyBunch",
+			Handler:    _Auth_ModifyBunch_Handler,
+		},
+		{
+			MethodName: "QueryBunches",
+			Handler:    _Auth_QueryBunches_Handler,
+		},
+		{
+			MethodName: "AddKeysToBunch",
+			Handler:    _Auth_AddKeysToBunch_Handler,
+		},
+		{
+			MethodName: "AddKey",
+			Handler:    _Auth_AddKey_Handler,
+		},
+		{
+			MethodName: "GetKey",
+			Handler:    _Auth_GetKey_Handler,
+		},
+		{
+			MethodName: "ModifyKey",
+			Handler:    _Auth_ModifyKey_Handler,
+		},
+		{
+			MethodName: "QueryKeys",
+			Handler:    _Auth_QueryKeys_Handler,
+		},
+		{
+			MethodName: "CheckKeys",
+			Handler:    _Auth_CheckKeys_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "auth.proto",
+}
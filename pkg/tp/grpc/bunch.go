@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+)
+
+func decodeAddingBunchRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*AddBunchRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.AddingBunch{
+		Name: req.Name,
+		Desc: req.Desc,
+	}, nil
+}
+
+func decodeGettingBunchRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*GetBunchRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return req.Name, nil
+}
+
+func decodeModifyingBunchRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*ModifyBunchRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.ModifyingBunch{
+		Lookup: req.Lookup,
+		Name:   req.Name,
+		Desc:   req.Desc,
+		Active: boolOf(req.Active),
+	}, nil
+}
+
+func decodeQueryingBunchRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*QueryBunchesRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.QueryingBunch{
+		Name:    req.Name,
+		Active:  nullBoolOf(req.Active),
+		Sort:    req.Sort,
+		Page:    req.Page,
+		PerPage: req.PerPage,
+	}, nil
+}
+
+func decodeAddingKeysToBunchRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*AddKeysToBunchRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.AddingKeysToBunch{
+		Keys:  req.Keys,
+		Bunch: req.Bunch,
+	}, nil
+}
+
+func encodeBunch(_ context.Context, response interface{}) (interface{}, error) {
+	b, ok := response.(*ep.Bunch)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return bunchOf(b), nil
+}
+
+func encodeBunches(_ context.Context, response interface{}) (interface{}, error) {
+	lst, ok := response.(*ep.Bunches)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	rows := make([]*Bunch, 0, len(lst.Records))
+	for _, b := range lst.Records {
+		rows = append(rows, bunchOf(b))
+	}
+
+	return &Bunches{
+		Records: rows,
+		Total:   lst.Total,
+		Page:    lst.Page,
+		PerPage: lst.PerPage,
+	}, nil
+}
+
+func bunchOf(b *ep.Bunch) *Bunch {
+	return &Bunch{
+		Id:        b.ID,
+		Name:      b.Name,
+		Desc:      b.Desc,
+		Active:    b.Active,
+		CreatedAt: timestampOf(b.CreatedAt),
+		UpdatedAt: timestampOf(b.UpdatedAt),
+	}
+}
@@ -0,0 +1,47 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+)
+
+func TestDecodeBunchRequests(t *testing.T) {
+	ctx := context.Background()
+	active := true
+
+	tests := []struct {
+		name    string
+		decode  func(context.Context, interface{}) (interface{}, error)
+		request interface{}
+		want    interface{}
+	}{
+		{"add", decodeAddingBunchRequest, &AddBunchRequest{Name: "staff_role", Desc: "Staff"},
+			&ep.AddingBunch{Name: "staff_role", Desc: "Staff"}},
+		{"get", decodeGettingBunchRequest, &GetBunchRequest{Name: "staff_role"}, "staff_role"},
+		{"modify", decodeModifyingBunchRequest, &ModifyBunchRequest{Lookup: "staff_role", Name: "sales_role",
+			Active: &wrappers.BoolValue{Value: true}},
+			&ep.ModifyingBunch{Lookup: "staff_role", Name: "sales_role", Active: &active}},
+		{"query", decodeQueryingBunchRequest, &QueryBunchesRequest{Name: "role", Active: &wrappers.BoolValue{},
+			Page: 1, PerPage: 20}, &ep.QueryingBunch{Name: "role", Active: sql.NullBool{Valid: true}, Page: 1,
+			PerPage: 20}},
+		{"add_keys", decodeAddingKeysToBunchRequest, &AddKeysToBunchRequest{Bunch: "staff_role",
+			Keys: []string{"get_user"}}, &ep.AddingKeysToBunch{Bunch: "staff_role", Keys: []string{"get_user"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.decode(ctx, test.request)
+			require.Nil(t, err)
+			require.Equal(t, test.want, got)
+
+			_, err = test.decode(ctx, &GetUserRequest{})
+			require.Equal(t, common.ErrWrongInputDatatype, err)
+		})
+	}
+}
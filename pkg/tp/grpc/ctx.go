@@ -0,0 +1,201 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/google/uuid"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/errcat"
+	"github.com/vespaiach/auth/pkg/i18n"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// tenantMetadata names the tenant which a call is made for, as the X-Tenant header does over http
+	tenantMetadata = "x-tenant"
+
+	// requestIDMetadata carries the id which a call is told by in logs and error details
+	requestIDMetadata = "x-request-id"
+
+	maxRequestIDLength = 128
+)
+
+// toStatus tells err with the catalog's message in the caller's language and the grpc code matching the
+// catalog's http status. The status carries the error's code and the request id as request info, the message as
+// a localized message and problems of fields as a bad request. Errors out of the catalog are logged with the
+// request id, callers are only told that the call failed.
+func toStatus(ctx context.Context, catalog *errcat.Catalog, err error) error {
+	switch err {
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	lang, _ := ctx.Value(common.LanguageContextKey).(string)
+	requestID, _ := ctx.Value(common.RequestIDContextKey).(string)
+
+	problem := catalog.Describe(err, lang)
+	if problem.Unknown {
+		log.Printf("request %s: %v", requestID, err)
+	}
+
+	st := status.New(codeOf(problem.Status), problem.Message)
+
+	details := []proto.Message{
+		&errdetails.RequestInfo{RequestId: requestID, ServingData: problem.Code},
+		&errdetails.LocalizedMessage{Locale: lang, Message: problem.Message},
+	}
+	if len(problem.Details) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(problem.Details))
+		for _, d := range problem.Details {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: d.Field,
+				Description: d.Message})
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// codeOf is the grpc code matching an http status of the catalog
+func codeOf(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
+
+// requestIDToContext keeps the id which the call is told by, the caller's own one from the x-request-id metadata
+// or a new one
+func requestIDToContext() kitgrpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		id := first(md, requestIDMetadata)
+		if len(id) == 0 || len(id) > maxRequestIDLength {
+			id = uuid.New().String()
+		}
+		return context.WithValue(ctx, common.RequestIDContextKey, id)
+	}
+}
+
+// languageToContext keeps the locale which messages are told in. The caller's preference carried by the bearer
+// token goes first, then languages of the accept-language metadata.
+func languageToContext(locales *i18n.Locales) kitgrpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		tags := i18n.ParseAcceptLanguage(first(md, "accept-language"))
+		if locale := ep.TokenLocale(ctx); len(locale) > 0 {
+			tags = append([]string{locale}, tags...)
+		}
+		return context.WithValue(ctx, common.LanguageContextKey, locales.Match(tags...))
+	}
+}
+
+func addToContext(s interface{}, key common.ContextKey) kitgrpc.ServerRequestFunc {
+	return func(ctx context.Context, _ metadata.MD) context.Context {
+		return context.WithValue(ctx, key, s)
+	}
+}
+
+func tenantToContext() kitgrpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		return context.WithValue(ctx, common.TenantHeaderContextKey, first(md, tenantMetadata))
+	}
+}
+
+// remoteAddrToContext keeps the caller's address, which conditions of grants may check
+func remoteAddrToContext() kitgrpc.ServerRequestFunc {
+	return func(ctx context.Context, _ metadata.MD) context.Context {
+		p, ok := peer.FromContext(ctx)
+		if !ok || p.Addr == nil {
+			return ctx
+		}
+
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return context.WithValue(ctx, common.RemoteAddrContextKey, host)
+	}
+}
+
+// auditToContext keeps the user agent and the called method, which audit events are told with
+func auditToContext() kitgrpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		method, _ := ctx.Value(kitgrpc.ContextKeyRequestMethod).(string)
+		ctx = context.WithValue(ctx, common.UserAgentContextKey, first(md, "user-agent"))
+		return context.WithValue(ctx, common.RequestPathContextKey, method)
+	}
+}
+
+func sessionToContext() kitgrpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		ctx = context.WithValue(ctx, common.ForwardedForContextKey, first(md, "x-forwarded-for"))
+		return context.WithValue(ctx, common.RealIPContextKey, first(md, "x-real-ip"))
+	}
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// encodeEmpty answers calls which give nothing back, their endpoints return true
+func encodeEmpty(_ context.Context, _ interface{}) (interface{}, error) {
+	return &empty.Empty{}, nil
+}
+
+func timestampOf(t time.Time) *timestamp.Timestamp {
+	ts, err := ptypes.TimestampProto(t)
+	if err != nil {
+		return nil
+	}
+	return ts
+}
+
+// nullBoolOf reads optional booleans, which are left out of filters when they're unset
+func nullBoolOf(v *wrappers.BoolValue) sql.NullBool {
+	if v == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: v.Value, Valid: true}
+}
+
+// boolOf reads optional booleans, which are kept unchanged by modifications when they're unset
+func boolOf(v *wrappers.BoolValue) *bool {
+	if v == nil {
+		return nil
+	}
+	b := v.Value
+	return &b
+}
@@ -0,0 +1,87 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/errcat"
+	"github.com/vespaiach/auth/pkg/i18n"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	locales, err := i18n.Load("../../../locales", "en")
+	require.Nil(t, err)
+	catalog, err := errcat.Load("../../../error.yml", locales)
+	require.Nil(t, err)
+
+	ctx := context.WithValue(context.Background(), common.RequestIDContextKey, "req-1")
+	ctx = context.WithValue(ctx, common.LanguageContextKey, "vi")
+
+	st := status.Convert(toStatus(ctx, catalog, common.ErrUserNotFound))
+	require.Equal(t, codes.NotFound, st.Code())
+	require.Equal(t, "người dùng không tồn tại", st.Message())
+	require.Equal(t, []interface{}{
+		&errdetails.RequestInfo{RequestId: "req-1", ServingData: "user_not_found"},
+		&errdetails.LocalizedMessage{Locale: "vi", Message: "người dùng không tồn tại"},
+	}, st.Details())
+
+	// problems of fields are told as a bad request
+	invalid := new(common.ValidationError)
+	invalid.Add("username", common.ErrUsernameInvalid)
+	invalid.Add("email", common.ErrEmailInvalid)
+
+	st = status.Convert(toStatus(context.Background(), catalog, invalid))
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	details := st.Details()
+	require.Len(t, details, 3)
+	violations := details[2].(*errdetails.BadRequest).FieldViolations
+	require.Len(t, violations, 2)
+	require.Equal(t, "username", violations[0].Field)
+	require.Equal(t, "username is invalid", violations[0].Description)
+	require.Equal(t, "email", violations[1].Field)
+
+	// messages of the storage aren't told to callers
+	st = status.Convert(toStatus(ctx, catalog, errors.New("Error 1146: Table 'auth.users' doesn't exist")))
+	require.Equal(t, codes.Internal, st.Code())
+	require.NotContains(t, st.Message(), "auth.users")
+
+	st = status.Convert(toStatus(ctx, catalog, context.DeadlineExceeded))
+	require.Equal(t, codes.DeadlineExceeded, st.Code())
+}
+
+func TestRequestIDToContext(t *testing.T) {
+	toID := requestIDToContext()
+
+	ctx := toID(context.Background(), metadata.Pairs(requestIDMetadata, "req-1"))
+	require.Equal(t, "req-1", ctx.Value(common.RequestIDContextKey))
+
+	ctx = toID(context.Background(), metadata.MD{})
+	id, _ := ctx.Value(common.RequestIDContextKey).(string)
+	require.NotEmpty(t, id)
+}
+
+func TestLanguageToContext(t *testing.T) {
+	locales := i18n.New("en")
+	require.Nil(t, locales.Add("vi", []byte("errors:\n  key_not_found: khóa không tồn tại\n")))
+	tolang := languageToContext(locales)
+
+	md := metadata.Pairs("accept-language", "fr;q=0.9, vi-VN;q=0.8, en;q=0.5")
+	require.Equal(t, "vi", tolang(context.Background(), md).Value(common.LanguageContextKey))
+
+	// the user's own locale goes before the metadata
+	token, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, ep.TokenClaims{Locale: "en-GB"}).
+		SignedString([]byte("secret"))
+	require.Nil(t, err)
+	ctx := context.WithValue(context.Background(), jwt.JWTTokenContextKey, token)
+	require.Equal(t, "en", tolang(ctx, md).Value(common.LanguageContextKey))
+}
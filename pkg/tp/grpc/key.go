@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+)
+
+func decodeAddingKeyRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*AddKeyRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.AddingKey{
+		Key:  req.Key,
+		Desc: req.Desc,
+	}, nil
+}
+
+func decodeGettingKeyRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*GetKeyRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return req.Key, nil
+}
+
+func decodeModifyingKeyRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*ModifyKeyRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.ModifyingKey{
+		Lookup: req.Lookup,
+		Key:    req.Key,
+		Desc:   req.Desc,
+	}, nil
+}
+
+func decodeQueryingKeyRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*QueryKeysRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.QueryingKey{
+		Name:        req.Name,
+		Application: req.Application,
+		Sort:        req.Sort,
+		Page:        req.Page,
+		PerPage:     req.PerPage,
+	}, nil
+}
+
+func decodeCheckingKeysRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*CheckKeysRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.CheckingKeys{
+		Keys:     req.Keys,
+		Resource: req.Resource,
+	}, nil
+}
+
+func encodeKey(_ context.Context, response interface{}) (interface{}, error) {
+	k, ok := response.(*ep.Key)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return keyOf(k), nil
+}
+
+func encodeKeys(_ context.Context, response interface{}) (interface{}, error) {
+	lst, ok := response.(*ep.Keys)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	rows := make([]*Key, 0, len(lst.Records))
+	for _, k := range lst.Records {
+		rows = append(rows, keyOf(k))
+	}
+
+	return &Keys{
+		Records: rows,
+		Total:   lst.Total,
+		Page:    lst.Page,
+		PerPage: lst.PerPage,
+	}, nil
+}
+
+func encodeCheckedKeys(_ context.Context, response interface{}) (interface{}, error) {
+	checked, ok := response.(*ep.CheckedKeys)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &CheckedKeys{Keys: checked.Keys}, nil
+}
+
+func keyOf(k *ep.Key) *Key {
+	return &Key{
+		Id:          k.ID,
+		Key:         k.Key,
+		Application: k.Application,
+		Desc:        k.Desc,
+		CreatedAt:   timestampOf(k.CreatedAt),
+		UpdatedAt:   timestampOf(k.UpdatedAt),
+	}
+}
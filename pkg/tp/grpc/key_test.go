@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+)
+
+func TestDecodeKeyRequests(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		decode  func(context.Context, interface{}) (interface{}, error)
+		request interface{}
+		want    interface{}
+	}{
+		{"add", decodeAddingKeyRequest, &AddKeyRequest{Key: "get_user", Desc: "Get user"},
+			&ep.AddingKey{Key: "get_user", Desc: "Get user"}},
+		{"get", decodeGettingKeyRequest, &GetKeyRequest{Key: "get_user"}, "get_user"},
+		{"modify", decodeModifyingKeyRequest, &ModifyKeyRequest{Lookup: "get_user", Key: "read_user"},
+			&ep.ModifyingKey{Lookup: "get_user", Key: "read_user"}},
+		{"query", decodeQueryingKeyRequest, &QueryKeysRequest{Name: "user", Application: "crm", Sort: "key",
+			Page: 1, PerPage: 20}, &ep.QueryingKey{Name: "user", Application: "crm", Sort: "key", Page: 1,
+			PerPage: 20}},
+		{"check", decodeCheckingKeysRequest, &CheckKeysRequest{Keys: []string{"get_user"},
+			Resource: map[string]string{"department": "sales"}}, &ep.CheckingKeys{Keys: []string{"get_user"},
+			Resource: map[string]string{"department": "sales"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.decode(ctx, test.request)
+			require.Nil(t, err)
+			require.Equal(t, test.want, got)
+
+			_, err = test.decode(ctx, &GetUserRequest{})
+			require.Equal(t, common.ErrWrongInputDatatype, err)
+		})
+	}
+}
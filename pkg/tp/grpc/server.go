@@ -0,0 +1,418 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/auth/jwt"
+	"github.com/go-kit/kit/endpoint"
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/authn"
+	"github.com/vespaiach/auth/pkg/backup"
+	"github.com/vespaiach/auth/pkg/bunchmgr"
+	"github.com/vespaiach/auth/pkg/cf"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/errcat"
+	"github.com/vespaiach/auth/pkg/i18n"
+	"github.com/vespaiach/auth/pkg/keymgr"
+	"github.com/vespaiach/auth/pkg/manifest"
+	"github.com/vespaiach/auth/pkg/rebac"
+	"github.com/vespaiach/auth/pkg/reviewmgr"
+	"github.com/vespaiach/auth/pkg/sessionmgr"
+	"github.com/vespaiach/auth/pkg/sso"
+	"github.com/vespaiach/auth/pkg/tenantmgr"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
+	"github.com/vespaiach/auth/pkg/usrmgr"
+	"github.com/vespaiach/auth/pkg/webhook"
+	"google.golang.org/grpc"
+)
+
+// auth.pb.go is protoc's output for auth.proto, it's generated again whenever auth.proto is changed
+//go:generate protoc --go_out=plugins=grpc:. auth.proto
+
+// method is a call of the Auth service. Its name is the name of the matching http route, so that the same
+// key is checked whichever transport the call comes through.
+type method struct {
+	name          string
+	endpoint      endpoint.Endpoint
+	middleware    []endpoint.Middleware
+	encoder       kitgrpc.EncodeResponseFunc
+	decoder       kitgrpc.DecodeRequestFunc
+	authorization bool
+	audited       bool
+}
+
+// List of methods
+var (
+	loginMethod = &method{
+		name:          "login",
+		endpoint:      ep.IssueTokenEndpoint,
+		middleware:    []endpoint.Middleware{ep.VerifyingUserMiddleware},
+		encoder:       encodeToken,
+		decoder:       decodeVerifyingUserRequest,
+		authorization: false,
+		audited:       true,
+	}
+	addUserMethod = &method{
+		name:          "add_user",
+		endpoint:      ep.AddingUserEndpoint,
+		encoder:       encodeUser,
+		decoder:       decodeAddingUserRequest,
+		authorization: true,
+		audited:       true,
+	}
+	getUserMethod = &method{
+		name:          "get_user",
+		endpoint:      ep.GettingUserEndpoint,
+		encoder:       encodeUser,
+		decoder:       decodeGettingUserRequest,
+		authorization: true,
+	}
+	modifyUserMethod = &method{
+		name:          "modify_user",
+		endpoint:      ep.ModifyingUserEndpoint,
+		encoder:       encodeEmpty,
+		decoder:       decodeModifyingUserRequest,
+		authorization: true,
+		audited:       true,
+	}
+	queryUserMethod = &method{
+		name:          "query_user",
+		endpoint:      ep.QueryingUserEndpoint,
+		encoder:       encodeUsers,
+		decoder:       decodeQueryingUserRequest,
+		authorization: true,
+	}
+	addBunchToUserMethod = &method{
+		name:          "add_bunch_to_user",
+		endpoint:      ep.AddingBunchesToUserEndpoint,
+		encoder:       encodeEmpty,
+		decoder:       decodeAddingBunchesToUserRequest,
+		authorization: true,
+		audited:       true,
+	}
+	addBunchMethod = &method{
+		name:          "add_bunch",
+		endpoint:      ep.AddingBunchEndpoint,
+		encoder:       encodeBunch,
+		decoder:       decodeAddingBunchRequest,
+		authorization: true,
+		audited:       true,
+	}
+	getBunchMethod = &method{
+		name:          "get_bunch",
+		endpoint:      ep.GettingBunchEndpoint,
+		encoder:       encodeBunch,
+		decoder:       decodeGettingBunchRequest,
+		authorization: true,
+	}
+	modifyBunchMethod = &method{
+		name:          "modify_bunch",
+		endpoint:      ep.ModifyingBunchEndpoint,
+		encoder:       encodeEmpty,
+		decoder:       decodeModifyingBunchRequest,
+		authorization: true,
+		audited:       true,
+	}
+	queryBunchMethod = &method{
+		name:          "query_bunch",
+		endpoint:      ep.QueryingBunchEndpoint,
+		encoder:       encodeBunches,
+		decoder:       decodeQueryingBunchRequest,
+		authorization: true,
+	}
+	addKeysToBunchMethod = &method{
+		name:          "add_keys_to_bunch",
+		endpoint:      ep.AddingKeysToBunchEndpoint,
+		encoder:       encodeEmpty,
+		decoder:       decodeAddingKeysToBunchRequest,
+		authorization: true,
+		audited:       true,
+	}
+	addKeyMethod = &method{
+		name:          "add_key",
+		endpoint:      ep.AddingKeyEndpoint,
+		encoder:       encodeKey,
+		decoder:       decodeAddingKeyRequest,
+		authorization: true,
+		audited:       true,
+	}
+	getKeyMethod = &method{
+		name:          "get_key",
+		endpoint:      ep.GettingKeyEndpoint,
+		encoder:       encodeKey,
+		decoder:       decodeGettingKeyRequest,
+		authorization: true,
+	}
+	modifyKeyMethod = &method{
+		name:          "modify_key",
+		endpoint:      ep.ModifyingKeyEndpoint,
+		encoder:       encodeKey,
+		decoder:       decodeModifyingKeyRequest,
+		authorization: true,
+		audited:       true,
+	}
+	queryKeyMethod = &method{
+		name:          "query_key",
+		endpoint:      ep.QueryingKeyEndpoint,
+		encoder:       encodeKeys,
+		decoder:       decodeQueryingKeyRequest,
+		authorization: true,
+	}
+	checkKeyMethod = &method{
+		name:          "check_key",
+		endpoint:      ep.CheckingKeysEndpoint,
+		encoder:       encodeCheckedKeys,
+		decoder:       decodeCheckingKeysRequest,
+		authorization: true,
+	}
+)
+
+// makeHandler wraps the endpoint with the same middleware as the http transport does
+func makeHandler(m *method, opts []kitgrpc.ServerOption) kitgrpc.Handler {
+	mids := make([]endpoint.Middleware, 0)
+
+	// calls refused for missing keys are audited too, so the audit goes before the key checker
+	if m.authorization {
+		mids = append(mids, ep.TokenParserMiddleware, ep.TenantMiddleware)
+		if m.audited {
+			mids = append(mids, ep.AuditMiddleware(m.name))
+		}
+		mids = append(mids, ep.KeyCheckerMiddleware(m.name))
+	} else {
+		mids = append(mids, ep.TenantMiddleware)
+		if m.audited {
+			mids = append(mids, ep.AuditMiddleware(m.name))
+		}
+	}
+
+	if m.middleware != nil {
+		mids = append(mids, m.middleware...)
+	}
+
+	return kitgrpc.NewServer(
+		endpoint.Chain(mids[0], mids[1:]...)(m.endpoint),
+		m.decoder,
+		m.encoder,
+		opts...,
+	)
+}
+
+type server struct {
+	login            kitgrpc.Handler
+	addUser          kitgrpc.Handler
+	getUser          kitgrpc.Handler
+	modifyUser       kitgrpc.Handler
+	queryUsers       kitgrpc.Handler
+	addBunchesToUser kitgrpc.Handler
+	addBunch         kitgrpc.Handler
+	getBunch         kitgrpc.Handler
+	modifyBunch      kitgrpc.Handler
+	queryBunches     kitgrpc.Handler
+	addKeysToBunch   kitgrpc.Handler
+	addKey           kitgrpc.Handler
+	getKey           kitgrpc.Handler
+	modifyKey        kitgrpc.Handler
+	queryKeys        kitgrpc.Handler
+	checkKeys        kitgrpc.Handler
+
+	catalog *errcat.Catalog
+}
+
+// CreateServer returns the grpc server of the Auth service, calls go through the endpoints of the http api.
+// Bearer tokens are read from the authorization metadata.
+func CreateServer(appConfig *cf.AppConfig, userServ usrmgr.Service, bunchServ bunchmgr.Service, keyServ keymgr.Service,
+	reviewServ reviewmgr.Service, manifestServ manifest.Service, backupServ backup.Service,
+	authenticator authn.Authenticator, ssoServ sso.Service, tokenServ tokenmgr.Service,
+	tenantServ tenantmgr.Service, relationServ rebac.Service, auditServ audit.Service,
+	sessionServ sessionmgr.Service, webhookServ webhook.Service, catalog *errcat.Catalog,
+	locales *i18n.Locales) *grpc.Server {
+	opts := []kitgrpc.ServerOption{
+		kitgrpc.ServerBefore(jwt.GRPCToContext()),
+		kitgrpc.ServerBefore(addToContext(appConfig, common.AppConfigContextKey)),
+		kitgrpc.ServerBefore(addToContext(userServ, common.UserManagementService)),
+		kitgrpc.ServerBefore(addToContext(bunchServ, common.BunchManagementService)),
+		kitgrpc.ServerBefore(addToContext(keyServ, common.KeyManagementService)),
+		kitgrpc.ServerBefore(addToContext(reviewServ, common.ReviewManagementService)),
+		kitgrpc.ServerBefore(addToContext(manifestServ, common.ManifestService)),
+		kitgrpc.ServerBefore(addToContext(backupServ, common.BackupService)),
+		kitgrpc.ServerBefore(addToContext(authenticator, common.AuthenticatorContextKey)),
+		kitgrpc.ServerBefore(addToContext(ssoServ, common.FederationService)),
+		kitgrpc.ServerBefore(addToContext(tokenServ, common.TokenManagementService)),
+		kitgrpc.ServerBefore(addToContext(tenantServ, common.TenantManagementService)),
+		kitgrpc.ServerBefore(addToContext(relationServ, common.RelationService)),
+		kitgrpc.ServerBefore(addToContext(auditServ, common.AuditService)),
+		kitgrpc.ServerBefore(addToContext(sessionServ, common.SessionService)),
+		kitgrpc.ServerBefore(addToContext(webhookServ, common.WebhookService)),
+		kitgrpc.ServerBefore(tenantToContext()),
+		kitgrpc.ServerBefore(remoteAddrToContext()),
+		kitgrpc.ServerBefore(auditToContext()),
+		kitgrpc.ServerBefore(sessionToContext()),
+		kitgrpc.ServerBefore(requestIDToContext()),
+		kitgrpc.ServerBefore(languageToContext(locales)),
+	}
+
+	srv := &server{
+		login:            makeHandler(loginMethod, opts),
+		addUser:          makeHandler(addUserMethod, opts),
+		getUser:          makeHandler(getUserMethod, opts),
+		modifyUser:       makeHandler(modifyUserMethod, opts),
+		queryUsers:       makeHandler(queryUserMethod, opts),
+		addBunchesToUser: makeHandler(addBunchToUserMethod, opts),
+		addBunch:         makeHandler(addBunchMethod, opts),
+		getBunch:         makeHandler(getBunchMethod, opts),
+		modifyBunch:      makeHandler(modifyBunchMethod, opts),
+		queryBunches:     makeHandler(queryBunchMethod, opts),
+		addKeysToBunch:   makeHandler(addKeysToBunchMethod, opts),
+		addKey:           makeHandler(addKeyMethod, opts),
+		getKey:           makeHandler(getKeyMethod, opts),
+		modifyKey:        makeHandler(modifyKeyMethod, opts),
+		queryKeys:        makeHandler(queryKeyMethod, opts),
+		checkKeys:        makeHandler(checkKeyMethod, opts),
+		catalog:          catalog,
+	}
+
+	// the interceptor names the called method, which audit events fall back to as their target
+	s := grpc.NewServer(grpc.UnaryInterceptor(kitgrpc.Interceptor))
+	RegisterAuthServer(s, srv)
+
+	return s
+}
+
+func (s *server) serve(ctx context.Context, h kitgrpc.Handler, req interface{}) (interface{}, error) {
+	ctx, rep, err := h.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, toStatus(ctx, s.catalog, err)
+	}
+	return rep, nil
+}
+
+func (s *server) Login(ctx context.Context, req *LoginRequest) (*Token, error) {
+	rep, err := s.serve(ctx, s.login, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*Token), nil
+}
+
+func (s *server) AddUser(ctx context.Context, req *AddUserRequest) (*User, error) {
+	rep, err := s.serve(ctx, s.addUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*User), nil
+}
+
+func (s *server) GetUser(ctx context.Context, req *GetUserRequest) (*User, error) {
+	rep, err := s.serve(ctx, s.getUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*User), nil
+}
+
+func (s *server) ModifyUser(ctx context.Context, req *ModifyUserRequest) (*empty.Empty, error) {
+	rep, err := s.serve(ctx, s.modifyUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*empty.Empty), nil
+}
+
+func (s *server) QueryUsers(ctx context.Context, req *QueryUsersRequest) (*Users, error) {
+	rep, err := s.serve(ctx, s.queryUsers, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*Users), nil
+}
+
+func (s *server) AddBunchesToUser(ctx context.Context, req *AddBunchesToUserRequest) (*empty.Empty, error) {
+	rep, err := s.serve(ctx, s.addBunchesToUser, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*empty.Empty), nil
+}
+
+func (s *server) AddBunch(ctx context.Context, req *AddBunchRequest) (*Bunch, error) {
+	rep, err := s.serve(ctx, s.addBunch, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*Bunch), nil
+}
+
+func (s *server) GetBunch(ctx context.Context, req *GetBunchRequest) (*Bunch, error) {
+	rep, err := s.serve(ctx, s.getBunch, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*Bunch), nil
+}
+
+func (s *server) ModifyBunch(ctx context.Context, req *ModifyBunchRequest) (*empty.Empty, error) {
+	rep, err := s.serve(ctx, s.modifyBunch, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*empty.Empty), nil
+}
+
+func (s *server) QueryBunches(ctx context.Context, req *QueryBunchesRequest) (*Bunches, error) {
+	rep, err := s.serve(ctx, s.queryBunches, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*Bunches), nil
+}
+
+func (s *server) AddKeysToBunch(ctx context.Context, req *AddKeysToBunchRequest) (*empty.Empty, error) {
+	rep, err := s.serve(ctx, s.addKeysToBunch, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*empty.Empty), nil
+}
+
+func (s *server) AddKey(ctx context.Context, req *AddKeyRequest) (*Key, error) {
+	rep, err := s.serve(ctx, s.addKey, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*Key), nil
+}
+
+func (s *server) GetKey(ctx context.Context, req *GetKeyRequest) (*Key, error) {
+	rep, err := s.serve(ctx, s.getKey, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*Key), nil
+}
+
+func (s *server) ModifyKey(ctx context.Context, req *ModifyKeyRequest) (*Key, error) {
+	rep, err := s.serve(ctx, s.modifyKey, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*Key), nil
+}
+
+func (s *server) QueryKeys(ctx context.Context, req *QueryKeysRequest) (*Keys, error) {
+	rep, err := s.serve(ctx, s.queryKeys, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*Keys), nil
+}
+
+func (s *server) CheckKeys(ctx context.Context, req *CheckKeysRequest) (*CheckedKeys, error) {
+	rep, err := s.serve(ctx, s.checkKeys, req)
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*CheckedKeys), nil
+}
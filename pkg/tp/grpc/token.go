@@ -0,0 +1,30 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+)
+
+func decodeVerifyingUserRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*LoginRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.VerifyingUser{
+		Username: req.Username,
+		Password: req.Password,
+		Audience: req.Audience,
+	}, nil
+}
+
+func encodeToken(_ context.Context, response interface{}) (interface{}, error) {
+	token, ok := response.(*ep.Token)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &Token{AccessToken: token.AccessToken}, nil
+}
@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+)
+
+func decodeAddingUserRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*AddUserRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.AddingUser{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+	}, nil
+}
+
+func decodeGettingUserRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*GetUserRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return req.Username, nil
+}
+
+func decodeModifyingUserRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*ModifyUserRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.ModifyingUser{
+		Lookup:      req.Lookup,
+		Username:    req.Username,
+		Email:       req.Email,
+		NewPassword: req.NewPassword,
+		OldPassword: req.OldPassword,
+		Active:      boolOf(req.Active),
+	}, nil
+}
+
+func decodeQueryingUserRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*QueryUsersRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.QueryingUser{
+		Username: req.Username,
+		Email:    req.Email,
+		Active:   nullBoolOf(req.Active),
+		Type:     req.Type,
+		Sort:     req.Sort,
+		Page:     req.Page,
+		PerPage:  req.PerPage,
+	}, nil
+}
+
+func decodeAddingBunchesToUserRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(*AddBunchesToUserRequest)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return &ep.AddingBunchesToUser{
+		Bunches:  req.Bunches,
+		Username: req.Username,
+	}, nil
+}
+
+func encodeUser(_ context.Context, response interface{}) (interface{}, error) {
+	u, ok := response.(*ep.User)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	return userOf(u), nil
+}
+
+func encodeUsers(_ context.Context, response interface{}) (interface{}, error) {
+	lst, ok := response.(*ep.Users)
+	if !ok {
+		return nil, common.ErrWrongInputDatatype
+	}
+
+	rows := make([]*User, 0, len(lst.Records))
+	for _, u := range lst.Records {
+		rows = append(rows, userOf(u))
+	}
+
+	return &Users{
+		Records: rows,
+		Total:   lst.Total,
+		Page:    lst.Page,
+		PerPage: lst.PerPage,
+	}, nil
+}
+
+func userOf(u *ep.User) *User {
+	return &User{
+		Id:        u.ID,
+		Username:  u.Username,
+		Email:     u.Email,
+		Active:    u.Active,
+		Type:      u.Type,
+		Owner:     u.Owner,
+		Desc:      u.Desc,
+		CreatedAt: timestampOf(u.CreatedAt),
+		UpdatedAt: timestampOf(u.UpdatedAt),
+	}
+}
@@ -0,0 +1,54 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+)
+
+func TestDecodeUserRequests(t *testing.T) {
+	ctx := context.Background()
+	active := false
+
+	tests := []struct {
+		name    string
+		decode  func(context.Context, interface{}) (interface{}, error)
+		request interface{}
+		want    interface{}
+	}{
+		{"add", decodeAddingUserRequest, &AddUserRequest{Username: "clerk", Email: "clerk@example.com",
+			Password: "secret"}, &ep.AddingUser{Username: "clerk", Email: "clerk@example.com", Password: "secret"}},
+		{"get", decodeGettingUserRequest, &GetUserRequest{Username: "clerk"}, "clerk"},
+		{"modify", decodeModifyingUserRequest, &ModifyUserRequest{Lookup: "clerk", Email: "new@example.com",
+			Active: &wrappers.BoolValue{Value: false}},
+			&ep.ModifyingUser{Lookup: "clerk", Email: "new@example.com", Active: &active}},
+		{"modify_active_unset", decodeModifyingUserRequest, &ModifyUserRequest{Lookup: "clerk"},
+			&ep.ModifyingUser{Lookup: "clerk"}},
+		{"query", decodeQueryingUserRequest, &QueryUsersRequest{Username: "cl", Active: &wrappers.BoolValue{
+			Value: true}, Sort: "-username", Page: 2, PerPage: 10}, &ep.QueryingUser{Username: "cl",
+			Active: sql.NullBool{Bool: true, Valid: true}, Sort: "-username", Page: 2, PerPage: 10}},
+		{"query_active_unset", decodeQueryingUserRequest, &QueryUsersRequest{}, &ep.QueryingUser{}},
+		{"add_bunches", decodeAddingBunchesToUserRequest, &AddBunchesToUserRequest{Username: "clerk",
+			Bunches: []string{"staff_role"}}, &ep.AddingBunchesToUser{Username: "clerk",
+			Bunches: []string{"staff_role"}}},
+		{"login", decodeVerifyingUserRequest, &LoginRequest{Username: "clerk", Password: "secret",
+			Audience: "crm"}, &ep.VerifyingUser{Username: "clerk", Password: "secret", Audience: "crm"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.decode(ctx, test.request)
+			require.Nil(t, err)
+			require.Equal(t, test.want, got)
+
+			// requests of other calls are refused
+			_, err = test.decode(ctx, &GetKeyRequest{})
+			require.Equal(t, common.ErrWrongInputDatatype, err)
+		})
+	}
+}
@@ -47,6 +47,11 @@ func decodeQueryingKeyRequest(_ context.Context, r *http.Request) (interface{},
 		data.Name = name[0]
 	}
 
+	application, aok := params["application"]
+	if aok && len(application) > 0 {
+		data.Application = application[0]
+	}
+
 	sort, sok := params["sort"]
 	if sok && len(sort) > 0 {
 		data.Sort = sort[0]
@@ -85,3 +90,14 @@ func decodeAddingKeyToBunchRequest(_ context.Context, r *http.Request) (interfac
 
 	return data, nil
 }
+
+func decodeCheckingKeysRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.CheckingKeys)
+
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,38 @@
+package tp
+
+import (
+	"context"
+	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/manifest"
+	"net/http"
+	"strconv"
+)
+
+func decodeApplyingManifestRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+	data := new(ep.ApplyingManifest)
+
+	m, err := manifest.Decode(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	data.Manifest = m
+
+	if dryRun := params.Get("dry_run"); len(dryRun) > 0 {
+		data.DryRun, err = strconv.ParseBool(dryRun)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if prune := params.Get("prune"); len(prune) > 0 {
+		data.Prune, err = strconv.ParseBool(prune)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data.PlanHash = params.Get("plan_hash")
+
+	return data, nil
+}
@@ -0,0 +1,419 @@
+package tp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// openAPIPath is where the api description is served, it isn't a route itself as it's made from the routes
+const openAPIPath = "/openapi.json"
+
+// Marker types of route responses which aren't written within the response envelope
+
+// redirect sends callers on to another location
+type redirect struct{}
+
+// stream writes server-sent events
+type stream struct{}
+
+// raw writes its data as is, without the envelope
+type raw struct{ data interface{} }
+
+// table writes its data within the envelope, or as csv when it's asked for with the format query
+type table struct{ data interface{} }
+
+// rows is a request body of csv, or of ndjson holding one data per line
+type rows struct{ data interface{} }
+
+var (
+	pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+	timeType         = reflect.TypeOf(time.Time{})
+	marshalerType    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+type openAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Servers    []openAPIServer                  `json:"servers"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type components struct {
+	Schemas         map[string]*schema         `json:"schemas"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat"`
+}
+
+type operation struct {
+	OperationID string                  `json:"operationId"`
+	Parameters  []*parameter            `json:"parameters"`
+	RequestBody *requestBody            `json:"requestBody,omitempty"`
+	Responses   map[string]*apiResponse `json:"responses"`
+
+	// Security names the key which the route needs, as a role of the bearer token
+	Security []map[string][]string `json:"security"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type apiResponse struct {
+	Description string                `json:"description"`
+	Headers     map[string]*header    `json:"headers,omitempty"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type header struct {
+	Schema *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+}
+
+// describeAPI returns the OpenAPI document of the routes. Routes sharing a path and a method are described once.
+func describeAPI(lst []*route) (*openAPI, error) {
+	doc := &openAPI{
+		OpenAPI: "3.1.0",
+		Info:    openAPIInfo{Title: "auth", Version: "1"},
+		Servers: []openAPIServer{{URL: "/v1"}},
+		Paths:   make(map[string]map[string]*operation),
+		Components: components{
+			Schemas: map[string]*schema{
+				"Error": {
+					Type: "object",
+					Properties: map[string]*schema{
+						"code":    {Type: "string"},
+						"message": {Type: "string"},
+						"details": {
+							Type: "array",
+							Items: &schema{
+								Type: "object",
+								Properties: map[string]*schema{
+									"field":   {Type: "string"},
+									"code":    {Type: "string"},
+									"message": {Type: "string"},
+								},
+							},
+						},
+						"request_id":   {Type: "string"},
+						"status":       {Type: "string"},
+						"responsed_at": {Type: "string"},
+					},
+				},
+			},
+			SecuritySchemes: map[string]*securityScheme{
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	ids := make(map[string]int)
+	for _, r := range lst {
+		method := strings.ToLower(r.method)
+		if _, ok := doc.Paths[r.path][method]; ok {
+			continue
+		}
+
+		op, err := describeRoute(doc, r)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %v", r.name, err)
+		}
+
+		// a route name may be shared by paths, operation ids can't be
+		ids[r.name]++
+		if ids[r.name] > 1 {
+			op.OperationID = fmt.Sprintf("%s_%d", r.name, ids[r.name])
+		}
+
+		if doc.Paths[r.path] == nil {
+			doc.Paths[r.path] = make(map[string]*operation)
+		}
+		doc.Paths[r.path][method] = op
+	}
+
+	return doc, nil
+}
+
+func describeRoute(doc *openAPI, r *route) (*operation, error) {
+	op := &operation{
+		OperationID: r.name,
+		Parameters:  make([]*parameter, 0),
+		Responses: map[string]*apiResponse{
+			"default": {
+				Description: "error",
+				Content:     jsonContent(&schema{Ref: "#/components/schemas/Error"}),
+			},
+		},
+		Security: make([]map[string][]string, 0),
+	}
+
+	if r.authorization {
+		op.Security = append(op.Security, map[string][]string{"bearer": {r.name}})
+	}
+
+	for _, m := range pathParamPattern.FindAllStringSubmatch(r.path, -1) {
+		op.Parameters = append(op.Parameters, &parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   &schema{Type: "string"},
+		})
+	}
+	op.Parameters = append(op.Parameters, &parameter{
+		Name:   tenantHeader,
+		In:     "header",
+		Schema: &schema{Type: "string"},
+	})
+
+	if r.request != nil {
+		body, err := describeRequest(doc, r.request)
+		if err != nil {
+			return nil, err
+		}
+		op.RequestBody = body
+	}
+
+	status, res, err := describeResponse(doc, r.response)
+	if err != nil {
+		return nil, err
+	}
+	op.Responses[status] = res
+
+	return op, nil
+}
+
+func describeRequest(doc *openAPI, request interface{}) (*requestBody, error) {
+	if lines, ok := request.(rows); ok {
+		s, err := doc.schemaOf(reflect.TypeOf(lines.data))
+		if err != nil {
+			return nil, err
+		}
+		return &requestBody{
+			Required: true,
+			Content: map[string]*mediaType{
+				"text/csv":             {Schema: &schema{Type: "string"}},
+				"application/x-ndjson": {Schema: s},
+			},
+		}, nil
+	}
+
+	s, err := doc.schemaOf(reflect.TypeOf(request))
+	if err != nil {
+		return nil, err
+	}
+	return &requestBody{Required: true, Content: jsonContent(s)}, nil
+}
+
+func describeResponse(doc *openAPI, response interface{}) (string, *apiResponse, error) {
+	switch data := response.(type) {
+	case redirect:
+		return "302", &apiResponse{
+			Description: "redirect",
+			Headers:     map[string]*header{"Location": {Schema: &schema{Type: "string"}}},
+		}, nil
+	case stream:
+		return "200", &apiResponse{
+			Description: "server-sent events",
+			Content:     map[string]*mediaType{"text/event-stream": {Schema: &schema{Type: "string"}}},
+		}, nil
+	case raw:
+		s, err := doc.schemaOf(reflect.TypeOf(data.data))
+		if err != nil {
+			return "", nil, err
+		}
+		return "200", &apiResponse{Description: "ok", Content: jsonContent(s)}, nil
+	case table:
+		s, err := doc.schemaOf(reflect.TypeOf(data.data))
+		if err != nil {
+			return "", nil, err
+		}
+		content := jsonContent(envelope(s))
+		content["text/csv"] = &mediaType{Schema: &schema{Type: "string"}}
+		return "200", &apiResponse{Description: "ok", Content: content}, nil
+	case nil:
+		return "200", &apiResponse{Description: "ok", Content: jsonContent(envelope(nil))}, nil
+	}
+
+	s, err := doc.schemaOf(reflect.TypeOf(response))
+	if err != nil {
+		return "", nil, err
+	}
+	return "200", &apiResponse{Description: "ok", Content: jsonContent(envelope(s))}, nil
+}
+
+// envelope is the object which responses are written within, see response of ctx.go
+func envelope(data *schema) *schema {
+	s := &schema{
+		Type: "object",
+		Properties: map[string]*schema{
+			"status":       {Type: "string"},
+			"code":         {Type: "integer"},
+			"responsed_at": {Type: "string"},
+		},
+	}
+	if data != nil {
+		s.Properties["data"] = data
+	}
+	return s
+}
+
+func jsonContent(s *schema) map[string]*mediaType {
+	return map[string]*mediaType{"application/json": {Schema: s}}
+}
+
+// schemaOf returns the json schema of values of t as they're written by encoding/json. Structs are added to
+// the components and referred to by their names, ep types go by their own names and other types are prefixed
+// with their packages unless they're named after them.
+func (doc *openAPI) schemaOf(t reflect.Type) (*schema, error) {
+	if t == timeType {
+		return &schema{Type: "string", Format: "date-time"}, nil
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		return &schema{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return doc.schemaOf(t.Elem())
+	case reflect.Bool:
+		return &schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32:
+		return &schema{Type: "integer", Format: "int32"}, nil
+	case reflect.Int64, reflect.Uint64:
+		return &schema{Type: "integer", Format: "int64"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}, nil
+	case reflect.String:
+		return &schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := doc.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := doc.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return doc.structSchemaOf(t)
+	}
+
+	return nil, fmt.Errorf("%s can't be described", t)
+}
+
+func (doc *openAPI) structSchemaOf(t reflect.Type) (*schema, error) {
+	name := t.Name()
+	if pkg := path.Base(t.PkgPath()); pkg != "ep" && !strings.EqualFold(pkg, name) {
+		name = strings.Title(pkg) + name
+	}
+	ref := &schema{Ref: "#/components/schemas/" + name}
+
+	if _, ok := doc.Components.Schemas[name]; ok {
+		return ref, nil
+	}
+
+	// it's added before its fields are described, so that recursive types refer to it
+	s := &schema{Type: "object", Properties: make(map[string]*schema)}
+	doc.Components.Schemas[name] = s
+
+	if err := doc.describeFields(s, t); err != nil {
+		return nil, err
+	}
+
+	return ref, nil
+}
+
+// describeFields adds fields with json tags to the properties of s, fields of embedded structs are added
+// as their own
+func (doc *openAPI) describeFields(s *schema, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag, ok := f.Tag.Lookup("json")
+		if !ok {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				if err := doc.describeFields(s, f.Type); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if name == "-" || len(f.PkgPath) > 0 {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+
+		fs, err := doc.schemaOf(f.Type)
+		if err != nil {
+			return err
+		}
+		s.Properties[name] = fs
+	}
+
+	return nil
+}
+
+// serveOpenAPI writes the OpenAPI document of the routes
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := describeAPI(routes)
+	if err != nil {
+		errorEncoder(nil)(r.Context(), err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(doc)
+}
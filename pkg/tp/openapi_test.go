@@ -0,0 +1,68 @@
+package tp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeAPI_Routes(t *testing.T) {
+	doc, err := describeAPI(routes)
+	require.Nil(t, err)
+
+	for _, r := range routes {
+		op, ok := doc.Paths[r.path][strings.ToLower(r.method)]
+		require.True(t, ok, "route %s: %s %s is missing from the spec", r.name, r.method, r.path)
+		require.True(t, strings.HasPrefix(op.OperationID, r.name), "route %s: operation is %s", r.name, op.OperationID)
+
+		if r.authorization {
+			require.Equal(t, []map[string][]string{{"bearer": {r.name}}}, op.Security, "route %s", r.name)
+		} else {
+			require.Empty(t, op.Security, "route %s", r.name)
+		}
+
+		for _, m := range pathParamPattern.FindAllStringSubmatch(r.path, -1) {
+			found := false
+			for _, p := range op.Parameters {
+				found = found || (p.In == "path" && p.Name == m[1])
+			}
+			require.True(t, found, "route %s: path parameter %s is missing", r.name, m[1])
+		}
+
+		require.Equal(t, r.request != nil, op.RequestBody != nil, "route %s", r.name)
+		require.NotEmpty(t, op.Responses, "route %s", r.name)
+	}
+}
+
+func TestDescribeAPI_Schemas(t *testing.T) {
+	doc, err := describeAPI(routes)
+	require.Nil(t, err)
+
+	// fields filled from the path aren't a part of bodies
+	user := doc.Components.Schemas["ModifyingUser"]
+	require.NotNil(t, user)
+	require.Contains(t, user.Properties, "new_password")
+	require.NotContains(t, user.Properties, "Lookup")
+
+	// fields of embedded structs are the struct's own
+	token := doc.Components.Schemas["AddingServiceAccountToken"]
+	require.NotNil(t, token)
+	require.Contains(t, token.Properties, "keys")
+
+	require.Equal(t, "date-time", doc.Components.Schemas["User"].Properties["created_at"].Format)
+	require.Contains(t, doc.Components.Schemas, "BackupDocument")
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	serveOpenAPI(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	doc := make(map[string]interface{})
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&doc))
+	require.Equal(t, "3.1.0", doc["openapi"])
+}
@@ -0,0 +1,25 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+)
+
+func decodeAddingPersonalTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.AddingPersonalToken)
+
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decodeRevokingPersonalTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
@@ -0,0 +1,69 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/rebac"
+	"net/http"
+)
+
+func decodeWritingNamespaceRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(rebac.Namespace)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Name = params["name"]
+
+	return data, nil
+}
+
+func decodeGettingNamespaceRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
+
+func decodeWritingRelationsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.WritingRelations)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decodeCheckingRelationRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.CheckingRelation)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decodeExpandingRelationRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+
+	return &ep.ExpandingRelation{
+		Object:   params.Get("object"),
+		Relation: params.Get("relation"),
+		Token:    params.Get("token"),
+	}, nil
+}
+
+func decodeListingObjectsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+
+	return &ep.ListingObjects{
+		Namespace: params.Get("namespace"),
+		Relation:  params.Get("relation"),
+		User:      params.Get("user"),
+		Token:     params.Get("token"),
+	}, nil
+}
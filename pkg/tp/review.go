@@ -0,0 +1,115 @@
+package tp
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+	"strconv"
+)
+
+func decodeStartingReviewRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.StartingReview)
+
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decodeGettingReviewRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	query := r.URL.Query()
+
+	id, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ep.GettingReview{
+		ID:       id,
+		Reviewer: query.Get("reviewer"),
+		Decision: query.Get("decision"),
+	}, nil
+}
+
+func decodeDecidingReviewItemRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(ep.DecidingReviewItem)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	data.CampaignID, err = strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	data.ItemID, err = strconv.ParseInt(params["item"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decodeClosingReviewRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return strconv.ParseInt(params["id"], 10, 64)
+}
+
+func decodeGettingReviewReportRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	id, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ep.GettingReviewReport{
+		ID:     id,
+		Format: r.URL.Query().Get("format"),
+	}, nil
+}
+
+// encodeReviewReportResponse writes report as a csv attachment when it's requested, otherwise as json
+func encodeReviewReportResponse(ctx context.Context, w http.ResponseWriter, data interface{}) error {
+	report, ok := data.(*ep.ReviewReport)
+	if !ok || report.Format != "csv" {
+		return encodeResponse(ctx, w, data)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"review_%d.csv\"", report.Campaign.ID))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "username", "bunch", "reviewer", "decision", "comment", "decided_at"})
+	for _, item := range report.Items {
+		var decidedAt string
+		if item.DecidedAt != nil {
+			decidedAt = item.DecidedAt.Format(common.TimeLayout)
+		}
+		writer.Write([]string{
+			strconv.FormatInt(item.ID, 10),
+			item.Username,
+			item.Bunch,
+			item.Reviewer,
+			item.Decision,
+			item.Comment,
+			decidedAt,
+		})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
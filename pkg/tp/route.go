@@ -6,12 +6,26 @@ import (
 	"github.com/go-kit/kit/transport/http"
 	kith "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/audit"
+	"github.com/vespaiach/auth/pkg/authn"
+	"github.com/vespaiach/auth/pkg/backup"
 	"github.com/vespaiach/auth/pkg/bunchmgr"
 	"github.com/vespaiach/auth/pkg/cf"
 	"github.com/vespaiach/auth/pkg/common"
 	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/errcat"
+	"github.com/vespaiach/auth/pkg/i18n"
 	"github.com/vespaiach/auth/pkg/keymgr"
+	"github.com/vespaiach/auth/pkg/manifest"
+	"github.com/vespaiach/auth/pkg/rebac"
+	"github.com/vespaiach/auth/pkg/reviewmgr"
+	"github.com/vespaiach/auth/pkg/scim"
+	"github.com/vespaiach/auth/pkg/sessionmgr"
+	"github.com/vespaiach/auth/pkg/sso"
+	"github.com/vespaiach/auth/pkg/tenantmgr"
+	"github.com/vespaiach/auth/pkg/tokenmgr"
 	"github.com/vespaiach/auth/pkg/usrmgr"
+	"github.com/vespaiach/auth/pkg/webhook"
 )
 
 type route struct {
@@ -23,6 +37,14 @@ type route struct {
 	encoder       http.EncodeResponseFunc
 	decoder       http.DecodeRequestFunc
 	authorization bool
+
+	// request is the json body which the route reads, it's nil when the route reads no body. Fields without
+	// a json tag are filled from the path or the query.
+	request interface{}
+
+	// response is the data which the route writes, within the response envelope unless it's a marker type
+	// of openapi.go. It's nil when the route writes no data.
+	response interface{}
 }
 
 // List of routes
@@ -36,6 +58,32 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeVerifyingUserUserRequest,
 		authorization: false,
+		request:       &ep.VerifyingUser{},
+		response:      &ep.Token{},
+	},
+	&route{
+		name:          "login_provider",
+		path:          "/login/{provider}",
+		method:        "GET",
+		endpoint:      ep.StartingFederatedLoginEndpoint,
+		middleware:    nil,
+		encoder:       encodeRedirectResponse,
+		decoder:       decodeStartingFederatedLoginRequest,
+		authorization: false,
+		request:       nil,
+		response:      redirect{},
+	},
+	&route{
+		name:          "login_provider_callback",
+		path:          "/login/{provider}/callback",
+		method:        "GET",
+		endpoint:      ep.IssueTokenEndpoint,
+		middleware:    []endpoint.Middleware{ep.FederatedUserMiddleware},
+		encoder:       encodeFederatedTokenResponse,
+		decoder:       decodeFederatedCallbackRequest,
+		authorization: false,
+		request:       nil,
+		response:      &ep.Token{},
 	},
 	&route{
 		name:          "add_user",
@@ -46,6 +94,32 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeAddingUserRequest,
 		authorization: true,
+		request:       &ep.AddingUser{},
+		response:      &ep.User{},
+	},
+	&route{
+		name:          "import_users",
+		path:          "/users/import",
+		method:        "POST",
+		endpoint:      ep.ImportingUsersEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeImportingUsersRequest,
+		authorization: true,
+		request:       rows{&usrmgr.ImportRow{}},
+		response:      &ep.UserImportReport{},
+	},
+	&route{
+		name:          "accept_invite",
+		path:          "/invites/accept",
+		method:        "POST",
+		endpoint:      ep.AcceptingInviteEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeAcceptingInviteRequest,
+		authorization: false,
+		request:       &ep.AcceptingInvite{},
+		response:      true,
 	},
 	&route{
 		name:          "modify_user",
@@ -56,6 +130,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeModifyingUserRequest,
 		authorization: true,
+		request:       &ep.ModifyingUser{},
+		response:      true,
 	},
 	&route{
 		name:          "get_user",
@@ -66,6 +142,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeGettingUserRequest,
 		authorization: true,
+		request:       nil,
+		response:      &ep.User{},
 	},
 	&route{
 		name:          "query_user",
@@ -76,6 +154,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeQueryingUserRequest,
 		authorization: true,
+		request:       nil,
+		response:      &ep.Users{},
 	},
 	&route{
 		name:          "query_user",
@@ -86,6 +166,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeQueryingUserRequest,
 		authorization: true,
+		request:       nil,
+		response:      &ep.Users{},
 	},
 	&route{
 		name:          "add_bunch_to_user",
@@ -96,6 +178,20 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeAddingBunchesToUserRequest,
 		authorization: true,
+		request:       &ep.AddingBunchesToUser{},
+		response:      true,
+	},
+	&route{
+		name:          "remove_bunch_from_user",
+		path:          "/users/{name}/bunches",
+		method:        "DELETE",
+		endpoint:      ep.RemovingBunchesFromUserEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeRemovingBunchesFromUserRequest,
+		authorization: true,
+		request:       &ep.RemovingBunchesFromUser{},
+		response:      true,
 	},
 	&route{
 		name:          "get_bunch_of_user",
@@ -106,6 +202,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeGettingBunchesOfUserRequest,
 		authorization: true,
+		request:       nil,
+		response:      []*ep.Bunch{},
 	},
 	&route{
 		name:          "get_key_of_user",
@@ -116,6 +214,92 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeGettingKeysOfUserRequest,
 		authorization: true,
+		request:       nil,
+		response:      []*ep.Key{},
+	},
+	&route{
+		name:          "modify_bunch_condition",
+		path:          "/users/{name}/bunches/{bunch}/condition",
+		method:        "PUT",
+		endpoint:      ep.ModifyingBunchConditionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeModifyingBunchConditionRequest,
+		authorization: true,
+		request:       &ep.ModifyingBunchCondition{},
+		response:      true,
+	},
+	&route{
+		name:          "get_user_attributes",
+		path:          "/users/{name}/attributes",
+		method:        "GET",
+		endpoint:      ep.GettingUserAttributesEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingUserAttributesRequest,
+		authorization: true,
+		request:       nil,
+		response:      map[string]string{},
+	},
+	&route{
+		name:          "modify_user_attributes",
+		path:          "/users/{name}/attributes",
+		method:        "PUT",
+		endpoint:      ep.ModifyingUserAttributesEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeModifyingUserAttributesRequest,
+		authorization: true,
+		request:       &ep.ModifyingUserAttributes{},
+		response:      true,
+	},
+	&route{
+		name:          "add_user_denials",
+		path:          "/users/{name}/denials",
+		method:        "POST",
+		endpoint:      ep.AddingUserDenialsEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeChangingUserDenialsRequest,
+		authorization: true,
+		request:       &ep.ChangingUserDenials{},
+		response:      true,
+	},
+	&route{
+		name:          "remove_user_denials",
+		path:          "/users/{name}/denials",
+		method:        "DELETE",
+		endpoint:      ep.RemovingUserDenialsEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeChangingUserDenialsRequest,
+		authorization: true,
+		request:       &ep.ChangingUserDenials{},
+		response:      true,
+	},
+	&route{
+		name:          "get_user_denials",
+		path:          "/users/{name}/denials",
+		method:        "GET",
+		endpoint:      ep.GettingUserDenialsEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingUserDenialsRequest,
+		authorization: true,
+		request:       nil,
+		response:      []*ep.Denial{},
+	},
+	&route{
+		name:          "explain_key",
+		path:          "/users/{name}/keys/{key}/explain",
+		method:        "GET",
+		endpoint:      ep.ExplainingKeyEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeExplainingKeyRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.Explanation{},
 	},
 	&route{
 		name:          "add_bunch",
@@ -126,6 +310,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeAddingBunchRequest,
 		authorization: true,
+		request:       &ep.AddingBunch{},
+		response:      &ep.Bunch{},
 	},
 	&route{
 		name:          "modify_bunch",
@@ -136,6 +322,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeModifyingBunchRequest,
 		authorization: true,
+		request:       &ep.ModifyingBunch{},
+		response:      true,
 	},
 	&route{
 		name:          "get_bunch",
@@ -146,6 +334,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeGettingBunchRequest,
 		authorization: true,
+		request:       nil,
+		response:      &ep.Bunch{},
 	},
 	&route{
 		name:          "query_bunch",
@@ -156,6 +346,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeQueryingBunchRequest,
 		authorization: true,
+		request:       nil,
+		response:      &ep.Bunches{},
 	},
 	&route{
 		name:          "add_keys_to_bunch",
@@ -166,6 +358,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeAddingKeysToBunchRequest,
 		authorization: true,
+		request:       &ep.AddingKeysToBunch{},
+		response:      true,
 	},
 	&route{
 		name:          "get_key_of_bunch",
@@ -176,6 +370,56 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeGettingKeysInBunchRequest,
 		authorization: true,
+		request:       nil,
+		response:      []*ep.Key{},
+	},
+	&route{
+		name:          "modify_key_condition",
+		path:          "/bunches/{name}/keys/{key}/condition",
+		method:        "PUT",
+		endpoint:      ep.ModifyingKeyConditionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeModifyingKeyConditionRequest,
+		authorization: true,
+		request:       &ep.ModifyingKeyCondition{},
+		response:      true,
+	},
+	&route{
+		name:          "add_bunch_denials",
+		path:          "/bunches/{name}/denials",
+		method:        "POST",
+		endpoint:      ep.AddingBunchDenialsEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeChangingBunchDenialsRequest,
+		authorization: true,
+		request:       &ep.ChangingBunchDenials{},
+		response:      true,
+	},
+	&route{
+		name:          "remove_bunch_denials",
+		path:          "/bunches/{name}/denials",
+		method:        "DELETE",
+		endpoint:      ep.RemovingBunchDenialsEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeChangingBunchDenialsRequest,
+		authorization: true,
+		request:       &ep.ChangingBunchDenials{},
+		response:      true,
+	},
+	&route{
+		name:          "get_bunch_denials",
+		path:          "/bunches/{name}/denials",
+		method:        "GET",
+		endpoint:      ep.GettingBunchDenialsEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingBunchDenialsRequest,
+		authorization: true,
+		request:       nil,
+		response:      []*ep.Key{},
 	},
 	&route{
 		name:          "add_key",
@@ -186,6 +430,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeAddingKeyRequest,
 		authorization: true,
+		request:       &ep.AddingKey{},
+		response:      &ep.Key{},
 	},
 	&route{
 		name:          "modify_key",
@@ -196,6 +442,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeModifyingKeyRequest,
 		authorization: true,
+		request:       &ep.ModifyingKey{},
+		response:      &ep.Key{},
 	},
 	&route{
 		name:          "get_key",
@@ -206,6 +454,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeGettingKeyRequest,
 		authorization: true,
+		request:       nil,
+		response:      &ep.Key{},
 	},
 	&route{
 		name:          "query_key",
@@ -216,6 +466,8 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeQueryingKeyRequest,
 		authorization: true,
+		request:       nil,
+		response:      &ep.Keys{},
 	},
 	&route{
 		name:          "add_key_to_bunch",
@@ -226,14 +478,674 @@ var routes = []*route{
 		encoder:       encodeResponse,
 		decoder:       decodeAddingKeyToBunchRequest,
 		authorization: true,
+		request:       &ep.AddingKeyToBunch{},
+		response:      int64(0),
+	},
+	&route{
+		name:          "check_key",
+		path:          "/check",
+		method:        "POST",
+		endpoint:      ep.CheckingKeysEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeCheckingKeysRequest,
+		authorization: true,
+		request:       &ep.CheckingKeys{},
+		response:      &ep.CheckedKeys{},
+	},
+	&route{
+		name:          "start_review",
+		path:          "/reviews",
+		method:        "POST",
+		endpoint:      ep.StartingReviewEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeStartingReviewRequest,
+		authorization: true,
+		request:       &ep.StartingReview{},
+		response:      &ep.ReviewCampaign{},
+	},
+	&route{
+		name:          "get_review",
+		path:          "/reviews/{id}",
+		method:        "GET",
+		endpoint:      ep.GettingReviewEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingReviewRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.ReviewCampaign{},
+	},
+	&route{
+		name:          "decide_review_item",
+		path:          "/reviews/{id}/items/{item}",
+		method:        "POST",
+		endpoint:      ep.DecidingReviewItemEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeDecidingReviewItemRequest,
+		authorization: true,
+		request:       &ep.DecidingReviewItem{},
+		response:      true,
+	},
+	&route{
+		name:          "close_review",
+		path:          "/reviews/{id}/close",
+		method:        "POST",
+		endpoint:      ep.ClosingReviewEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeClosingReviewRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.ReviewReport{},
+	},
+	&route{
+		name:          "get_review_report",
+		path:          "/reviews/{id}/report",
+		method:        "GET",
+		endpoint:      ep.GettingReviewReportEndpoint,
+		middleware:    nil,
+		encoder:       encodeReviewReportResponse,
+		decoder:       decodeGettingReviewReportRequest,
+		authorization: true,
+		request:       nil,
+		response:      table{&ep.ReviewReport{}},
+	},
+	&route{
+		name:          "add_exclusion",
+		path:          "/exclusions",
+		method:        "POST",
+		endpoint:      ep.AddingExclusionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeAddingExclusionRequest,
+		authorization: true,
+		request:       &ep.AddingExclusion{},
+		response:      &ep.Exclusion{},
+	},
+	&route{
+		name:          "query_exclusion",
+		path:          "/exclusions",
+		method:        "GET",
+		endpoint:      ep.QueryingExclusionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeEmptyRequest,
+		authorization: true,
+		request:       nil,
+		response:      []*ep.Exclusion{},
+	},
+	&route{
+		name:          "get_exclusion_violation",
+		path:          "/exclusions/violations",
+		method:        "GET",
+		endpoint:      ep.GettingExclusionViolationsEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeEmptyRequest,
+		authorization: true,
+		request:       nil,
+		response:      []*ep.Violation{},
+	},
+	&route{
+		name:          "get_exclusion",
+		path:          "/exclusions/{name}",
+		method:        "GET",
+		endpoint:      ep.GettingExclusionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingExclusionRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.Exclusion{},
+	},
+	&route{
+		name:          "remove_exclusion",
+		path:          "/exclusions/{name}",
+		method:        "DELETE",
+		endpoint:      ep.RemovingExclusionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingExclusionRequest,
+		authorization: true,
+		request:       nil,
+		response:      true,
+	},
+	&route{
+		name:          "apply_manifest",
+		path:          "/manifest",
+		method:        "POST",
+		endpoint:      ep.ApplyingManifestEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeApplyingManifestRequest,
+		authorization: true,
+		request:       &manifest.Manifest{},
+		response:      &ep.ManifestPlan{},
+	},
+	&route{
+		name:          "export_data",
+		path:          "/export",
+		method:        "GET",
+		endpoint:      ep.ExportingDataEndpoint,
+		middleware:    nil,
+		encoder:       encodeDocumentResponse,
+		decoder:       decodeExportingDataRequest,
+		authorization: true,
+		request:       nil,
+		response:      raw{&backup.Document{}},
+	},
+	&route{
+		name:          "import_data",
+		path:          "/import",
+		method:        "POST",
+		endpoint:      ep.ImportingDataEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeImportingDataRequest,
+		authorization: true,
+		request:       &backup.Document{},
+		response:      &backup.Result{},
+	},
+	&route{
+		name:          "add_token",
+		path:          "/tokens",
+		method:        "POST",
+		endpoint:      ep.AddingPersonalTokenEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeAddingPersonalTokenRequest,
+		authorization: true,
+		request:       &ep.AddingPersonalToken{},
+		response:      &ep.PersonalToken{},
+	},
+	&route{
+		name:          "query_token",
+		path:          "/tokens",
+		method:        "GET",
+		endpoint:      ep.QueryingPersonalTokenEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeEmptyRequest,
+		authorization: true,
+		request:       nil,
+		response:      []*ep.PersonalToken{},
+	},
+	&route{
+		name:          "revoke_token",
+		path:          "/tokens/{name}",
+		method:        "DELETE",
+		endpoint:      ep.RevokingPersonalTokenEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeRevokingPersonalTokenRequest,
+		authorization: true,
+		request:       nil,
+		response:      nil,
+	},
+	&route{
+		name:          "add_service_account",
+		path:          "/service-accounts",
+		method:        "POST",
+		endpoint:      ep.AddingServiceAccountEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeAddingServiceAccountRequest,
+		authorization: true,
+		request:       &ep.AddingServiceAccount{},
+		response:      &ep.User{},
+	},
+	&route{
+		name:          "modify_service_account",
+		path:          "/service-accounts/{name}",
+		method:        "PUT",
+		endpoint:      ep.ModifyingServiceAccountEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeModifyingServiceAccountRequest,
+		authorization: true,
+		request:       &ep.ModifyingServiceAccount{},
+		response:      true,
+	},
+	&route{
+		name:          "add_service_account_token",
+		path:          "/service-accounts/{name}/tokens",
+		method:        "POST",
+		endpoint:      ep.AddingServiceAccountTokenEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeAddingServiceAccountTokenRequest,
+		authorization: true,
+		request:       &ep.AddingServiceAccountToken{},
+		response:      &ep.PersonalToken{},
+	},
+	&route{
+		name:          "query_service_account_token",
+		path:          "/service-accounts/{name}/tokens",
+		method:        "GET",
+		endpoint:      ep.QueryingServiceAccountTokenEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeQueryingServiceAccountTokenRequest,
+		authorization: true,
+		request:       nil,
+		response:      []*ep.PersonalToken{},
+	},
+	&route{
+		name:          "revoke_service_account_token",
+		path:          "/service-accounts/{name}/tokens/{token}",
+		method:        "DELETE",
+		endpoint:      ep.RevokingServiceAccountTokenEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeRevokingServiceAccountTokenRequest,
+		authorization: true,
+		request:       nil,
+		response:      nil,
+	},
+	&route{
+		name:          "add_tenant",
+		path:          "/tenants",
+		method:        "POST",
+		endpoint:      ep.AddingTenantEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeAddingTenantRequest,
+		authorization: true,
+		request:       &ep.AddingTenant{},
+		response:      &ep.Tenant{},
+	},
+	&route{
+		name:          "modify_tenant",
+		path:          "/tenants/{name}",
+		method:        "PUT",
+		endpoint:      ep.ModifyingTenantEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeModifyingTenantRequest,
+		authorization: true,
+		request:       &ep.ModifyingTenant{},
+		response:      true,
+	},
+	&route{
+		name:          "get_tenant",
+		path:          "/tenants/{name}",
+		method:        "GET",
+		endpoint:      ep.GettingTenantEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingTenantRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.Tenant{},
+	},
+	&route{
+		name:          "query_tenant",
+		path:          "/tenants",
+		method:        "GET",
+		endpoint:      ep.QueryingTenantEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeQueryingTenantRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.Tenants{},
+	},
+	&route{
+		name:          "add_application",
+		path:          "/applications",
+		method:        "POST",
+		endpoint:      ep.AddingApplicationEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeAddingApplicationRequest,
+		authorization: true,
+		request:       &ep.AddingApplication{},
+		response:      &ep.Application{},
+	},
+	&route{
+		name:          "modify_application",
+		path:          "/applications/{name}",
+		method:        "PUT",
+		endpoint:      ep.ModifyingApplicationEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeModifyingApplicationRequest,
+		authorization: true,
+		request:       &ep.ModifyingApplication{},
+		response:      true,
+	},
+	&route{
+		name:          "get_application",
+		path:          "/applications/{name}",
+		method:        "GET",
+		endpoint:      ep.GettingApplicationEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingApplicationRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.Application{},
+	},
+	&route{
+		name:          "query_application",
+		path:          "/applications",
+		method:        "GET",
+		endpoint:      ep.QueryingApplicationEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeQueryingApplicationRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.Applications{},
+	},
+	&route{
+		name:          "write_namespace",
+		path:          "/namespaces/{name}",
+		method:        "PUT",
+		endpoint:      ep.WritingNamespaceEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeWritingNamespaceRequest,
+		authorization: true,
+		request:       &rebac.Namespace{},
+		response:      true,
+	},
+	&route{
+		name:          "get_namespace",
+		path:          "/namespaces/{name}",
+		method:        "GET",
+		endpoint:      ep.GettingNamespaceEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingNamespaceRequest,
+		authorization: true,
+		request:       nil,
+		response:      &rebac.Namespace{},
+	},
+	&route{
+		name:          "write_relations",
+		path:          "/relations",
+		method:        "POST",
+		endpoint:      ep.WritingRelationsEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeWritingRelationsRequest,
+		authorization: true,
+		request:       &ep.WritingRelations{},
+		response:      &ep.RelationToken{},
+	},
+	&route{
+		name:          "check_relation",
+		path:          "/relations/check",
+		method:        "POST",
+		endpoint:      ep.CheckingRelationEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeCheckingRelationRequest,
+		authorization: true,
+		request:       &ep.CheckingRelation{},
+		response:      &ep.CheckedRelation{},
+	},
+	&route{
+		name:          "expand_relation",
+		path:          "/relations/expand",
+		method:        "GET",
+		endpoint:      ep.ExpandingRelationEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeExpandingRelationRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.ExpandedRelation{},
+	},
+	&route{
+		name:          "list_objects",
+		path:          "/relations/objects",
+		method:        "GET",
+		endpoint:      ep.ListingObjectsEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeListingObjectsRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.ListedObjects{},
+	},
+	&route{
+		name:          "query_audit_event",
+		path:          "/audit/events",
+		method:        "GET",
+		endpoint:      ep.QueryingAuditEventEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeQueryingAuditEventRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.AuditEvents{},
+	},
+	&route{
+		name:          "export_audit_event",
+		path:          "/audit/events/export",
+		method:        "GET",
+		endpoint:      ep.ExportingAuditEventEndpoint,
+		middleware:    nil,
+		encoder:       encodeAuditExportResponse,
+		decoder:       decodeExportingAuditEventRequest,
+		authorization: true,
+		request:       nil,
+		response:      table{[]*ep.AuditEvent{}},
+	},
+	&route{
+		name:          "verify_audit_log",
+		path:          "/audit/verify",
+		method:        "GET",
+		endpoint:      ep.VerifyingAuditLogEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeVerifyingAuditLogRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.AuditVerification{},
+	},
+	&route{
+		name:          "query_own_session",
+		path:          "/me/sessions",
+		method:        "GET",
+		endpoint:      ep.QueryingOwnSessionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeQueryingOwnSessionRequest,
+		authorization: true,
+		request:       nil,
+		response:      []*ep.Session{},
+	},
+	&route{
+		name:          "terminate_own_session",
+		path:          "/me/sessions",
+		method:        "DELETE",
+		endpoint:      ep.TerminatingOwnSessionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeTerminatingOwnSessionRequest,
+		authorization: true,
+		request:       nil,
+		response:      nil,
+	},
+	&route{
+		name:          "terminate_own_session",
+		path:          "/me/sessions/{id}",
+		method:        "DELETE",
+		endpoint:      ep.TerminatingOwnSessionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeTerminatingOwnSessionRequest,
+		authorization: true,
+		request:       nil,
+		response:      nil,
+	},
+	&route{
+		name:          "query_user_session",
+		path:          "/users/{name}/sessions",
+		method:        "GET",
+		endpoint:      ep.QueryingUserSessionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeQueryingUserSessionRequest,
+		authorization: true,
+		request:       nil,
+		response:      []*ep.Session{},
+	},
+	&route{
+		name:          "terminate_user_session",
+		path:          "/users/{name}/sessions",
+		method:        "DELETE",
+		endpoint:      ep.TerminatingUserSessionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeTerminatingUserSessionRequest,
+		authorization: true,
+		request:       nil,
+		response:      nil,
+	},
+	&route{
+		name:          "terminate_user_session",
+		path:          "/users/{name}/sessions/{id}",
+		method:        "DELETE",
+		endpoint:      ep.TerminatingUserSessionEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeTerminatingUserSessionRequest,
+		authorization: true,
+		request:       nil,
+		response:      nil,
+	},
+	&route{
+		name:          "add_webhook",
+		path:          "/webhooks",
+		method:        "POST",
+		endpoint:      ep.AddingWebhookEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeAddingWebhookRequest,
+		authorization: true,
+		request:       &ep.AddingWebhook{},
+		response:      &ep.Webhook{},
+	},
+	&route{
+		name:          "query_webhook",
+		path:          "/webhooks",
+		method:        "GET",
+		endpoint:      ep.QueryingWebhookEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeQueryingWebhookRequest,
+		authorization: true,
+		request:       nil,
+		response:      []*ep.Webhook{},
+	},
+	&route{
+		name:          "get_webhook",
+		path:          "/webhooks/{name}",
+		method:        "GET",
+		endpoint:      ep.GettingWebhookEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingWebhookRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.Webhook{},
+	},
+	&route{
+		name:          "modify_webhook",
+		path:          "/webhooks/{name}",
+		method:        "PUT",
+		endpoint:      ep.ModifyingWebhookEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeModifyingWebhookRequest,
+		authorization: true,
+		request:       &ep.ModifyingWebhook{},
+		response:      true,
+	},
+	&route{
+		name:          "remove_webhook",
+		path:          "/webhooks/{name}",
+		method:        "DELETE",
+		endpoint:      ep.RemovingWebhookEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingWebhookRequest,
+		authorization: true,
+		request:       nil,
+		response:      true,
+	},
+	&route{
+		name:          "query_webhook_delivery",
+		path:          "/webhooks/{name}/deliveries",
+		method:        "GET",
+		endpoint:      ep.QueryingWebhookDeliveryEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeQueryingWebhookDeliveryRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.WebhookDeliveries{},
+	},
+	&route{
+		name:          "get_webhook_delivery",
+		path:          "/webhooks/{name}/deliveries/{id}",
+		method:        "GET",
+		endpoint:      ep.GettingWebhookDeliveryEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingWebhookDeliveryRequest,
+		authorization: true,
+		request:       nil,
+		response:      &ep.WebhookDelivery{},
+	},
+	&route{
+		name:          "retry_webhook_delivery",
+		path:          "/webhooks/{name}/deliveries/{id}/retry",
+		method:        "POST",
+		endpoint:      ep.RetryingWebhookDeliveryEndpoint,
+		middleware:    nil,
+		encoder:       encodeResponse,
+		decoder:       decodeGettingWebhookDeliveryRequest,
+		authorization: true,
+		request:       nil,
+		response:      true,
+	},
+	&route{
+		name:          "stream_event",
+		path:          "/events/stream",
+		method:        "GET",
+		endpoint:      ep.StreamingEventEndpoint,
+		middleware:    nil,
+		encoder:       encodeEventStream,
+		decoder:       decodeStreamingEventRequest,
+		authorization: true,
+		request:       nil,
+		response:      stream{},
 	},
 }
 
 func makeHandler(r *route, opts []kith.ServerOption) *kith.Server {
 	mids := make([]endpoint.Middleware, 0)
 
+	// calls refused for missing keys are audited too, so the audit goes before the key checker
 	if r.authorization {
-		mids = append(mids, ep.TokenParserMiddleware, ep.KeyCheckerMiddleware(r.name))
+		mids = append(mids, ep.TokenParserMiddleware, ep.TenantMiddleware)
+		if isAudited(r) {
+			mids = append(mids, ep.AuditMiddleware(r.name))
+		}
+		mids = append(mids, ep.KeyCheckerMiddleware(r.name))
+	} else {
+		mids = append(mids, ep.TenantMiddleware)
+		if isAudited(r) {
+			mids = append(mids, ep.AuditMiddleware(r.name))
+		}
 	}
 
 	if r.middleware != nil {
@@ -245,26 +1157,49 @@ func makeHandler(r *route, opts []kith.ServerOption) *kith.Server {
 			endpoint.Chain(mids[0], mids[1:]...)(r.endpoint),
 			r.decoder,
 			r.encoder,
-			opts...
+			opts...,
 		)
 	}
 	return kith.NewServer(
 		r.endpoint,
 		r.decoder,
 		r.encoder,
-		opts...
+		opts...,
 	)
 }
 
-func CreateRouter(appConfig *cf.AppConfig, userServ usrmgr.Service, bunchServ bunchmgr.Service, keyServ keymgr.Service) *mux.Router {
+func CreateRouter(appConfig *cf.AppConfig, userServ usrmgr.Service, bunchServ bunchmgr.Service, keyServ keymgr.Service,
+	reviewServ reviewmgr.Service, manifestServ manifest.Service, backupServ backup.Service,
+	authenticator authn.Authenticator, ssoServ sso.Service, tokenServ tokenmgr.Service,
+	tenantServ tenantmgr.Service, relationServ rebac.Service, auditServ audit.Service,
+	sessionServ sessionmgr.Service, webhookServ webhook.Service, catalog *errcat.Catalog,
+	locales *i18n.Locales) *mux.Router {
 	router := mux.NewRouter()
 	opts := []kith.ServerOption{
-		kith.ServerErrorEncoder(encodeError),
+		kith.ServerErrorEncoder(errorEncoder(catalog)),
+		kith.ServerBefore(requestIDToContext()),
+		kith.ServerAfter(requestIDToHeader()),
 		kith.ServerBefore(jwt.HTTPToContext()),
+		kith.ServerBefore(languageToContext(locales)),
 		kith.ServerBefore(addToContext(appConfig, common.AppConfigContextKey)),
 		kith.ServerBefore(addToContext(userServ, common.UserManagementService)),
 		kith.ServerBefore(addToContext(bunchServ, common.BunchManagementService)),
 		kith.ServerBefore(addToContext(keyServ, common.KeyManagementService)),
+		kith.ServerBefore(addToContext(reviewServ, common.ReviewManagementService)),
+		kith.ServerBefore(addToContext(manifestServ, common.ManifestService)),
+		kith.ServerBefore(addToContext(backupServ, common.BackupService)),
+		kith.ServerBefore(addToContext(authenticator, common.AuthenticatorContextKey)),
+		kith.ServerBefore(addToContext(ssoServ, common.FederationService)),
+		kith.ServerBefore(addToContext(tokenServ, common.TokenManagementService)),
+		kith.ServerBefore(addToContext(tenantServ, common.TenantManagementService)),
+		kith.ServerBefore(addToContext(relationServ, common.RelationService)),
+		kith.ServerBefore(addToContext(auditServ, common.AuditService)),
+		kith.ServerBefore(addToContext(sessionServ, common.SessionService)),
+		kith.ServerBefore(addToContext(webhookServ, common.WebhookService)),
+		kith.ServerBefore(tenantToContext()),
+		kith.ServerBefore(remoteAddrToContext()),
+		kith.ServerBefore(auditToContext()),
+		kith.ServerBefore(sessionToContext()),
 	}
 
 	for _, r := range routes {
@@ -274,5 +1209,14 @@ func CreateRouter(appConfig *cf.AppConfig, userServ usrmgr.Service, bunchServ bu
 			Handler(makeHandler(r, opts))
 	}
 
+	// the api is described from the routes, the description is open to callers without tokens
+	router.PathPrefix("/v1").
+		Path(openAPIPath).
+		Methods("GET").
+		HandlerFunc(serveOpenAPI)
+
+	// identity providers provision users of the default tenant
+	router.PathPrefix(scim.PathPrefix).Handler(scim.NewHandler(appConfig, userServ, bunchServ, auditServ))
+
 	return router
 }
@@ -0,0 +1,59 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+)
+
+func decodeAddingServiceAccountRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.AddingServiceAccount)
+
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decodeModifyingServiceAccountRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.ModifyingServiceAccount)
+	params := mux.Vars(r)
+
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Lookup = params["name"]
+
+	return data, nil
+}
+
+func decodeAddingServiceAccountTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.AddingServiceAccountToken)
+	params := mux.Vars(r)
+
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Account = params["name"]
+
+	return data, nil
+}
+
+func decodeQueryingServiceAccountTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
+
+func decodeRevokingServiceAccountTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return &ep.RevokingServiceAccountToken{
+		Account: params["name"],
+		Name:    params["token"],
+	}, nil
+}
@@ -0,0 +1,35 @@
+package tp
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+)
+
+func sessionToContext() func(ctx context.Context, r *http.Request) context.Context {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		ctx = context.WithValue(ctx, common.ForwardedForContextKey, r.Header.Get("X-Forwarded-For"))
+		return context.WithValue(ctx, common.RealIPContextKey, r.Header.Get("X-Real-IP"))
+	}
+}
+
+func decodeQueryingOwnSessionRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+func decodeTerminatingOwnSessionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return &ep.TerminatingSession{ID: params["id"]}, nil
+}
+
+func decodeQueryingUserSessionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
+
+func decodeTerminatingUserSessionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return &ep.TerminatingSession{Username: params["name"], ID: params["id"]}, nil
+}
@@ -0,0 +1,84 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+	"strconv"
+)
+
+// tenantHeader names the tenant which a request is made for
+const tenantHeader = "X-Tenant"
+
+func tenantToContext() func(ctx context.Context, r *http.Request) context.Context {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		return context.WithValue(ctx, common.TenantHeaderContextKey, r.Header.Get(tenantHeader))
+	}
+}
+
+func decodeAddingTenantRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.AddingTenant)
+
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decodeGettingTenantRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
+
+func decodeModifyingTenantRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(ep.ModifyingTenant)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Lookup = params["name"]
+
+	return data, nil
+}
+
+func decodeQueryingTenantRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+	data := &ep.QueryingTenant{}
+
+	name, nok := params["name"]
+	if nok && len(name) > 0 {
+		data.Name = name[0]
+	}
+
+	sort, sok := params["sort"]
+	if sok && len(sort) > 0 {
+		data.Sort = sort[0]
+	}
+
+	page, pok := params["page"]
+	if pok && len(page) > 0 {
+		intPage, err := strconv.ParseInt(page[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data.Page = intPage
+	}
+
+	perPage, ppok := params["per_page"]
+	if ppok && len(perPage) > 0 {
+		intPerPage, err := strconv.ParseInt(perPage[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data.PerPage = intPerPage
+	}
+
+	return data, nil
+}
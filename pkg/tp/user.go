@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"github.com/gorilla/mux"
 	"github.com/vespaiach/auth/pkg/ep"
+	"github.com/vespaiach/auth/pkg/usrmgr"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 func decodeAddingUserRequest(_ context.Context, r *http.Request) (interface{}, error) {
@@ -21,6 +23,43 @@ func decodeAddingUserRequest(_ context.Context, r *http.Request) (interface{}, e
 	return data, nil
 }
 
+// decodeImportingUsersRequest reads csv by default or ndjson when it's asked by format param or content type
+func decodeImportingUsersRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+	data := new(ep.ImportingUsers)
+
+	var err error
+	format := params.Get("format")
+	if len(format) == 0 && strings.Contains(r.Header.Get("Content-Type"), "ndjson") {
+		format = "ndjson"
+	}
+
+	if format == "ndjson" {
+		data.Rows, err = usrmgr.DecodeImportNDJSON(r.Body)
+	} else {
+		data.Rows, err = usrmgr.DecodeImportCSV(r.Body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun := params.Get("dry_run"); len(dryRun) > 0 {
+		data.DryRun, err = strconv.ParseBool(dryRun)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if batch := params.Get("batch_size"); len(batch) > 0 {
+		data.BatchSize, err = strconv.Atoi(batch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
 func decodeGettingUserRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	params := mux.Vars(r)
 	return params["name"], nil
@@ -65,6 +104,11 @@ func decodeQueryingUserRequest(_ context.Context, r *http.Request) (interface{},
 		}
 	}
 
+	userType, tok := params["type"]
+	if tok && len(userType) > 0 {
+		data.Type = userType[0]
+	}
+
 	sort, sok := params["sort"]
 	if sok && len(sort) > 0 {
 		data.Sort = sort[0]
@@ -104,6 +148,19 @@ func decodeAddingBunchesToUserRequest(_ context.Context, r *http.Request) (inter
 	return data, nil
 }
 
+func decodeRemovingBunchesFromUserRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(ep.RemovingBunchesFromUser)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Username = params["name"]
+
+	return data, nil
+}
+
 func decodeGettingBunchesOfUserRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	params := mux.Vars(r)
 	return params["name"], nil
@@ -114,6 +171,16 @@ func decodeGettingKeysOfUserRequest(_ context.Context, r *http.Request) (interfa
 	return params["name"], nil
 }
 
+func decodeAcceptingInviteRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.AcceptingInvite)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
 func decodeVerifyingUserUserRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	data := new(ep.VerifyingUser)
 	err := json.NewDecoder(r.Body).Decode(data)
@@ -0,0 +1,34 @@
+package tp
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/ep"
+)
+
+func TestDecodeImportingUsersRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/users/import?dry_run=true&batch_size=50",
+		strings.NewReader("username,email,password\nclerk,clerk@test.com,secret\n"))
+	req, err := decodeImportingUsersRequest(context.Background(), r)
+	require.Nil(t, err)
+	data := req.(*ep.ImportingUsers)
+	require.True(t, data.DryRun)
+	require.Equal(t, 50, data.BatchSize)
+	require.Equal(t, "clerk", data.Rows[0].Username)
+
+	// ndjson is read when the content type asks for it
+	r = httptest.NewRequest("POST", "/users/import",
+		strings.NewReader(`{"username": "clerk", "email": "clerk@test.com", "password": "secret"}`))
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	req, err = decodeImportingUsersRequest(context.Background(), r)
+	require.Nil(t, err)
+	require.Equal(t, "clerk@test.com", req.(*ep.ImportingUsers).Rows[0].Email)
+
+	r = httptest.NewRequest("POST", "/users/import?batch_size=many", strings.NewReader("username,email\n"))
+	_, err = decodeImportingUsersRequest(context.Background(), r)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,82 @@
+package tp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/vespaiach/auth/pkg/ep"
+	"net/http"
+	"strconv"
+)
+
+func decodeAddingWebhookRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	data := new(ep.AddingWebhook)
+
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decodeGettingWebhookRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+	return params["name"], nil
+}
+
+func decodeQueryingWebhookRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+func decodeModifyingWebhookRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	data := new(ep.ModifyingWebhook)
+	err := json.NewDecoder(r.Body).Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Lookup = params["name"]
+
+	return data, nil
+}
+
+func decodeQueryingWebhookDeliveryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := r.URL.Query()
+	data := &ep.QueryingWebhookDelivery{
+		Name:   mux.Vars(r)["name"],
+		Status: params.Get("status"),
+	}
+
+	page, pok := params["page"]
+	if pok && len(page) > 0 {
+		intPage, err := strconv.ParseInt(page[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data.Page = intPage
+	}
+
+	perPage, ppok := params["per_page"]
+	if ppok && len(perPage) > 0 {
+		intPerPage, err := strconv.ParseInt(perPage[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data.PerPage = intPerPage
+	}
+
+	return data, nil
+}
+
+func decodeGettingWebhookDeliveryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	params := mux.Vars(r)
+
+	id, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ep.GettingWebhookDelivery{Name: params["name"], ID: id}, nil
+}
@@ -0,0 +1,159 @@
+package usrmgr
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Import statuses of a row
+const (
+	ImportStatusValid   = "valid"
+	ImportStatusCreated = "created"
+	ImportStatusInvited = "invited"
+	ImportStatusFailed  = "failed"
+)
+
+// ImportRow is one user of a bulk import. A row without password is an invite: the user is created
+// with an unusable password hash and an invite, which the user accepts by setting a password.
+type ImportRow struct {
+	Line     int      `json:"-"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Password string   `json:"password"`
+	Bunches  []string `json:"bunches"`
+	Hash     string   `json:"-"`
+	BunchIDs []int64  `json:"-"`
+
+	// Invite is the hash of the row's invite, rows with a password have none
+	Invite          string    `json:"-"`
+	InviteExpiresAt time.Time `json:"-"`
+}
+
+// ImportResult is the outcome of a row, Invite is the plain invite of an invited row. It's told only here.
+type ImportResult struct {
+	Line     int
+	Username string
+	Status   string
+	Error    error
+	Invite   string
+}
+
+type ImportOptions struct {
+	DryRun     bool
+	BatchSize  int
+	BcryptCost int
+}
+
+var errMissingCSVColumn = errors.New("csv header must have username and email columns")
+
+// DecodeImportCSV reads rows from csv with a header line. Columns are username, email, password and
+// bunches, bunches are separated by "|".
+func DecodeImportCSV(r io.Reader) ([]*ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, errMissingCSVColumn
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errMissingCSVColumn
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]*ImportRow, 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := &ImportRow{
+			Line:     line,
+			Username: field(record, "username"),
+			Email:    field(record, "email"),
+			Password: field(record, "password"),
+		}
+		if bunches := field(record, "bunches"); len(bunches) > 0 {
+			row.Bunches = strings.Split(bunches, "|")
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// DecodeImportNDJSON reads rows from newline delimited json objects
+func DecodeImportNDJSON(r io.Reader) ([]*ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	rows := make([]*ImportRow, 0)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 {
+			continue
+		}
+
+		row := new(ImportRow)
+		if err := json.Unmarshal([]byte(text), row); err != nil {
+			return nil, err
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// hashImportRows hashes passwords, invited rows get a hash of random bytes which no password matches and an
+// invite, their plain invites are returned by the rows' indexes
+func hashImportRows(rows []*ImportRow, cost int) (map[int]string, error) {
+	invites := make(map[int]string)
+	for i, row := range rows {
+		hash, err := HashPassword(row.Password, cost)
+		if err != nil {
+			return nil, err
+		}
+		row.Hash = hash
+
+		if len(row.Password) == 0 {
+			plain, inviteHash, err := newInvite()
+			if err != nil {
+				return nil, err
+			}
+			row.Invite = inviteHash
+			row.InviteExpiresAt = time.Now().Add(InviteDuration)
+			invites[i] = plain
+		}
+	}
+
+	return invites, nil
+}
@@ -0,0 +1,172 @@
+package usrmgr
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// store is a Storer which knows a single user, imported rows and their invites are kept
+type store struct {
+	Storer
+	imported []*ImportRow
+	accepted map[int64]string
+}
+
+func (st *store) GetUserByUsername(username string) (*User, error) {
+	if username == "admin" {
+		return &User{ID: 1, Username: username, Email: "admin@test.com"}, nil
+	}
+	return nil, nil
+}
+
+func (st *store) GetUserByEmail(email string) (*User, error) {
+	if email == "admin@test.com" {
+		return &User{ID: 1, Username: "admin", Email: email}, nil
+	}
+	return nil, nil
+}
+
+func (st *store) ImportUsers(rows []*ImportRow) error {
+	st.imported = append(st.imported, rows...)
+	return nil
+}
+
+func (st *store) GetInvite(hash string) (*Invite, error) {
+	for i, row := range st.imported {
+		if row.Invite == hash {
+			return &Invite{UserID: int64(i + 2), TenantID: common.DefaultTenant, Hash: hash,
+				ExpiresAt: row.InviteExpiresAt}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (st *store) AcceptInvite(userID int64, inviteHash string, hash string) error {
+	if st.accepted == nil {
+		st.accepted = make(map[int64]string)
+	}
+	st.accepted[userID] = hash
+	st.imported[userID-2].Invite = ""
+	return nil
+}
+
+func (st *store) WithTenant(tenantID int64) Storer {
+	return st
+}
+
+func TestDecodeImportCSV(t *testing.T) {
+	rows, err := DecodeImportCSV(strings.NewReader("Email, username,bunches,password\n" +
+		"clerk@test.com, clerk, staff_role|sales_role, secret\n" +
+		"buyer@test.com,buyer\n"))
+	require.Nil(t, err)
+	require.Equal(t, []*ImportRow{
+		{Line: 2, Username: "clerk", Email: "clerk@test.com", Password: "secret",
+			Bunches: []string{"staff_role", "sales_role"}},
+		{Line: 3, Username: "buyer", Email: "buyer@test.com"},
+	}, rows)
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"email_column_missing", "username,password\nclerk,secret\n"},
+		{"username_column_missing", "email\nclerk@test.com\n"},
+		{"empty", ""},
+		{"quote_broken", "username,email\n\"clerk,clerk@test.com\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeImportCSV(strings.NewReader(test.content))
+			require.NotNil(t, err)
+		})
+	}
+}
+
+func TestDecodeImportNDJSON(t *testing.T) {
+	rows, err := DecodeImportNDJSON(strings.NewReader(
+		`{"username": "clerk", "email": "clerk@test.com", "password": "secret", "bunches": ["staff_role"]}` +
+			"\n\n" + `{"username": "buyer", "email": "buyer@test.com", "hash": "forged"}` + "\n"))
+	require.Nil(t, err)
+
+	// lines are counted with blank ones, hashes aren't read from rows
+	require.Equal(t, []*ImportRow{
+		{Line: 1, Username: "clerk", Email: "clerk@test.com", Password: "secret", Bunches: []string{"staff_role"}},
+		{Line: 3, Username: "buyer", Email: "buyer@test.com"},
+	}, rows)
+
+	_, err = DecodeImportNDJSON(strings.NewReader(`{"username": "clerk"` + "\n"))
+	require.NotNil(t, err)
+}
+
+func TestService_ImportUsers(t *testing.T) {
+	st := &store{}
+	s := NewService(st)
+
+	rows := []*ImportRow{
+		{Line: 1, Username: "clerk", Email: "clerk@test.com", Password: "secret"},
+		{Line: 2, Username: "buyer", Email: "buyer@test.com"},
+		{Line: 3, Username: "admin", Email: "other@test.com", Password: "secret"},
+		{Line: 4, Username: "seller", Email: "clerk@test.com", Password: "secret"},
+		{Line: 5, Username: "Bad Name", Email: "bad@test.com", Password: "secret"},
+	}
+
+	results, err := s.ImportUsers(rows, &ImportOptions{DryRun: true, BcryptCost: bcrypt.MinCost})
+	require.Nil(t, err)
+	require.Len(t, st.imported, 0)
+
+	statuses := make([]string, 0, len(results))
+	errs := make([]error, 0, len(results))
+	for _, r := range results {
+		statuses = append(statuses, r.Status)
+		errs = append(errs, r.Error)
+	}
+	require.Equal(t, []string{ImportStatusValid, ImportStatusValid, ImportStatusFailed, ImportStatusFailed,
+		ImportStatusFailed}, statuses)
+	require.Equal(t, []error{nil, nil, common.ErrDuplicatedUsername, common.ErrDuplicatedEmail,
+		common.ErrUsernameInvalid}, errs)
+
+	results, err = s.ImportUsers(rows, &ImportOptions{BcryptCost: bcrypt.MinCost})
+	require.Nil(t, err)
+	require.Equal(t, ImportStatusCreated, results[0].Status)
+	require.Empty(t, results[0].Invite)
+	require.Len(t, st.imported, 2)
+	require.Nil(t, bcrypt.CompareHashAndPassword([]byte(st.imported[0].Hash), []byte("secret")))
+
+	// rows without a password are invited, with a hash which no password matches
+	require.Equal(t, ImportStatusInvited, results[1].Status)
+	require.True(t, strings.HasPrefix(results[1].Invite, InvitePrefix))
+	require.Equal(t, hashInvite(results[1].Invite), st.imported[1].Invite)
+	require.NotNil(t, bcrypt.CompareHashAndPassword([]byte(st.imported[1].Hash), []byte("")))
+}
+
+func TestService_AcceptInvite(t *testing.T) {
+	st := &store{}
+	s := NewService(st)
+
+	results, err := s.ImportUsers([]*ImportRow{{Line: 1, Username: "buyer", Email: "buyer@test.com"}},
+		&ImportOptions{BcryptCost: bcrypt.MinCost})
+	require.Nil(t, err)
+	invite := results[0].Invite
+
+	require.Equal(t, common.ErrMissingHash, s.AcceptInvite(invite, ""))
+	require.Equal(t, common.ErrInviteInvalid, s.AcceptInvite(InvitePrefix+"unknown", "hash"))
+
+	require.Nil(t, s.AcceptInvite(invite, "hash"))
+	require.Equal(t, map[int64]string{2: "hash"}, st.accepted)
+
+	// an invite is accepted once
+	require.Equal(t, common.ErrInviteInvalid, s.AcceptInvite(invite, "other"))
+
+	// nor after it has expired
+	results, err = s.ImportUsers([]*ImportRow{{Line: 1, Username: "seller", Email: "seller@test.com"}},
+		&ImportOptions{BcryptCost: bcrypt.MinCost})
+	require.Nil(t, err)
+	st.imported[1].InviteExpiresAt = time.Now().Add(-time.Minute)
+	require.Equal(t, common.ErrInviteInvalid, s.AcceptInvite(results[0].Invite, "hash"))
+}
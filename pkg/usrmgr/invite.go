@@ -0,0 +1,40 @@
+package usrmgr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// InvitePrefix starts every invite, so that invites are told apart from other secrets
+const InvitePrefix = "ainv_"
+
+// InviteDuration is how long an invite can be accepted for
+const InviteDuration = 7 * 24 * time.Hour
+
+// Invite lets a user who was created without a password set one. Only its sha256 hash is stored, the plain
+// invite is told once to whoever creates the user, who passes it on.
+type Invite struct {
+	UserID    int64
+	TenantID  int64
+	Hash      string
+	ExpiresAt time.Time
+}
+
+// newInvite returns a plain invite and its hash
+func newInvite() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	plain := InvitePrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return plain, hashInvite(plain), nil
+}
+
+func hashInvite(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
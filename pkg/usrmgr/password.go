@@ -0,0 +1,26 @@
+package usrmgr
+
+import (
+	"crypto/rand"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword returns bcrypt hash of password. An empty password is replaced by random bytes, so
+// users which are provisioned from an external identity source get an unusable hash.
+func HashPassword(password string, cost int) (string, error) {
+	secret := []byte(password)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return "", err
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword(secret, cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
@@ -3,36 +3,80 @@ package usrmgr
 import (
 	"database/sql"
 	"github.com/vespaiach/auth/pkg/common"
+	"github.com/vespaiach/auth/pkg/condition"
+	"github.com/vespaiach/auth/pkg/keymatch"
 	"regexp"
 	"strings"
+	"time"
 )
 
+var attributeReg = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// localeReg matches language tags such as vi, en-US or zh-Hant-TW
+var localeReg = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8}){0,2}$`)
+
 var emailReg = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
 type Storer interface {
 	AddUser(username string, email string, hash string) (int64, error)
-	ModifyUser(id int64, username string, email string, hash string, active sql.NullBool) error
+	ModifyUser(id int64, username string, email string, hash string, active sql.NullBool,
+		locale sql.NullString) error
+	DeleteUser(id int64) error
 	GetUserByUsername(username string) (*User, error)
 	GetUserByEmail(email string) (*User, error)
 	GetUser(id int64) (*User, error)
-	AddBunchesToUser(userID int64, bunchIDs []int64) error
-	QueryUsers(take int64, skip int64, username string, email string, active sql.NullBool, sortby string,
-		direction common.SortingDirection) ([]*User, int64, error)
+	AddBunchesToUser(userID int64, bunchIDs []int64, check func(held []int64) error) error
+	RemoveBunchesFromUser(userID int64, bunchIDs []int64) error
+	QueryUsers(take int64, skip int64, username string, email string, active sql.NullBool, userType string,
+		sortby string, direction common.SortingDirection) ([]*User, int64, error)
 	GetBunchIDs(bunches []string) ([]int64, error)
 	GetBunches(username string) ([]*Bunch, error)
 	GetKeys(username string) ([]*Key, error)
+	GetExclusionsOfBunches(bunchIDs []int64) ([]*Exclusion, error)
+	ImportUsers(rows []*ImportRow) error
+	GetInvite(hash string) (*Invite, error)
+	AcceptInvite(userID int64, inviteHash string, hash string) error
+	AddServiceAccount(username string, ownerID int64, desc string, hash string) (int64, error)
+	ModifyServiceAccount(id int64, ownerID int64, desc string) error
+	SetBunchCondition(userID int64, bunchID int64, expression sql.NullString) error
+	GetAttributes(userID int64) (map[string]string, error)
+	SetAttributes(userID int64, attributes map[string]string) error
+	GetKeyIDs(keys []string) ([]int64, error)
+	AddDenials(userID int64, keyIDs []int64) error
+	RemoveDenials(userID int64, keyIDs []int64) error
+	GetDenials(username string) ([]*Denial, error)
+	GetGrants(username string) ([]*Grant, error)
+	WithTenant(tenantID int64) Storer
 }
 
 type Service interface {
 	AddUser(username string, email string, hash string) (int64, error)
-	ModifyUser(id int64, username string, email string, hash string, active sql.NullBool) error
+	AddExternalUser(username string, email string, hash string) (int64, error)
+	ModifyUser(id int64, username string, email string, hash string, active sql.NullBool,
+		locale sql.NullString) error
+	DeleteUser(id int64) error
 	GetUserByUsername(username string) (*User, error)
+	GetUserByEmail(email string) (*User, error)
 	GetUser(id int64) (*User, error)
-	QueryUsers(page int64, perPage int64, username string, email string, active sql.NullBool,
+	QueryUsers(page int64, perPage int64, username string, email string, active sql.NullBool, userType string,
 		order string) ([]*User, int64, error)
+	AddServiceAccount(name string, owner string, desc string, hash string) (int64, error)
+	ModifyServiceAccount(name string, owner string, desc string) error
 	AddBunchesToUser(username string, bunches []string) error
+	RemoveBunchesFromUser(username string, bunches []string) error
+	SyncBunches(username string, managed []string, wanted []string) error
 	GetBunches(username string) ([]*Bunch, error)
 	GetKeys(username string) ([]*Key, error)
+	SetBunchCondition(username string, bunch string, expression string) error
+	GetAttributes(username string) (map[string]string, error)
+	SetAttributes(username string, attributes map[string]string) error
+	AddDenials(username string, keys []string) error
+	RemoveDenials(username string, keys []string) error
+	GetDenials(username string) ([]*Denial, error)
+	Explain(username string, key string) (*Explanation, error)
+	ImportUsers(rows []*ImportRow, opts *ImportOptions) ([]*ImportResult, error)
+	AcceptInvite(invite string, hash string) error
+	WithTenant(tenantID int64) Service
 }
 
 type service struct {
@@ -43,13 +87,24 @@ func NewService(st Storer) Service {
 	return &service{st}
 }
 
+// WithTenant returns the service working on users of the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st.WithTenant(tenantID)}
+}
+
 func (s *service) AddUser(username string, email string, hash string) (int64, error) {
-	if !s.isValidName(username) {
-		return 0, common.ErrUsernameInvalid
-	}
+	return s.addUser(username, email, hash, false)
+}
 
-	if !s.isValidEmail(email) {
-		return 0, common.ErrEmailInvalid
+// AddExternalUser adds a user provisioned from an external identity source, which may not tell an email
+func (s *service) AddExternalUser(username string, email string, hash string) (int64, error) {
+	return s.addUser(username, email, hash, true)
+}
+
+func (s *service) addUser(username string, email string, hash string, optionalEmail bool) (int64, error) {
+	withEmail := len(email) > 0 || !optionalEmail
+	if err := validateUser(username, email, withEmail); err != nil {
+		return 0, err
 	}
 
 	dupName, err := s.isDuplicatedUsername(username)
@@ -60,12 +115,14 @@ func (s *service) AddUser(username string, email string, hash string) (int64, er
 		return 0, common.ErrDuplicatedUsername
 	}
 
-	dupEmail, err := s.isDuplicatedEmail(email)
-	if err != nil {
-		return 0, err
-	}
-	if dupEmail {
-		return 0, common.ErrDuplicatedEmail
+	if withEmail {
+		dupEmail, err := s.isDuplicatedEmail(email)
+		if err != nil {
+			return 0, err
+		}
+		if dupEmail {
+			return 0, common.ErrDuplicatedEmail
+		}
 	}
 
 	if len(hash) == 0 {
@@ -75,7 +132,11 @@ func (s *service) AddUser(username string, email string, hash string) (int64, er
 	return s.st.AddUser(username, email, hash)
 }
 
-func (s *service) ModifyUser(id int64, username string, email string, hash string, active sql.NullBool) error {
+// ModifyUser changes the fields which are given. A valid empty locale clears the user's locale, which leaves the
+// language of messages to the user's requests.
+func (s *service) ModifyUser(id int64, username string, email string, hash string, active sql.NullBool,
+	locale sql.NullString) error {
+
 	updating, err := s.st.GetUser(id)
 	if err != nil {
 		return err
@@ -84,49 +145,75 @@ func (s *service) ModifyUser(id int64, username string, email string, hash strin
 		return common.ErrUserNotFound
 	}
 
-	if len(username) > 0 {
-		if !s.isValidName(username) {
-			return common.ErrUsernameInvalid
+	invalid := new(common.ValidationError)
+	if len(username) > 0 && !isValidName(username) {
+		invalid.Add("username", common.ErrUsernameInvalid)
+	}
+	if len(email) > 0 && !isValidEmail(email) {
+		invalid.Add("email", common.ErrEmailInvalid)
+	}
+	if len(locale.String) > 0 && !localeReg.MatchString(locale.String) {
+		invalid.Add("locale", common.ErrLocaleInvalid)
+	}
+	if err := invalid.Err(); err != nil {
+		return err
+	}
+
+	if len(username) > 0 && username != updating.Username {
+		dup, err := s.isDuplicatedUsername(username)
+		if err != nil {
+			return err
 		}
-		if username != updating.Username {
-			dup, err := s.isDuplicatedUsername(username)
-			if err != nil {
-				return err
-			}
-			if dup {
-				return common.ErrDuplicatedUsername
-			}
+		if dup {
+			return common.ErrDuplicatedUsername
 		}
 	}
 
-	if len(email) > 0 {
-		if !s.isValidEmail(email) {
-			return common.ErrEmailInvalid
+	if len(email) > 0 && email != updating.Email {
+		dup, err := s.isDuplicatedEmail(email)
+		if err != nil {
+			return err
 		}
-		if email != updating.Email {
-			dup, err := s.isDuplicatedEmail(email)
-			if err != nil {
-				return err
-			}
-			if dup {
-				return common.ErrDuplicatedEmail
-			}
+		if dup {
+			return common.ErrDuplicatedEmail
 		}
 	}
 
-	return s.st.ModifyUser(id, username, email, hash, active)
+	return s.st.ModifyUser(id, username, email, hash, active, locale)
+}
+
+// DeleteUser removes the user along with its grants, its sessions are revoked. Service accounts which the user
+// owns are kept without an owner.
+func (s *service) DeleteUser(id int64) error {
+	deleting, err := s.st.GetUser(id)
+	if err != nil {
+		return err
+	}
+	if deleting == nil {
+		return common.ErrUserNotFound
+	}
+
+	return s.st.DeleteUser(id)
 }
 
 func (s *service) GetUserByUsername(username string) (*User, error) {
 	return s.st.GetUserByUsername(username)
 }
 
+func (s *service) GetUserByEmail(email string) (*User, error) {
+	return s.st.GetUserByEmail(email)
+}
+
 func (s *service) GetUser(id int64) (*User, error) {
 	return s.st.GetUser(id)
 }
 
 func (s *service) QueryUsers(page int64, perPage int64, username string, email string, active sql.NullBool,
-	order string) ([]*User, int64, error) {
+	userType string, order string) ([]*User, int64, error) {
+
+	if len(userType) > 0 && userType != TypeHuman && userType != TypeService {
+		return nil, 0, common.ErrUserTypeInvalid
+	}
 
 	var (
 		sortby    string
@@ -155,7 +242,55 @@ func (s *service) QueryUsers(page int64, perPage int64, username string, email s
 		direction = common.Descending
 	}
 
-	return s.st.QueryUsers(take, skip, username, email, active, sortby, direction)
+	return s.st.QueryUsers(take, skip, username, email, active, userType, sortby, direction)
+}
+
+// AddServiceAccount creates a service account owned by an existing human user. Service accounts have
+// neither password nor email, so they can't login and only authenticate with access tokens. Their hash is
+// the one of random bytes, which no password matches.
+func (s *service) AddServiceAccount(name string, owner string, desc string, hash string) (int64, error) {
+	if !isValidName(name) {
+		return 0, common.ErrUsernameInvalid
+	}
+
+	if len(hash) == 0 {
+		return 0, common.ErrMissingHash
+	}
+
+	dup, err := s.isDuplicatedUsername(name)
+	if err != nil {
+		return 0, err
+	}
+	if dup {
+		return 0, common.ErrDuplicatedUsername
+	}
+
+	ownerID, err := s.getOwnerID(owner)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.st.AddServiceAccount(name, ownerID, desc, hash)
+}
+
+// ModifyServiceAccount changes owner and description of a service account, empty values are left unchanged
+func (s *service) ModifyServiceAccount(name string, owner string, desc string) error {
+	account, err := s.st.GetUserByUsername(name)
+	if err != nil {
+		return err
+	}
+	if account == nil || !account.IsServiceAccount() {
+		return common.ErrServiceAccountNotFound
+	}
+
+	var ownerID int64
+	if len(owner) > 0 {
+		if ownerID, err = s.getOwnerID(owner); err != nil {
+			return err
+		}
+	}
+
+	return s.st.ModifyServiceAccount(account.ID, ownerID, desc)
 }
 
 func (s *service) AddBunchesToUser(username string, bunches []string) error {
@@ -176,7 +311,220 @@ func (s *service) AddBunchesToUser(username string, bunches []string) error {
 			return common.ErrKeyNotFound
 		}
 
-		return s.st.AddBunchesToUser(user.ID, bunchIDs)
+		exclusions, err := s.st.GetExclusionsOfBunches(bunchIDs)
+		if err != nil {
+			return err
+		}
+
+		// user's bunches are checked in the same transaction which grants the new ones, so that concurrent
+		// grants can't add up to two bunches of an exclusion set
+		var check func(held []int64) error
+		if len(exclusions) > 0 {
+			check = func(held []int64) error {
+				return conflictOf(exclusions, held, bunchIDs)
+			}
+		}
+
+		return s.st.AddBunchesToUser(user.ID, bunchIDs, check)
+	}
+
+	return nil
+}
+
+func (s *service) RemoveBunchesFromUser(username string, bunches []string) error {
+	user, err := s.st.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return common.ErrUserNotFound
+	}
+
+	if len(bunches) > 0 {
+		bunchIDs, err := s.st.GetBunchIDs(bunches)
+		if err != nil {
+			return err
+		}
+		if len(bunchIDs) == 0 {
+			return common.ErrBunchNotFound
+		}
+
+		return s.st.RemoveBunchesFromUser(user.ID, bunchIDs)
+	}
+
+	return nil
+}
+
+// SyncBunches makes user hold exactly the wanted bunches among the managed ones, which are usually
+// mapped from an external identity source. Bunches which aren't managed are left untouched.
+func (s *service) SyncBunches(username string, managed []string, wanted []string) error {
+	isManaged := make(map[string]bool, len(managed))
+	for _, b := range managed {
+		isManaged[b] = true
+	}
+
+	isWanted := make(map[string]bool, len(wanted))
+	for _, b := range wanted {
+		isWanted[b] = true
+	}
+
+	held, err := s.GetBunches(username)
+	if err != nil {
+		return err
+	}
+
+	holding := make(map[string]bool, len(held))
+	removing := make([]string, 0)
+	for _, b := range held {
+		holding[b.Name] = true
+		if isManaged[b.Name] && !isWanted[b.Name] {
+			removing = append(removing, b.Name)
+		}
+	}
+
+	adding := make([]string, 0)
+	for b := range isWanted {
+		if !holding[b] {
+			adding = append(adding, b)
+		}
+	}
+
+	if len(removing) > 0 {
+		if err := s.RemoveBunchesFromUser(username, removing); err != nil {
+			return err
+		}
+	}
+
+	return s.AddBunchesToUser(username, adding)
+}
+
+// ImportUsers validates every row with the same rules as AddUser, then creates valid rows in
+// batches. Each batch is committed in its own transaction, a failed batch marks all its rows as failed.
+func (s *service) ImportUsers(rows []*ImportRow, opts *ImportOptions) ([]*ImportResult, error) {
+	results := make([]*ImportResult, 0, len(rows))
+	valid := make([]*ImportRow, 0, len(rows))
+	validResults := make([]*ImportResult, 0, len(rows))
+	usernames := make(map[string]bool, len(rows))
+	emails := make(map[string]bool, len(rows))
+
+	for _, row := range rows {
+		result := &ImportResult{Line: row.Line, Username: row.Username, Status: ImportStatusValid}
+		results = append(results, result)
+
+		err := s.validateImportRow(row, usernames, emails)
+		if err != nil {
+			result.Status = ImportStatusFailed
+			result.Error = err
+			continue
+		}
+
+		usernames[row.Username] = true
+		emails[row.Email] = true
+		valid = append(valid, row)
+		validResults = append(validResults, result)
+	}
+
+	if opts.DryRun {
+		return results, nil
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = common.Take
+	}
+
+	for start := 0; start < len(valid); start += batchSize {
+		end := start + batchSize
+		if end > len(valid) {
+			end = len(valid)
+		}
+
+		batch := valid[start:end]
+		invites, err := hashImportRows(batch, opts.BcryptCost)
+		if err == nil {
+			err = s.st.ImportUsers(batch)
+		}
+
+		for i, row := range batch {
+			result := validResults[start+i]
+			switch {
+			case err != nil:
+				result.Status = ImportStatusFailed
+				result.Error = err
+			case len(row.Password) == 0:
+				result.Status = ImportStatusInvited
+				result.Invite = invites[i]
+			default:
+				result.Status = ImportStatusCreated
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// AcceptInvite sets the password hash of the invited user, the invite can't be accepted again. Invites are
+// found in whichever tenant they were made in.
+func (s *service) AcceptInvite(invite string, hash string) error {
+	if len(hash) == 0 {
+		return common.ErrMissingHash
+	}
+
+	inv, err := s.st.GetInvite(hashInvite(invite))
+	if err != nil {
+		return err
+	}
+	if inv == nil || !time.Now().Before(inv.ExpiresAt) {
+		return common.ErrInviteInvalid
+	}
+
+	return s.st.WithTenant(inv.TenantID).AcceptInvite(inv.UserID, inv.Hash, hash)
+}
+
+func (s *service) validateImportRow(row *ImportRow, usernames map[string]bool, emails map[string]bool) error {
+	if !isValidName(row.Username) {
+		return common.ErrUsernameInvalid
+	}
+
+	if !isValidEmail(row.Email) {
+		return common.ErrEmailInvalid
+	}
+
+	if usernames[row.Username] {
+		return common.ErrDuplicatedUsername
+	}
+	dupName, err := s.isDuplicatedUsername(row.Username)
+	if err != nil {
+		return err
+	}
+	if dupName {
+		return common.ErrDuplicatedUsername
+	}
+
+	if emails[row.Email] {
+		return common.ErrDuplicatedEmail
+	}
+	dupEmail, err := s.isDuplicatedEmail(row.Email)
+	if err != nil {
+		return err
+	}
+	if dupEmail {
+		return common.ErrDuplicatedEmail
+	}
+
+	if len(row.Bunches) > 0 {
+		row.BunchIDs, err = s.st.GetBunchIDs(row.Bunches)
+		if err != nil {
+			return err
+		}
+		if len(row.BunchIDs) != len(row.Bunches) {
+			return common.ErrBunchNotFound
+		}
+
+		err = s.checkExclusions(row.Username, row.BunchIDs)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -186,8 +534,258 @@ func (s *service) GetBunches(username string) ([]*Bunch, error) {
 	return s.st.GetBunches(username)
 }
 
+// GetKeys returns keys granted to the user, leaving out keys matched by one of user's denials
 func (s *service) GetKeys(username string) ([]*Key, error) {
-	return s.st.GetKeys(username)
+	keys, err := s.st.GetKeys(username)
+	if err != nil {
+		return nil, err
+	}
+
+	denials, err := s.st.GetDenials(username)
+	if err != nil {
+		return nil, err
+	}
+	if len(denials) == 0 {
+		return keys, nil
+	}
+
+	results := make([]*Key, 0, len(keys))
+	for _, k := range keys {
+		if !isDenied(denials, k.Key) {
+			results = append(results, k)
+		}
+	}
+
+	return results, nil
+}
+
+// AddDenials denies keys to the user whatever bunches grant them
+func (s *service) AddDenials(username string, keys []string) error {
+	user, keyIDs, err := s.lookupDenials(username, keys)
+	if err != nil {
+		return err
+	}
+
+	return s.st.AddDenials(user.ID, keyIDs)
+}
+
+func (s *service) RemoveDenials(username string, keys []string) error {
+	user, keyIDs, err := s.lookupDenials(username, keys)
+	if err != nil {
+		return err
+	}
+
+	return s.st.RemoveDenials(user.ID, keyIDs)
+}
+
+// GetDenials returns keys denied to the user, directly or by bunches the user holds
+func (s *service) GetDenials(username string) ([]*Denial, error) {
+	user, err := s.st.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	return s.st.GetDenials(user.Username)
+}
+
+// Explain lists grants and denials of the user which match the key. The key is granted when no denial matches it
+// and a grant does, it's conditional when all matching grants have conditions.
+func (s *service) Explain(username string, key string) (*Explanation, error) {
+	user, err := s.st.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	grants, err := s.st.GetGrants(user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	denials, err := s.st.GetDenials(user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Explanation{Key: key, Allows: make([]*Grant, 0), Denials: make([]*Denial, 0), Conditional: true}
+	for _, g := range grants {
+		if keymatch.Match(g.Key, key) {
+			result.Allows = append(result.Allows, g)
+			if len(g.Condition) == 0 {
+				result.Conditional = false
+			}
+		}
+	}
+	for _, d := range denials {
+		if keymatch.Match(d.Key, key) {
+			result.Denials = append(result.Denials, d)
+		}
+	}
+
+	result.Granted = len(result.Allows) > 0 && len(result.Denials) == 0
+	result.Conditional = result.Granted && result.Conditional
+
+	return result, nil
+}
+
+func (s *service) lookupDenials(username string, keys []string) (*User, []int64, error) {
+	user, err := s.st.GetUserByUsername(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, common.ErrUserNotFound
+	}
+
+	if len(keys) == 0 {
+		return nil, nil, common.ErrKeyNotFound
+	}
+	keyIDs, err := s.st.GetKeyIDs(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(keyIDs) != len(keys) {
+		return nil, nil, common.ErrKeyNotFound
+	}
+
+	return user, keyIDs, nil
+}
+
+func isDenied(denials []*Denial, key string) bool {
+	for _, d := range denials {
+		if keymatch.Match(d.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExclusions makes sure that user won't hold two bunches of the same exclusion set
+// after being granted bunchIDs
+func (s *service) checkExclusions(username string, bunchIDs []int64) error {
+	exclusions, err := s.st.GetExclusionsOfBunches(bunchIDs)
+	if err != nil {
+		return err
+	}
+	if len(exclusions) == 0 {
+		return nil
+	}
+
+	current, err := s.st.GetBunches(username)
+	if err != nil {
+		return err
+	}
+
+	held := make([]int64, 0, len(current))
+	for _, b := range current {
+		held = append(held, b.ID)
+	}
+
+	return conflictOf(exclusions, held, bunchIDs)
+}
+
+// conflictOf returns the error of the first exclusion set which has two bunches of the held and the added ones
+func conflictOf(exclusions []*Exclusion, heldIDs []int64, addedIDs []int64) error {
+	held := make(map[int64]bool, len(heldIDs)+len(addedIDs))
+	for _, id := range heldIDs {
+		held[id] = true
+	}
+	for _, id := range addedIDs {
+		held[id] = true
+	}
+
+	for _, e := range exclusions {
+		conflicts := make([]string, 0)
+		for i, id := range e.BunchIDs {
+			if held[id] {
+				conflicts = append(conflicts, e.Bunches[i])
+			}
+		}
+
+		if len(conflicts) > 1 {
+			return &common.ExclusionError{Exclusion: e.Name, Bunches: conflicts}
+		}
+	}
+
+	return nil
+}
+
+// getOwnerID returns id of the human user who can own service accounts
+func (s *service) getOwnerID(owner string) (int64, error) {
+	user, err := s.st.GetUserByUsername(owner)
+	if err != nil {
+		return 0, err
+	}
+	if user == nil || user.IsServiceAccount() {
+		return 0, common.ErrOwnerInvalid
+	}
+
+	return user.ID, nil
+}
+
+// SetBunchCondition makes user's grant of the bunch conditional, an empty condition removes it
+func (s *service) SetBunchCondition(username string, bunch string, expression string) error {
+	user, err := s.st.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return common.ErrUserNotFound
+	}
+
+	expression = strings.TrimSpace(expression)
+	expr := sql.NullString{String: expression, Valid: len(expression) > 0}
+	if expr.Valid && condition.Validate(expression) != nil {
+		return common.ErrConditionInvalid
+	}
+
+	held, err := s.st.GetBunches(user.Username)
+	if err != nil {
+		return err
+	}
+	for _, b := range held {
+		if b.Name == bunch {
+			return s.st.SetBunchCondition(user.ID, b.ID, expr)
+		}
+	}
+
+	return common.ErrGrantNotFound
+}
+
+func (s *service) GetAttributes(username string) (map[string]string, error) {
+	user, err := s.st.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, common.ErrUserNotFound
+	}
+
+	return s.st.GetAttributes(user.ID)
+}
+
+// SetAttributes replaces attributes of the user, which conditions of grants may refer to as user.<name>
+func (s *service) SetAttributes(username string, attributes map[string]string) error {
+	user, err := s.st.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return common.ErrUserNotFound
+	}
+
+	for name, value := range attributes {
+		if !attributeReg.MatchString(name) || len(value) > 255 {
+			return common.ErrAttributeInvalid
+		}
+	}
+
+	return s.st.SetAttributes(user.ID, attributes)
 }
 
 func (s *service) isDuplicatedUsername(username string) (bool, error) {
@@ -206,12 +804,35 @@ func (s *service) isDuplicatedEmail(email string) (bool, error) {
 	return existing != nil, nil
 }
 
-func (s *service) isValidName(name string) bool {
+// ValidateUser checks username and email of a user which is stored by other means than the service, e.g. the
+// first admin of a new tenant
+func ValidateUser(username string, email string) error {
+	return validateUser(username, email, true)
+}
+
+// ValidateExternalUser checks username and email of a user which is loaded from an external source, e.g. an
+// imported document, such users may have no email
+func ValidateExternalUser(username string, email string) error {
+	return validateUser(username, email, len(email) > 0)
+}
+
+func validateUser(username string, email string, withEmail bool) error {
+	invalid := new(common.ValidationError)
+	if !isValidName(username) {
+		invalid.Add("username", common.ErrUsernameInvalid)
+	}
+	if withEmail && !isValidEmail(email) {
+		invalid.Add("email", common.ErrEmailInvalid)
+	}
+	return invalid.Err()
+}
+
+func isValidName(name string) bool {
 	matched, err := regexp.Match(`^[a-zA-Z0-9_]{1,32}$`, []byte(name))
 	return err == nil && matched
 }
 
-func (s *service) isValidEmail(email string) bool {
+func isValidEmail(email string) bool {
 	if !emailReg.MatchString(email) {
 		return false
 	}
@@ -0,0 +1,60 @@
+package usrmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+// grants is a store which knows three bunches, two of which exclude each other, grants are checked against
+// bunches which the store holds when they're added
+type grants struct {
+	store
+	held []int64
+}
+
+var grantBunches = map[string]int64{"buyer_role": 1, "seller_role": 2, "staff_role": 3}
+
+func (st *grants) GetBunchIDs(names []string) ([]int64, error) {
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		if id, ok := grantBunches[name]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (st *grants) GetExclusionsOfBunches(bunchIDs []int64) ([]*Exclusion, error) {
+	for _, id := range bunchIDs {
+		if id == 1 || id == 2 {
+			return []*Exclusion{{ID: 1, Name: "trade", BunchIDs: []int64{1, 2},
+				Bunches: []string{"buyer_role", "seller_role"}}}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (st *grants) AddBunchesToUser(userID int64, bunchIDs []int64, check func(held []int64) error) error {
+	if check != nil {
+		if err := check(st.held); err != nil {
+			return err
+		}
+	}
+	st.held = append(st.held, bunchIDs...)
+	return nil
+}
+
+func TestService_AddBunchesToUser(t *testing.T) {
+	st := &grants{held: []int64{1}}
+	s := NewService(st)
+
+	require.Nil(t, s.AddBunchesToUser("admin", []string{"staff_role"}))
+	require.Equal(t, common.ErrUserNotFound, s.AddBunchesToUser("nobody", []string{"staff_role"}))
+
+	// the grant is checked against the bunches which are held when it's added
+	err := s.AddBunchesToUser("admin", []string{"seller_role"})
+	require.Equal(t, &common.ExclusionError{Exclusion: "trade", Bunches: []string{"buyer_role", "seller_role"}}, err)
+	require.Equal(t, []int64{1, 3}, st.held)
+}
@@ -5,14 +5,33 @@ import (
 	"time"
 )
 
+// Types of users
+const (
+	TypeHuman   = "user"
+	TypeService = "service"
+)
+
+// User is either a human or a service account. Service accounts have no password nor email, they are
+// owned by a human user and authenticate with access tokens only.
 type User struct {
 	ID        int64
+	TenantID  int64
 	Username  string
 	Email     string
 	Hash      string
 	Active    sql.NullBool
+	Type      string
+	Owner     string
+	Desc      string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Locale is the language tag which the user prefers messages in, it's empty when the user has no preference
+	Locale string
+}
+
+func (u *User) IsServiceAccount() bool {
+	return u.Type == TypeService
 }
 
 type Bunch struct {
@@ -20,14 +39,54 @@ type Bunch struct {
 	Name      string
 	Desc      string
 	Active    sql.NullBool
+	Condition string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// Key is a key granted to a user. Conditions holds the conditions of the grants which lead to the key, the key
+// is granted when one of them holds. It's empty when the key is granted without conditions by any of them.
 type Key struct {
-	ID        int64
+	ID         int64
+	Key        string
+	Desc       string
+	Conditions []string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// IsConditional tells whether the key is only granted when one of its conditions holds
+func (k *Key) IsConditional() bool {
+	return len(k.Conditions) > 0
+}
+
+// Denial is a key denied to a user, either directly or by one of the bunches the user holds.
+// Denials take precedence over any grant of a matching key.
+type Denial struct {
+	Key   string
+	Bunch string
+}
+
+// Grant is a key granted to a user through a bunch
+type Grant struct {
+	Bunch     string
 	Key       string
-	Desc      string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	Condition string
+}
+
+// Explanation tells why a key is granted to a user or not
+type Explanation struct {
+	Key         string
+	Granted     bool
+	Conditional bool
+	Allows      []*Grant
+	Denials     []*Denial
+}
+
+// Exclusion is a set of bunches which mustn't be held together by one user
+type Exclusion struct {
+	ID       int64
+	Name     string
+	BunchIDs []int64
+	Bunches  []string
 }
@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it's dead
+	MaxAttempts = 8
+
+	// retries wait for backoffBase, doubled at each attempt up to backoffCap
+	backoffBase = 30 * time.Second
+	backoffCap  = 6 * time.Hour
+
+	// lease is how long a delivery taken by a dispatcher is kept from the others
+	lease = 2 * time.Minute
+
+	// batch is how many events or deliveries are taken at once
+	batch = 100
+
+	// sendTimeout bounds a delivery when the client has no timeout of its own
+	sendTimeout = 10 * time.Second
+
+	// maxAttemptError is the length which errors are cut to in the delivery log
+	maxAttemptError = 255
+)
+
+// Relay is the storage which the dispatcher works on, across tenants
+type Relay interface {
+	// RelayEvents turns outbox events into deliveries to subscriptions wanting them, and reports how many
+	// events were relayed
+	RelayEvents(take int, now time.Time) (int, error)
+
+	// ClaimDeliveries returns pending deliveries which are due at now, keeping them from other dispatchers
+	// until leaseUntil
+	ClaimDeliveries(take int, now time.Time, leaseUntil time.Time) ([]*Delivery, error)
+
+	// AddAttempt logs the attempt and saves the delivery's new status
+	AddAttempt(a *Attempt, d *Delivery) error
+}
+
+// Dispatcher sends events of the outbox to subscriptions. Deliveries are made at least once, receivers
+// should dedupe them by the delivery header.
+type Dispatcher struct {
+	relay    Relay
+	client   *http.Client
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func NewDispatcher(relay Relay, client *http.Client, interval time.Duration) *Dispatcher {
+	timeout := client.Timeout
+	if timeout <= 0 {
+		timeout = sendTimeout
+	}
+
+	return &Dispatcher{relay, client, interval, timeout}
+}
+
+// Run dispatches at every interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Dispatch(ctx); err != nil {
+				log.Println("webhook dispatch:", err)
+			}
+		}
+	}
+}
+
+// Dispatch relays the outbox, then sends the deliveries which are due
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	for {
+		n, err := d.relay.RelayEvents(batch, time.Now())
+		if err != nil {
+			return err
+		}
+		if n < batch {
+			break
+		}
+	}
+
+	take := d.take()
+	for {
+		now := time.Now()
+		deliveries, err := d.relay.ClaimDeliveries(take, now, now.Add(lease))
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			attempt := d.send(ctx, delivery)
+			next(delivery, attempt)
+			if err := d.relay.AddAttempt(attempt, delivery); err != nil {
+				return err
+			}
+		}
+
+		if len(deliveries) < take {
+			return nil
+		}
+	}
+}
+
+// take returns how many deliveries are claimed at once. Deliveries are sent one by one, so as many are taken
+// as can time out within the lease, with one timeout to spare, otherwise the lease could run out before the
+// last of them is sent and another dispatcher would send it again.
+func (d *Dispatcher) take() int {
+	n := int(lease/d.timeout) - 1
+	switch {
+	case n < 1:
+		return 1
+	case n > batch:
+		return batch
+	}
+
+	return n
+}
+
+// send posts the payload to the subscription's URL
+func (d *Dispatcher) send(ctx context.Context, delivery *Delivery) *Attempt {
+	started := time.Now()
+	attempt := &Attempt{DeliveryID: delivery.ID, CreatedAt: started}
+
+	body, err := envelope(delivery)
+	if err != nil {
+		attempt.Error = cut(err.Error())
+		return attempt
+	}
+
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = cut(err.Error())
+		return attempt
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(started.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, started.Unix(), body))
+
+	res, err := d.client.Do(req)
+	attempt.Duration = time.Since(started)
+	if err != nil {
+		attempt.Error = cut(err.Error())
+		return attempt
+	}
+	defer res.Body.Close()
+
+	// the body is drained so that the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+
+	attempt.StatusCode = res.StatusCode
+	if !attempt.Succeeded() {
+		attempt.Error = fmt.Sprintf("receiver responded %d", res.StatusCode)
+	}
+
+	return attempt
+}
+
+// envelope wraps the event's payload with what the receiver needs to tell events apart
+func envelope(d *Delivery) ([]byte, error) {
+	return json.Marshal(&struct {
+		ID         int64           `json:"id"`
+		Type       string          `json:"type"`
+		OccurredAt time.Time       `json:"occurred_at"`
+		Data       json.RawMessage `json:"data"`
+	}{d.EventID, d.EventType, d.EventCreatedAt, json.RawMessage(d.Payload)})
+}
+
+// next moves the delivery on after the attempt: it's done when the attempt succeeded, dead when it has
+// run out of attempts, otherwise it's retried after a backoff
+func next(d *Delivery, a *Attempt) {
+	d.Attempts++
+	d.LastStatusCode = a.StatusCode
+	d.LastError = a.Error
+
+	switch {
+	case a.Succeeded():
+		d.Status = Succeeded
+		d.NextAttemptAt = sql.NullTime{}
+	case d.Attempts >= MaxAttempts:
+		d.Status = Dead
+		d.NextAttemptAt = sql.NullTime{}
+	default:
+		d.Status = Pending
+		d.NextAttemptAt = sql.NullTime{Time: a.CreatedAt.Add(Backoff(d.Attempts)), Valid: true}
+	}
+}
+
+// Backoff returns how long to wait before trying a delivery again after its attempts failed
+func Backoff(attempts int) time.Duration {
+	wait := backoffBase
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= backoffCap {
+			return backoffCap
+		}
+	}
+
+	return wait
+}
+
+func cut(s string) string {
+	if len(s) > maxAttemptError {
+		return s[:maxAttemptError]
+	}
+	return s
+}
@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// relay is an in-memory Relay holding deliveries which are already relayed
+type relay struct {
+	deliveries []*Delivery
+	attempts   []*Attempt
+}
+
+func (r *relay) RelayEvents(take int, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func (r *relay) ClaimDeliveries(take int, now time.Time, leaseUntil time.Time) ([]*Delivery, error) {
+	lst := make([]*Delivery, 0)
+	for _, d := range r.deliveries {
+		if len(lst) == take {
+			break
+		}
+		if d.Status == Pending && !d.NextAttemptAt.Time.After(now) {
+			d.NextAttemptAt.Time = leaseUntil
+			copied := *d
+			lst = append(lst, &copied)
+		}
+	}
+	return lst, nil
+}
+
+func (r *relay) AddAttempt(a *Attempt, d *Delivery) error {
+	r.attempts = append(r.attempts, a)
+	for i, row := range r.deliveries {
+		if row.ID == d.ID {
+			r.deliveries[i] = d
+		}
+	}
+	return nil
+}
+
+func due(d *Delivery) {
+	d.NextAttemptAt.Time = time.Now().Add(-time.Second)
+}
+
+func newDelivery(url string) *Delivery {
+	d := &Delivery{
+		ID:        7,
+		EventID:   3,
+		EventType: UserDeactivated,
+		Payload:   `{"id":1,"username":"alice"}`,
+		URL:       url,
+		Secret:    "secret",
+		Status:    Pending,
+	}
+	d.NextAttemptAt.Valid = true
+	due(d)
+
+	return d
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	r := &relay{deliveries: []*Delivery{newDelivery(srv.URL)}}
+	require.Nil(t, NewDispatcher(r, srv.Client(), time.Second).Dispatch(context.Background()))
+
+	require.Equal(t, "7", received.Header.Get(HeaderDelivery))
+	require.Equal(t, UserDeactivated, received.Header.Get(HeaderEvent))
+
+	timestamp, err := strconv.ParseInt(received.Header.Get(HeaderTimestamp), 10, 64)
+	require.Nil(t, err)
+	require.Equal(t, Sign("secret", timestamp, body), received.Header.Get(HeaderSignature))
+	require.NotEqual(t, Sign("other", timestamp, body), received.Header.Get(HeaderSignature))
+
+	var envelope struct {
+		ID   int64
+		Type string
+		Data map[string]interface{}
+	}
+	require.Nil(t, json.Unmarshal(body, &envelope))
+	require.Equal(t, int64(3), envelope.ID)
+	require.Equal(t, "alice", envelope.Data["username"])
+
+	require.Equal(t, Succeeded, r.deliveries[0].Status)
+	require.Equal(t, 1, r.deliveries[0].Attempts)
+	require.Len(t, r.attempts, 1)
+	require.Equal(t, http.StatusNoContent, r.attempts[0].StatusCode)
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	r := &relay{deliveries: []*Delivery{newDelivery(srv.URL)}}
+	d := NewDispatcher(r, srv.Client(), time.Second)
+
+	require.Nil(t, d.Dispatch(context.Background()))
+	require.Equal(t, Pending, r.deliveries[0].Status)
+	require.Equal(t, "receiver responded 503", r.deliveries[0].LastError)
+	require.WithinDuration(t, time.Now().Add(backoffBase), r.deliveries[0].NextAttemptAt.Time, 5*time.Second)
+
+	// a delivery which isn't due yet is left alone
+	require.Nil(t, d.Dispatch(context.Background()))
+	require.Len(t, r.attempts, 1)
+
+	for i := 1; i < MaxAttempts; i++ {
+		due(r.deliveries[0])
+		require.Nil(t, d.Dispatch(context.Background()))
+	}
+
+	require.Equal(t, Dead, r.deliveries[0].Status)
+	require.Equal(t, MaxAttempts, r.deliveries[0].Attempts)
+	require.False(t, r.deliveries[0].NextAttemptAt.Valid)
+	require.Len(t, r.attempts, MaxAttempts)
+}
+
+func TestDispatcher_Take(t *testing.T) {
+	// every delivery claimed at once has to be sent before the lease runs out
+	d := NewDispatcher(&relay{}, &http.Client{Timeout: 10 * time.Second}, time.Second)
+	require.Equal(t, 11, d.take())
+	require.True(t, time.Duration(d.take()+1)*10*time.Second <= lease)
+
+	d = NewDispatcher(&relay{}, &http.Client{Timeout: time.Second}, time.Second)
+	require.Equal(t, batch, d.take())
+
+	d = NewDispatcher(&relay{}, &http.Client{Timeout: 5 * time.Minute}, time.Second)
+	require.Equal(t, 1, d.take())
+
+	// clients without timeout are bounded by sendTimeout
+	d = NewDispatcher(&relay{}, &http.Client{}, time.Second)
+	require.Equal(t, int(lease/sendTimeout)-1, d.take())
+}
+
+func TestDispatcher_DispatchAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// deliveries beyond one claim are sent in the same dispatch
+	r := &relay{}
+	for i := 0; i < 25; i++ {
+		delivery := newDelivery(srv.URL)
+		delivery.ID = int64(i + 1)
+		r.deliveries = append(r.deliveries, delivery)
+	}
+
+	require.Nil(t, NewDispatcher(r, srv.Client(), time.Second).Dispatch(context.Background()))
+	require.Len(t, r.attempts, 25)
+	for _, delivery := range r.deliveries {
+		require.Equal(t, Succeeded, delivery.Status)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// loopback is refused at dial time, whatever the URL names
+	_, err := NewClient(time.Second).Get(srv.URL)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), errForbiddenHost.Error())
+
+	// redirects are returned to the dispatcher rather than followed
+	redirect := NewClient(time.Second).CheckRedirect
+	require.Equal(t, http.ErrUseLastResponse, redirect(nil, nil))
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, 30*time.Second, Backoff(1))
+	require.Equal(t, time.Minute, Backoff(2))
+	require.Equal(t, 4*time.Minute, Backoff(4))
+	require.Equal(t, backoffCap, Backoff(20))
+}
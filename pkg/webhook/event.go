@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"strings"
+	"time"
+)
+
+// Domain events, they're written to the outbox along with the changes they tell about
+const (
+	UserCreated       = "user.created"
+	UserUpdated       = "user.updated"
+	UserActivated     = "user.activated"
+	UserDeactivated   = "user.deactivated"
+	UserDeleted       = "user.deleted"
+	UserDenialAdded   = "user.denial_added"
+	UserDenialRemoved = "user.denial_removed"
+	GrantAdded        = "grant.added"
+	GrantRevoked      = "grant.revoked"
+	GrantConditioned  = "grant.condition_changed"
+
+	BunchCreated        = "bunch.created"
+	BunchUpdated        = "bunch.updated"
+	BunchActivated      = "bunch.activated"
+	BunchDeactivated    = "bunch.deactivated"
+	BunchKeyAdded       = "bunch.key_added"
+	BunchKeyRemoved     = "bunch.key_removed"
+	BunchKeyConditioned = "bunch.key_condition_changed"
+	BunchDenialAdded    = "bunch.denial_added"
+	BunchDenialRemoved  = "bunch.denial_removed"
+
+	KeyCreated = "key.created"
+	KeyUpdated = "key.updated"
+
+	LoginSucceeded = "login.succeeded"
+)
+
+// EventTypes lists every domain event which can be subscribed to
+var EventTypes = []string{
+	UserCreated, UserUpdated, UserActivated, UserDeactivated, UserDeleted, UserDenialAdded, UserDenialRemoved,
+	GrantAdded, GrantRevoked, GrantConditioned,
+	BunchCreated, BunchUpdated, BunchActivated, BunchDeactivated,
+	BunchKeyAdded, BunchKeyRemoved, BunchKeyConditioned, BunchDenialAdded, BunchDenialRemoved,
+	KeyCreated, KeyUpdated,
+	LoginSucceeded,
+}
+
+// PermissionEvents are the events which change what users are allowed to do, caches of tokens and of
+// permission checks are invalidated by them
+var PermissionEvents = []string{
+	GrantAdded, GrantRevoked, GrantConditioned,
+	BunchKeyAdded, BunchKeyRemoved, BunchKeyConditioned,
+	UserDenialAdded, UserDenialRemoved, BunchDenialAdded, BunchDenialRemoved,
+	UserActivated, UserDeactivated, UserDeleted, BunchActivated, BunchDeactivated,
+}
+
+// Event is a domain event of the outbox, Payload is a json object. Seq numbers events of a tenant in the order
+// which they're committed in, readers of the outbox resume from it.
+type Event struct {
+	ID        int64
+	TenantID  int64
+	Seq       int64
+	Type      string
+	Payload   string
+	CreatedAt time.Time
+}
+
+// Matches reports whether the event type is subscribed to by the pattern. A pattern is either an event type,
+// a group of event types like "user.*", or "*" for every event.
+func Matches(pattern string, typ string) bool {
+	if pattern == "*" || pattern == typ {
+		return true
+	}
+
+	return strings.HasSuffix(pattern, ".*") && strings.HasPrefix(typ, pattern[:len(pattern)-1])
+}
+
+// Expand returns event types matched by the patterns, nil when a pattern matches no event type
+func Expand(patterns []string) []string {
+	for _, pattern := range patterns {
+		if !isValidPattern(pattern) {
+			return nil
+		}
+	}
+
+	types := make([]string, 0, len(EventTypes))
+	for _, typ := range EventTypes {
+		for _, pattern := range patterns {
+			if Matches(pattern, typ) {
+				types = append(types, typ)
+				break
+			}
+		}
+	}
+
+	return types
+}
+
+// isValidPattern reports whether the pattern matches any event type
+func isValidPattern(pattern string) bool {
+	for _, typ := range EventTypes {
+		if Matches(pattern, typ) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,255 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+type Storer interface {
+	AddSubscription(name string, url string, secret string, events []string) (int64, error)
+	GetSubscription(name string) (*Subscription, error)
+	GetSubscriptions() ([]*Subscription, error)
+	ModifySubscription(id int64, url string, events []string, active sql.NullBool) error
+	RemoveSubscription(id int64) error
+	QueryDeliveries(subscriptionID int64, status string, take int64, skip int64) ([]*Delivery, int64, error)
+	GetDelivery(subscriptionID int64, id int64) (*Delivery, error)
+	GetAttempts(deliveryID int64) ([]*Attempt, error)
+	RequeueDelivery(id int64, at time.Time) error
+	GetEvents(afterSeq int64, types []string, take int64) ([]*Event, error)
+	GetLastSeq() (int64, error)
+	WithTenant(tenantID int64) Storer
+}
+
+// Service manages webhook subscriptions and lets their deliveries be looked into. Deliveries are made
+// by the Dispatcher. Events of the outbox can also be read straight away, to be streamed.
+type Service interface {
+	AddSubscription(name string, url string, events []string) (*Subscription, error)
+	GetSubscription(name string) (*Subscription, error)
+	GetSubscriptions() ([]*Subscription, error)
+	ModifySubscription(name string, url string, events []string, active sql.NullBool) error
+	RemoveSubscription(name string) error
+	QueryDeliveries(name string, status string, page int64, perPage int64) ([]*Delivery, int64, error)
+	GetDelivery(name string, id int64) (*Delivery, []*Attempt, error)
+	RetryDelivery(name string, id int64) error
+	GetEvents(afterSeq int64, patterns []string, take int64) ([]*Event, error)
+	GetLastSeq() (int64, error)
+	WithTenant(tenantID int64) Service
+}
+
+type service struct {
+	st Storer
+}
+
+func NewService(st Storer) Service {
+	return &service{st}
+}
+
+// WithTenant returns the service managing webhooks of the tenant
+func (s *service) WithTenant(tenantID int64) Service {
+	return &service{s.st.WithTenant(tenantID)}
+}
+
+// AddSubscription returns the subscription along with its secret, which isn't returned by other calls
+func (s *service) AddSubscription(name string, url string, events []string) (*Subscription, error) {
+	invalid := new(common.ValidationError)
+	if !s.isValidName(name) {
+		invalid.Add("name", common.ErrWebhookNameInvalid)
+	}
+	if !s.isValidURL(url) {
+		invalid.Add("url", common.ErrWebhookURLInvalid)
+	}
+	if !s.isValidEvents(events) {
+		invalid.Add("events", common.ErrWebhookEventsInvalid)
+	}
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.st.GetSubscription(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, common.ErrDuplicatedWebhook
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	_, err = s.st.AddSubscription(name, url, hex.EncodeToString(secret), events)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.st.GetSubscription(name)
+}
+
+func (s *service) GetSubscription(name string) (*Subscription, error) {
+	return s.getSubscription(name)
+}
+
+func (s *service) GetSubscriptions() ([]*Subscription, error) {
+	return s.st.GetSubscriptions()
+}
+
+// ModifySubscription changes the given fields only, url is kept when it's empty and events when they're nil
+func (s *service) ModifySubscription(name string, url string, events []string, active sql.NullBool) error {
+	invalid := new(common.ValidationError)
+	if len(url) > 0 && !s.isValidURL(url) {
+		invalid.Add("url", common.ErrWebhookURLInvalid)
+	}
+	if events != nil && !s.isValidEvents(events) {
+		invalid.Add("events", common.ErrWebhookEventsInvalid)
+	}
+	if err := invalid.Err(); err != nil {
+		return err
+	}
+
+	sub, err := s.getSubscription(name)
+	if err != nil {
+		return err
+	}
+
+	return s.st.ModifySubscription(sub.ID, url, events, active)
+}
+
+// RemoveSubscription removes the subscription along with its deliveries
+func (s *service) RemoveSubscription(name string) error {
+	sub, err := s.getSubscription(name)
+	if err != nil {
+		return err
+	}
+
+	return s.st.RemoveSubscription(sub.ID)
+}
+
+// QueryDeliveries returns deliveries of the subscription, latest first. Asking for dead ones gives
+// the subscription's dead-letter queue.
+func (s *service) QueryDeliveries(name string, status string, page int64, perPage int64) ([]*Delivery, int64, error) {
+	switch status {
+	case "", Pending, Succeeded, Dead:
+	default:
+		return nil, 0, common.ErrDeliveryStatusInvalid
+	}
+
+	sub, err := s.getSubscription(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return s.st.QueryDeliveries(sub.ID, status, perPage, perPage*(page-1))
+}
+
+// GetDelivery returns the delivery with its log of attempts, oldest first
+func (s *service) GetDelivery(name string, id int64) (*Delivery, []*Attempt, error) {
+	delivery, err := s.getDelivery(name, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attempts, err := s.st.GetAttempts(delivery.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return delivery, attempts, nil
+}
+
+// RetryDelivery queues the delivery to be sent right away, with its attempts starting over. It's how
+// deliveries are taken out of the dead-letter queue.
+func (s *service) RetryDelivery(name string, id int64) error {
+	delivery, err := s.getDelivery(name, id)
+	if err != nil {
+		return err
+	}
+
+	return s.st.RequeueDelivery(delivery.ID, time.Now())
+}
+
+// GetEvents returns events of the types matched by patterns which follow the sequence number afterSeq, in the
+// order of commit. Permission events are returned when no pattern is given.
+func (s *service) GetEvents(afterSeq int64, patterns []string, take int64) ([]*Event, error) {
+	types := PermissionEvents
+	if len(patterns) > 0 {
+		if types = Expand(patterns); len(types) == 0 {
+			return nil, common.ErrEventTypeInvalid
+		}
+	}
+
+	return s.st.GetEvents(afterSeq, types, take)
+}
+
+// GetLastSeq returns the sequence number of the latest event, events are read after it when there's no event to
+// resume from
+func (s *service) GetLastSeq() (int64, error) {
+	return s.st.GetLastSeq()
+}
+
+func (s *service) getSubscription(name string) (*Subscription, error) {
+	sub, err := s.st.GetSubscription(name)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, common.ErrWebhookNotFound
+	}
+
+	return sub, nil
+}
+
+func (s *service) getDelivery(name string, id int64) (*Delivery, error) {
+	sub, err := s.getSubscription(name)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.st.GetDelivery(sub.ID, id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, common.ErrDeliveryNotFound
+	}
+
+	return delivery, nil
+}
+
+func (s *service) isValidName(name string) bool {
+	matched, err := regexp.Match(`^[a-zA-Z0-9_-]{1,64}$`, []byte(name))
+	return err == nil && matched
+}
+
+func (s *service) isValidURL(raw string) bool {
+	if len(raw) > 1024 {
+		return false
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && isAllowedHost(u.Hostname())
+}
+
+func (s *service) isValidEvents(events []string) bool {
+	if len(events) == 0 {
+		return false
+	}
+
+	for _, e := range events {
+		if !isValidPattern(e) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,263 @@
+package webhook
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vespaiach/auth/pkg/common"
+)
+
+// subscriptions is an in-memory Storer
+type subscriptions struct {
+	rows       []*Subscription
+	deliveries []*Delivery
+	events     []*Event
+}
+
+func (st *subscriptions) AddSubscription(name string, url string, secret string, events []string) (int64, error) {
+	id := int64(len(st.rows) + 1)
+	st.rows = append(st.rows, &Subscription{ID: id, Name: name, URL: url, Secret: secret, Events: events,
+		Active: true})
+	return id, nil
+}
+
+func (st *subscriptions) GetSubscription(name string) (*Subscription, error) {
+	for _, s := range st.rows {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (st *subscriptions) GetSubscriptions() ([]*Subscription, error) {
+	return st.rows, nil
+}
+
+func (st *subscriptions) ModifySubscription(id int64, url string, events []string, active sql.NullBool) error {
+	for _, s := range st.rows {
+		if s.ID == id {
+			if len(url) > 0 {
+				s.URL = url
+			}
+			if events != nil {
+				s.Events = events
+			}
+			if active.Valid {
+				s.Active = active.Bool
+			}
+		}
+	}
+	return nil
+}
+
+func (st *subscriptions) RemoveSubscription(id int64) error {
+	for i, s := range st.rows {
+		if s.ID == id {
+			st.rows = append(st.rows[:i], st.rows[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (st *subscriptions) QueryDeliveries(subscriptionID int64, status string, take int64,
+	skip int64) ([]*Delivery, int64, error) {
+
+	lst := make([]*Delivery, 0)
+	for _, d := range st.deliveries {
+		if d.SubscriptionID == subscriptionID && (len(status) == 0 || d.Status == status) {
+			lst = append(lst, d)
+		}
+	}
+	return lst, int64(len(lst)), nil
+}
+
+func (st *subscriptions) GetDelivery(subscriptionID int64, id int64) (*Delivery, error) {
+	for _, d := range st.deliveries {
+		if d.SubscriptionID == subscriptionID && d.ID == id {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (st *subscriptions) GetAttempts(deliveryID int64) ([]*Attempt, error) {
+	return []*Attempt{}, nil
+}
+
+func (st *subscriptions) RequeueDelivery(id int64, at time.Time) error {
+	for _, d := range st.deliveries {
+		if d.ID == id {
+			d.Status, d.Attempts = Pending, 0
+			d.NextAttemptAt = sql.NullTime{Time: at, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (st *subscriptions) GetEvents(afterSeq int64, types []string, take int64) ([]*Event, error) {
+	lst := make([]*Event, 0)
+	for _, e := range st.events {
+		for _, typ := range types {
+			if e.Seq > afterSeq && e.Type == typ && int64(len(lst)) < take {
+				lst = append(lst, e)
+			}
+		}
+	}
+	return lst, nil
+}
+
+func (st *subscriptions) GetLastSeq() (int64, error) {
+	if len(st.events) == 0 {
+		return 0, nil
+	}
+	return st.events[len(st.events)-1].Seq, nil
+}
+
+func (st *subscriptions) WithTenant(tenantID int64) Storer {
+	return st
+}
+
+func TestService_AddSubscription(t *testing.T) {
+	st := new(subscriptions)
+	s := NewService(st)
+
+	sub, err := s.AddSubscription("crm", "https://crm.example.com/hooks", []string{"user.*", GrantRevoked})
+	require.Nil(t, err)
+	require.Len(t, sub.Secret, 64)
+	require.True(t, sub.Wants(UserDeactivated))
+	require.True(t, sub.Wants(GrantRevoked))
+	require.False(t, sub.Wants(GrantAdded))
+
+	_, err = s.AddSubscription("crm", "https://crm.example.com/hooks", []string{"*"})
+	require.Equal(t, common.ErrDuplicatedWebhook, err)
+
+	_, err = s.AddSubscription("bad name", "https://crm.example.com/hooks", []string{"*"})
+	require.Equal(t, common.ErrWebhookNameInvalid, err)
+
+	_, err = s.AddSubscription("ftp", "ftp://crm.example.com/hooks", []string{"*"})
+	require.Equal(t, common.ErrWebhookURLInvalid, err)
+
+	_, err = s.AddSubscription("relative", "/hooks", []string{"*"})
+	require.Equal(t, common.ErrWebhookURLInvalid, err)
+
+	// hosts behind the firewall can't be targeted
+	for _, url := range []string{"http://localhost:8080/hooks", "http://127.0.0.1/hooks", "http://10.1.2.3/hooks",
+		"http://192.168.0.10/hooks", "http://169.254.169.254/latest", "http://[::1]/hooks", "http://0.0.0.0/hooks",
+		"http://[fd00::1]/hooks", "http://api.localhost/hooks"} {
+		_, err = s.AddSubscription("internal", url, []string{"*"})
+		require.Equal(t, common.ErrWebhookURLInvalid, err, url)
+	}
+
+	_, err = s.AddSubscription("none", "https://crm.example.com/hooks", nil)
+	require.Equal(t, common.ErrWebhookEventsInvalid, err)
+
+	_, err = s.AddSubscription("unknown", "https://crm.example.com/hooks", []string{"user.archived"})
+	require.Equal(t, common.ErrWebhookEventsInvalid, err)
+
+	_, err = s.AddSubscription("group", "https://crm.example.com/hooks", []string{"tenant.*"})
+	require.Equal(t, common.ErrWebhookEventsInvalid, err)
+
+	// every invalid field is told
+	_, err = s.AddSubscription("bad name", "/hooks", nil)
+	require.Equal(t, &common.ValidationError{Problems: []*common.FieldProblem{
+		{Field: "name", Err: common.ErrWebhookNameInvalid},
+		{Field: "url", Err: common.ErrWebhookURLInvalid},
+		{Field: "events", Err: common.ErrWebhookEventsInvalid},
+	}}, err)
+}
+
+func TestService_ModifySubscription(t *testing.T) {
+	st := new(subscriptions)
+	s := NewService(st)
+
+	_, err := s.AddSubscription("crm", "https://crm.example.com/hooks", []string{"*"})
+	require.Nil(t, err)
+
+	require.Equal(t, common.ErrWebhookNotFound,
+		s.ModifySubscription("erp", "", nil, sql.NullBool{Bool: false, Valid: true}))
+	require.Equal(t, common.ErrWebhookEventsInvalid, s.ModifySubscription("crm", "", []string{}, sql.NullBool{}))
+
+	require.Nil(t, s.ModifySubscription("crm", "", []string{BunchKeyAdded}, sql.NullBool{Bool: false, Valid: true}))
+
+	sub, err := s.GetSubscription("crm")
+	require.Nil(t, err)
+	require.Equal(t, "https://crm.example.com/hooks", sub.URL)
+	require.Equal(t, []string{BunchKeyAdded}, sub.Events)
+
+	// inactive subscriptions want nothing
+	require.False(t, sub.Wants(BunchKeyAdded))
+
+	require.Nil(t, s.RemoveSubscription("crm"))
+	require.Equal(t, common.ErrWebhookNotFound, s.RemoveSubscription("crm"))
+}
+
+func TestService_RetryDelivery(t *testing.T) {
+	st := new(subscriptions)
+	s := NewService(st)
+
+	_, err := s.AddSubscription("crm", "https://crm.example.com/hooks", []string{"*"})
+	require.Nil(t, err)
+	_, err = s.AddSubscription("erp", "https://erp.example.com/hooks", []string{"*"})
+	require.Nil(t, err)
+	st.deliveries = []*Delivery{{ID: 1, SubscriptionID: 1, Status: Dead, Attempts: MaxAttempts}}
+
+	lst, total, err := s.QueryDeliveries("crm", Dead, 1, 10)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), total)
+	require.Len(t, lst, 1)
+
+	_, _, err = s.QueryDeliveries("crm", "failed", 1, 10)
+	require.Equal(t, common.ErrDeliveryStatusInvalid, err)
+
+	// a delivery is only found under its own subscription
+	require.Equal(t, common.ErrDeliveryNotFound, s.RetryDelivery("erp", 1))
+	require.Nil(t, s.RetryDelivery("crm", 1))
+
+	d, attempts, err := s.GetDelivery("crm", 1)
+	require.Nil(t, err)
+	require.Len(t, attempts, 0)
+	require.Equal(t, Pending, d.Status)
+	require.Equal(t, 0, d.Attempts)
+}
+
+func TestService_GetEvents(t *testing.T) {
+	st := &subscriptions{events: []*Event{
+		{ID: 1, Seq: 1, Type: UserCreated},
+		{ID: 3, Seq: 2, Type: GrantAdded},
+		{ID: 2, Seq: 3, Type: LoginSucceeded},
+		{ID: 4, Seq: 4, Type: UserDeactivated},
+		{ID: 5, Seq: 5, Type: BunchKeyConditioned},
+	}}
+	s := NewService(st)
+
+	// permission events are streamed by default, conditions change them as well
+	lst, err := s.GetEvents(0, nil, 10)
+	require.Nil(t, err)
+	require.Len(t, lst, 3)
+	require.Equal(t, GrantAdded, lst[0].Type)
+	require.Equal(t, BunchKeyConditioned, lst[2].Type)
+
+	lst, err = s.GetEvents(2, []string{"user.*"}, 10)
+	require.Nil(t, err)
+	require.Len(t, lst, 1)
+	require.Equal(t, int64(4), lst[0].Seq)
+
+	_, err = s.GetEvents(0, []string{"user.*", "tenant.created"}, 10)
+	require.Equal(t, common.ErrEventTypeInvalid, err)
+
+	seq, err := s.GetLastSeq()
+	require.Nil(t, err)
+	require.Equal(t, int64(5), seq)
+}
+
+func TestMatches(t *testing.T) {
+	require.True(t, Matches("*", LoginSucceeded))
+	require.True(t, Matches("bunch.*", BunchKeyAdded))
+	require.True(t, Matches(GrantAdded, GrantAdded))
+	require.False(t, Matches("bunch.*", "bunches.created"))
+	require.False(t, Matches("user", UserCreated))
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Subscription sends events of its types to URL, each delivery is signed with Secret
+type Subscription struct {
+	ID        int64
+	TenantID  int64
+	Name      string
+	URL       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Wants reports whether the subscription is sent events of the type
+func (s *Subscription) Wants(typ string) bool {
+	if !s.Active {
+		return false
+	}
+
+	for _, pattern := range s.Events {
+		if Matches(pattern, typ) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delivery statuses, a delivery is dead once it has run out of attempts. Dead deliveries make up the dead-letter
+// queue, they are only sent again when they're retried through the API.
+const (
+	Pending   = "pending"
+	Succeeded = "succeeded"
+	Dead      = "dead"
+)
+
+// Delivery is an event to send to a subscription
+type Delivery struct {
+	ID             int64
+	TenantID       int64
+	SubscriptionID int64
+	EventID        int64
+	EventType      string
+	EventCreatedAt time.Time
+	Payload        string
+	URL            string
+	Secret         string
+	Status         string
+	Attempts       int
+	NextAttemptAt  sql.NullTime
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Attempt is an entry of the delivery log, StatusCode is zero when no response was received
+type Attempt struct {
+	ID         int64
+	DeliveryID int64
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+	CreatedAt  time.Time
+}
+
+// Succeeded reports whether the receiver accepted the delivery
+func (a *Attempt) Succeeded() bool {
+	return a.StatusCode >= 200 && a.StatusCode < 300
+}